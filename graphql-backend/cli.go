@@ -0,0 +1,307 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"graphql-backend/graph"
+	"graphql-backend/graph/model"
+	"os"
+	"strings"
+	"time"
+)
+
+// =============================================
+// ========== KOMMANDORADSVERKTYG ============
+// =============================================
+
+// cliUsage visas när kommandot saknas eller är okänt
+const cliUsage = `Usage:
+  graphql-backend [flags]              start the server
+  graphql-backend [flags] <command>    run a command, see below
+
+Commands:
+  graphql-backend bagit export -node <id> -out <dir> [-user <username>]
+  graphql-backend bagit import -bag <dir> -node <id> [-user <username>]
+  graphql-backend import -source <dir|zip> -node <id> [-user <username>] [-sidecar <file>] [-skip-existing]
+  graphql-backend audit verify
+  graphql-backend backup [list]
+  graphql-backend restore (-backup <name> | -at <time>) [-yes]
+`
+
+// runCommand kör ett kommandoradskommando och returnerar programmets exit-kod
+func runCommand(args []string) int {
+	switch args[0] {
+	case "bagit":
+		return runBagitCommand(args[1:])
+	case "import":
+		return runImportCommand(args[1:])
+	case "audit":
+		return runAuditCommand(args[1:])
+	case "backup":
+		return runBackupCommand(args[1:])
+	case "restore":
+		return runRestoreCommand(args[1:])
+	case "help", "-h", "--help":
+		fmt.Print(cliUsage)
+		return 0
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", args[0], cliUsage)
+		return 2
+	}
+}
+
+// runBagitCommand exporterar eller importerar BagIt-paket direkt mot databasen
+func runBagitCommand(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, cliUsage)
+		return 2
+	}
+
+	switch args[0] {
+	case "export":
+		flags := flag.NewFlagSet("bagit export", flag.ContinueOnError)
+		nodeID := flags.String("node", "", "ID of the node to export")
+		outDir := flags.String("out", "", "directory to write the bag to")
+		username := flags.String("user", "", "username that reads are recorded on in nodes with access logging")
+		if err := flags.Parse(args[1:]); err != nil {
+			return 2
+		}
+		if *nodeID == "" || *outDir == "" {
+			fmt.Fprintln(os.Stderr, "bagit export: -node and -out are required")
+			return 2
+		}
+
+		openDB()
+		defer db.Close()
+
+		userID, err := lookupUserID(db, *username)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "bagit export failed: %v\n", err)
+			return 1
+		}
+
+		result, err := graph.ExportBag(db, *nodeID, *outDir, userID)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "bagit export failed: %v\n", err)
+			return 1
+		}
+		fmt.Printf("Exported %d files (%d bytes) to %s\n", result.Files, result.Bytes, result.Directory)
+		return 0
+
+	case "import":
+		flags := flag.NewFlagSet("bagit import", flag.ContinueOnError)
+		bagDir := flags.String("bag", "", "directory containing the bag")
+		nodeID := flags.String("node", "", "ID of the node to import into")
+		username := flags.String("user", "", "username that will own the imported nodes")
+		if err := flags.Parse(args[1:]); err != nil {
+			return 2
+		}
+		if *bagDir == "" || *nodeID == "" {
+			fmt.Fprintln(os.Stderr, "bagit import: -bag and -node are required")
+			return 2
+		}
+
+		openDB()
+		defer db.Close()
+
+		userID, err := lookupUserID(db, *username)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "bagit import failed: %v\n", err)
+			return 1
+		}
+
+		result, err := graph.ImportBag(db, *bagDir, *nodeID, userID)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "bagit import failed: %v\n", err)
+			return 1
+		}
+		fmt.Printf("Imported %d nodes and %d files as node %s\n", result.Nodes, result.Files, result.NodeID)
+		return 0
+
+	default:
+		fmt.Fprintf(os.Stderr, "unknown bagit command %q\n\n%s", args[0], cliUsage)
+		return 2
+	}
+}
+
+// runImportCommand massimporterar ett katalogträd eller ZIP-arkiv direkt mot databasen
+func runImportCommand(args []string) int {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	source := flags.String("source", "", "directory or ZIP archive to import")
+	nodeID := flags.String("node", "", "ID of the node to import into")
+	username := flags.String("user", "", "username that will own the imported nodes")
+	sidecar := flags.String("sidecar", "", "CSV or JSON file with metadata, relative to the source")
+	skipExisting := flags.Bool("skip-existing", false, "skip files that already exist in the target folder")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *source == "" || *nodeID == "" {
+		fmt.Fprintln(os.Stderr, "import: -source and -node are required")
+		return 2
+	}
+
+	openDB()
+	defer db.Close()
+
+	userID, err := lookupUserID(db, *username)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "import failed: %v\n", err)
+		return 1
+	}
+
+	options := graph.BulkImportOptions{Sidecar: *sidecar, SkipExisting: *skipExisting}
+	result, items, err := graph.BulkImport(db, *source, *nodeID, userID, options)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "import failed: %v\n", err)
+		return 1
+	}
+
+	for _, item := range items {
+		if item.Status == model.BulkImportItemStatusFailed {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", item.Path, *item.Message)
+		}
+	}
+	fmt.Printf("Imported %d nodes and %d files into node %s (%d skipped, %d failed)\n",
+		result.Nodes, result.Files, result.TargetNodeID, result.Skipped, result.Failed)
+	if result.Failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// runAuditCommand verifierar revisionsloggens hashkedja direkt mot databasen
+func runAuditCommand(args []string) int {
+	if len(args) == 0 || args[0] != "verify" {
+		fmt.Fprint(os.Stderr, cliUsage)
+		return 2
+	}
+
+	openDB()
+	defer db.Close()
+
+	result, err := graph.VerifyAuditLog(db)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "audit verify failed: %v\n", err)
+		return 1
+	}
+	if !result.Valid {
+		fmt.Printf("Audit log is INVALID at entry %s: %s\n", *result.FirstInvalidID, result.Message)
+		return 1
+	}
+
+	lastHash := ""
+	if result.LastHash != nil {
+		lastHash = *result.LastHash
+	}
+	fmt.Printf("Audit log is valid: %s (last hash %s)\n", result.Message, lastHash)
+	return 0
+}
+
+// runBackupCommand tar en säkerhetskopia av databasen, eller listar de befintliga. Servern kan
+// vara igång under tiden.
+func runBackupCommand(args []string) int {
+	if len(args) > 0 && args[0] == "list" {
+		backups, err := graph.ListBackups()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "backup list failed: %v\n", err)
+			return 1
+		}
+		for _, backup := range backups {
+			kind := "manual"
+			if backup.Scheduled {
+				kind = "scheduled"
+			}
+			fmt.Printf("%s  %s  %-9s  schema %d  %d bytes  %d blob files\n",
+				backup.Name, backup.CreatedAt, kind, backup.SchemaVersion, backup.Size, backup.BlobFiles)
+		}
+		return 0
+	}
+	if len(args) > 0 {
+		fmt.Fprintf(os.Stderr, "unknown backup command %q\n\n%s", args[0], cliUsage)
+		return 2
+	}
+
+	openDB()
+	defer db.Close()
+
+	backup, err := graph.CreateBackup(db, false)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "backup failed: %v\n", err)
+		return 1
+	}
+	fmt.Printf("Created backup %s (%d bytes, %d blob files) in %s\n", backup.Name, backup.Size, backup.BlobFiles, appConfig.Backup.Dir)
+	return 0
+}
+
+// runRestoreCommand ersätter databasen med en säkerhetskopia, utpekad med namn eller med en
+// tidpunkt. Servern måste vara stoppad, och kopian kontrolleras innan något byts ut.
+func runRestoreCommand(args []string) int {
+	flags := flag.NewFlagSet("restore", flag.ContinueOnError)
+	name := flags.String("backup", "", "name or directory of the backup to restore")
+	at := flags.String("at", "", "restore the latest backup taken at or before this time (RFC 3339)")
+	yes := flags.Bool("yes", false, "replace the database without asking")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if (*name == "") == (*at == "") {
+		fmt.Fprintln(os.Stderr, "restore: exactly one of -backup and -at is required")
+		return 2
+	}
+
+	if *at != "" {
+		pointInTime, err := time.Parse(time.RFC3339, *at)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "restore: -at must be a time like 2026-01-31T18:00:00Z: %v\n", err)
+			return 2
+		}
+		if *name, err = graph.FindBackup(pointInTime); err != nil {
+			fmt.Fprintf(os.Stderr, "restore failed: %v\n", err)
+			return 1
+		}
+	}
+
+	if !*yes {
+		fmt.Printf("Replace %s with backup %s? The server must be stopped. [y/N] ", appConfig.Database.DSN, *name)
+		answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		if answer = strings.ToLower(strings.TrimSpace(answer)); answer != "y" && answer != "yes" {
+			fmt.Println("Restore cancelled")
+			return 1
+		}
+	}
+
+	result, err := graph.RestoreBackup(*name, appConfig.Database.DSN)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "restore failed: %v\n", err)
+		return 1
+	}
+	fmt.Printf("Restored backup %s taken %s to %s\n", result.Backup, result.CreatedAt, result.DatabasePath)
+	if result.PreviousDatabase != "" {
+		fmt.Printf("The previous database was moved to %s\n", result.PreviousDatabase)
+	}
+	for _, dir := range result.BlobDirs {
+		fmt.Printf("Restored %s\n", dir)
+	}
+	for _, dir := range result.PreviousBlobDirs {
+		fmt.Printf("Previous blob contents were moved to %s\n", dir)
+	}
+	if appConfig.Database.UpdateScript != "" {
+		fmt.Printf("Warning: database.update_script runs %s when the server starts, remove it from the configuration if it resets the database\n", appConfig.Database.UpdateScript)
+	}
+	return 0
+}
+
+// lookupUserID hämtar ID för ett användarnamn (tom sträng om inget namn angetts)
+func lookupUserID(db *sql.DB, username string) (string, error) {
+	if username == "" {
+		return "", nil
+	}
+
+	user, err := graph.NewServices(db).Users.GetByUsername(context.Background(), username)
+	if err != nil {
+		return "", fmt.Errorf("%s: %v", username, err)
+	}
+	return user.ID, nil
+}
@@ -0,0 +1,440 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"graphql-backend/graph"
+	"io"
+	"net/mail"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// =============================================
+// ========== KONFIGURATION ===================
+// =============================================
+
+// Config innehåller alla inställningar för servern och kommandoradsverktygen. Värdena läses i
+// ordningen standardvärden, konfigurationsfil, miljövariabler och flaggor, där det senare vinner.
+type Config struct {
+	Server   ServerConfig   `yaml:"server" toml:"server"`
+	Database DatabaseConfig `yaml:"database" toml:"database"`
+	Storage  StorageConfig  `yaml:"storage" toml:"storage"`
+	Auth     AuthConfig     `yaml:"auth" toml:"auth"`
+	Jobs     JobsConfig     `yaml:"jobs" toml:"jobs"`
+	Webhooks WebhooksConfig `yaml:"webhooks" toml:"webhooks"`
+	WebDAV   WebDAVConfig   `yaml:"webdav" toml:"webdav"`
+	OAI      OAIConfig      `yaml:"oai" toml:"oai"`
+	Backup   BackupConfig   `yaml:"backup" toml:"backup"`
+}
+
+// ServerConfig styr HTTP-servern
+type ServerConfig struct {
+	Port int `yaml:"port" toml:"port"`
+	// Ursprung som frontend får anropa servern från, både med vanliga anrop och websocket
+	CORSOrigins []string `yaml:"cors_origins" toml:"cors_origins"`
+	// GraphQL-adressen som sandboxen på /sandbox skickar frågor till. Tom ger servern på localhost.
+	SandboxEndpoint string `yaml:"sandbox_endpoint" toml:"sandbox_endpoint"`
+}
+
+// DatabaseConfig pekar ut databasen
+type DatabaseConfig struct {
+	// Sökväg till en SQLite-fil eller en postgres://-URL
+	DSN string `yaml:"dsn" toml:"dsn"`
+	// Skriptet som återställer SQLite-databasen vid start. Tomt behåller databasen mellan starterna.
+	UpdateScript string `yaml:"update_script" toml:"update_script"`
+}
+
+// StorageConfig pekar ut katalogerna för exporter och importer
+type StorageConfig struct {
+	// Katalogen där exporterna skrivs
+	ExportDir string `yaml:"export_dir" toml:"export_dir"`
+	// Katalogen som importer via API:et läser paket och katalogträd från
+	ImportDir string `yaml:"import_dir" toml:"import_dir"`
+}
+
+// AuthConfig styr inloggningen
+type AuthConfig struct {
+	// Hemligheten som token signeras med
+	JWTSecret string `yaml:"jwt_secret" toml:"jwt_secret"`
+	// Hur länge en token gäller efter inloggningen
+	TokenLifetime time.Duration `yaml:"token_lifetime" toml:"token_lifetime"`
+}
+
+// JobsConfig styr bakgrundsjobben
+type JobsConfig struct {
+	Workers int `yaml:"workers" toml:"workers"`
+}
+
+// WebhooksConfig styr omförsöken av webhookleveranser
+type WebhooksConfig struct {
+	// Fördröjningen före första omförsöket, som sedan fördubblas för varje försök
+	RetryBaseDelay time.Duration `yaml:"retry_base_delay" toml:"retry_base_delay"`
+	// Längsta fördröjning mellan två försök
+	RetryMaxDelay time.Duration `yaml:"retry_max_delay" toml:"retry_max_delay"`
+}
+
+// WebDAVConfig styr låsen i WebDAV
+type WebDAVConfig struct {
+	// Tidsgränsen för lås där klienten inte begär någon
+	LockDefaultTimeout time.Duration `yaml:"lock_default_timeout" toml:"lock_default_timeout"`
+	// Längsta tid ett lås gäller innan det måste förnyas
+	LockMaxTimeout time.Duration `yaml:"lock_max_timeout" toml:"lock_max_timeout"`
+}
+
+// OAIConfig beskriver arkivet för skördare på /oai
+type OAIConfig struct {
+	RepositoryName       string `yaml:"repository_name" toml:"repository_name"`
+	RepositoryIdentifier string `yaml:"repository_identifier" toml:"repository_identifier"`
+	AdminEmail           string `yaml:"admin_email" toml:"admin_email"`
+}
+
+// BackupConfig styr säkerhetskopiorna
+type BackupConfig struct {
+	// Katalogen där säkerhetskopiorna sparas
+	Dir string `yaml:"dir" toml:"dir"`
+	// Kataloger med innehåll utanför databasen som kopieras med databasen
+	BlobDirs []string `yaml:"blob_dirs" toml:"blob_dirs"`
+	// Hur ofta en säkerhetskopia tas automatiskt. 0 stänger av schemat.
+	Interval time.Duration `yaml:"interval" toml:"interval"`
+	// Antal schemalagda säkerhetskopior som sparas
+	Keep int `yaml:"keep" toml:"keep"`
+}
+
+// Minsta tid mellan schemalagda säkerhetskopior
+const minBackupInterval = time.Minute
+
+// Minsta längd på JWT-hemligheten
+const minJWTSecretLength = 16
+
+// Kortaste tid en token får gälla
+const minTokenLifetime = time.Minute
+
+// defaultConfig ger standardvärdena, som motsvarar utvecklingsmiljön
+func defaultConfig() Config {
+	return Config{
+		Server: ServerConfig{
+			Port:        defaultPort,
+			CORSOrigins: []string{"http://localhost:5173"},
+		},
+		Database: DatabaseConfig{
+			DSN: graph.DefaultDatabaseDSN,
+		},
+		Storage: StorageConfig{
+			ExportDir: "exports",
+			ImportDir: "imports",
+		},
+		Auth: AuthConfig{
+			JWTSecret:     graph.DefaultJWTSecret,
+			TokenLifetime: 7 * 24 * time.Hour,
+		},
+		Jobs: JobsConfig{
+			Workers: defaultJobWorkers,
+		},
+		Webhooks: WebhooksConfig{
+			RetryBaseDelay: 30 * time.Second,
+			RetryMaxDelay:  time.Hour,
+		},
+		WebDAV: WebDAVConfig{
+			LockDefaultTimeout: 10 * time.Minute,
+			LockMaxTimeout:     time.Hour,
+		},
+		OAI: OAIConfig{
+			RepositoryName:       "e-Arkive",
+			RepositoryIdentifier: "e-arkive.local",
+			AdminEmail:           "admin@e-arkive.local",
+		},
+		Backup: BackupConfig{
+			Dir:  "backups",
+			Keep: 7,
+		},
+	}
+}
+
+// setting är en inställning som kan ges som miljövariabel och flagga. Namnet är samma som
+// nyckeln i konfigurationsfilen.
+type setting struct {
+	name  string
+	env   string
+	flag  string
+	usage string
+	// field ger fältet i Config: *string, *int, *time.Duration eller *[]string
+	field func(c *Config) interface{}
+}
+
+// settings är alla inställningar som kan ges som miljövariabel eller flagga. JWT-hemligheten
+// saknar flagga, eftersom kommandoraden syns för andra användare på datorn.
+var settings = []setting{
+	{"server.port", "PORT", "port", "port the server listens on",
+		func(c *Config) interface{} { return &c.Server.Port }},
+	{"server.cors_origins", "CORS_ORIGINS", "cors-origins", "comma-separated origins allowed to call the server",
+		func(c *Config) interface{} { return &c.Server.CORSOrigins }},
+	{"server.sandbox_endpoint", "SANDBOX_ENDPOINT", "sandbox-endpoint", "GraphQL URL used by the sandbox page",
+		func(c *Config) interface{} { return &c.Server.SandboxEndpoint }},
+	{"database.dsn", "DATABASE_DSN", "database-dsn", "SQLite file or postgres:// URL",
+		func(c *Config) interface{} { return &c.Database.DSN }},
+	{"database.update_script", "UPDATE_DATABASE_SCRIPT", "update-script", "SQL script to run against the SQLite database at startup, e.g. update_database.sql to reset it",
+		func(c *Config) interface{} { return &c.Database.UpdateScript }},
+	{"storage.export_dir", "EXPORT_DIR", "export-dir", "directory where exports are written",
+		func(c *Config) interface{} { return &c.Storage.ExportDir }},
+	{"storage.import_dir", "IMPORT_DIR", "import-dir", "directory that imports started through the API read from",
+		func(c *Config) interface{} { return &c.Storage.ImportDir }},
+	{"auth.jwt_secret", "JWT_SECRET", "", "",
+		func(c *Config) interface{} { return &c.Auth.JWTSecret }},
+	{"auth.token_lifetime", "TOKEN_LIFETIME", "token-lifetime", "how long a token is valid after login, e.g. 168h",
+		func(c *Config) interface{} { return &c.Auth.TokenLifetime }},
+	{"jobs.workers", "JOB_WORKERS", "job-workers", "number of background job workers",
+		func(c *Config) interface{} { return &c.Jobs.Workers }},
+	{"webhooks.retry_base_delay", "WEBHOOK_RETRY_BASE_DELAY", "webhook-retry-base-delay", "delay before the first webhook retry, doubled for each attempt",
+		func(c *Config) interface{} { return &c.Webhooks.RetryBaseDelay }},
+	{"webhooks.retry_max_delay", "WEBHOOK_RETRY_MAX_DELAY", "webhook-retry-max-delay", "longest delay between webhook retries",
+		func(c *Config) interface{} { return &c.Webhooks.RetryMaxDelay }},
+	{"webdav.lock_default_timeout", "WEBDAV_LOCK_DEFAULT_TIMEOUT", "webdav-lock-default-timeout", "timeout of WebDAV locks that do not ask for one",
+		func(c *Config) interface{} { return &c.WebDAV.LockDefaultTimeout }},
+	{"webdav.lock_max_timeout", "WEBDAV_LOCK_MAX_TIMEOUT", "webdav-lock-max-timeout", "longest time a WebDAV lock lasts before it must be refreshed",
+		func(c *Config) interface{} { return &c.WebDAV.LockMaxTimeout }},
+	{"oai.repository_name", "OAI_REPOSITORY_NAME", "oai-repository-name", "repository name in OAI-PMH Identify",
+		func(c *Config) interface{} { return &c.OAI.RepositoryName }},
+	{"oai.repository_identifier", "OAI_REPOSITORY_IDENTIFIER", "oai-repository-identifier", "namespace of OAI-PMH identifiers",
+		func(c *Config) interface{} { return &c.OAI.RepositoryIdentifier }},
+	{"oai.admin_email", "OAI_ADMIN_EMAIL", "oai-admin-email", "contact address in OAI-PMH Identify",
+		func(c *Config) interface{} { return &c.OAI.AdminEmail }},
+	{"backup.dir", "BACKUP_DIR", "backup-dir", "directory where backups are stored",
+		func(c *Config) interface{} { return &c.Backup.Dir }},
+	{"backup.blob_dirs", "BACKUP_BLOB_DIRS", "backup-blob-dirs", "comma-separated directories with content outside the database",
+		func(c *Config) interface{} { return &c.Backup.BlobDirs }},
+	{"backup.interval", "BACKUP_INTERVAL", "backup-interval", "time between scheduled backups, e.g. 24h (0 disables them)",
+		func(c *Config) interface{} { return &c.Backup.Interval }},
+	{"backup.keep", "BACKUP_KEEP", "backup-keep", "number of scheduled backups to keep",
+		func(c *Config) interface{} { return &c.Backup.Keep }},
+}
+
+// assignSetting tolkar ett värde från en miljövariabel eller flagga till fältets typ
+func assignSetting(field interface{}, value string) error {
+	switch f := field.(type) {
+	case *string:
+		*f = value
+	case *int:
+		n, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil {
+			return fmt.Errorf("%q is not a whole number", value)
+		}
+		*f = n
+	case *time.Duration:
+		d, err := time.ParseDuration(strings.TrimSpace(value))
+		if err != nil {
+			return fmt.Errorf("%q is not a duration like 24h", value)
+		}
+		*f = d
+	case *[]string:
+		*f = nil
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				*f = append(*f, item)
+			}
+		}
+	}
+	return nil
+}
+
+// loadConfig läser konfigurationen för programmet. Flaggorna står före ett eventuellt kommando,
+// och argumenten efter flaggorna returneras. Konfigurationsfilen anges med -config eller
+// CONFIG_FILE. Ett fel beskriver alla ogiltiga inställningar på en gång.
+func loadConfig(args []string) (*Config, []string, error) {
+	flags := flag.NewFlagSet("graphql-backend", flag.ContinueOnError)
+	flags.SetOutput(os.Stderr)
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), cliUsage+"\nFlags:\n")
+		flags.PrintDefaults()
+	}
+
+	configFile := flags.String("config", os.Getenv("CONFIG_FILE"), "YAML or TOML configuration file (CONFIG_FILE)")
+	type flagValue struct {
+		setting setting
+		value   string
+	}
+	var flagValues []flagValue
+	for _, s := range settings {
+		if s.flag == "" {
+			continue
+		}
+		s := s
+		flags.Func(s.flag, fmt.Sprintf("%s (%s, %s)", s.usage, s.name, s.env), func(value string) error {
+			flagValues = append(flagValues, flagValue{s, value})
+			return nil
+		})
+	}
+	if err := flags.Parse(args); err != nil {
+		return nil, nil, err
+	}
+
+	config := defaultConfig()
+	if *configFile != "" {
+		if err := readConfigFile(*configFile, &config); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	var problems []string
+	for _, s := range settings {
+		if value := os.Getenv(s.env); value != "" {
+			if err := assignSetting(s.field(&config), value); err != nil {
+				problems = append(problems, fmt.Sprintf("environment variable %s: %v", s.env, err))
+			}
+		}
+	}
+	for _, fv := range flagValues {
+		if err := assignSetting(fv.setting.field(&config), fv.value); err != nil {
+			problems = append(problems, fmt.Sprintf("flag -%s: %v", fv.setting.flag, err))
+		}
+	}
+
+	if config.Server.SandboxEndpoint == "" {
+		config.Server.SandboxEndpoint = fmt.Sprintf("http://localhost:%d/query", config.Server.Port)
+	}
+	problems = append(problems, config.validate()...)
+	if len(problems) > 0 {
+		return nil, nil, fmt.Errorf("invalid configuration:\n  %s", strings.Join(problems, "\n  "))
+	}
+	return &config, flags.Args(), nil
+}
+
+// readConfigFile läser en konfigurationsfil i YAML eller TOML, efter filändelsen, ovanpå
+// värdena som redan finns i config. Okända nycklar ger fel, så att felstavningar upptäcks.
+func readConfigFile(path string, config *Config) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read configuration file: %v", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(config); err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("invalid configuration file %s: %v", path, err)
+		}
+	case ".toml":
+		meta, err := toml.Decode(string(data), config)
+		if err != nil {
+			return fmt.Errorf("invalid configuration file %s: %v", path, err)
+		}
+		if undecoded := meta.Undecoded(); len(undecoded) > 0 {
+			keys := make([]string, len(undecoded))
+			for i, key := range undecoded {
+				keys[i] = key.String()
+			}
+			return fmt.Errorf("invalid configuration file %s: unknown keys %s", path, strings.Join(keys, ", "))
+		}
+	default:
+		return fmt.Errorf("configuration file %s must end in .yaml, .yml or .toml", path)
+	}
+	return nil
+}
+
+// validate kontrollerar inställningarna och beskriver varje problem med nyckeln i konfigurationsfilen
+func (c *Config) validate() []string {
+	var problems []string
+	add := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if c.Server.Port < 1 || c.Server.Port > 65535 {
+		add("server.port: %d is not a port between 1 and 65535", c.Server.Port)
+	}
+	for _, origin := range c.Server.CORSOrigins {
+		if !isOrigin(origin) {
+			add("server.cors_origins: %q is not an origin like http://localhost:5173", origin)
+		}
+	}
+	if u, err := url.Parse(c.Server.SandboxEndpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		add("server.sandbox_endpoint: %q is not an http or https URL", c.Server.SandboxEndpoint)
+	}
+
+	if strings.TrimSpace(c.Database.DSN) == "" {
+		add("database.dsn: must not be empty")
+	}
+
+	if strings.TrimSpace(c.Storage.ExportDir) == "" {
+		add("storage.export_dir: must not be empty")
+	}
+	if strings.TrimSpace(c.Storage.ImportDir) == "" {
+		add("storage.import_dir: must not be empty")
+	}
+
+	if len(c.Auth.JWTSecret) < minJWTSecretLength {
+		add("auth.jwt_secret: must be at least %d characters", minJWTSecretLength)
+	}
+	if c.Auth.TokenLifetime < minTokenLifetime {
+		add("auth.token_lifetime: must be at least %s, got %s", minTokenLifetime, c.Auth.TokenLifetime)
+	}
+
+	if c.Jobs.Workers < 1 {
+		add("jobs.workers: must be at least 1, got %d", c.Jobs.Workers)
+	}
+
+	if c.Webhooks.RetryBaseDelay < time.Second {
+		add("webhooks.retry_base_delay: must be at least 1s, got %s", c.Webhooks.RetryBaseDelay)
+	}
+	if c.Webhooks.RetryMaxDelay < c.Webhooks.RetryBaseDelay {
+		add("webhooks.retry_max_delay: must be at least webhooks.retry_base_delay (%s), got %s", c.Webhooks.RetryBaseDelay, c.Webhooks.RetryMaxDelay)
+	}
+
+	// Låsens tidsgränser skickas till klienten i hela sekunder
+	if c.WebDAV.LockDefaultTimeout < time.Second {
+		add("webdav.lock_default_timeout: must be at least 1s, got %s", c.WebDAV.LockDefaultTimeout)
+	}
+	if c.WebDAV.LockMaxTimeout < c.WebDAV.LockDefaultTimeout {
+		add("webdav.lock_max_timeout: must be at least webdav.lock_default_timeout (%s), got %s", c.WebDAV.LockDefaultTimeout, c.WebDAV.LockMaxTimeout)
+	}
+
+	if strings.TrimSpace(c.OAI.RepositoryName) == "" {
+		add("oai.repository_name: must not be empty")
+	}
+	if strings.TrimSpace(c.OAI.RepositoryIdentifier) == "" {
+		add("oai.repository_identifier: must not be empty")
+	}
+	if _, err := mail.ParseAddress(c.OAI.AdminEmail); err != nil {
+		add("oai.admin_email: %q is not an e-mail address", c.OAI.AdminEmail)
+	}
+
+	if strings.TrimSpace(c.Backup.Dir) == "" {
+		add("backup.dir: must not be empty")
+	}
+	// Katalogerna sparas under sina namn i säkerhetskopian, så namnen måste vara unika
+	blobNames := map[string]bool{}
+	for _, dir := range c.Backup.BlobDirs {
+		name := filepath.Base(filepath.Clean(dir))
+		if name == "." || name == ".." || name == string(filepath.Separator) {
+			add("backup.blob_dirs: %q does not name a directory", dir)
+		} else if blobNames[name] {
+			add("backup.blob_dirs: more than one directory is named %s", name)
+		}
+		blobNames[name] = true
+	}
+	if c.Backup.Interval < 0 || (c.Backup.Interval > 0 && c.Backup.Interval < minBackupInterval) {
+		add("backup.interval: must be 0 or at least %s, got %s", minBackupInterval, c.Backup.Interval)
+	}
+	if c.Backup.Keep < 1 {
+		add("backup.keep: must be at least 1, got %d", c.Backup.Keep)
+	}
+	return problems
+}
+
+// isOrigin avgör om värdet är ett ursprung: schema och värd, utan sökväg
+func isOrigin(value string) bool {
+	u, err := url.Parse(value)
+	if err != nil {
+		return false
+	}
+	return (u.Scheme == "http" || u.Scheme == "https") && u.Host != "" && u.Path == "" && u.RawQuery == "" && u.User == nil
+}
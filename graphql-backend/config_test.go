@@ -0,0 +1,286 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// clearConfigEnv tömmer miljövariablerna för konfigurationen under testet
+func clearConfigEnv(t *testing.T) {
+	t.Helper()
+	t.Setenv("CONFIG_FILE", "")
+	for _, s := range settings {
+		t.Setenv(s.env, "")
+	}
+}
+
+// writeConfigFile skriver en konfigurationsfil i en tillfällig katalog
+func writeConfigFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("write config file: %v", err)
+	}
+	return path
+}
+
+func TestLoadConfigDefaults(t *testing.T) {
+	clearConfigEnv(t)
+
+	config, args, err := loadConfig(nil)
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	if len(args) != 0 {
+		t.Errorf("args = %v, want none", args)
+	}
+	want := defaultConfig()
+	want.Server.SandboxEndpoint = "http://localhost:8080/query"
+	if !reflect.DeepEqual(*config, want) {
+		t.Errorf("config = %+v, want %+v", *config, want)
+	}
+	// update_database.sql tömmer databasen, så inget skript körs om det inte anges
+	if config.Database.UpdateScript != "" {
+		t.Errorf("update script = %q, want none by default", config.Database.UpdateScript)
+	}
+}
+
+func TestLoadConfigPrecedence(t *testing.T) {
+	clearConfigEnv(t)
+	path := writeConfigFile(t, "config.yaml", `
+server:
+  port: 9000
+  cors_origins: [https://arkiv.example]
+database:
+  dsn: /data/arkiv.db
+jobs:
+  workers: 4
+`)
+	t.Setenv("JOB_WORKERS", "6")
+	t.Setenv("JWT_SECRET", "en-hemlighet-som-är-lång-nog")
+
+	config, args, err := loadConfig([]string{"-config", path, "-job-workers", "8", "audit", "verify"})
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	if !reflect.DeepEqual(args, []string{"audit", "verify"}) {
+		t.Errorf("args = %v, want the command", args)
+	}
+	if config.Server.Port != 9000 || config.Database.DSN != "/data/arkiv.db" {
+		t.Errorf("file values = %d, %s", config.Server.Port, config.Database.DSN)
+	}
+	if !reflect.DeepEqual(config.Server.CORSOrigins, []string{"https://arkiv.example"}) {
+		t.Errorf("cors origins = %v", config.Server.CORSOrigins)
+	}
+	if config.Server.SandboxEndpoint != "http://localhost:9000/query" {
+		t.Errorf("sandbox endpoint = %s, want it to follow the port", config.Server.SandboxEndpoint)
+	}
+	if config.Auth.JWTSecret != "en-hemlighet-som-är-lång-nog" {
+		t.Errorf("jwt secret = %q, want the environment value", config.Auth.JWTSecret)
+	}
+	// Flaggan går före miljövariabeln, som går före filen
+	if config.Jobs.Workers != 8 {
+		t.Errorf("workers = %d, want 8 from the flag", config.Jobs.Workers)
+	}
+	// Värden som inte anges behåller standardvärdet
+	if config.OAI.RepositoryName != "e-Arkive" {
+		t.Errorf("repository name = %q, want the default", config.OAI.RepositoryName)
+	}
+}
+
+func TestLoadConfigTOML(t *testing.T) {
+	clearConfigEnv(t)
+	path := writeConfigFile(t, "config.toml", `
+[database]
+dsn = "postgres://earkive@db/earkive"
+
+[oai]
+repository_identifier = "arkiv.example"
+
+[storage]
+export_dir = "/srv/exporter"
+
+[webhooks]
+retry_base_delay = "1m"
+
+[webdav]
+lock_max_timeout = "2h"
+
+[backup]
+interval = "24h"
+blob_dirs = ["/srv/innehåll"]
+`)
+	t.Setenv("CONFIG_FILE", path)
+
+	config, _, err := loadConfig(nil)
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	if config.Database.DSN != "postgres://earkive@db/earkive" || config.OAI.RepositoryIdentifier != "arkiv.example" {
+		t.Errorf("config = %+v", *config)
+	}
+	if config.Backup.Interval != 24*time.Hour || !reflect.DeepEqual(config.Backup.BlobDirs, []string{"/srv/innehåll"}) {
+		t.Errorf("backup = %+v", config.Backup)
+	}
+	if config.Storage.ExportDir != "/srv/exporter" || config.Storage.ImportDir != "imports" {
+		t.Errorf("storage = %+v", config.Storage)
+	}
+	if config.Webhooks.RetryBaseDelay != time.Minute || config.Webhooks.RetryMaxDelay != time.Hour {
+		t.Errorf("webhooks = %+v", config.Webhooks)
+	}
+	if config.WebDAV.LockDefaultTimeout != 10*time.Minute || config.WebDAV.LockMaxTimeout != 2*time.Hour {
+		t.Errorf("webdav = %+v", config.WebDAV)
+	}
+}
+
+// TestConfigExample kontrollerar att exempelfilen går att läsa och visar standardvärdena
+func TestConfigExample(t *testing.T) {
+	clearConfigEnv(t)
+
+	config, _, err := loadConfig([]string{"-config", "config.example.yaml"})
+	if err != nil {
+		t.Fatalf("load config.example.yaml: %v", err)
+	}
+	want := defaultConfig()
+	want.Server.SandboxEndpoint = "http://localhost:8080/query"
+	// blob_dirs: [] i filen ger en tom lista i stället för standardvärdets nil
+	want.Backup.BlobDirs = []string{}
+	if !reflect.DeepEqual(*config, want) {
+		t.Errorf("config.example.yaml = %+v, want the defaults %+v", *config, want)
+	}
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		env  map[string]string
+		args []string
+		want []string
+	}{
+		{
+			name: "unknown yaml key",
+			file: "config.yaml",
+			want: []string{"field prot not found"},
+		},
+		{
+			name: "unknown toml key",
+			file: "config.toml",
+			want: []string{"unknown keys server.prot"},
+		},
+		{
+			name: "invalid values",
+			env:  map[string]string{"PORT": "åttio", "JWT_SECRET": "kort", "OAI_ADMIN_EMAIL": "ingen"},
+			args: []string{"-job-workers", "0", "-cors-origins", "http://localhost:5173/app", "-sandbox-endpoint", "localhost:8080"},
+			want: []string{
+				`environment variable PORT: "åttio" is not a whole number`,
+				"auth.jwt_secret: must be at least 16 characters",
+				`oai.admin_email: "ingen" is not an e-mail address`,
+				"jobs.workers: must be at least 1, got 0",
+				`server.cors_origins: "http://localhost:5173/app" is not an origin`,
+				`server.sandbox_endpoint: "localhost:8080" is not an http or https URL`,
+			},
+		},
+		{
+			name: "invalid backup settings",
+			env:  map[string]string{"BACKUP_INTERVAL": "dagligen", "BACKUP_BLOB_DIRS": "/a/innehåll,/b/innehåll"},
+			args: []string{"-backup-keep", "0"},
+			want: []string{
+				`environment variable BACKUP_INTERVAL: "dagligen" is not a duration like 24h`,
+				"backup.blob_dirs: more than one directory is named innehåll",
+				"backup.keep: must be at least 1, got 0",
+			},
+		},
+		{
+			name: "invalid directories and timeouts",
+			env:  map[string]string{"EXPORT_DIR": " ", "TOKEN_LIFETIME": "30s", "WEBHOOK_RETRY_BASE_DELAY": "5m"},
+			args: []string{"-import-dir", "", "-webhook-retry-max-delay", "1m", "-webdav-lock-default-timeout", "0", "-webdav-lock-max-timeout", "1h"},
+			want: []string{
+				"storage.export_dir: must not be empty",
+				"storage.import_dir: must not be empty",
+				"auth.token_lifetime: must be at least 1m0s, got 30s",
+				"webhooks.retry_max_delay: must be at least webhooks.retry_base_delay (5m0s), got 1m0s",
+				"webdav.lock_default_timeout: must be at least 1s, got 0s",
+			},
+		},
+		{
+			name: "missing file",
+			args: []string{"-config", "saknas.yaml"},
+			want: []string{"failed to read configuration file"},
+		},
+		{
+			name: "unknown format",
+			file: "config.json",
+			want: []string{"must end in .yaml, .yml or .toml"},
+		},
+	}
+
+	contents := map[string]string{
+		"config.yaml": "server:\n  prot: 9000\n",
+		"config.toml": "[server]\nprot = 9000\n",
+		"config.json": "{}",
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearConfigEnv(t)
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+			args := tt.args
+			if tt.file != "" {
+				args = append([]string{"-config", writeConfigFile(t, tt.file, contents[tt.file])}, args...)
+			}
+
+			_, _, err := loadConfig(args)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error does not mention %q:\n%v", want, err)
+				}
+			}
+		})
+	}
+}
+
+func TestSandboxHTML(t *testing.T) {
+	page := string(sandboxHTML("https://arkiv.example/query?x=</script>"))
+	if !strings.Contains(page, `initialEndpoint: "https://arkiv.example/query?x=\u003c/script\u003e",`) {
+		t.Errorf("sandbox page does not contain the encoded endpoint:\n%s", page)
+	}
+}
+
+// TestCORSAllowsAPIMethods kontrollerar att webbläsare får anropa REST-API:t och WebDAV från frontend
+func TestCORSAllowsAPIMethods(t *testing.T) {
+	handler := corsHandler([]string{"http://localhost:5173"}, http.NotFoundHandler())
+	preflight := func(origin string, method string, headers string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodOptions, "/webdav/", nil)
+		request.Header.Set("Origin", origin)
+		request.Header.Set("Access-Control-Request-Method", method)
+		if headers != "" {
+			request.Header.Set("Access-Control-Request-Headers", headers)
+		}
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	for _, method := range []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE", "PROPFIND", "PROPPATCH", "MKCOL", "COPY", "MOVE", "LOCK", "UNLOCK"} {
+		if allowed := preflight("http://localhost:5173", method, "").Header().Get("Access-Control-Allow-Methods"); allowed != method {
+			t.Errorf("%s: allowed methods = %q", method, allowed)
+		}
+	}
+	if allowed := preflight("http://localhost:5173", "MOVE", "authorization,destination,overwrite").Header().Get("Access-Control-Allow-Headers"); allowed == "" {
+		t.Error("WebDAV headers are not allowed")
+	}
+	if allowed := preflight("http://annan.example", "DELETE", "").Header().Get("Access-Control-Allow-Origin"); allowed != "" {
+		t.Errorf("other origin allowed: %q", allowed)
+	}
+}
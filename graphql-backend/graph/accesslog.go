@@ -0,0 +1,130 @@
+package graph
+
+import (
+	"context"
+	"database/sql"
+	"graphql-backend/graph/model"
+	"log"
+	"time"
+)
+
+// =============================================
+// ========== ÅTKOMSTLOGGNING ================
+// =============================================
+
+// userAgentKey är nyckeln för klientens User-Agent i context
+type userAgentKey struct{}
+
+// WithUserAgent lägger klientens User-Agent i context så att den kan loggas vid läsning av filer
+func WithUserAgent(ctx context.Context, userAgent string) context.Context {
+	return context.WithValue(ctx, userAgentKey{}, userAgent)
+}
+
+// userAgentFromContext hämtar klientens User-Agent från context, eller en tom sträng
+func userAgentFromContext(ctx context.Context) string {
+	userAgent, _ := ctx.Value(userAgentKey{}).(string)
+	return userAgent
+}
+
+// accessLoggingPolicy avgör om läsningar i en nod ska loggas. Närmaste nod uppåt i trädet med en
+// egen inställning avgör; policyNodeID är den noden, eller tom om ingen nod har en inställning.
+func accessLoggingPolicy(db sqlQueryer, nodeID string) (bool, string, error) {
+	visited := make(map[string]bool)
+	for currentID := nodeID; currentID != "" && !visited[currentID]; {
+		visited[currentID] = true
+
+		var parentID sql.NullString
+		var enabled sql.NullBool
+		err := db.QueryRow("SELECT parent_id, access_logging FROM nodes WHERE id = ?", currentID).Scan(&parentID, &enabled)
+		if err == sql.ErrNoRows {
+			return false, "", nil
+		} else if err != nil {
+			log.Printf("Error fetching access logging setting of node %s: %v", currentID, err)
+			return false, "", serviceErrorf(errInternal, "failed to fetch access logging setting: %v", err)
+		}
+
+		if enabled.Valid {
+			return enabled.Bool, currentID, nil
+		}
+		currentID = parentID.String
+	}
+	return false, "", nil
+}
+
+// RecordFileAccess loggar att en fil lästs, om filens nod har åtkomstloggning. Används av allt
+// som lämnar ut filer: getFile, downloadFile, fillistorna, strömmade nedladdningar och exporter.
+func RecordFileAccess(ctx context.Context, db *sql.DB, fileID string, accessType model.FileAccessType) error {
+	var fileName string
+	var nodeID sql.NullString
+	err := db.QueryRow("SELECT name, node_id FROM files WHERE id = ?", fileID).Scan(&fileName, &nodeID)
+	if err == sql.ErrNoRows {
+		return serviceErrorf(errNotFound, "file not found")
+	} else if err != nil {
+		log.Printf("Error fetching file %s for access log: %v", fileID, err)
+		return serviceErrorf(errInternal, "failed to fetch file: %v", err)
+	}
+
+	enabled, _, err := accessLoggingPolicy(db, nodeID.String)
+	if err != nil {
+		return err
+	}
+	if !enabled {
+		return nil
+	}
+
+	// Läsningar i loggade noder kräver en användare att logga; oinloggade läsningar nekas
+	userID, err := getUserIDFromContext(ctx)
+	if err != nil {
+		return err
+	}
+	username, _ := lookupUsername(db, userID)
+
+	_, err = db.Exec(`
+		INSERT INTO file_access_log (file_id, file_name, node_id, user_id, username, access_type, accessed_at, client_ip, user_agent)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, fileID, fileName, nodeID, nullIfEmpty(userID), nullIfEmpty(username), accessType.String(),
+		time.Now().UTC().Format(time.RFC3339), nullIfEmpty(clientIPFromContext(ctx)), nullIfEmpty(userAgentFromContext(ctx)))
+	if err != nil {
+		log.Printf("Error recording access to file %s: %v", fileID, err)
+		return serviceErrorf(errInternal, "failed to record file access: %v", err)
+	}
+
+	log.Printf("Recorded %s of file %s by user %s", accessType, fileID, userID)
+	return nil
+}
+
+// loadFileAccesses hämtar rader ur åtkomstloggen, nyaste först. limit -1 hämtar alla rader.
+func loadFileAccesses(db sqlQueryer, where string, limit int, offset int, args ...interface{}) ([]*model.FileAccess, error) {
+	rows, err := db.Query(`
+		SELECT id, file_id, file_name, node_id, user_id, username, access_type, accessed_at, client_ip, user_agent
+		FROM file_access_log WHERE `+where+` ORDER BY id DESC LIMIT ? OFFSET ?`, append(args, limit, offset)...)
+	if err != nil {
+		log.Printf("Error fetching file access log: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to fetch file access log: %v", err)
+	}
+	defer rows.Close()
+
+	accesses := []*model.FileAccess{}
+	for rows.Next() {
+		var access model.FileAccess
+		var nodeID, userID, username, clientIP, userAgent sql.NullString
+		err := rows.Scan(&access.ID, &access.FileID, &access.FileName, &nodeID, &userID, &username,
+			&access.AccessType, &access.AccessedAt, &clientIP, &userAgent)
+		if err != nil {
+			log.Printf("Error scanning file access: %v", err)
+			return nil, serviceErrorf(errInternal, "failed to read file access log: %v", err)
+		}
+
+		access.NodeID = nullStringPtr(nodeID)
+		access.UserID = nullStringPtr(userID)
+		access.Username = nullStringPtr(username)
+		access.ClientIP = nullStringPtr(clientIP)
+		access.UserAgent = nullStringPtr(userAgent)
+		accesses = append(accesses, &access)
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("Error iterating file access log: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to read file access log: %v", err)
+	}
+	return accesses, nil
+}
@@ -0,0 +1,144 @@
+package graph
+
+// This file will be automatically regenerated based on the schema, any resolver implementations
+// will be copied through when generating and any unknown code will be moved to the end.
+// Code generated by github.com/99designs/gqlgen version v0.17.69
+
+import (
+	"context"
+	"fmt"
+	"graphql-backend/graph/model"
+	"log"
+)
+
+// File is the resolver for the file field.
+func (r *fileAccessResolver) File(ctx context.Context, obj *model.FileAccess) (*model.File, error) {
+	// Historiken finns kvar även för filer som tagits bort
+	files, err := queryTreeFiles(r.DB, "id = ?", obj.FileID)
+	if err != nil || len(files) == 0 {
+		return nil, err
+	}
+	return treeFileModel(files[0]), nil
+}
+
+// User is the resolver for the user field.
+func (r *fileAccessResolver) User(ctx context.Context, obj *model.FileAccess) (*model.User, error) {
+	if obj.UserID == nil {
+		return nil, nil
+	}
+	return getUserByID(r.DB, *obj.UserID)
+}
+
+// SetNodeAccessLogging is the resolver for the setNodeAccessLogging field.
+func (r *mutationResolver) SetNodeAccessLogging(ctx context.Context, nodeID string, enabled *bool) (*model.Node, error) {
+	logAction(fmt.Sprintf("Setting access logging of node %s", nodeID))
+
+	if r.DB == nil {
+		log.Printf("Database connection is nil")
+		return nil, serviceErrorf(errInternal, "internal server error: database connection is not initialized")
+	}
+
+	// Endast administratörer får ändra loggningen, annars kan den som läser stänga av den
+	if _, err := requireAdministrator(ctx, r.DB, "configure access logging"); err != nil {
+		return nil, err
+	}
+
+	result, err := r.DB.Exec("UPDATE nodes SET access_logging = ?, updated_at = datetime('now') WHERE id = ?", enabled, nodeID)
+	if err != nil {
+		log.Printf("Error setting access logging of node %s: %v", nodeID, err)
+		return nil, serviceErrorf(errInternal, "failed to set access logging: %v", err)
+	}
+	if rowsAffected, err := result.RowsAffected(); err == nil && rowsAffected == 0 {
+		return nil, serviceErrorf(errNotFound, "node not found")
+	}
+
+	return getNodeWithPermissions(ctx, r.DB, nodeID)
+}
+
+// AccessLogging is the resolver for the accessLogging field.
+func (r *nodeResolver) AccessLogging(ctx context.Context, obj *model.Node) (*model.AccessLoggingPolicy, error) {
+	if r.DB == nil {
+		return nil, serviceErrorf(errInternal, "internal server error: database connection is not initialized")
+	}
+
+	enabled, policyNodeID, err := accessLoggingPolicy(r.DB, obj.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &model.AccessLoggingPolicy{Enabled: enabled, NodeID: optionalString(policyNodeID)}, nil
+}
+
+// FileAccessHistory is the resolver for the fileAccessHistory field.
+func (r *queryResolver) FileAccessHistory(ctx context.Context, fileID string, limit *int, offset *int) ([]*model.FileAccess, error) {
+	if r.DB == nil {
+		return nil, serviceErrorf(errInternal, "internal server error: database connection is not initialized")
+	}
+
+	if _, err := requireAdministrator(ctx, r.DB, "view file access history"); err != nil {
+		return nil, err
+	}
+
+	pageSize := 100
+	if limit != nil && *limit > 0 {
+		pageSize = *limit
+	}
+	skip := 0
+	if offset != nil && *offset > 0 {
+		skip = *offset
+	}
+
+	return loadFileAccesses(r.DB, "file_id = ?", pageSize, skip, fileID)
+}
+
+// UserAccessReport is the resolver for the userAccessReport field.
+func (r *queryResolver) UserAccessReport(ctx context.Context, userID string, from *string, to *string) (*model.UserAccessReport, error) {
+	if r.DB == nil {
+		return nil, serviceErrorf(errInternal, "internal server error: database connection is not initialized")
+	}
+
+	if _, err := requireAdministrator(ctx, r.DB, "view access reports"); err != nil {
+		return nil, err
+	}
+
+	user, err := getUserByID(r.DB, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, serviceErrorf(errNotFound, "user not found")
+	}
+
+	where := "user_id = ?"
+	args := []interface{}{userID}
+	if from != nil {
+		where += " AND accessed_at >= ?"
+		args = append(args, *from)
+	}
+	if to != nil {
+		// Jämför bara så många tecken som gränsen har, så att "2024-05-31" tar med hela dagen
+		where += " AND substr(accessed_at, 1, length(?)) <= ?"
+		args = append(args, *to, *to)
+	}
+
+	accesses, err := loadFileAccesses(r.DB, where, -1, 0, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	distinctFiles := make(map[string]bool)
+	for _, access := range accesses {
+		distinctFiles[access.FileID] = true
+	}
+
+	return &model.UserAccessReport{
+		User:          user,
+		TotalAccesses: len(accesses),
+		DistinctFiles: len(distinctFiles),
+		Accesses:      accesses,
+	}, nil
+}
+
+// FileAccess returns FileAccessResolver implementation.
+func (r *Resolver) FileAccess() FileAccessResolver { return &fileAccessResolver{r} }
+
+type fileAccessResolver struct{ *Resolver }
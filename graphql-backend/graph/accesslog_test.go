@@ -0,0 +1,188 @@
+package graph
+
+import (
+	"context"
+	"graphql-backend/graph/model"
+	"strings"
+	"testing"
+)
+
+func TestAccessLoggingPolicy(t *testing.T) {
+	db := openTestDB(t)
+	ctx := testAdminContext(t)
+	mutation := NewResolver(db).Mutation()
+	rootID := insertTestNode(t, db, "Gradert", "")
+	childID := insertTestNode(t, db, "Åpen", rootID)
+	grandchildID := insertTestNode(t, db, "Underlag", childID)
+
+	enabled, disabled := true, false
+	if _, err := mutation.SetNodeAccessLogging(ctx, rootID, &enabled); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mutation.SetNodeAccessLogging(ctx, childID, &disabled); err != nil {
+		t.Fatal(err)
+	}
+
+	// Närmaste nod med en egen inställning avgör
+	tests := []struct {
+		nodeID string
+		want   bool
+		from   string
+	}{
+		{rootID, true, rootID},
+		{childID, false, childID},
+		{grandchildID, false, childID},
+	}
+	for _, tt := range tests {
+		if logged, from, err := accessLoggingPolicy(db, tt.nodeID); err != nil || logged != tt.want || from != tt.from {
+			t.Errorf("policy of %s = %v from %s, %v, want %v from %s", tt.nodeID, logged, from, err, tt.want, tt.from)
+		}
+	}
+
+	// Utan inställning ärvs föräldrens igen
+	if _, err := mutation.SetNodeAccessLogging(ctx, childID, nil); err != nil {
+		t.Fatal(err)
+	}
+	if logged, from, err := accessLoggingPolicy(db, grandchildID); err != nil || !logged || from != rootID {
+		t.Errorf("policy after clearing = %v from %s, %v", logged, from, err)
+	}
+
+	bobID := insertTestUser(t, db, "bob")
+	if _, err := mutation.SetNodeAccessLogging(testUserContext(t, bobID, "bob"), rootID, &disabled); err == nil || !strings.HasPrefix(err.Error(), "permission denied") {
+		t.Errorf("disable logging as non-admin: err = %v", err)
+	}
+}
+
+func TestFileAccessLog(t *testing.T) {
+	db := openTestDB(t)
+	resolver := NewResolver(db)
+	admin := testAdminContext(t)
+
+	classified := createTestNode(t, db, "Gradert", nil)
+	open := createTestNode(t, db, "Åpen", nil)
+	enabled := true
+	if _, err := resolver.Mutation().SetNodeAccessLogging(admin, classified.ID, &enabled); err != nil {
+		t.Fatal(err)
+	}
+	secret := saveTestFile(t, db, "rapport.txt", classified.ID, "hemmelig")
+	public := saveTestFile(t, db, "plakat.txt", open.ID, "offentlig")
+
+	ctx := WithUserAgent(WithClientIP(admin, "192.0.2.10"), "Arkivklient/1.0")
+	for _, fileID := range []string{secret.ID, public.ID} {
+		if _, err := resolver.Query().GetFile(ctx, fileID); err != nil {
+			t.Fatalf("get: %v", err)
+		}
+		if _, err := resolver.Query().DownloadFile(ctx, fileID); err != nil {
+			t.Fatalf("download: %v", err)
+		}
+	}
+
+	history, err := resolver.Query().FileAccessHistory(admin, secret.ID, nil, nil)
+	if err != nil || len(history) != 2 {
+		t.Fatalf("history = %v, %v, want a view and a download", history, err)
+	}
+	// Nyaste först
+	if history[0].AccessType != model.FileAccessTypeDownload || history[1].AccessType != model.FileAccessTypeView {
+		t.Errorf("access types = %s, %s", history[0].AccessType, history[1].AccessType)
+	}
+	access := history[0]
+	if access.FileName != "rapport.txt" || access.Username == nil || *access.Username != "admin" ||
+		access.ClientIP == nil || *access.ClientIP != "192.0.2.10" || access.UserAgent == nil || *access.UserAgent != "Arkivklient/1.0" {
+		t.Errorf("access = %+v", access)
+	}
+	limit := 1
+	if page, err := resolver.Query().FileAccessHistory(admin, secret.ID, &limit, &limit); err != nil || len(page) != 1 || page[0].ID != history[1].ID {
+		t.Errorf("second page = %v, %v", page, err)
+	}
+
+	if untracked, err := resolver.Query().FileAccessHistory(admin, public.ID, nil, nil); err != nil || len(untracked) != 0 {
+		t.Errorf("history of file outside classified nodes = %v, %v", untracked, err)
+	}
+
+	report, err := resolver.Query().UserAccessReport(admin, "1", nil, nil)
+	if err != nil || report.TotalAccesses != 2 || report.DistinctFiles != 1 {
+		t.Fatalf("report = %+v, %v", report, err)
+	}
+	// Ett datum som övre gräns tar med hela dagen
+	day := access.AccessedAt[:10]
+	if report, err := resolver.Query().UserAccessReport(admin, "1", &day, &day); err != nil || report.TotalAccesses != 2 {
+		t.Errorf("report for %s = %+v, %v", day, report, err)
+	}
+	before := "2000-01-01"
+	if report, err := resolver.Query().UserAccessReport(admin, "1", nil, &before); err != nil || report.TotalAccesses != 0 {
+		t.Errorf("report before %s = %+v, %v", before, report, err)
+	}
+
+	bobID := insertTestUser(t, db, "bob")
+	if _, err := resolver.Query().FileAccessHistory(testUserContext(t, bobID, "bob"), secret.ID, nil, nil); err == nil || !strings.HasPrefix(err.Error(), "permission denied") {
+		t.Errorf("history as non-admin: err = %v", err)
+	}
+}
+
+func TestFileAccessLogCoversListsAndExports(t *testing.T) {
+	db := openTestDB(t)
+	t.Chdir(t.TempDir())
+	services := NewServices(db)
+	admin := testAdminContext(t)
+
+	classified := createTestNode(t, db, "Gradert", nil)
+	open := createTestNode(t, db, "Åpen", nil)
+	enabled := true
+	if _, err := NewResolver(db).Mutation().SetNodeAccessLogging(admin, classified.ID, &enabled); err != nil {
+		t.Fatal(err)
+	}
+	secret := saveTestFile(t, db, "rapport.txt", classified.ID, "hemmelig")
+	public := saveTestFile(t, db, "plakat.txt", open.ID, "offentlig")
+
+	// Fillistorna loggas som läsningar
+	if _, err := services.Files.List(admin); err != nil {
+		t.Fatalf("list files: %v", err)
+	}
+	if _, err := services.Files.ListByNode(admin, classified.ID); err != nil {
+		t.Fatalf("list files in node: %v", err)
+	}
+
+	// Exporterna loggas på användaren som startade dem
+	if _, err := ExportBag(db, classified.ID, "bag", "1"); err != nil {
+		t.Fatalf("bag export: %v", err)
+	}
+	job := testJobContext(t, db)
+	job.userID = "1"
+	for _, kind := range []string{"AIP", "DIP"} {
+		if _, err := runInformationPackage(db, job, kind, classified.ID, "admin"); err != nil {
+			t.Fatalf("%s export: %v", kind, err)
+		}
+	}
+	if _, err := runNoarkExport(db, job, classified.ID, "admin"); err != nil {
+		t.Fatalf("noark export: %v", err)
+	}
+
+	history, err := loadFileAccesses(db, "file_id = ?", -1, 0, secret.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var types []string
+	for i := len(history) - 1; i >= 0; i-- {
+		if history[i].UserID == nil || *history[i].UserID != "1" {
+			t.Errorf("access %s was recorded without the user", history[i].AccessType)
+		}
+		types = append(types, string(history[i].AccessType))
+	}
+	if got, want := strings.Join(types, " "), "VIEW VIEW EXPORT EXPORT EXPORT EXPORT"; got != want {
+		t.Errorf("access types = %s, want %s", got, want)
+	}
+
+	// Oinloggade läsningar i loggade noder nekas i stället för att loggas utan användare
+	if err := RecordFileAccess(context.Background(), db, secret.ID, model.FileAccessTypeStream); err == nil || err.Error() != "not authenticated" {
+		t.Errorf("anonymous read of a logged file: err = %v", err)
+	}
+	if err := RecordFileAccess(context.Background(), db, public.ID, model.FileAccessTypeStream); err != nil {
+		t.Errorf("anonymous read outside logged nodes: %v", err)
+	}
+	if _, err := ExportBag(db, classified.ID, "anonym", ""); err == nil || err.Error() != "not authenticated" {
+		t.Errorf("bag export without a user: err = %v", err)
+	}
+	if after, err := loadFileAccesses(db, "file_id = ?", -1, 0, secret.ID); err != nil || len(after) != len(history) {
+		t.Errorf("refused reads were logged: %d accesses, %v", len(after), err)
+	}
+}
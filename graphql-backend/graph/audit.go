@@ -0,0 +1,786 @@
+package graph
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"graphql-backend/graph/model"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// =============================================
+// ========== REVISIONSLOGG ==================
+// =============================================
+
+// Utfall för en händelse i revisionsloggen
+const (
+	AUDIT_OUTCOME_SUCCESS = "SUCCESS"
+	AUDIT_OUTCOME_FAILURE = "FAILURE"
+)
+
+// Värdet som ersätter lösenord, token och filinnehåll i loggade argument
+const auditRedacted = "[REDACTED]"
+
+// Argument som aldrig loggas, med nyckeln i gemener. Utöver dessa döljs alla nycklar som
+// innehåller "password".
+var auditRedactedKeys = map[string]bool{
+	"token":    true,
+	"secret":   true,
+	"filedata": true,
+	"zipdata":  true,
+	"data":     true,
+}
+
+// Längsta sträng som loggas i argumenten. Kedjan kan aldrig rensas, så längre värden, som
+// uppladdat innehåll under andra namn, ersätts i stället för att sparas för alltid.
+const auditMaxArgumentLength = 4096
+
+// auditGenesisHash är prev_hash för den första händelsen i kedjan
+var auditGenesisHash = strings.Repeat("0", 64)
+
+// auditMutex serialiserar skrivningar i den här processen så att varje händelse kedjas till den
+// senast skrivna. Mellan servrar som delar en PostgreSQL-databas gör lockAuditChain samma sak.
+var auditMutex sync.Mutex
+
+// auditLockKey är nyckeln för PostgreSQL-låset som serialiserar skrivningar till kedjan
+const auditLockKey = 7263001
+
+// AuditEntry är en händelse som ska skrivas till revisionsloggen.
+// Tomma strängar lagras som NULL.
+type AuditEntry struct {
+	ActorID    string
+	Actor      string
+	Action     string
+	TargetType string
+	TargetID   string
+	Arguments  string
+	Before     string
+	After      string
+	ClientIP   string
+	Outcome    string
+	Error      string
+}
+
+// auditHashInput är fälten som ingår i en händelses hash, i fast ordning
+type auditHashInput struct {
+	ID         string `json:"id"`
+	OccurredAt string `json:"occurredAt"`
+	ActorID    string `json:"actorId"`
+	Actor      string `json:"actor"`
+	Action     string `json:"action"`
+	TargetType string `json:"targetType"`
+	TargetID   string `json:"targetId"`
+	Arguments  string `json:"arguments"`
+	Before     string `json:"before"`
+	After      string `json:"after"`
+	ClientIP   string `json:"clientIp"`
+	Outcome    string `json:"outcome"`
+	Error      string `json:"error"`
+}
+
+// auditHash beräknar sha256 över föregående hash och händelsens fält
+func auditHash(prevHash string, input auditHashInput) string {
+	data, _ := json.Marshal(input) // En struct med enbart strängar kan alltid serialiseras
+	sum := sha256.Sum256(append([]byte(prevHash+"\n"), data...))
+	return hex.EncodeToString(sum[:])
+}
+
+// auditHashInputFromModel återskapar hashens indata från en lagrad händelse
+func auditHashInputFromModel(event *model.AuditEvent) auditHashInput {
+	value := func(s *string) string {
+		if s == nil {
+			return ""
+		}
+		return *s
+	}
+	return auditHashInput{
+		ID:         event.ID,
+		OccurredAt: event.OccurredAt,
+		ActorID:    value(event.ActorID),
+		Actor:      value(event.Actor),
+		Action:     event.Action,
+		TargetType: value(event.TargetType),
+		TargetID:   value(event.TargetID),
+		Arguments:  value(event.Arguments),
+		Before:     value(event.Before),
+		After:      value(event.After),
+		ClientIP:   value(event.ClientIP),
+		Outcome:    event.Outcome,
+		Error:      value(event.Error),
+	}
+}
+
+// RecordAuditEvent lägger till en händelse sist i revisionsloggen och kedjar den till den föregående
+func RecordAuditEvent(db *sql.DB, entry AuditEntry) error {
+	auditMutex.Lock()
+	defer auditMutex.Unlock()
+	return appendAuditEvent(db, entry)
+}
+
+// appendAuditEvent skriver händelsen i en transaktion som låser kedjan i databasen
+func appendAuditEvent(db *sql.DB, entry AuditEntry) error {
+	tx, err := db.Begin()
+	if err != nil {
+		log.Printf("Error starting transaction: %v", err)
+		return serviceErrorf(errInternal, "failed to start transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if err := lockAuditChain(db, tx); err != nil {
+		return err
+	}
+
+	// Kedjans huvud pekar ut den senaste händelsen; saknas det utgår vi från den sista raden
+	var lastID int64
+	prevHash := auditGenesisHash
+	err = tx.QueryRow("SELECT last_id, last_hash FROM audit_log_head WHERE id = 1").Scan(&lastID, &prevHash)
+	if err == sql.ErrNoRows {
+		err = tx.QueryRow("SELECT id, hash FROM audit_events ORDER BY id DESC LIMIT 1").Scan(&lastID, &prevHash)
+	}
+	if err != nil && err != sql.ErrNoRows {
+		log.Printf("Error fetching head of audit log: %v", err)
+		return serviceErrorf(errInternal, "failed to fetch head of audit log: %v", err)
+	}
+
+	if entry.Outcome == "" {
+		entry.Outcome = AUDIT_OUTCOME_SUCCESS
+	}
+
+	id := lastID + 1
+	occurredAt := time.Now().UTC().Format(time.RFC3339Nano)
+	hash := auditHash(prevHash, auditHashInput{
+		ID:         strconv.FormatInt(id, 10),
+		OccurredAt: occurredAt,
+		ActorID:    entry.ActorID,
+		Actor:      entry.Actor,
+		Action:     entry.Action,
+		TargetType: entry.TargetType,
+		TargetID:   entry.TargetID,
+		Arguments:  entry.Arguments,
+		Before:     entry.Before,
+		After:      entry.After,
+		ClientIP:   entry.ClientIP,
+		Outcome:    entry.Outcome,
+		Error:      entry.Error,
+	})
+
+	_, err = tx.Exec(`
+		INSERT INTO audit_events (id, occurred_at, actor_id, actor, action, target_type, target_id,
+			arguments, before_value, after_value, client_ip, outcome, error, prev_hash, hash)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, id, occurredAt, nullIfEmpty(entry.ActorID), nullIfEmpty(entry.Actor), entry.Action,
+		nullIfEmpty(entry.TargetType), nullIfEmpty(entry.TargetID), nullIfEmpty(entry.Arguments),
+		nullIfEmpty(entry.Before), nullIfEmpty(entry.After), nullIfEmpty(entry.ClientIP),
+		entry.Outcome, nullIfEmpty(entry.Error), prevHash, hash)
+	if err != nil {
+		log.Printf("Error writing audit event: %v", err)
+		return serviceErrorf(errInternal, "failed to write audit event: %v", err)
+	}
+
+	if _, err := tx.Exec("INSERT INTO audit_log_head (id, last_id, last_hash) VALUES (1, ?, ?) ON CONFLICT(id) DO UPDATE SET last_id = excluded.last_id, last_hash = excluded.last_hash", id, hash); err != nil {
+		log.Printf("Error updating head of audit log: %v", err)
+		return serviceErrorf(errInternal, "failed to update head of audit log: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("Error committing transaction: %v", err)
+		return serviceErrorf(errInternal, "failed to commit transaction: %v", err)
+	}
+	return nil
+}
+
+// lockAuditChain låser kedjan i PostgreSQL till slutet av transaktionen. Flera servrar kan dela
+// databasen, och utan låset kunde två av dem läsa samma huvud och skriva händelser med samma id
+// och prev_hash. Låset fungerar även innan kedjans huvud finns. SQLite-filen används av en server
+// i taget, så där räcker auditMutex.
+func lockAuditChain(db *sql.DB, tx *sql.Tx) error {
+	if isSQLiteDB(db) {
+		return nil
+	}
+	if _, err := tx.Exec(fmt.Sprintf("SELECT pg_advisory_xact_lock(%d)", auditLockKey)); err != nil {
+		log.Printf("Error locking audit log: %v", err)
+		return serviceErrorf(errInternal, "failed to lock audit log: %v", err)
+	}
+	return nil
+}
+
+// nullIfEmpty gör en tom sträng till NULL i databasen
+func nullIfEmpty(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// scanAuditEvent läser en rad från audit_events
+func scanAuditEvent(rows *sql.Rows) (*model.AuditEvent, error) {
+	var event model.AuditEvent
+	var actorID, actor, targetType, targetID, arguments, before, after, clientIP, errorMessage sql.NullString
+	err := rows.Scan(&event.ID, &event.OccurredAt, &actorID, &actor, &event.Action, &targetType, &targetID,
+		&arguments, &before, &after, &clientIP, &event.Outcome, &errorMessage, &event.PrevHash, &event.Hash)
+	if err != nil {
+		log.Printf("Error scanning audit event: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to read audit event: %v", err)
+	}
+
+	event.ActorID = nullStringPtr(actorID)
+	event.Actor = nullStringPtr(actor)
+	event.TargetType = nullStringPtr(targetType)
+	event.TargetID = nullStringPtr(targetID)
+	event.Arguments = nullStringPtr(arguments)
+	event.Before = nullStringPtr(before)
+	event.After = nullStringPtr(after)
+	event.ClientIP = nullStringPtr(clientIP)
+	event.Error = nullStringPtr(errorMessage)
+	return &event, nil
+}
+
+// Kolumnerna som scanAuditEvent förväntar sig, i samma ordning
+const auditEventColumns = `id, occurred_at, actor_id, actor, action, target_type, target_id,
+	arguments, before_value, after_value, client_ip, outcome, error, prev_hash, hash`
+
+// VerifyAuditLog går igenom hela kedjan och rapporterar den första händelse som ändrats, tagits bort
+// eller inte längre hänger ihop med den föregående. Borttagna händelser i slutet av loggen upptäcks
+// genom kedjans huvud i audit_log_head.
+func VerifyAuditLog(db *sql.DB) (*model.AuditVerification, error) {
+	auditMutex.Lock()
+	defer auditMutex.Unlock()
+
+	// Händelserna och huvudet läses i samma transaktion under låset, så att en händelse som en
+	// annan server skriver under tiden inte ser ut som en borttagen händelse i slutet
+	tx, err := db.Begin()
+	if err != nil {
+		log.Printf("Error starting transaction: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to start transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if err := lockAuditChain(db, tx); err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.Query("SELECT " + auditEventColumns + " FROM audit_events ORDER BY id ASC")
+	if err != nil {
+		log.Printf("Error fetching audit events: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to fetch audit events: %v", err)
+	}
+	defer rows.Close()
+
+	result := &model.AuditVerification{Valid: true}
+	invalid := func(eventID string, message string) (*model.AuditVerification, error) {
+		log.Printf("Audit log verification failed: %s", message)
+		result.Valid = false
+		result.FirstInvalidID = &eventID
+		result.Message = message
+		return result, nil
+	}
+
+	expectedPrev := auditGenesisHash
+	expectedID := int64(1)
+	for rows.Next() {
+		event, err := scanAuditEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		id, err := strconv.ParseInt(event.ID, 10, 64)
+		if err != nil {
+			return invalid(event.ID, fmt.Sprintf("entry %s has an invalid id", event.ID))
+		}
+		if id != expectedID {
+			return invalid(strconv.FormatInt(expectedID, 10), fmt.Sprintf("entry %d is missing before entry %d", expectedID, id))
+		}
+		if event.PrevHash != expectedPrev {
+			return invalid(event.ID, fmt.Sprintf("entry %d does not link to the previous entry", id))
+		}
+		if auditHash(event.PrevHash, auditHashInputFromModel(event)) != event.Hash {
+			return invalid(event.ID, fmt.Sprintf("entry %d has been altered", id))
+		}
+
+		result.Checked++
+		expectedPrev = event.Hash
+		expectedID = id + 1
+		lastHash := event.Hash
+		result.LastHash = &lastHash
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("Error iterating audit events: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to read audit events: %v", err)
+	}
+
+	// Jämför med kedjans huvud så att borttagna händelser i slutet också upptäcks
+	var headID int64
+	var headHash string
+	err = tx.QueryRow("SELECT last_id, last_hash FROM audit_log_head WHERE id = 1").Scan(&headID, &headHash)
+	if err == sql.ErrNoRows {
+		if result.Checked > 0 {
+			return invalid(strconv.FormatInt(expectedID-1, 10), "the head of the audit chain is missing")
+		}
+	} else if err != nil {
+		log.Printf("Error fetching head of audit log: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to fetch head of audit log: %v", err)
+	} else if headID != expectedID-1 || headHash != expectedPrev {
+		if headID == expectedID {
+			return invalid(strconv.FormatInt(expectedID, 10), fmt.Sprintf("entry %d has been removed from the end of the log", expectedID))
+		} else if headID > expectedID {
+			return invalid(strconv.FormatInt(expectedID, 10), fmt.Sprintf("entries %d to %d have been removed from the end of the log", expectedID, headID))
+		}
+		return invalid(strconv.FormatInt(expectedID-1, 10), "the head of the audit chain does not match the last entry")
+	}
+
+	result.Message = fmt.Sprintf("verified %d entries", result.Checked)
+	return result, nil
+}
+
+// =============================================
+// ========== REVISION AV GRAPHQL-ANROP ======
+// =============================================
+
+// clientIPKey är nyckeln för klientens IP-adress i context
+type clientIPKey struct{}
+
+// WithClientIP lägger klientens IP-adress i context så att den kan loggas i revisionsloggen
+func WithClientIP(ctx context.Context, ip string) context.Context {
+	return context.WithValue(ctx, clientIPKey{}, ip)
+}
+
+// clientIPFromContext hämtar klientens IP-adress från context, eller en tom sträng
+func clientIPFromContext(ctx context.Context) string {
+	ip, _ := ctx.Value(clientIPKey{}).(string)
+	return ip
+}
+
+// Argument som pekar ut målet för en mutation, i prioritetsordning (moveFile har både fileId och nodeId)
+var auditTargetArgs = []struct {
+	arg        string
+	targetType string
+}{
+	{"fileId", "file"},
+	{"requestId", "disposal_request"},
+	{"groupId", "group"},
+	{"userId", "user"},
+	{"nodeId", "node"},
+	{"vocabularyId", "vocabulary"},
+}
+
+// Måltyp för fält vars mål anges som id, utifrån slutet av fältnamnet (t.ex. deleteFile, moveNode)
+var auditTargetNames = []struct {
+	suffix     string
+	targetType string
+}{
+	{"File", "file"},
+	{"Node", "node"},
+	{"Group", "group"},
+	{"User", "user"},
+	{"RetentionRule", "retention_rule"},
+	{"LegalHold", "legal_hold"},
+	{"MetadataSchema", "metadata_schema"},
+	{"VocabularyTerm", "vocabulary_term"},
+	{"Vocabulary", "vocabulary"},
+	{"Crosswalk", "crosswalk"},
+	{"Webhook", "webhook"},
+}
+
+// Tabeller som ögonblicksbilder före och efter en ändring hämtas från
+var auditSnapshotTables = map[string]string{
+	"file":             "files",
+	"node":             "nodes",
+	"group":            "groups",
+	"user":             "users",
+	"retention_rule":   "retention_rules",
+	"legal_hold":       "legal_holds",
+	"metadata_schema":  "metadata_schemas",
+	"vocabulary":       "vocabularies",
+	"vocabulary_term":  "vocabulary_terms",
+	"crosswalk":        "crosswalks",
+	"disposal_request": "disposal_requests",
+	"job":              "jobs",
+	"webhook":          "webhooks",
+}
+
+// Kolumner som aldrig tas med i ögonblicksbilder
+var auditExcludedColumns = map[string]bool{
+	"file_data":     true,
+	"input_data":    true,
+	"password_hash": true,
+	"secret":        true,
+}
+
+// AuditMiddleware skriver varje mutation och varje filnedladdning till revisionsloggen,
+// med aktör, mål, ögonblicksbilder före och efter samt klientens IP-adress
+func AuditMiddleware(db *sql.DB) graphql.FieldMiddleware {
+	return func(ctx context.Context, next graphql.Resolver) (interface{}, error) {
+		fc := graphql.GetFieldContext(ctx)
+		if fc == nil || !isAuditedField(fc) {
+			return next(ctx)
+		}
+
+		action := fc.Field.Name
+		// Nedladdningar ändrar inget, så där räcker målet och argumenten
+		withSnapshots := fc.Object == "Mutation"
+
+		targetType, targetID := auditTargetFromArgs(action, fc.Args)
+		before := ""
+		if withSnapshots && targetID != "" {
+			before = auditSnapshot(db, targetType, targetID)
+		}
+
+		result, err := next(ctx)
+
+		if targetID == "" && err == nil {
+			targetType, targetID = auditTargetFromResult(result)
+		}
+
+		entry := AuditEntry{
+			Action:     action,
+			TargetType: targetType,
+			TargetID:   targetID,
+			Arguments:  auditArguments(fc.Args),
+			Before:     before,
+			ClientIP:   clientIPFromContext(ctx),
+			Outcome:    AUDIT_OUTCOME_SUCCESS,
+		}
+		if withSnapshots && targetID != "" {
+			entry.After = auditSnapshot(db, targetType, targetID)
+		}
+		if err != nil {
+			entry.Outcome = AUDIT_OUTCOME_FAILURE
+			entry.Error = err.Error()
+		}
+		entry.ActorID, entry.Actor = auditActor(ctx, db, fc.Args, result)
+
+		if auditErr := RecordAuditEvent(db, entry); auditErr != nil {
+			log.Printf("Error recording audit event for %s: %v", action, auditErr)
+		}
+		return result, err
+	}
+}
+
+// isAuditedField avgör om ett fält ska revisionsloggas: alla mutationer samt nedladdningar, dvs.
+// downloadFile och frågor som hämtar filinnehåll med fileData, t.ex. files { fileData }
+func isAuditedField(fc *graphql.FieldContext) bool {
+	if fc.Field.Field == nil {
+		return false
+	}
+	if fc.Object == "Mutation" {
+		return true
+	}
+	return fc.Object == "Query" && (fc.Field.Name == "downloadFile" || selectsFileData(fc.Field.SelectionSet))
+}
+
+// selectsFileData avgör om ett urval hämtar fileData på någon nivå, även genom fragment
+func selectsFileData(selections ast.SelectionSet) bool {
+	for _, selection := range selections {
+		switch s := selection.(type) {
+		case *ast.Field:
+			if s.Name == "fileData" || selectsFileData(s.SelectionSet) {
+				return true
+			}
+		case *ast.InlineFragment:
+			if selectsFileData(s.SelectionSet) {
+				return true
+			}
+		case *ast.FragmentSpread:
+			if s.Definition != nil && selectsFileData(s.Definition.SelectionSet) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// callResolver anropar en resolver utanför GraphQL-servern, t.ex. från WebDAV, genom samma
+// middleware som GraphQL-anrop går igenom. Anropet revisionsloggas och ändringar publiceras på
+// samma sätt som om fältet hade anropats med argumenten args.
+func callResolver(ctx context.Context, db *sql.DB, object string, field string, args map[string]interface{}, resolve graphql.Resolver) (interface{}, error) {
+	ctx = graphql.WithFieldContext(ctx, &graphql.FieldContext{
+		Object: object,
+		Field:  graphql.CollectedField{Field: &ast.Field{Name: field}},
+		Args:   args,
+	})
+	audit, changes := AuditMiddleware(db), ChangeEventMiddleware(db)
+	return audit(ctx, func(ctx context.Context) (interface{}, error) {
+		return changes(ctx, resolve)
+	})
+}
+
+// resolverErrorMessage hämtar felmeddelandet från ett resolveranrop, utan sökvägen som GraphQL-fel har framför
+func resolverErrorMessage(err error) string {
+	var gqlErr *gqlerror.Error
+	if errors.As(err, &gqlErr) {
+		return gqlErr.Message
+	}
+	return err.Error()
+}
+
+// auditTargetFromArgs hittar målet för ett anrop utifrån dess argument
+func auditTargetFromArgs(fieldName string, args map[string]interface{}) (string, string) {
+	for _, target := range auditTargetArgs {
+		if id := auditArgString(args[target.arg]); id != "" {
+			return target.targetType, id
+		}
+	}
+
+	if id := auditArgString(args["id"]); id != "" {
+		for _, target := range auditTargetNames {
+			if strings.HasSuffix(fieldName, target.suffix) {
+				return target.targetType, id
+			}
+		}
+	}
+	return "", ""
+}
+
+// auditArgString läser ett ID-argument som kan vara en sträng eller en valfri sträng
+func auditArgString(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case *string:
+		if v != nil {
+			return *v
+		}
+	}
+	return ""
+}
+
+// auditTargetFromResult hittar målet för ett anrop som skapar något, utifrån det returnerade objektet
+func auditTargetFromResult(result interface{}) (string, string) {
+	switch v := result.(type) {
+	case *model.File:
+		if v != nil {
+			return "file", v.ID
+		}
+	case *model.Node:
+		if v != nil {
+			return "node", v.ID
+		}
+	case *model.Group:
+		if v != nil {
+			return "group", v.ID
+		}
+	case *model.User:
+		if v != nil {
+			return "user", v.ID
+		}
+	case *model.AuthPayload:
+		if v != nil && v.User != nil {
+			return "user", v.User.ID
+		}
+	case *model.RetentionRule:
+		if v != nil {
+			return "retention_rule", v.ID
+		}
+	case *model.LegalHold:
+		if v != nil {
+			return "legal_hold", v.ID
+		}
+	case *model.MetadataSchema:
+		if v != nil {
+			return "metadata_schema", v.ID
+		}
+	case *model.Vocabulary:
+		if v != nil {
+			return "vocabulary", v.ID
+		}
+	case *model.VocabularyTerm:
+		if v != nil {
+			return "vocabulary_term", v.ID
+		}
+	case *model.Crosswalk:
+		if v != nil {
+			return "crosswalk", v.ID
+		}
+	case *model.DisposalRequest:
+		if v != nil {
+			return "disposal_request", v.ID
+		}
+	case *model.Job:
+		if v != nil {
+			return "job", v.ID
+		}
+	case *model.Webhook:
+		if v != nil {
+			return "webhook", v.ID
+		}
+	}
+	return "", ""
+}
+
+// auditActor returnerar ID och användarnamn för den som utför anropet. Vid inloggning används
+// användarnamnet i argumenten så att även misslyckade inloggningsförsök kan spåras.
+func auditActor(ctx context.Context, db *sql.DB, args map[string]interface{}, result interface{}) (string, string) {
+	if userID, err := getUserIDFromContext(ctx); err == nil {
+		username, _ := lookupUsername(db, userID)
+		return userID, username
+	}
+
+	if payload, ok := result.(*model.AuthPayload); ok && payload != nil && payload.User != nil {
+		return payload.User.ID, payload.User.Username
+	}
+	username, _ := args["username"].(string)
+	return "", username
+}
+
+// auditArguments serialiserar anropets argument till JSON, utan lösenord, token, hemligheter och
+// uppladdat innehåll
+func auditArguments(args map[string]interface{}) string {
+	if len(args) == 0 {
+		return ""
+	}
+
+	data, err := json.Marshal(args)
+	if err != nil {
+		log.Printf("Error serializing arguments for audit log: %v", err)
+		return ""
+	}
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		log.Printf("Error serializing arguments for audit log: %v", err)
+		return ""
+	}
+
+	data, err = json.Marshal(redactAuditValue(value))
+	if err != nil {
+		log.Printf("Error serializing arguments for audit log: %v", err)
+		return ""
+	}
+	return string(data)
+}
+
+// redactAuditValue ersätter känsliga och stora värden i en avkodad JSON-struktur
+func redactAuditValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case string:
+		if len(v) > auditMaxArgumentLength {
+			return auditRedacted
+		}
+	case map[string]interface{}:
+		for key, item := range v {
+			lowerKey := strings.ToLower(key)
+			if strings.Contains(lowerKey, "password") || auditRedactedKeys[lowerKey] {
+				v[key] = auditRedacted
+			} else {
+				v[key] = redactAuditValue(item)
+			}
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = redactAuditValue(item)
+		}
+	}
+	return value
+}
+
+// auditSnapshot hämtar målets aktuella rad som JSON, eller en tom sträng om den inte finns.
+// För filer och noder tas även metadata och Noark 5-fält med.
+func auditSnapshot(db *sql.DB, targetType string, targetID string) string {
+	table, ok := auditSnapshotTables[targetType]
+	if !ok {
+		return ""
+	}
+
+	rows, err := db.Query("SELECT * FROM "+table+" WHERE id = ?", targetID)
+	if err != nil {
+		log.Printf("Error fetching %s %s for audit log: %v", targetType, targetID, err)
+		return ""
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		log.Printf("Error fetching columns of %s for audit log: %v", table, err)
+		return ""
+	}
+	if !rows.Next() {
+		return ""
+	}
+
+	values := make([]interface{}, len(columns))
+	pointers := make([]interface{}, len(columns))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+	if err := rows.Scan(pointers...); err != nil {
+		log.Printf("Error scanning %s %s for audit log: %v", targetType, targetID, err)
+		return ""
+	}
+	rows.Close()
+
+	snapshot := make(map[string]interface{})
+	for i, column := range columns {
+		if auditExcludedColumns[column] {
+			continue
+		}
+		if data, ok := values[i].([]byte); ok {
+			snapshot[column] = string(data)
+		} else {
+			snapshot[column] = values[i]
+		}
+	}
+
+	switch targetType {
+	case "file":
+		metadata, err := loadAuditMetadata(db, "metadata", "file_id", targetID)
+		if err != nil {
+			return ""
+		}
+		snapshot["metadata"] = metadata
+		fields, err := loadTypedFields(db, ENTITY_KIND_FILE, targetID)
+		if err != nil {
+			return ""
+		}
+		snapshot["fields"] = fields
+	case "node":
+		metadata, err := loadAuditMetadata(db, "node_metadata", "node_id", targetID)
+		if err != nil {
+			return ""
+		}
+		snapshot["metadata"] = metadata
+		fields, err := loadTypedFields(db, ENTITY_KIND_NODE, targetID)
+		if err != nil {
+			return ""
+		}
+		snapshot["fields"] = fields
+	}
+
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		log.Printf("Error serializing %s %s for audit log: %v", targetType, targetID, err)
+		return ""
+	}
+	return string(data)
+}
+
+// loadAuditMetadata hämtar en fils eller nods metadata som nyckel/värde-par
+func loadAuditMetadata(db *sql.DB, table string, ownerColumn string, ownerID string) (map[string]string, error) {
+	rows, err := db.Query("SELECT key, value FROM "+table+" WHERE "+ownerColumn+" = ?", ownerID)
+	if err != nil {
+		log.Printf("Error fetching metadata of %s %s for audit log: %v", ownerColumn, ownerID, err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	metadata := make(map[string]string)
+	for rows.Next() {
+		var key, value string
+		if err := rows.Scan(&key, &value); err != nil {
+			log.Printf("Error scanning metadata of %s %s for audit log: %v", ownerColumn, ownerID, err)
+			return nil, err
+		}
+		metadata[key] = value
+	}
+	return metadata, rows.Err()
+}
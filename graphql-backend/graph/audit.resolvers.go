@@ -0,0 +1,105 @@
+package graph
+
+// This file will be automatically regenerated based on the schema, any resolver implementations
+// will be copied through when generating and any unknown code will be moved to the end.
+// Code generated by github.com/99designs/gqlgen version v0.17.69
+
+import (
+	"context"
+	"graphql-backend/graph/model"
+	"log"
+	"strings"
+)
+
+// AuditEvents is the resolver for the auditEvents field.
+func (r *queryResolver) AuditEvents(ctx context.Context, filter *model.AuditEventFilter, limit *int, offset *int) ([]*model.AuditEvent, error) {
+	if r.DB == nil {
+		return nil, serviceErrorf(errInternal, "internal server error: database connection is not initialized")
+	}
+
+	if _, err := requireAdministrator(ctx, r.DB, "view the audit log"); err != nil {
+		return nil, err
+	}
+
+	conditions := []string{}
+	args := []interface{}{}
+	if filter != nil {
+		for _, condition := range []struct {
+			column string
+			value  *string
+		}{
+			{"actor_id", filter.ActorID},
+			{"actor", filter.Actor},
+			{"action", filter.Action},
+			{"target_type", filter.TargetType},
+			{"target_id", filter.TargetID},
+			{"outcome", filter.Outcome},
+		} {
+			if condition.value != nil {
+				conditions = append(conditions, condition.column+" = ?")
+				args = append(args, *condition.value)
+			}
+		}
+		if filter.From != nil {
+			conditions = append(conditions, "occurred_at >= ?")
+			args = append(args, *filter.From)
+		}
+		if filter.To != nil {
+			// Jämför bara så många tecken som gränsen har, så att "2024-05-31" tar med hela dagen
+			conditions = append(conditions, "substr(occurred_at, 1, length(?)) <= ?")
+			args = append(args, *filter.To, *filter.To)
+		}
+	}
+
+	query := "SELECT " + auditEventColumns + " FROM audit_events"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY id DESC LIMIT ? OFFSET ?"
+
+	pageSize := 100
+	if limit != nil && *limit > 0 {
+		pageSize = *limit
+	}
+	skip := 0
+	if offset != nil && *offset > 0 {
+		skip = *offset
+	}
+	args = append(args, pageSize, skip)
+
+	rows, err := r.DB.Query(query, args...)
+	if err != nil {
+		log.Printf("Error fetching audit events: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to fetch audit events: %v", err)
+	}
+	defer rows.Close()
+
+	events := []*model.AuditEvent{}
+	for rows.Next() {
+		event, err := scanAuditEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("Error iterating audit events: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to fetch audit events: %v", err)
+	}
+	return events, nil
+}
+
+// VerifyAuditLog is the resolver for the verifyAuditLog field.
+func (r *queryResolver) VerifyAuditLog(ctx context.Context) (*model.AuditVerification, error) {
+	logAction("Verifying audit log")
+
+	if r.DB == nil {
+		return nil, serviceErrorf(errInternal, "internal server error: database connection is not initialized")
+	}
+
+	if _, err := requireAdministrator(ctx, r.DB, "verify the audit log"); err != nil {
+		return nil, err
+	}
+
+	return VerifyAuditLog(r.DB)
+}
@@ -0,0 +1,318 @@
+package graph
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"graphql-backend/graph/model"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+)
+
+// recordTestAuditEvents skriver count händelser till revisionsloggen
+func recordTestAuditEvents(t *testing.T, db *sql.DB, count int) {
+	t.Helper()
+	for i := 1; i <= count; i++ {
+		entry := AuditEntry{ActorID: "1", Actor: "admin", Action: "renameFile", TargetType: "file", TargetID: fmt.Sprint(i)}
+		if err := RecordAuditEvent(db, entry); err != nil {
+			t.Fatalf("record audit event %d: %v", i, err)
+		}
+	}
+}
+
+// loadTestAuditEvent hämtar en händelse från revisionsloggen
+func loadTestAuditEvent(t *testing.T, db *sql.DB, id string) *model.AuditEvent {
+	t.Helper()
+	rows, err := db.Query("SELECT "+auditEventColumns+" FROM audit_events WHERE id = ?", id)
+	if err != nil {
+		t.Fatalf("fetch audit event %s: %v", id, err)
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		t.Fatalf("audit event %s not found", id)
+	}
+	event, err := scanAuditEvent(rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return event
+}
+
+// auditEventsSince hämtar händelserna efter id, äldst först
+func auditEventsSince(t *testing.T, db *sql.DB, id int) []*model.AuditEvent {
+	t.Helper()
+	rows, err := db.Query("SELECT "+auditEventColumns+" FROM audit_events WHERE id > ? ORDER BY id ASC", id)
+	if err != nil {
+		t.Fatalf("fetch audit events: %v", err)
+	}
+	defer rows.Close()
+	var events []*model.AuditEvent
+	for rows.Next() {
+		event, err := scanAuditEvent(rows)
+		if err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, event)
+	}
+	return events
+}
+
+func lastAuditEventID(t *testing.T, db *sql.DB) int {
+	t.Helper()
+	var id sql.NullInt64
+	if err := db.QueryRow("SELECT MAX(id) FROM audit_events").Scan(&id); err != nil {
+		t.Fatalf("fetch last audit event: %v", err)
+	}
+	return int(id.Int64)
+}
+
+func TestVerifyAuditLog(t *testing.T) {
+	tests := []struct {
+		name      string
+		tamper    func(t *testing.T, db *sql.DB)
+		invalidID string
+		message   string
+	}{
+		{
+			name: "intact chain",
+		},
+		{
+			name: "edited row",
+			tamper: func(t *testing.T, db *sql.DB) {
+				mustExec(t, db, "UPDATE audit_events SET actor = 'mallory' WHERE id = 2")
+			},
+			invalidID: "2",
+			message:   "entry 2 has been altered",
+		},
+		{
+			name: "deleted middle row",
+			tamper: func(t *testing.T, db *sql.DB) {
+				mustExec(t, db, "DELETE FROM audit_events WHERE id = 2")
+			},
+			invalidID: "2",
+			message:   "entry 2 is missing before entry 3",
+		},
+		{
+			name: "truncated tail",
+			tamper: func(t *testing.T, db *sql.DB) {
+				mustExec(t, db, "DELETE FROM audit_events WHERE id = 4")
+			},
+			invalidID: "4",
+			message:   "entry 4 has been removed from the end of the log",
+		},
+		{
+			name: "several rows removed from the tail",
+			tamper: func(t *testing.T, db *sql.DB) {
+				mustExec(t, db, "DELETE FROM audit_events WHERE id >= 3")
+			},
+			invalidID: "3",
+			message:   "entries 3 to 4 have been removed from the end of the log",
+		},
+		{
+			// Raden har en hash som stämmer med dess egna fält, men den pekar inte på den föregående
+			name: "broken prev_hash",
+			tamper: func(t *testing.T, db *sql.DB) {
+				event := loadTestAuditEvent(t, db, "3")
+				prevHash := strings.Repeat("f", 64)
+				hash := auditHash(prevHash, auditHashInputFromModel(event))
+				mustExec(t, db, "UPDATE audit_events SET prev_hash = ?, hash = ? WHERE id = 3", prevHash, hash)
+			},
+			invalidID: "3",
+			message:   "entry 3 does not link to the previous entry",
+		},
+		{
+			name: "missing head",
+			tamper: func(t *testing.T, db *sql.DB) {
+				mustExec(t, db, "DELETE FROM audit_log_head")
+			},
+			invalidID: "4",
+			message:   "the head of the audit chain is missing",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := openTestDB(t)
+			recordTestAuditEvents(t, db, 4)
+			if tt.tamper != nil {
+				tt.tamper(t, db)
+			}
+
+			result, err := VerifyAuditLog(db)
+			if err != nil {
+				t.Fatalf("verify audit log: %v", err)
+			}
+			if tt.tamper == nil {
+				if !result.Valid || result.Checked != 4 || result.Message != "verified 4 entries" {
+					t.Errorf("result = %+v, want 4 verified entries", result)
+				}
+				return
+			}
+			if result.Valid {
+				t.Fatalf("tampered log verified as valid: %+v", result)
+			}
+			if result.FirstInvalidID == nil || *result.FirstInvalidID != tt.invalidID || result.Message != tt.message {
+				t.Errorf("result = %v, %q, want %s, %q", result.FirstInvalidID, result.Message, tt.invalidID, tt.message)
+			}
+		})
+	}
+}
+
+func TestAuditFileDataQueries(t *testing.T) {
+	db := openTestDB(t)
+	nodeID := insertTestNode(t, db, "Arkiv", "")
+	insertTestFile(t, db, "brev.txt", nodeID)
+
+	srv := handler.New(NewExecutableSchema(Config{Resolvers: NewResolver(db)}))
+	srv.AddTransport(transport.POST{})
+	srv.AroundFields(AuditMiddleware(db))
+	server := httptest.NewServer(withTestBearerToken(srv))
+	t.Cleanup(server.Close)
+	token, err := generateJWT("1", "admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	queries := []struct {
+		query  string
+		action string // Åtgärden i revisionsloggen, tom om frågan inte ska loggas
+	}{
+		{"{ getFiles { id name } }", ""},
+		{"{ getFiles { id fileData } }", "getFiles"},
+		{"{ getFiles { ...content } } fragment content on File { fileData }", "getFiles"},
+		{fmt.Sprintf(`{ getNodeById(id: %q) { files { ... on File { fileData } } } }`, nodeID), "getNodeById"},
+	}
+	for _, q := range queries {
+		before := lastAuditEventID(t, db)
+		body, _ := json.Marshal(map[string]string{"query": q.query})
+		req, _ := http.NewRequest(http.MethodPost, server.URL, bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("query %s: %v", q.query, err)
+		}
+		resp.Body.Close()
+
+		events := auditEventsSince(t, db, before)
+		if q.action != "" && (len(events) != 1 || events[0].Action != q.action || *events[0].Actor != "admin") {
+			t.Errorf("%s: events = %d, want one %s event by admin", q.query, len(events), q.action)
+		} else if q.action == "" && len(events) != 0 {
+			t.Errorf("%s: events = %d, want none", q.query, len(events))
+		}
+	}
+}
+
+func TestZipExportHandlerAudit(t *testing.T) {
+	db := openTestDB(t)
+	nodeID := insertTestNode(t, db, "Arkiv", "")
+	insertTestFile(t, db, "brev.txt", nodeID)
+	bobID := insertTestUser(t, db, "bob")
+	exportHandler := ZipExportHandler(db)
+
+	requests := []struct {
+		name    string
+		userID  string
+		query   string
+		status  int
+		target  string
+		outcome string
+	}{
+		{"streamed export", "1", "nodeId=" + nodeID, http.StatusOK, nodeID, AUDIT_OUTCOME_SUCCESS},
+		{"denied export", bobID, "nodeId=" + nodeID, http.StatusForbidden, nodeID, AUDIT_OUTCOME_FAILURE},
+		{"unfinished job", "1", "jobId=999", http.StatusNotFound, "999", AUDIT_OUTCOME_FAILURE},
+		{"not authenticated", "", "nodeId=" + nodeID, http.StatusUnauthorized, nodeID, AUDIT_OUTCOME_FAILURE},
+	}
+	for _, tt := range requests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := lastAuditEventID(t, db)
+			req := httptest.NewRequest(http.MethodGet, ZipExportPath+"?"+tt.query, nil)
+			if tt.userID != "" {
+				req = req.WithContext(WithClientIP(testUserContext(t, tt.userID, "user"), "192.0.2.1"))
+			}
+			rec := httptest.NewRecorder()
+			exportHandler.ServeHTTP(rec, req)
+			if rec.Code != tt.status {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.status, rec.Body.String())
+			}
+
+			events := auditEventsSince(t, db, before)
+			if len(events) != 1 {
+				t.Fatalf("audit events = %d, want 1", len(events))
+			}
+			event := events[0]
+			if event.Action != "exportZip" || event.TargetID == nil || *event.TargetID != tt.target || event.Outcome != tt.outcome {
+				t.Errorf("event = %s on %v, %s, want exportZip on %s, %s", event.Action, event.TargetID, event.Outcome, tt.target, tt.outcome)
+			}
+			if tt.userID != "" && (event.ActorID == nil || *event.ActorID != tt.userID) {
+				t.Errorf("actor = %v, want %s", event.ActorID, tt.userID)
+			}
+		})
+	}
+}
+
+func TestExportJobAudit(t *testing.T) {
+	db := openTestDB(t)
+	nodeID := insertTestNode(t, db, "Arkiv", "")
+	job := &jobContext{db: db, id: "7", jobType: JOB_TYPE_BAGIT_EXPORT, userID: "1", attempt: 2,
+		payload: fmt.Sprintf(`{"nodeId":%q}`, nodeID)}
+
+	recordJobAudit(job, "exportBag", `{"bagDir":"exports/bagit-7"}`, nil)
+	recordJobAudit(job, "exportBag", "", fmt.Errorf("disk full"))
+
+	events := auditEventsSince(t, db, 0)
+	if len(events) != 2 {
+		t.Fatalf("audit events = %d, want 2", len(events))
+	}
+	success, failure := events[0], events[1]
+	if success.Action != "exportBag" || *success.TargetID != nodeID || *success.Actor != "admin" || *success.After != `{"bagDir":"exports/bagit-7"}` {
+		t.Errorf("success = %+v", success)
+	}
+	if *success.Arguments != fmt.Sprintf(`{"jobId":"7","nodeId":%q,"attempt":2}`, nodeID) {
+		t.Errorf("arguments = %s", *success.Arguments)
+	}
+	if failure.Outcome != AUDIT_OUTCOME_FAILURE || *failure.Error != "disk full" {
+		t.Errorf("failure = %s, %v", failure.Outcome, failure.Error)
+	}
+
+	for jobType, handler := range jobHandlers {
+		export := strings.HasSuffix(jobType, "_EXPORT")
+		if export != (handler.auditAction != "") {
+			t.Errorf("%s: audit action = %q", jobType, handler.auditAction)
+		}
+	}
+}
+
+func TestRestContentDownloadAudit(t *testing.T) {
+	db := openTestDB(t)
+	nodeID := insertTestNode(t, db, "Arkiv", "")
+	fileID := insertTestFile(t, db, "brev.txt", nodeID)
+	server := httptest.NewServer(withTestBearerToken(RestAPIHandler(db)))
+	t.Cleanup(server.Close)
+	token, err := generateJWT("1", "admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL+RestAPIPath+"files/"+fileID+"/content", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("download content: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+
+	events := auditEventsSince(t, db, 0)
+	if len(events) != 1 || events[0].Action != "downloadFile" || *events[0].TargetID != fileID {
+		t.Errorf("events = %d, want one downloadFile event for file %s", len(events), fileID)
+	}
+}
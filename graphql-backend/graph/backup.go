@@ -0,0 +1,736 @@
+package graph
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"graphql-backend/graph/model"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// =============================================
+// ========== SÄKERHETSKOPIOR =================
+// =============================================
+
+// En säkerhetskopia är en katalog i backupkatalogen med en kopia av SQLite-databasen, kopior av
+// kataloger med innehåll utanför databasen under blobs/ och ett manifest med kontrollsummor.
+// Databasen kopieras med SQLites backup-API, så servern kan fortsätta skriva under tiden.
+// Kopian skrivs först till <namn>.partial och byter namn när den är klar, så att en avbruten
+// säkerhetskopia aldrig ser ut som en hel.
+
+// BackupOptions anger var säkerhetskopiorna sparas och vad de omfattar
+type BackupOptions struct {
+	// Katalogen där varje säkerhetskopia sparas i en egen underkatalog
+	Dir string
+	// Kataloger med innehåll som lagras utanför databasen. Filernas innehåll ligger i databasen,
+	// så katalogerna behövs bara när innehåll även läggs i filsystemet.
+	BlobDirs []string
+}
+
+// backupOptions är inställningarna som säkerhetskopior tas och återställs med
+var backupOptions = BackupOptions{Dir: "backups"}
+
+// SetBackupOptions anger var säkerhetskopiorna sparas. Anropas en gång vid start.
+func SetBackupOptions(options BackupOptions) {
+	backupOptions = options
+}
+
+const (
+	backupManifestName  = "manifest.json"
+	backupDatabaseName  = "e-Arkive.db"
+	backupBlobsDir      = "blobs"
+	backupPartialSuffix = ".partial"
+	backupNamePrefix    = "backup-"
+	backupTimeFormat    = "20060102T150405Z"
+	backupFormat        = 1
+)
+
+// Databasen kopieras i steg så att andra anslutningar kan skriva mellan stegen
+const (
+	backupPagesPerStep = 1024
+	backupStepPause    = 10 * time.Millisecond
+)
+
+// backupMutex hindrar att schemat och en administratör tar säkerhetskopior samtidigt
+var backupMutex sync.Mutex
+
+// backupManifest beskriver innehållet i en säkerhetskopia
+type backupManifest struct {
+	Format        int           `json:"format"`
+	CreatedAt     string        `json:"createdAt"`
+	Scheduled     bool          `json:"scheduled"`
+	SchemaVersion int           `json:"schemaVersion"`
+	Database      backupEntry   `json:"database"`
+	Blobs         []backupEntry `json:"blobs"`
+}
+
+// backupEntry är en fil i säkerhetskopian, med sökvägen relativt kopians katalog
+type backupEntry struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// toModel ger säkerhetskopian som den visas i GraphQL
+func (m *backupManifest) toModel(name string) *model.Backup {
+	size := m.Database.Size
+	for _, blob := range m.Blobs {
+		size += blob.Size
+	}
+	return &model.Backup{
+		Name:             name,
+		CreatedAt:        m.CreatedAt,
+		Scheduled:        m.Scheduled,
+		SchemaVersion:    m.SchemaVersion,
+		DatabaseSize:     int(m.Database.Size),
+		DatabaseChecksum: m.Database.SHA256,
+		BlobFiles:        len(m.Blobs),
+		Size:             int(size),
+	}
+}
+
+// isSQLiteDB avgör om anslutningen går till en SQLite-databas
+func isSQLiteDB(db *sql.DB) bool {
+	_, ok := db.Driver().(*sqlite3.SQLiteDriver)
+	return ok
+}
+
+// CreateBackup tar en säkerhetskopia av databasen och katalogerna med innehåll utanför den.
+// Schemalagda kopior markeras så att de kan gallras med PruneBackups.
+func CreateBackup(db *sql.DB, scheduled bool) (*model.Backup, error) {
+	if !isSQLiteDB(db) {
+		return nil, fmt.Errorf("online backup is only supported for SQLite databases, use pg_dump for PostgreSQL")
+	}
+
+	backupMutex.Lock()
+	defer backupMutex.Unlock()
+
+	options := backupOptions
+	if err := os.MkdirAll(options.Dir, 0755); err != nil {
+		return nil, serviceErrorf(errInternal, "failed to create backup directory: %v", err)
+	}
+
+	createdAt := time.Now().UTC()
+	name, partial, err := reserveBackupName(options.Dir, createdAt)
+	if err != nil {
+		return nil, err
+	}
+	complete := false
+	defer func() {
+		if !complete {
+			os.RemoveAll(partial)
+		}
+	}()
+
+	if err := sqliteOnlineBackup(db, filepath.Join(partial, backupDatabaseName)); err != nil {
+		return nil, serviceErrorf(errInternal, "failed to copy database: %v", err)
+	}
+	schemaVersion, err := verifySQLiteFile(filepath.Join(partial, backupDatabaseName))
+	if err != nil {
+		return nil, fmt.Errorf("backup copy failed verification: %v", err)
+	}
+
+	manifest := backupManifest{
+		Format:        backupFormat,
+		CreatedAt:     createdAt.Format(time.RFC3339),
+		Scheduled:     scheduled,
+		SchemaVersion: schemaVersion,
+		Blobs:         []backupEntry{},
+	}
+	if manifest.Database, err = describeBackupFile(partial, backupDatabaseName); err != nil {
+		return nil, err
+	}
+	for _, dir := range options.BlobDirs {
+		entries, err := snapshotBlobDir(dir, partial)
+		if err != nil {
+			return nil, serviceErrorf(errInternal, "failed to copy %s: %v", dir, err)
+		}
+		manifest.Blobs = append(manifest.Blobs, entries...)
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(partial, backupManifestName), data, 0644); err != nil {
+		return nil, serviceErrorf(errInternal, "failed to write backup manifest: %v", err)
+	}
+	if err := os.Rename(partial, filepath.Join(options.Dir, name)); err != nil {
+		return nil, serviceErrorf(errInternal, "failed to complete backup: %v", err)
+	}
+	complete = true
+
+	backup := manifest.toModel(name)
+	log.Printf("Created backup %s (%d bytes, %d blob files)", name, backup.Size, backup.BlobFiles)
+	return backup, nil
+}
+
+// reserveBackupName väljer ett namn efter tidpunkten och skapar katalogen som kopian skrivs till.
+// Tas flera kopior inom samma sekund får de ett löpnummer.
+func reserveBackupName(dir string, createdAt time.Time) (string, string, error) {
+	base := backupNamePrefix + createdAt.Format(backupTimeFormat)
+	for i := 1; i < 100; i++ {
+		name := base
+		if i > 1 {
+			name = fmt.Sprintf("%s-%d", base, i)
+		}
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			continue
+		}
+		partial := filepath.Join(dir, name+backupPartialSuffix)
+		if err := os.Mkdir(partial, 0755); err == nil {
+			return name, partial, nil
+		} else if !errors.Is(err, fs.ErrExist) {
+			return "", "", serviceErrorf(errInternal, "failed to create backup directory: %v", err)
+		}
+	}
+	return "", "", fmt.Errorf("too many backups named %s", base)
+}
+
+// sqliteOnlineBackup kopierar databasen till en ny fil med SQLites backup-API. Ändras databasen
+// av en annan anslutning under kopieringen börjar SQLite om, så kopian blir alltid konsistent.
+func sqliteOnlineBackup(db *sql.DB, destPath string) error {
+	ctx := context.Background()
+
+	src, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	destDB, err := sql.Open("sqlite3", destPath)
+	if err != nil {
+		return err
+	}
+	defer destDB.Close()
+	dest, err := destDB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer dest.Close()
+
+	return dest.Raw(func(destConn interface{}) error {
+		return src.Raw(func(srcConn interface{}) error {
+			backup, err := destConn.(*sqlite3.SQLiteConn).Backup("main", srcConn.(*sqlite3.SQLiteConn), "main")
+			if err != nil {
+				return err
+			}
+			for {
+				done, err := backup.Step(backupPagesPerStep)
+				if err != nil {
+					backup.Finish()
+					return err
+				}
+				if done {
+					return backup.Finish()
+				}
+				time.Sleep(backupStepPause)
+			}
+		})
+	})
+}
+
+// verifySQLiteFile kör SQLites integritetskontroll på en databasfil och returnerar den senaste
+// migreringen i den
+func verifySQLiteFile(path string) (int, error) {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+
+	rows, err := db.Query("PRAGMA integrity_check")
+	if err != nil {
+		return 0, fmt.Errorf("integrity check failed: %v", err)
+	}
+	var problems []string
+	for rows.Next() {
+		var result string
+		if err := rows.Scan(&result); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		if result != "ok" {
+			problems = append(problems, result)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("integrity check failed: %v", err)
+	}
+	if len(problems) > 0 {
+		return 0, fmt.Errorf("integrity check failed: %s", strings.Join(problems, "; "))
+	}
+
+	var version int
+	if err := db.QueryRow("SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&version); err != nil {
+		return 0, serviceErrorf(errInternal, "failed to read schema version: %v", err)
+	}
+	return version, nil
+}
+
+// describeBackupFile ger storlek och kontrollsumma för en fil i säkerhetskopian
+func describeBackupFile(backupDir, relPath string) (backupEntry, error) {
+	f, err := os.Open(filepath.Join(backupDir, filepath.FromSlash(relPath)))
+	if err != nil {
+		return backupEntry{}, err
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, f)
+	if err != nil {
+		return backupEntry{}, err
+	}
+	return backupEntry{Path: relPath, Size: size, SHA256: hex.EncodeToString(hash.Sum(nil))}, nil
+}
+
+// snapshotBlobDir kopierar en katalog med innehåll utanför databasen till blobs/<katalognamn>/
+// i säkerhetskopian. En katalog som inte finns ännu ger en tom kopia.
+func snapshotBlobDir(dir, backupDir string) ([]backupEntry, error) {
+	prefix := path.Join(backupBlobsDir, filepath.Base(filepath.Clean(dir)))
+	entries := []backupEntry{}
+
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if p == dir && errors.Is(err, fs.ErrNotExist) {
+				return fs.SkipDir
+			}
+			return err
+		}
+		// Bara vanliga filer kopieras; symboliska länkar kan peka ut ur katalogen
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		entry, err := copyBackupFile(p, backupDir, path.Join(prefix, filepath.ToSlash(rel)))
+		if err != nil {
+			return err
+		}
+		entries = append(entries, entry)
+		return nil
+	})
+	return entries, err
+}
+
+// copyBackupFile kopierar en fil till relPath under destDir och beräknar kontrollsumman under tiden
+func copyBackupFile(src, destDir, relPath string) (backupEntry, error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return backupEntry{}, err
+	}
+	defer in.Close()
+
+	dest := filepath.Join(destDir, filepath.FromSlash(relPath))
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return backupEntry{}, err
+	}
+	out, err := os.Create(dest)
+	if err != nil {
+		return backupEntry{}, err
+	}
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(out, hash), in)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return backupEntry{}, err
+	}
+	return backupEntry{Path: relPath, Size: size, SHA256: hex.EncodeToString(hash.Sum(nil))}, nil
+}
+
+// readBackupManifest läser manifestet i en säkerhetskopias katalog
+func readBackupManifest(dir string) (*backupManifest, error) {
+	data, err := os.ReadFile(filepath.Join(dir, backupManifestName))
+	if err != nil {
+		return nil, serviceErrorf(errInternal, "failed to read backup manifest: %v", err)
+	}
+	var manifest backupManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("invalid backup manifest: %v", err)
+	}
+	if manifest.Format != backupFormat {
+		return nil, fmt.Errorf("unsupported backup format %d", manifest.Format)
+	}
+	return &manifest, nil
+}
+
+// ListBackups ger säkerhetskopiorna i backupkatalogen, nyaste först. Ofullständiga kopior och
+// kataloger utan läsbart manifest hoppas över.
+func ListBackups() ([]*model.Backup, error) {
+	entries, err := os.ReadDir(backupOptions.Dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return []*model.Backup{}, nil
+	}
+	if err != nil {
+		return nil, serviceErrorf(errInternal, "failed to read backup directory: %v", err)
+	}
+
+	backups := []*model.Backup{}
+	for _, entry := range entries {
+		if !entry.IsDir() || strings.HasSuffix(entry.Name(), backupPartialSuffix) {
+			continue
+		}
+		manifest, err := readBackupManifest(filepath.Join(backupOptions.Dir, entry.Name()))
+		if err != nil {
+			log.Printf("Skipping backup %s: %v", entry.Name(), err)
+			continue
+		}
+		backups = append(backups, manifest.toModel(entry.Name()))
+	}
+	sort.SliceStable(backups, func(i, j int) bool {
+		if backups[i].CreatedAt != backups[j].CreatedAt {
+			return backups[i].CreatedAt > backups[j].CreatedAt
+		}
+		return backups[i].Name > backups[j].Name
+	})
+	return backups, nil
+}
+
+// PruneBackups tar bort de schemalagda säkerhetskopior som är äldre än de keep senaste.
+// Kopior som tagits för hand tas aldrig bort automatiskt. Returnerar namnen på de borttagna.
+func PruneBackups(keep int) ([]string, error) {
+	backups, err := ListBackups()
+	if err != nil {
+		return nil, err
+	}
+
+	removed := []string{}
+	kept := 0
+	for _, backup := range backups {
+		if !backup.Scheduled {
+			continue
+		}
+		if kept < keep {
+			kept++
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(backupOptions.Dir, backup.Name)); err != nil {
+			return removed, serviceErrorf(errInternal, "failed to remove backup %s: %v", backup.Name, err)
+		}
+		removed = append(removed, backup.Name)
+	}
+	return removed, nil
+}
+
+// StartBackupScheduler tar en säkerhetskopia med jämna mellanrum och gallrar de schemalagda
+// kopiorna efteråt. Första kopian tas när intervallet gått sedan den senaste schemalagda, så att
+// en omstart inte skjuter upp den. Anropas en gång när servern startar.
+func StartBackupScheduler(db *sql.DB, interval time.Duration, keep int) {
+	delay := interval
+	if backups, err := ListBackups(); err == nil {
+		for _, backup := range backups {
+			if !backup.Scheduled {
+				continue
+			}
+			if createdAt, err := time.Parse(time.RFC3339, backup.CreatedAt); err == nil {
+				delay = time.Until(createdAt.Add(interval))
+			}
+			break
+		}
+	}
+	if delay < 0 {
+		delay = 0
+	}
+
+	go func() {
+		timer := time.NewTimer(delay)
+		for range timer.C {
+			runScheduledBackup(db, keep)
+			timer.Reset(interval)
+		}
+	}()
+	log.Printf("Scheduled backups every %s, keeping the latest %d", interval, keep)
+}
+
+// runScheduledBackup tar en schemalagd säkerhetskopia och gallrar de äldre
+func runScheduledBackup(db *sql.DB, keep int) {
+	if _, err := CreateBackup(db, true); err != nil {
+		log.Printf("Scheduled backup failed: %v", err)
+		return
+	}
+	removed, err := PruneBackups(keep)
+	if err != nil {
+		log.Printf("Error pruning backups: %v", err)
+	}
+	for _, name := range removed {
+		log.Printf("Removed backup %s", name)
+	}
+}
+
+// =============================================
+// ========== ÅTERSTÄLLNING ===================
+// =============================================
+
+// RestoreResult beskriver en genomförd återställning
+type RestoreResult struct {
+	Backup    string
+	CreatedAt string
+	// Databasfilen som återställdes och vart den tidigare databasen flyttades (tom om ingen fanns)
+	DatabasePath     string
+	PreviousDatabase string
+	// Katalogerna med innehåll utanför databasen och vart deras tidigare innehåll flyttades
+	BlobDirs         []string
+	PreviousBlobDirs []string
+	BlobFiles        int
+}
+
+// FindBackup ger namnet på den senaste säkerhetskopian som togs vid eller före tidpunkten at
+func FindBackup(at time.Time) (string, error) {
+	backups, err := ListBackups()
+	if err != nil {
+		return "", err
+	}
+	for _, backup := range backups {
+		createdAt, err := time.Parse(time.RFC3339, backup.CreatedAt)
+		if err == nil && !createdAt.After(at) {
+			return backup.Name, nil
+		}
+	}
+	return "", fmt.Errorf("no backup taken at or before %s", at.UTC().Format(time.RFC3339))
+}
+
+// sqliteDatabasePath ger sökvägen till filen som en SQLite-DSN pekar ut
+func sqliteDatabasePath(dsn string) string {
+	p := strings.TrimPrefix(dsn, "file:")
+	if i := strings.IndexByte(p, '?'); i >= 0 {
+		p = p[:i]
+	}
+	return p
+}
+
+// lockSQLiteDatabase tar ett exklusivt lås på databasfilen, utan att vänta om någon annan
+// använder den. Funktionen som returneras släpper låset och kan anropas flera gånger.
+func lockSQLiteDatabase(path string) (func(), error) {
+	if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+		return func() {}, nil
+	}
+	db, err := sql.Open("sqlite3", "file:"+path+"?_busy_timeout=0")
+	if err != nil {
+		return nil, err
+	}
+	conn, err := db.Conn(context.Background())
+	if err == nil {
+		_, err = conn.ExecContext(context.Background(), "BEGIN EXCLUSIVE")
+	}
+	if err != nil {
+		if conn != nil {
+			conn.Close()
+		}
+		db.Close()
+		return nil, fmt.Errorf("database %s is in use, stop the server before restoring: %v", path, err)
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			conn.ExecContext(context.Background(), "ROLLBACK")
+			conn.Close()
+			db.Close()
+		})
+	}, nil
+}
+
+// RestoreBackup ersätter databasen som DSN pekar ut, och katalogerna med innehåll utanför den,
+// med en säkerhetskopia. Kopian kontrolleras mot manifestets kontrollsummor, med SQLites
+// integritetskontroll och mot revisionsloggens hashkedja innan något byts ut. De tidigare
+// filerna flyttas till <namn>.before-restore-<tid>. Återställningen vägras medan
+// någon annan läser eller skriver i databasen, men servern ska ändå vara stoppad.
+func RestoreBackup(name string, dsn string) (*RestoreResult, error) {
+	if DialectForDSN(dsn) != DialectSQLite {
+		return nil, fmt.Errorf("restore is only supported for SQLite databases, use pg_restore for PostgreSQL")
+	}
+	databasePath := sqliteDatabasePath(dsn)
+
+	// Servern eller ett annat verktyg får inte läsa eller skriva i databasen under återställningen
+	unlock, err := lockSQLiteDatabase(databasePath)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	backupDir := name
+	if !strings.ContainsRune(name, filepath.Separator) && !strings.ContainsRune(name, '/') {
+		backupDir = filepath.Join(backupOptions.Dir, name)
+	}
+	manifest, err := readBackupManifest(backupDir)
+	if err != nil {
+		return nil, err
+	}
+
+	// Kontrollera hela kopian innan något skrivs
+	if err := verifyBackupEntry(backupDir, manifest.Database); err != nil {
+		return nil, err
+	}
+	blobDirs := map[string]string{}
+	for _, dir := range backupOptions.BlobDirs {
+		blobDirs[filepath.Base(filepath.Clean(dir))] = dir
+	}
+	for _, blob := range manifest.Blobs {
+		if err := verifyBackupEntry(backupDir, blob); err != nil {
+			return nil, err
+		}
+		if _, ok := blobDirs[blobDirName(blob.Path)]; !ok {
+			return nil, fmt.Errorf("backup contains files for blob directory %s, which is not configured", blobDirName(blob.Path))
+		}
+	}
+	migrations, err := loadMigrations(DialectSQLite)
+	if err != nil {
+		return nil, err
+	}
+	if latest := migrations[len(migrations)-1].version; manifest.SchemaVersion > latest {
+		return nil, fmt.Errorf("backup has schema version %d, newer than %d supported by this version", manifest.SchemaVersion, latest)
+	}
+
+	// Kopiera databasen bredvid den nuvarande och kontrollera kopian där
+	restoring := databasePath + ".restoring"
+	staged := []string{restoring}
+	swapped := false
+	defer func() {
+		if !swapped {
+			for _, p := range staged {
+				os.RemoveAll(p)
+			}
+		}
+	}()
+	os.RemoveAll(restoring)
+	if err := copyRestoreFile(backupDir, manifest.Database, restoring); err != nil {
+		return nil, err
+	}
+	if _, err := verifySQLiteFile(restoring); err != nil {
+		return nil, fmt.Errorf("restored database failed verification: %v", err)
+	}
+	if err := verifyRestoredAuditLog(restoring); err != nil {
+		return nil, err
+	}
+
+	result := &RestoreResult{
+		Backup:       filepath.Base(backupDir),
+		CreatedAt:    manifest.CreatedAt,
+		DatabasePath: databasePath,
+		BlobFiles:    len(manifest.Blobs),
+	}
+	for _, dir := range backupOptions.BlobDirs {
+		stagedDir := filepath.Clean(dir) + ".restoring"
+		os.RemoveAll(stagedDir)
+		if err := os.MkdirAll(stagedDir, 0755); err != nil {
+			return nil, err
+		}
+		staged = append(staged, stagedDir)
+		prefix := path.Join(backupBlobsDir, filepath.Base(filepath.Clean(dir))) + "/"
+		for _, blob := range manifest.Blobs {
+			if !strings.HasPrefix(blob.Path, prefix) {
+				continue
+			}
+			if err := copyRestoreFile(backupDir, blob, filepath.Join(stagedDir, filepath.FromSlash(strings.TrimPrefix(blob.Path, prefix)))); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// Byt ut filerna. Låset släpps först, eftersom en öppen fil inte kan flyttas på alla system.
+	// Den tidigare databasens journalfiler flyttas med, så att de inte tillämpas på den
+	// återställda databasen.
+	unlock()
+	suffix := ".before-restore-" + time.Now().UTC().Format(backupTimeFormat)
+	swapped = true
+	if _, err := os.Stat(databasePath); err == nil {
+		result.PreviousDatabase = databasePath + suffix
+		for _, ext := range []string{"", "-journal", "-wal", "-shm"} {
+			if err := os.Rename(databasePath+ext, result.PreviousDatabase+ext); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return nil, serviceErrorf(errInternal, "failed to move %s aside, restored copy is at %s: %v", databasePath+ext, restoring, err)
+			}
+		}
+	}
+	if err := os.Rename(restoring, databasePath); err != nil {
+		return nil, serviceErrorf(errInternal, "failed to move restored database into place, it is at %s: %v", restoring, err)
+	}
+	for _, dir := range backupOptions.BlobDirs {
+		dir = filepath.Clean(dir)
+		if _, err := os.Stat(dir); err == nil {
+			if err := os.Rename(dir, dir+suffix); err != nil {
+				return nil, serviceErrorf(errInternal, "failed to move %s aside, restored copy is at %s.restoring: %v", dir, dir, err)
+			}
+			result.PreviousBlobDirs = append(result.PreviousBlobDirs, dir+suffix)
+		}
+		if err := os.Rename(dir+".restoring", dir); err != nil {
+			return nil, serviceErrorf(errInternal, "failed to move restored %s into place: %v", dir, err)
+		}
+		result.BlobDirs = append(result.BlobDirs, dir)
+	}
+
+	log.Printf("Restored backup %s taken %s", result.Backup, result.CreatedAt)
+	return result, nil
+}
+
+// blobDirName ger katalognamnet i blobs/<katalog>/... för en fil i säkerhetskopian
+func blobDirName(relPath string) string {
+	rest := strings.TrimPrefix(relPath, backupBlobsDir+"/")
+	name, _, _ := strings.Cut(rest, "/")
+	return name
+}
+
+// verifyBackupEntry jämför en fil i säkerhetskopian med storleken och kontrollsumman i manifestet
+func verifyBackupEntry(backupDir string, entry backupEntry) error {
+	if !filepath.IsLocal(filepath.FromSlash(entry.Path)) {
+		return fmt.Errorf("backup manifest contains invalid path %s", entry.Path)
+	}
+	actual, err := describeBackupFile(backupDir, entry.Path)
+	if err != nil {
+		return fmt.Errorf("backup is incomplete: %v", err)
+	}
+	if actual.Size != entry.Size || actual.SHA256 != entry.SHA256 {
+		return fmt.Errorf("backup is damaged: %s has checksum %s, manifest says %s", entry.Path, actual.SHA256, entry.SHA256)
+	}
+	return nil
+}
+
+// copyRestoreFile kopierar en fil ur säkerhetskopian och kontrollerar kopian mot manifestet
+func copyRestoreFile(backupDir string, entry backupEntry, dest string) error {
+	copied, err := copyBackupFile(filepath.Join(backupDir, filepath.FromSlash(entry.Path)), filepath.Dir(dest), filepath.Base(dest))
+	if err != nil {
+		return serviceErrorf(errInternal, "failed to copy %s: %v", entry.Path, err)
+	}
+	if copied.SHA256 != entry.SHA256 {
+		return fmt.Errorf("copy of %s does not match the backup", entry.Path)
+	}
+	return nil
+}
+
+// verifyRestoredAuditLog kontrollerar revisionsloggens hashkedja i den återställda databasen
+func verifyRestoredAuditLog(path string) error {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	verification, err := VerifyAuditLog(db)
+	if err != nil {
+		return serviceErrorf(errInternal, "failed to verify audit log in backup: %v", err)
+	}
+	if !verification.Valid {
+		return fmt.Errorf("audit log in backup is invalid: %s", verification.Message)
+	}
+	return nil
+}
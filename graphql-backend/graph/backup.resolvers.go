@@ -0,0 +1,49 @@
+package graph
+
+// This file will be automatically regenerated based on the schema, any resolver implementations
+// will be copied through when generating and any unknown code will be moved to the end.
+// Code generated by github.com/99designs/gqlgen version v0.17.69
+
+import (
+	"context"
+	"graphql-backend/graph/model"
+	"log"
+)
+
+// CreateBackup is the resolver for the createBackup field.
+func (r *mutationResolver) CreateBackup(ctx context.Context) (*model.Backup, error) {
+	logAction("Creating backup")
+
+	if r.DB == nil {
+		log.Printf("Database connection is nil")
+		return nil, serviceErrorf(errInternal, "internal server error: database connection is not initialized")
+	}
+
+	// Säkerhetskopian innehåller alla användares data
+	if _, err := requireAdministrator(ctx, r.DB, "create backups"); err != nil {
+		return nil, err
+	}
+
+	backup, err := CreateBackup(r.DB, false)
+	if err != nil {
+		log.Printf("Error creating backup: %v", err)
+		return nil, err
+	}
+	return backup, nil
+}
+
+// Backups is the resolver for the backups field.
+func (r *queryResolver) Backups(ctx context.Context) ([]*model.Backup, error) {
+	logAction("Listing backups")
+
+	if r.DB == nil {
+		log.Printf("Database connection is nil")
+		return nil, serviceErrorf(errInternal, "internal server error: database connection is not initialized")
+	}
+
+	if _, err := requireAdministrator(ctx, r.DB, "list backups"); err != nil {
+		return nil, err
+	}
+
+	return ListBackups()
+}
@@ -0,0 +1,231 @@
+package graph
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// setupBackupTest öppnar en SQLite-fil med aktuellt schema och pekar säkerhetskopiorna till en
+// tillfällig katalog, med en katalog för innehåll utanför databasen
+func setupBackupTest(t *testing.T) (db *sql.DB, dsn string, blobDir string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	dsn = filepath.Join(dir, "e-Arkive.db")
+	db, _, err := OpenDatabase(dsn)
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := Migrate(db, DialectSQLite); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	blobDir = filepath.Join(dir, "content")
+	if err := os.MkdirAll(filepath.Join(blobDir, "2026"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, filepath.Join(blobDir, "2026", "brev.txt"), "original")
+
+	previous := backupOptions
+	SetBackupOptions(BackupOptions{Dir: filepath.Join(dir, "backups"), BlobDirs: []string{blobDir}})
+	t.Cleanup(func() { SetBackupOptions(previous) })
+	return db, dsn, blobDir
+}
+
+func countNodes(t *testing.T, db *sql.DB) int {
+	t.Helper()
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM nodes").Scan(&count); err != nil {
+		t.Fatalf("count nodes: %v", err)
+	}
+	return count
+}
+
+func TestCreateAndRestoreBackup(t *testing.T) {
+	db, dsn, blobDir := setupBackupTest(t)
+	createTestNode(t, db, "Före kopian", nil)
+	before := countNodes(t, db)
+
+	backup, err := CreateBackup(db, false)
+	if err != nil {
+		t.Fatalf("create backup: %v", err)
+	}
+	if backup.Scheduled || backup.BlobFiles != 1 || backup.DatabaseSize == 0 || backup.SchemaVersion < 1 {
+		t.Errorf("backup = %+v", backup)
+	}
+	backups, err := ListBackups()
+	if err != nil || len(backups) != 1 || backups[0].Name != backup.Name {
+		t.Fatalf("backups = %v, %v, want %s", backups, err, backup.Name)
+	}
+
+	// Ändringar efter kopian ska försvinna vid återställningen
+	createTestNode(t, db, "Efter kopian", nil)
+	writeTestFile(t, filepath.Join(blobDir, "2026", "brev.txt"), "ändrad")
+	writeTestFile(t, filepath.Join(blobDir, "ny.txt"), "ny")
+	db.Close()
+
+	result, err := RestoreBackup(backup.Name, dsn)
+	if err != nil {
+		t.Fatalf("restore backup: %v", err)
+	}
+	if result.PreviousDatabase == "" || len(result.PreviousBlobDirs) != 1 {
+		t.Errorf("result = %+v, want the previous data kept", result)
+	}
+
+	restored, _, err := OpenDatabase(dsn)
+	if err != nil {
+		t.Fatalf("open restored database: %v", err)
+	}
+	defer restored.Close()
+	if got := countNodes(t, restored); got != before {
+		t.Errorf("nodes after restore = %d, want %d", got, before)
+	}
+	if data, err := os.ReadFile(filepath.Join(blobDir, "2026", "brev.txt")); err != nil || string(data) != "original" {
+		t.Errorf("restored blob = %q, %v, want original", data, err)
+	}
+	if _, err := os.Stat(filepath.Join(blobDir, "ny.txt")); !os.IsNotExist(err) {
+		t.Errorf("file added after the backup still exists: %v", err)
+	}
+
+	previous, _, err := OpenDatabase(result.PreviousDatabase)
+	if err != nil {
+		t.Fatalf("open previous database: %v", err)
+	}
+	defer previous.Close()
+	if got := countNodes(t, previous); got != before+1 {
+		t.Errorf("nodes in previous database = %d, want %d", got, before+1)
+	}
+}
+
+func TestRestoreRejectsDamagedBackup(t *testing.T) {
+	db, dsn, _ := setupBackupTest(t)
+	backup, err := CreateBackup(db, false)
+	if err != nil {
+		t.Fatalf("create backup: %v", err)
+	}
+	createTestNode(t, db, "Behålls", nil)
+	want := countNodes(t, db)
+
+	copyPath := filepath.Join(backupOptions.Dir, backup.Name, backupDatabaseName)
+	data, err := os.ReadFile(copyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[len(data)/2] ^= 0xff
+	writeTestFile(t, copyPath, string(data))
+
+	if _, err := RestoreBackup(backup.Name, dsn); err == nil || !strings.Contains(err.Error(), "backup is damaged") {
+		t.Fatalf("restore damaged backup: err = %v", err)
+	}
+	if got := countNodes(t, db); got != want {
+		t.Errorf("nodes after refused restore = %d, want %d", got, want)
+	}
+	if _, err := os.Stat(dsn + ".restoring"); !os.IsNotExist(err) {
+		t.Errorf("staged copy was left behind: %v", err)
+	}
+
+	if _, err := RestoreBackup(backup.Name, "postgres://localhost/earkive"); err == nil {
+		t.Error("restore into PostgreSQL succeeded")
+	}
+}
+
+func TestRestoreRefusesDatabaseInUse(t *testing.T) {
+	db, dsn, _ := setupBackupTest(t)
+	backup, err := CreateBackup(db, false)
+	if err != nil {
+		t.Fatalf("create backup: %v", err)
+	}
+	createTestNode(t, db, "Behålls", nil)
+	want := countNodes(t, db)
+
+	// En pågående läsning räcker för att återställningen ska vägras
+	rows, err := db.Query("SELECT id FROM nodes")
+	if err != nil || !rows.Next() {
+		t.Fatalf("read nodes: %v", err)
+	}
+	if _, err := RestoreBackup(backup.Name, dsn); err == nil || !strings.Contains(err.Error(), "is in use") {
+		t.Fatalf("restore while reading: err = %v", err)
+	}
+	rows.Close()
+	if got := countNodes(t, db); got != want {
+		t.Errorf("nodes after refused restore = %d, want %d", got, want)
+	}
+	if _, err := os.Stat(dsn + ".restoring"); !os.IsNotExist(err) {
+		t.Errorf("staged copy was left behind: %v", err)
+	}
+
+	db.Close()
+	if _, err := RestoreBackup(backup.Name, dsn); err != nil {
+		t.Fatalf("restore after the database was closed: %v", err)
+	}
+}
+
+func TestPruneAndFindBackups(t *testing.T) {
+	db, _, _ := setupBackupTest(t)
+
+	manual, err := CreateBackup(db, false)
+	if err != nil {
+		t.Fatalf("create manual backup: %v", err)
+	}
+	var scheduled []string
+	for i := 0; i < 3; i++ {
+		backup, err := CreateBackup(db, true)
+		if err != nil {
+			t.Fatalf("create scheduled backup: %v", err)
+		}
+		scheduled = append(scheduled, backup.Name)
+	}
+
+	removed, err := PruneBackups(1)
+	if err != nil {
+		t.Fatalf("prune backups: %v", err)
+	}
+	if len(removed) != 2 {
+		t.Errorf("removed = %v, want the two oldest scheduled backups", removed)
+	}
+	backups, err := ListBackups()
+	if err != nil {
+		t.Fatalf("list backups: %v", err)
+	}
+	var names []string
+	for _, backup := range backups {
+		names = append(names, backup.Name)
+	}
+	if len(names) != 2 || names[0] != scheduled[2] || names[1] != manual.Name {
+		t.Errorf("backups after prune = %v, want %s and %s", names, scheduled[2], manual.Name)
+	}
+
+	if name, err := FindBackup(time.Now().Add(time.Minute)); err != nil || name != scheduled[2] {
+		t.Errorf("find latest backup = %s, %v, want %s", name, err, scheduled[2])
+	}
+	if _, err := FindBackup(time.Now().Add(-time.Hour)); err == nil {
+		t.Error("found a backup taken before any backup existed")
+	}
+}
+
+func TestCreateBackupMutation(t *testing.T) {
+	db := openTestDB(t)
+	previous := backupOptions
+	SetBackupOptions(BackupOptions{Dir: t.TempDir()})
+	t.Cleanup(func() { SetBackupOptions(previous) })
+	bobID := insertTestUser(t, db, "bob")
+
+	mutation := NewResolver(db).Mutation()
+	if _, err := mutation.CreateBackup(testUserContext(t, bobID, "bob")); err == nil || err.Error() != "permission denied: must be an administrator to create backups" {
+		t.Errorf("create backup as non-admin: err = %v", err)
+	}
+
+	backup, err := mutation.CreateBackup(testAdminContext(t))
+	if err != nil {
+		t.Fatalf("create backup: %v", err)
+	}
+	backups, err := NewResolver(db).Query().Backups(testAdminContext(t))
+	if err != nil || len(backups) != 1 || backups[0].DatabaseChecksum != backup.DatabaseChecksum {
+		t.Errorf("backups = %v, %v", backups, err)
+	}
+}
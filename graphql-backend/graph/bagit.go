@@ -0,0 +1,948 @@
+package graph
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"graphql-backend/graph/model"
+	"io"
+	"io/fs"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// =============================================
+// ========== BAGIT-PAKET (RFC 8493) =========
+// =============================================
+
+// importBaseDir är katalogen som paket måste ligga i när import startas via API:et
+var importBaseDir = "imports"
+
+// SetImportDir anger katalogen som importer via API:et läser från. Anropas en gång vid start.
+func SetImportDir(dir string) {
+	importBaseDir = dir
+}
+
+// Filnamn i ett BagIt-paket
+const (
+	bagDeclarationFile = "bagit.txt"
+	bagInfoFile        = "bag-info.txt"
+	bagManifestFile    = "manifest-sha256.txt"
+	bagTagManifestFile = "tagmanifest-sha256.txt"
+	bagMetadataFile    = "metadata/e-arkive.json"
+	bagPayloadDir      = "data"
+)
+
+// bagMetadata är sidovagnsfilen som bevarar nodträdets namn, typer och metadata.
+// Utan den kan en import bara återskapa katalogstrukturen under data/.
+type bagMetadata struct {
+	Version    int      `json:"version"`
+	Source     string   `json:"source"`
+	ExportedAt string   `json:"exportedAt"`
+	Root       *bagNode `json:"root"`
+}
+
+type bagNode struct {
+	Name      string            `json:"name"`
+	Path      string            `json:"path"`
+	NodeType  model.NodeType    `json:"nodeType"`
+	Fields    map[string]string `json:"fields,omitempty"`
+	Metadata  []*model.Metadata `json:"metadata,omitempty"`
+	CreatedAt string            `json:"createdAt"`
+	Children  []*bagNode        `json:"children,omitempty"`
+	Files     []*bagFile        `json:"files,omitempty"`
+}
+
+type bagFile struct {
+	Name        string            `json:"name"`
+	Path        string            `json:"path"`
+	ContentType string            `json:"contentType"`
+	CreatedAt   string            `json:"createdAt"`
+	FileType    model.FileType    `json:"fileType"`
+	Fields      map[string]string `json:"fields,omitempty"`
+	Metadata    []*model.Metadata `json:"metadata,omitempty"`
+}
+
+// BagExportResult är resultatet av en BagIt-export
+type BagExportResult struct {
+	Directory string `json:"directory"`
+	Files     int    `json:"files"`
+	Bytes     int64  `json:"bytes"`
+}
+
+// BagImportResult är resultatet av en BagIt-import
+type BagImportResult struct {
+	NodeID string `json:"nodeId"`
+	Nodes  int    `json:"nodes"`
+	Files  int    `json:"files"`
+}
+
+// ---------- Export ----------
+
+// bagExport håller tillståndet för en pågående BagIt-export
+type bagExport struct {
+	ctx       context.Context // Användaren som exporterar, för åtkomstloggningen
+	db        *sql.DB
+	job       *jobContext
+	outDir    string
+	total     int
+	processed int
+	bytes     int64
+	manifest  map[string]string // payloadsökväg -> SHA256
+}
+
+// ExportBag skriver en nod och hela dess underträd som ett BagIt-paket i outDir. userID är
+// användaren som läsningarna loggas på i noder med åtkomstloggning.
+func ExportBag(db *sql.DB, nodeID string, outDir string, userID string) (*BagExportResult, error) {
+	return exportBag(withJobUser(context.Background(), userID), db, nil, nodeID, outDir)
+}
+
+// exportBag skapar paketet som användaren i ctx och rapporterar framsteg till jobbet
+func exportBag(ctx context.Context, db *sql.DB, job *jobContext, nodeID string, outDir string) (*BagExportResult, error) {
+	job.setProgress(0, "Reading node tree")
+
+	root, err := loadSubtree(db, nodeID)
+	if err != nil {
+		return nil, err
+	}
+
+	if entries, err := os.ReadDir(outDir); err == nil && len(entries) > 0 {
+		return nil, fmt.Errorf("output directory %s is not empty", outDir)
+	}
+	if err := os.MkdirAll(filepath.Join(outDir, bagPayloadDir), 0755); err != nil {
+		return nil, serviceErrorf(errInternal, "failed to create bag directory: %v", err)
+	}
+
+	export := &bagExport{
+		ctx:      ctx,
+		db:       db,
+		job:      job,
+		outDir:   outDir,
+		total:    root.countFiles(),
+		manifest: make(map[string]string),
+	}
+
+	meta := &bagMetadata{
+		Version:    1,
+		Source:     "e-Arkive",
+		ExportedAt: time.Now().Format(time.RFC3339),
+	}
+	meta.Root, err = export.writeNode(root, bagPayloadDir)
+	if err != nil {
+		return nil, err
+	}
+
+	job.setProgress(90, "Writing tag files")
+
+	if err := writeBagManifest(filepath.Join(outDir, bagManifestFile), export.manifest); err != nil {
+		return nil, err
+	}
+
+	tagManifest := make(map[string]string)
+	declaration := "BagIt-Version: 1.0\nTag-File-Character-Encoding: UTF-8\n"
+	if tagManifest[bagDeclarationFile], err = writeBagFile(outDir, bagDeclarationFile, []byte(declaration)); err != nil {
+		return nil, err
+	}
+
+	info := []string{
+		"Source-Organization: e-Arkive",
+		"Bag-Software-Agent: e-Arkive",
+		"Bagging-Date: " + time.Now().Format("2006-01-02"),
+		"External-Identifier: " + noarkSystemID("node", root.ID),
+		"External-Description: " + bagInfoValue(root.Name),
+		fmt.Sprintf("Payload-Oxum: %d.%d", export.bytes, len(export.manifest)),
+	}
+	if tagManifest[bagInfoFile], err = writeBagFile(outDir, bagInfoFile, []byte(strings.Join(info, "\n")+"\n")); err != nil {
+		return nil, err
+	}
+
+	metaJSON, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return nil, serviceErrorf(errInternal, "failed to encode bag metadata: %v", err)
+	}
+	if tagManifest[bagMetadataFile], err = writeBagFile(outDir, bagMetadataFile, metaJSON); err != nil {
+		return nil, err
+	}
+
+	if tagManifest[bagManifestFile], err = fileSHA256(filepath.Join(outDir, bagManifestFile)); err != nil {
+		return nil, err
+	}
+	if err := writeBagManifest(filepath.Join(outDir, bagTagManifestFile), tagManifest); err != nil {
+		return nil, err
+	}
+
+	logAction(fmt.Sprintf("BagIt export of node %s written to %s", nodeID, outDir))
+
+	return &BagExportResult{
+		Directory: outDir,
+		Files:     len(export.manifest),
+		Bytes:     export.bytes,
+	}, nil
+}
+
+// writeNode skriver en nods filer och barnnoder under katalogen dir i paketet
+func (e *bagExport) writeNode(node *treeNode, dir string) (*bagNode, error) {
+	result := &bagNode{
+		Name:      node.Name,
+		Path:      dir,
+		NodeType:  node.NodeType,
+		Fields:    node.Fields,
+		Metadata:  node.Metadata,
+		CreatedAt: node.CreatedAt,
+	}
+
+	// Namn måste vara unika inom katalogen, även på filsystem som inte skiljer på versaler
+	used := make(map[string]bool)
+
+	for _, file := range node.Files {
+		if err := e.job.err(); err != nil {
+			return nil, err
+		}
+		if err := RecordFileAccess(e.ctx, e.db, file.ID, model.FileAccessTypeExport); err != nil {
+			return nil, err
+		}
+		data, err := readFileData(e.db, file.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		payloadPath := path.Join(dir, uniqueBagName(used, file.Name))
+		checksum, err := writeBagFile(e.outDir, payloadPath, data)
+		if err != nil {
+			return nil, err
+		}
+		e.manifest[payloadPath] = checksum
+		e.bytes += int64(len(data))
+
+		result.Files = append(result.Files, &bagFile{
+			Name:        file.Name,
+			Path:        payloadPath,
+			ContentType: file.ContentType,
+			CreatedAt:   file.CreatedAt,
+			FileType:    file.FileType,
+			Fields:      file.Fields,
+			Metadata:    file.Metadata,
+		})
+
+		e.processed++
+		if e.total > 0 {
+			e.job.setProgress(e.processed*90/e.total, fmt.Sprintf("Exported %d of %d files", e.processed, e.total))
+		}
+	}
+
+	for _, child := range node.Children {
+		childDir := path.Join(dir, uniqueBagName(used, child.Name))
+		if err := os.MkdirAll(filepath.Join(e.outDir, filepath.FromSlash(childDir)), 0755); err != nil {
+			return nil, serviceErrorf(errInternal, "failed to create directory %s: %v", childDir, err)
+		}
+
+		childNode, err := e.writeNode(child, childDir)
+		if err != nil {
+			return nil, err
+		}
+		result.Children = append(result.Children, childNode)
+	}
+
+	return result, nil
+}
+
+// startBagExportJob kontrollerar noden och startar BagIt-exporten som ett bakgrundsjobb
+func startBagExportJob(db *sql.DB, nodeID string, userID string) (*model.Job, error) {
+	if _, err := getNodeType(db, nodeID); err != nil {
+		return nil, err
+	}
+
+	return enqueueJob(db, JOB_TYPE_BAGIT_EXPORT, userID, bagExportPayload{NodeID: nodeID}, nil)
+}
+
+// bagExportPayload är parametrarna för ett exportjobb
+type bagExportPayload struct {
+	NodeID string `json:"nodeId"`
+}
+
+// runBagExportJob skriver paketet till en ny katalog under exports/ för varje försök
+func runBagExportJob(job *jobContext) (string, error) {
+	var payload bagExportPayload
+	if err := job.decodePayload(&payload); err != nil {
+		return "", err
+	}
+
+	outDir := filepath.Join(exportBaseDir, fmt.Sprintf("bagit-%s-%s", job.id, time.Now().Format("20060102150405")))
+	result, err := exportBag(job.userContext("", ""), job.db, job, payload.NodeID, outDir)
+	if err != nil {
+		return "", err
+	}
+	return encodeJobResult(result)
+}
+
+// ---------- Import ----------
+
+// ImportBag validerar ett BagIt-paket och importerar innehållet under målnoden.
+// userID anges som ägare till de nya noderna; tom sträng ger noder utan ägare.
+func ImportBag(db *sql.DB, bagDir string, targetNodeID string, userID string) (*BagImportResult, error) {
+	return importBag(db, nil, bagDir, targetNodeID, userID)
+}
+
+// importBag utför importen och rapporterar framsteg till jobbet
+func importBag(db *sql.DB, job *jobContext, bagDir string, targetNodeID string, userID string) (*BagImportResult, error) {
+	job.setProgress(0, "Validating bag")
+
+	targetType, err := getNodeType(db, targetNodeID)
+	if err != nil {
+		return nil, fmt.Errorf("target node: %v", err)
+	}
+
+	if err := validateBag(job, bagDir); err != nil {
+		return nil, err
+	}
+
+	root, err := readBagStructure(bagDir)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := validateBagTree(root, targetType); err != nil {
+		return nil, err
+	}
+
+	agent := "system"
+	if userID != "" {
+		if err := db.QueryRow("SELECT username FROM users WHERE id = ?", userID).Scan(&agent); err != nil {
+			log.Printf("Error fetching username for user %s: %v", userID, err)
+			return nil, serviceErrorf(errInternal, "failed to fetch user: %v", err)
+		}
+	}
+
+	acceptedFormats, formatPolicyNodeID, err := effectiveAcceptedFormats(db, targetNodeID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Jobbets framsteg uppdateras inte under transaktionen eftersom SQLite
+	// bara tillåter en skrivare åt gången
+	job.setProgress(50, "Importing files")
+
+	tx, err := db.Begin()
+	if err != nil {
+		log.Printf("Error starting transaction: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to start transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	importer := &bagImporter{
+		tx:                 tx,
+		bagDir:             bagDir,
+		agent:              agent,
+		acceptedFormats:    acceptedFormats,
+		formatPolicyNodeID: formatPolicyNodeID,
+	}
+	if userID != "" {
+		importer.ownerID = &userID
+	}
+
+	nodeID, err := importer.importNode(root, targetNodeID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("Error committing transaction: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to commit transaction: %v", err)
+	}
+
+	logAction(fmt.Sprintf("BagIt import from %s created node %s under node %s", bagDir, nodeID, targetNodeID))
+	notifyCreated(db, "node", nodeID, JOB_TYPE_BAGIT_IMPORT, userID)
+
+	return &BagImportResult{
+		NodeID: nodeID,
+		Nodes:  importer.nodes,
+		Files:  importer.files,
+	}, nil
+}
+
+// validateBag kontrollerar paketets deklaration, att varje payloadfil finns i manifestet
+// och att alla kontrollsummor stämmer
+func validateBag(job *jobContext, bagDir string) error {
+	declaration, err := readBagTagFile(filepath.Join(bagDir, bagDeclarationFile))
+	if err != nil {
+		return fmt.Errorf("invalid bag: %v", err)
+	}
+	if declaration["BagIt-Version"] == "" {
+		return fmt.Errorf("invalid bag: %s is missing BagIt-Version", bagDeclarationFile)
+	}
+	if encoding := declaration["Tag-File-Character-Encoding"]; encoding != "" && !strings.EqualFold(encoding, "UTF-8") {
+		return fmt.Errorf("invalid bag: unsupported tag file encoding %s", encoding)
+	}
+
+	manifest, err := readBagManifest(filepath.Join(bagDir, bagManifestFile))
+	if err != nil {
+		return fmt.Errorf("invalid bag: %v", err)
+	}
+
+	payload, err := listBagPayload(bagDir)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range payload {
+		if _, ok := manifest[p]; !ok {
+			return fmt.Errorf("invalid bag: payload file %s is not listed in %s", p, bagManifestFile)
+		}
+	}
+
+	paths := make([]string, 0, len(manifest))
+	for p := range manifest {
+		if !strings.HasPrefix(p, bagPayloadDir+"/") {
+			return fmt.Errorf("invalid bag: manifest entry %s is outside the payload directory", p)
+		}
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	var totalBytes int64
+	for i, p := range paths {
+		if err := job.err(); err != nil {
+			return err
+		}
+		info, err := os.Stat(filepath.Join(bagDir, filepath.FromSlash(p)))
+		if err != nil {
+			return fmt.Errorf("invalid bag: payload file %s is missing", p)
+		}
+		totalBytes += info.Size()
+
+		checksum, err := fileSHA256(filepath.Join(bagDir, filepath.FromSlash(p)))
+		if err != nil {
+			return err
+		}
+		if checksum != manifest[p] {
+			return fmt.Errorf("invalid bag: checksum mismatch for %s", p)
+		}
+
+		job.setProgress((i+1)*50/len(paths), fmt.Sprintf("Verified %d of %d payload files", i+1, len(paths)))
+	}
+
+	info, err := readBagTagFile(filepath.Join(bagDir, bagInfoFile))
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("invalid bag: %v", err)
+	}
+	if oxum := info["Payload-Oxum"]; oxum != "" {
+		expected := fmt.Sprintf("%d.%d", totalBytes, len(paths))
+		if oxum != expected {
+			return fmt.Errorf("invalid bag: Payload-Oxum is %s but payload is %s", oxum, expected)
+		}
+	}
+
+	// Taggmanifestet är valfritt, men om det finns ska det stämma
+	tagManifest, err := readBagManifest(filepath.Join(bagDir, bagTagManifestFile))
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("invalid bag: %v", err)
+	}
+	for p, expected := range tagManifest {
+		checksum, err := fileSHA256(filepath.Join(bagDir, filepath.FromSlash(p)))
+		if err != nil {
+			return fmt.Errorf("invalid bag: tag file %s is missing", p)
+		}
+		if checksum != expected {
+			return fmt.Errorf("invalid bag: checksum mismatch for tag file %s", p)
+		}
+	}
+
+	return nil
+}
+
+// readBagStructure läser nodträdet från sidovagnsfilen, eller bygger det från
+// katalogstrukturen under data/ om paketet inte kommer från e-Arkive
+func readBagStructure(bagDir string) (*bagNode, error) {
+	data, err := os.ReadFile(filepath.Join(bagDir, filepath.FromSlash(bagMetadataFile)))
+	if err == nil {
+		var meta bagMetadata
+		if err := json.Unmarshal(data, &meta); err != nil {
+			return nil, fmt.Errorf("invalid bag metadata: %v", err)
+		}
+		if meta.Root == nil {
+			return nil, fmt.Errorf("invalid bag metadata: root node is missing")
+		}
+		return meta.Root, nil
+	} else if !os.IsNotExist(err) {
+		return nil, serviceErrorf(errInternal, "failed to read bag metadata: %v", err)
+	}
+
+	info, _ := readBagTagFile(filepath.Join(bagDir, bagInfoFile))
+	name := info["External-Description"]
+	if name == "" {
+		name = filepath.Base(filepath.Clean(bagDir))
+	}
+
+	return readBagDirectory(bagDir, bagPayloadDir, name)
+}
+
+// readBagDirectory bygger en nod av en katalog i paketet
+func readBagDirectory(bagDir string, dir string, name string) (*bagNode, error) {
+	entries, err := os.ReadDir(filepath.Join(bagDir, filepath.FromSlash(dir)))
+	if err != nil {
+		return nil, serviceErrorf(errInternal, "failed to read bag directory %s: %v", dir, err)
+	}
+
+	node := &bagNode{Name: name, Path: dir, NodeType: model.NodeTypeFolder}
+	for _, entry := range entries {
+		entryPath := path.Join(dir, entry.Name())
+		if entry.IsDir() {
+			child, err := readBagDirectory(bagDir, entryPath, entry.Name())
+			if err != nil {
+				return nil, err
+			}
+			node.Children = append(node.Children, child)
+			continue
+		}
+
+		node.Files = append(node.Files, &bagFile{
+			Name:     entry.Name(),
+			Path:     entryPath,
+			FileType: model.FileTypeDokument,
+		})
+	}
+
+	return node, nil
+}
+
+// validateBagTree kontrollerar typer, obligatoriska fält och placering innan något skrivs
+func validateBagTree(node *bagNode, parentType model.NodeType) error {
+	if node.NodeType == "" {
+		node.NodeType = model.NodeTypeFolder
+	}
+	if node.Name == "" {
+		return fmt.Errorf("invalid bag: node at %s has no name", node.Path)
+	}
+	if err := validateNodePlacement(node.NodeType, &parentType); err != nil {
+		return fmt.Errorf("invalid bag: %s: %v", node.Path, err)
+	}
+	if _, err := validateTypedFields(string(node.NodeType), nodeTypeFields[node.NodeType], typedFieldInputs(node.Fields)); err != nil {
+		return fmt.Errorf("invalid bag: %s: %v", node.Path, err)
+	}
+
+	for _, file := range node.Files {
+		if file.FileType == "" {
+			file.FileType = model.FileTypeDokument
+		}
+		if !strings.HasPrefix(file.Path, bagPayloadDir+"/") || path.Clean(file.Path) != file.Path {
+			return fmt.Errorf("invalid bag: file path %s is outside the payload directory", file.Path)
+		}
+		if err := validateFilePlacement(file.FileType, node.NodeType); err != nil {
+			return fmt.Errorf("invalid bag: %s: %v", file.Path, err)
+		}
+		if _, err := validateTypedFields(string(file.FileType), fileTypeFields[file.FileType], typedFieldInputs(file.Fields)); err != nil {
+			return fmt.Errorf("invalid bag: %s: %v", file.Path, err)
+		}
+	}
+
+	for _, child := range node.Children {
+		if err := validateBagTree(child, node.NodeType); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// bagImporter skriver ett validerat paket till databasen inom en transaktion
+type bagImporter struct {
+	tx      *sql.Tx
+	bagDir  string
+	ownerID *string
+	agent   string // Användarnamn som registreras på bevarandehändelserna
+	nodes   int
+	files   int
+
+	acceptedFormats    []string // Godkända format för målnoden, nil om alla format godtas
+	formatPolicyNodeID string
+}
+
+// importNode skapar en nod med filer och barnnoder under parentID och returnerar nodens ID
+func (i *bagImporter) importNode(node *bagNode, parentID string) (string, error) {
+	now := time.Now().Format(time.RFC3339)
+	createdAt := node.CreatedAt
+	if createdAt == "" {
+		createdAt = now
+	}
+
+	result, err := i.tx.Exec(
+		"INSERT INTO nodes (name, parent_id, owner_user_id, node_type, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?)",
+		node.Name, parentID, i.ownerID, node.NodeType, createdAt, now,
+	)
+	if err != nil {
+		log.Printf("Error creating node during bag import: %v", err)
+		return "", serviceErrorf(errInternal, "failed to create node %s: %v", node.Name, err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return "", serviceErrorf(errInternal, "failed to retrieve node ID: %v", err)
+	}
+	nodeID := strconv.FormatInt(id, 10)
+	i.nodes++
+
+	if err := saveTypedFields(i.tx, ENTITY_KIND_NODE, nodeID, node.Fields); err != nil {
+		return "", err
+	}
+
+	for _, meta := range node.Metadata {
+		if meta == nil {
+			continue
+		}
+		entry := metadataEntry{Key: meta.Key, Value: meta.Value, Type: meta.Type, Inheritable: meta.Inheritable}
+		if err := insertNodeMetadata(i.tx, nodeID, entry); err != nil {
+			return "", err
+		}
+	}
+
+	for _, file := range node.Files {
+		if err := i.importFile(file, nodeID); err != nil {
+			return "", err
+		}
+	}
+
+	for _, child := range node.Children {
+		if _, err := i.importNode(child, nodeID); err != nil {
+			return "", err
+		}
+	}
+
+	return nodeID, nil
+}
+
+// importFile läser en payloadfil och sparar den med metadata i noden
+func (i *bagImporter) importFile(file *bagFile, nodeID string) error {
+	data, err := os.ReadFile(filepath.Join(i.bagDir, filepath.FromSlash(file.Path)))
+	if err != nil {
+		return serviceErrorf(errInternal, "failed to read payload file %s: %v", file.Path, err)
+	}
+
+	contentType := file.ContentType
+	if contentType == "" {
+		contentType = detectContentType(file.Name, data)
+	}
+	createdAt := file.CreatedAt
+	if createdAt == "" {
+		createdAt = time.Now().Format(time.RFC3339)
+	}
+
+	// Formatet identifieras från innehållet; målnodens lista över godkända format gäller hela importen
+	format := identifyFormat(file.Name, contentType, data)
+	if err := validateFormatAccepted(i.acceptedFormats, i.formatPolicyNodeID, format); err != nil {
+		return fmt.Errorf("%s: %v", file.Path, err)
+	}
+
+	checksum := checksumSHA256(data)
+	result, err := i.tx.Exec(
+		"INSERT INTO files (name, size, content_type, created_at, file_data, node_id, file_type, checksum, puid, format_mime_type, format_mismatch) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		file.Name, len(data), contentType, createdAt, data, nodeID, file.FileType, checksum,
+		optionalString(format.PUID()), optionalString(format.MimeType()), format.Mismatch,
+	)
+	if err != nil {
+		log.Printf("Error saving file during bag import: %v", err)
+		return serviceErrorf(errInternal, "failed to save file %s: %v", file.Name, err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return serviceErrorf(errInternal, "failed to retrieve file ID: %v", err)
+	}
+	fileID := strconv.FormatInt(id, 10)
+
+	for _, meta := range file.Metadata {
+		if meta == nil {
+			continue
+		}
+		if err := insertMetadata(i.tx, fileID, metadataEntry{Key: meta.Key, Value: meta.Value, Type: meta.Type}); err != nil {
+			return err
+		}
+	}
+
+	if err := saveTypedFields(i.tx, ENTITY_KIND_FILE, fileID, file.Fields); err != nil {
+		return err
+	}
+
+	if err := recordPreservationEvent(i.tx, fileID, PRESERVATION_EVENT_INGEST, PRESERVATION_OUTCOME_SUCCESS,
+		fmt.Sprintf("Imported from BagIt package %s (manifest checksum verified)", filepath.Base(i.bagDir)), i.agent, checksum); err != nil {
+		return err
+	}
+	if err := recordPreservationEvent(i.tx, fileID, PRESERVATION_EVENT_FORMAT_IDENTIFICATION, format.eventOutcome(),
+		format.eventDetail(contentType), i.agent, checksum); err != nil {
+		return err
+	}
+
+	i.files++
+	return nil
+}
+
+// startBagImportJob kontrollerar indata och startar BagIt-importen som ett bakgrundsjobb.
+// Paket som importeras via API:et måste ligga under importkatalogen på servern.
+func startBagImportJob(db *sql.DB, bagPath string, targetNodeID string, userID string) (*model.Job, error) {
+	bagDir, err := resolveImportPath(bagPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := getNodeType(db, targetNodeID); err != nil {
+		return nil, err
+	}
+
+	return enqueueJob(db, JOB_TYPE_BAGIT_IMPORT, userID, bagImportPayload{BagDir: bagDir, TargetNodeID: targetNodeID}, nil)
+}
+
+// bagImportPayload är parametrarna för ett importjobb
+type bagImportPayload struct {
+	BagDir       string `json:"bagDir"`
+	TargetNodeID string `json:"targetNodeId"`
+}
+
+// runBagImportJob importerar paketet. Importen görs i en transaktion och kan därför köras om.
+func runBagImportJob(job *jobContext) (string, error) {
+	var payload bagImportPayload
+	if err := job.decodePayload(&payload); err != nil {
+		return "", err
+	}
+
+	result, err := importBag(job.db, job, payload.BagDir, payload.TargetNodeID, job.userID)
+	if err != nil {
+		return "", err
+	}
+	return encodeJobResult(result)
+}
+
+// resolveImportPath översätter en sökväg relativt importkatalogen och hindrar att den pekar utanför
+func resolveImportPath(bagPath string) (string, error) {
+	dir, ok := importPath(bagPath)
+	if !ok {
+		return "", fmt.Errorf("bag path must be relative to the %s directory", importBaseDir)
+	}
+
+	info, err := os.Stat(dir)
+	if err != nil || !info.IsDir() {
+		return "", serviceErrorf(errNotFound, "bag %s not found", bagPath)
+	}
+	return dir, nil
+}
+
+// =============================================
+// ========== HJÄLPFUNKTIONER ================
+// =============================================
+
+// importPath lägger en relativ sökväg under importkatalogen. ok är false om sökvägen är tom,
+// absolut eller pekar utanför katalogen.
+func importPath(relative string) (string, bool) {
+	clean := filepath.Clean(filepath.FromSlash(relative))
+	if relative == "" || filepath.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return filepath.Join(importBaseDir, clean), true
+}
+
+// readBagManifest läser ett manifest med rader på formen "<kontrollsumma> <sökväg>"
+func readBagManifest(filename string) (map[string]string, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	manifest := make(map[string]string)
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimRight(scanner.Text(), "\r")
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+
+		fields := strings.Fields(text)
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("%s line %d: expected checksum and path", filepath.Base(filename), line)
+		}
+
+		// Sökvägen kan innehålla blanksteg, så allt efter kontrollsumman räknas
+		checksum := strings.ToLower(fields[0])
+		p := decodeBagPath(strings.TrimLeft(strings.TrimPrefix(strings.TrimLeft(text, " \t"), fields[0]), " \t"))
+		if path.IsAbs(p) || path.Clean(p) != p || strings.HasPrefix(p, "../") {
+			return nil, fmt.Errorf("%s line %d: invalid path %s", filepath.Base(filename), line, p)
+		}
+		manifest[p] = checksum
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, serviceErrorf(errInternal, "failed to read %s: %v", filepath.Base(filename), err)
+	}
+	return manifest, nil
+}
+
+// writeBagManifest skriver ett manifest sorterat på sökväg
+func writeBagManifest(filename string, manifest map[string]string) error {
+	paths := make([]string, 0, len(manifest))
+	for p := range manifest {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	var sb strings.Builder
+	for _, p := range paths {
+		fmt.Fprintf(&sb, "%s  %s\n", manifest[p], encodeBagPath(p))
+	}
+
+	if err := os.WriteFile(filename, []byte(sb.String()), 0644); err != nil {
+		return serviceErrorf(errInternal, "failed to write %s: %v", filepath.Base(filename), err)
+	}
+	return nil
+}
+
+// readBagTagFile läser en taggfil med rader på formen "Etikett: värde"
+func readBagTagFile(filename string) (map[string]string, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return map[string]string{}, err
+	}
+
+	values := make(map[string]string)
+	var last string
+	for _, line := range strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n") {
+		if line == "" {
+			continue
+		}
+		// Rader som börjar med blanksteg fortsätter föregående värde
+		if (line[0] == ' ' || line[0] == '\t') && last != "" {
+			values[last] += " " + strings.TrimSpace(line)
+			continue
+		}
+		label, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, fmt.Errorf("%s: malformed line %q", filepath.Base(filename), line)
+		}
+		last = strings.TrimSpace(label)
+		if _, exists := values[last]; !exists {
+			values[last] = strings.TrimSpace(value)
+		}
+	}
+	return values, nil
+}
+
+// listBagPayload listar alla filer under data/ som sökvägar relativt paketet
+func listBagPayload(bagDir string) ([]string, error) {
+	root := filepath.Join(bagDir, bagPayloadDir)
+	if info, err := os.Stat(root); err != nil || !info.IsDir() {
+		return nil, fmt.Errorf("invalid bag: payload directory %s is missing", bagPayloadDir)
+	}
+
+	var payload []string
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(bagDir, p)
+		if err != nil {
+			return err
+		}
+		payload = append(payload, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		return nil, serviceErrorf(errInternal, "failed to list payload: %v", err)
+	}
+	return payload, nil
+}
+
+// writeBagFile skriver en fil i paketet och returnerar dess SHA256-kontrollsumma
+func writeBagFile(bagDir string, name string, data []byte) (string, error) {
+	filename := filepath.Join(bagDir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return "", serviceErrorf(errInternal, "failed to create directory for %s: %v", name, err)
+	}
+	if err := os.WriteFile(filename, data, 0644); err != nil {
+		return "", serviceErrorf(errInternal, "failed to write %s: %v", name, err)
+	}
+
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// fileSHA256 beräknar SHA256-kontrollsumman för en fil
+func fileSHA256(filename string) (string, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", serviceErrorf(errInternal, "failed to read %s: %v", filename, err)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// uniqueBagName gör ett namn säkert som sökvägssegment och unikt inom katalogen
+func uniqueBagName(used map[string]bool, name string) string {
+	base := bagPathSegment(name)
+	candidate := base
+	ext := path.Ext(base)
+	for n := 2; used[strings.ToLower(candidate)]; n++ {
+		candidate = fmt.Sprintf("%s-%d%s", strings.TrimSuffix(base, ext), n, ext)
+	}
+	used[strings.ToLower(candidate)] = true
+	return candidate
+}
+
+// bagPathSegment ersätter tecken som inte kan ingå i ett sökvägssegment
+func bagPathSegment(name string) string {
+	clean := strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r < 0x20 || r == 0x7f {
+			return '_'
+		}
+		return r
+	}, strings.TrimSpace(name))
+
+	if clean == "" || clean == "." || clean == ".." {
+		return "unnamed"
+	}
+	return clean
+}
+
+// encodeBagPath och decodeBagPath hanterar procentkodningen av CR, LF och % i manifest (RFC 8493, 2.1.3)
+func encodeBagPath(p string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(p)
+}
+
+func decodeBagPath(p string) string {
+	return strings.NewReplacer("%0D", "\r", "%0d", "\r", "%0A", "\n", "%0a", "\n", "%25", "%").Replace(p)
+}
+
+// bagInfoValue gör ett värde säkert att skriva på en rad i bag-info.txt
+func bagInfoValue(value string) string {
+	return strings.NewReplacer("\r", " ", "\n", " ").Replace(value)
+}
+
+// detectContentType gissar innehållstypen från filändelsen eller innehållet
+func detectContentType(name string, data []byte) string {
+	if contentType := mime.TypeByExtension(path.Ext(name)); contentType != "" {
+		return contentType
+	}
+	return http.DetectContentType(data)
+}
+
+// encodeJobResult serialiserar ett jobbresultat till JSON
+func encodeJobResult(result interface{}) (string, error) {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return "", serviceErrorf(errInternal, "failed to encode job result: %v", err)
+	}
+	return string(data), nil
+}
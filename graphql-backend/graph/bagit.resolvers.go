@@ -0,0 +1,47 @@
+package graph
+
+// This file will be automatically regenerated based on the schema, any resolver implementations
+// will be copied through when generating and any unknown code will be moved to the end.
+// Code generated by github.com/99designs/gqlgen version v0.17.69
+
+import (
+	"context"
+	"fmt"
+	"graphql-backend/graph/model"
+	"log"
+)
+
+// StartBagExport is the resolver for the startBagExport field.
+func (r *mutationResolver) StartBagExport(ctx context.Context, nodeID string) (*model.Job, error) {
+	logAction(fmt.Sprintf("Starting BagIt export of node %s", nodeID))
+
+	if r.DB == nil {
+		log.Printf("Database connection is nil")
+		return nil, serviceErrorf(errInternal, "internal server error: database connection is not initialized")
+	}
+
+	// Paketet omfattar hela underträdet och kräver därför administratörsbehörighet
+	userID, err := requireAdministrator(ctx, r.DB, "export bags")
+	if err != nil {
+		return nil, err
+	}
+
+	return startBagExportJob(r.DB, nodeID, userID)
+}
+
+// StartBagImport is the resolver for the startBagImport field.
+func (r *mutationResolver) StartBagImport(ctx context.Context, path string, targetNodeID string) (*model.Job, error) {
+	logAction(fmt.Sprintf("Starting BagIt import of %s into node %s", path, targetNodeID))
+
+	if r.DB == nil {
+		log.Printf("Database connection is nil")
+		return nil, serviceErrorf(errInternal, "internal server error: database connection is not initialized")
+	}
+
+	userID, err := requireAdministrator(ctx, r.DB, "import bags")
+	if err != nil {
+		return nil, err
+	}
+
+	return startBagImportJob(r.DB, path, targetNodeID, userID)
+}
@@ -0,0 +1,184 @@
+package graph
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"graphql-backend/graph/model"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// exportTestBag skapar ett litet nodträd med en fil i varje nivå och exporterar det som ett paket
+func exportTestBag(t *testing.T) (db *sql.DB, bagDir string) {
+	t.Helper()
+	db = openTestDB(t)
+
+	root := createTestNode(t, db, "Arkiv", nil)
+	serie := createTestNode(t, db, "Serie A", &root.ID)
+	saveTestFile(t, db, "brev.txt", root.ID, "första brevet")
+	saveTestFile(t, db, "protokoll.txt", serie.ID, "protokoll från mötet")
+
+	bagDir = filepath.Join(t.TempDir(), "bag")
+	result, err := ExportBag(db, root.ID, bagDir, "1")
+	if err != nil {
+		t.Fatalf("export bag: %v", err)
+	}
+	if result.Files != 2 || result.Directory != bagDir {
+		t.Errorf("export result = %+v", result)
+	}
+	return db, bagDir
+}
+
+func TestBagExportAndImportRoundTrip(t *testing.T) {
+	db, bagDir := exportTestBag(t)
+
+	for _, name := range []string{bagDeclarationFile, bagInfoFile, bagManifestFile, bagTagManifestFile, bagMetadataFile} {
+		if _, err := os.Stat(filepath.Join(bagDir, filepath.FromSlash(name))); err != nil {
+			t.Errorf("bag is missing %s: %v", name, err)
+		}
+	}
+
+	target := createTestNode(t, db, "Mottaget", nil)
+	result, err := ImportBag(db, bagDir, target.ID, "1")
+	if err != nil {
+		t.Fatalf("import bag: %v", err)
+	}
+	if result.Nodes != 2 || result.Files != 2 {
+		t.Errorf("import result = %+v, want 2 nodes and 2 files", result)
+	}
+
+	ctx := testAdminContext(t)
+	query := NewResolver(db).Query()
+	imported, err := query.GetNodeByID(ctx, result.NodeID)
+	if err != nil || imported.Name != "Arkiv" || imported.ParentID == nil || *imported.ParentID != target.ID {
+		t.Fatalf("imported root = %+v, %v", imported, err)
+	}
+	files, err := query.GetFilesByNodeID(ctx, result.NodeID)
+	if err != nil || len(files) != 1 {
+		t.Fatalf("files = %v, %v", files, err)
+	}
+	file, err := query.DownloadFile(ctx, files[0].ID)
+	if err != nil {
+		t.Fatalf("download: %v", err)
+	}
+	if data, _ := base64.StdEncoding.DecodeString(*file.FileData); string(data) != "första brevet" || file.Name != "brev.txt" {
+		t.Errorf("imported file = %s %q", file.Name, data)
+	}
+	if author, _ := fileMetadataValue(file, "author"); author != "Anna" {
+		t.Errorf("author = %q, want the metadata from the sidecar file", author)
+	}
+}
+
+func TestBagImportRejectsTamperedBags(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper func(t *testing.T, bagDir string)
+		want   string
+	}{
+		{
+			name: "changed payload",
+			tamper: func(t *testing.T, bagDir string) {
+				writeTestFile(t, filepath.Join(bagDir, "data", "brev.txt"), "förfalskat")
+			},
+			want: "checksum mismatch for data/brev.txt",
+		},
+		{
+			name: "unlisted payload",
+			tamper: func(t *testing.T, bagDir string) {
+				writeTestFile(t, filepath.Join(bagDir, "data", "extra.txt"), "smugglat")
+			},
+			want: "payload file data/extra.txt is not listed",
+		},
+		{
+			name: "missing payload",
+			tamper: func(t *testing.T, bagDir string) {
+				if err := os.Remove(filepath.Join(bagDir, "data", "brev.txt")); err != nil {
+					t.Fatal(err)
+				}
+			},
+			want: "payload file data/brev.txt is missing",
+		},
+		{
+			name: "changed tag file",
+			tamper: func(t *testing.T, bagDir string) {
+				info, err := os.ReadFile(filepath.Join(bagDir, bagInfoFile))
+				if err != nil {
+					t.Fatal(err)
+				}
+				writeTestFile(t, filepath.Join(bagDir, bagInfoFile), string(info)+"Contact-Name: Okänd\n")
+			},
+			want: "checksum mismatch for tag file bag-info.txt",
+		},
+		{
+			name: "missing declaration",
+			tamper: func(t *testing.T, bagDir string) {
+				if err := os.Remove(filepath.Join(bagDir, bagDeclarationFile)); err != nil {
+					t.Fatal(err)
+				}
+			},
+			want: "invalid bag",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, bagDir := exportTestBag(t)
+			target := createTestNode(t, db, "Mottaget", nil)
+			tt.tamper(t, bagDir)
+
+			if _, err := ImportBag(db, bagDir, target.ID, "1"); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("import: err = %v, want %q", err, tt.want)
+			}
+			children, err := NewResolver(db).Query().GetChildNodes(testAdminContext(t), target.ID)
+			if err != nil || len(children) != 0 {
+				t.Errorf("children after refused import = %v, %v, want none", children, err)
+			}
+		})
+	}
+}
+
+func TestBagImportChecksNodeTypes(t *testing.T) {
+	db, bagDir := exportTestBag(t)
+	nodeType := model.NodeTypeArkiv
+	arkiv, err := NewResolver(db).Mutation().CreateNode(testAdminContext(t), model.NodeInput{Name: "Arkiv", NodeType: &nodeType, Fields: typedFields("arkivstatus", "Opprettet")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Paketets mappar är FOLDER, som inte får ligga direkt under ett arkiv
+	if _, err := ImportBag(db, bagDir, arkiv.ID, "1"); err == nil {
+		t.Error("imported folders directly under an arkiv")
+	}
+}
+
+func TestResolveImportPath(t *testing.T) {
+	t.Chdir(t.TempDir())
+	if err := os.MkdirAll(filepath.Join(importBaseDir, "leverans", "2026"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if dir, err := resolveImportPath("leverans/2026"); err != nil || dir != filepath.Join(importBaseDir, "leverans", "2026") {
+		t.Errorf("resolveImportPath = %s, %v", dir, err)
+	}
+	for _, bad := range []string{"", "/etc", "..", "../hemligt", "leverans/../../hemligt"} {
+		if dir, err := resolveImportPath(bad); err == nil || err.Error() != "bag path must be relative to the imports directory" {
+			t.Errorf("resolveImportPath(%q) = %s, %v, want it refused", bad, dir, err)
+		}
+	}
+	if _, err := resolveImportPath("leverans/2025"); err == nil || err.Error() != "bag leverans/2025 not found" {
+		t.Errorf("missing bag: err = %v", err)
+	}
+}
+
+func TestImportPath(t *testing.T) {
+	if dir, ok := importPath("leverans/2026"); !ok || dir != filepath.Join(importBaseDir, "leverans", "2026") {
+		t.Errorf("importPath = %s, %v", dir, ok)
+	}
+	for _, bad := range []string{"", "/etc", "..", "../hemligt", "leverans/../../hemligt"} {
+		if dir, ok := importPath(bad); ok {
+			t.Errorf("importPath(%q) = %s, want it refused", bad, dir)
+		}
+	}
+}
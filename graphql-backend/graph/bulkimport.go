@@ -0,0 +1,692 @@
+package graph
+
+import (
+	"archive/zip"
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"graphql-backend/graph/model"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// =============================================
+// ========== MASSIMPORT ======================
+// =============================================
+
+// Sidovagnsfiler som används om ingen annan anges
+var defaultBulkImportSidecars = []string{"metadata.csv", "metadata.json"}
+
+// BulkImportOptions styr en massimport
+type BulkImportOptions struct {
+	Sidecar      string `json:"sidecar,omitempty"` // Sidovagnsfil relativt källans rot, tom för standardnamnen
+	SkipExisting bool   `json:"skipExisting"`      // Hoppa över filer med samma namn som en befintlig fil i noden
+}
+
+// BulkImportResult är resultatet av en massimport
+type BulkImportResult struct {
+	TargetNodeID string `json:"targetNodeId"`
+	Nodes        int    `json:"nodes"`
+	Files        int    `json:"files"`
+	Skipped      int    `json:"skipped"`
+	Failed       int    `json:"failed"`
+}
+
+// bulkImportEntry är en katalog eller fil i källan, med sökvägen separerad med /
+type bulkImportEntry struct {
+	Path string
+	Dir  bool
+	read func() ([]byte, error)
+}
+
+// ---------- Källor ----------
+
+// readDirectorySource listar ett katalogträd på servern. Symboliska länkar följs inte,
+// så att importen inte kan läsa filer utanför katalogen.
+func readDirectorySource(dir string) ([]*bulkImportEntry, error) {
+	var entries []*bulkImportEntry
+	err := filepath.WalkDir(dir, func(filename string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		relative, err := filepath.Rel(dir, filename)
+		if err != nil || relative == "." {
+			return err
+		}
+		if d.Type()&fs.ModeSymlink != 0 {
+			return nil
+		}
+
+		entry := &bulkImportEntry{Path: filepath.ToSlash(relative), Dir: d.IsDir()}
+		if !d.IsDir() {
+			entry.read = func() ([]byte, error) { return os.ReadFile(filename) }
+		}
+		entries = append(entries, entry)
+		return nil
+	})
+	if err != nil {
+		return nil, serviceErrorf(errInternal, "failed to read directory %s: %v", dir, err)
+	}
+	return entries, nil
+}
+
+// readZipSource listar innehållet i ett ZIP-arkiv. Poster med sökvägar som pekar utanför
+// arkivets rot och macOS-metadata hoppas över.
+func readZipSource(data []byte) ([]*bulkImportEntry, error) {
+	reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid ZIP archive: %v", err)
+	}
+
+	var entries []*bulkImportEntry
+	for _, file := range reader.File {
+		name := strings.ReplaceAll(file.Name, "\\", "/")
+		clean := path.Clean(strings.TrimPrefix(name, "./"))
+		if path.IsAbs(clean) || clean == "." || clean == ".." || strings.HasPrefix(clean, "../") {
+			log.Printf("Skipping ZIP entry with unsafe path %s", file.Name)
+			continue
+		}
+		if clean == "__MACOSX" || strings.HasPrefix(clean, "__MACOSX/") {
+			continue
+		}
+
+		entry := &bulkImportEntry{Path: clean, Dir: strings.HasSuffix(name, "/")}
+		if !entry.Dir {
+			zipFile := file
+			entry.read = func() ([]byte, error) {
+				content, err := zipFile.Open()
+				if err != nil {
+					return nil, err
+				}
+				defer content.Close()
+				return io.ReadAll(content)
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// readLocalSource läser en katalog, eller ett ZIP-arkiv om sökvägen är en fil
+func readLocalSource(filename string) ([]*bulkImportEntry, error) {
+	info, err := os.Stat(filename)
+	if err != nil {
+		return nil, serviceErrorf(errNotFound, "import source %s not found", filename)
+	}
+	if info.IsDir() {
+		return readDirectorySource(filename)
+	}
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, serviceErrorf(errInternal, "failed to read %s: %v", filename, err)
+	}
+	return readZipSource(data)
+}
+
+// ---------- Sidovagnsfil ----------
+
+// bulkImportSidecar är metadata per sökväg. Sökvägar till kataloger ger metadata på noden.
+type bulkImportSidecar map[string][]metadataEntry
+
+// parseBulkImportSidecar läser metadata ur en CSV- eller JSON-fil.
+//
+// CSV: rubrikraden börjar med kolumnen path, övriga kolumner är metadatanycklar. En nyckel kan
+// få en typ med kolon, t.ex. belopp:DECIMAL. Tomma celler hoppas över, och samma nyckel kan
+// förekomma i flera kolumner för att ge flera värden.
+//
+// JSON: en lista med objekt {"path": "...", "metadata": [{"key": "...", "value": "...", "type": "..."}]}.
+func parseBulkImportSidecar(name string, data []byte) (bulkImportSidecar, error) {
+	sidecar := make(bulkImportSidecar)
+
+	if strings.EqualFold(path.Ext(name), ".json") {
+		var records []struct {
+			Path     string                 `json:"path"`
+			Metadata []*model.MetadataInput `json:"metadata"`
+		}
+		if err := json.Unmarshal(data, &records); err != nil {
+			return nil, fmt.Errorf("invalid sidecar %s: %v", name, err)
+		}
+		for _, record := range records {
+			for _, input := range record.Metadata {
+				if input != nil && input.Type != nil && !input.Type.IsValid() {
+					return nil, fmt.Errorf("invalid sidecar %s: unknown metadata type %s", name, *input.Type)
+				}
+			}
+			key := normalizeImportPath(record.Path)
+			sidecar[key] = append(sidecar[key], nodeMetadataEntriesFromInput(record.Metadata)...)
+		}
+		return sidecar, nil
+	}
+
+	records, err := newCSVReader(string(data)).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("invalid sidecar %s: %v", name, err)
+	}
+	if len(records) == 0 || !strings.EqualFold(strings.TrimSpace(records[0][0]), "path") {
+		return nil, fmt.Errorf("invalid sidecar %s: the first column must be path", name)
+	}
+
+	type column struct {
+		key       string
+		valueType model.MetadataValueType
+	}
+	var columns []column
+	for _, header := range records[0][1:] {
+		key, valueType, _ := strings.Cut(strings.TrimSpace(header), ":")
+		if key == "" {
+			return nil, fmt.Errorf("invalid sidecar %s: empty column name", name)
+		}
+		if valueType != "" && !model.MetadataValueType(strings.ToUpper(valueType)).IsValid() {
+			return nil, fmt.Errorf("invalid sidecar %s: unknown metadata type %s in column %s", name, valueType, header)
+		}
+		columns = append(columns, column{key: key, valueType: model.MetadataValueType(strings.ToUpper(valueType))})
+	}
+
+	for _, record := range records[1:] {
+		filePath := normalizeImportPath(record[0])
+		if filePath == "" {
+			continue
+		}
+		for i, value := range record[1:] {
+			if value = strings.TrimSpace(value); value != "" && i < len(columns) {
+				sidecar[filePath] = append(sidecar[filePath], metadataEntry{Key: columns[i].key, Value: value, Type: columns[i].valueType})
+			}
+		}
+	}
+	return sidecar, nil
+}
+
+// normalizeImportPath gör om en sökväg i sidovagnsfilen till samma form som källans sökvägar
+func normalizeImportPath(p string) string {
+	p = strings.TrimSpace(strings.ReplaceAll(p, "\\", "/"))
+	if p == "" {
+		return ""
+	}
+	return strings.TrimPrefix(path.Clean("/"+p), "/")
+}
+
+// findBulkImportSidecar hittar och läser sidovagnsfilen bland källans filer. Sidovagnsfilen
+// importeras inte som fil. Utan angiven fil används standardnamnen i källans rot.
+func findBulkImportSidecar(entries []*bulkImportEntry, name string) (bulkImportSidecar, string, error) {
+	candidates := defaultBulkImportSidecars
+	if name != "" {
+		candidates = []string{normalizeImportPath(name)}
+	}
+
+	for _, candidate := range candidates {
+		for _, entry := range entries {
+			if entry.Dir || entry.Path != candidate {
+				continue
+			}
+			data, err := entry.read()
+			if err != nil {
+				return nil, "", serviceErrorf(errInternal, "failed to read sidecar %s: %v", candidate, err)
+			}
+			sidecar, err := parseBulkImportSidecar(candidate, data)
+			return sidecar, candidate, err
+		}
+	}
+
+	if name != "" {
+		return nil, "", serviceErrorf(errNotFound, "sidecar %s not found in the import source", name)
+	}
+	return bulkImportSidecar{}, "", nil
+}
+
+// ---------- Import ----------
+
+// bulkImporter skapar noder och filer för en källa. Varje fil sparas i en egen transaktion,
+// så att en fil som inte kan importeras inte stoppar resten.
+type bulkImporter struct {
+	db           *sql.DB
+	job          *jobContext
+	userID       string
+	ownerID      *string
+	agent        string
+	skipExisting bool
+	sidecar      bulkImportSidecar
+	nodes        map[string]string // katalogsökväg -> nod-ID, "." är målnoden
+	result       BulkImportResult
+	items        []*model.BulkImportItem
+}
+
+// BulkImport importerar en katalog eller ett ZIP-arkiv på servern under målnoden utan att
+// registrera något jobb. Utfallet per mapp och fil returneras tillsammans med resultatet.
+func BulkImport(db *sql.DB, source string, targetNodeID string, userID string, options BulkImportOptions) (*BulkImportResult, []*model.BulkImportItem, error) {
+	entries, err := readLocalSource(source)
+	if err != nil {
+		return nil, nil, err
+	}
+	importer, err := runBulkImport(db, nil, entries, targetNodeID, userID, options)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &importer.result, importer.items, nil
+}
+
+// runBulkImport återskapar källans katalogträd som noder under målnoden och importerar filerna
+func runBulkImport(db *sql.DB, job *jobContext, entries []*bulkImportEntry, targetNodeID string, userID string, options BulkImportOptions) (*bulkImporter, error) {
+	job.setProgress(0, "Reading import source")
+
+	if _, err := getNodeType(db, targetNodeID); err != nil {
+		return nil, fmt.Errorf("target node: %v", err)
+	}
+
+	sidecar, sidecarPath, err := findBulkImportSidecar(entries, options.Sidecar)
+	if err != nil {
+		return nil, err
+	}
+
+	importer := &bulkImporter{
+		db:           db,
+		job:          job,
+		userID:       userID,
+		agent:        "system",
+		skipExisting: options.SkipExisting,
+		sidecar:      sidecar,
+		nodes:        map[string]string{".": targetNodeID},
+		result:       BulkImportResult{TargetNodeID: targetNodeID},
+	}
+	if userID != "" {
+		importer.ownerID = &userID
+		if importer.agent, err = lookupUsername(db, userID); err != nil {
+			return nil, err
+		}
+	}
+
+	// Kataloger som bara finns underförstått i filernas sökvägar skapas också
+	dirs := make(map[string]bool)
+	var files []*bulkImportEntry
+	for _, entry := range entries {
+		if entry.Dir {
+			dirs[entry.Path] = true
+			continue
+		}
+		if entry.Path == sidecarPath {
+			continue
+		}
+		files = append(files, entry)
+		for dir := path.Dir(entry.Path); dir != "."; dir = path.Dir(dir) {
+			dirs[dir] = true
+		}
+	}
+
+	// Föräldrar skapas före barnen eftersom en kortare sökväg sorteras först
+	dirPaths := make([]string, 0, len(dirs))
+	for dir := range dirs {
+		dirPaths = append(dirPaths, dir)
+	}
+	sort.Strings(dirPaths)
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+
+	used := make(map[string]bool)
+	for _, dir := range dirPaths {
+		importer.importDir(dir)
+		used[dir] = true
+	}
+	for i, file := range files {
+		if err := job.err(); err != nil {
+			return nil, err
+		}
+		job.setProgress(i*100/len(files), fmt.Sprintf("Importing file %d of %d", i+1, len(files)))
+		importer.importFile(file)
+		used[file.Path] = true
+	}
+
+	// Rader i sidovagnsfilen som inte motsvarar något i källan är troligen felskrivna sökvägar
+	var unmatched []string
+	for entryPath := range sidecar {
+		if !used[entryPath] {
+			unmatched = append(unmatched, entryPath)
+		}
+	}
+	sort.Strings(unmatched)
+	for _, entryPath := range unmatched {
+		importer.record(&model.BulkImportItem{Path: entryPath, Kind: model.BulkImportItemKindFile, Status: model.BulkImportItemStatusFailed},
+			fmt.Sprintf("%s has metadata in %s but is not in the import source", entryPath, sidecarPath))
+	}
+
+	logAction(fmt.Sprintf("Bulk import into node %s: %d nodes and %d files imported, %d skipped, %d failed",
+		targetNodeID, importer.result.Nodes, importer.result.Files, importer.result.Skipped, importer.result.Failed))
+	return importer, nil
+}
+
+// importDir skapar noden för en katalog, eller återanvänder en befintlig nod med samma namn
+func (i *bulkImporter) importDir(dir string) {
+	item := &model.BulkImportItem{Path: dir, Kind: model.BulkImportItemKindNode}
+
+	parentID, ok := i.nodes[path.Dir(dir)]
+	if !ok {
+		item.Status = model.BulkImportItemStatusFailed
+		i.record(item, "the parent folder was not imported")
+		return
+	}
+	name := path.Base(dir)
+
+	var existingID string
+	err := i.db.QueryRow("SELECT id FROM nodes WHERE parent_id = ? AND name = ? ORDER BY id ASC LIMIT 1", parentID, name).Scan(&existingID)
+	if err == nil {
+		i.nodes[dir] = existingID
+		item.NodeID = &existingID
+		item.Status = model.BulkImportItemStatusSkipped
+		i.record(item, "an existing node with the same name is used")
+		return
+	} else if err != sql.ErrNoRows {
+		log.Printf("Error looking up node %s under node %s: %v", name, parentID, err)
+		item.Status = model.BulkImportItemStatusFailed
+		i.record(item, fmt.Sprintf("failed to look up existing node: %v", err))
+		return
+	}
+
+	nodeID, err := i.createNode(name, parentID, i.sidecar[dir])
+	if err != nil {
+		item.Status = model.BulkImportItemStatusFailed
+		i.record(item, bulkImportErrorMessage(err))
+		return
+	}
+	i.nodes[dir] = nodeID
+	item.NodeID = &nodeID
+	item.Status = model.BulkImportItemStatusImported
+	i.record(item, "")
+}
+
+// createNode skapar en mapp med metadata från sidovagnsfilen
+func (i *bulkImporter) createNode(name string, parentID string, metadata []metadataEntry) (string, error) {
+	if err := checkNodePlacement(i.db, model.NodeTypeFolder, &parentID); err != nil {
+		return "", err
+	}
+	entries, err := checkMetadataValid(i.db, "", metadata)
+	if err != nil {
+		return "", err
+	}
+
+	tx, err := i.db.Begin()
+	if err != nil {
+		log.Printf("Error starting transaction: %v", err)
+		return "", serviceErrorf(errInternal, "failed to start transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now().Format(time.RFC3339)
+	result, err := tx.Exec(
+		"INSERT INTO nodes (name, parent_id, owner_user_id, node_type, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?)",
+		name, parentID, i.ownerID, model.NodeTypeFolder, now, now,
+	)
+	if err != nil {
+		log.Printf("Error creating node during bulk import: %v", err)
+		return "", serviceErrorf(errInternal, "failed to create node: %v", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return "", serviceErrorf(errInternal, "failed to retrieve node ID: %v", err)
+	}
+	nodeID := strconv.FormatInt(id, 10)
+
+	for _, entry := range entries {
+		if err := insertNodeMetadata(tx, nodeID, entry); err != nil {
+			return "", err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("Error committing transaction: %v", err)
+		return "", serviceErrorf(errInternal, "failed to commit transaction: %v", err)
+	}
+	notifyCreated(i.db, "node", nodeID, JOB_TYPE_BULK_IMPORT, i.userID)
+	return nodeID, nil
+}
+
+// importFile sparar en fil med samma kontroller som saveFile
+func (i *bulkImporter) importFile(entry *bulkImportEntry) {
+	item := &model.BulkImportItem{Path: entry.Path, Kind: model.BulkImportItemKindFile}
+
+	nodeID, ok := i.nodes[path.Dir(entry.Path)]
+	if !ok {
+		item.Status = model.BulkImportItemStatusFailed
+		i.record(item, "the folder of the file was not imported")
+		return
+	}
+	item.NodeID = &nodeID
+	name := path.Base(entry.Path)
+
+	if i.skipExisting {
+		var exists bool
+		err := i.db.QueryRow("SELECT EXISTS(SELECT 1 FROM files WHERE node_id = ? AND name = ?)", nodeID, name).Scan(&exists)
+		if err != nil {
+			log.Printf("Error checking for existing file %s: %v", entry.Path, err)
+			item.Status = model.BulkImportItemStatusFailed
+			i.record(item, fmt.Sprintf("failed to check for existing file: %v", err))
+			return
+		}
+		if exists {
+			item.Status = model.BulkImportItemStatusSkipped
+			i.record(item, "a file with the same name already exists")
+			return
+		}
+	}
+
+	fileID, err := i.saveFile(entry, name, nodeID)
+	if err != nil {
+		item.Status = model.BulkImportItemStatusFailed
+		i.record(item, bulkImportErrorMessage(err))
+		return
+	}
+	item.FileID = &fileID
+	item.Status = model.BulkImportItemStatusImported
+	i.record(item, "")
+}
+
+// saveFile kontrollerar och sparar en fil med metadata och bevarandehändelser
+func (i *bulkImporter) saveFile(entry *bulkImportEntry, name string, nodeID string) (string, error) {
+	data, err := entry.read()
+	if err != nil {
+		return "", serviceErrorf(errInternal, "failed to read file: %v", err)
+	}
+
+	if err := checkFilePlacement(i.db, model.FileTypeDokument, nodeID); err != nil {
+		return "", err
+	}
+	contentType := detectContentType(name, data)
+	format := identifyFormat(name, contentType, data)
+	if err := checkFormatAccepted(i.db, nodeID, format); err != nil {
+		return "", err
+	}
+
+	defaults, err := inheritedMetadataDefaults(i.db, nodeID)
+	if err != nil {
+		return "", err
+	}
+	metadataEntries, err := checkMetadataValid(i.db, nodeID, withInheritedMetadata(i.sidecar[entry.Path], defaults))
+	if err != nil {
+		return "", err
+	}
+
+	tx, err := i.db.Begin()
+	if err != nil {
+		log.Printf("Error starting transaction: %v", err)
+		return "", serviceErrorf(errInternal, "failed to start transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	checksum := checksumSHA256(data)
+	result, err := tx.Exec(
+		"INSERT INTO files (name, size, content_type, created_at, file_data, node_id, file_type, checksum, puid, format_mime_type, format_mismatch) VALUES (?, ?, ?, datetime('now'), ?, ?, ?, ?, ?, ?, ?)",
+		name, len(data), contentType, data, nodeID, model.FileTypeDokument, checksum,
+		optionalString(format.PUID()), optionalString(format.MimeType()), format.Mismatch,
+	)
+	if err != nil {
+		log.Printf("Error saving file during bulk import: %v", err)
+		return "", serviceErrorf(errInternal, "failed to save file: %v", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return "", serviceErrorf(errInternal, "failed to retrieve file ID: %v", err)
+	}
+	fileID := strconv.FormatInt(id, 10)
+
+	for _, metadata := range metadataEntries {
+		if err := insertMetadata(tx, fileID, metadata); err != nil {
+			return "", err
+		}
+	}
+
+	if err := recordPreservationEvent(tx, fileID, PRESERVATION_EVENT_INGEST, PRESERVATION_OUTCOME_SUCCESS,
+		fmt.Sprintf("Imported from %s in a bulk import", entry.Path), i.agent, checksum); err != nil {
+		return "", err
+	}
+	if err := recordPreservationEvent(tx, fileID, PRESERVATION_EVENT_FORMAT_IDENTIFICATION, format.eventOutcome(),
+		format.eventDetail(contentType), i.agent, checksum); err != nil {
+		return "", err
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("Error committing transaction: %v", err)
+		return "", serviceErrorf(errInternal, "failed to commit transaction: %v", err)
+	}
+	notifyCreated(i.db, "file", fileID, JOB_TYPE_BULK_IMPORT, i.userID)
+	return fileID, nil
+}
+
+// bulkImportErrorMessage ger felmeddelandet utan prefixet som GraphQL-fel får från Error()
+func bulkImportErrorMessage(err error) string {
+	var gqlErr *gqlerror.Error
+	if errors.As(err, &gqlErr) {
+		return gqlErr.Message
+	}
+	return err.Error()
+}
+
+// record räknar utfallet för en mapp eller fil och sparar det på jobbet
+func (i *bulkImporter) record(item *model.BulkImportItem, message string) {
+	item.Message = optionalString(message)
+
+	switch item.Status {
+	case model.BulkImportItemStatusImported:
+		if item.Kind == model.BulkImportItemKindNode {
+			i.result.Nodes++
+		} else {
+			i.result.Files++
+		}
+	case model.BulkImportItemStatusSkipped:
+		i.result.Skipped++
+	case model.BulkImportItemStatusFailed:
+		i.result.Failed++
+		log.Printf("Bulk import of %s failed: %s", item.Path, message)
+	}
+	i.items = append(i.items, item)
+
+	if i.job == nil {
+		return
+	}
+	result, err := i.db.Exec(
+		"INSERT INTO bulk_import_items (job_id, path, kind, status, node_id, file_id, message) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		i.job.id, item.Path, item.Kind, item.Status, item.NodeID, item.FileID, item.Message,
+	)
+	if err != nil {
+		log.Printf("Error saving bulk import item %s: %v", item.Path, err)
+		return
+	}
+	if id, err := result.LastInsertId(); err == nil {
+		item.ID = strconv.FormatInt(id, 10)
+	}
+}
+
+// bulkImportPayload är parametrarna för ett importjobb. Ett uppladdat ZIP-arkiv sparas som
+// jobbets indata; annars läses källan från Source på servern.
+type bulkImportPayload struct {
+	TargetNodeID string            `json:"targetNodeId"`
+	Source       string            `json:"source,omitempty"`
+	Options      BulkImportOptions `json:"options"`
+}
+
+// startBulkImportJob lägger massimporten i jobbkön
+func startBulkImportJob(db *sql.DB, source string, zipData []byte, targetNodeID string, userID string, options BulkImportOptions) (*model.Job, error) {
+	payload := bulkImportPayload{TargetNodeID: targetNodeID, Source: source, Options: options}
+	return enqueueJob(db, JOB_TYPE_BULK_IMPORT, userID, payload, zipData)
+}
+
+// runBulkImportJob kör massimporten. Ett nytt försök fortsätter där det förra slutade: mappar
+// som redan skapats återanvänds och filer som redan importerats hoppas över.
+func runBulkImportJob(job *jobContext) (string, error) {
+	var payload bulkImportPayload
+	if err := job.decodePayload(&payload); err != nil {
+		return "", err
+	}
+
+	var entries []*bulkImportEntry
+	var err error
+	if payload.Source != "" {
+		entries, err = readLocalSource(payload.Source)
+	} else {
+		entries, err = readZipSource(job.inputData)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if job.attempt > 1 {
+		payload.Options.SkipExisting = true
+		if _, err := job.db.Exec("DELETE FROM bulk_import_items WHERE job_id = ?", job.id); err != nil {
+			log.Printf("Error clearing import items of job %s: %v", job.id, err)
+			return "", serviceErrorf(errInternal, "failed to clear import items: %v", err)
+		}
+	}
+
+	importer, err := runBulkImport(job.db, job, entries, payload.TargetNodeID, job.userID, payload.Options)
+	if err != nil {
+		return "", err
+	}
+	return encodeJobResult(importer.result)
+}
+
+// loadBulkImportItems hämtar utfallet per mapp och fil för ett jobb
+func loadBulkImportItems(db *sql.DB, jobID string, status *model.BulkImportItemStatus, limit int, offset int) ([]*model.BulkImportItem, error) {
+	where := "job_id = ?"
+	args := []interface{}{jobID}
+	if status != nil {
+		where += " AND status = ?"
+		args = append(args, *status)
+	}
+	args = append(args, limit, offset)
+
+	rows, err := db.Query(
+		"SELECT id, path, kind, status, node_id, file_id, message FROM bulk_import_items WHERE "+where+" ORDER BY id ASC LIMIT ? OFFSET ?",
+		args...,
+	)
+	if err != nil {
+		log.Printf("Error fetching bulk import items of job %s: %v", jobID, err)
+		return nil, serviceErrorf(errInternal, "failed to fetch import items: %v", err)
+	}
+	defer rows.Close()
+
+	items := []*model.BulkImportItem{}
+	for rows.Next() {
+		var item model.BulkImportItem
+		var kind, itemStatus string
+		var nodeID, fileID, message sql.NullString
+		if err := rows.Scan(&item.ID, &item.Path, &kind, &itemStatus, &nodeID, &fileID, &message); err != nil {
+			log.Printf("Error scanning bulk import item row: %v", err)
+			return nil, serviceErrorf(errInternal, "failed to scan import item row: %v", err)
+		}
+		item.Kind = model.BulkImportItemKind(kind)
+		item.Status = model.BulkImportItemStatus(itemStatus)
+		item.NodeID = nullStringPtr(nodeID)
+		item.FileID = nullStringPtr(fileID)
+		item.Message = nullStringPtr(message)
+		items = append(items, &item)
+	}
+	return items, rows.Err()
+}
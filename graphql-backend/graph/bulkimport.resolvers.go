@@ -0,0 +1,92 @@
+package graph
+
+// This file will be automatically regenerated based on the schema, any resolver implementations
+// will be copied through when generating and any unknown code will be moved to the end.
+// Code generated by github.com/99designs/gqlgen version v0.17.69
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"graphql-backend/graph/model"
+	"log"
+)
+
+// ImportItems is the resolver for the importItems field.
+func (r *jobResolver) ImportItems(ctx context.Context, obj *model.Job, status *model.BulkImportItemStatus, limit *int, offset *int) ([]*model.BulkImportItem, error) {
+	if r.DB == nil {
+		log.Printf("Database connection is nil")
+		return nil, serviceErrorf(errInternal, "internal server error: database connection is not initialized")
+	}
+
+	pageSize, skip := 100, 0
+	if limit != nil && *limit > 0 {
+		pageSize = *limit
+	}
+	if offset != nil && *offset > 0 {
+		skip = *offset
+	}
+
+	return loadBulkImportItems(r.DB, obj.ID, status, pageSize, skip)
+}
+
+// StartBulkImport is the resolver for the startBulkImport field.
+func (r *mutationResolver) StartBulkImport(ctx context.Context, input model.BulkImportInput) (*model.Job, error) {
+	logAction(fmt.Sprintf("Starting bulk import into node %s", input.TargetNodeID))
+
+	if r.DB == nil {
+		log.Printf("Database connection is nil")
+		return nil, serviceErrorf(errInternal, "internal server error: database connection is not initialized")
+	}
+
+	userID, err := getUserIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	hasPermission, err := checkPermission(ctx, r.DB, input.TargetNodeID, PERM_MODIFY)
+	if err != nil {
+		return nil, err
+	}
+
+	if !hasPermission {
+		return nil, serviceErrorf(errPermissionDenied, "permission denied: cannot modify this node")
+	}
+
+	if (input.Path == nil) == (input.ZipData == nil) {
+		return nil, fmt.Errorf("exactly one of path and zipData must be given")
+	}
+
+	// Källan läses redan här så att ogiltiga arkiv och sökvägar avvisas direkt
+	var source string
+	var zipData []byte
+	if input.Path != nil {
+		// Kataloger på servern kan innehålla vad som helst och kräver därför administratörsbehörighet
+		if _, err := requireAdministrator(ctx, r.DB, "import from server directories"); err != nil {
+			return nil, err
+		}
+		filename, ok := importPath(*input.Path)
+		if !ok {
+			return nil, fmt.Errorf("import path must be relative to the %s directory", importBaseDir)
+		}
+		source = filename
+		_, err = readLocalSource(source)
+	} else {
+		zipData, err = base64.StdEncoding.DecodeString(*input.ZipData)
+		if err != nil {
+			log.Printf("Error decoding ZIP data: %v", err)
+			return nil, fmt.Errorf("invalid ZIP data: %v", err)
+		}
+		_, err = readZipSource(zipData)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	options := BulkImportOptions{SkipExisting: input.SkipExisting != nil && *input.SkipExisting}
+	if input.Sidecar != nil {
+		options.Sidecar = *input.Sidecar
+	}
+
+	return startBulkImportJob(r.DB, source, zipData, input.TargetNodeID, userID, options)
+}
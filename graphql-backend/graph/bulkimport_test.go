@@ -0,0 +1,200 @@
+package graph
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"graphql-backend/graph/model"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// lastAuditArguments hämtar argumenten i den senaste händelsen i revisionsloggen för åtgärden
+func lastAuditArguments(t *testing.T, db *sql.DB, action string) string {
+	t.Helper()
+	var arguments sql.NullString
+	err := db.QueryRow("SELECT arguments FROM audit_events WHERE action = ? ORDER BY id DESC LIMIT 1", action).Scan(&arguments)
+	if err != nil {
+		t.Fatalf("fetch audit event for %s: %v", action, err)
+	}
+	return arguments.String
+}
+
+func TestBulkImportAuditRedactsZipData(t *testing.T) {
+	db := openTestDB(t)
+	ctx := testAdminContext(t)
+	nodeID := insertTestNode(t, db, "Import", "")
+
+	zipData := base64.StdEncoding.EncodeToString(testZipArchive(t, map[string]string{"brev.txt": "konfidentiellt innehåll"}))
+	input := model.BulkImportInput{TargetNodeID: nodeID, ZipData: &zipData}
+	mutation := NewResolver(db).Mutation()
+	_, err := callResolver(ctx, db, "Mutation", "startBulkImport", map[string]interface{}{"input": input}, func(ctx context.Context) (interface{}, error) {
+		return mutation.StartBulkImport(ctx, input)
+	})
+	if err != nil {
+		t.Fatalf("start bulk import: %v", err)
+	}
+
+	arguments := lastAuditArguments(t, db, "startBulkImport")
+	if !strings.Contains(arguments, `"zipData":"`+auditRedacted+`"`) {
+		t.Errorf("arguments = %s, want zipData redacted", arguments)
+	}
+	if strings.Contains(arguments, zipData) || !strings.Contains(arguments, `"targetNodeId":"`+nodeID+`"`) {
+		t.Errorf("arguments = %s, want only the archive removed", arguments)
+	}
+}
+
+func TestAuditArgumentsRedaction(t *testing.T) {
+	large := strings.Repeat("x", auditMaxArgumentLength+1)
+	arguments := auditArguments(map[string]interface{}{
+		"vocabularyId": "3",
+		"data":         "<rdf:RDF/>",
+		"input":        map[string]interface{}{"newPassword": "hemligt", "content": large, "name": "Brev"},
+	})
+
+	for _, hidden := range []string{"<rdf:RDF/>", "hemligt", large} {
+		if strings.Contains(arguments, hidden) {
+			t.Errorf("arguments contain %.20q: %s", hidden, arguments)
+		}
+	}
+	if !strings.Contains(arguments, `"vocabularyId":"3"`) || !strings.Contains(arguments, `"name":"Brev"`) {
+		t.Errorf("arguments = %s, want the other values kept", arguments)
+	}
+}
+
+// importItemSummary skriver utfallet per mapp och fil som "sökväg=status"
+func importItemSummary(items []*model.BulkImportItem) string {
+	parts := make([]string, len(items))
+	for i, item := range items {
+		parts[i] = item.Path + "=" + string(item.Status)
+	}
+	return strings.Join(parts, " ")
+}
+
+func TestBulkImportFromDirectory(t *testing.T) {
+	db := openTestDB(t)
+	ctx := testAdminContext(t)
+	target := createTestNode(t, db, "Filserver", nil)
+
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"Protokoll/april.txt":     "april",
+		"Protokoll/2026/mars.txt": "mars",
+		"metadata.csv":            "path,diarienummer,belopp:DECIMAL\nProtokoll,2026/1,\nProtokoll/april.txt,2026/2,12.50\nProtokoll/2026/mars.txt,2026/3,tusen\nProtokoll/maj.txt,2026/4,\n",
+	} {
+		filename := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filename, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(dir, "Tom"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	result, items, err := BulkImport(db, dir, target.ID, "1", BulkImportOptions{})
+	if err != nil {
+		t.Fatalf("bulk import: %v", err)
+	}
+	// Ogiltig metadata och rader i sidovagnsfilen utan motsvarande fil stoppar inte resten av importen
+	want := "Protokoll=IMPORTED Protokoll/2026=IMPORTED Tom=IMPORTED Protokoll/2026/mars.txt=FAILED Protokoll/april.txt=IMPORTED Protokoll/maj.txt=FAILED"
+	if got := importItemSummary(items); got != want {
+		t.Errorf("items:\n%s\nwant:\n%s", got, want)
+	}
+	if result.Nodes != 3 || result.Files != 1 || result.Skipped != 0 || result.Failed != 2 {
+		t.Errorf("result = %+v", result)
+	}
+	if message := items[3].Message; message == nil || !strings.Contains(*message, "belopp") {
+		t.Errorf("message for invalid metadata = %v", message)
+	}
+	if message := items[5].Message; message == nil || *message != "Protokoll/maj.txt has metadata in metadata.csv but is not in the import source" {
+		t.Errorf("message for unmatched sidecar row = %v", message)
+	}
+
+	protokoll := *items[0].NodeID
+	if metadata, _ := loadNodeMetadata(db, protokoll); metadataSummary(metadata) != "diarienummer:STRING=2026/1" {
+		t.Errorf("node metadata = %s", metadataSummary(metadata))
+	}
+	files, err := NewResolver(db).Query().GetFilesByNodeID(ctx, protokoll)
+	if err != nil || len(files) != 1 {
+		t.Fatalf("files in Protokoll = %v, %v, want only april.txt", files, err)
+	}
+	if got := metadataSummary(files[0].Metadata); files[0].Name != "april.txt" || got != "diarienummer:STRING=2026/2 belopp:DECIMAL=12.50" {
+		t.Errorf("file %s metadata = %s", files[0].Name, got)
+	}
+
+	// En ny körning återanvänder mapparna och hoppar över filer som redan finns
+	result, items, err = BulkImport(db, dir, target.ID, "1", BulkImportOptions{SkipExisting: true})
+	if err != nil {
+		t.Fatalf("repeat bulk import: %v", err)
+	}
+	if result.Nodes != 0 || result.Files != 0 || result.Skipped != 4 || result.Failed != 2 || *items[0].NodeID != protokoll {
+		t.Errorf("repeated import = %+v, items %s", result, importItemSummary(items))
+	}
+
+	if _, _, err := BulkImport(db, dir, target.ID, "1", BulkImportOptions{Sidecar: "saknas.csv"}); err == nil || err.Error() != "sidecar saknas.csv not found in the import source" {
+		t.Errorf("missing sidecar: err = %v", err)
+	}
+}
+
+func TestBulkImportJobFromZip(t *testing.T) {
+	db := openTestDB(t)
+	ctx := testAdminContext(t)
+	resolver := NewResolver(db)
+	target := createTestNode(t, db, "Migrering", nil)
+
+	zipData := base64.StdEncoding.EncodeToString(testZipArchive(t, map[string]string{
+		"Avtal/leverantor.txt":  "avtal",
+		"../utanfor.txt":        "utanför",
+		"__MACOSX/Avtal/._a":    "resursgren",
+		"beskrivning/meta.json": `[{"path": "Avtal/leverantor.txt", "metadata": [{"key": "giltigTill", "value": "2027-12-31", "type": "DATE"}]}]`,
+	}))
+	sidecar := "beskrivning/meta.json"
+	input := model.BulkImportInput{TargetNodeID: target.ID, ZipData: &zipData, Sidecar: &sidecar}
+	job, err := resolver.Mutation().StartBulkImport(ctx, input)
+	if err != nil {
+		t.Fatalf("start bulk import: %v", err)
+	}
+	if job.Status != model.JobStatusQueued {
+		t.Errorf("status = %s, want QUEUED", job.Status)
+	}
+
+	job = runQueuedTestJob(t, db)
+	if job.Status != model.JobStatusSucceeded {
+		t.Fatalf("job = %s (%v), want SUCCEEDED", job.Status, job.Error)
+	}
+	// Sökvägar utanför arkivet, macOS-metadata och sidovagnsfilen, med sin katalog, importeras inte
+	items, err := resolver.Job().ImportItems(ctx, job, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := importItemSummary(items); got != "Avtal=IMPORTED Avtal/leverantor.txt=IMPORTED" {
+		t.Errorf("items = %s", got)
+	}
+	file := mustGetFile(t, db, *items[1].FileID)
+	if got := metadataSummary(file.Metadata); got != "giltigTill:DATE=2027-12-31" {
+		t.Errorf("metadata = %s", got)
+	}
+	failed := model.BulkImportItemStatusFailed
+	if items, err := resolver.Job().ImportItems(ctx, job, &failed, nil, nil); err != nil || len(items) != 0 {
+		t.Errorf("failed items = %v, %v", items, err)
+	}
+
+	bobID := insertTestUser(t, db, "bob")
+	bob := testUserContext(t, bobID, "bob")
+	if _, err := resolver.Mutation().StartBulkImport(bob, input); err == nil || !strings.HasPrefix(err.Error(), "permission denied") {
+		t.Errorf("import as user without modify permission: err = %v", err)
+	}
+	path := "filserver"
+	if _, err := resolver.Mutation().StartBulkImport(ctx, model.BulkImportInput{TargetNodeID: target.ID, Path: &path, ZipData: &zipData}); err == nil || err.Error() != "exactly one of path and zipData must be given" {
+		t.Errorf("path and zipData: err = %v", err)
+	}
+	invalid := base64.StdEncoding.EncodeToString([]byte("inte ett arkiv"))
+	if _, err := resolver.Mutation().StartBulkImport(ctx, model.BulkImportInput{TargetNodeID: target.ID, ZipData: &invalid}); err == nil || !strings.HasPrefix(err.Error(), "invalid ZIP archive") {
+		t.Errorf("invalid archive: err = %v", err)
+	}
+}
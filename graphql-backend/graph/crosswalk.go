@@ -0,0 +1,465 @@
+package graph
+
+import (
+	"database/sql"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"graphql-backend/graph/model"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// =============================================
+// ========== CROSSWALKS TILL DUBLIN CORE =====
+// =============================================
+
+const (
+	dcNamespace      = "http://purl.org/dc/elements/1.1/"
+	dctermsNamespace = "http://purl.org/dc/terms/"
+
+	crosswalkSourceMetadata     = "metadata:"
+	crosswalkSourceNodeMetadata = "node:"
+	crosswalkSourceField        = "field:"
+	crosswalkSourceConstant     = "const:"
+)
+
+// dublinCoreElements är de 15 elementen i Dublin Core Metadata Element Set
+var dublinCoreElements = []string{
+	"title", "creator", "subject", "description", "publisher", "contributor", "date", "type",
+	"format", "identifier", "source", "language", "relation", "coverage", "rights",
+}
+
+// crosswalkBuiltinSources är filens egna egenskaper som kan mappas utan prefix
+var crosswalkBuiltinSources = []string{
+	"id", "name", "contentType", "size", "createdAt", "updatedAt", "checksum", "puid", "formatName", "fileType", "nodeName",
+}
+
+// builtinCrosswalkMappings används när ingen crosswalk är markerad som standard
+var builtinCrosswalkMappings = []*model.CrosswalkMapping{
+	{Source: "name", Target: "dc:title"},
+	{Source: "createdAt", Target: "dc:date"},
+	{Source: "contentType", Target: "dc:format"},
+	{Source: "id", Target: "dc:identifier"},
+}
+
+// dublinCoreElement är ett värde i en Dublin Core-post, t.ex. dc:title
+type dublinCoreElement struct {
+	Prefix string // dc eller dcterms
+	Name   string
+	Value  string
+}
+
+func (e dublinCoreElement) qualifiedName() string {
+	return e.Prefix + ":" + e.Name
+}
+
+// normalizeCrosswalkTarget kontrollerar ett mål och lägger till dc: framför element utan prefix
+func normalizeCrosswalkTarget(target string) (string, error) {
+	target = strings.TrimSpace(target)
+	prefix, name, found := strings.Cut(target, ":")
+	if !found {
+		prefix, name = "dc", target
+	}
+
+	switch prefix {
+	case "dc":
+		if !containsString(dublinCoreElements, name) {
+			return "", fmt.Errorf("unknown Dublin Core element %s, expected one of %s", name, strings.Join(dublinCoreElements, ", "))
+		}
+	case "dcterms":
+		if !isXMLName(name) {
+			return "", fmt.Errorf("invalid DCMI term %s", name)
+		}
+	default:
+		return "", fmt.Errorf("unknown crosswalk target %s, expected dc:<element> or dcterms:<term>", target)
+	}
+	return prefix + ":" + name, nil
+}
+
+// isXMLName kontrollerar att ett namn kan användas som elementnamn utan prefix
+func isXMLName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, c := range name {
+		letter := c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+		if !letter && (i == 0 || !(c == '-' || c == '.' || (c >= '0' && c <= '9'))) {
+			return false
+		}
+	}
+	return true
+}
+
+// validateCrosswalkSource kontrollerar att en källa är en egenskap hos filen eller har ett känt prefix
+func validateCrosswalkSource(source string) (string, error) {
+	source = strings.TrimSpace(source)
+	if containsString(crosswalkBuiltinSources, source) {
+		return source, nil
+	}
+	for _, prefix := range []string{crosswalkSourceMetadata, crosswalkSourceNodeMetadata, crosswalkSourceField, crosswalkSourceConstant} {
+		if strings.HasPrefix(source, prefix) {
+			if strings.TrimSpace(strings.TrimPrefix(source, prefix)) == "" {
+				return "", fmt.Errorf("crosswalk source %s is missing a name", source)
+			}
+			return source, nil
+		}
+	}
+	return "", fmt.Errorf("unknown crosswalk source %s, expected one of %s or a metadata:, node:, field: or const: prefix",
+		source, strings.Join(crosswalkBuiltinSources, ", "))
+}
+
+// crosswalkMappingsFromInput kontrollerar och normaliserar mappningarna i en crosswalk
+func crosswalkMappingsFromInput(inputs []*model.CrosswalkMappingInput) ([]*model.CrosswalkMapping, error) {
+	if len(inputs) == 0 {
+		return nil, fmt.Errorf("a crosswalk needs at least one mapping")
+	}
+
+	mappings := []*model.CrosswalkMapping{}
+	for _, input := range inputs {
+		if input == nil {
+			continue
+		}
+		source, err := validateCrosswalkSource(input.Source)
+		if err != nil {
+			return nil, err
+		}
+		target, err := normalizeCrosswalkTarget(input.Target)
+		if err != nil {
+			return nil, err
+		}
+		mappings = append(mappings, &model.CrosswalkMapping{Source: source, Target: target})
+	}
+	return mappings, nil
+}
+
+// saveCrosswalkMappings ersätter mappningarna i en crosswalk
+func saveCrosswalkMappings(tx *sql.Tx, crosswalkID string, mappings []*model.CrosswalkMapping) error {
+	if _, err := tx.Exec("DELETE FROM crosswalk_mappings WHERE crosswalk_id = ?", crosswalkID); err != nil {
+		log.Printf("Error deleting mappings of crosswalk %s: %v", crosswalkID, err)
+		return serviceErrorf(errInternal, "failed to save crosswalk mappings: %v", err)
+	}
+	for position, mapping := range mappings {
+		_, err := tx.Exec(
+			"INSERT INTO crosswalk_mappings (crosswalk_id, position, source, target) VALUES (?, ?, ?, ?)",
+			crosswalkID, position, mapping.Source, mapping.Target,
+		)
+		if err != nil {
+			log.Printf("Error saving mapping of crosswalk %s: %v", crosswalkID, err)
+			return serviceErrorf(errInternal, "failed to save crosswalk mappings: %v", err)
+		}
+	}
+	return nil
+}
+
+// getCrosswalk hämtar en crosswalk med dess mappningar
+func getCrosswalk(db sqlQueryer, crosswalkID string) (*model.Crosswalk, error) {
+	var crosswalk model.Crosswalk
+	var description sql.NullString
+	err := db.QueryRow(`
+		SELECT id, name, description, is_default, created_at, updated_at
+		FROM crosswalks WHERE id = ?
+	`, crosswalkID).Scan(&crosswalk.ID, &crosswalk.Name, &description, &crosswalk.IsDefault, &crosswalk.CreatedAt, &crosswalk.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, serviceErrorf(errNotFound, "crosswalk not found")
+	} else if err != nil {
+		log.Printf("Error fetching crosswalk %s: %v", crosswalkID, err)
+		return nil, serviceErrorf(errInternal, "failed to fetch crosswalk: %v", err)
+	}
+	crosswalk.Description = nullStringPtr(description)
+
+	rows, err := db.Query("SELECT source, target FROM crosswalk_mappings WHERE crosswalk_id = ? ORDER BY position ASC", crosswalkID)
+	if err != nil {
+		log.Printf("Error fetching mappings of crosswalk %s: %v", crosswalkID, err)
+		return nil, serviceErrorf(errInternal, "failed to fetch crosswalk mappings: %v", err)
+	}
+	defer rows.Close()
+
+	crosswalk.Mappings = []*model.CrosswalkMapping{}
+	for rows.Next() {
+		var mapping model.CrosswalkMapping
+		if err := rows.Scan(&mapping.Source, &mapping.Target); err != nil {
+			log.Printf("Error scanning crosswalk mapping row: %v", err)
+			return nil, serviceErrorf(errInternal, "failed to scan crosswalk mapping row: %v", err)
+		}
+		crosswalk.Mappings = append(crosswalk.Mappings, &mapping)
+	}
+	return &crosswalk, rows.Err()
+}
+
+// crosswalkMappings hämtar mappningarna i en crosswalk, eller i standardcrosswalken om inget id anges.
+// Finns ingen standardcrosswalk används de inbyggda mappningarna.
+func crosswalkMappings(db sqlQueryer, crosswalkID *string) ([]*model.CrosswalkMapping, error) {
+	if crosswalkID == nil {
+		var defaultID string
+		err := db.QueryRow("SELECT id FROM crosswalks WHERE is_default = 1 ORDER BY id ASC LIMIT 1").Scan(&defaultID)
+		if err == sql.ErrNoRows {
+			return builtinCrosswalkMappings, nil
+		} else if err != nil {
+			log.Printf("Error fetching default crosswalk: %v", err)
+			return nil, serviceErrorf(errInternal, "failed to fetch default crosswalk: %v", err)
+		}
+		crosswalkID = &defaultID
+	}
+
+	crosswalk, err := getCrosswalk(db, *crosswalkID)
+	if err != nil {
+		return nil, err
+	}
+	return crosswalk.Mappings, nil
+}
+
+// crosswalkNode är det en crosswalk behöver veta om noden en fil ligger i
+type crosswalkNode struct {
+	name     string
+	metadata []*model.Metadata
+}
+
+// crosswalker gör om filer till Dublin Core-poster. Noderna cachas eftersom många filer delar nod.
+type crosswalker struct {
+	db       *sql.DB
+	mappings []*model.CrosswalkMapping
+	nodes    map[string]*crosswalkNode
+}
+
+func newCrosswalker(db *sql.DB, mappings []*model.CrosswalkMapping) *crosswalker {
+	return &crosswalker{db: db, mappings: mappings, nodes: make(map[string]*crosswalkNode)}
+}
+
+func (c *crosswalker) node(nodeID string) (*crosswalkNode, error) {
+	if node, ok := c.nodes[nodeID]; ok {
+		return node, nil
+	}
+
+	node := &crosswalkNode{}
+	err := c.db.QueryRow("SELECT name FROM nodes WHERE id = ?", nodeID).Scan(&node.name)
+	if err != nil && err != sql.ErrNoRows {
+		log.Printf("Error fetching node %s for crosswalk: %v", nodeID, err)
+		return nil, serviceErrorf(errInternal, "failed to fetch node: %v", err)
+	}
+	node.metadata, err = loadNodeMetadata(c.db, nodeID)
+	if err != nil {
+		return nil, err
+	}
+	c.nodes[nodeID] = node
+	return node, nil
+}
+
+// sourceValues hämtar värdena för en källa. Metadata kan ha flera värden för samma nyckel.
+func (c *crosswalker) sourceValues(file *treeFile, updatedAt string, source string) ([]string, error) {
+	switch {
+	case strings.HasPrefix(source, crosswalkSourceMetadata):
+		return metadataValues(file.Metadata, strings.TrimPrefix(source, crosswalkSourceMetadata)), nil
+	case strings.HasPrefix(source, crosswalkSourceNodeMetadata):
+		node, err := c.node(file.NodeID)
+		if err != nil {
+			return nil, err
+		}
+		return metadataValues(node.metadata, strings.TrimPrefix(source, crosswalkSourceNodeMetadata)), nil
+	case strings.HasPrefix(source, crosswalkSourceField):
+		return nonEmpty(file.Fields[strings.TrimPrefix(source, crosswalkSourceField)]), nil
+	case strings.HasPrefix(source, crosswalkSourceConstant):
+		return nonEmpty(strings.TrimPrefix(source, crosswalkSourceConstant)), nil
+	}
+
+	switch source {
+	case "id":
+		return []string{file.ID}, nil
+	case "name":
+		return []string{file.Name}, nil
+	case "contentType":
+		return nonEmpty(file.ContentType), nil
+	case "size":
+		return []string{strconv.Itoa(file.Size)}, nil
+	case "createdAt":
+		return nonEmpty(normalizeDatestamp(file.CreatedAt)), nil
+	case "updatedAt":
+		return nonEmpty(normalizeDatestamp(updatedAt)), nil
+	case "checksum":
+		return nonEmpty(file.Checksum), nil
+	case "puid":
+		return nonEmpty(file.PUID), nil
+	case "formatName":
+		if format := file.format(); format != nil {
+			return []string{format.Name}, nil
+		}
+		return nil, nil
+	case "fileType":
+		return []string{string(file.FileType)}, nil
+	case "nodeName":
+		node, err := c.node(file.NodeID)
+		if err != nil {
+			return nil, err
+		}
+		return nonEmpty(node.name), nil
+	}
+	return nil, fmt.Errorf("unknown crosswalk source %s", source)
+}
+
+// record gör om en fil till Dublin Core-element i mappningarnas ordning
+func (c *crosswalker) record(file *treeFile, updatedAt string) ([]dublinCoreElement, error) {
+	var elements []dublinCoreElement
+	for _, mapping := range c.mappings {
+		values, err := c.sourceValues(file, updatedAt, mapping.Source)
+		if err != nil {
+			return nil, err
+		}
+		prefix, name, _ := strings.Cut(mapping.Target, ":")
+		for _, value := range values {
+			elements = append(elements, dublinCoreElement{Prefix: prefix, Name: name, Value: value})
+		}
+	}
+	return elements, nil
+}
+
+// metadataValues hämtar alla icke-tomma värden för en nyckel
+func metadataValues(metadata []*model.Metadata, key string) []string {
+	var values []string
+	for _, meta := range metadata {
+		if meta != nil && meta.Key == key && meta.Value != "" {
+			values = append(values, meta.Value)
+		}
+	}
+	return values
+}
+
+// nonEmpty gör om ett värde till en lista, tom om värdet saknas
+func nonEmpty(value string) []string {
+	if value == "" {
+		return nil
+	}
+	return []string{value}
+}
+
+// fileUpdatedAt hämtar när filens metadata, typ eller placering senast ändrades, tom om den inte ändrats
+func fileUpdatedAt(db sqlQueryer, fileID string) (string, error) {
+	var updatedAt sql.NullString
+	err := db.QueryRow("SELECT updated_at FROM files WHERE id = ?", fileID).Scan(&updatedAt)
+	if err != nil && err != sql.ErrNoRows {
+		log.Printf("Error fetching update time of file %s: %v", fileID, err)
+		return "", serviceErrorf(errInternal, "failed to fetch file: %v", err)
+	}
+	return updatedAt.String, nil
+}
+
+// touchFile noterar att filens metadata, typ eller placering ändrats, så att den skördas på nytt
+func touchFile(db sqlExecer, fileID string) error {
+	if _, err := db.Exec("UPDATE files SET updated_at = datetime('now') WHERE id = ?", fileID); err != nil {
+		log.Printf("Error updating modification time of file %s: %v", fileID, err)
+		return serviceErrorf(errInternal, "failed to update file: %v", err)
+	}
+	return nil
+}
+
+// normalizeDatestamp gör om en tidpunkt från databasen till UTC i formatet YYYY-MM-DDThh:mm:ssZ.
+// Tidpunkter som inte kan tolkas lämnas som de är.
+func normalizeDatestamp(value string) string {
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02 15:04:05", "2006-01-02"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t.UTC().Format("2006-01-02T15:04:05Z")
+		}
+	}
+	return value
+}
+
+// fileRecordURN är postens identifierare i JSON-LD
+func fileRecordURN(fileID string) string {
+	return "urn:e-arkive:file:" + fileID
+}
+
+// ---------- Serialisering ----------
+
+type dublinCoreXMLElement struct {
+	XMLName xml.Name
+	Value   string `xml:",chardata"`
+}
+
+type dublinCoreXMLRecord struct {
+	XMLName      xml.Name               `xml:"metadata"`
+	XmlnsDC      string                 `xml:"xmlns:dc,attr"`
+	XmlnsDCTerms string                 `xml:"xmlns:dcterms,attr"`
+	Elements     []dublinCoreXMLElement `xml:",any"`
+}
+
+// dublinCoreXMLElements gör om elementen till XML-element med prefix
+func dublinCoreXMLElements(elements []dublinCoreElement) []dublinCoreXMLElement {
+	xmlElements := make([]dublinCoreXMLElement, 0, len(elements))
+	for _, element := range elements {
+		xmlElements = append(xmlElements, dublinCoreXMLElement{XMLName: xml.Name{Local: element.qualifiedName()}, Value: element.Value})
+	}
+	return xmlElements
+}
+
+// marshalDublinCoreXML skriver en post som fristående XML med både dc- och dcterms-element
+func marshalDublinCoreXML(elements []dublinCoreElement) (string, error) {
+	record := dublinCoreXMLRecord{
+		XmlnsDC:      dcNamespace,
+		XmlnsDCTerms: dctermsNamespace,
+		Elements:     dublinCoreXMLElements(elements),
+	}
+	data, err := xml.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return "", serviceErrorf(errInternal, "failed to write Dublin Core XML: %v", err)
+	}
+	return xml.Header + string(data), nil
+}
+
+// marshalDublinCoreJSONLD skriver en post som JSON-LD. Element med flera värden blir listor.
+func marshalDublinCoreJSONLD(identifier string, elements []dublinCoreElement) (string, error) {
+	document := map[string]interface{}{
+		"@context": map[string]string{
+			"dc":      dcNamespace,
+			"dcterms": dctermsNamespace,
+		},
+		"@id": identifier,
+	}
+
+	values := make(map[string][]string)
+	for _, element := range elements {
+		values[element.qualifiedName()] = append(values[element.qualifiedName()], element.Value)
+	}
+	for name, elementValues := range values {
+		if len(elementValues) == 1 {
+			document[name] = elementValues[0]
+		} else {
+			document[name] = elementValues
+		}
+	}
+
+	data, err := json.MarshalIndent(document, "", "  ")
+	if err != nil {
+		return "", serviceErrorf(errInternal, "failed to write Dublin Core JSON-LD: %v", err)
+	}
+	return string(data), nil
+}
+
+// =============================================
+// ========== PUBLICERING ====================
+// =============================================
+
+// publicationPolicy avgör om en nods filer skördas via OAI-PMH. Närmaste nod uppåt i trädet med en
+// egen inställning avgör; policyNodeID är den noden, eller tom om ingen nod har en inställning.
+func publicationPolicy(db sqlQueryer, nodeID string) (bool, string, error) {
+	visited := make(map[string]bool)
+	for currentID := nodeID; currentID != "" && !visited[currentID]; {
+		visited[currentID] = true
+
+		var parentID sql.NullString
+		var published sql.NullBool
+		err := db.QueryRow("SELECT parent_id, published FROM nodes WHERE id = ?", currentID).Scan(&parentID, &published)
+		if err == sql.ErrNoRows {
+			return false, "", nil
+		} else if err != nil {
+			log.Printf("Error fetching publication setting of node %s: %v", currentID, err)
+			return false, "", serviceErrorf(errInternal, "failed to fetch publication setting: %v", err)
+		}
+
+		if published.Valid {
+			return published.Bool, currentID, nil
+		}
+		currentID = parentID.String
+	}
+	return false, "", nil
+}
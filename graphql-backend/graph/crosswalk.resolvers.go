@@ -0,0 +1,294 @@
+package graph
+
+// This file will be automatically regenerated based on the schema, any resolver implementations
+// will be copied through when generating and any unknown code will be moved to the end.
+// Code generated by github.com/99designs/gqlgen version v0.17.69
+
+import (
+	"context"
+	"fmt"
+	"graphql-backend/graph/model"
+	"log"
+	"strings"
+	"time"
+)
+
+// DublinCore is the resolver for the dublinCore field.
+func (r *fileResolver) DublinCore(ctx context.Context, obj *model.File, format model.DublinCoreFormat, crosswalkID *string) (string, error) {
+	if r.DB == nil {
+		return "", serviceErrorf(errInternal, "internal server error: database connection is not initialized")
+	}
+
+	mappings, err := crosswalkMappings(r.DB, crosswalkID)
+	if err != nil {
+		return "", err
+	}
+	file, err := loadTreeFile(r.DB, obj.ID)
+	if err != nil {
+		return "", err
+	}
+	updatedAt, err := fileUpdatedAt(r.DB, obj.ID)
+	if err != nil {
+		return "", err
+	}
+	elements, err := newCrosswalker(r.DB, mappings).record(file, updatedAt)
+	if err != nil {
+		return "", err
+	}
+
+	if format == model.DublinCoreFormatJSONLd {
+		return marshalDublinCoreJSONLD(fileRecordURN(file.ID), elements)
+	}
+	return marshalDublinCoreXML(elements)
+}
+
+// CreateCrosswalk is the resolver for the createCrosswalk field.
+func (r *mutationResolver) CreateCrosswalk(ctx context.Context, input model.CrosswalkInput) (*model.Crosswalk, error) {
+	logAction(fmt.Sprintf("Creating crosswalk %s", input.Name))
+
+	if r.DB == nil {
+		log.Printf("Database connection is nil")
+		return nil, serviceErrorf(errInternal, "internal server error: database connection is not initialized")
+	}
+
+	if _, err := requireAdministrator(ctx, r.DB, "manage crosswalks"); err != nil {
+		return nil, err
+	}
+
+	name := strings.TrimSpace(input.Name)
+	if name == "" {
+		return nil, fmt.Errorf("crosswalk name is required")
+	}
+	mappings, err := crosswalkMappingsFromInput(input.Mappings)
+	if err != nil {
+		return nil, err
+	}
+	isDefault := input.IsDefault != nil && *input.IsDefault
+
+	tx, err := r.DB.Begin()
+	if err != nil {
+		log.Printf("Error starting transaction: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to start transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	// Endast en crosswalk i taget kan vara standard
+	if isDefault {
+		if _, err := tx.Exec("UPDATE crosswalks SET is_default = 0"); err != nil {
+			log.Printf("Error clearing default crosswalk: %v", err)
+			return nil, serviceErrorf(errInternal, "failed to create crosswalk: %v", err)
+		}
+	}
+
+	now := time.Now().Format(time.RFC3339)
+	result, err := tx.Exec(`
+		INSERT INTO crosswalks (name, description, is_default, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?)
+	`, name, input.Description, isDefault, now, now)
+	if err != nil {
+		log.Printf("Error creating crosswalk: %v", err)
+		if isUniqueViolation(err) {
+			return nil, fmt.Errorf("a crosswalk named %s already exists", name)
+		}
+		return nil, serviceErrorf(errInternal, "failed to create crosswalk: %v", err)
+	}
+
+	crosswalkID, err := result.LastInsertId()
+	if err != nil {
+		log.Printf("Error retrieving last insert ID: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to retrieve crosswalk ID: %v", err)
+	}
+	if err := saveCrosswalkMappings(tx, fmt.Sprintf("%d", crosswalkID), mappings); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("Error committing transaction: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to commit transaction: %v", err)
+	}
+
+	log.Printf("Crosswalk %s created with ID %d", name, crosswalkID)
+	return getCrosswalk(r.DB, fmt.Sprintf("%d", crosswalkID))
+}
+
+// UpdateCrosswalk is the resolver for the updateCrosswalk field.
+func (r *mutationResolver) UpdateCrosswalk(ctx context.Context, id string, input model.CrosswalkInput) (*model.Crosswalk, error) {
+	logAction(fmt.Sprintf("Updating crosswalk %s", id))
+
+	if r.DB == nil {
+		log.Printf("Database connection is nil")
+		return nil, serviceErrorf(errInternal, "internal server error: database connection is not initialized")
+	}
+
+	if _, err := requireAdministrator(ctx, r.DB, "manage crosswalks"); err != nil {
+		return nil, err
+	}
+
+	crosswalk, err := getCrosswalk(r.DB, id)
+	if err != nil {
+		return nil, err
+	}
+	name := strings.TrimSpace(input.Name)
+	if name == "" {
+		return nil, fmt.Errorf("crosswalk name is required")
+	}
+	mappings, err := crosswalkMappingsFromInput(input.Mappings)
+	if err != nil {
+		return nil, err
+	}
+	isDefault := crosswalk.IsDefault
+	if input.IsDefault != nil {
+		isDefault = *input.IsDefault
+	}
+
+	tx, err := r.DB.Begin()
+	if err != nil {
+		log.Printf("Error starting transaction: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to start transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if isDefault {
+		if _, err := tx.Exec("UPDATE crosswalks SET is_default = 0 WHERE id != ?", id); err != nil {
+			log.Printf("Error clearing default crosswalk: %v", err)
+			return nil, serviceErrorf(errInternal, "failed to update crosswalk: %v", err)
+		}
+	}
+
+	_, err = tx.Exec(`
+		UPDATE crosswalks SET name = ?, description = ?, is_default = ?, updated_at = ?
+		WHERE id = ?
+	`, name, input.Description, isDefault, time.Now().Format(time.RFC3339), id)
+	if err != nil {
+		log.Printf("Error updating crosswalk %s: %v", id, err)
+		if isUniqueViolation(err) {
+			return nil, fmt.Errorf("a crosswalk named %s already exists", name)
+		}
+		return nil, serviceErrorf(errInternal, "failed to update crosswalk: %v", err)
+	}
+	if err := saveCrosswalkMappings(tx, id, mappings); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("Error committing transaction: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to commit transaction: %v", err)
+	}
+
+	return getCrosswalk(r.DB, id)
+}
+
+// DeleteCrosswalk is the resolver for the deleteCrosswalk field.
+func (r *mutationResolver) DeleteCrosswalk(ctx context.Context, id string) (bool, error) {
+	logAction(fmt.Sprintf("Deleting crosswalk %s", id))
+
+	if r.DB == nil {
+		log.Printf("Database connection is nil")
+		return false, serviceErrorf(errInternal, "internal server error: database connection is not initialized")
+	}
+
+	if _, err := requireAdministrator(ctx, r.DB, "manage crosswalks"); err != nil {
+		return false, err
+	}
+
+	tx, err := r.DB.Begin()
+	if err != nil {
+		log.Printf("Error starting transaction: %v", err)
+		return false, serviceErrorf(errInternal, "failed to start transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM crosswalk_mappings WHERE crosswalk_id = ?", id); err != nil {
+		log.Printf("Error deleting mappings of crosswalk %s: %v", id, err)
+		return false, serviceErrorf(errInternal, "failed to delete crosswalk: %v", err)
+	}
+	result, err := tx.Exec("DELETE FROM crosswalks WHERE id = ?", id)
+	if err != nil {
+		log.Printf("Error deleting crosswalk %s: %v", id, err)
+		return false, serviceErrorf(errInternal, "failed to delete crosswalk: %v", err)
+	}
+	if rowsAffected, err := result.RowsAffected(); err == nil && rowsAffected == 0 {
+		return false, serviceErrorf(errNotFound, "crosswalk not found")
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("Error committing transaction: %v", err)
+		return false, serviceErrorf(errInternal, "failed to commit transaction: %v", err)
+	}
+	return true, nil
+}
+
+// SetNodePublished is the resolver for the setNodePublished field.
+func (r *mutationResolver) SetNodePublished(ctx context.Context, nodeID string, published *bool) (*model.Node, error) {
+	logAction(fmt.Sprintf("Setting publication of node %s", nodeID))
+
+	if r.DB == nil {
+		log.Printf("Database connection is nil")
+		return nil, serviceErrorf(errInternal, "internal server error: database connection is not initialized")
+	}
+
+	// Publicerade filer kan skördas utan inloggning, så endast administratörer får publicera
+	if _, err := requireAdministrator(ctx, r.DB, "publish nodes"); err != nil {
+		return nil, err
+	}
+
+	result, err := r.DB.Exec("UPDATE nodes SET published = ?, updated_at = datetime('now') WHERE id = ?", published, nodeID)
+	if err != nil {
+		log.Printf("Error setting publication of node %s: %v", nodeID, err)
+		return nil, serviceErrorf(errInternal, "failed to set publication: %v", err)
+	}
+	if rowsAffected, err := result.RowsAffected(); err == nil && rowsAffected == 0 {
+		return nil, serviceErrorf(errNotFound, "node not found")
+	}
+
+	return getNodeWithPermissions(ctx, r.DB, nodeID)
+}
+
+// Publication is the resolver for the publication field.
+func (r *nodeResolver) Publication(ctx context.Context, obj *model.Node) (*model.PublicationPolicy, error) {
+	if r.DB == nil {
+		return nil, serviceErrorf(errInternal, "internal server error: database connection is not initialized")
+	}
+
+	published, policyNodeID, err := publicationPolicy(r.DB, obj.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &model.PublicationPolicy{Published: published, NodeID: optionalString(policyNodeID)}, nil
+}
+
+// Crosswalks is the resolver for the crosswalks field.
+func (r *queryResolver) Crosswalks(ctx context.Context) ([]*model.Crosswalk, error) {
+	if r.DB == nil {
+		return nil, serviceErrorf(errInternal, "internal server error: database connection is not initialized")
+	}
+
+	rows, err := r.DB.Query("SELECT id FROM crosswalks ORDER BY name ASC")
+	if err != nil {
+		log.Printf("Error fetching crosswalks: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to fetch crosswalks: %v", err)
+	}
+	crosswalkIDs, err := scanIDs(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	crosswalks := []*model.Crosswalk{}
+	for _, crosswalkID := range crosswalkIDs {
+		crosswalk, err := getCrosswalk(r.DB, crosswalkID)
+		if err != nil {
+			return nil, err
+		}
+		crosswalks = append(crosswalks, crosswalk)
+	}
+	return crosswalks, nil
+}
+
+// Crosswalk is the resolver for the crosswalk field.
+func (r *queryResolver) Crosswalk(ctx context.Context, id string) (*model.Crosswalk, error) {
+	if r.DB == nil {
+		return nil, serviceErrorf(errInternal, "internal server error: database connection is not initialized")
+	}
+
+	return getCrosswalk(r.DB, id)
+}
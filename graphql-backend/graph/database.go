@@ -0,0 +1,70 @@
+package graph
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/mattn/go-sqlite3"
+)
+
+// =============================================
+// ========== DATABASDIALEKTER ================
+// =============================================
+
+// Dialect anger vilken databas data lagras i. SQLite passar för en enskild dator, PostgreSQL för
+// driftmiljöer med flera samtidiga skrivare och replikering.
+type Dialect string
+
+const (
+	DialectSQLite   Dialect = "sqlite"
+	DialectPostgres Dialect = "postgres"
+)
+
+// DefaultDatabaseDSN är SQLite-filen som används när ingen annan databas angetts
+const DefaultDatabaseDSN = "./e-Arkive.db"
+
+// DialectForDSN avgör dialekten från en DSN. URL:er med schemat postgres:// eller postgresql://
+// pekar ut en PostgreSQL-databas, allt annat tolkas som sökvägen till en SQLite-fil.
+func DialectForDSN(dsn string) Dialect {
+	lower := strings.ToLower(strings.TrimSpace(dsn))
+	if strings.HasPrefix(lower, "postgres://") || strings.HasPrefix(lower, "postgresql://") {
+		return DialectPostgres
+	}
+	return DialectSQLite
+}
+
+// OpenDatabase öppnar databasen som DSN pekar ut och kontrollerar att den svarar. Frågorna skrivs
+// för SQLite; mot PostgreSQL skrivs de om av drivrutinen i postgres.go.
+func OpenDatabase(dsn string) (*sql.DB, Dialect, error) {
+	dialect := DialectForDSN(dsn)
+
+	driverName := "sqlite3"
+	if dialect == DialectPostgres {
+		driverName = postgresDriverName
+	}
+
+	db, err := sql.Open(driverName, dsn)
+	if err != nil {
+		return nil, dialect, serviceErrorf(errInternal, "failed to open %s database: %v", dialect, err)
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, dialect, serviceErrorf(errInternal, "failed to connect to %s database: %v", dialect, err)
+	}
+	return db, dialect, nil
+}
+
+// isUniqueViolation avgör om ett databasfel beror på att en unik nyckel redan finns, oavsett dialekt
+func isUniqueViolation(err error) bool {
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique || sqliteErr.ExtendedCode == sqlite3.ErrConstraintPrimaryKey
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code == "23505" // unique_violation
+	}
+	return false
+}
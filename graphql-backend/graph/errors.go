@@ -0,0 +1,42 @@
+package graph
+
+import (
+	"errors"
+	"fmt"
+)
+
+// =============================================
+// ========== FELSORTER ==================
+// =============================================
+
+// Felsorter som tjänsterna märker sina fel med, så att REST-API:t, WebDAV och ZIP-exporten kan
+// välja HTTP-status utan att tolka felmeddelandena. Fel utan sort beror på anroparens indata.
+var (
+	errNotAuthenticated = errors.New("not authenticated")
+	errPermissionDenied = errors.New("permission denied")
+	errNotFound         = errors.New("not found")
+	errConflict         = errors.New("conflict")
+	errInternal         = errors.New("internal server error")
+)
+
+// errTokenInvalidated returneras för token som loggats ut
+var errTokenInvalidated = errors.New("token has been invalidated")
+
+// kindError är ett fel med en sort. Meddelandet är detsamma som utan sort.
+type kindError struct {
+	kind error
+	err  error
+}
+
+func (e *kindError) Error() string {
+	return e.err.Error()
+}
+
+func (e *kindError) Unwrap() []error {
+	return []error{e.kind, e.err}
+}
+
+// serviceErrorf skapar ett fel av sorten kind, formaterat som med fmt.Errorf
+func serviceErrorf(kind error, format string, args ...interface{}) error {
+	return &kindError{kind: kind, err: fmt.Errorf(format, args...)}
+}
@@ -0,0 +1,772 @@
+package graph
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"fmt"
+	"graphql-backend/graph/model"
+	"log"
+	"strings"
+	"time"
+)
+
+// =============================================
+// ========== FILTJÄNST ======================
+// =============================================
+
+// fileService implementerar FileService mot databasen
+type fileService struct {
+	db *sql.DB
+}
+
+// Get hämtar en fils uppgifter och metadata, utan innehåll. Kräver läsbehörighet på filens nod.
+func (s *fileService) Get(ctx context.Context, id string) (*model.File, error) {
+	logAction(fmt.Sprintf("Fetching file with ID: %s", id))
+
+	if s.db == nil {
+		err := serviceErrorf(errInternal, "database connection is not initialized")
+		log.Printf("GetFile failed: %v", err)
+		return nil, err
+	}
+
+	if _, err := getUserIDFromContext(ctx); err != nil {
+		return nil, err
+	}
+
+	// Hämtar filinformation från databasen utan binärdata
+	var file model.File
+	var createdAt string
+	var nodeID sql.NullString
+	err := s.db.QueryRow(`
+		SELECT id, name, size, content_type, created_at, node_id
+		FROM files WHERE id = ?`, id).Scan(
+		&file.ID, &file.Name, &file.Size, &file.ContentType, &createdAt, &nodeID)
+
+	if err == sql.ErrNoRows {
+		errMsg := fmt.Sprintf("file not found with ID: %s", id)
+		log.Printf("GetFile failed: %s", errMsg)
+		return nil, serviceErrorf(errNotFound, "%s", errMsg)
+	} else if err != nil {
+		log.Printf("GetFile failed: Error fetching file with ID %s: %v", id, err)
+		return nil, serviceErrorf(errInternal, "failed to fetch file: %v", err)
+	}
+
+	file.CreatedAt = createdAt
+	file.NodeID = nullStringPtr(nodeID)
+	if nodeID.Valid {
+		if err := requireNodePermission(ctx, s.db, nodeID.String, PERM_VIEW, "cannot view this node"); err != nil {
+			return nil, err
+		}
+	}
+
+	// Hämtar metadata för filen
+	metadata, err := loadFileMetadata(s.db, file.ID)
+	if err != nil {
+		return nil, err
+	}
+	file.Metadata = metadata
+
+	// Läsningen loggas i noder med åtkomstloggning; går det inte att logga lämnas filen inte ut
+	if err := RecordFileAccess(ctx, s.db, file.ID, model.FileAccessTypeView); err != nil {
+		return nil, err
+	}
+
+	log.Printf("Successfully fetched file %s (ID: %s)", file.Name, file.ID)
+	return &file, nil
+}
+
+// List hämtar alla filer som användaren får se, utan innehåll
+func (s *fileService) List(ctx context.Context) ([]*model.File, error) {
+	logAction("Fetching all files from the database")
+
+	if _, err := getUserIDFromContext(ctx); err != nil {
+		return nil, err
+	}
+
+	// Hämtar alla filer från databasen
+	rows, err := s.db.Query("SELECT id, name, size, content_type, created_at, node_id FROM files")
+	if err != nil {
+		log.Printf("Error fetching files from database: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to fetch files: %v", err)
+	}
+	defer rows.Close()
+
+	var files []*model.File
+	for rows.Next() {
+		var file model.File
+		var createdAt string
+		var nodeID sql.NullString
+		if err := rows.Scan(&file.ID, &file.Name, &file.Size, &file.ContentType, &createdAt, &nodeID); err != nil {
+			log.Printf("Error scanning file row: %v", err)
+			return nil, serviceErrorf(errInternal, "failed to scan file row: %v", err)
+		}
+		file.CreatedAt = createdAt
+		file.NodeID = nullStringPtr(nodeID)
+		files = append(files, &file)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Printf("Error iterating over file rows: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to iterate over file rows: %v", err)
+	}
+	rows.Close()
+
+	// Filer i noder som användaren inte får se tas bort ur listan. Behörigheten kontrolleras
+	// en gång per nod.
+	visibleNodes := map[string]bool{}
+	visible := files[:0]
+	for _, file := range files {
+		if file.NodeID != nil {
+			allowed, checked := visibleNodes[*file.NodeID]
+			if !checked {
+				if allowed, err = checkPermission(ctx, s.db, *file.NodeID, PERM_VIEW); err != nil {
+					return nil, err
+				}
+				visibleNodes[*file.NodeID] = allowed
+			}
+			if !allowed {
+				continue
+			}
+		}
+
+		// Hämtar metadata för varje fil
+		metadata, err := loadFileMetadata(s.db, file.ID)
+		if err != nil {
+			return nil, err
+		}
+		file.Metadata = metadata
+
+		// Listningen loggas som läsning i noder med åtkomstloggning
+		if err := RecordFileAccess(ctx, s.db, file.ID, model.FileAccessTypeView); err != nil {
+			return nil, err
+		}
+		visible = append(visible, file)
+	}
+
+	log.Printf("Successfully fetched %d files", len(visible))
+	return visible, nil
+}
+
+// ListByNode hämtar filerna i en nod, utan innehåll. Kräver läsbehörighet på noden.
+func (s *fileService) ListByNode(ctx context.Context, nodeID string) ([]*model.File, error) {
+	logAction(fmt.Sprintf("Fetching files for node ID: %s", nodeID))
+
+	if s.db == nil {
+		log.Printf("Database connection is nil")
+		return nil, serviceErrorf(errInternal, "internal server error: database connection is not initialized")
+	}
+
+	// First check if user has permission to view this node
+	hasPermission, err := checkPermission(ctx, s.db, nodeID, PERM_VIEW)
+	if err != nil {
+		return nil, err
+	}
+
+	if !hasPermission {
+		return nil, serviceErrorf(errPermissionDenied, "permission denied: cannot view files in this node")
+	}
+
+	// Query files for this specific node
+	rows, err := s.db.Query(`
+		SELECT id, name, size, content_type, created_at, file_data
+		FROM files
+		WHERE node_id = ?
+		ORDER BY name ASC
+	`, nodeID)
+	if err != nil {
+		log.Printf("Error fetching files for node ID %s: %v", nodeID, err)
+		return nil, serviceErrorf(errInternal, "failed to fetch files: %v", err)
+	}
+	defer rows.Close()
+
+	var files []*model.File
+	for rows.Next() {
+		var file model.File
+		var createdAt string
+		var fileData []byte
+		if err := rows.Scan(&file.ID, &file.Name, &file.Size, &file.ContentType, &createdAt, &fileData); err != nil {
+			log.Printf("Error scanning file row: %v", err)
+			return nil, serviceErrorf(errInternal, "failed to scan file row: %v", err)
+		}
+		file.CreatedAt = createdAt
+
+		// Don't send file data in listing, only metadata
+		// We'll only include the file data when explicitly downloading the file
+
+		// Set the nodeId on the file
+		file.NodeID = &nodeID
+
+		// Fetch metadata for each file
+		metadata, err := loadFileMetadata(s.db, file.ID)
+		if err != nil {
+			return nil, err
+		}
+		file.Metadata = metadata
+		files = append(files, &file)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Printf("Error iterating over file rows: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to iterate over file rows: %v", err)
+	}
+
+	rows.Close()
+
+	// Listningen loggas som läsning i noder med åtkomstloggning, när raderna har lästs klart
+	for _, file := range files {
+		if err := RecordFileAccess(ctx, s.db, file.ID, model.FileAccessTypeView); err != nil {
+			return nil, err
+		}
+	}
+
+	log.Printf("Successfully fetched %d files for node ID %s", len(files), nodeID)
+	return files, nil
+}
+
+// Download hämtar en fil med base64-kodat innehåll för nedladdning. Kräver läsbehörighet på
+// filens nod.
+func (s *fileService) Download(ctx context.Context, id string) (*model.File, error) {
+	logAction(fmt.Sprintf("Attempting to download file with ID: %s", id))
+
+	if s.db == nil {
+		err := serviceErrorf(errInternal, "database connection is not initialized")
+		log.Printf("Download failed: %v", err)
+		return nil, err
+	}
+
+	if _, err := getUserIDFromContext(ctx); err != nil {
+		return nil, err
+	}
+
+	// Hämtar filinformation från databasen
+	var file model.File
+	var createdAt string
+	var fileData []byte
+	var nodeID sql.NullString
+	err := s.db.QueryRow(`
+		SELECT id, name, size, content_type, created_at, file_data, node_id
+		FROM files WHERE id = ?`, id).Scan(
+		&file.ID, &file.Name, &file.Size, &file.ContentType, &createdAt, &fileData, &nodeID)
+
+	if err == sql.ErrNoRows {
+		errMsg := fmt.Sprintf("file not found with ID: %s", id)
+		log.Printf("Download failed: %s", errMsg)
+		return nil, serviceErrorf(errNotFound, "%s", errMsg)
+	} else if err != nil {
+		log.Printf("Download failed: Error fetching file with ID %s: %v", id, err)
+		return nil, serviceErrorf(errInternal, "failed to fetch file: %v", err)
+	}
+
+	file.CreatedAt = createdAt
+	file.NodeID = nullStringPtr(nodeID)
+	if nodeID.Valid {
+		if err := requireNodePermission(ctx, s.db, nodeID.String, PERM_VIEW, "cannot view this node"); err != nil {
+			return nil, err
+		}
+	}
+	if fileData != nil {
+		encodedFileData := base64.StdEncoding.EncodeToString(fileData)
+		file.FileData = &encodedFileData
+	}
+
+	// Hämtar metadata för filen
+	metadata, err := loadFileMetadata(s.db, file.ID)
+	if err != nil {
+		return nil, err
+	}
+	file.Metadata = metadata
+
+	// Nedladdningen loggas i noder med åtkomstloggning; går det inte att logga lämnas filen inte ut
+	if err := RecordFileAccess(ctx, s.db, file.ID, model.FileAccessTypeDownload); err != nil {
+		return nil, err
+	}
+
+	log.Printf("Successfully prepared file %s (ID: %s) for download", file.Name, file.ID)
+	return &file, nil
+}
+
+// Save sparar en ny fil med metadata, typ och fält i en nod. Kräver ändringsbehörighet på noden.
+func (s *fileService) Save(ctx context.Context, input model.FileInput) (*model.File, error) {
+	logAction("Received request to save file")
+	log.Printf("Saving file: %s", input.Name)
+
+	// Verifierar att databasanslutningen är aktiv
+	if s.db == nil {
+		log.Printf("Database connection is nil")
+		return nil, serviceErrorf(errInternal, "internal server error: database connection is not initialized")
+	}
+
+	// Dekodar base64-data till binär form
+	fileData, err := base64.StdEncoding.DecodeString(input.FileData)
+	if err != nil {
+		log.Printf("Error decoding file data: %v", err)
+		return nil, fmt.Errorf("invalid file data: %v", err)
+	}
+
+	// Default nodeId to 1 (root) if not specified
+	nodeID := "1"
+	if input.NodeID != nil && *input.NodeID != "" {
+		// Verify that the node exists
+		var exists bool
+		err := s.db.QueryRow("SELECT EXISTS(SELECT 1 FROM nodes WHERE id = ?)", *input.NodeID).Scan(&exists)
+		if err != nil {
+			log.Printf("Error checking if node exists: %v", err)
+			return nil, serviceErrorf(errInternal, "failed to validate node: %v", err)
+		}
+		if !exists {
+			log.Printf("Node with ID %s does not exist", *input.NodeID)
+			return nil, serviceErrorf(errNotFound, "node not found")
+		}
+		nodeID = *input.NodeID
+	}
+	if err := requireNodePermission(ctx, s.db, nodeID, PERM_MODIFY, "cannot modify this node"); err != nil {
+		return nil, err
+	}
+
+	// Kontrollerar Noark 5-typ, obligatoriska fält och placering
+	fileType := model.FileTypeDokument
+	if input.FileType != nil {
+		fileType = *input.FileType
+	}
+	typedValues, err := validateTypedFields(string(fileType), fileTypeFields[fileType], input.Fields)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkFilePlacement(s.db, fileType, nodeID); err != nil {
+		return nil, err
+	}
+
+	// Identifierar formatet från innehållet i stället för att lita på den angivna innehållstypen
+	format := identifyFormat(input.Name, input.ContentType, fileData)
+	if err := checkFormatAccepted(s.db, nodeID, format); err != nil {
+		return nil, err
+	}
+	if format.Mismatch {
+		log.Printf("Declared content type %s of file %s does not match identified format %s", input.ContentType, input.Name, format.PUID())
+	}
+
+	// Nycklar som inte anges får nodens ärvbara standardvärden. Metadatan valideras sedan mot
+	// sina typer och schemat som gäller för noden.
+	defaults, err := inheritedMetadataDefaults(s.db, nodeID)
+	if err != nil {
+		return nil, err
+	}
+	metadataEntries, err := checkMetadataValid(s.db, nodeID, withInheritedMetadata(metadataEntriesFromInput(input.Metadata), defaults))
+	if err != nil {
+		return nil, err
+	}
+
+	// Sparar filinformation och binär data i databasen
+	checksum := checksumSHA256(fileData)
+	result, err := s.db.Exec(
+		"INSERT INTO files (name, size, content_type, created_at, file_data, node_id, file_type, checksum, puid, format_mime_type, format_mismatch) VALUES (?, ?, ?, datetime('now'), ?, ?, ?, ?, ?, ?, ?)",
+		input.Name, input.Size, input.ContentType, fileData, nodeID, fileType, checksum,
+		optionalString(format.PUID()), optionalString(format.MimeType()), format.Mismatch,
+	)
+	if err != nil {
+		log.Printf("Error saving file to database: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to save file: %v", err)
+	}
+
+	fileID, err := result.LastInsertId()
+	if err != nil {
+		log.Printf("Error retrieving last insert ID: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to retrieve file ID: %v", err)
+	}
+
+	log.Printf("File saved to database with ID: %d", fileID)
+
+	if err := saveTypedFields(s.db, ENTITY_KIND_FILE, fmt.Sprintf("%d", fileID), typedValues); err != nil {
+		return nil, err
+	}
+
+	// Registrerar mottagandet som bevarandehändelse med kontrollsumman som referens
+	if err := recordPreservationEvent(s.db, fmt.Sprintf("%d", fileID), PRESERVATION_EVENT_INGEST, PRESERVATION_OUTCOME_SUCCESS,
+		"File uploaded through saveFile", eventAgent(ctx), checksum); err != nil {
+		return nil, err
+	}
+	if err := recordPreservationEvent(s.db, fmt.Sprintf("%d", fileID), PRESERVATION_EVENT_FORMAT_IDENTIFICATION, format.eventOutcome(),
+		format.eventDetail(input.ContentType), eventAgent(ctx), checksum); err != nil {
+		return nil, err
+	}
+
+	// Sparar metadata för filen
+	for _, entry := range metadataEntries {
+		if err := insertMetadata(s.db, fileID, entry); err != nil {
+			return nil, err
+		}
+	}
+
+	log.Printf("File and metadata saved successfully with ID: %d", fileID)
+
+	// Konverterar metadata till rätt format för responsen
+	metadata := metadataModels(metadataEntries)
+
+	// Convert nodeID to string pointer
+	nodeIDStr := nodeID
+
+	return &model.File{
+		ID:          fmt.Sprintf("%d", fileID),
+		Name:        input.Name,
+		Size:        input.Size,
+		ContentType: input.ContentType,
+		CreatedAt:   time.Now().Format(time.RFC3339),
+		FileData:    &input.FileData, // Skickar tillbaka base64-kodad data
+		Metadata:    metadata,
+		NodeID:      &nodeIDStr,
+		FileType:    fileType,
+	}, nil
+}
+
+// Rename byter namn på en fil. Kräver ändringsbehörighet på filens nod.
+func (s *fileService) Rename(ctx context.Context, id string, name string) (*model.File, error) {
+	logAction(fmt.Sprintf("Renaming file %s to %s", id, name))
+
+	if s.db == nil {
+		log.Printf("Database connection is nil")
+		return nil, serviceErrorf(errInternal, "internal server error: database connection is not initialized")
+	}
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, fmt.Errorf("file name cannot be empty")
+	}
+
+	// Namnbytet ändrar nodens innehåll och kräver därför ändringsbehörighet på noden
+	if _, err := requireFilePermission(ctx, s.db, id, PERM_MODIFY, "cannot modify this node"); err != nil {
+		return nil, err
+	}
+
+	// Filer som omfattas av ett tilbakehold får inte ändras
+	if err := checkFileNotHeld(s.db, id, "rename file"); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.db.Exec("UPDATE files SET name = ?, updated_at = datetime('now') WHERE id = ?", name, id); err != nil {
+		log.Printf("Error renaming file %s: %v", id, err)
+		return nil, serviceErrorf(errInternal, "failed to rename file: %v", err)
+	}
+
+	log.Printf("Successfully renamed file ID %s to %s", id, name)
+	return loadFile(s.db, id)
+}
+
+// Move flyttar en fil till en annan nod
+func (s *fileService) Move(ctx context.Context, fileID string, nodeID string) (*model.File, error) {
+	logAction(fmt.Sprintf("Moving file %s to node %s", fileID, nodeID))
+
+	// Verify the file exists and that the user may modify the node it is moved out of
+	if _, err := requireFilePermission(ctx, s.db, fileID, PERM_MODIFY, "cannot modify this node"); err != nil {
+		return nil, err
+	}
+
+	// Files under legal hold cannot be moved out of the held node
+	if err := checkFileNotHeld(s.db, fileID, "move file"); err != nil {
+		return nil, err
+	}
+
+	// Verify the node exists
+	var nodeExists bool
+	err := s.db.QueryRow("SELECT EXISTS(SELECT 1 FROM nodes WHERE id = ?)", nodeID).Scan(&nodeExists)
+	if err != nil {
+		log.Printf("Error checking if node exists: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to verify node: %v", err)
+	}
+	if !nodeExists {
+		log.Printf("Node with ID %s does not exist", nodeID)
+		return nil, serviceErrorf(errNotFound, "node not found")
+	}
+
+	// The file is added to the target node, which also requires modify permission
+	hasPermission, err := checkPermission(ctx, s.db, nodeID, PERM_MODIFY)
+	if err != nil {
+		return nil, err
+	}
+	if !hasPermission {
+		return nil, serviceErrorf(errPermissionDenied, "permission denied: cannot modify the target node")
+	}
+
+	// Verify that the file type is allowed in the target node
+	fileType, err := getFileType(s.db, fileID)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkFilePlacement(s.db, fileType, nodeID); err != nil {
+		return nil, err
+	}
+
+	// Verify that the file format is accepted in the target node
+	format, err := getFileIdentification(s.db, fileID)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkFormatAccepted(s.db, nodeID, format); err != nil {
+		return nil, err
+	}
+
+	// Update the file's node_id
+	_, err = s.db.Exec("UPDATE files SET node_id = ?, updated_at = datetime('now') WHERE id = ?", nodeID, fileID)
+	if err != nil {
+		log.Printf("Error updating file node_id: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to update file: %v", err)
+	}
+
+	// Get the updated file with all necessary information
+	var file model.File
+	var createdAt string
+
+	err = s.db.QueryRow(`
+		SELECT id, name, size, content_type, created_at
+		FROM files WHERE id = ?`, fileID).Scan(
+		&file.ID, &file.Name, &file.Size, &file.ContentType, &createdAt)
+
+	if err != nil {
+		log.Printf("Error fetching updated file data: %v", err)
+		return nil, fmt.Errorf("file updated but failed to retrieve updated data: %v", err)
+	}
+
+	file.CreatedAt = createdAt
+	file.NodeID = &nodeID
+
+	// Fetch metadata for the file
+	metadata, err := loadFileMetadata(s.db, fileID)
+	if err != nil {
+		return nil, err
+	}
+	file.Metadata = metadata
+
+	// Fetch the node data so the file has a reference to its node
+	node, err := getNodeById(ctx, s.db, nodeID)
+	if err != nil {
+		log.Printf("Warning: Failed to fetch node data for the file: %v", err)
+		// Continue without the node data as it's not critical
+	} else {
+		file.Node = node
+	}
+
+	log.Printf("Successfully moved file ID %s to node ID %s", fileID, nodeID)
+	return &file, nil
+}
+
+// Delete tar bort en fil som inte omfattas av ett tilbakehold
+func (s *fileService) Delete(ctx context.Context, id string) (bool, error) {
+	logAction(fmt.Sprintf("Attempting to delete file with ID: %s", id))
+
+	if s.db == nil {
+		log.Printf("Database connection is nil")
+		return false, serviceErrorf(errInternal, "internal server error: database connection is not initialized")
+	}
+
+	// Att ta bort en fil ändrar nodens innehåll och kräver ändringsbehörighet på noden
+	if _, err := requireFilePermission(ctx, s.db, id, PERM_MODIFY, "cannot modify this node"); err != nil {
+		return false, err
+	}
+
+	// Filer som omfattas av ett tilbakehold får inte tas bort
+	if err := checkFileNotHeld(s.db, id, "delete file"); err != nil {
+		return false, err
+	}
+
+	// Ta bort filen och dess metadata från databasen
+	result, err := s.db.Exec("DELETE FROM files WHERE id = ?", id)
+	if err != nil {
+		log.Printf("Error deleting file with ID %s: %v", id, err)
+		return false, serviceErrorf(errInternal, "failed to delete file: %v", err)
+	}
+
+	affectedRows, err := result.RowsAffected()
+	if err != nil {
+		log.Printf("Error fetching affected rows for file ID %s: %v", id, err)
+		return false, serviceErrorf(errInternal, "failed to verify deletion: %v", err)
+	}
+
+	if affectedRows == 0 {
+		log.Printf("No file found with ID: %s", id)
+		return false, serviceErrorf(errNotFound, "file not found")
+	}
+
+	// Ta bort filens Noark 5-fält
+	if err := saveTypedFields(s.db, ENTITY_KIND_FILE, id, nil); err != nil {
+		return false, err
+	}
+
+	log.Printf("Successfully deleted file with ID: %s", id)
+	return true, nil
+}
+
+// UpdateMetadata lägger till eller ändrar metadata på en fil
+func (s *fileService) UpdateMetadata(ctx context.Context, fileID string, metadataInput []*model.MetadataInput) (*model.File, error) {
+	logAction(fmt.Sprintf("Attempting to update metadata for file with ID: %s", fileID))
+
+	if s.db == nil {
+		log.Printf("Database connection is nil")
+		return nil, serviceErrorf(errInternal, "internal server error: database connection is not initialized")
+	}
+
+	// Kontrollera att filen finns och att användaren får ändra noden som filen ligger i
+	nodeID, err := requireFilePermission(ctx, s.db, fileID, PERM_MODIFY, "cannot modify this node")
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkFileNotHeld(s.db, fileID, "update metadata"); err != nil {
+		return nil, err
+	}
+
+	// Den nya metadatan ersätter den gamla och måste följa schemat som gäller för filens nod
+	metadataEntries, err := checkMetadataValid(s.db, nodeID, metadataEntriesFromInput(metadataInput))
+	if err != nil {
+		return nil, err
+	}
+
+	// Starta en transaktion för att säkerställa att alla operationer lyckas eller misslyckas tillsammans
+	tx, err := s.db.Begin()
+	if err != nil {
+		log.Printf("Error starting transaction: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to start transaction: %v", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// Ta bort befintlig metadata för filen
+	_, err = tx.Exec("DELETE FROM metadata WHERE file_id = ?", fileID)
+	if err != nil {
+		log.Printf("Error deleting existing metadata: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to delete existing metadata: %v", err)
+	}
+
+	// Lägg till ny metadata
+	for _, entry := range metadataEntries {
+		if err = insertMetadata(tx, fileID, entry); err != nil {
+			return nil, err
+		}
+	}
+	if err = touchFile(tx, fileID); err != nil {
+		return nil, err
+	}
+
+	// Commit transaktionen
+	if err = tx.Commit(); err != nil {
+		log.Printf("Error committing transaction: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to commit transaction: %v", err)
+	}
+
+	log.Printf("Successfully updated metadata for file with ID: %s", fileID)
+
+	// Hämta den uppdaterade filen för att returnera
+	return s.Get(ctx, fileID)
+}
+
+// DeleteMetadata tar bort metadata med de angivna nycklarna från en fil
+func (s *fileService) DeleteMetadata(ctx context.Context, fileID string, keys []string) (*model.File, error) {
+	logAction(fmt.Sprintf("Attempting to delete metadata for file with ID: %s", fileID))
+
+	if s.db == nil {
+		log.Printf("Database connection is nil")
+		return nil, serviceErrorf(errInternal, "internal server error: database connection is not initialized")
+	}
+
+	// Kontrollera att filen finns och att användaren får ändra noden som filen ligger i
+	nodeID, err := requireFilePermission(ctx, s.db, fileID, PERM_MODIFY, "cannot modify this node")
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkFileNotHeld(s.db, fileID, "delete metadata"); err != nil {
+		return nil, err
+	}
+
+	// Metadatan som blir kvar måste följa schemat, t.ex. får obligatoriska fält inte tas bort
+	current, err := loadMetadataEntries(s.db, fileID)
+	if err != nil {
+		return nil, err
+	}
+	var remaining []metadataEntry
+	for _, entry := range current {
+		if !containsString(keys, entry.Key) {
+			remaining = append(remaining, entry)
+		}
+	}
+	if _, err := checkMetadataValid(s.db, nodeID, remaining); err != nil {
+		return nil, err
+	}
+
+	// Starta en transaktion
+	tx, err := s.db.Begin()
+	if err != nil {
+		log.Printf("Error starting transaction: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to start transaction: %v", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// Ta bort metadata baserat på nycklar
+	for _, key := range keys {
+		_, err = tx.Exec("DELETE FROM metadata WHERE file_id = ? AND key = ?", fileID, key)
+		if err != nil {
+			log.Printf("Error deleting metadata with key %s: %v", key, err)
+			return nil, serviceErrorf(errInternal, "failed to delete metadata: %v", err)
+		}
+	}
+	if err = touchFile(tx, fileID); err != nil {
+		return nil, err
+	}
+
+	// Commit transaktionen
+	if err = tx.Commit(); err != nil {
+		log.Printf("Error committing transaction: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to commit transaction: %v", err)
+	}
+
+	log.Printf("Successfully deleted metadata for file with ID: %s", fileID)
+
+	// Hämta den uppdaterade filen för att returnera
+	return s.Get(ctx, fileID)
+}
+
+// requireFilePermission hämtar noden som filen ligger i och kräver behörigheten på den. Filer
+// utan nod har ingen behörighet att kontrollera och kräver bara en inloggad användare; noden är
+// då tom.
+func requireFilePermission(ctx context.Context, db *sql.DB, fileID string, permission int, denied string) (string, error) {
+	if _, err := getUserIDFromContext(ctx); err != nil {
+		return "", err
+	}
+
+	var nodeID sql.NullString
+	err := db.QueryRow("SELECT node_id FROM files WHERE id = ?", fileID).Scan(&nodeID)
+	if err == sql.ErrNoRows {
+		log.Printf("File with ID %s does not exist", fileID)
+		return "", serviceErrorf(errNotFound, "file not found")
+	} else if err != nil {
+		log.Printf("Error checking if file exists: %v", err)
+		return "", serviceErrorf(errInternal, "failed to verify file: %v", err)
+	}
+	if !nodeID.Valid {
+		return "", nil
+	}
+
+	if err := requireNodePermission(ctx, db, nodeID.String, permission, denied); err != nil {
+		return "", err
+	}
+	return nodeID.String, nil
+}
+
+// requireNodePermission kräver en behörighet på en nod. Filtjänsten och WebDAV kontrollerar
+// behörigheter på samma sätt.
+func requireNodePermission(ctx context.Context, db *sql.DB, nodeID string, permission int, denied string) error {
+	hasPermission, err := checkPermission(ctx, db, nodeID, permission)
+	if err != nil {
+		return err
+	}
+	if !hasPermission {
+		return serviceErrorf(errPermissionDenied, "permission denied: %s", denied)
+	}
+	return nil
+}
@@ -0,0 +1,268 @@
+package graph
+
+import (
+	"context"
+	"encoding/base64"
+	"graphql-backend/graph/model"
+	"testing"
+)
+
+func TestFileServiceSaveAndDownload(t *testing.T) {
+	db := openTestDB(t)
+	services := NewServices(db)
+	ctx := testAdminContext(t)
+	node := createTestNode(t, db, "Arkiv", nil)
+
+	saved := saveTestFile(t, db, "brev.txt", node.ID, "hello")
+
+	got, err := services.Files.Get(ctx, saved.ID)
+	if err != nil {
+		t.Fatalf("get file: %v", err)
+	}
+	if got.Name != "brev.txt" || got.Size != 5 || got.FileData != nil {
+		t.Errorf("got %s (%d bytes, data %v), want brev.txt without data", got.Name, got.Size, got.FileData)
+	}
+	if value, ok := fileMetadataValue(got, "author"); !ok || value != "Anna" {
+		t.Errorf("author = %q, %v, want Anna", value, ok)
+	}
+
+	downloaded, err := services.Files.Download(ctx, saved.ID)
+	if err != nil {
+		t.Fatalf("download file: %v", err)
+	}
+	if downloaded.FileData == nil {
+		t.Fatal("download has no data")
+	}
+	data, _ := base64.StdEncoding.DecodeString(*downloaded.FileData)
+	if string(data) != "hello" {
+		t.Errorf("content = %q, want hello", data)
+	}
+
+	listed, err := services.Files.ListByNode(ctx, node.ID)
+	if err != nil {
+		t.Fatalf("list files: %v", err)
+	}
+	if len(listed) != 1 || listed[0].ID != saved.ID {
+		t.Errorf("files in node = %v, want only %s", listed, saved.ID)
+	}
+
+	if _, err := services.Files.Get(ctx, "99"); err == nil || err.Error() != "file not found with ID: 99" {
+		t.Errorf("get missing file: err = %v", err)
+	}
+}
+
+func TestFileServiceRenameAndMove(t *testing.T) {
+	db := openTestDB(t)
+	services := NewServices(db)
+	ctx := testAdminContext(t)
+	first := createTestNode(t, db, "Första", nil)
+	second := createTestNode(t, db, "Andra", nil)
+	file := saveTestFile(t, db, "utkast.txt", first.ID, "hello")
+
+	renamed, err := services.Files.Rename(ctx, file.ID, "  slutlig.txt ")
+	if err != nil {
+		t.Fatalf("rename file: %v", err)
+	}
+	if renamed.Name != "slutlig.txt" {
+		t.Errorf("name = %q, want slutlig.txt", renamed.Name)
+	}
+	if _, err := services.Files.Rename(ctx, file.ID, " "); err == nil || err.Error() != "file name cannot be empty" {
+		t.Errorf("rename to empty name: err = %v", err)
+	}
+
+	if _, err := services.Files.Move(ctx, file.ID, second.ID); err != nil {
+		t.Fatalf("move file: %v", err)
+	}
+	remaining, err := services.Files.ListByNode(ctx, first.ID)
+	if err != nil {
+		t.Fatalf("list files: %v", err)
+	}
+	moved, err := services.Files.ListByNode(ctx, second.ID)
+	if err != nil {
+		t.Fatalf("list files: %v", err)
+	}
+	if len(remaining) != 0 || len(moved) != 1 {
+		t.Errorf("after move: %d files in source, %d in target", len(remaining), len(moved))
+	}
+
+	// Namnbyte kräver ändringsbehörighet på filens nod
+	bobID := insertTestUser(t, db, "bob")
+	if _, err := services.Files.Rename(testUserContext(t, bobID, "bob"), file.ID, "bob.txt"); err == nil || err.Error() != "permission denied: cannot modify this node" {
+		t.Errorf("rename without permission: err = %v", err)
+	}
+}
+
+func TestFileServiceMetadataAndDelete(t *testing.T) {
+	db := openTestDB(t)
+	services := NewServices(db)
+	ctx := testAdminContext(t)
+	node := createTestNode(t, db, "Arkiv", nil)
+	file := saveTestFile(t, db, "brev.txt", node.ID, "hello")
+
+	updated, err := services.Files.UpdateMetadata(ctx, file.ID, []*model.MetadataInput{{Key: "year", Value: "2024"}})
+	if err != nil {
+		t.Fatalf("update metadata: %v", err)
+	}
+	if value, ok := fileMetadataValue(updated, "year"); !ok || value != "2024" {
+		t.Errorf("year = %q, %v, want 2024", value, ok)
+	}
+
+	trimmed, err := services.Files.DeleteMetadata(ctx, file.ID, []string{"author"})
+	if err != nil {
+		t.Fatalf("delete metadata: %v", err)
+	}
+	if _, ok := fileMetadataValue(trimmed, "author"); ok {
+		t.Error("author is still set after it was deleted")
+	}
+
+	if ok, err := services.Files.Delete(ctx, file.ID); err != nil || !ok {
+		t.Fatalf("delete file: %v, %v", ok, err)
+	}
+	if _, err := services.Files.Delete(ctx, file.ID); err == nil || err.Error() != "file not found" {
+		t.Errorf("delete missing file: err = %v, want file not found", err)
+	}
+}
+
+func TestFileServiceRequiresModifyPermission(t *testing.T) {
+	db := openTestDB(t)
+	services := NewServices(db)
+	admin := testAdminContext(t)
+	bobID := insertTestUser(t, db, "bob")
+	bob := testUserContext(t, bobID, "bob")
+
+	// Bob får ändra i Egen och Mottagning men bara läsa i Läsbar
+	nodes := make(map[string]string)
+	for name, permissions := range map[string]int{"Egen": PERM_VIEW | PERM_MODIFY, "Mottagning": PERM_VIEW | PERM_MODIFY, "Läsbar": PERM_VIEW} {
+		node := createTestNode(t, db, name, nil)
+		if _, err := services.Nodes.SetOwnership(admin, node.ID, &bobID, nil); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := services.Nodes.SetPermissions(admin, node.ID, permissions); err != nil {
+			t.Fatal(err)
+		}
+		nodes[name] = node.ID
+	}
+	own := saveTestFile(t, db, "egen.txt", nodes["Egen"], "egen")
+	readOnly := saveTestFile(t, db, "läsbar.txt", nodes["Läsbar"], "läsbar")
+
+	denied := map[string]func() error{
+		"update metadata": func() error {
+			_, err := services.Files.UpdateMetadata(bob, readOnly.ID, []*model.MetadataInput{{Key: "year", Value: "2024"}})
+			return err
+		},
+		"delete metadata": func() error {
+			_, err := services.Files.DeleteMetadata(bob, readOnly.ID, []string{"author"})
+			return err
+		},
+		"delete": func() error {
+			_, err := services.Files.Delete(bob, readOnly.ID)
+			return err
+		},
+		"move out of a read-only node": func() error {
+			_, err := services.Files.Move(bob, readOnly.ID, nodes["Egen"])
+			return err
+		},
+	}
+	for action, run := range denied {
+		if err := run(); err == nil || err.Error() != "permission denied: cannot modify this node" {
+			t.Errorf("%s: err = %v", action, err)
+		}
+	}
+	// Målnoden får också nya filer och kräver ändringsbehörighet
+	if _, err := services.Files.Move(bob, own.ID, nodes["Läsbar"]); err == nil || err.Error() != "permission denied: cannot modify the target node" {
+		t.Errorf("move into a read-only node: err = %v", err)
+	}
+	file, err := loadFile(db, readOnly.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value, _ := fileMetadataValue(file, "author"); value != "Anna" || file.NodeID == nil || *file.NodeID != nodes["Läsbar"] {
+		t.Errorf("refused changes altered the file: %+v", file)
+	}
+
+	if _, err := services.Files.UpdateMetadata(bob, own.ID, []*model.MetadataInput{{Key: "year", Value: "2024"}}); err != nil {
+		t.Errorf("update metadata with permission: %v", err)
+	}
+	if _, err := services.Files.DeleteMetadata(bob, own.ID, []string{"year"}); err != nil {
+		t.Errorf("delete metadata with permission: %v", err)
+	}
+	if _, err := services.Files.Move(bob, own.ID, nodes["Mottagning"]); err != nil {
+		t.Errorf("move with permission on both nodes: %v", err)
+	}
+	if ok, err := services.Files.Delete(bob, own.ID); err != nil || !ok {
+		t.Errorf("delete with permission: %v, %v", ok, err)
+	}
+}
+
+func TestFileServiceRequiresViewPermission(t *testing.T) {
+	db := openTestDB(t)
+	services := NewServices(db)
+	admin := testAdminContext(t)
+	bobID := insertTestUser(t, db, "bob")
+	bob := testUserContext(t, bobID, "bob")
+
+	// Bob får läsa i Läsbar men inte se Hemlig
+	readable := createTestNode(t, db, "Läsbar", nil)
+	if _, err := services.Nodes.SetOwnership(admin, readable.ID, &bobID, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := services.Nodes.SetPermissions(admin, readable.ID, PERM_VIEW); err != nil {
+		t.Fatal(err)
+	}
+	secret := createTestNode(t, db, "Hemlig", nil)
+	visible := saveTestFile(t, db, "läsbar.txt", readable.ID, "läsbar")
+	hidden := saveTestFile(t, db, "hemlig.txt", secret.ID, "hemlig")
+
+	anonymous := context.Background()
+	if _, err := services.Files.Get(anonymous, visible.ID); err == nil || err.Error() != "not authenticated" {
+		t.Errorf("anonymous get: err = %v", err)
+	}
+	if _, err := services.Files.Download(anonymous, visible.ID); err == nil || err.Error() != "not authenticated" {
+		t.Errorf("anonymous download: err = %v", err)
+	}
+	if _, err := services.Files.List(anonymous); err == nil || err.Error() != "not authenticated" {
+		t.Errorf("anonymous list: err = %v", err)
+	}
+
+	if _, err := services.Files.Get(bob, hidden.ID); err == nil || err.Error() != "permission denied: cannot view this node" {
+		t.Errorf("get without permission: err = %v", err)
+	}
+	if _, err := services.Files.Download(bob, hidden.ID); err == nil || err.Error() != "permission denied: cannot view this node" {
+		t.Errorf("download without permission: err = %v", err)
+	}
+
+	got, err := services.Files.Get(bob, visible.ID)
+	if err != nil {
+		t.Fatalf("get with permission: %v", err)
+	}
+	if got.NodeID == nil || *got.NodeID != readable.ID {
+		t.Errorf("nodeId = %v, want %s", got.NodeID, readable.ID)
+	}
+
+	// Listan innehåller bara filer i noder som Bob får se, och aldrig filernas innehåll
+	listed, err := services.Files.List(bob)
+	if err != nil {
+		t.Fatalf("list files: %v", err)
+	}
+	if len(listed) != 1 || listed[0].ID != visible.ID {
+		t.Errorf("listed %v, want only %s", listed, visible.ID)
+	}
+	all, err := services.Files.List(admin)
+	if err != nil {
+		t.Fatalf("list files as admin: %v", err)
+	}
+	if len(all) != 2 {
+		t.Errorf("admin listed %d files, want 2", len(all))
+	}
+	for _, file := range all {
+		if file.FileData != nil {
+			t.Errorf("list returned the content of %s", file.Name)
+		}
+	}
+
+	// Att spara en fil kräver ändringsbehörighet på noden
+	input := model.FileInput{Name: "ny.txt", Size: 2, ContentType: "text/plain", FileData: base64.StdEncoding.EncodeToString([]byte("ny")), NodeID: &readable.ID}
+	if _, err := services.Files.Save(bob, input); err == nil || err.Error() != "permission denied: cannot modify this node" {
+		t.Errorf("save without permission: err = %v", err)
+	}
+}
@@ -0,0 +1,30 @@
+package graph
+
+// This file will be automatically regenerated based on the schema, any resolver implementations
+// will be copied through when generating and any unknown code will be moved to the end.
+// Code generated by github.com/99designs/gqlgen version v0.17.69
+
+import (
+	"context"
+	"fmt"
+	"graphql-backend/graph/model"
+	"log"
+)
+
+// StartFixityCheck is the resolver for the startFixityCheck field.
+func (r *mutationResolver) StartFixityCheck(ctx context.Context, nodeID string) (*model.Job, error) {
+	logAction(fmt.Sprintf("Starting fixity check of node %s", nodeID))
+
+	if r.DB == nil {
+		log.Printf("Database connection is nil")
+		return nil, serviceErrorf(errInternal, "internal server error: database connection is not initialized")
+	}
+
+	// Kontrollen registrerar bevarandehändelser för hela underträdet
+	userID, err := requireAdministrator(ctx, r.DB, "run fixity checks")
+	if err != nil {
+		return nil, err
+	}
+
+	return startFixityCheckJob(r.DB, nodeID, userID)
+}
@@ -0,0 +1,597 @@
+package graph
+
+import (
+	"archive/zip"
+	"bytes"
+	"database/sql"
+	_ "embed"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"graphql-backend/graph/model"
+	"log"
+	"path"
+	"strings"
+	"unicode/utf8"
+)
+
+// =============================================
+// ========== FILFORMATSIDENTIFIERING ========
+// =============================================
+
+// Registret är en reducerad PRONOM-förteckning som byggs in i programmet
+//
+//go:embed signatures/pronom.json
+var formatRegistryJSON []byte
+
+// formatRegistry laddas vid start så att ett trasigt register upptäcks direkt
+var formatRegistry = mustLoadFormatRegistry(formatRegistryJSON)
+
+// Hur ett format identifierades
+const (
+	FORMAT_BASIS_SIGNATURE = "signature"
+	FORMAT_BASIS_CONTAINER = "container"
+	FORMAT_BASIS_EXTENSION = "extension"
+	FORMAT_BASIS_TEXT      = "text"
+)
+
+// Antal byte i början av filen som används för att avgöra om innehållet är text
+const textSniffLength = 8192
+
+// formatSequence är en bytesekvens som måste finnas på en fast position,
+// eller någonstans inom de första Search byten
+type formatSequence struct {
+	Offset     int    `json:"offset"`
+	Search     int    `json:"search"`
+	Hex        string `json:"hex"`
+	Text       string `json:"text"`
+	IgnoreCase bool   `json:"ignoreCase"`
+
+	pattern  []byte
+	wildcard []bool
+}
+
+// fileFormat är en post i signaturregistret
+type fileFormat struct {
+	PUID             string              `json:"puid"`
+	Name             string              `json:"name"`
+	Version          string              `json:"version"`
+	MimeType         string              `json:"mimeType"`
+	MimeAliases      []string            `json:"mimeAliases"`
+	Extensions       []string            `json:"extensions"`
+	Priority         int                 `json:"priority"`
+	Signatures       [][]*formatSequence `json:"signatures"`
+	ContainerEntries []string            `json:"containerEntries"`
+	Textual          bool                `json:"textual"`
+	DefaultText      bool                `json:"defaultText"`
+}
+
+// signatureRegistry är det inlästa registret med uppslag på PUID
+type signatureRegistry struct {
+	Version string        `json:"version"`
+	Formats []*fileFormat `json:"formats"`
+
+	byPUID map[string]*fileFormat
+}
+
+// formatIdentification är resultatet av att identifiera en fils format
+type formatIdentification struct {
+	Format   *fileFormat // nil om formatet inte kunde identifieras
+	Basis    string
+	Mismatch bool // Den deklarerade innehållstypen stämmer inte med det identifierade formatet
+}
+
+// mustLoadFormatRegistry tolkar registret och förbereder signaturerna för matchning
+func mustLoadFormatRegistry(data []byte) *signatureRegistry {
+	var registry signatureRegistry
+	if err := json.Unmarshal(data, &registry); err != nil {
+		log.Fatalf("Error parsing format registry: %v", err)
+	}
+
+	registry.byPUID = make(map[string]*fileFormat)
+	for _, format := range registry.Formats {
+		if _, exists := registry.byPUID[format.PUID]; exists {
+			log.Fatalf("Error in format registry: duplicate PUID %s", format.PUID)
+		}
+		registry.byPUID[format.PUID] = format
+
+		for _, signature := range format.Signatures {
+			for _, sequence := range signature {
+				if err := sequence.compile(); err != nil {
+					log.Fatalf("Error in format registry: %s: %v", format.PUID, err)
+				}
+			}
+		}
+	}
+
+	return &registry
+}
+
+// compile omvandlar hex- eller textmönstret till bytes med jokertecken
+func (s *formatSequence) compile() error {
+	if s.Text != "" {
+		s.pattern = []byte(s.Text)
+		if s.IgnoreCase {
+			s.pattern = bytes.ToLower(s.pattern)
+		}
+		s.wildcard = make([]bool, len(s.pattern))
+		return nil
+	}
+
+	if len(s.Hex) == 0 || len(s.Hex)%2 != 0 {
+		return fmt.Errorf("invalid byte sequence %q", s.Hex)
+	}
+	for i := 0; i < len(s.Hex); i += 2 {
+		pair := s.Hex[i : i+2]
+		if pair == "??" {
+			s.pattern = append(s.pattern, 0)
+			s.wildcard = append(s.wildcard, true)
+			continue
+		}
+		b, err := hex.DecodeString(pair)
+		if err != nil {
+			return fmt.Errorf("invalid byte sequence %q: %v", s.Hex, err)
+		}
+		s.pattern = append(s.pattern, b[0])
+		s.wildcard = append(s.wildcard, false)
+	}
+	return nil
+}
+
+// matchAt kontrollerar om sekvensen finns på en given position
+func (s *formatSequence) matchAt(data []byte, offset int) bool {
+	if offset < 0 || offset+len(s.pattern) > len(data) {
+		return false
+	}
+	for i, b := range s.pattern {
+		if s.wildcard[i] {
+			continue
+		}
+		actual := data[offset+i]
+		if s.IgnoreCase && actual >= 'A' && actual <= 'Z' {
+			actual += 'a' - 'A'
+		}
+		if actual != b {
+			return false
+		}
+	}
+	return true
+}
+
+// match kontrollerar sekvensen mot filinnehållet
+func (s *formatSequence) match(data []byte) bool {
+	if s.Search == 0 {
+		return s.matchAt(data, s.Offset)
+	}
+
+	end := s.Offset + s.Search
+	if end > len(data) {
+		end = len(data)
+	}
+	for offset := s.Offset; offset+len(s.pattern) <= end; offset++ {
+		if s.matchAt(data, offset) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesSignature returnerar true om någon av formatets signaturer matchar helt
+func (f *fileFormat) matchesSignature(data []byte) bool {
+	for _, signature := range f.Signatures {
+		matched := len(signature) > 0
+		for _, sequence := range signature {
+			if !sequence.match(data) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+// acceptsMimeType returnerar true om innehållstypen motsvarar formatet
+func (f *fileFormat) acceptsMimeType(contentType string) bool {
+	if contentType == f.MimeType || containsString(f.MimeAliases, contentType) {
+		return true
+	}
+	// Vanlig text kan deklareras med vilken text-typ som helst
+	return f.DefaultText && strings.HasPrefix(contentType, "text/")
+}
+
+// hasExtension returnerar true om filnamnets ändelse hör till formatet
+func (f *fileFormat) hasExtension(name string) bool {
+	ext := strings.TrimPrefix(strings.ToLower(path.Ext(name)), ".")
+	return ext != "" && containsString(f.Extensions, ext)
+}
+
+// identifyFormat identifierar en fils format från innehållet och jämför med den deklarerade typen.
+// Signaturer går före filändelser; vid flera träffar vinner formatet med högst prioritet.
+func identifyFormat(name string, declaredType string, data []byte) *formatIdentification {
+	textual := isTextContent(data)
+	identification := &formatIdentification{}
+
+	var entries []string
+	entriesRead := false
+	for _, format := range formatRegistry.Formats {
+		if len(format.Signatures) == 0 || (format.Textual && !textual) || !format.matchesSignature(data) {
+			continue
+		}
+
+		basis := FORMAT_BASIS_SIGNATURE
+		if len(format.ContainerEntries) > 0 {
+			if !entriesRead {
+				entries = zipEntryNames(data)
+				entriesRead = true
+			}
+			if !containsAllStrings(entries, format.ContainerEntries) {
+				continue
+			}
+			basis = FORMAT_BASIS_CONTAINER
+		}
+
+		if identification.Format == nil || format.Priority > identification.Format.Priority {
+			identification.Format = format
+			identification.Basis = basis
+		}
+	}
+
+	// Textformat utan signatur känns igen på filändelsen, annars som vanlig text
+	if identification.Format == nil && textual {
+		for _, format := range formatRegistry.Formats {
+			if format.Textual && len(format.Signatures) == 0 && format.hasExtension(name) {
+				identification.Format = format
+				identification.Basis = FORMAT_BASIS_EXTENSION
+				break
+			}
+		}
+		if identification.Format == nil {
+			for _, format := range formatRegistry.Formats {
+				if format.DefaultText {
+					identification.Format = format
+					identification.Basis = FORMAT_BASIS_TEXT
+					break
+				}
+			}
+		}
+	}
+
+	// Ospecificerade typer räknas inte som avvikelser
+	declared := normalizeContentType(declaredType)
+	if identification.Format != nil && declared != "" && declared != "application/octet-stream" {
+		identification.Mismatch = !identification.Format.acceptsMimeType(declared)
+	}
+
+	return identification
+}
+
+// PUID returnerar det identifierade formatets PUID, eller tom sträng
+func (i *formatIdentification) PUID() string {
+	if i.Format == nil {
+		return ""
+	}
+	return i.Format.PUID
+}
+
+// MimeType returnerar det identifierade formatets MIME-typ, eller tom sträng
+func (i *formatIdentification) MimeType() string {
+	if i.Format == nil {
+		return ""
+	}
+	return i.Format.MimeType
+}
+
+// eventDetail beskriver identifieringen för PREMIS-händelsen
+func (i *formatIdentification) eventDetail(declaredType string) string {
+	if i.Format == nil {
+		return "Format could not be identified"
+	}
+	detail := fmt.Sprintf("Identified as %s (%s) by %s", i.Format.PUID, i.Format.Name, i.Basis)
+	if i.Mismatch {
+		detail += fmt.Sprintf("; declared content type %s does not match %s", declaredType, i.Format.MimeType)
+	}
+	return detail
+}
+
+// eventOutcome ger utfallet för PREMIS-händelsen; oidentifierade filer räknas som misslyckade
+func (i *formatIdentification) eventOutcome() string {
+	if i.Format == nil {
+		return PRESERVATION_OUTCOME_FAILURE
+	}
+	return PRESERVATION_OUTCOME_SUCCESS
+}
+
+// normalizeContentType tar bort parametrar och versaler från en innehållstyp
+func normalizeContentType(contentType string) string {
+	if i := strings.Index(contentType, ";"); i >= 0 {
+		contentType = contentType[:i]
+	}
+	return strings.ToLower(strings.TrimSpace(contentType))
+}
+
+// isTextContent avgör om början av innehållet är UTF-8-text utan styrtecken
+func isTextContent(data []byte) bool {
+	if len(data) == 0 {
+		return false
+	}
+	if len(data) > textSniffLength {
+		data = data[:textSniffLength]
+		// Ett tecken kan ha delats av vid gränsen
+		for i := 0; i < utf8.UTFMax && len(data) > 0 && !utf8.Valid(data); i++ {
+			data = data[:len(data)-1]
+		}
+	}
+	if !utf8.Valid(data) {
+		return false
+	}
+	for _, b := range data {
+		if b < 0x20 && b != '\t' && b != '\n' && b != '\r' && b != '\f' {
+			return false
+		}
+	}
+	return true
+}
+
+// zipEntryNames läser namnen på posterna i ett ZIP-arkiv, eller nil om innehållet inte är ett giltigt arkiv
+func zipEntryNames(data []byte) []string {
+	reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil
+	}
+	names := make([]string, len(reader.File))
+	for i, entry := range reader.File {
+		names[i] = entry.Name
+	}
+	return names
+}
+
+// containsAllStrings returnerar true om alla värden finns i listan
+func containsAllStrings(list []string, values []string) bool {
+	for _, value := range values {
+		if !containsString(list, value) {
+			return false
+		}
+	}
+	return true
+}
+
+// =============================================
+// ========== GODKÄNDA FORMAT PER NOD ========
+// =============================================
+
+// sqlQueryer är det gemensamma gränssnittet för *sql.DB och *sql.Tx vid läsning
+type sqlQueryer interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// loadAcceptedFormats hämtar de PUID:er som angetts direkt på en nod
+func loadAcceptedFormats(db sqlQueryer, nodeID string) ([]string, error) {
+	rows, err := db.Query("SELECT puid FROM node_accepted_formats WHERE node_id = ? ORDER BY puid ASC", nodeID)
+	if err != nil {
+		log.Printf("Error fetching accepted formats for node %s: %v", nodeID, err)
+		return nil, serviceErrorf(errInternal, "failed to fetch accepted formats: %v", err)
+	}
+	defer rows.Close()
+
+	var puids []string
+	for rows.Next() {
+		var puid string
+		if err := rows.Scan(&puid); err != nil {
+			log.Printf("Error scanning accepted format: %v", err)
+			return nil, serviceErrorf(errInternal, "failed to scan accepted format: %v", err)
+		}
+		puids = append(puids, puid)
+	}
+	return puids, rows.Err()
+}
+
+// effectiveAcceptedFormats hämtar listan över godkända format som gäller för en nod.
+// Listan ärvs från närmaste förälder som har en egen lista; nil betyder att alla format godtas.
+func effectiveAcceptedFormats(db sqlQueryer, nodeID string) ([]string, string, error) {
+	visited := make(map[string]bool)
+	currentID := nodeID
+	for currentID != "" && !visited[currentID] {
+		visited[currentID] = true
+
+		puids, err := loadAcceptedFormats(db, currentID)
+		if err != nil {
+			return nil, "", err
+		}
+		if len(puids) > 0 {
+			return puids, currentID, nil
+		}
+
+		var parentID sql.NullString
+		err = db.QueryRow("SELECT parent_id FROM nodes WHERE id = ?", currentID).Scan(&parentID)
+		if err == sql.ErrNoRows {
+			return nil, "", nil
+		} else if err != nil {
+			log.Printf("Error fetching parent of node %s: %v", currentID, err)
+			return nil, "", serviceErrorf(errInternal, "failed to fetch parent node: %v", err)
+		}
+		currentID = parentID.String
+	}
+	return nil, "", nil
+}
+
+// checkFormatAccepted kontrollerar att ett identifierat format godtas i en nod
+func checkFormatAccepted(db sqlQueryer, nodeID string, identification *formatIdentification) error {
+	puids, policyNodeID, err := effectiveAcceptedFormats(db, nodeID)
+	if err != nil {
+		return err
+	}
+	return validateFormatAccepted(puids, policyNodeID, identification)
+}
+
+// validateFormatAccepted jämför ett identifierat format mot en lista över godkända format
+func validateFormatAccepted(puids []string, policyNodeID string, identification *formatIdentification) error {
+	if len(puids) == 0 {
+		return nil
+	}
+	if identification.Format == nil {
+		return fmt.Errorf("file format could not be identified; node %s only accepts %s", policyNodeID, strings.Join(puids, ", "))
+	}
+	if !containsString(puids, identification.Format.PUID) {
+		return fmt.Errorf("file format %s (%s) is not accepted; node %s only accepts %s",
+			identification.Format.PUID, identification.Format.Name, policyNodeID, strings.Join(puids, ", "))
+	}
+	return nil
+}
+
+// checkSubtreeFormatsAccepted kontrollerar att filerna i ett underträd godtas under en ny förälder.
+// Noder med en egen lista omfattas inte av förälderns lista och hoppas över.
+func checkSubtreeFormatsAccepted(db sqlQueryer, nodeID string, newParentID string) error {
+	puids, policyNodeID, err := effectiveAcceptedFormats(db, newParentID)
+	if err != nil || len(puids) == 0 {
+		return err
+	}
+
+	pending := []string{nodeID}
+	for len(pending) > 0 {
+		currentID := pending[0]
+		pending = pending[1:]
+
+		own, err := loadAcceptedFormats(db, currentID)
+		if err != nil {
+			return err
+		}
+		if len(own) > 0 {
+			continue
+		}
+
+		rows, err := db.Query("SELECT id FROM files WHERE node_id = ?", currentID)
+		if err != nil {
+			log.Printf("Error fetching files for node %s: %v", currentID, err)
+			return serviceErrorf(errInternal, "failed to fetch files: %v", err)
+		}
+		fileIDs, err := scanIDs(rows)
+		if err != nil {
+			return err
+		}
+		for _, fileID := range fileIDs {
+			identification, err := getFileIdentification(db, fileID)
+			if err != nil {
+				return err
+			}
+			if err := validateFormatAccepted(puids, policyNodeID, identification); err != nil {
+				return fmt.Errorf("file %s: %v", fileID, err)
+			}
+		}
+
+		rows, err = db.Query("SELECT id FROM nodes WHERE parent_id = ?", currentID)
+		if err != nil {
+			log.Printf("Error fetching child nodes of %s: %v", currentID, err)
+			return serviceErrorf(errInternal, "failed to fetch child nodes: %v", err)
+		}
+		childIDs, err := scanIDs(rows)
+		if err != nil {
+			return err
+		}
+		pending = append(pending, childIDs...)
+	}
+	return nil
+}
+
+// scanIDs läser en kolumn med ID:n och stänger raderna
+func scanIDs(rows *sql.Rows) ([]string, error) {
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			log.Printf("Error scanning ID: %v", err)
+			return nil, serviceErrorf(errInternal, "failed to scan ID: %v", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// getFileIdentification hämtar en fils sparade formatidentifiering från registret
+func getFileIdentification(db sqlQueryer, fileID string) (*formatIdentification, error) {
+	var puid sql.NullString
+	var mismatch bool
+	err := db.QueryRow("SELECT puid, format_mismatch FROM files WHERE id = ?", fileID).Scan(&puid, &mismatch)
+	if err == sql.ErrNoRows {
+		return nil, serviceErrorf(errNotFound, "file not found")
+	} else if err != nil {
+		log.Printf("Error fetching format of file %s: %v", fileID, err)
+		return nil, serviceErrorf(errInternal, "failed to fetch file format: %v", err)
+	}
+
+	return &formatIdentification{Format: formatRegistry.byPUID[puid.String], Mismatch: mismatch}, nil
+}
+
+// format returnerar filens identifierade format från registret, eller nil
+func (f *treeFile) format() *fileFormat {
+	return formatRegistry.byPUID[f.PUID]
+}
+
+// mimeType returnerar det identifierade formatets MIME-typ, annars den deklarerade typen
+func (f *treeFile) mimeType() string {
+	if format := f.format(); format != nil {
+		return format.MimeType
+	}
+	return f.ContentType
+}
+
+// formatCode returnerar PUID för identifierade format, annars den deklarerade typen
+func (f *treeFile) formatCode() string {
+	if f.PUID != "" {
+		return f.PUID
+	}
+	return f.ContentType
+}
+
+// getStoredFormat hämtar det identifierade formatet som sparats för en fil
+func getStoredFormat(db sqlQueryer, fileID string) (*model.FormatIdentification, error) {
+	var puid, mimeType sql.NullString
+	var mismatch bool
+	err := db.QueryRow("SELECT puid, format_mime_type, format_mismatch FROM files WHERE id = ?", fileID).Scan(&puid, &mimeType, &mismatch)
+	if err == sql.ErrNoRows {
+		return nil, serviceErrorf(errNotFound, "file not found")
+	} else if err != nil {
+		log.Printf("Error fetching format of file %s: %v", fileID, err)
+		return nil, serviceErrorf(errInternal, "failed to fetch file format: %v", err)
+	}
+
+	identification := &model.FormatIdentification{
+		Puid:     nullStringPtr(puid),
+		MimeType: nullStringPtr(mimeType),
+		Mismatch: mismatch,
+	}
+	// Namnet hämtas från registret; PUID:er som inte längre finns där saknar namn
+	if format, ok := formatRegistry.byPUID[puid.String]; ok {
+		identification.Name = &format.Name
+		identification.Version = optionalString(format.Version)
+	}
+	return identification, nil
+}
+
+// fileFormatModel konverterar en registerpost till GraphQL-modellen
+func fileFormatModel(format *fileFormat) *model.FileFormat {
+	extensions := format.Extensions
+	if extensions == nil {
+		extensions = []string{}
+	}
+	return &model.FileFormat{
+		Puid:       format.PUID,
+		Name:       format.Name,
+		Version:    optionalString(format.Version),
+		MimeType:   format.MimeType,
+		Extensions: extensions,
+	}
+}
+
+// optionalString returnerar nil för tomma strängar
+func optionalString(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return &value
+}
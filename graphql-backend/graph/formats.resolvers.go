@@ -0,0 +1,99 @@
+package graph
+
+// This file will be automatically regenerated based on the schema, any resolver implementations
+// will be copied through when generating and any unknown code will be moved to the end.
+// Code generated by github.com/99designs/gqlgen version v0.17.69
+
+import (
+	"context"
+	"fmt"
+	"graphql-backend/graph/model"
+	"log"
+)
+
+// Format is the resolver for the format field.
+func (r *fileResolver) Format(ctx context.Context, obj *model.File) (*model.FormatIdentification, error) {
+	if r.DB == nil {
+		return nil, serviceErrorf(errInternal, "internal server error: database connection is not initialized")
+	}
+
+	return getStoredFormat(r.DB, obj.ID)
+}
+
+// SetAcceptedFormats is the resolver for the setAcceptedFormats field.
+func (r *mutationResolver) SetAcceptedFormats(ctx context.Context, nodeID string, puids []string) (*model.Node, error) {
+	logAction(fmt.Sprintf("Setting accepted formats of node %s", nodeID))
+
+	if r.DB == nil {
+		log.Printf("Database connection is nil")
+		return nil, serviceErrorf(errInternal, "internal server error: database connection is not initialized")
+	}
+
+	hasPermission, err := checkPermission(ctx, r.DB, nodeID, PERM_MODIFY)
+	if err != nil {
+		return nil, err
+	}
+	if !hasPermission {
+		return nil, serviceErrorf(errPermissionDenied, "permission denied: cannot modify this node")
+	}
+
+	// Endast format som finns i registret kan identifieras och därmed godkännas
+	for _, puid := range puids {
+		if _, ok := formatRegistry.byPUID[puid]; !ok {
+			return nil, fmt.Errorf("unknown format %s", puid)
+		}
+	}
+
+	tx, err := r.DB.Begin()
+	if err != nil {
+		log.Printf("Error starting transaction: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to start transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM node_accepted_formats WHERE node_id = ?", nodeID); err != nil {
+		log.Printf("Error clearing accepted formats for node %s: %v", nodeID, err)
+		return nil, serviceErrorf(errInternal, "failed to update accepted formats: %v", err)
+	}
+
+	for _, puid := range puids {
+		if _, err := tx.Exec("INSERT INTO node_accepted_formats (node_id, puid) VALUES (?, ?) ON CONFLICT DO NOTHING", nodeID, puid); err != nil {
+			log.Printf("Error saving accepted format %s for node %s: %v", puid, nodeID, err)
+			return nil, serviceErrorf(errInternal, "failed to update accepted formats: %v", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("Error committing transaction: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to commit transaction: %v", err)
+	}
+
+	log.Printf("Node %s now accepts %d formats", nodeID, len(puids))
+	return getNodeWithPermissions(ctx, r.DB, nodeID)
+}
+
+// FormatPolicy is the resolver for the formatPolicy field.
+func (r *nodeResolver) FormatPolicy(ctx context.Context, obj *model.Node) (*model.FormatPolicy, error) {
+	if r.DB == nil {
+		return nil, serviceErrorf(errInternal, "internal server error: database connection is not initialized")
+	}
+
+	puids, policyNodeID, err := effectiveAcceptedFormats(r.DB, obj.ID)
+	if err != nil {
+		return nil, err
+	}
+	if len(puids) == 0 {
+		return nil, nil
+	}
+
+	return &model.FormatPolicy{NodeID: policyNodeID, Puids: puids}, nil
+}
+
+// FileFormats is the resolver for the fileFormats field.
+func (r *queryResolver) FileFormats(ctx context.Context) ([]*model.FileFormat, error) {
+	formats := make([]*model.FileFormat, len(formatRegistry.Formats))
+	for i, format := range formatRegistry.Formats {
+		formats[i] = fileFormatModel(format)
+	}
+	return formats, nil
+}
@@ -0,0 +1,109 @@
+package graph
+
+import (
+	"encoding/base64"
+	"graphql-backend/graph/model"
+	"strings"
+	"testing"
+)
+
+func TestIdentifyFormat(t *testing.T) {
+	docx := testZipArchive(t, map[string]string{"[Content_Types].xml": "<Types/>", "word/document.xml": "<w:document/>"})
+	plainZip := testZipArchive(t, map[string]string{"brev.txt": "hej"})
+
+	tests := []struct {
+		name     string
+		fileName string
+		declared string
+		data     []byte
+		puid     string
+		basis    string
+		mismatch bool
+	}{
+		{"pdf", "rapport.pdf", "application/pdf", []byte("%PDF-1.4\n%âãÏÓ\n1 0 obj"), "fmt/18", FORMAT_BASIS_SIGNATURE, false},
+		{"png", "bild.png", "image/png", []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"), "fmt/11", FORMAT_BASIS_SIGNATURE, false},
+		{"docx wins over zip", "brev.docx", "application/zip", docx, "fmt/412", FORMAT_BASIS_CONTAINER, true},
+		{"plain zip", "arkiv.zip", "application/zip", plainZip, "x-fmt/263", FORMAT_BASIS_SIGNATURE, false},
+		{"xml", "data.xml", "text/xml; charset=utf-8", []byte(`<?xml version="1.0"?><a/>`), "fmt/101", FORMAT_BASIS_SIGNATURE, false},
+		{"csv by extension", "lista.csv", "text/csv", []byte("namn;år\nAnna;2026\n"), "x-fmt/18", FORMAT_BASIS_EXTENSION, false},
+		{"other text", "anteckning", "", []byte("Mötet börjar klockan tio."), "x-fmt/111", FORMAT_BASIS_TEXT, false},
+		{"declared type ignored when unspecified", "rapport.pdf", "application/octet-stream", []byte("%PDF-1.4\n"), "fmt/18", FORMAT_BASIS_SIGNATURE, false},
+		{"pdf renamed to png", "bild.png", "image/png", []byte("%PDF-1.4\n"), "fmt/18", FORMAT_BASIS_SIGNATURE, true},
+		{"unknown binary", "data.bin", "application/octet-stream", []byte{0x00, 0x01, 0x02, 0xff}, "", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := identifyFormat(tt.fileName, tt.declared, tt.data)
+			if got.PUID() != tt.puid || got.Basis != tt.basis || got.Mismatch != tt.mismatch {
+				t.Errorf("identify = %s by %q (mismatch %v), want %s by %q (mismatch %v)", got.PUID(), got.Basis, got.Mismatch, tt.puid, tt.basis, tt.mismatch)
+			}
+		})
+	}
+
+	if outcome := identifyFormat("data.bin", "", []byte{0x00}).eventOutcome(); outcome != PRESERVATION_OUTCOME_FAILURE {
+		t.Errorf("outcome for unknown format = %s", outcome)
+	}
+}
+
+func TestIsTextContent(t *testing.T) {
+	// Ett tecken på flera byte som delas vid gränsen ska inte göra innehållet binärt
+	split := strings.Repeat("a", textSniffLength-1) + "ö"
+	for _, text := range []string{"hej\n", "tab\tseparerat\r\n", split} {
+		if !isTextContent([]byte(text)) {
+			t.Errorf("isTextContent(%.20q) = false", text)
+		}
+	}
+	for _, data := range [][]byte{nil, {0x00}, {'a', 0x1b}, {0xff, 0xfe}} {
+		if isTextContent(data) {
+			t.Errorf("isTextContent(%v) = true", data)
+		}
+	}
+}
+
+func TestAcceptedFormatsOnSave(t *testing.T) {
+	db := openTestDB(t)
+	ctx := testAdminContext(t)
+	root := createTestNode(t, db, "Bevarande", nil)
+	child := createTestNode(t, db, "Inkommande", &root.ID)
+	other := createTestNode(t, db, "Övrigt", nil)
+
+	mutation := NewResolver(db).Mutation()
+	if _, err := mutation.SetAcceptedFormats(ctx, root.ID, []string{"fmt/18", "x-fmt/111"}); err != nil {
+		t.Fatalf("set accepted formats: %v", err)
+	}
+
+	save := func(nodeID string, name string, data []byte) (*model.File, error) {
+		return mutation.SaveFile(ctx, model.FileInput{
+			Name: name, Size: len(data), ContentType: "application/octet-stream",
+			FileData: base64.StdEncoding.EncodeToString(data), NodeID: &nodeID,
+		})
+	}
+
+	// Listan ärvs av barnnoderna
+	if _, err := save(child.ID, "rapport.pdf", []byte("%PDF-1.4\n")); err != nil {
+		t.Errorf("save accepted pdf: %v", err)
+	}
+	if _, err := save(child.ID, "bild.png", []byte("\x89PNG\r\n\x1a\n")); err == nil || !strings.Contains(err.Error(), "file format fmt/11 (Portable Network Graphics) is not accepted; node "+root.ID) {
+		t.Errorf("save png: err = %v", err)
+	}
+	if _, err := save(child.ID, "data.bin", []byte{0x00, 0x01}); err == nil || !strings.Contains(err.Error(), "could not be identified") {
+		t.Errorf("save unknown format: err = %v", err)
+	}
+	png, err := save(other.ID, "bild.png", []byte("\x89PNG\r\n\x1a\n"))
+	if err != nil {
+		t.Fatalf("save png without policy: %v", err)
+	}
+
+	if _, err := mutation.MoveFile(ctx, png.ID, child.ID); err == nil {
+		t.Error("moved a png into a node that only accepts pdf and text")
+	}
+	if _, err := mutation.MoveNode(ctx, other.ID, root.ID); err == nil {
+		t.Error("moved a node with a png under a node that only accepts pdf and text")
+	}
+
+	stored, err := getStoredFormat(db, png.ID)
+	if err != nil || stored == nil || stored.Puid == nil || *stored.Puid != "fmt/11" {
+		t.Errorf("stored format = %+v, %v", stored, err)
+	}
+}
@@ -0,0 +1,618 @@
+package graph
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"graphql-backend/graph/model"
+	"log"
+)
+
+// =============================================
+// ========== GRUPPTJÄNST ====================
+// =============================================
+
+// groupService implementerar GroupService mot databasen
+type groupService struct {
+	db *sql.DB
+}
+
+// Get hämtar en grupp med medlemmar
+func (s *groupService) Get(ctx context.Context, id string) (*model.Group, error) {
+	logAction(fmt.Sprintf("Fetching group with ID: %s", id))
+
+	// Only users with permission to view permissions should see group details
+	userID, err := getUserIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check if the user is an administrator or is in the requested group
+	var hasPermission bool
+	err = s.db.QueryRow(`
+		SELECT EXISTS(
+			SELECT 1 
+			FROM group_members gm
+			JOIN groups g ON gm.group_id = g.id
+			WHERE gm.user_id = ? AND (g.name = 'Administrators' OR gm.group_id = ?)
+		)
+	`, userID, id).Scan(&hasPermission)
+
+	if err != nil {
+		log.Printf("Error checking group permission: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to check group permission: %v", err)
+	}
+
+	if !hasPermission {
+		return nil, serviceErrorf(errPermissionDenied, "permission denied: can only view groups you are a member of")
+	}
+
+	// Query the group
+	var group model.Group
+	err = s.db.QueryRow(`
+		SELECT id, name
+		FROM groups
+		WHERE id = ?
+	`, id).Scan(&group.ID, &group.Name)
+
+	if err == sql.ErrNoRows {
+		return nil, serviceErrorf(errNotFound, "group not found")
+	} else if err != nil {
+		log.Printf("Error fetching group: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to fetch group: %v", err)
+	}
+
+	// Get members of the group
+	memberRows, err := s.db.Query(`
+		SELECT u.id, u.username
+		FROM users u
+		JOIN group_members gm ON u.id = gm.user_id
+		WHERE gm.group_id = ?
+		ORDER BY u.username ASC
+	`, id)
+	if err != nil {
+		log.Printf("Error fetching group members: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to fetch group members: %v", err)
+	}
+	defer memberRows.Close()
+
+	var members []*model.User
+	for memberRows.Next() {
+		var user model.User
+		if err := memberRows.Scan(&user.ID, &user.Username); err != nil {
+			log.Printf("Error scanning user row: %v", err)
+			return nil, serviceErrorf(errInternal, "failed to scan user row: %v", err)
+		}
+		user.Name = user.Username // Using username as name for simplicity
+		members = append(members, &user)
+	}
+
+	if err := memberRows.Err(); err != nil {
+		log.Printf("Error iterating over member rows: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to iterate over member rows: %v", err)
+	}
+
+	group.Members = members
+	return &group, nil
+}
+
+// List hämtar alla grupper
+func (s *groupService) List(ctx context.Context) ([]*model.Group, error) {
+	logAction("Fetching all groups")
+
+	// Only users with permission to view permissions should see all groups
+	userID, err := getUserIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check if the user is an administrator
+	var isAdmin bool
+	err = s.db.QueryRow(`
+		SELECT EXISTS(
+			SELECT 1 
+			FROM group_members gm
+			JOIN groups g ON gm.group_id = g.id
+			WHERE gm.user_id = ? AND g.name = 'Administrators'
+		)
+	`, userID).Scan(&isAdmin)
+
+	if err != nil {
+		log.Printf("Error checking admin status: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to check administrator status: %v", err)
+	}
+
+	if !isAdmin {
+		return nil, serviceErrorf(errPermissionDenied, "permission denied: must be an administrator to view all groups")
+	}
+
+	// Query all groups
+	rows, err := s.db.Query(`
+		SELECT id, name
+		FROM groups
+		ORDER BY name ASC
+	`)
+	if err != nil {
+		log.Printf("Error fetching groups: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to fetch groups: %v", err)
+	}
+	defer rows.Close()
+
+	var groups []*model.Group
+	for rows.Next() {
+		var group model.Group
+		if err := rows.Scan(&group.ID, &group.Name); err != nil {
+			log.Printf("Error scanning group row: %v", err)
+			return nil, serviceErrorf(errInternal, "failed to scan group row: %v", err)
+		}
+		groups = append(groups, &group)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Printf("Error iterating over group rows: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to iterate over group rows: %v", err)
+	}
+
+	return groups, nil
+}
+
+// ForCurrentUser hämtar grupperna som den inloggade användaren är medlem i
+func (s *groupService) ForCurrentUser(ctx context.Context) ([]*model.Group, error) {
+	logAction("Fetching groups for current user")
+
+	// Get user ID from context
+	userID, err := getUserIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return getUserGroups(ctx, s.db, userID)
+}
+
+// Members hämtar en grupps medlemmar
+func (s *groupService) Members(ctx context.Context, groupID string) ([]*model.User, error) {
+	logAction(fmt.Sprintf("Fetching members for group ID: %s", groupID))
+
+	// Query members of the group
+	rows, err := s.db.Query(`
+		SELECT u.id, u.username
+		FROM users u
+		JOIN group_members gm ON u.id = gm.user_id
+		WHERE gm.group_id = ?
+		ORDER BY u.username ASC
+	`, groupID)
+	if err != nil {
+		log.Printf("Error fetching group members: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to fetch group members: %v", err)
+	}
+	defer rows.Close()
+
+	var members []*model.User
+	for rows.Next() {
+		var user model.User
+		if err := rows.Scan(&user.ID, &user.Username); err != nil {
+			log.Printf("Error scanning user row: %v", err)
+			return nil, serviceErrorf(errInternal, "failed to scan user row: %v", err)
+		}
+		user.Name = user.Username // Using username as name for simplicity
+		members = append(members, &user)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Printf("Error iterating over member rows: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to iterate over member rows: %v", err)
+	}
+
+	return members, nil
+}
+
+// Create skapar en grupp
+func (s *groupService) Create(ctx context.Context, name string) (*model.Group, error) {
+	logAction(fmt.Sprintf("Creating group with name: %s", name))
+
+	// Only administrators can create groups
+	userID, err := getUserIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check if the user is an administrator
+	var isAdmin bool
+	err = s.db.QueryRow(`
+		SELECT EXISTS(
+			SELECT 1 
+			FROM group_members gm
+			JOIN groups g ON gm.group_id = g.id
+			WHERE gm.user_id = ? AND g.name = 'Administrators'
+		)
+	`, userID).Scan(&isAdmin)
+
+	if err != nil {
+		log.Printf("Error checking admin status: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to check administrator status: %v", err)
+	}
+
+	if !isAdmin {
+		return nil, serviceErrorf(errPermissionDenied, "permission denied: must be an administrator to create groups")
+	}
+
+	// Check if group name already exists
+	var exists bool
+	err = s.db.QueryRow("SELECT EXISTS(SELECT 1 FROM groups WHERE name = ?)", name).Scan(&exists)
+	if err != nil {
+		log.Printf("Error checking if group exists: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to check if group exists: %v", err)
+	}
+
+	if exists {
+		return nil, fmt.Errorf("group name already exists")
+	}
+
+	// Create the group
+	result, err := s.db.Exec(
+		"INSERT INTO groups (name, created_at) VALUES (?, datetime('now'))",
+		name,
+	)
+	if err != nil {
+		log.Printf("Error creating group: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to create group: %v", err)
+	}
+
+	groupID, err := result.LastInsertId()
+	if err != nil {
+		log.Printf("Error retrieving last insert ID: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to retrieve group ID: %v", err)
+	}
+
+	return &model.Group{
+		ID:   fmt.Sprintf("%d", groupID),
+		Name: name,
+	}, nil
+}
+
+// Update byter namn på en grupp
+func (s *groupService) Update(ctx context.Context, id string, name string) (*model.Group, error) {
+	logAction(fmt.Sprintf("Updating group with ID: %s", id))
+
+	// Only administrators can update groups
+	userID, err := getUserIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check if the user is an administrator
+	var isAdmin bool
+	err = s.db.QueryRow(`
+		SELECT EXISTS(
+			SELECT 1 
+			FROM group_members gm
+			JOIN groups g ON gm.group_id = g.id
+			WHERE gm.user_id = ? AND g.name = 'Administrators'
+		)
+	`, userID).Scan(&isAdmin)
+
+	if err != nil {
+		log.Printf("Error checking admin status: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to check administrator status: %v", err)
+	}
+
+	if !isAdmin {
+		return nil, serviceErrorf(errPermissionDenied, "permission denied: must be an administrator to update groups")
+	}
+
+	// Check if group exists
+	var exists bool
+	err = s.db.QueryRow("SELECT EXISTS(SELECT 1 FROM groups WHERE id = ?)", id).Scan(&exists)
+	if err != nil {
+		log.Printf("Error checking if group exists: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to check if group exists: %v", err)
+	}
+
+	if !exists {
+		return nil, serviceErrorf(errNotFound, "group not found")
+	}
+
+	// Check if this is the Administrators group - don't allow renaming it
+	var currentName string
+	err = s.db.QueryRow("SELECT name FROM groups WHERE id = ?", id).Scan(&currentName)
+	if err != nil {
+		log.Printf("Error fetching group name: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to fetch group name: %v", err)
+	}
+
+	if currentName == "Administrators" {
+		return nil, serviceErrorf(errConflict, "cannot rename the Administrators group")
+	}
+
+	// Check if new name already exists
+	var nameExists bool
+	err = s.db.QueryRow("SELECT EXISTS(SELECT 1 FROM groups WHERE name = ? AND id != ?)", name, id).Scan(&nameExists)
+	if err != nil {
+		log.Printf("Error checking if group name exists: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to check if group name exists: %v", err)
+	}
+
+	if nameExists {
+		return nil, fmt.Errorf("group name already exists")
+	}
+
+	// Update the group
+	_, err = s.db.Exec(
+		"UPDATE groups SET name = ? WHERE id = ?",
+		name, id,
+	)
+	if err != nil {
+		log.Printf("Error updating group: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to update group: %v", err)
+	}
+
+	return &model.Group{
+		ID:   id,
+		Name: name,
+	}, nil
+}
+
+// Delete tar bort en grupp och dess medlemskap
+func (s *groupService) Delete(ctx context.Context, id string) (bool, error) {
+	logAction(fmt.Sprintf("Deleting group with ID: %s", id))
+
+	// Only administrators can delete groups
+	userID, err := getUserIDFromContext(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	// Check if the user is an administrator
+	var isAdmin bool
+	err = s.db.QueryRow(`
+		SELECT EXISTS(
+			SELECT 1 
+			FROM group_members gm
+			JOIN groups g ON gm.group_id = g.id
+			WHERE gm.user_id = ? AND g.name = 'Administrators'
+		)
+	`, userID).Scan(&isAdmin)
+
+	if err != nil {
+		log.Printf("Error checking admin status: %v", err)
+		return false, serviceErrorf(errInternal, "failed to check administrator status: %v", err)
+	}
+
+	if !isAdmin {
+		return false, serviceErrorf(errPermissionDenied, "permission denied: must be an administrator to delete groups")
+	}
+
+	// Check if group exists
+	var exists bool
+	err = s.db.QueryRow("SELECT EXISTS(SELECT 1 FROM groups WHERE id = ?)", id).Scan(&exists)
+	if err != nil {
+		log.Printf("Error checking if group exists: %v", err)
+		return false, serviceErrorf(errInternal, "failed to check if group exists: %v", err)
+	}
+
+	if !exists {
+		return false, serviceErrorf(errNotFound, "group not found")
+	}
+
+	// Check if this is the Administrators group - don't allow deleting it
+	var groupName string
+	err = s.db.QueryRow("SELECT name FROM groups WHERE id = ?", id).Scan(&groupName)
+	if err != nil {
+		log.Printf("Error fetching group name: %v", err)
+		return false, serviceErrorf(errInternal, "failed to fetch group name: %v", err)
+	}
+
+	if groupName == "Administrators" {
+		return false, serviceErrorf(errConflict, "cannot delete the Administrators group")
+	}
+
+	// Remove all members from the group and then delete it
+	tx, err := s.db.Begin()
+	if err != nil {
+		log.Printf("Error starting transaction: %v", err)
+		return false, serviceErrorf(errInternal, "failed to start transaction: %v", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// Delete group memberships
+	_, err = tx.Exec("DELETE FROM group_members WHERE group_id = ?", id)
+	if err != nil {
+		log.Printf("Error deleting group memberships: %v", err)
+		return false, serviceErrorf(errInternal, "failed to delete group memberships: %v", err)
+	}
+
+	// Delete group
+	result, err := tx.Exec("DELETE FROM groups WHERE id = ?", id)
+	if err != nil {
+		log.Printf("Error deleting group: %v", err)
+		return false, serviceErrorf(errInternal, "failed to delete group: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		log.Printf("Error getting rows affected: %v", err)
+		tx.Rollback()
+		return false, fmt.Errorf("error confirming deletion: %v", err)
+	}
+
+	if rowsAffected == 0 {
+		tx.Rollback()
+		return false, serviceErrorf(errNotFound, "group not found")
+	}
+
+	// Commit the transaction
+	if err = tx.Commit(); err != nil {
+		log.Printf("Error committing transaction: %v", err)
+		return false, serviceErrorf(errInternal, "failed to commit transaction: %v", err)
+	}
+
+	return true, nil
+}
+
+// AddMember lägger till en användare i en grupp
+func (s *groupService) AddMember(ctx context.Context, userID string, groupID string) (bool, error) {
+	logAction(fmt.Sprintf("Adding user %s to group %s", userID, groupID))
+
+	// Only administrators can manage group memberships
+	currentUserID, err := getUserIDFromContext(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	// Check if the user is an administrator
+	var isAdmin bool
+	err = s.db.QueryRow(`
+		SELECT EXISTS(
+			SELECT 1 
+			FROM group_members gm
+			JOIN groups g ON gm.group_id = g.id
+			WHERE gm.user_id = ? AND g.name = 'Administrators'
+		)
+	`, currentUserID).Scan(&isAdmin)
+
+	if err != nil {
+		log.Printf("Error checking admin status: %v", err)
+		return false, serviceErrorf(errInternal, "failed to check administrator status: %v", err)
+	}
+
+	if !isAdmin {
+		return false, serviceErrorf(errPermissionDenied, "permission denied: must be an administrator to manage group memberships")
+	}
+
+	// Check if user and group exist
+	var userExists bool
+	err = s.db.QueryRow("SELECT EXISTS(SELECT 1 FROM users WHERE id = ?)", userID).Scan(&userExists)
+	if err != nil {
+		log.Printf("Error checking if user exists: %v", err)
+		return false, serviceErrorf(errInternal, "failed to check if user exists: %v", err)
+	}
+
+	if !userExists {
+		return false, serviceErrorf(errNotFound, "user not found")
+	}
+
+	var groupExists bool
+	err = s.db.QueryRow("SELECT EXISTS(SELECT 1 FROM groups WHERE id = ?)", groupID).Scan(&groupExists)
+	if err != nil {
+		log.Printf("Error checking if group exists: %v", err)
+		return false, serviceErrorf(errInternal, "failed to check if group exists: %v", err)
+	}
+
+	if !groupExists {
+		return false, serviceErrorf(errNotFound, "group not found")
+	}
+
+	// Check if user is already in the group
+	var memberExists bool
+	err = s.db.QueryRow("SELECT EXISTS(SELECT 1 FROM group_members WHERE user_id = ? AND group_id = ?)", userID, groupID).Scan(&memberExists)
+	if err != nil {
+		log.Printf("Error checking if membership exists: %v", err)
+		return false, serviceErrorf(errInternal, "failed to check if membership exists: %v", err)
+	}
+
+	if memberExists {
+		// User is already in the group, consider this a success
+		return true, nil
+	}
+
+	// Add user to group
+	_, err = s.db.Exec(
+		"INSERT INTO group_members (user_id, group_id, created_at) VALUES (?, ?, datetime('now'))",
+		userID, groupID,
+	)
+	if err != nil {
+		log.Printf("Error adding user to group: %v", err)
+		return false, serviceErrorf(errInternal, "failed to add user to group: %v", err)
+	}
+
+	return true, nil
+}
+
+// RemoveMember tar bort en användare från en grupp
+func (s *groupService) RemoveMember(ctx context.Context, userID string, groupID string) (bool, error) {
+	logAction(fmt.Sprintf("Removing user %s from group %s", userID, groupID))
+
+	// Only administrators can manage group memberships
+	currentUserID, err := getUserIDFromContext(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	// Check if the user is an administrator
+	var isAdmin bool
+	err = s.db.QueryRow(`
+		SELECT EXISTS(
+			SELECT 1 
+			FROM group_members gm
+			JOIN groups g ON gm.group_id = g.id
+			WHERE gm.user_id = ? AND g.name = 'Administrators'
+		)
+	`, currentUserID).Scan(&isAdmin)
+
+	if err != nil {
+		log.Printf("Error checking admin status: %v", err)
+		return false, serviceErrorf(errInternal, "failed to check administrator status: %v", err)
+	}
+
+	if !isAdmin {
+		return false, serviceErrorf(errPermissionDenied, "permission denied: must be an administrator to manage group memberships")
+	}
+
+	// Special check: if this is the Administrators group, make sure we're not removing the last admin
+	var groupName string
+	err = s.db.QueryRow("SELECT name FROM groups WHERE id = ?", groupID).Scan(&groupName)
+	if err != nil {
+		log.Printf("Error fetching group name: %v", err)
+		return false, serviceErrorf(errInternal, "failed to fetch group name: %v", err)
+	}
+
+	if groupName == "Administrators" {
+		// Count admins
+		var adminCount int
+		err = s.db.QueryRow("SELECT COUNT(*) FROM group_members WHERE group_id = ?", groupID).Scan(&adminCount)
+		if err != nil {
+			log.Printf("Error counting administrators: %v", err)
+			return false, serviceErrorf(errInternal, "failed to count administrators: %v", err)
+		}
+
+		if adminCount <= 1 {
+			// Check if this is the last admin
+			var isLastAdmin bool
+			err = s.db.QueryRow("SELECT EXISTS(SELECT 1 FROM group_members WHERE group_id = ? AND user_id = ?)", groupID, userID).Scan(&isLastAdmin)
+			if err != nil {
+				log.Printf("Error checking if user is admin: %v", err)
+				return false, serviceErrorf(errInternal, "failed to check if user is admin: %v", err)
+			}
+
+			if isLastAdmin {
+				return false, serviceErrorf(errConflict, "cannot remove the last administrator")
+			}
+		}
+	}
+
+	// Remove user from group
+	result, err := s.db.Exec(
+		"DELETE FROM group_members WHERE user_id = ? AND group_id = ?",
+		userID, groupID,
+	)
+	if err != nil {
+		log.Printf("Error removing user from group: %v", err)
+		return false, serviceErrorf(errInternal, "failed to remove user from group: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		log.Printf("Error getting rows affected: %v", err)
+		return false, fmt.Errorf("error confirming removal: %v", err)
+	}
+
+	if rowsAffected == 0 {
+		return false, fmt.Errorf("user is not a member of the group")
+	}
+
+	return true, nil
+}
@@ -0,0 +1,101 @@
+package graph
+
+import (
+	"testing"
+)
+
+func TestGroupServiceMembership(t *testing.T) {
+	db := openTestDB(t)
+	groups := NewServices(db).Groups
+	ctx := testAdminContext(t)
+	bobID := insertTestUser(t, db, "bob")
+	bob := testUserContext(t, bobID, "bob")
+
+	group, err := groups.Create(ctx, "Arkivarier")
+	if err != nil {
+		t.Fatalf("create group: %v", err)
+	}
+	if _, err := groups.Create(ctx, "Arkivarier"); err == nil || err.Error() != "group name already exists" {
+		t.Errorf("create duplicate: err = %v, want group name already exists", err)
+	}
+
+	// Den som inte är medlem får inte se gruppen
+	if _, err := groups.Get(bob, group.ID); err == nil || err.Error() != "permission denied: can only view groups you are a member of" {
+		t.Errorf("get as non-member: err = %v", err)
+	}
+
+	if ok, err := groups.AddMember(ctx, bobID, group.ID); err != nil || !ok {
+		t.Fatalf("add member: %v, %v", ok, err)
+	}
+	members, err := groups.Members(ctx, group.ID)
+	if err != nil {
+		t.Fatalf("list members: %v", err)
+	}
+	if len(members) != 1 || members[0].ID != bobID {
+		t.Errorf("members = %v, want only bob", members)
+	}
+
+	mine, err := groups.ForCurrentUser(bob)
+	if err != nil {
+		t.Fatalf("groups for current user: %v", err)
+	}
+	if len(mine) != 1 || mine[0].ID != group.ID {
+		t.Errorf("bob's groups = %v, want only %s", mine, group.ID)
+	}
+	if _, err := groups.Get(bob, group.ID); err != nil {
+		t.Errorf("get as member: %v", err)
+	}
+
+	if ok, err := groups.RemoveMember(ctx, bobID, group.ID); err != nil || !ok {
+		t.Fatalf("remove member: %v, %v", ok, err)
+	}
+	if members, err := groups.Members(ctx, group.ID); err != nil || len(members) != 0 {
+		t.Errorf("members after removal = %d, %v, want none", len(members), err)
+	}
+}
+
+func TestGroupServiceAdministration(t *testing.T) {
+	db := openTestDB(t)
+	groups := NewServices(db).Groups
+	ctx := testAdminContext(t)
+	bobID := insertTestUser(t, db, "bob")
+
+	if _, err := groups.Create(testUserContext(t, bobID, "bob"), "Egen"); err == nil || err.Error() != "permission denied: must be an administrator to create groups" {
+		t.Errorf("create as non-admin: err = %v", err)
+	}
+
+	group, err := groups.Create(ctx, "Läsare")
+	if err != nil {
+		t.Fatalf("create group: %v", err)
+	}
+	renamed, err := groups.Update(ctx, group.ID, "Granskare")
+	if err != nil {
+		t.Fatalf("rename group: %v", err)
+	}
+	if renamed.Name != "Granskare" {
+		t.Errorf("name = %q, want Granskare", renamed.Name)
+	}
+
+	all, err := groups.List(ctx)
+	if err != nil {
+		t.Fatalf("list groups: %v", err)
+	}
+	if len(all) != 2 {
+		t.Errorf("groups = %d, want Administrators and Granskare", len(all))
+	}
+
+	// Administratörsgruppen skapas av databasskriptet och är skyddad
+	if _, err := groups.Update(ctx, "1", "Admins"); err == nil || err.Error() != "cannot rename the Administrators group" {
+		t.Errorf("rename Administrators: err = %v", err)
+	}
+	if _, err := groups.Delete(ctx, "1"); err == nil || err.Error() != "cannot delete the Administrators group" {
+		t.Errorf("delete Administrators: err = %v", err)
+	}
+
+	if ok, err := groups.Delete(ctx, group.ID); err != nil || !ok {
+		t.Fatalf("delete group: %v, %v", ok, err)
+	}
+	if _, err := groups.Delete(ctx, group.ID); err == nil || err.Error() != "group not found" {
+		t.Errorf("delete missing group: err = %v, want group not found", err)
+	}
+}
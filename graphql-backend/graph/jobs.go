@@ -0,0 +1,595 @@
+package graph
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"graphql-backend/graph/model"
+	"log"
+	"os"
+	"strconv"
+	"time"
+)
+
+// =============================================
+// ========== BAKGRUNDSJOBB ==================
+// =============================================
+
+// Jobbtyper som körs i bakgrunden
+const (
+	JOB_TYPE_NOARK_EXPORT = "NOARK_EXPORT"
+	JOB_TYPE_BAGIT_EXPORT = "BAGIT_EXPORT"
+	JOB_TYPE_BAGIT_IMPORT = "BAGIT_IMPORT"
+	JOB_TYPE_AIP_EXPORT   = "AIP_EXPORT"
+	JOB_TYPE_DIP_EXPORT   = "DIP_EXPORT"
+	JOB_TYPE_BULK_IMPORT  = "BULK_IMPORT"
+	JOB_TYPE_ZIP_EXPORT   = "ZIP_EXPORT"
+	JOB_TYPE_FIXITY_CHECK = "FIXITY_CHECK"
+)
+
+// Fördröjning före första omförsöket; fördubblas för varje misslyckat försök upp till maxvärdet
+const (
+	jobRetryBaseDelay = 30 * time.Second
+	jobRetryMaxDelay  = 30 * time.Minute
+)
+
+// Hur ofta arbetarna letar efter köade jobb när ingen väcker dem
+const jobPollInterval = time.Second
+
+// Hur ofta servern förnyar pulsen på sina körande jobb, och hur gammal pulsen får bli innan ett
+// jobb räknas som avbrutet och återupptas av en annan server
+const (
+	jobHeartbeatInterval = 30 * time.Second
+	jobHeartbeatTimeout  = 2 * time.Minute
+)
+
+// jobWorkerID identifierar den här serverns arbetare på jobben de kör. Flera servrar kan dela en
+// PostgreSQL-databas och skiljs åt av värdnamn, process och starttid.
+var jobWorkerID = newJobWorkerID()
+
+func newJobWorkerID() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%s/%d/%d", host, os.Getpid(), time.Now().UnixNano())
+}
+
+// errJobCancelled returneras av jobb som avbrutits med cancelJob
+var errJobCancelled = errors.New("job was cancelled")
+
+// jobHandler beskriver hur en jobbtyp körs. Jobb som inte tål att köras om efter ett avbrott
+// har maxAttempts 1 eller gör själva sitt arbete idempotent. Jobb som lämnar ut innehåll ur
+// arkivet har en auditAction, och varje försök skrivs då till revisionsloggen som en nedladdning.
+type jobHandler struct {
+	run         jobFunc
+	maxAttempts int
+	auditAction string
+}
+
+// jobHandlers kopplar varje jobbtyp till funktionen som utför den. Jobbets parametrar sparas
+// som JSON i databasen, så att köade och avbrutna jobb kan köras efter en omstart.
+var jobHandlers = map[string]jobHandler{
+	JOB_TYPE_NOARK_EXPORT: {run: runNoarkExportJob, maxAttempts: 3, auditAction: "exportNoark"},
+	JOB_TYPE_BAGIT_EXPORT: {run: runBagExportJob, maxAttempts: 3, auditAction: "exportBag"},
+	JOB_TYPE_BAGIT_IMPORT: {run: runBagImportJob, maxAttempts: 3},
+	JOB_TYPE_AIP_EXPORT:   {run: runInformationPackageJob, maxAttempts: 3, auditAction: "exportAip"},
+	JOB_TYPE_DIP_EXPORT:   {run: runInformationPackageJob, maxAttempts: 3, auditAction: "exportDip"},
+	JOB_TYPE_BULK_IMPORT:  {run: runBulkImportJob, maxAttempts: 3},
+	JOB_TYPE_ZIP_EXPORT:   {run: runZipExportJob, maxAttempts: 3, auditAction: "exportZip"},
+	JOB_TYPE_FIXITY_CHECK: {run: runFixityCheckJob, maxAttempts: 3},
+}
+
+// jobUserKey är nyckeln för användaren som ett bakgrundsjobb körs som
+type jobUserKey struct{}
+
+// withJobUser lägger användaren som startade ett jobb i context. Värdet kan bara sättas inom
+// paketet och gäller i stället för en token, som inte finns när jobbet körs.
+func withJobUser(ctx context.Context, userID string) context.Context {
+	return context.WithValue(ctx, jobUserKey{}, userID)
+}
+
+// jobWake väcker en ledig arbetare när ett jobb läggs i kön
+var jobWake = make(chan struct{}, 1)
+
+// jobContext ger ett körande jobb tillgång till sina parametrar och möjlighet att rapportera
+// sina framsteg och upptäcka att det avbrutits
+type jobContext struct {
+	db        *sql.DB
+	id        string
+	jobType   string
+	userID    string
+	attempt   int
+	payload   string
+	inputData []byte
+	cancelled bool
+}
+
+// jobFunc är funktionen som utför själva arbetet i ett jobb.
+// Returnerar en resultatsträng som sparas på jobbet när det lyckas.
+type jobFunc func(job *jobContext) (string, error)
+
+// setProgress uppdaterar jobbets framsteg (0-100) och statusmeddelande.
+// Ett nil-jobb ignoreras så att samma kod kan köras direkt från kommandoraden.
+func (j *jobContext) setProgress(progress int, message string) {
+	if j == nil {
+		return
+	}
+
+	if progress < 0 {
+		progress = 0
+	} else if progress > 100 {
+		progress = 100
+	}
+
+	_, err := j.db.Exec(
+		"UPDATE jobs SET progress = ?, message = ?, updated_at = ? WHERE id = ?",
+		progress, message, time.Now().Format(time.RFC3339), j.id,
+	)
+	if err != nil {
+		log.Printf("Error updating progress for job %s: %v", j.id, err)
+		return
+	}
+	publishJobUpdate(j.id)
+}
+
+// err returnerar errJobCancelled om jobbet har avbrutits. Jobb anropar den mellan varje fil så att
+// ett avbrott får verkan direkt. Ett nil-jobb avbryts aldrig.
+func (j *jobContext) err() error {
+	if j == nil {
+		return nil
+	}
+	if !j.cancelled {
+		if err := j.db.QueryRow("SELECT cancel_requested FROM jobs WHERE id = ?", j.id).Scan(&j.cancelled); err != nil {
+			log.Printf("Error checking cancellation of job %s: %v", j.id, err)
+		}
+	}
+	if j.cancelled {
+		return errJobCancelled
+	}
+	return nil
+}
+
+// decodePayload läser jobbets parametrar
+func (j *jobContext) decodePayload(v interface{}) error {
+	if err := json.Unmarshal([]byte(j.payload), v); err != nil {
+		return fmt.Errorf("invalid job parameters: %v", err)
+	}
+	return nil
+}
+
+// userContext ger ett context där jobbet agerar som användaren som startade det, för
+// behörighetskontroller och åtkomstloggning
+func (j *jobContext) userContext(clientIP string, userAgent string) context.Context {
+	ctx := withJobUser(context.Background(), j.userID)
+	ctx = WithClientIP(ctx, clientIP)
+	return WithUserAgent(ctx, userAgent)
+}
+
+// enqueueJob lägger ett jobb i kön med parametrarna som JSON. inputData är valfri binär indata,
+// t.ex. ett uppladdat arkiv, som tas bort när jobbet är klart.
+func enqueueJob(db *sql.DB, jobType string, userID string, payload interface{}, inputData []byte) (*model.Job, error) {
+	handler, ok := jobHandlers[jobType]
+	if !ok {
+		return nil, fmt.Errorf("unknown job type %s", jobType)
+	}
+
+	payloadJSON, err := json.Marshal(payload)
+	if err != nil {
+		return nil, serviceErrorf(errInternal, "failed to encode job parameters: %v", err)
+	}
+
+	now := time.Now().Format(time.RFC3339)
+	result, err := db.Exec(
+		"INSERT INTO jobs (type, status, progress, payload, input_data, max_attempts, created_by, created_at, updated_at) VALUES (?, ?, 0, ?, ?, ?, ?, ?, ?)",
+		jobType, model.JobStatusQueued, string(payloadJSON), inputData, handler.maxAttempts, nullIfEmpty(userID), now, now,
+	)
+	if err != nil {
+		log.Printf("Error creating job: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to create job: %v", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		log.Printf("Error retrieving last insert ID: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to retrieve job ID: %v", err)
+	}
+
+	jobID := strconv.FormatInt(id, 10)
+	logAction(fmt.Sprintf("Queued %s job with ID: %s", jobType, jobID))
+
+	select {
+	case jobWake <- struct{}{}:
+	default:
+	}
+
+	return getJob(db, jobID)
+}
+
+// StartJobWorkers återupptar jobb som avbröts när en server stoppades och startar arbetare
+// som kör köade jobb. Anropas en gång när servern startar.
+func StartJobWorkers(db *sql.DB, workers int) {
+	if err := recoverInterruptedJobs(db, time.Now()); err != nil {
+		log.Printf("Error recovering interrupted jobs: %v", err)
+	}
+	if workers < 1 {
+		workers = 1
+	}
+	for i := 0; i < workers; i++ {
+		go runJobWorker(db)
+	}
+	go runJobHeartbeat(db)
+	log.Printf("Started %d job workers as %s", workers, jobWorkerID)
+}
+
+// runJobHeartbeat förnyar pulsen på serverns körande jobb och återupptar jobb från servrar som
+// slutat svara
+func runJobHeartbeat(db *sql.DB) {
+	ticker := time.NewTicker(jobHeartbeatInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		now := time.Now()
+		if _, err := db.Exec(
+			"UPDATE jobs SET heartbeat_at = ? WHERE status = ? AND worker_id = ?",
+			now.UTC().Format(time.RFC3339), model.JobStatusRunning, jobWorkerID,
+		); err != nil {
+			log.Printf("Error renewing job heartbeats: %v", err)
+		}
+		if err := recoverInterruptedJobs(db, now); err != nil {
+			log.Printf("Error recovering interrupted jobs: %v", err)
+		}
+	}
+}
+
+// recoverInterruptedJobs lägger tillbaka körande jobb vars puls är äldre än jobHeartbeatTimeout i
+// kön, eller markerar dem som misslyckade om de inte har fler försök kvar. Jobb som en annan
+// server fortfarande kör har en färsk puls och lämnas orörda.
+func recoverInterruptedJobs(db *sql.DB, now time.Time) error {
+	updatedAt := now.Format(time.RFC3339)
+	stale := "status = ? AND (heartbeat_at IS NULL OR heartbeat_at < ?)"
+	staleArgs := []interface{}{model.JobStatusRunning, now.Add(-jobHeartbeatTimeout).UTC().Format(time.RFC3339)}
+
+	_, err := db.Exec(
+		"UPDATE jobs SET status = ?, message = ?, updated_at = ?, finished_at = ?, input_data = NULL WHERE "+stale+" AND cancel_requested = 1",
+		append([]interface{}{model.JobStatusCancelled, "Cancelled", updatedAt, updatedAt}, staleArgs...)...,
+	)
+	if err != nil {
+		return serviceErrorf(errInternal, "failed to cancel interrupted jobs: %v", err)
+	}
+
+	_, err = db.Exec(
+		"UPDATE jobs SET status = ?, error = ?, updated_at = ?, finished_at = ?, input_data = NULL WHERE "+stale+" AND attempts >= max_attempts",
+		append([]interface{}{model.JobStatusFailed, "interrupted when its server stopped", updatedAt, updatedAt}, staleArgs...)...,
+	)
+	if err != nil {
+		return serviceErrorf(errInternal, "failed to fail interrupted jobs: %v", err)
+	}
+
+	result, err := db.Exec(
+		"UPDATE jobs SET status = ?, message = ?, run_after = NULL, worker_id = NULL, heartbeat_at = NULL, updated_at = ? WHERE "+stale,
+		append([]interface{}{model.JobStatusQueued, "Interrupted when its server stopped, waiting to resume", updatedAt}, staleArgs...)...,
+	)
+	if err != nil {
+		return serviceErrorf(errInternal, "failed to requeue interrupted jobs: %v", err)
+	}
+	if count, _ := result.RowsAffected(); count > 0 {
+		log.Printf("Requeued %d jobs interrupted when their server stopped", count)
+	}
+	return nil
+}
+
+// runJobWorker hämtar och kör köade jobb ett i taget
+func runJobWorker(db *sql.DB) {
+	ticker := time.NewTicker(jobPollInterval)
+	defer ticker.Stop()
+
+	for {
+		job, err := claimJob(db)
+		if err != nil {
+			log.Printf("Error claiming job: %v", err)
+		}
+		if job != nil {
+			executeJob(job)
+			continue
+		}
+
+		select {
+		case <-jobWake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// claimJob markerar det äldsta köade jobbet som är redo att köras som körande och returnerar det,
+// eller nil om kön är tom. Villkoret på status gör att två arbetare aldrig tar samma jobb.
+func claimJob(db *sql.DB) (*jobContext, error) {
+	now := time.Now()
+	job := &jobContext{db: db}
+	var userID sql.NullString
+	var payload sql.NullString
+
+	err := db.QueryRow(`
+		UPDATE jobs SET status = ?, attempts = attempts + 1, worker_id = ?, heartbeat_at = ?, started_at = ?, updated_at = ?
+		WHERE id = (
+			SELECT id FROM jobs
+			WHERE status = ? AND (run_after IS NULL OR run_after <= ?)
+			ORDER BY id ASC LIMIT 1
+		) AND status = ?
+		RETURNING id, type, created_by, attempts, payload, input_data, cancel_requested
+	`, model.JobStatusRunning, jobWorkerID, now.UTC().Format(time.RFC3339), now.Format(time.RFC3339), now.Format(time.RFC3339),
+		model.JobStatusQueued, now.UTC().Format(time.RFC3339), model.JobStatusQueued,
+	).Scan(&job.id, &job.jobType, &userID, &job.attempt, &payload, &job.inputData, &job.cancelled)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, serviceErrorf(errInternal, "failed to claim job: %v", err)
+	}
+
+	job.userID = userID.String
+	job.payload = payload.String
+	publishJobUpdate(job.id)
+	return job, nil
+}
+
+// executeJob kör jobbfunktionen och sparar slutstatus, resultat eller fel. Ett misslyckat jobb
+// läggs tillbaka i kön med en fördröjning som fördubblas för varje försök tills försöken är slut.
+func executeJob(job *jobContext) {
+	handler, ok := jobHandlers[job.jobType]
+	if !ok {
+		finishJob(job, model.JobStatusFailed, "", fmt.Errorf("unknown job type %s", job.jobType))
+		return
+	}
+
+	log.Printf("Running job %s (%s), attempt %d", job.id, job.jobType, job.attempt)
+	output, runErr := runJobSafely(job, handler.run)
+	if handler.auditAction != "" {
+		recordJobAudit(job, handler.auditAction, output, runErr)
+	}
+
+	var maxAttempts int
+	if err := job.db.QueryRow("SELECT max_attempts FROM jobs WHERE id = ?", job.id).Scan(&maxAttempts); err != nil {
+		log.Printf("Error fetching max attempts for job %s: %v", job.id, err)
+	}
+
+	switch {
+	case runErr == nil:
+		finishJob(job, model.JobStatusSucceeded, output, nil)
+	case errors.Is(runErr, errJobCancelled) || job.err() != nil:
+		finishJob(job, model.JobStatusCancelled, "", nil)
+	case job.attempt < maxAttempts:
+		retryJob(job, maxAttempts, runErr)
+	default:
+		finishJob(job, model.JobStatusFailed, "", runErr)
+	}
+}
+
+// recordJobAudit skriver ett försök av ett exportjobb till revisionsloggen, med användaren som
+// startade jobbet som aktör och jobbets resultat, med sökvägarna till exporten, som efter-värde.
+// Även misslyckade och avbrutna försök loggas, eftersom en del av exporten kan ha skrivits.
+func recordJobAudit(job *jobContext, action string, output string, runErr error) {
+	var payload struct {
+		NodeID   string `json:"nodeId"`
+		ClientIP string `json:"clientIp"`
+	}
+	if err := job.decodePayload(&payload); err != nil {
+		log.Printf("Error reading parameters of job %s for audit log: %v", job.id, err)
+	}
+
+	entry := AuditEntry{
+		ActorID:    job.userID,
+		Action:     action,
+		TargetType: "node",
+		TargetID:   payload.NodeID,
+		Arguments:  fmt.Sprintf(`{"jobId":%q,"nodeId":%q,"attempt":%d}`, job.id, payload.NodeID, job.attempt),
+		After:      output,
+		ClientIP:   payload.ClientIP,
+		Outcome:    AUDIT_OUTCOME_SUCCESS,
+	}
+	if job.userID != "" {
+		entry.Actor, _ = lookupUsername(job.db, job.userID)
+	}
+	if runErr != nil {
+		entry.Outcome = AUDIT_OUTCOME_FAILURE
+		entry.Error = runErr.Error()
+	}
+	if err := RecordAuditEvent(job.db, entry); err != nil {
+		log.Printf("Error recording audit event for job %s: %v", job.id, err)
+	}
+}
+
+// runJobSafely kör jobbfunktionen och gör om en panik till ett fel, så att arbetaren överlever
+func runJobSafely(job *jobContext, run jobFunc) (output string, err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			log.Printf("Job %s panicked: %v", job.id, recovered)
+			err = fmt.Errorf("job panicked: %v", recovered)
+		}
+	}()
+	return run(job)
+}
+
+// retryJob lägger tillbaka ett misslyckat jobb i kön efter en fördröjning
+func retryJob(job *jobContext, maxAttempts int, runErr error) {
+	delay := jobRetryBaseDelay << (job.attempt - 1)
+	if delay > jobRetryMaxDelay || delay <= 0 {
+		delay = jobRetryMaxDelay
+	}
+	runAfter := time.Now().Add(delay)
+
+	log.Printf("Job %s failed on attempt %d of %d, retrying in %s: %v", job.id, job.attempt, maxAttempts, delay, runErr)
+	_, err := job.db.Exec(
+		"UPDATE jobs SET status = ?, error = ?, message = ?, run_after = ?, updated_at = ? WHERE id = ?",
+		model.JobStatusQueued, runErr.Error(), fmt.Sprintf("Attempt %d of %d failed, retrying at %s", job.attempt, maxAttempts, runAfter.Format(time.RFC3339)),
+		runAfter.UTC().Format(time.RFC3339), time.Now().Format(time.RFC3339), job.id,
+	)
+	if err != nil {
+		log.Printf("Error requeueing job %s: %v", job.id, err)
+		return
+	}
+	publishJobUpdate(job.id)
+}
+
+// finishJob sparar jobbets slutstatus och tar bort indata som inte längre behövs
+func finishJob(job *jobContext, status model.JobStatus, output string, runErr error) {
+	now := time.Now().Format(time.RFC3339)
+	var err error
+
+	switch status {
+	case model.JobStatusSucceeded:
+		log.Printf("Job %s finished successfully", job.id)
+		_, err = job.db.Exec(
+			"UPDATE jobs SET status = ?, progress = 100, result = ?, error = NULL, input_data = NULL, updated_at = ?, finished_at = ? WHERE id = ?",
+			status, output, now, now, job.id,
+		)
+	case model.JobStatusCancelled:
+		log.Printf("Job %s was cancelled", job.id)
+		_, err = job.db.Exec(
+			"UPDATE jobs SET status = ?, message = ?, input_data = NULL, updated_at = ?, finished_at = ? WHERE id = ?",
+			status, "Cancelled", now, now, job.id,
+		)
+	default:
+		log.Printf("Job %s failed: %v", job.id, runErr)
+		_, err = job.db.Exec(
+			"UPDATE jobs SET status = ?, error = ?, input_data = NULL, updated_at = ?, finished_at = ? WHERE id = ?",
+			status, runErr.Error(), now, now, job.id,
+		)
+	}
+
+	if err != nil {
+		log.Printf("Error saving final status for job %s: %v", job.id, err)
+		return
+	}
+	publishJobUpdate(job.id)
+}
+
+// cancelJob avbryter ett jobb. Ett köat jobb avbryts direkt; ett körande jobb får en flagga som
+// det läser mellan varje fil och avslutas sedan som avbrutet.
+func cancelJob(db *sql.DB, jobID string) error {
+	now := time.Now().Format(time.RFC3339)
+
+	result, err := db.Exec(
+		"UPDATE jobs SET status = ?, cancel_requested = 1, message = ?, input_data = NULL, updated_at = ?, finished_at = ? WHERE id = ? AND status = ?",
+		model.JobStatusCancelled, "Cancelled", now, now, jobID, model.JobStatusQueued,
+	)
+	if err != nil {
+		log.Printf("Error cancelling job %s: %v", jobID, err)
+		return serviceErrorf(errInternal, "failed to cancel job: %v", err)
+	}
+	if count, _ := result.RowsAffected(); count > 0 {
+		logAction(fmt.Sprintf("Cancelled queued job %s", jobID))
+		publishJobUpdate(jobID)
+		return nil
+	}
+
+	result, err = db.Exec(
+		"UPDATE jobs SET cancel_requested = 1, message = ?, updated_at = ? WHERE id = ? AND status = ?",
+		"Cancelling", now, jobID, model.JobStatusRunning,
+	)
+	if err != nil {
+		log.Printf("Error cancelling job %s: %v", jobID, err)
+		return serviceErrorf(errInternal, "failed to cancel job: %v", err)
+	}
+	if count, _ := result.RowsAffected(); count == 0 {
+		return fmt.Errorf("job has already finished")
+	}
+
+	logAction(fmt.Sprintf("Requested cancellation of running job %s", jobID))
+	publishJobUpdate(jobID)
+	return nil
+}
+
+// getJob hämtar ett jobb från databasen baserat på ID
+func getJob(db *sql.DB, id string) (*model.Job, error) {
+	jobs, err := queryJobs(db, "id = ?", 1, 0, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(jobs) == 0 {
+		return nil, serviceErrorf(errNotFound, "job not found")
+	}
+	return jobs[0], nil
+}
+
+// queryJobs hämtar jobb som matchar ett villkor, nyaste först
+func queryJobs(db *sql.DB, where string, limit int, offset int, args ...interface{}) ([]*model.Job, error) {
+	rows, err := db.Query(`
+		SELECT id, type, status, progress, message, result, error, attempts, max_attempts, run_after,
+			cancel_requested, created_by, created_at, updated_at, started_at, finished_at
+		FROM jobs
+		WHERE `+where+`
+		ORDER BY id DESC
+		LIMIT ? OFFSET ?
+	`, append(args, limit, offset)...)
+	if err != nil {
+		log.Printf("Error fetching jobs: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to fetch jobs: %v", err)
+	}
+	defer rows.Close()
+
+	jobs := []*model.Job{}
+	for rows.Next() {
+		var job model.Job
+		var status string
+		var message, result, jobErr, runAfter, createdBy, startedAt, finishedAt sql.NullString
+		err := rows.Scan(&job.ID, &job.Type, &status, &job.Progress, &message, &result, &jobErr,
+			&job.Attempts, &job.MaxAttempts, &runAfter, &job.CancelRequested,
+			&createdBy, &job.CreatedAt, &job.UpdatedAt, &startedAt, &finishedAt)
+		if err != nil {
+			log.Printf("Error scanning job row: %v", err)
+			return nil, serviceErrorf(errInternal, "failed to scan job row: %v", err)
+		}
+
+		job.Status = model.JobStatus(status)
+		job.Message = nullStringPtr(message)
+		job.Result = nullStringPtr(result)
+		job.Error = nullStringPtr(jobErr)
+		job.RunAfter = nullStringPtr(runAfter)
+		job.CreatedBy = nullStringPtr(createdBy)
+		job.StartedAt = nullStringPtr(startedAt)
+		job.FinishedAt = nullStringPtr(finishedAt)
+		jobs = append(jobs, &job)
+	}
+
+	return jobs, rows.Err()
+}
+
+// getJobForUser hämtar ett jobb om användaren har skapat det eller är administratör
+func getJobForUser(ctx context.Context, db *sql.DB, id string) (*model.Job, error) {
+	userID, err := getUserIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	job, err := getJob(db, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if job.CreatedBy != nil && *job.CreatedBy == userID {
+		return job, nil
+	}
+
+	isAdmin, err := isAdministrator(db, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !isAdmin {
+		return nil, serviceErrorf(errPermissionDenied, "permission denied: cannot view this job")
+	}
+
+	return job, nil
+}
+
+// nullStringPtr konverterar en sql.NullString till en strängpekare (nil om värdet saknas)
+func nullStringPtr(s sql.NullString) *string {
+	if !s.Valid {
+		return nil
+	}
+	value := s.String
+	return &value
+}
@@ -0,0 +1,94 @@
+package graph
+
+// This file will be automatically regenerated based on the schema, any resolver implementations
+// will be copied through when generating and any unknown code will be moved to the end.
+// Code generated by github.com/99designs/gqlgen version v0.17.69
+
+import (
+	"context"
+	"fmt"
+	"graphql-backend/graph/model"
+	"log"
+)
+
+// CancelJob is the resolver for the cancelJob field.
+func (r *mutationResolver) CancelJob(ctx context.Context, id string) (*model.Job, error) {
+	logAction(fmt.Sprintf("Cancelling job with ID: %s", id))
+
+	if r.DB == nil {
+		log.Printf("Database connection is nil")
+		return nil, serviceErrorf(errInternal, "internal server error: database connection is not initialized")
+	}
+
+	// Samma regler som för att se jobbet: den som startade det eller en administratör
+	if _, err := getJobForUser(ctx, r.DB, id); err != nil {
+		return nil, err
+	}
+
+	if err := cancelJob(r.DB, id); err != nil {
+		return nil, err
+	}
+
+	return getJob(r.DB, id)
+}
+
+// Job is the resolver for the job field.
+func (r *queryResolver) Job(ctx context.Context, id string) (*model.Job, error) {
+	logAction(fmt.Sprintf("Fetching job with ID: %s", id))
+
+	if r.DB == nil {
+		return nil, serviceErrorf(errInternal, "database connection is not initialized")
+	}
+
+	return getJobForUser(ctx, r.DB, id)
+}
+
+// Jobs is the resolver for the jobs field.
+func (r *queryResolver) Jobs(ctx context.Context, status *model.JobStatus, typeArg *string, limit *int, offset *int) ([]*model.Job, error) {
+	logAction("Fetching jobs")
+
+	if r.DB == nil {
+		log.Printf("Database connection is nil")
+		return nil, serviceErrorf(errInternal, "internal server error: database connection is not initialized")
+	}
+
+	userID, err := getUserIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	isAdmin, err := isAdministrator(r.DB, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	where := "1 = 1"
+	var args []interface{}
+	if !isAdmin {
+		where += " AND created_by = ?"
+		args = append(args, userID)
+	}
+	if status != nil {
+		where += " AND status = ?"
+		args = append(args, *status)
+	}
+	if typeArg != nil {
+		where += " AND type = ?"
+		args = append(args, *typeArg)
+	}
+
+	pageSize, skip := 100, 0
+	if limit != nil && *limit > 0 {
+		pageSize = *limit
+	}
+	if offset != nil && *offset > 0 {
+		skip = *offset
+	}
+
+	return queryJobs(r.DB, where, pageSize, skip, args...)
+}
+
+// Job returns JobResolver implementation.
+func (r *Resolver) Job() JobResolver { return &jobResolver{r} }
+
+type jobResolver struct{ *Resolver }
@@ -0,0 +1,345 @@
+package graph
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"graphql-backend/graph/model"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// insertTestJob lägger in ett jobb direkt i tabellen. heartbeatAt är nil för jobb utan puls.
+func insertTestJob(t *testing.T, db *sql.DB, status model.JobStatus, attempts int, maxAttempts int, workerID string, heartbeatAt *time.Time) string {
+	t.Helper()
+	now := time.Now().Format(time.RFC3339)
+	var heartbeat interface{}
+	if heartbeatAt != nil {
+		heartbeat = heartbeatAt.UTC().Format(time.RFC3339)
+	}
+	result, err := db.Exec(
+		"INSERT INTO jobs (type, status, payload, attempts, max_attempts, worker_id, heartbeat_at, created_at, updated_at) VALUES (?, ?, '{}', ?, ?, ?, ?, ?, ?)",
+		JOB_TYPE_FIXITY_CHECK, status, attempts, maxAttempts, nullIfEmpty(workerID), heartbeat, now, now,
+	)
+	if err != nil {
+		t.Fatalf("insert job: %v", err)
+	}
+	id, _ := result.LastInsertId()
+	return strconv.FormatInt(id, 10)
+}
+
+func testJobStatus(t *testing.T, db *sql.DB, id string) model.JobStatus {
+	t.Helper()
+	job, err := getJob(db, id)
+	if err != nil {
+		t.Fatalf("get job %s: %v", id, err)
+	}
+	return job.Status
+}
+
+func TestRecoverInterruptedJobsLeavesLivePeers(t *testing.T) {
+	db := openTestDB(t)
+	now := time.Now()
+	fresh := now.Add(-jobHeartbeatInterval)
+	stale := now.Add(-jobHeartbeatTimeout - time.Minute)
+
+	live := insertTestJob(t, db, model.JobStatusRunning, 1, 3, "annan-server/1/1", &fresh)
+	stopped := insertTestJob(t, db, model.JobStatusRunning, 1, 3, "stoppad-server/1/1", &stale)
+	withoutHeartbeat := insertTestJob(t, db, model.JobStatusRunning, 1, 3, "", nil)
+	exhausted := insertTestJob(t, db, model.JobStatusRunning, 3, 3, "stoppad-server/1/1", &stale)
+
+	if err := recoverInterruptedJobs(db, now); err != nil {
+		t.Fatalf("recover interrupted jobs: %v", err)
+	}
+
+	want := map[string]model.JobStatus{
+		live:             model.JobStatusRunning,
+		stopped:          model.JobStatusQueued,
+		withoutHeartbeat: model.JobStatusQueued,
+		exhausted:        model.JobStatusFailed,
+	}
+	for id, status := range want {
+		if got := testJobStatus(t, db, id); got != status {
+			t.Errorf("job %s = %s, want %s", id, got, status)
+		}
+	}
+}
+
+func TestClaimJobMarksWorker(t *testing.T) {
+	db := openTestDB(t)
+	id := insertTestJob(t, db, model.JobStatusQueued, 0, 3, "", nil)
+
+	job, err := claimJob(db)
+	if err != nil || job == nil || job.id != id {
+		t.Fatalf("claim job = %v, %v, want job %s", job, err, id)
+	}
+
+	var workerID, heartbeatAt sql.NullString
+	if err := db.QueryRow("SELECT worker_id, heartbeat_at FROM jobs WHERE id = ?", id).Scan(&workerID, &heartbeatAt); err != nil {
+		t.Fatalf("fetch job: %v", err)
+	}
+	if workerID.String != jobWorkerID || !heartbeatAt.Valid {
+		t.Errorf("worker = %q, heartbeat = %q, want this server with a heartbeat", workerID.String, heartbeatAt.String)
+	}
+
+	// Ett nyss taget jobb återupptas inte av en server som startar under tiden
+	if err := recoverInterruptedJobs(db, time.Now()); err != nil {
+		t.Fatalf("recover interrupted jobs: %v", err)
+	}
+	if got := testJobStatus(t, db, id); got != model.JobStatusRunning {
+		t.Errorf("claimed job = %s, want RUNNING", got)
+	}
+}
+
+// runQueuedTestJob tar nästa köade jobb och kör det som en arbetare gör
+func runQueuedTestJob(t *testing.T, db *sql.DB) *model.Job {
+	t.Helper()
+	job, err := claimJob(db)
+	if err != nil || job == nil {
+		t.Fatalf("claim job = %v, %v, want a queued job", job, err)
+	}
+	executeJob(job)
+	result, err := getJob(db, job.id)
+	if err != nil {
+		t.Fatalf("get job %s: %v", job.id, err)
+	}
+	return result
+}
+
+// testJobType är en jobbtyp som bara finns i testerna
+const testJobType = "TEST_JOB"
+
+// registerTestJob kopplar testJobType till run under testet
+func registerTestJob(t *testing.T, run jobFunc, maxAttempts int) {
+	t.Helper()
+	jobHandlers[testJobType] = jobHandler{run: run, maxAttempts: maxAttempts}
+	t.Cleanup(func() { delete(jobHandlers, testJobType) })
+}
+
+// jobInputData hämtar jobbets indata, som ska tas bort när jobbet är klart
+func jobInputData(t *testing.T, db *sql.DB, id string) []byte {
+	t.Helper()
+	var data []byte
+	if err := db.QueryRow("SELECT input_data FROM jobs WHERE id = ?", id).Scan(&data); err != nil {
+		t.Fatalf("fetch input data of job %s: %v", id, err)
+	}
+	return data
+}
+
+// assertRetryDelay kontrollerar att ett köat jobb väntar ungefär delay innan nästa försök
+func assertRetryDelay(t *testing.T, job *model.Job, delay time.Duration) {
+	t.Helper()
+	if job.RunAfter == nil {
+		t.Fatalf("job %s has no runAfter", job.ID)
+	}
+	runAfter, err := time.Parse(time.RFC3339, *job.RunAfter)
+	if err != nil {
+		t.Fatalf("parse runAfter %q: %v", *job.RunAfter, err)
+	}
+	if wait := time.Until(runAfter); wait < delay-5*time.Second || wait > delay+time.Second {
+		t.Errorf("retry in %s, want about %s", wait.Round(time.Second), delay)
+	}
+}
+
+func TestJobSucceeds(t *testing.T) {
+	db := openTestDB(t)
+	registerTestJob(t, func(job *jobContext) (string, error) {
+		var payload struct{ Name string }
+		if err := job.decodePayload(&payload); err != nil {
+			return "", err
+		}
+		job.setProgress(50, "Halvvägs")
+		return "klart: " + payload.Name, nil
+	}, 3)
+
+	queued, err := enqueueJob(db, testJobType, "1", map[string]string{"Name": "arkiv"}, []byte("indata"))
+	if err != nil {
+		t.Fatalf("enqueue job: %v", err)
+	}
+	if queued.Status != model.JobStatusQueued || queued.MaxAttempts != 3 || queued.CreatedBy == nil || *queued.CreatedBy != "1" {
+		t.Errorf("queued job = %+v", queued)
+	}
+
+	job := runQueuedTestJob(t, db)
+	if job.Status != model.JobStatusSucceeded || job.Progress != 100 || job.Result == nil || *job.Result != "klart: arkiv" || job.Attempts != 1 || job.FinishedAt == nil {
+		t.Errorf("finished job = %+v", job)
+	}
+	if data := jobInputData(t, db, job.ID); data != nil {
+		t.Errorf("input data = %q after the job finished", data)
+	}
+
+	if _, err := enqueueJob(db, "OKAND", "1", nil, nil); err == nil || err.Error() != "unknown job type OKAND" {
+		t.Errorf("enqueue unknown type: err = %v", err)
+	}
+}
+
+func TestJobRetriesWithBackoff(t *testing.T) {
+	db := openTestDB(t)
+	registerTestJob(t, func(job *jobContext) (string, error) {
+		return "", fmt.Errorf("disk full on attempt %d", job.attempt)
+	}, 3)
+
+	queued, err := enqueueJob(db, testJobType, "1", nil, []byte("indata"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Fördröjningen fördubblas för varje misslyckat försök
+	for _, delay := range []time.Duration{jobRetryBaseDelay, 2 * jobRetryBaseDelay} {
+		job := runQueuedTestJob(t, db)
+		if job.Status != model.JobStatusQueued || job.Error == nil || *job.Error != fmt.Sprintf("disk full on attempt %d", job.Attempts) {
+			t.Fatalf("job after attempt %d = %s (%v), want QUEUED with the error", job.Attempts, job.Status, job.Error)
+		}
+		assertRetryDelay(t, job, delay)
+		// Jobbet körs inte igen förrän fördröjningen har gått
+		if next, err := claimJob(db); err != nil || next != nil {
+			t.Fatalf("claimed job %v, %v before its retry time", next, err)
+		}
+		mustExec(t, db, "UPDATE jobs SET run_after = ? WHERE id = ?", time.Now().Add(-time.Second).UTC().Format(time.RFC3339), queued.ID)
+	}
+
+	job := runQueuedTestJob(t, db)
+	if job.Status != model.JobStatusFailed || job.Attempts != 3 || job.Error == nil || *job.Error != "disk full on attempt 3" || job.FinishedAt == nil {
+		t.Errorf("job after the last attempt = %+v", job)
+	}
+	if data := jobInputData(t, db, job.ID); data != nil {
+		t.Errorf("input data = %q after the job failed", data)
+	}
+}
+
+func TestRetryDelayIsCapped(t *testing.T) {
+	db := openTestDB(t)
+	id := insertTestJob(t, db, model.JobStatusRunning, 12, 20, jobWorkerID, nil)
+
+	retryJob(&jobContext{db: db, id: id, attempt: 12}, 20, errors.New("timeout"))
+	job, err := getJob(db, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.Status != model.JobStatusQueued || job.Message == nil || !strings.HasPrefix(*job.Message, "Attempt 12 of 20 failed") {
+		t.Errorf("job = %s, %v", job.Status, job.Message)
+	}
+	assertRetryDelay(t, job, jobRetryMaxDelay)
+}
+
+func TestJobPanicFailsJob(t *testing.T) {
+	db := openTestDB(t)
+	registerTestJob(t, func(job *jobContext) (string, error) {
+		panic("boom")
+	}, 1)
+
+	if _, err := enqueueJob(db, testJobType, "1", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	// Arbetaren överlever och jobbet misslyckas utan nya försök
+	job := runQueuedTestJob(t, db)
+	if job.Status != model.JobStatusFailed || job.Error == nil || *job.Error != "job panicked: boom" {
+		t.Errorf("job = %s (%v), want FAILED after the panic", job.Status, job.Error)
+	}
+}
+
+func TestCancelJob(t *testing.T) {
+	db := openTestDB(t)
+	admin := testAdminContext(t)
+	bobID := insertTestUser(t, db, "bob")
+	bob := testUserContext(t, bobID, "bob")
+	resolver := NewResolver(db)
+
+	var runs int
+	registerTestJob(t, func(job *jobContext) (string, error) {
+		runs++
+		job.setProgress(10, "Arbetar")
+		// Användaren avbryter medan jobbet kör. Felet som jobbet sedan returnerar ger inget nytt försök.
+		if err := cancelJob(job.db, job.id); err != nil {
+			return "", err
+		}
+		if err := job.err(); err != errJobCancelled {
+			return "", fmt.Errorf("cancellation not noticed: %v", err)
+		}
+		return "", errors.New("stopped halfway")
+	}, 3)
+
+	queued, err := enqueueJob(db, testJobType, "1", nil, []byte("indata"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := resolver.Mutation().CancelJob(bob, queued.ID); err == nil || err.Error() != "permission denied: cannot view this job" {
+		t.Errorf("cancel another user's job: err = %v", err)
+	}
+	cancelled, err := resolver.Mutation().CancelJob(admin, queued.ID)
+	if err != nil || cancelled.Status != model.JobStatusCancelled || !cancelled.CancelRequested {
+		t.Fatalf("cancel queued job = %+v, %v", cancelled, err)
+	}
+	if next, err := claimJob(db); err != nil || next != nil {
+		t.Errorf("claimed cancelled job %v, %v", next, err)
+	}
+	if data := jobInputData(t, db, queued.ID); data != nil {
+		t.Errorf("input data = %q after cancelling", data)
+	}
+	if _, err := resolver.Mutation().CancelJob(admin, queued.ID); err == nil || err.Error() != "job has already finished" {
+		t.Errorf("cancel finished job: err = %v", err)
+	}
+
+	running, err := enqueueJob(db, testJobType, bobID, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	job := runQueuedTestJob(t, db)
+	if job.ID != running.ID || job.Status != model.JobStatusCancelled || job.Error != nil || runs != 1 {
+		t.Errorf("job cancelled while running = %s (%v) after %d runs, want CANCELLED", job.Status, job.Error, runs)
+	}
+
+	// Ett avbrutet jobb vars server stoppades återupptas inte
+	stale := time.Now().Add(-jobHeartbeatTimeout - time.Minute)
+	interrupted := insertTestJob(t, db, model.JobStatusRunning, 1, 3, "stoppad-server/1/1", &stale)
+	mustExec(t, db, "UPDATE jobs SET cancel_requested = 1 WHERE id = ?", interrupted)
+	if err := recoverInterruptedJobs(db, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if got := testJobStatus(t, db, interrupted); got != model.JobStatusCancelled {
+		t.Errorf("interrupted job with cancellation = %s, want CANCELLED", got)
+	}
+}
+
+func TestJobQueries(t *testing.T) {
+	db := openTestDB(t)
+	admin := testAdminContext(t)
+	bobID := insertTestUser(t, db, "bob")
+	bob := testUserContext(t, bobID, "bob")
+	query := NewResolver(db).Query()
+	registerTestJob(t, func(job *jobContext) (string, error) { return "", nil }, 1)
+
+	adminJob, err := enqueueJob(db, testJobType, "1", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bobJob, err := enqueueJob(db, testJobType, bobID, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	runQueuedTestJob(t, db)
+
+	// Användare ser sina egna jobb, administratörer alla, nyaste först
+	if jobs, err := query.Jobs(bob, nil, nil, nil, nil); err != nil || len(jobs) != 1 || jobs[0].ID != bobJob.ID {
+		t.Errorf("bob's jobs = %v, %v", jobs, err)
+	}
+	if jobs, err := query.Jobs(admin, nil, nil, nil, nil); err != nil || len(jobs) != 2 || jobs[0].ID != bobJob.ID {
+		t.Errorf("all jobs = %v, %v", jobs, err)
+	}
+	succeeded := model.JobStatusSucceeded
+	if jobs, err := query.Jobs(admin, &succeeded, strPtr(testJobType), nil, nil); err != nil || len(jobs) != 1 || jobs[0].ID != adminJob.ID {
+		t.Errorf("succeeded jobs = %v, %v", jobs, err)
+	}
+
+	if job, err := query.Job(admin, bobJob.ID); err != nil || job.ID != bobJob.ID {
+		t.Errorf("admin gets bob's job = %v, %v", job, err)
+	}
+	if _, err := query.Job(bob, adminJob.ID); err == nil || err.Error() != "permission denied: cannot view this job" {
+		t.Errorf("bob gets admin's job: err = %v", err)
+	}
+	if _, err := query.Job(admin, "999"); err == nil || err.Error() != "job not found" {
+		t.Errorf("missing job: err = %v", err)
+	}
+}
@@ -0,0 +1,172 @@
+package graph
+
+import (
+	"database/sql"
+	"fmt"
+	"graphql-backend/graph/model"
+	"log"
+
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// =============================================
+// ========== RETTSLIG TILBAKEHOLD ===========
+// =============================================
+
+// Felkod i GraphQL-felets extensions när en åtgärd stoppas av ett tilbakehold
+const ERROR_CODE_LEGAL_HOLD = "LEGAL_HOLD"
+
+// getLegalHold hämtar ett tilbakehold
+func getLegalHold(db sqlQueryer, holdID string) (*model.LegalHold, error) {
+	var hold model.LegalHold
+	var releasedBy, releasedAt, releaseNote sql.NullString
+	err := db.QueryRow(`
+		SELECT id, node_id, reason, placed_by, placed_at, released_by, released_at, release_note
+		FROM legal_holds WHERE id = ?
+	`, holdID).Scan(&hold.ID, &hold.NodeID, &hold.Reason, &hold.PlacedByID, &hold.PlacedAt, &releasedBy, &releasedAt, &releaseNote)
+	if err == sql.ErrNoRows {
+		return nil, serviceErrorf(errNotFound, "legal hold not found")
+	} else if err != nil {
+		log.Printf("Error fetching legal hold %s: %v", holdID, err)
+		return nil, serviceErrorf(errInternal, "failed to fetch legal hold: %v", err)
+	}
+
+	hold.ReleasedByID = nullStringPtr(releasedBy)
+	hold.ReleasedAt = nullStringPtr(releasedAt)
+	hold.ReleaseNote = nullStringPtr(releaseNote)
+	hold.Active = !releasedAt.Valid
+	return &hold, nil
+}
+
+// activeHoldOnNode hämtar det äldsta aktiva tilbakeholdet som ligger direkt på en nod, eller nil
+func activeHoldOnNode(db sqlQueryer, nodeID string) (*model.LegalHold, error) {
+	var holdID string
+	err := db.QueryRow(`
+		SELECT id FROM legal_holds
+		WHERE node_id = ? AND released_at IS NULL
+		ORDER BY id ASC LIMIT 1
+	`, nodeID).Scan(&holdID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		log.Printf("Error fetching legal holds for node %s: %v", nodeID, err)
+		return nil, serviceErrorf(errInternal, "failed to fetch legal holds: %v", err)
+	}
+	return getLegalHold(db, holdID)
+}
+
+// activeLegalHold hämtar ett aktivt tilbakehold som gäller för en nod, på noden själv eller på en förälder
+func activeLegalHold(db sqlQueryer, nodeID string) (*model.LegalHold, error) {
+	visited := make(map[string]bool)
+	for currentID := nodeID; currentID != "" && !visited[currentID]; {
+		visited[currentID] = true
+
+		hold, err := activeHoldOnNode(db, currentID)
+		if err != nil || hold != nil {
+			return hold, err
+		}
+
+		var parentID sql.NullString
+		err = db.QueryRow("SELECT parent_id FROM nodes WHERE id = ?", currentID).Scan(&parentID)
+		if err == sql.ErrNoRows {
+			return nil, nil
+		} else if err != nil {
+			log.Printf("Error fetching parent of node %s: %v", currentID, err)
+			return nil, serviceErrorf(errInternal, "failed to fetch parent node: %v", err)
+		}
+		currentID = parentID.String
+	}
+	return nil, nil
+}
+
+// activeLegalHoldForFile hämtar ett aktivt tilbakehold som gäller för filens nod
+func activeLegalHoldForFile(db sqlQueryer, fileID string) (*model.LegalHold, error) {
+	var nodeID sql.NullString
+	err := db.QueryRow("SELECT node_id FROM files WHERE id = ?", fileID).Scan(&nodeID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		log.Printf("Error fetching node of file %s: %v", fileID, err)
+		return nil, serviceErrorf(errInternal, "failed to fetch file: %v", err)
+	}
+	return activeLegalHold(db, nodeID.String)
+}
+
+// activeLegalHoldInSubtree hämtar ett aktivt tilbakehold på en nod under den angivna noden, eller nil
+func activeLegalHoldInSubtree(db sqlQueryer, nodeID string) (*model.LegalHold, error) {
+	pending := []string{nodeID}
+	for len(pending) > 0 {
+		currentID := pending[0]
+		pending = pending[1:]
+
+		hold, err := activeHoldOnNode(db, currentID)
+		if err != nil || hold != nil {
+			return hold, err
+		}
+
+		rows, err := db.Query("SELECT id FROM nodes WHERE parent_id = ?", currentID)
+		if err != nil {
+			log.Printf("Error fetching child nodes of %s: %v", currentID, err)
+			return nil, serviceErrorf(errInternal, "failed to fetch child nodes: %v", err)
+		}
+		childIDs, err := scanIDs(rows)
+		if err != nil {
+			return nil, err
+		}
+		pending = append(pending, childIDs...)
+	}
+	return nil, nil
+}
+
+// checkNodeNotHeld returnerar ett fel med koden LEGAL_HOLD om noden omfattas av ett tilbakehold
+func checkNodeNotHeld(db sqlQueryer, nodeID string, action string) error {
+	hold, err := activeLegalHold(db, nodeID)
+	if err != nil {
+		return err
+	}
+	if hold != nil {
+		return legalHoldError(hold, action)
+	}
+	return nil
+}
+
+// checkSubtreeNotHeld kontrollerar noden, dess föräldrar och alla undernoder, t.ex. innan en nod flyttas
+func checkSubtreeNotHeld(db sqlQueryer, nodeID string, action string) error {
+	if err := checkNodeNotHeld(db, nodeID, action); err != nil {
+		return err
+	}
+	hold, err := activeLegalHoldInSubtree(db, nodeID)
+	if err != nil {
+		return err
+	}
+	if hold != nil {
+		return legalHoldError(hold, action)
+	}
+	return nil
+}
+
+// checkFileNotHeld returnerar ett fel med koden LEGAL_HOLD om filen omfattas av ett tilbakehold
+func checkFileNotHeld(db sqlQueryer, fileID string, action string) error {
+	hold, err := activeLegalHoldForFile(db, fileID)
+	if err != nil {
+		return err
+	}
+	if hold != nil {
+		return legalHoldError(hold, action)
+	}
+	return nil
+}
+
+// legalHoldError skapar ett GraphQL-fel med felkod så att klienter kan skilja tilbakehold från andra fel
+func legalHoldError(hold *model.LegalHold, action string) error {
+	log.Printf("Refused to %s: node %s is under legal hold %s", action, hold.NodeID, hold.ID)
+	return &gqlerror.Error{
+		Err:     errConflict,
+		Message: fmt.Sprintf("cannot %s: content is under legal hold %s (%s)", action, hold.ID, hold.Reason),
+		Extensions: map[string]interface{}{
+			"code":        ERROR_CODE_LEGAL_HOLD,
+			"legalHoldId": hold.ID,
+			"nodeId":      hold.NodeID,
+		},
+	}
+}
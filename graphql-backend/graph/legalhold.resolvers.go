@@ -0,0 +1,179 @@
+package graph
+
+// This file will be automatically regenerated based on the schema, any resolver implementations
+// will be copied through when generating and any unknown code will be moved to the end.
+// Code generated by github.com/99designs/gqlgen version v0.17.69
+
+import (
+	"context"
+	"fmt"
+	"graphql-backend/graph/model"
+	"log"
+	"strings"
+	"time"
+)
+
+// LegalHold is the resolver for the legalHold field.
+func (r *fileResolver) LegalHold(ctx context.Context, obj *model.File) (*model.LegalHold, error) {
+	if r.DB == nil {
+		return nil, serviceErrorf(errInternal, "internal server error: database connection is not initialized")
+	}
+
+	return activeLegalHoldForFile(r.DB, obj.ID)
+}
+
+// Node is the resolver for the node field.
+func (r *legalHoldResolver) Node(ctx context.Context, obj *model.LegalHold) (*model.Node, error) {
+	// Registret behåller hävda tilbakehold även om noden senare tagits bort
+	var exists bool
+	if err := r.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM nodes WHERE id = ?)", obj.NodeID).Scan(&exists); err != nil {
+		log.Printf("Error checking if node exists: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to fetch node: %v", err)
+	}
+	if !exists {
+		return nil, nil
+	}
+	return getNodeWithPermissions(ctx, r.DB, obj.NodeID)
+}
+
+// PlacedBy is the resolver for the placedBy field.
+func (r *legalHoldResolver) PlacedBy(ctx context.Context, obj *model.LegalHold) (*model.User, error) {
+	return getUserByID(r.DB, obj.PlacedByID)
+}
+
+// ReleasedBy is the resolver for the releasedBy field.
+func (r *legalHoldResolver) ReleasedBy(ctx context.Context, obj *model.LegalHold) (*model.User, error) {
+	if obj.ReleasedByID == nil {
+		return nil, nil
+	}
+	return getUserByID(r.DB, *obj.ReleasedByID)
+}
+
+// PlaceLegalHold is the resolver for the placeLegalHold field.
+func (r *mutationResolver) PlaceLegalHold(ctx context.Context, nodeID string, reason string) (*model.LegalHold, error) {
+	logAction(fmt.Sprintf("Placing legal hold on node %s", nodeID))
+
+	if r.DB == nil {
+		log.Printf("Database connection is nil")
+		return nil, serviceErrorf(errInternal, "internal server error: database connection is not initialized")
+	}
+
+	userID, err := requireAdministrator(ctx, r.DB, "place legal holds")
+	if err != nil {
+		return nil, err
+	}
+
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return nil, fmt.Errorf("a reason is required for a legal hold")
+	}
+
+	if _, err := getNodeType(r.DB, nodeID); err != nil {
+		return nil, err
+	}
+
+	result, err := r.DB.Exec(
+		"INSERT INTO legal_holds (node_id, reason, placed_by, placed_at) VALUES (?, ?, ?, ?)",
+		nodeID, reason, userID, time.Now().Format(time.RFC3339),
+	)
+	if err != nil {
+		log.Printf("Error placing legal hold on node %s: %v", nodeID, err)
+		return nil, serviceErrorf(errInternal, "failed to place legal hold: %v", err)
+	}
+
+	holdID, err := result.LastInsertId()
+	if err != nil {
+		log.Printf("Error retrieving last insert ID: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to retrieve legal hold ID: %v", err)
+	}
+
+	log.Printf("Legal hold %d placed on node %s", holdID, nodeID)
+	return getLegalHold(r.DB, fmt.Sprintf("%d", holdID))
+}
+
+// ReleaseLegalHold is the resolver for the releaseLegalHold field.
+func (r *mutationResolver) ReleaseLegalHold(ctx context.Context, id string, note *string) (*model.LegalHold, error) {
+	logAction(fmt.Sprintf("Releasing legal hold %s", id))
+
+	if r.DB == nil {
+		log.Printf("Database connection is nil")
+		return nil, serviceErrorf(errInternal, "internal server error: database connection is not initialized")
+	}
+
+	userID, err := requireAdministrator(ctx, r.DB, "release legal holds")
+	if err != nil {
+		return nil, err
+	}
+
+	hold, err := getLegalHold(r.DB, id)
+	if err != nil {
+		return nil, err
+	}
+	if !hold.Active {
+		return nil, fmt.Errorf("legal hold %s has already been released", id)
+	}
+
+	// Tilbakeholdet tas inte bort utan markeras som hävt så att registret behåller historiken
+	_, err = r.DB.Exec(
+		"UPDATE legal_holds SET released_by = ?, released_at = ?, release_note = ? WHERE id = ?",
+		userID, time.Now().Format(time.RFC3339), note, id,
+	)
+	if err != nil {
+		log.Printf("Error releasing legal hold %s: %v", id, err)
+		return nil, serviceErrorf(errInternal, "failed to release legal hold: %v", err)
+	}
+
+	log.Printf("Legal hold %s on node %s released", id, hold.NodeID)
+	return getLegalHold(r.DB, id)
+}
+
+// LegalHold is the resolver for the legalHold field.
+func (r *nodeResolver) LegalHold(ctx context.Context, obj *model.Node) (*model.LegalHold, error) {
+	if r.DB == nil {
+		return nil, serviceErrorf(errInternal, "internal server error: database connection is not initialized")
+	}
+
+	return activeLegalHold(r.DB, obj.ID)
+}
+
+// LegalHolds is the resolver for the legalHolds field.
+func (r *queryResolver) LegalHolds(ctx context.Context, activeOnly *bool) ([]*model.LegalHold, error) {
+	if r.DB == nil {
+		return nil, serviceErrorf(errInternal, "internal server error: database connection is not initialized")
+	}
+
+	if _, err := requireAdministrator(ctx, r.DB, "view legal holds"); err != nil {
+		return nil, err
+	}
+
+	query := "SELECT id FROM legal_holds"
+	if activeOnly != nil && *activeOnly {
+		query += " WHERE released_at IS NULL"
+	}
+	query += " ORDER BY id DESC"
+
+	rows, err := r.DB.Query(query)
+	if err != nil {
+		log.Printf("Error fetching legal holds: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to fetch legal holds: %v", err)
+	}
+	holdIDs, err := scanIDs(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	holds := []*model.LegalHold{}
+	for _, holdID := range holdIDs {
+		hold, err := getLegalHold(r.DB, holdID)
+		if err != nil {
+			return nil, err
+		}
+		holds = append(holds, hold)
+	}
+	return holds, nil
+}
+
+// LegalHold returns LegalHoldResolver implementation.
+func (r *Resolver) LegalHold() LegalHoldResolver { return &legalHoldResolver{r} }
+
+type legalHoldResolver struct{ *Resolver }
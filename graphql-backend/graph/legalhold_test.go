@@ -0,0 +1,143 @@
+package graph
+
+import (
+	"errors"
+	"graphql-backend/graph/model"
+	"strings"
+	"testing"
+
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// assertLegalHoldError kontrollerar att ett fel kommer från tilbakeholdet holdID
+func assertLegalHoldError(t *testing.T, action string, err error, holdID string) {
+	t.Helper()
+	var gqlErr *gqlerror.Error
+	if !errors.As(err, &gqlErr) {
+		t.Errorf("%s: err = %v, want a legal hold error", action, err)
+		return
+	}
+	if gqlErr.Extensions["code"] != ERROR_CODE_LEGAL_HOLD || gqlErr.Extensions["legalHoldId"] != holdID {
+		t.Errorf("%s: extensions = %v, want hold %s", action, gqlErr.Extensions, holdID)
+	}
+}
+
+func TestLegalHoldBlocksChanges(t *testing.T) {
+	db := openTestDB(t)
+	ctx := testAdminContext(t)
+	resolver := NewResolver(db)
+	mutation := resolver.Mutation()
+
+	root := createTestNode(t, db, "Tvist", nil)
+	child := createTestNode(t, db, "Korrespondanse", &root.ID)
+	outside := createTestNode(t, db, "Utenfor", nil)
+	file := saveTestFile(t, db, "brev.txt", child.ID, "brevet")
+
+	if _, err := mutation.PlaceLegalHold(ctx, root.ID, "  "); err == nil || err.Error() != "a reason is required for a legal hold" {
+		t.Errorf("place hold without reason: err = %v", err)
+	}
+	bobID := insertTestUser(t, db, "bob")
+	if _, err := mutation.PlaceLegalHold(testUserContext(t, bobID, "bob"), root.ID, "Rettssak"); err == nil || !strings.HasPrefix(err.Error(), "permission denied") {
+		t.Errorf("place hold as non-admin: err = %v", err)
+	}
+	hold, err := mutation.PlaceLegalHold(ctx, root.ID, "Rettssak 26-001")
+	if err != nil {
+		t.Fatalf("place legal hold: %v", err)
+	}
+
+	// Tilbakeholdet på roten gäller filer och noder längre ner i trädet
+	name := "nytt namn"
+	metadata := []*model.MetadataInput{{Key: "status", Value: "endret"}}
+	blocked := map[string]func() error{
+		"rename file":     func() error { _, err := mutation.RenameFile(ctx, file.ID, "nytt.txt"); return err },
+		"move file":       func() error { _, err := mutation.MoveFile(ctx, file.ID, outside.ID); return err },
+		"delete file":     func() error { _, err := mutation.DeleteFile(ctx, file.ID); return err },
+		"update metadata": func() error { _, err := mutation.UpdateMetadata(ctx, file.ID, metadata); return err },
+		"delete metadata": func() error { _, err := mutation.DeleteMetadata(ctx, file.ID, []string{"author"}); return err },
+		"update node": func() error {
+			_, err := mutation.UpdateNode(ctx, child.ID, model.NodeUpdateInput{Name: &name})
+			return err
+		},
+		"move node":        func() error { _, err := mutation.MoveNode(ctx, child.ID, outside.ID); return err },
+		"delete node":      func() error { _, err := mutation.DeleteNode(ctx, child.ID); return err },
+		"request disposal": func() error { _, err := mutation.RequestDisposal(ctx, []string{file.ID}, nil); return err },
+	}
+	for action, run := range blocked {
+		assertLegalHoldError(t, action, run(), hold.ID)
+	}
+
+	if _, err := mutation.MoveNode(ctx, root.ID, outside.ID); err == nil {
+		t.Error("moved the held node")
+	}
+	// Nytt innehåll får läggas till, men en förälder till en fryst nod kan inte flyttas
+	inner := createTestNode(t, db, "Granskning", &outside.ID)
+	innerHold, err := mutation.PlaceLegalHold(ctx, inner.ID, "Granskning")
+	if err != nil {
+		t.Fatal(err)
+	}
+	parent := createTestNode(t, db, "Överordnad", nil)
+	_, err = mutation.MoveNode(ctx, outside.ID, parent.ID)
+	assertLegalHoldError(t, "move parent of held node", err, innerHold.ID)
+	if _, err := mutation.MoveNode(ctx, parent.ID, child.ID); err != nil {
+		t.Errorf("move node into held subtree: %v", err)
+	}
+	if _, err := mutation.ReleaseLegalHold(ctx, innerHold.ID, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	held, err := resolver.File().LegalHold(ctx, file)
+	if err != nil || held == nil || held.ID != hold.ID {
+		t.Errorf("file legal hold = %+v, %v", held, err)
+	}
+
+	released, err := mutation.ReleaseLegalHold(ctx, hold.ID, strPtr("Saken er avsluttet"))
+	if err != nil {
+		t.Fatalf("release legal hold: %v", err)
+	}
+	if released.Active || released.ReleasedAt == nil {
+		t.Errorf("released hold = %+v", released)
+	}
+	if _, err := mutation.ReleaseLegalHold(ctx, hold.ID, nil); err == nil || !strings.Contains(err.Error(), "has already been released") {
+		t.Errorf("release twice: err = %v", err)
+	}
+	if _, err := mutation.RenameFile(ctx, file.ID, "nytt.txt"); err != nil {
+		t.Errorf("rename after release: %v", err)
+	}
+
+	active := true
+	holds, err := resolver.Query().LegalHolds(ctx, &active)
+	if err != nil || len(holds) != 0 {
+		t.Errorf("active holds = %v, %v", holds, err)
+	}
+	all, err := resolver.Query().LegalHolds(ctx, nil)
+	if err != nil || len(all) != 2 {
+		t.Errorf("all holds = %d, %v, want the released holds kept in the register", len(all), err)
+	}
+}
+
+func TestLegalHoldBlocksApprovedDisposal(t *testing.T) {
+	db := openTestDB(t)
+	mutation := NewResolver(db).Mutation()
+	admin := testAdminContext(t)
+	archivistID := insertTestUser(t, db, "arkivarie")
+	mustExec(t, db, "INSERT INTO group_members (user_id, group_id, created_at) SELECT ?, id, '2026-01-01T00:00:00Z' FROM groups WHERE name = 'Administrators'", archivistID)
+
+	nodeID := insertTestNode(t, db, "Arkiv", "")
+	createTestRetentionRule(t, db, nodeID, "Kort", 0, RETENTION_TRIGGER_CREATED_AT, model.DisposalActionDestroy)
+	fileID := insertTestFile(t, db, "brev.txt", nodeID)
+
+	request, err := mutation.RequestDisposal(admin, []string{fileID}, nil)
+	if err != nil {
+		t.Fatalf("request disposal: %v", err)
+	}
+	// Ett tilbakehold som läggs efter begäran stoppar godkännandet
+	hold, err := mutation.PlaceLegalHold(admin, nodeID, "Innsynskrav")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = mutation.ApproveDisposal(testUserContext(t, archivistID, "arkivarie"), request.ID, nil)
+	assertLegalHoldError(t, "approve disposal", err, hold.ID)
+	if _, err := loadTreeFile(db, fileID); err != nil {
+		t.Errorf("held file was disposed of: %v", err)
+	}
+}
@@ -0,0 +1,412 @@
+package graph
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"graphql-backend/graph/model"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// =============================================
+// ========== METADATASCHEMAN ================
+// =============================================
+
+// Felkod i GraphQL-felets extensions när metadata inte följer nodens schema
+const ERROR_CODE_METADATA_VALIDATION = "METADATA_VALIDATION"
+
+// metadataEntry är ett typat nyckel/värde-par som ska valideras mot ett schema
+type metadataEntry struct {
+	Key   string
+	Value string
+	Type  model.MetadataValueType // Tom om typen inte angetts och ska tas från schemat
+
+	Inheritable bool // Gäller bara metadata på noder
+}
+
+// metadataFieldError är ett valideringsfel för en enskild nyckel
+type metadataFieldError struct {
+	Key     string `json:"key"`
+	Message string `json:"message"`
+}
+
+// metadataEntriesFromInput gör om GraphQL-indata till nyckel/värde-par
+func metadataEntriesFromInput(inputs []*model.MetadataInput) []metadataEntry {
+	entries := []metadataEntry{}
+	for _, input := range inputs {
+		if input != nil {
+			entry := metadataEntry{Key: input.Key, Value: input.Value}
+			if input.Type != nil {
+				entry.Type = *input.Type
+			}
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// loadMetadataEntries hämtar en fils metadata som nyckel/värde-par
+func loadMetadataEntries(db sqlQueryer, fileID string) ([]metadataEntry, error) {
+	rows, err := db.Query("SELECT key, value, value_type FROM metadata WHERE file_id = ? ORDER BY id ASC", fileID)
+	if err != nil {
+		log.Printf("Error fetching metadata for file %s: %v", fileID, err)
+		return nil, serviceErrorf(errInternal, "failed to fetch metadata: %v", err)
+	}
+	defer rows.Close()
+
+	entries := []metadataEntry{}
+	for rows.Next() {
+		meta, err := scanMetadataRow(rows)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, metadataEntry{Key: meta.Key, Value: meta.Value, Type: meta.Type})
+	}
+	return entries, rows.Err()
+}
+
+// validateMetadataSchemaInput kontrollerar ett schema innan det sparas
+func validateMetadataSchemaInput(input model.MetadataSchemaInput) (string, error) {
+	name := strings.TrimSpace(input.Name)
+	if name == "" {
+		return "", fmt.Errorf("metadata schema name is required")
+	}
+
+	seen := make(map[string]bool)
+	for _, field := range input.Fields {
+		fieldName := strings.TrimSpace(field.Name)
+		if fieldName == "" {
+			return "", fmt.Errorf("metadata schema field name is required")
+		}
+		if seen[fieldName] {
+			return "", fmt.Errorf("field %q is defined more than once", fieldName)
+		}
+		seen[fieldName] = true
+
+		if !field.Type.IsValid() {
+			return "", fmt.Errorf("field %q has invalid type %s", fieldName, field.Type)
+		}
+		if field.Type == model.MetadataFieldTypeEnum && len(field.AllowedValues) == 0 {
+			return "", fmt.Errorf("field %q of type ENUM must list its allowed values", fieldName)
+		}
+		if field.Type != model.MetadataFieldTypeEnum && len(field.AllowedValues) > 0 {
+			return "", fmt.Errorf("only fields of type ENUM can list allowed values (field %q)", fieldName)
+		}
+		if field.VocabularyID != nil && field.Type != model.MetadataFieldTypeString {
+			return "", fmt.Errorf("only fields of type STRING can use a vocabulary (field %q)", fieldName)
+		}
+		if field.Pattern != nil && *field.Pattern != "" {
+			if _, err := regexp.Compile(*field.Pattern); err != nil {
+				return "", fmt.Errorf("field %q has an invalid pattern: %v", fieldName, err)
+			}
+		}
+	}
+	return name, nil
+}
+
+// saveMetadataSchemaFields ersätter fälten i ett schema
+func saveMetadataSchemaFields(tx *sql.Tx, schemaID string, fields []*model.MetadataSchemaFieldInput) error {
+	if _, err := tx.Exec("DELETE FROM metadata_schema_fields WHERE schema_id = ?", schemaID); err != nil {
+		log.Printf("Error deleting fields of metadata schema %s: %v", schemaID, err)
+		return serviceErrorf(errInternal, "failed to update metadata schema fields: %v", err)
+	}
+
+	for i, field := range fields {
+		var allowedValues interface{}
+		if len(field.AllowedValues) > 0 {
+			data, err := json.Marshal(field.AllowedValues)
+			if err != nil {
+				return serviceErrorf(errInternal, "failed to serialize allowed values: %v", err)
+			}
+			allowedValues = string(data)
+		}
+
+		var pattern interface{}
+		if field.Pattern != nil && *field.Pattern != "" {
+			pattern = *field.Pattern
+		}
+
+		if field.VocabularyID != nil {
+			if _, err := getVocabulary(tx, *field.VocabularyID); err != nil {
+				return fmt.Errorf("field %q: %v", field.Name, err)
+			}
+		}
+
+		_, err := tx.Exec(`
+			INSERT INTO metadata_schema_fields (schema_id, position, name, field_type, required, repeatable, pattern, allowed_values, vocabulary_id, description)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`, schemaID, i, strings.TrimSpace(field.Name), field.Type, field.Required != nil && *field.Required,
+			field.Repeatable != nil && *field.Repeatable, pattern, allowedValues, field.VocabularyID, field.Description)
+		if err != nil {
+			log.Printf("Error saving field %s of metadata schema %s: %v", field.Name, schemaID, err)
+			return serviceErrorf(errInternal, "failed to save metadata schema field: %v", err)
+		}
+	}
+	return nil
+}
+
+// getMetadataSchema hämtar ett metadataschema med dess fält
+func getMetadataSchema(db sqlQueryer, schemaID string) (*model.MetadataSchema, error) {
+	var schema model.MetadataSchema
+	var description sql.NullString
+	err := db.QueryRow(`
+		SELECT id, name, description, allow_additional_keys, created_at, updated_at
+		FROM metadata_schemas WHERE id = ?
+	`, schemaID).Scan(&schema.ID, &schema.Name, &description, &schema.AllowAdditionalKeys, &schema.CreatedAt, &schema.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, serviceErrorf(errNotFound, "metadata schema not found")
+	} else if err != nil {
+		log.Printf("Error fetching metadata schema %s: %v", schemaID, err)
+		return nil, serviceErrorf(errInternal, "failed to fetch metadata schema: %v", err)
+	}
+	schema.Description = nullStringPtr(description)
+
+	rows, err := db.Query(`
+		SELECT name, field_type, required, repeatable, pattern, allowed_values, vocabulary_id, description
+		FROM metadata_schema_fields WHERE schema_id = ? ORDER BY position ASC
+	`, schemaID)
+	if err != nil {
+		log.Printf("Error fetching fields of metadata schema %s: %v", schemaID, err)
+		return nil, serviceErrorf(errInternal, "failed to fetch metadata schema fields: %v", err)
+	}
+	defer rows.Close()
+
+	schema.Fields = []*model.MetadataSchemaField{}
+	for rows.Next() {
+		var field model.MetadataSchemaField
+		var fieldType string
+		var pattern, allowedValues, vocabularyID, fieldDescription sql.NullString
+		if err := rows.Scan(&field.Name, &fieldType, &field.Required, &field.Repeatable, &pattern, &allowedValues, &vocabularyID, &fieldDescription); err != nil {
+			log.Printf("Error scanning metadata schema field: %v", err)
+			return nil, serviceErrorf(errInternal, "failed to read metadata schema field: %v", err)
+		}
+
+		field.Type = model.MetadataFieldType(fieldType)
+		field.Pattern = nullStringPtr(pattern)
+		field.VocabularyID = nullStringPtr(vocabularyID)
+		field.Description = nullStringPtr(fieldDescription)
+		field.AllowedValues = []string{}
+		if allowedValues.Valid {
+			if err := json.Unmarshal([]byte(allowedValues.String), &field.AllowedValues); err != nil {
+				log.Printf("Error parsing allowed values of field %s: %v", field.Name, err)
+				return nil, serviceErrorf(errInternal, "failed to read metadata schema field: %v", err)
+			}
+		}
+		schema.Fields = append(schema.Fields, &field)
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("Error iterating metadata schema fields: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to read metadata schema fields: %v", err)
+	}
+	return &schema, nil
+}
+
+// effectiveMetadataSchema hämtar schemat som gäller för en nod: nodens eget eller närmaste förälders
+func effectiveMetadataSchema(db sqlQueryer, nodeID string) (*model.MetadataSchema, error) {
+	visited := make(map[string]bool)
+	for currentID := nodeID; currentID != "" && !visited[currentID]; {
+		visited[currentID] = true
+
+		var parentID, schemaID sql.NullString
+		err := db.QueryRow("SELECT parent_id, metadata_schema_id FROM nodes WHERE id = ?", currentID).Scan(&parentID, &schemaID)
+		if err == sql.ErrNoRows {
+			return nil, nil
+		} else if err != nil {
+			log.Printf("Error fetching metadata schema of node %s: %v", currentID, err)
+			return nil, serviceErrorf(errInternal, "failed to fetch metadata schema: %v", err)
+		}
+
+		if schemaID.Valid {
+			return getMetadataSchema(db, schemaID.String)
+		}
+		currentID = parentID.String
+	}
+	return nil, nil
+}
+
+// validateMetadata kontrollerar metadata mot ett schema och returnerar ett fel per nyckel som inte stämmer
+func validateMetadata(schema *model.MetadataSchema, entries []metadataEntry) []metadataFieldError {
+	var fieldErrors []metadataFieldError
+
+	values := make(map[string][]string)
+	var keys []string
+	for _, entry := range entries {
+		if _, ok := values[entry.Key]; !ok {
+			keys = append(keys, entry.Key)
+		}
+		values[entry.Key] = append(values[entry.Key], entry.Value)
+	}
+
+	defined := make(map[string]bool)
+	for _, field := range schema.Fields {
+		defined[field.Name] = true
+	}
+	if !schema.AllowAdditionalKeys {
+		for _, key := range keys {
+			if !defined[key] {
+				fieldErrors = append(fieldErrors, metadataFieldError{key, fmt.Sprintf("is not defined in metadata schema %s", schema.Name)})
+			}
+		}
+	}
+
+	for _, field := range schema.Fields {
+		var present []string
+		for _, value := range values[field.Name] {
+			if strings.TrimSpace(value) != "" {
+				present = append(present, value)
+			}
+		}
+
+		if len(present) == 0 {
+			if field.Required {
+				fieldErrors = append(fieldErrors, metadataFieldError{field.Name, "is required"})
+			}
+			continue
+		}
+		if len(present) > 1 && !field.Repeatable {
+			fieldErrors = append(fieldErrors, metadataFieldError{field.Name, "cannot have more than one value"})
+			continue
+		}
+
+		for _, value := range present {
+			if message := validateMetadataValue(field, strings.TrimSpace(value)); message != "" {
+				fieldErrors = append(fieldErrors, metadataFieldError{field.Name, message})
+				break
+			}
+		}
+	}
+	return fieldErrors
+}
+
+// validateMetadataValue kontrollerar ett enskilt värde och returnerar ett felmeddelande, eller en tom sträng
+func validateMetadataValue(field *model.MetadataSchemaField, value string) string {
+	switch field.Type {
+	case model.MetadataFieldTypeNumber:
+		if _, err := strconv.ParseFloat(value, 64); err != nil {
+			return fmt.Sprintf("must be a number, got %q", value)
+		}
+	case model.MetadataFieldTypeDate:
+		if _, err := time.Parse("2006-01-02", value); err != nil {
+			return fmt.Sprintf("must be a date (YYYY-MM-DD), got %q", value)
+		}
+	case model.MetadataFieldTypeEnum:
+		if !containsString(field.AllowedValues, value) {
+			return fmt.Sprintf("must be one of: %s", strings.Join(field.AllowedValues, ", "))
+		}
+	}
+
+	if field.Pattern != nil {
+		// Mönstret ska matcha hela värdet, inte bara en del av det
+		pattern, err := regexp.Compile("^(?:" + *field.Pattern + ")$")
+		if err != nil {
+			return fmt.Sprintf("cannot be checked: invalid pattern %q", *field.Pattern)
+		}
+		if !pattern.MatchString(value) {
+			return fmt.Sprintf("does not match pattern %s", *field.Pattern)
+		}
+	}
+	return ""
+}
+
+// metadataValueTypeForField ger lagringstypen för värden i ett schemafält som saknar angiven typ
+func metadataValueTypeForField(fieldType model.MetadataFieldType) model.MetadataValueType {
+	switch fieldType {
+	case model.MetadataFieldTypeNumber:
+		return model.MetadataValueTypeDecimal
+	case model.MetadataFieldTypeDate:
+		return model.MetadataValueTypeDate
+	}
+	return model.MetadataValueTypeString
+}
+
+// checkMetadataValid kontrollerar att värdena följer sina typer och schemat som gäller för noden, och
+// returnerar posterna med typ och normaliserat värde. Metadata utan angiven typ får typen från
+// schemats fält, annars STRING. Felet har koden METADATA_VALIDATION och en lista fieldErrors med
+// nyckel och meddelande per fel.
+func checkMetadataValid(db sqlQueryer, nodeID string, entries []metadataEntry) ([]metadataEntry, error) {
+	schema, err := effectiveMetadataSchema(db, nodeID)
+	if err != nil {
+		return nil, err
+	}
+
+	schemaTypes := make(map[string]model.MetadataValueType)
+	vocabularies := make(map[string]string)
+	if schema != nil {
+		for _, field := range schema.Fields {
+			schemaTypes[field.Name] = metadataValueTypeForField(field.Type)
+			if field.VocabularyID != nil {
+				vocabularies[field.Name] = *field.VocabularyID
+			}
+		}
+	}
+
+	var fieldErrors []metadataFieldError
+	prepared := make([]metadataEntry, 0, len(entries))
+	for _, entry := range entries {
+		if entry.Type == "" {
+			entry.Type = schemaTypes[entry.Key]
+		}
+		entry.Type = metadataValueTypeOrDefault(entry.Type)
+
+		typed, err := parseMetadataValue(entry.Type, entry.Value)
+		if err != nil {
+			fieldErrors = append(fieldErrors, metadataFieldError{entry.Key, err.Error()})
+			continue
+		}
+		entry.Value = typed.Value
+
+		message, err := checkMetadataReference(db, entry.Type, entry.Value)
+		if err != nil {
+			return nil, err
+		}
+		if message != "" {
+			fieldErrors = append(fieldErrors, metadataFieldError{entry.Key, message})
+			continue
+		}
+
+		// Värden i fält med vokabulär sparas som termens kod, oavsett om namnet eller koden angavs
+		if vocabularyID, ok := vocabularies[entry.Key]; ok && strings.TrimSpace(entry.Value) != "" {
+			code, message, err := resolveVocabularyValue(db, vocabularyID, entry.Value)
+			if err != nil {
+				return nil, err
+			}
+			if message != "" {
+				fieldErrors = append(fieldErrors, metadataFieldError{entry.Key, message})
+				continue
+			}
+			entry.Value = code
+		}
+		prepared = append(prepared, entry)
+	}
+
+	if schema != nil && len(fieldErrors) == 0 {
+		fieldErrors = validateMetadata(schema, prepared)
+	}
+	if len(fieldErrors) == 0 {
+		return prepared, nil
+	}
+
+	messages := make([]string, len(fieldErrors))
+	for i, fieldError := range fieldErrors {
+		messages[i] = fieldError.Key + " " + fieldError.Message
+	}
+
+	extensions := map[string]interface{}{
+		"code":        ERROR_CODE_METADATA_VALIDATION,
+		"fieldErrors": fieldErrors,
+	}
+	message := "invalid metadata: " + strings.Join(messages, "; ")
+	if schema != nil {
+		extensions["schemaId"] = schema.ID
+		message = fmt.Sprintf("metadata does not match schema %s: %s", schema.Name, strings.Join(messages, "; "))
+	}
+	log.Printf("Metadata rejected: %s", message)
+
+	return nil, &gqlerror.Error{Message: message, Extensions: extensions}
+}
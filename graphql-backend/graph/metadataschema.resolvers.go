@@ -0,0 +1,261 @@
+package graph
+
+// This file will be automatically regenerated based on the schema, any resolver implementations
+// will be copied through when generating and any unknown code will be moved to the end.
+// Code generated by github.com/99designs/gqlgen version v0.17.69
+
+import (
+	"context"
+	"fmt"
+	"graphql-backend/graph/model"
+	"log"
+	"time"
+)
+
+// CreateMetadataSchema is the resolver for the createMetadataSchema field.
+func (r *mutationResolver) CreateMetadataSchema(ctx context.Context, input model.MetadataSchemaInput) (*model.MetadataSchema, error) {
+	logAction(fmt.Sprintf("Creating metadata schema %s", input.Name))
+
+	if r.DB == nil {
+		log.Printf("Database connection is nil")
+		return nil, serviceErrorf(errInternal, "internal server error: database connection is not initialized")
+	}
+
+	if _, err := requireAdministrator(ctx, r.DB, "manage metadata schemas"); err != nil {
+		return nil, err
+	}
+
+	name, err := validateMetadataSchemaInput(input)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := r.DB.Begin()
+	if err != nil {
+		log.Printf("Error starting transaction: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to start transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now().Format(time.RFC3339)
+	result, err := tx.Exec(`
+		INSERT INTO metadata_schemas (name, description, allow_additional_keys, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?)
+	`, name, input.Description, input.AllowAdditionalKeys != nil && *input.AllowAdditionalKeys, now, now)
+	if err != nil {
+		log.Printf("Error creating metadata schema: %v", err)
+		if isUniqueViolation(err) {
+			return nil, fmt.Errorf("a metadata schema named %s already exists", name)
+		}
+		return nil, serviceErrorf(errInternal, "failed to create metadata schema: %v", err)
+	}
+
+	schemaID, err := result.LastInsertId()
+	if err != nil {
+		log.Printf("Error retrieving last insert ID: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to retrieve metadata schema ID: %v", err)
+	}
+
+	if err := saveMetadataSchemaFields(tx, fmt.Sprintf("%d", schemaID), input.Fields); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("Error committing transaction: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to commit transaction: %v", err)
+	}
+
+	log.Printf("Metadata schema %s created with ID %d", name, schemaID)
+	return getMetadataSchema(r.DB, fmt.Sprintf("%d", schemaID))
+}
+
+// UpdateMetadataSchema is the resolver for the updateMetadataSchema field.
+func (r *mutationResolver) UpdateMetadataSchema(ctx context.Context, id string, input model.MetadataSchemaInput) (*model.MetadataSchema, error) {
+	logAction(fmt.Sprintf("Updating metadata schema %s", id))
+
+	if r.DB == nil {
+		log.Printf("Database connection is nil")
+		return nil, serviceErrorf(errInternal, "internal server error: database connection is not initialized")
+	}
+
+	if _, err := requireAdministrator(ctx, r.DB, "manage metadata schemas"); err != nil {
+		return nil, err
+	}
+
+	name, err := validateMetadataSchemaInput(input)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := r.DB.Begin()
+	if err != nil {
+		log.Printf("Error starting transaction: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to start transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	// Befintlig metadata valideras inte om; ändringen gäller från nästa skrivning
+	result, err := tx.Exec(`
+		UPDATE metadata_schemas
+		SET name = ?, description = ?, allow_additional_keys = ?, updated_at = ?
+		WHERE id = ?
+	`, name, input.Description, input.AllowAdditionalKeys != nil && *input.AllowAdditionalKeys, time.Now().Format(time.RFC3339), id)
+	if err != nil {
+		log.Printf("Error updating metadata schema %s: %v", id, err)
+		if isUniqueViolation(err) {
+			return nil, fmt.Errorf("a metadata schema named %s already exists", name)
+		}
+		return nil, serviceErrorf(errInternal, "failed to update metadata schema: %v", err)
+	}
+	if rowsAffected, err := result.RowsAffected(); err == nil && rowsAffected == 0 {
+		return nil, serviceErrorf(errNotFound, "metadata schema not found")
+	}
+
+	if err := saveMetadataSchemaFields(tx, id, input.Fields); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("Error committing transaction: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to commit transaction: %v", err)
+	}
+
+	return getMetadataSchema(r.DB, id)
+}
+
+// DeleteMetadataSchema is the resolver for the deleteMetadataSchema field.
+func (r *mutationResolver) DeleteMetadataSchema(ctx context.Context, id string) (bool, error) {
+	logAction(fmt.Sprintf("Deleting metadata schema %s", id))
+
+	if r.DB == nil {
+		log.Printf("Database connection is nil")
+		return false, serviceErrorf(errInternal, "internal server error: database connection is not initialized")
+	}
+
+	if _, err := requireAdministrator(ctx, r.DB, "manage metadata schemas"); err != nil {
+		return false, err
+	}
+
+	// Ett schema som används av noder kan inte tas bort
+	var inUse bool
+	err := r.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM nodes WHERE metadata_schema_id = ?)", id).Scan(&inUse)
+	if err != nil {
+		log.Printf("Error checking if metadata schema %s is in use: %v", id, err)
+		return false, serviceErrorf(errInternal, "failed to check metadata schema: %v", err)
+	}
+	if inUse {
+		return false, serviceErrorf(errConflict, "cannot delete metadata schema: it is attached to one or more nodes")
+	}
+
+	tx, err := r.DB.Begin()
+	if err != nil {
+		log.Printf("Error starting transaction: %v", err)
+		return false, serviceErrorf(errInternal, "failed to start transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("DELETE FROM metadata_schemas WHERE id = ?", id)
+	if err != nil {
+		log.Printf("Error deleting metadata schema %s: %v", id, err)
+		return false, serviceErrorf(errInternal, "failed to delete metadata schema: %v", err)
+	}
+	if rowsAffected, err := result.RowsAffected(); err == nil && rowsAffected == 0 {
+		return false, serviceErrorf(errNotFound, "metadata schema not found")
+	}
+
+	if _, err := tx.Exec("DELETE FROM metadata_schema_fields WHERE schema_id = ?", id); err != nil {
+		log.Printf("Error deleting fields of metadata schema %s: %v", id, err)
+		return false, serviceErrorf(errInternal, "failed to delete metadata schema: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("Error committing transaction: %v", err)
+		return false, serviceErrorf(errInternal, "failed to commit transaction: %v", err)
+	}
+
+	log.Printf("Metadata schema %s deleted", id)
+	return true, nil
+}
+
+// SetNodeMetadataSchema is the resolver for the setNodeMetadataSchema field.
+func (r *mutationResolver) SetNodeMetadataSchema(ctx context.Context, nodeID string, schemaID *string) (*model.Node, error) {
+	logAction(fmt.Sprintf("Setting metadata schema of node %s", nodeID))
+
+	if r.DB == nil {
+		log.Printf("Database connection is nil")
+		return nil, serviceErrorf(errInternal, "internal server error: database connection is not initialized")
+	}
+
+	if _, err := requireAdministrator(ctx, r.DB, "manage metadata schemas"); err != nil {
+		return nil, err
+	}
+
+	if schemaID != nil {
+		if _, err := getMetadataSchema(r.DB, *schemaID); err != nil {
+			return nil, err
+		}
+	}
+
+	result, err := r.DB.Exec("UPDATE nodes SET metadata_schema_id = ?, updated_at = datetime('now') WHERE id = ?", schemaID, nodeID)
+	if err != nil {
+		log.Printf("Error setting metadata schema of node %s: %v", nodeID, err)
+		return nil, serviceErrorf(errInternal, "failed to set metadata schema: %v", err)
+	}
+	if rowsAffected, err := result.RowsAffected(); err == nil && rowsAffected == 0 {
+		return nil, serviceErrorf(errNotFound, "node not found")
+	}
+
+	return getNodeWithPermissions(ctx, r.DB, nodeID)
+}
+
+// MetadataSchema is the resolver for the metadataSchema field.
+func (r *nodeResolver) MetadataSchema(ctx context.Context, obj *model.Node) (*model.MetadataSchema, error) {
+	if r.DB == nil {
+		return nil, serviceErrorf(errInternal, "internal server error: database connection is not initialized")
+	}
+
+	return effectiveMetadataSchema(r.DB, obj.ID)
+}
+
+// MetadataSchemas is the resolver for the metadataSchemas field.
+func (r *queryResolver) MetadataSchemas(ctx context.Context) ([]*model.MetadataSchema, error) {
+	if r.DB == nil {
+		return nil, serviceErrorf(errInternal, "internal server error: database connection is not initialized")
+	}
+
+	rows, err := r.DB.Query("SELECT id FROM metadata_schemas ORDER BY name ASC")
+	if err != nil {
+		log.Printf("Error fetching metadata schemas: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to fetch metadata schemas: %v", err)
+	}
+	schemaIDs, err := scanIDs(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	schemas := []*model.MetadataSchema{}
+	for _, schemaID := range schemaIDs {
+		schema, err := getMetadataSchema(r.DB, schemaID)
+		if err != nil {
+			return nil, err
+		}
+		schemas = append(schemas, schema)
+	}
+	return schemas, nil
+}
+
+// MetadataSchema is the resolver for the metadataSchema field.
+func (r *queryResolver) MetadataSchema(ctx context.Context, id string) (*model.MetadataSchema, error) {
+	if r.DB == nil {
+		return nil, serviceErrorf(errInternal, "internal server error: database connection is not initialized")
+	}
+
+	return getMetadataSchema(r.DB, id)
+}
+
+// MetadataSchemaField returns MetadataSchemaFieldResolver implementation.
+func (r *Resolver) MetadataSchemaField() MetadataSchemaFieldResolver {
+	return &metadataSchemaFieldResolver{r}
+}
+
+type metadataSchemaFieldResolver struct{ *Resolver }
@@ -0,0 +1,213 @@
+package graph
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"graphql-backend/graph/model"
+	"strings"
+	"testing"
+
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// testMetadataSchemaInput är ett schema för inkomna brev med ett fält av varje typ
+func testMetadataSchemaInput() model.MetadataSchemaInput {
+	required, repeatable := true, true
+	pattern := `\d{4}/\d+`
+	return model.MetadataSchemaInput{
+		Name: "Inkomna brev",
+		Fields: []*model.MetadataSchemaFieldInput{
+			{Name: "diarienummer", Type: model.MetadataFieldTypeString, Required: &required, Pattern: &pattern},
+			{Name: "mottaget", Type: model.MetadataFieldTypeDate},
+			{Name: "belopp", Type: model.MetadataFieldTypeNumber},
+			{Name: "status", Type: model.MetadataFieldTypeEnum, AllowedValues: []string{"Ny", "Avslutad"}},
+			{Name: "amnesord", Type: model.MetadataFieldTypeString, Repeatable: &repeatable},
+		},
+	}
+}
+
+// metadataFieldErrors hämtar fältfelen ur ett valideringsfel som "nyckel meddelande"
+func metadataFieldErrors(t *testing.T, err error) []string {
+	t.Helper()
+	var gqlErr *gqlerror.Error
+	if !errors.As(err, &gqlErr) || gqlErr.Extensions["code"] != ERROR_CODE_METADATA_VALIDATION {
+		t.Fatalf("err = %v, want a metadata validation error", err)
+	}
+	fieldErrors, _ := gqlErr.Extensions["fieldErrors"].([]metadataFieldError)
+	var messages []string
+	for _, fieldError := range fieldErrors {
+		messages = append(messages, fieldError.Key+" "+fieldError.Message)
+	}
+	return messages
+}
+
+func TestValidateMetadataSchemaInput(t *testing.T) {
+	if name, err := validateMetadataSchemaInput(testMetadataSchemaInput()); err != nil || name != "Inkomna brev" {
+		t.Fatalf("validate = %q, %v", name, err)
+	}
+
+	field := func(name string, fieldType model.MetadataFieldType) *model.MetadataSchemaFieldInput {
+		return &model.MetadataSchemaFieldInput{Name: name, Type: fieldType}
+	}
+	badPattern := "(["
+	tests := []struct {
+		name   string
+		fields []*model.MetadataSchemaFieldInput
+		want   string
+	}{
+		{"empty field name", []*model.MetadataSchemaFieldInput{field(" ", model.MetadataFieldTypeString)}, "metadata schema field name is required"},
+		{"duplicate field", []*model.MetadataSchemaFieldInput{field("a", model.MetadataFieldTypeString), field("a", model.MetadataFieldTypeDate)}, `field "a" is defined more than once`},
+		{"enum without values", []*model.MetadataSchemaFieldInput{field("status", model.MetadataFieldTypeEnum)}, `field "status" of type ENUM must list its allowed values`},
+		{"values on a string", []*model.MetadataSchemaFieldInput{{Name: "namn", Type: model.MetadataFieldTypeString, AllowedValues: []string{"x"}}}, `only fields of type ENUM can list allowed values (field "namn")`},
+		{"invalid pattern", []*model.MetadataSchemaFieldInput{{Name: "kod", Type: model.MetadataFieldTypeString, Pattern: &badPattern}}, `field "kod" has an invalid pattern`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := validateMetadataSchemaInput(model.MetadataSchemaInput{Name: "Schema", Fields: tt.fields})
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, want %q", err, tt.want)
+			}
+		})
+	}
+	if _, err := validateMetadataSchemaInput(model.MetadataSchemaInput{Name: "  "}); err == nil || err.Error() != "metadata schema name is required" {
+		t.Errorf("empty name: err = %v", err)
+	}
+}
+
+func TestValidateMetadata(t *testing.T) {
+	pattern := `\d{4}/\d+`
+	schema := &model.MetadataSchema{
+		Name: "Inkomna brev",
+		Fields: []*model.MetadataSchemaField{
+			{Name: "diarienummer", Type: model.MetadataFieldTypeString, Required: true, Pattern: &pattern},
+			{Name: "mottaget", Type: model.MetadataFieldTypeDate},
+			{Name: "belopp", Type: model.MetadataFieldTypeNumber},
+			{Name: "status", Type: model.MetadataFieldTypeEnum, AllowedValues: []string{"Ny", "Avslutad"}},
+			{Name: "amnesord", Type: model.MetadataFieldTypeString, Repeatable: true},
+		},
+	}
+	entries := func(pairs ...string) []metadataEntry {
+		var result []metadataEntry
+		for i := 0; i+1 < len(pairs); i += 2 {
+			result = append(result, metadataEntry{Key: pairs[i], Value: pairs[i+1]})
+		}
+		return result
+	}
+
+	valid := entries("diarienummer", "2026/14", "mottaget", "2026-03-01", "belopp", "1250.50", "status", "Ny", "amnesord", "bygg", "amnesord", "tillstånd")
+	if fieldErrors := validateMetadata(schema, valid); len(fieldErrors) != 0 {
+		t.Errorf("valid metadata: %v", fieldErrors)
+	}
+
+	tests := []struct {
+		name    string
+		entries []metadataEntry
+		want    string
+	}{
+		{"missing required", entries("status", "Ny"), "diarienummer is required"},
+		{"blank required", entries("diarienummer", "  "), "diarienummer is required"},
+		{"partial pattern match", entries("diarienummer", "dnr 2026/14"), `diarienummer does not match pattern \d{4}/\d+`},
+		{"not a date", entries("diarienummer", "2026/1", "mottaget", "1 mars"), `mottaget must be a date (YYYY-MM-DD), got "1 mars"`},
+		{"not a number", entries("diarienummer", "2026/1", "belopp", "tusen"), `belopp must be a number, got "tusen"`},
+		{"not in enum", entries("diarienummer", "2026/1", "status", "Glömd"), "status must be one of: Ny, Avslutad"},
+		{"repeated single value", entries("diarienummer", "2026/1", "diarienummer", "2026/2"), "diarienummer cannot have more than one value"},
+		{"undefined key", entries("diarienummer", "2026/1", "farg", "blå"), "farg is not defined in metadata schema Inkomna brev"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, fieldError := range validateMetadata(schema, tt.entries) {
+				got = append(got, fieldError.Key+" "+fieldError.Message)
+			}
+			if strings.Join(got, "; ") != tt.want {
+				t.Errorf("errors = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	schema.AllowAdditionalKeys = true
+	if fieldErrors := validateMetadata(schema, entries("diarienummer", "2026/1", "farg", "blå")); len(fieldErrors) != 0 {
+		t.Errorf("additional key with allowAdditionalKeys: %v", fieldErrors)
+	}
+}
+
+func TestMetadataSchemaOnFiles(t *testing.T) {
+	db := openTestDB(t)
+	ctx := testAdminContext(t)
+	mutation := NewResolver(db).Mutation()
+
+	root := createTestNode(t, db, "Diarium", nil)
+	child := createTestNode(t, db, "2026", &root.ID)
+	schema, err := mutation.CreateMetadataSchema(ctx, testMetadataSchemaInput())
+	if err != nil {
+		t.Fatalf("create metadata schema: %v", err)
+	}
+	if _, err := mutation.SetNodeMetadataSchema(ctx, root.ID, &schema.ID); err != nil {
+		t.Fatalf("set metadata schema: %v", err)
+	}
+
+	save := func(metadata ...*model.MetadataInput) (*model.File, error) {
+		return mutation.SaveFile(ctx, model.FileInput{
+			Name: "brev.txt", Size: 5, ContentType: "text/plain",
+			FileData: base64.StdEncoding.EncodeToString([]byte("brevet")), NodeID: &child.ID, Metadata: metadata,
+		})
+	}
+
+	// Schemat ärvs av undernoden, och värden som inte följer fältets typ avvisas innan schemat kontrolleras
+	_, err = save(&model.MetadataInput{Key: "mottaget", Value: "igår"}, &model.MetadataInput{Key: "farg", Value: "blå"})
+	if got := metadataFieldErrors(t, err); strings.Join(got, "; ") != `mottaget must be a date (YYYY-MM-DD), got "igår"` || !strings.Contains(err.Error(), "metadata does not match schema Inkomna brev") {
+		t.Errorf("save with invalid metadata: %v (%q)", err, got)
+	}
+	_, err = save(&model.MetadataInput{Key: "farg", Value: "blå"})
+	if got := metadataFieldErrors(t, err); strings.Join(got, "; ") != "farg is not defined in metadata schema Inkomna brev; diarienummer is required" {
+		t.Errorf("save without required field: %q", got)
+	}
+
+	file, err := save(&model.MetadataInput{Key: "diarienummer", Value: "2026/7"}, &model.MetadataInput{Key: "mottaget", Value: "2026-03-01"}, &model.MetadataInput{Key: "status", Value: "Ny"})
+	if err != nil {
+		t.Fatalf("save valid file: %v", err)
+	}
+	// Typen tas från schemats fält när klienten inte anger någon
+	for _, meta := range file.Metadata {
+		if meta.Key == "mottaget" && meta.Type != model.MetadataValueTypeDate {
+			t.Errorf("type of mottaget = %s, want DATE from the schema", meta.Type)
+		}
+	}
+
+	if _, err := mutation.UpdateMetadata(ctx, file.ID, []*model.MetadataInput{{Key: "diarienummer", Value: "saknas"}}); len(metadataFieldErrors(t, err)) != 1 {
+		t.Errorf("update with invalid metadata: err = %v", err)
+	}
+	if value, _ := fileMetadataValue(mustGetFile(t, db, file.ID), "status"); value != "Ny" {
+		t.Errorf("status after refused update = %q, want the metadata unchanged", value)
+	}
+
+	if _, err := mutation.DeleteMetadata(ctx, file.ID, []string{"diarienummer"}); len(metadataFieldErrors(t, err)) != 1 {
+		t.Errorf("delete required field: err = %v", err)
+	}
+	updated, err := mutation.DeleteMetadata(ctx, file.ID, []string{"status"})
+	if err != nil {
+		t.Fatalf("delete optional field: %v", err)
+	}
+	if _, ok := fileMetadataValue(updated, "status"); ok {
+		t.Error("status was not deleted")
+	}
+
+	// Utan schema accepteras godtyckliga nycklar igen
+	if _, err := mutation.SetNodeMetadataSchema(ctx, root.ID, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := save(&model.MetadataInput{Key: "farg", Value: "blå"}); err != nil {
+		t.Errorf("save after removing schema: %v", err)
+	}
+}
+
+// mustGetFile hämtar en fil som administratör
+func mustGetFile(t *testing.T, db *sql.DB, fileID string) *model.File {
+	t.Helper()
+	file, err := NewResolver(db).Query().GetFile(testAdminContext(t), fileID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
@@ -0,0 +1,346 @@
+package graph
+
+import (
+	"database/sql"
+	"fmt"
+	"graphql-backend/graph/model"
+	"log"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// =============================================
+// ========== TYPADE METADATAVÄRDEN ==========
+// =============================================
+
+// Format för normaliserade tidpunkter i value_time, så att de kan jämföras som text
+const metadataTimeLayout = "2006-01-02T15:04:05Z"
+
+// decimalPattern är de decimaltal som godtas; texten sparas som den är så att t.ex. "12.50" behålls
+var decimalPattern = regexp.MustCompile(`^[+-]?(\d+(\.\d*)?|\.\d+)([eE][+-]?\d+)?$`)
+
+// typedMetadataValue är ett metadatavärde tolkat enligt sin typ
+type typedMetadataValue struct {
+	Value  string      // Värdet som sparas i value och returneras till klienter
+	Number interface{} // value_number: int64 eller float64, nil för typer utan tal
+	Time   interface{} // value_time: normaliserat datum eller tidpunkt, nil för övriga typer
+}
+
+// metadataValueTypeOrDefault ger STRING för metadata utan angiven typ
+func metadataValueTypeOrDefault(valueType model.MetadataValueType) model.MetadataValueType {
+	if valueType == "" {
+		return model.MetadataValueTypeString
+	}
+	return valueType
+}
+
+// parseMetadataValue tolkar ett värde enligt typen. Referenser kontrolleras mot databasen separat.
+func parseMetadataValue(valueType model.MetadataValueType, value string) (*typedMetadataValue, error) {
+	trimmed := strings.TrimSpace(value)
+
+	switch metadataValueTypeOrDefault(valueType) {
+	case model.MetadataValueTypeString:
+		return &typedMetadataValue{Value: value}, nil
+
+	case model.MetadataValueTypeInteger:
+		number, err := strconv.ParseInt(trimmed, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("must be an integer, got %q", value)
+		}
+		return &typedMetadataValue{Value: strconv.FormatInt(number, 10), Number: number}, nil
+
+	case model.MetadataValueTypeDecimal:
+		number, err := strconv.ParseFloat(trimmed, 64)
+		if err != nil || !decimalPattern.MatchString(trimmed) {
+			return nil, fmt.Errorf("must be a decimal number, got %q", value)
+		}
+		return &typedMetadataValue{Value: trimmed, Number: number}, nil
+
+	case model.MetadataValueTypeDate:
+		if _, err := time.Parse("2006-01-02", trimmed); err != nil {
+			return nil, fmt.Errorf("must be a date (YYYY-MM-DD), got %q", value)
+		}
+		return &typedMetadataValue{Value: trimmed, Time: trimmed}, nil
+
+	case model.MetadataValueTypeDatetime:
+		// Tidszonen i värdet behålls; value_time normaliseras till UTC för jämförelser
+		t, err := time.Parse(time.RFC3339, trimmed)
+		if err != nil {
+			return nil, fmt.Errorf("must be a date and time (RFC 3339), got %q", value)
+		}
+		return &typedMetadataValue{Value: trimmed, Time: t.UTC().Format(metadataTimeLayout)}, nil
+
+	case model.MetadataValueTypeBoolean:
+		b, err := strconv.ParseBool(trimmed)
+		if err != nil {
+			return nil, fmt.Errorf("must be true or false, got %q", value)
+		}
+		number := int64(0)
+		if b {
+			number = 1
+		}
+		return &typedMetadataValue{Value: strconv.FormatBool(b), Number: number}, nil
+
+	case model.MetadataValueTypeUser, model.MetadataValueTypeNode:
+		if trimmed == "" {
+			return nil, fmt.Errorf("must be the ID of a %s", strings.ToLower(string(valueType)))
+		}
+		return &typedMetadataValue{Value: trimmed}, nil
+	}
+
+	return nil, fmt.Errorf("has unknown type %s", valueType)
+}
+
+// checkMetadataReference kontrollerar att en användare eller nod som metadata refererar till finns
+func checkMetadataReference(db sqlQueryer, valueType model.MetadataValueType, id string) (string, error) {
+	var table string
+	switch valueType {
+	case model.MetadataValueTypeUser:
+		table = "users"
+	case model.MetadataValueTypeNode:
+		table = "nodes"
+	default:
+		return "", nil
+	}
+
+	var exists bool
+	if err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM "+table+" WHERE id = ?)", id).Scan(&exists); err != nil {
+		log.Printf("Error checking metadata reference %s %s: %v", valueType, id, err)
+		return "", serviceErrorf(errInternal, "failed to check metadata reference: %v", err)
+	}
+	if !exists {
+		return fmt.Sprintf("refers to %s %s, which does not exist", strings.ToLower(string(valueType)), id), nil
+	}
+	return "", nil
+}
+
+// insertMetadata sparar ett metadatavärde med dess typade kolumner. Värdet ska redan vara validerat.
+func insertMetadata(db sqlExecer, fileID interface{}, entry metadataEntry) error {
+	valueType := metadataValueTypeOrDefault(entry.Type)
+	typed, err := parseMetadataValue(valueType, entry.Value)
+	if err != nil {
+		return fmt.Errorf("metadata %s %v", entry.Key, err)
+	}
+
+	_, err = db.Exec(
+		"INSERT INTO metadata (file_id, key, value, value_type, value_number, value_time) VALUES (?, ?, ?, ?, ?, ?)",
+		fileID, entry.Key, typed.Value, valueType, typed.Number, typed.Time,
+	)
+	if err != nil {
+		log.Printf("Error saving metadata to database: %v", err)
+		return serviceErrorf(errInternal, "failed to save metadata: %v", err)
+	}
+	return nil
+}
+
+// metadataModels gör om validerade nyckel/värde-par till GraphQL-modellen
+func metadataModels(entries []metadataEntry) []*model.Metadata {
+	metadata := make([]*model.Metadata, len(entries))
+	for i, entry := range entries {
+		metadata[i] = &model.Metadata{Key: entry.Key, Value: entry.Value, Type: metadataValueTypeOrDefault(entry.Type)}
+	}
+	return metadata
+}
+
+// =============================================
+// ========== SÖKNING PÅ METADATA ============
+// =============================================
+
+// metadataFilterCondition bygger ett SQL-villkor för ett filter mot metadatatabellen table, där
+// ownerColumn kopplas till ownerRef i den yttre frågan. Intervall jämförs mot de typade
+// kolumnerna, så att t.ex. belopp jämförs som tal och datum som datum.
+func metadataFilterCondition(filter *model.MetadataFilter, table string, ownerColumn string, ownerRef string) (string, []interface{}, error) {
+	conditions := []string{"m." + ownerColumn + " = " + ownerRef, "m.key = ?"}
+	args := []interface{}{filter.Key}
+
+	valueType := model.MetadataValueTypeString
+	if filter.Type != nil {
+		valueType = *filter.Type
+		conditions = append(conditions, "m.value_type = ?")
+		args = append(args, valueType)
+	} else if filter.Min != nil || filter.Max != nil {
+		return "", nil, fmt.Errorf("filter on %s needs a type to compare a range", filter.Key)
+	}
+
+	column := "m.value"
+	switch valueType {
+	case model.MetadataValueTypeInteger, model.MetadataValueTypeDecimal, model.MetadataValueTypeBoolean:
+		column = "m.value_number"
+	case model.MetadataValueTypeDate, model.MetadataValueTypeDatetime:
+		column = "m.value_time"
+	}
+	if (filter.Min != nil || filter.Max != nil) && (valueType == model.MetadataValueTypeBoolean ||
+		valueType == model.MetadataValueTypeUser || valueType == model.MetadataValueTypeNode) {
+		return "", nil, fmt.Errorf("filter on %s: values of type %s cannot be compared as a range", filter.Key, valueType)
+	}
+
+	bound := func(value string) (interface{}, error) {
+		// Datum går att använda som gräns även för tidpunkter
+		if valueType == model.MetadataValueTypeDatetime {
+			if _, err := time.Parse("2006-01-02", strings.TrimSpace(value)); err == nil {
+				return strings.TrimSpace(value), nil
+			}
+		}
+		typed, err := parseMetadataValue(valueType, value)
+		if err != nil {
+			return nil, fmt.Errorf("filter on %s: value %v", filter.Key, err)
+		}
+		switch {
+		case typed.Number != nil:
+			return typed.Number, nil
+		case typed.Time != nil:
+			return typed.Time, nil
+		}
+		return typed.Value, nil
+	}
+
+	if filter.Equals != nil {
+		value, err := bound(*filter.Equals)
+		if err != nil {
+			return "", nil, err
+		}
+		conditions = append(conditions, column+" = ?")
+		args = append(args, value)
+	}
+	if filter.Min != nil {
+		value, err := bound(*filter.Min)
+		if err != nil {
+			return "", nil, err
+		}
+		conditions = append(conditions, column+" >= ?")
+		args = append(args, value)
+	}
+	if filter.Max != nil {
+		value, err := bound(*filter.Max)
+		if err != nil {
+			return "", nil, err
+		}
+		if text, ok := value.(string); ok && column == "m.value_time" {
+			// Jämför bara så många tecken som gränsen har, så att ett datum tar med hela dagen
+			conditions = append(conditions, "substr(m.value_time, 1, length(?)) <= ?")
+			args = append(args, text, text)
+		} else {
+			conditions = append(conditions, column+" <= ?")
+			args = append(args, value)
+		}
+	}
+
+	return "EXISTS (SELECT 1 FROM " + table + " m WHERE " + strings.Join(conditions, " AND ") + ")", args, nil
+}
+
+// subtreeNodeIDs hämtar en nod och alla noder under den
+func subtreeNodeIDs(db sqlQueryer, nodeID string) ([]string, error) {
+	nodeIDs := []string{}
+	pending := []string{nodeID}
+	visited := make(map[string]bool)
+	for len(pending) > 0 {
+		currentID := pending[0]
+		pending = pending[1:]
+		if visited[currentID] {
+			continue
+		}
+		visited[currentID] = true
+		nodeIDs = append(nodeIDs, currentID)
+
+		rows, err := db.Query("SELECT id FROM nodes WHERE parent_id = ?", currentID)
+		if err != nil {
+			log.Printf("Error fetching child nodes of %s: %v", currentID, err)
+			return nil, serviceErrorf(errInternal, "failed to fetch child nodes: %v", err)
+		}
+		childIDs, err := scanIDs(rows)
+		if err != nil {
+			return nil, err
+		}
+		pending = append(pending, childIDs...)
+	}
+	return nodeIDs, nil
+}
+
+// sortByMetadata sorterar filer eller noder efter det första värdet för en nyckel, jämfört enligt
+// värdets typ. Poster utan värde hamnar sist oavsett sorteringsordning.
+func sortByMetadata[T comparable](items []T, metadataOf func(T) []*model.Metadata, key string, descending bool) {
+	type sortValue struct {
+		present bool
+		number  float64
+		text    string
+		numeric bool
+	}
+
+	values := make(map[T]sortValue, len(items))
+	for _, item := range items {
+		for _, meta := range metadataOf(item) {
+			if meta.Key != key {
+				continue
+			}
+			value := sortValue{present: true, text: meta.Value}
+			if typed, err := parseMetadataValue(meta.Type, meta.Value); err == nil {
+				switch number := typed.Number.(type) {
+				case int64:
+					value.number, value.numeric = float64(number), true
+				case float64:
+					value.number, value.numeric = number, true
+				}
+				if text, ok := typed.Time.(string); ok {
+					value.text = text
+				}
+			}
+			values[item] = value
+			break
+		}
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		a, b := values[items[i]], values[items[j]]
+		if a.present != b.present {
+			return a.present
+		}
+		if !a.present {
+			return false
+		}
+
+		var less, greater bool
+		if a.numeric && b.numeric {
+			less, greater = a.number < b.number, a.number > b.number
+		} else {
+			less, greater = a.text < b.text, a.text > b.text
+		}
+		if descending {
+			return greater
+		}
+		return less
+	})
+}
+
+// pageBounds ger start och slut för en sida av total poster, med 100 poster som standard
+func pageBounds(total int, limit *int, offset *int) (int, int) {
+	start, count := 0, 100
+	if offset != nil && *offset > 0 {
+		start = *offset
+	}
+	if limit != nil && *limit >= 0 {
+		count = *limit
+	}
+	if start > total {
+		start = total
+	}
+	end := start + count
+	if end > total {
+		end = total
+	}
+	return start, end
+}
+
+// scanMetadataRow läser en rad med key, value och value_type från metadata
+func scanMetadataRow(rows *sql.Rows) (*model.Metadata, error) {
+	var meta model.Metadata
+	var valueType string
+	if err := rows.Scan(&meta.Key, &meta.Value, &valueType); err != nil {
+		log.Printf("Error scanning metadata row: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to scan metadata row: %v", err)
+	}
+	meta.Type = metadataValueTypeOrDefault(model.MetadataValueType(valueType))
+	return &meta, nil
+}
@@ -0,0 +1,156 @@
+package graph
+
+// This file will be automatically regenerated based on the schema, any resolver implementations
+// will be copied through when generating and any unknown code will be moved to the end.
+// Code generated by github.com/99designs/gqlgen version v0.17.69
+
+import (
+	"context"
+	"graphql-backend/graph/model"
+	"log"
+	"strconv"
+	"strings"
+)
+
+// IntegerValue is the resolver for the integerValue field.
+func (r *metadataResolver) IntegerValue(ctx context.Context, obj *model.Metadata) (*int, error) {
+	if obj.Type != model.MetadataValueTypeInteger {
+		return nil, nil
+	}
+	value, err := strconv.Atoi(obj.Value)
+	if err != nil {
+		return nil, nil
+	}
+	return &value, nil
+}
+
+// DecimalValue is the resolver for the decimalValue field.
+func (r *metadataResolver) DecimalValue(ctx context.Context, obj *model.Metadata) (*float64, error) {
+	if obj.Type != model.MetadataValueTypeInteger && obj.Type != model.MetadataValueTypeDecimal {
+		return nil, nil
+	}
+	value, err := strconv.ParseFloat(obj.Value, 64)
+	if err != nil {
+		return nil, nil
+	}
+	return &value, nil
+}
+
+// BooleanValue is the resolver for the booleanValue field.
+func (r *metadataResolver) BooleanValue(ctx context.Context, obj *model.Metadata) (*bool, error) {
+	if obj.Type != model.MetadataValueTypeBoolean {
+		return nil, nil
+	}
+	value, err := strconv.ParseBool(obj.Value)
+	if err != nil {
+		return nil, nil
+	}
+	return &value, nil
+}
+
+// ReferencedUser is the resolver for the referencedUser field.
+func (r *metadataResolver) ReferencedUser(ctx context.Context, obj *model.Metadata) (*model.User, error) {
+	if obj.Type != model.MetadataValueTypeUser || r.DB == nil {
+		return nil, nil
+	}
+	return getUserByID(r.DB, obj.Value)
+}
+
+// ReferencedNode is the resolver for the referencedNode field.
+func (r *metadataResolver) ReferencedNode(ctx context.Context, obj *model.Metadata) (*model.Node, error) {
+	if obj.Type != model.MetadataValueTypeNode || r.DB == nil {
+		return nil, nil
+	}
+
+	// Noder som tagits bort eller som användaren inte får se visas inte
+	hasPermission, err := checkPermission(ctx, r.DB, obj.Value, PERM_VIEW)
+	if err != nil || !hasPermission {
+		return nil, nil
+	}
+	return getNodeWithPermissions(ctx, r.DB, obj.Value)
+}
+
+// SearchFiles is the resolver for the searchFiles field.
+func (r *queryResolver) SearchFiles(ctx context.Context, nodeID *string, filters []*model.MetadataFilter, nodeFilters []*model.MetadataFilter, sort *model.MetadataSort, limit *int, offset *int) ([]*model.File, error) {
+	logAction("Searching files by metadata")
+
+	if r.DB == nil {
+		log.Printf("Database connection is nil")
+		return nil, serviceErrorf(errInternal, "internal server error: database connection is not initialized")
+	}
+
+	if _, err := getUserIDFromContext(ctx); err != nil {
+		return nil, err
+	}
+
+	conditions := []string{"1 = 1"}
+	args := []interface{}{}
+
+	if nodeID != nil {
+		nodeIDs, err := subtreeNodeIDs(r.DB, *nodeID)
+		if err != nil {
+			return nil, err
+		}
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(nodeIDs)), ", ")
+		conditions = append(conditions, "node_id IN ("+placeholders+")")
+		for _, id := range nodeIDs {
+			args = append(args, id)
+		}
+	}
+
+	for _, filter := range filters {
+		condition, filterArgs, err := metadataFilterCondition(filter, "metadata", "file_id", "files.id")
+		if err != nil {
+			return nil, err
+		}
+		conditions = append(conditions, condition)
+		args = append(args, filterArgs...)
+	}
+
+	// Filter på metadata i filens nod, t.ex. status på ärendemappen
+	for _, filter := range nodeFilters {
+		condition, filterArgs, err := metadataFilterCondition(filter, "node_metadata", "node_id", "files.node_id")
+		if err != nil {
+			return nil, err
+		}
+		conditions = append(conditions, condition)
+		args = append(args, filterArgs...)
+	}
+
+	files, err := queryTreeFiles(r.DB, strings.Join(conditions, " AND "), args...)
+	if err != nil {
+		return nil, err
+	}
+
+	// Endast filer i noder som användaren får se tas med
+	visible := []*treeFile{}
+	permissions := make(map[string]bool)
+	for _, file := range files {
+		if file.NodeID != "" {
+			allowed, checked := permissions[file.NodeID]
+			if !checked {
+				allowed, err = checkPermission(ctx, r.DB, file.NodeID, PERM_VIEW)
+				if err != nil {
+					return nil, err
+				}
+				permissions[file.NodeID] = allowed
+			}
+			if !allowed {
+				continue
+			}
+		}
+		visible = append(visible, file)
+	}
+
+	if sort != nil {
+		sortByMetadata(visible, func(file *treeFile) []*model.Metadata { return file.Metadata }, sort.Key, sort.Descending != nil && *sort.Descending)
+	}
+
+	start, end := pageBounds(len(visible), limit, offset)
+
+	result := make([]*model.File, 0, end-start)
+	for _, file := range visible[start:end] {
+		result = append(result, treeFileModel(file))
+	}
+	return result, nil
+}
@@ -0,0 +1,201 @@
+package graph
+
+import (
+	"encoding/base64"
+	"graphql-backend/graph/model"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// typedMetadata bygger metadataindata av en given typ
+func typedMetadata(key string, valueType model.MetadataValueType, value string) *model.MetadataInput {
+	return &model.MetadataInput{Key: key, Value: value, Type: &valueType}
+}
+
+// metadataSummary skriver en fils metadata som "nyckel:typ=värde" i sparad ordning
+func metadataSummary(metadata []*model.Metadata) string {
+	parts := make([]string, len(metadata))
+	for i, meta := range metadata {
+		parts[i] = meta.Key + ":" + string(meta.Type) + "=" + meta.Value
+	}
+	return strings.Join(parts, " ")
+}
+
+func TestParseMetadataValue(t *testing.T) {
+	tests := []struct {
+		valueType model.MetadataValueType
+		value     string
+		want      string
+		number    interface{}
+		time      interface{}
+	}{
+		{model.MetadataValueTypeString, "  mellanslag behålls ", "  mellanslag behålls ", nil, nil},
+		{"", "utan typ", "utan typ", nil, nil},
+		{model.MetadataValueTypeInteger, " 007 ", "7", int64(7), nil},
+		{model.MetadataValueTypeDecimal, "12.50", "12.50", 12.5, nil},
+		{model.MetadataValueTypeDecimal, "-1e3", "-1e3", -1000.0, nil},
+		{model.MetadataValueTypeDate, "2026-03-01", "2026-03-01", nil, "2026-03-01"},
+		{model.MetadataValueTypeDatetime, "2026-03-01T01:30:00+02:00", "2026-03-01T01:30:00+02:00", nil, "2026-02-28T23:30:00Z"},
+		{model.MetadataValueTypeBoolean, "TRUE", "true", int64(1), nil},
+		{model.MetadataValueTypeBoolean, "0", "false", int64(0), nil},
+		{model.MetadataValueTypeUser, " 1 ", "1", nil, nil},
+	}
+	for _, tt := range tests {
+		typed, err := parseMetadataValue(tt.valueType, tt.value)
+		if err != nil {
+			t.Errorf("parse %s %q: %v", tt.valueType, tt.value, err)
+			continue
+		}
+		if typed.Value != tt.want || typed.Number != tt.number || typed.Time != tt.time {
+			t.Errorf("parse %s %q = %q (%v, %v), want %q (%v, %v)", tt.valueType, tt.value, typed.Value, typed.Number, typed.Time, tt.want, tt.number, tt.time)
+		}
+	}
+
+	invalid := map[model.MetadataValueType]string{
+		model.MetadataValueTypeInteger:  "1.5",
+		model.MetadataValueTypeDecimal:  "NaN",
+		model.MetadataValueTypeDate:     "2026-02-30",
+		model.MetadataValueTypeDatetime: "2026-03-01 10:00",
+		model.MetadataValueTypeBoolean:  "ja",
+		model.MetadataValueTypeNode:     " ",
+	}
+	for valueType, value := range invalid {
+		if typed, err := parseMetadataValue(valueType, value); err == nil {
+			t.Errorf("parse %s %q = %q, want an error", valueType, value, typed.Value)
+		}
+	}
+}
+
+func TestTypedMetadataRoundTrip(t *testing.T) {
+	db := openTestDB(t)
+	ctx := testAdminContext(t)
+	mutation := NewResolver(db).Mutation()
+	root := createTestNode(t, db, "Fakturor", nil)
+
+	metadata := []*model.MetadataInput{
+		typedMetadata("belopp", model.MetadataValueTypeDecimal, "1250.50"),
+		typedMetadata("antal", model.MetadataValueTypeInteger, "3"),
+		typedMetadata("forfallodag", model.MetadataValueTypeDate, "2026-04-30"),
+		typedMetadata("mottagen", model.MetadataValueTypeDatetime, "2026-03-01T09:15:00+01:00"),
+		typedMetadata("betald", model.MetadataValueTypeBoolean, "false"),
+		typedMetadata("attestant", model.MetadataValueTypeUser, "1"),
+		typedMetadata("arende", model.MetadataValueTypeNode, root.ID),
+		{Key: "anteckning", Value: "0012.50"},
+	}
+	want := "belopp:DECIMAL=1250.50 antal:INTEGER=3 forfallodag:DATE=2026-04-30 mottagen:DATETIME=2026-03-01T09:15:00+01:00 " +
+		"betald:BOOLEAN=false attestant:USER=1 arende:NODE=" + root.ID + " anteckning:STRING=0012.50"
+
+	saved, err := mutation.SaveFile(ctx, model.FileInput{
+		Name: "faktura.txt", Size: 7, ContentType: "text/plain",
+		FileData: base64.StdEncoding.EncodeToString([]byte("faktura")), NodeID: &root.ID, Metadata: metadata,
+	})
+	if err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	file, err := NewResolver(db).Query().GetFile(ctx, saved.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := metadataSummary(file.Metadata); got != want {
+		t.Errorf("metadata after save:\n%s\nwant:\n%s", got, want)
+	}
+
+	// Typerna följer med genom en export och import som BagIt-paket
+	bagDir := filepath.Join(t.TempDir(), "bag")
+	if _, err := ExportBag(db, root.ID, bagDir, "1"); err != nil {
+		t.Fatalf("export bag: %v", err)
+	}
+	target := createTestNode(t, db, "Mottaget", nil)
+	result, err := ImportBag(db, bagDir, target.ID, "1")
+	if err != nil {
+		t.Fatalf("import bag: %v", err)
+	}
+	files, err := NewResolver(db).Query().GetFilesByNodeID(ctx, result.NodeID)
+	if err != nil || len(files) != 1 {
+		t.Fatalf("imported files = %v, %v", files, err)
+	}
+	if got := metadataSummary(files[0].Metadata); got != want {
+		t.Errorf("metadata after bag round trip:\n%s\nwant:\n%s", got, want)
+	}
+
+	resolver := NewResolver(db).Metadata()
+	for _, meta := range file.Metadata {
+		switch meta.Key {
+		case "belopp":
+			if value, _ := resolver.DecimalValue(ctx, meta); value == nil || *value != 1250.5 {
+				t.Errorf("decimalValue = %v", value)
+			}
+		case "antal":
+			if value, _ := resolver.IntegerValue(ctx, meta); value == nil || *value != 3 {
+				t.Errorf("integerValue = %v", value)
+			}
+		case "betald":
+			if value, _ := resolver.BooleanValue(ctx, meta); value == nil || *value {
+				t.Errorf("booleanValue = %v", value)
+			}
+		case "arende":
+			if node, _ := resolver.ReferencedNode(ctx, meta); node == nil || node.ID != root.ID {
+				t.Errorf("referencedNode = %v", node)
+			}
+		}
+	}
+
+	if _, err := mutation.UpdateMetadata(ctx, file.ID, []*model.MetadataInput{typedMetadata("attestant", model.MetadataValueTypeUser, "999")}); err == nil || !strings.Contains(err.Error(), "refers to user 999, which does not exist") {
+		t.Errorf("reference to missing user: err = %v", err)
+	}
+}
+
+func TestSearchFilesByTypedMetadata(t *testing.T) {
+	db := openTestDB(t)
+	ctx := testAdminContext(t)
+	query := NewResolver(db).Query()
+	root := createTestNode(t, db, "Fakturor", nil)
+
+	for _, invoice := range []struct{ name, amount, received string }{
+		{"a.txt", "9.50", "2026-03-01T23:30:00-02:00"},
+		{"b.txt", "10", "2026-03-01T10:00:00Z"},
+		{"c.txt", "100.25", "2026-03-03T08:00:00+01:00"},
+	} {
+		_, err := NewResolver(db).Mutation().SaveFile(ctx, model.FileInput{
+			Name: invoice.name, Size: 1, ContentType: "text/plain", FileData: base64.StdEncoding.EncodeToString([]byte("x")), NodeID: &root.ID,
+			Metadata: []*model.MetadataInput{
+				typedMetadata("belopp", model.MetadataValueTypeDecimal, invoice.amount),
+				typedMetadata("mottagen", model.MetadataValueTypeDatetime, invoice.received),
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	decimal, datetime := model.MetadataValueTypeDecimal, model.MetadataValueTypeDatetime
+	search := func(filter *model.MetadataFilter, sort *model.MetadataSort) string {
+		t.Helper()
+		files, err := query.SearchFiles(ctx, &root.ID, []*model.MetadataFilter{filter}, nil, sort, nil, nil)
+		if err != nil {
+			t.Fatalf("search: %v", err)
+		}
+		names := make([]string, len(files))
+		for i, file := range files {
+			names[i] = file.Name
+		}
+		return strings.Join(names, ",")
+	}
+
+	// Belopp jämförs som tal, inte som text där "9.50" skulle vara större än "10"
+	if got := search(&model.MetadataFilter{Key: "belopp", Type: &decimal, Min: strPtr("9"), Max: strPtr("50")}, nil); got != "a.txt,b.txt" {
+		t.Errorf("amounts between 9 and 50 = %s", got)
+	}
+	if got := search(&model.MetadataFilter{Key: "belopp", Type: &decimal}, &model.MetadataSort{Key: "belopp", Descending: boolPtr(true)}); got != "c.txt,b.txt,a.txt" {
+		t.Errorf("sorted by amount = %s", got)
+	}
+	// Tidpunkter jämförs i UTC, och ett datum som övre gräns tar med hela dagen
+	if got := search(&model.MetadataFilter{Key: "mottagen", Type: &datetime, Min: strPtr("2026-03-02"), Max: strPtr("2026-03-03")}, nil); got != "a.txt,c.txt" {
+		t.Errorf("received 2–3 March (UTC) = %s", got)
+	}
+
+	if _, err := query.SearchFiles(ctx, &root.ID, []*model.MetadataFilter{{Key: "belopp", Min: strPtr("1")}}, nil, nil, nil, nil); err == nil || !strings.Contains(err.Error(), "needs a type to compare a range") {
+		t.Errorf("range without type: err = %v", err)
+	}
+}
@@ -0,0 +1,119 @@
+package graph
+
+import (
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"log"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// =============================================
+// ========== DATABASMIGRERINGAR ==============
+// =============================================
+
+// migrationFiles innehåller migreringarna för varje dialekt, i filer som heter
+// <version>_<namn>.sql. Samma version ska finnas för båda dialekterna och ge samma schema.
+//
+//go:embed migrations/sqlite/*.sql migrations/postgres/*.sql
+var migrationFiles embed.FS
+
+// migration är en schemaändring som körs en gång per databas
+type migration struct {
+	version int
+	name    string
+	script  string
+}
+
+// loadMigrations läser migreringarna för en dialekt, sorterade efter version
+func loadMigrations(dialect Dialect) ([]migration, error) {
+	dir := path.Join("migrations", string(dialect))
+	entries, err := fs.ReadDir(migrationFiles, dir)
+	if err != nil {
+		return nil, fmt.Errorf("no migrations for %s: %v", dialect, err)
+	}
+
+	var migrations []migration
+	for _, entry := range entries {
+		prefix, name, ok := strings.Cut(strings.TrimSuffix(entry.Name(), ".sql"), "_")
+		version, err := strconv.Atoi(prefix)
+		if !ok || err != nil {
+			return nil, fmt.Errorf("invalid migration file name %s", entry.Name())
+		}
+		script, err := migrationFiles.ReadFile(path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, serviceErrorf(errInternal, "failed to read migration %s: %v", entry.Name(), err)
+		}
+		migrations = append(migrations, migration{version: version, name: name, script: string(script)})
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].version < migrations[j].version })
+	return migrations, nil
+}
+
+// Migrate för databasen till senaste schemaversion. Körda migreringar registreras i
+// schema_migrations, så att varje migrering bara körs en gång.
+func Migrate(db *sql.DB, dialect Dialect) error {
+	migrations, err := loadMigrations(dialect)
+	if err != nil {
+		return err
+	}
+
+	if _, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			name TEXT NOT NULL,
+			applied_at TEXT NOT NULL
+		)
+	`); err != nil {
+		return serviceErrorf(errInternal, "failed to create schema_migrations: %v", err)
+	}
+
+	for _, m := range migrations {
+		applied, err := applyMigration(db, dialect, m)
+		if err != nil {
+			return fmt.Errorf("migration %d (%s) failed: %v", m.version, m.name, err)
+		}
+		if applied {
+			log.Printf("Applied %s migration %d (%s)", dialect, m.version, m.name)
+		}
+	}
+	return nil
+}
+
+// applyMigration kör en migrering i en transaktion om den inte redan körts
+func applyMigration(db *sql.DB, dialect Dialect, m migration) (bool, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	// Flera servrar mot samma PostgreSQL-databas får inte köra samma migrering samtidigt
+	if dialect == DialectPostgres {
+		if _, err := tx.Exec("LOCK TABLE schema_migrations IN EXCLUSIVE MODE"); err != nil {
+			return false, err
+		}
+	}
+
+	var applied bool
+	if err := tx.QueryRow("SELECT EXISTS(SELECT 1 FROM schema_migrations WHERE version = ?)", m.version).Scan(&applied); err != nil {
+		return false, err
+	}
+	if applied {
+		return false, nil
+	}
+
+	if _, err := tx.Exec(m.script); err != nil {
+		return false, err
+	}
+	if _, err := tx.Exec("INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)",
+		m.version, m.name, time.Now().UTC().Format(time.RFC3339)); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
@@ -0,0 +1,484 @@
+-- Ursprungligt schema för PostgreSQL: samma tabeller, index och grunddata som för SQLite.
+-- Löpnummer blir SERIAL och binärdata BYTEA. Flaggor och tidsstämplar lagras som heltal och
+-- text precis som i SQLite, så att frågorna fungerar oförändrade mot båda dialekterna.
+
+-- Create users table for authentication
+CREATE TABLE IF NOT EXISTS users (
+    id SERIAL PRIMARY KEY,
+    username TEXT NOT NULL UNIQUE,
+    name TEXT,
+    password_hash TEXT NOT NULL,
+    created_at TEXT NOT NULL
+);
+
+-- Create table for user groups
+CREATE TABLE IF NOT EXISTS groups (
+    id SERIAL PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE,
+    created_at TEXT NOT NULL
+);
+
+-- Create table for group members (relation between users and groups)
+CREATE TABLE IF NOT EXISTS group_members (
+    user_id INTEGER NOT NULL,
+    group_id INTEGER NOT NULL,
+    created_at TEXT NOT NULL,
+    PRIMARY KEY (user_id, group_id),
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+    FOREIGN KEY (group_id) REFERENCES groups (id) ON DELETE CASCADE
+);
+
+-- Create index for faster group membership lookup
+CREATE INDEX IF NOT EXISTS idx_group_members_user_id ON group_members(user_id);
+CREATE INDEX IF NOT EXISTS idx_group_members_group_id ON group_members(group_id);
+-- Create unique constraint to prevent duplicate memberships
+CREATE UNIQUE INDEX IF NOT EXISTS idx_group_members_unique ON group_members(user_id, group_id);
+
+-- Create table for retention rules (bevaringstid och kassasjonsvedtak)
+-- trigger_field anger vilket datum bevaringstiden räknas från, disposal_action är DESTROY, REVIEW eller RETAIN
+CREATE TABLE IF NOT EXISTS retention_rules (
+    id SERIAL PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE,
+    retention_years INTEGER NOT NULL,
+    trigger_field TEXT NOT NULL DEFAULT 'createdAt',
+    disposal_action TEXT NOT NULL,
+    description TEXT,
+    created_at TEXT NOT NULL
+);
+
+-- Create table for controlled vocabularies and classification schemes
+-- kind är VOCABULARY (platt lista) eller CLASSIFICATION (hierarkiskt klassifikasjonssystem)
+CREATE TABLE IF NOT EXISTS vocabularies (
+    id SERIAL PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE,
+    description TEXT,
+    kind TEXT NOT NULL DEFAULT 'VOCABULARY',
+    created_at TEXT NOT NULL,
+    updated_at TEXT NOT NULL
+);
+
+-- Create table for the terms of a vocabulary
+-- code är värdet som sparas i metadata och fält, alt_labels är en JSON-lista med alternativa namn
+CREATE TABLE IF NOT EXISTS vocabulary_terms (
+    id SERIAL PRIMARY KEY,
+    vocabulary_id INTEGER NOT NULL,
+    code TEXT NOT NULL,
+    label TEXT NOT NULL,
+    alt_labels TEXT,
+    parent_id INTEGER, -- Överordnad term i ett klassifikasjonssystem
+    description TEXT,
+    uri TEXT,
+    FOREIGN KEY (vocabulary_id) REFERENCES vocabularies (id) ON DELETE CASCADE,
+    FOREIGN KEY (parent_id) REFERENCES vocabulary_terms (id)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_vocabulary_terms_code ON vocabulary_terms(vocabulary_id, code);
+CREATE INDEX IF NOT EXISTS idx_vocabulary_terms_parent ON vocabulary_terms(parent_id);
+
+-- Create table for Noark 5 node type fields whose values must come from a vocabulary
+CREATE TABLE IF NOT EXISTS node_type_field_vocabularies (
+    node_type TEXT NOT NULL,
+    field_name TEXT NOT NULL,
+    vocabulary_id INTEGER NOT NULL,
+    PRIMARY KEY (node_type, field_name),
+    FOREIGN KEY (vocabulary_id) REFERENCES vocabularies (id)
+);
+
+-- Create table for metadata schemas that nodes can require for their files
+CREATE TABLE IF NOT EXISTS metadata_schemas (
+    id SERIAL PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE,
+    description TEXT,
+    allow_additional_keys INTEGER NOT NULL DEFAULT 0,
+    created_at TEXT NOT NULL,
+    updated_at TEXT NOT NULL
+);
+
+-- Create table for the fields of a metadata schema
+-- field_type är STRING, NUMBER, DATE eller ENUM, allowed_values är en JSON-lista för ENUM
+CREATE TABLE IF NOT EXISTS metadata_schema_fields (
+    id SERIAL PRIMARY KEY,
+    schema_id INTEGER NOT NULL,
+    position INTEGER NOT NULL,
+    name TEXT NOT NULL,
+    field_type TEXT NOT NULL,
+    required INTEGER NOT NULL DEFAULT 0,
+    repeatable INTEGER NOT NULL DEFAULT 0,
+    pattern TEXT,
+    allowed_values TEXT,
+    vocabulary_id INTEGER, -- Värdena måste vara termer i vokabulären
+    description TEXT,
+    FOREIGN KEY (schema_id) REFERENCES metadata_schemas (id) ON DELETE CASCADE,
+    FOREIGN KEY (vocabulary_id) REFERENCES vocabularies (id)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_metadata_schema_fields ON metadata_schema_fields(schema_id, name);
+
+-- Create table for hierarchical nodes structure with permission support
+CREATE TABLE IF NOT EXISTS nodes (
+    id SERIAL PRIMARY KEY,
+    name TEXT NOT NULL,
+    parent_id INTEGER,
+    owner_user_id INTEGER,
+    owner_group_id INTEGER,
+    permissions INTEGER DEFAULT 63, -- Default: 111111 binary (all permissions)
+    node_type TEXT NOT NULL DEFAULT 'FOLDER', -- Noark 5: FOLDER, ARKIV, ARKIVDEL, KLASSE, SAKSMAPPE
+    retention_rule_id INTEGER, -- Bevaringsregel för noden och undernoder utan egen regel
+    access_logging INTEGER, -- 1 = logga läsningar, 0 = logga inte, NULL = ärv från föräldern
+    metadata_schema_id INTEGER, -- Metadataschema för filer i noden och undernoder utan eget schema
+    published INTEGER, -- 1 = filerna skördas via OAI-PMH, 0 = skördas inte, NULL = ärv från föräldern
+    created_at TEXT NOT NULL,
+    updated_at TEXT NOT NULL,
+    FOREIGN KEY (parent_id) REFERENCES nodes (id) ON DELETE RESTRICT,
+    FOREIGN KEY (retention_rule_id) REFERENCES retention_rules (id) ON DELETE RESTRICT,
+    FOREIGN KEY (metadata_schema_id) REFERENCES metadata_schemas (id) ON DELETE RESTRICT,
+    FOREIGN KEY (owner_user_id) REFERENCES users (id) ON DELETE SET NULL,
+    FOREIGN KEY (owner_group_id) REFERENCES groups (id) ON DELETE SET NULL
+);
+
+-- Index på parent_id för snabbare sökning
+CREATE INDEX IF NOT EXISTS idx_nodes_parent_id ON nodes(parent_id);
+-- Index för snabbare sökning på ägare
+CREATE INDEX IF NOT EXISTS idx_nodes_owner_user_id ON nodes(owner_user_id);
+CREATE INDEX IF NOT EXISTS idx_nodes_owner_group_id ON nodes(owner_group_id);
+
+-- Create user settings table
+CREATE TABLE IF NOT EXISTS user_settings (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    key TEXT NOT NULL,
+    value TEXT NOT NULL,
+    created_at TEXT NOT NULL,
+    updated_at TEXT NOT NULL,
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+-- Create table for storing files with binary content and node relationship
+CREATE TABLE IF NOT EXISTS files (
+    id SERIAL PRIMARY KEY,
+    name TEXT NOT NULL,
+    size INTEGER NOT NULL,
+    content_type TEXT NOT NULL,
+    created_at TEXT NOT NULL,
+    file_data BYTEA,
+    node_id INTEGER DEFAULT 1,
+    file_type TEXT NOT NULL DEFAULT 'DOKUMENT', -- Noark 5: DOKUMENT, JOURNALPOST
+    checksum TEXT, -- SHA256 beräknad när filen togs emot, används vid fixitetskontroll
+    puid TEXT, -- PRONOM-identifierare för det identifierade formatet, NULL om okänt
+    format_mime_type TEXT, -- MIME-typ enligt signaturregistret
+    format_mismatch INTEGER NOT NULL DEFAULT 0, -- 1 om content_type inte stämmer med identifierat format
+    updated_at TEXT, -- Senaste ändring av metadata, typ eller placering, NULL om filen inte ändrats sedan den togs emot
+    FOREIGN KEY (node_id) REFERENCES nodes (id)
+);
+
+-- Create index for faster node-based file queries
+CREATE INDEX IF NOT EXISTS idx_files_node_id ON files(node_id);
+
+-- Create table for storing metadata associated with files
+CREATE TABLE IF NOT EXISTS metadata (
+    id SERIAL PRIMARY KEY,
+    file_id INTEGER NOT NULL,
+    key TEXT NOT NULL,
+    value TEXT NOT NULL, -- Värdet exakt som det returneras till klienter
+    value_type TEXT NOT NULL DEFAULT 'STRING', -- STRING, INTEGER, DECIMAL, DATE, DATETIME, BOOLEAN, USER eller NODE
+    value_number NUMERIC, -- Tal för INTEGER, DECIMAL och BOOLEAN, för sortering och intervallsökning
+    value_time TEXT, -- Datum (YYYY-MM-DD) eller UTC-tidpunkt för DATE och DATETIME
+    FOREIGN KEY (file_id) REFERENCES files (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_metadata_key_number ON metadata(key, value_number);
+CREATE INDEX IF NOT EXISTS idx_metadata_key_time ON metadata(key, value_time);
+
+-- Create table for metadata on nodes, e.g. case number and status of a case folder
+CREATE TABLE IF NOT EXISTS node_metadata (
+    id SERIAL PRIMARY KEY,
+    node_id INTEGER NOT NULL,
+    key TEXT NOT NULL,
+    value TEXT NOT NULL,
+    value_type TEXT NOT NULL DEFAULT 'STRING',
+    value_number NUMERIC,
+    value_time TEXT,
+    inheritable INTEGER NOT NULL DEFAULT 0, -- 1 om filer som laddas upp under noden får värdet som standard
+    FOREIGN KEY (node_id) REFERENCES nodes (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_node_metadata_node_id ON node_metadata(node_id);
+CREATE INDEX IF NOT EXISTS idx_node_metadata_key_number ON node_metadata(key, value_number);
+CREATE INDEX IF NOT EXISTS idx_node_metadata_key_time ON node_metadata(key, value_time);
+
+-- Create table for Noark 5 fields on typed nodes and files
+-- entity_kind är 'node' eller 'file', entity_id är nodens eller filens ID
+CREATE TABLE IF NOT EXISTS typed_fields (
+    id SERIAL PRIMARY KEY,
+    entity_kind TEXT NOT NULL,
+    entity_id INTEGER NOT NULL,
+    name TEXT NOT NULL,
+    value TEXT NOT NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_typed_fields_entity ON typed_fields(entity_kind, entity_id, name);
+
+-- Create table for PREMIS preservation events (ingestion, fixity check, migration)
+CREATE TABLE IF NOT EXISTS preservation_events (
+    id SERIAL PRIMARY KEY,
+    file_id INTEGER NOT NULL,
+    event_type TEXT NOT NULL,
+    event_date TEXT NOT NULL,
+    outcome TEXT NOT NULL,
+    detail TEXT,
+    agent TEXT,
+    checksum TEXT,
+    FOREIGN KEY (file_id) REFERENCES files (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_preservation_events_file_id ON preservation_events(file_id);
+
+-- Create table for formats accepted in a node (and nodes below it without a list of their own)
+CREATE TABLE IF NOT EXISTS node_accepted_formats (
+    id SERIAL PRIMARY KEY,
+    node_id INTEGER NOT NULL,
+    puid TEXT NOT NULL,
+    FOREIGN KEY (node_id) REFERENCES nodes (id) ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_node_accepted_formats ON node_accepted_formats(node_id, puid);
+
+-- Create table for background jobs (exports and other long-running operations).
+-- Jobs are run from this queue by worker goroutines; payload holds the job parameters as JSON
+-- so that queued and interrupted jobs can run after a restart. input_data holds binary input
+-- such as uploaded archives and is cleared when the job finishes. run_after is in UTC.
+CREATE TABLE IF NOT EXISTS jobs (
+    id SERIAL PRIMARY KEY,
+    type TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'QUEUED',
+    progress INTEGER NOT NULL DEFAULT 0,
+    message TEXT,
+    result TEXT,
+    error TEXT,
+    payload TEXT,
+    input_data BYTEA,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    max_attempts INTEGER NOT NULL DEFAULT 1,
+    run_after TEXT,
+    cancel_requested INTEGER NOT NULL DEFAULT 0,
+    created_by INTEGER,
+    created_at TEXT NOT NULL,
+    updated_at TEXT NOT NULL,
+    started_at TEXT,
+    finished_at TEXT,
+    FOREIGN KEY (created_by) REFERENCES users (id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_jobs_status ON jobs(status, run_after);
+CREATE INDEX IF NOT EXISTS idx_jobs_created_by ON jobs(created_by);
+
+-- Create table for the outcome of each folder and file in a bulk import job
+CREATE TABLE IF NOT EXISTS bulk_import_items (
+    id SERIAL PRIMARY KEY,
+    job_id INTEGER NOT NULL,
+    path TEXT NOT NULL, -- Sökväg i katalogen eller ZIP-arkivet
+    kind TEXT NOT NULL, -- NODE eller FILE
+    status TEXT NOT NULL, -- IMPORTED, SKIPPED eller FAILED
+    node_id INTEGER,
+    file_id INTEGER,
+    message TEXT,
+    FOREIGN KEY (job_id) REFERENCES jobs (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_bulk_import_items_job_id ON bulk_import_items(job_id);
+
+-- Create table for outgoing webhooks; node_id limits the events to a subtree, NULL means the whole archive
+CREATE TABLE IF NOT EXISTS webhooks (
+    id SERIAL PRIMARY KEY,
+    url TEXT NOT NULL,
+    event_types TEXT NOT NULL, -- Kommaseparerade händelsetyper
+    node_id INTEGER,
+    secret TEXT NOT NULL, -- Nyckel för HMAC-signaturen
+    active INTEGER NOT NULL DEFAULT 1,
+    created_by INTEGER,
+    created_at TEXT NOT NULL,
+    updated_at TEXT NOT NULL,
+    FOREIGN KEY (node_id) REFERENCES nodes (id) ON DELETE CASCADE,
+    FOREIGN KEY (created_by) REFERENCES users (id) ON DELETE SET NULL
+);
+
+-- Create table for webhook deliveries; pending deliveries are sent by a worker and retried
+-- with back-off, and the last response is kept as a delivery log. next_attempt_at is in UTC.
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id SERIAL PRIMARY KEY,
+    webhook_id INTEGER NOT NULL,
+    event_type TEXT NOT NULL,
+    payload TEXT NOT NULL, -- JSON-kroppen som signeras och skickas
+    status TEXT NOT NULL DEFAULT 'PENDING', -- PENDING, SENDING, DELIVERED eller FAILED
+    attempts INTEGER NOT NULL DEFAULT 0,
+    max_attempts INTEGER NOT NULL DEFAULT 1,
+    next_attempt_at TEXT,
+    response_status INTEGER,
+    response_body TEXT, -- Början av svaret från mottagaren
+    error TEXT,
+    created_at TEXT NOT NULL,
+    delivered_at TEXT,
+    FOREIGN KEY (webhook_id) REFERENCES webhooks (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_status ON webhook_deliveries(status, next_attempt_at);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_webhook_id ON webhook_deliveries(webhook_id);
+
+-- Create table for legal holds; a hold is active until released_at is set
+CREATE TABLE IF NOT EXISTS legal_holds (
+    id SERIAL PRIMARY KEY,
+    node_id INTEGER NOT NULL,
+    reason TEXT NOT NULL,
+    placed_by INTEGER NOT NULL,
+    placed_at TEXT NOT NULL,
+    released_by INTEGER,
+    released_at TEXT,
+    release_note TEXT,
+    FOREIGN KEY (node_id) REFERENCES nodes (id),
+    FOREIGN KEY (placed_by) REFERENCES users (id),
+    FOREIGN KEY (released_by) REFERENCES users (id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_legal_holds_node_id ON legal_holds(node_id);
+
+-- Create table for disposal requests awaiting approval (PENDING, APPROVED, REJECTED)
+CREATE TABLE IF NOT EXISTS disposal_requests (
+    id SERIAL PRIMARY KEY,
+    status TEXT NOT NULL DEFAULT 'PENDING',
+    reason TEXT,
+    requested_by INTEGER NOT NULL,
+    requested_at TEXT NOT NULL,
+    decided_by INTEGER,
+    decided_at TEXT,
+    decision_note TEXT,
+    FOREIGN KEY (requested_by) REFERENCES users (id),
+    FOREIGN KEY (decided_by) REFERENCES users (id)
+);
+
+CREATE TABLE IF NOT EXISTS disposal_request_files (
+    request_id INTEGER NOT NULL,
+    file_id INTEGER NOT NULL,
+    PRIMARY KEY (request_id, file_id),
+    FOREIGN KEY (request_id) REFERENCES disposal_requests (id) ON DELETE CASCADE
+);
+
+-- Create table for disposal certificates, kept after the file itself has been deleted
+CREATE TABLE IF NOT EXISTS disposal_certificates (
+    id SERIAL PRIMARY KEY,
+    request_id INTEGER NOT NULL,
+    file_id INTEGER NOT NULL,
+    file_name TEXT NOT NULL,
+    node_id INTEGER,
+    file_type TEXT,
+    size INTEGER NOT NULL,
+    checksum TEXT,
+    puid TEXT,
+    rule_name TEXT NOT NULL,
+    retention_years INTEGER NOT NULL,
+    trigger_field TEXT NOT NULL,
+    trigger_date TEXT NOT NULL,
+    disposal_date TEXT NOT NULL,
+    disposed_at TEXT NOT NULL,
+    requested_by TEXT NOT NULL,
+    approved_by TEXT NOT NULL,
+    FOREIGN KEY (request_id) REFERENCES disposal_requests (id)
+);
+
+-- Create table for the audit log; every row is hash-chained to the previous one via prev_hash
+-- Rows are never updated or deleted, and actor/target are stored without foreign keys so they survive deletions
+CREATE TABLE IF NOT EXISTS audit_events (
+    id SERIAL PRIMARY KEY,
+    occurred_at TEXT NOT NULL,
+    actor_id TEXT,
+    actor TEXT,
+    action TEXT NOT NULL,
+    target_type TEXT,
+    target_id TEXT,
+    arguments TEXT,
+    before_value TEXT,
+    after_value TEXT,
+    client_ip TEXT,
+    outcome TEXT NOT NULL,
+    error TEXT,
+    prev_hash TEXT NOT NULL,
+    hash TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_events_target ON audit_events(target_type, target_id);
+
+-- Create table for read access to files in nodes with access logging enabled
+-- file_name and node_id are copied so the history survives deletion of the file
+CREATE TABLE IF NOT EXISTS file_access_log (
+    id SERIAL PRIMARY KEY,
+    file_id INTEGER NOT NULL,
+    file_name TEXT NOT NULL,
+    node_id INTEGER,
+    user_id INTEGER,
+    username TEXT,
+    access_type TEXT NOT NULL,
+    accessed_at TEXT NOT NULL,
+    client_ip TEXT,
+    user_agent TEXT
+);
+
+CREATE INDEX IF NOT EXISTS idx_file_access_log_file_id ON file_access_log(file_id);
+CREATE INDEX IF NOT EXISTS idx_file_access_log_user_id ON file_access_log(user_id);
+
+-- Create table for crosswalks from our metadata to Dublin Core
+CREATE TABLE IF NOT EXISTS crosswalks (
+    id SERIAL PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE,
+    description TEXT,
+    is_default INTEGER NOT NULL DEFAULT 0, -- Används av OAI-PMH och när ingen crosswalk anges
+    created_at TEXT NOT NULL,
+    updated_at TEXT NOT NULL
+);
+
+-- Create table for the mappings of a crosswalk, in the order the elements are written
+CREATE TABLE IF NOT EXISTS crosswalk_mappings (
+    id SERIAL PRIMARY KEY,
+    crosswalk_id INTEGER NOT NULL,
+    position INTEGER NOT NULL,
+    source TEXT NOT NULL, -- T.ex. name, metadata:<nyckel>, node:<nyckel>, field:<fält> eller const:<text>
+    target TEXT NOT NULL, -- T.ex. dc:title eller dcterms:spatial
+    FOREIGN KEY (crosswalk_id) REFERENCES crosswalks (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_crosswalk_mappings_crosswalk_id ON crosswalk_mappings(crosswalk_id);
+
+-- Create table holding the head of the audit chain, so that removing the newest entries can be detected
+CREATE TABLE IF NOT EXISTS audit_log_head (
+    id INTEGER PRIMARY KEY CHECK (id = 1),
+    last_id INTEGER NOT NULL,
+    last_hash TEXT NOT NULL
+);
+
+-- Create index for faster user settings queries
+CREATE INDEX IF NOT EXISTS idx_user_settings_user_id ON user_settings(user_id);
+-- Create unique index on user_id and key to ensure no duplicate settings
+CREATE UNIQUE INDEX IF NOT EXISTS idx_user_settings_unique ON user_settings(user_id, key);
+
+-- Create Administrators group if not exists
+INSERT INTO groups (id, name, created_at)
+VALUES (1, 'Administrators', to_char(timezone('UTC', now()), 'YYYY-MM-DD HH24:MI:SS'))
+ON CONFLICT DO NOTHING;
+
+-- Insert default admin user with password "admin" (using bcrypt hash)
+INSERT INTO users (id, username, name, password_hash, created_at)
+VALUES (1, 'admin', 'admin', '$2a$10$G/Yn1SqchSCfNYdN6.LYBemwy8pwMAFwFb30il2wzmkb57wgS2f6q', to_char(timezone('UTC', now()), 'YYYY-MM-DD HH24:MI:SS'))
+ON CONFLICT DO NOTHING;
+
+-- Add admin user to Administrators group
+INSERT INTO group_members (user_id, group_id, created_at)
+VALUES (1, 1, to_char(timezone('UTC', now()), 'YYYY-MM-DD HH24:MI:SS'))
+ON CONFLICT DO NOTHING;
+
+-- Insert Root node as a starting point (admin group owns root node with full permissions)
+INSERT INTO nodes (id, name, parent_id, owner_user_id, owner_group_id, permissions, created_at, updated_at)
+VALUES (1, 'Root', NULL, 1, 1, 63, to_char(timezone('UTC', now()), 'YYYY-MM-DD HH24:MI:SS'), to_char(timezone('UTC', now()), 'YYYY-MM-DD HH24:MI:SS'))
+ON CONFLICT DO NOTHING;
+
+-- Grunddata skrivs med fasta ID:n, så löpnumren flyttas fram förbi dem
+SELECT setval(pg_get_serial_sequence('groups', 'id'), (SELECT MAX(id) FROM groups));
+SELECT setval(pg_get_serial_sequence('users', 'id'), (SELECT MAX(id) FROM users));
+SELECT setval(pg_get_serial_sequence('nodes', 'id'), (SELECT MAX(id) FROM nodes));
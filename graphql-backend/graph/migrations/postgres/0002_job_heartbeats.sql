@@ -0,0 +1,5 @@
+-- Jobb som körs markeras med arbetaren som tog dem och en puls som uppdateras medan de körs.
+-- Flera servrar kan dela databasen, och bara jobb vars puls har upphört återupptas av en annan
+-- server. heartbeat_at är i UTC.
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS worker_id TEXT;
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS heartbeat_at TEXT;
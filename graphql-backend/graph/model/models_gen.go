@@ -2,11 +2,239 @@
 
 package model
 
+import (
+	"fmt"
+	"io"
+	"strconv"
+)
+
+type ArchiveEntity interface {
+	IsArchiveEntity()
+	GetSystemID() string
+	GetTittel() string
+	GetFields() []*TypedField
+}
+
+type AccessLoggingPolicy struct {
+	Enabled bool    `json:"enabled"`
+	NodeID  *string `json:"nodeId,omitempty"`
+}
+
+type Arkiv struct {
+	SystemID    string        `json:"systemId"`
+	Tittel      string        `json:"tittel"`
+	Fields      []*TypedField `json:"fields"`
+	Arkivstatus string        `json:"arkivstatus"`
+	Arkivskaper *string       `json:"arkivskaper,omitempty"`
+	Beskrivelse *string       `json:"beskrivelse,omitempty"`
+}
+
+func (Arkiv) IsArchiveEntity()         {}
+func (this Arkiv) GetSystemID() string { return this.SystemID }
+func (this Arkiv) GetTittel() string   { return this.Tittel }
+func (this Arkiv) GetFields() []*TypedField {
+	if this.Fields == nil {
+		return nil
+	}
+	interfaceSlice := make([]*TypedField, 0, len(this.Fields))
+	for _, concrete := range this.Fields {
+		interfaceSlice = append(interfaceSlice, concrete)
+	}
+	return interfaceSlice
+}
+
+type Arkivdel struct {
+	SystemID              string        `json:"systemId"`
+	Tittel                string        `json:"tittel"`
+	Fields                []*TypedField `json:"fields"`
+	Arkivdelstatus        string        `json:"arkivdelstatus"`
+	ArkivperiodeStartDato *string       `json:"arkivperiodeStartDato,omitempty"`
+	ArkivperiodeSluttDato *string       `json:"arkivperiodeSluttDato,omitempty"`
+}
+
+func (Arkivdel) IsArchiveEntity()         {}
+func (this Arkivdel) GetSystemID() string { return this.SystemID }
+func (this Arkivdel) GetTittel() string   { return this.Tittel }
+func (this Arkivdel) GetFields() []*TypedField {
+	if this.Fields == nil {
+		return nil
+	}
+	interfaceSlice := make([]*TypedField, 0, len(this.Fields))
+	for _, concrete := range this.Fields {
+		interfaceSlice = append(interfaceSlice, concrete)
+	}
+	return interfaceSlice
+}
+
+type AuditEvent struct {
+	ID         string  `json:"id"`
+	OccurredAt string  `json:"occurredAt"`
+	ActorID    *string `json:"actorId,omitempty"`
+	Actor      *string `json:"actor,omitempty"`
+	Action     string  `json:"action"`
+	TargetType *string `json:"targetType,omitempty"`
+	TargetID   *string `json:"targetId,omitempty"`
+	Arguments  *string `json:"arguments,omitempty"`
+	Before     *string `json:"before,omitempty"`
+	After      *string `json:"after,omitempty"`
+	ClientIP   *string `json:"clientIp,omitempty"`
+	Outcome    string  `json:"outcome"`
+	Error      *string `json:"error,omitempty"`
+	PrevHash   string  `json:"prevHash"`
+	Hash       string  `json:"hash"`
+}
+
+type AuditEventFilter struct {
+	ActorID    *string `json:"actorId,omitempty"`
+	Actor      *string `json:"actor,omitempty"`
+	Action     *string `json:"action,omitempty"`
+	TargetType *string `json:"targetType,omitempty"`
+	TargetID   *string `json:"targetId,omitempty"`
+	Outcome    *string `json:"outcome,omitempty"`
+	From       *string `json:"from,omitempty"`
+	To         *string `json:"to,omitempty"`
+}
+
+type AuditVerification struct {
+	Valid          bool    `json:"valid"`
+	Checked        int     `json:"checked"`
+	FirstInvalidID *string `json:"firstInvalidId,omitempty"`
+	LastHash       *string `json:"lastHash,omitempty"`
+	Message        string  `json:"message"`
+}
+
 type AuthPayload struct {
 	Token string `json:"token"`
 	User  *User  `json:"user"`
 }
 
+type Backup struct {
+	// Katalognamnet i backupkatalogen, används för att peka ut kopian vid återställning
+	Name      string `json:"name"`
+	CreatedAt string `json:"createdAt"`
+	// Sant för kopior från schemat, som gallras efter backup.keep
+	Scheduled bool `json:"scheduled"`
+	// Senaste migreringen i den kopierade databasen
+	SchemaVersion int `json:"schemaVersion"`
+	DatabaseSize  int `json:"databaseSize"`
+	// SHA-256 av den kopierade databasfilen
+	DatabaseChecksum string `json:"databaseChecksum"`
+	// Antal filer från kataloger utanför databasen
+	BlobFiles int `json:"blobFiles"`
+	// Kopians totala storlek i byte
+	Size int `json:"size"`
+}
+
+type BulkImportInput struct {
+	TargetNodeID string `json:"targetNodeId"`
+	// Katalog eller ZIP-arkiv under imports/ på servern. Kräver administratör.
+	Path *string `json:"path,omitempty"`
+	// Uppladdat ZIP-arkiv i base64
+	ZipData *string `json:"zipData,omitempty"`
+	// Sidovagnsfil med metadata (CSV eller JSON) relativt källans rot. Utan den används metadata.csv eller metadata.json om någon av dem finns.
+	Sidecar *string `json:"sidecar,omitempty"`
+	// Hoppa över filer med samma namn som en fil som redan finns i noden
+	SkipExisting *bool `json:"skipExisting,omitempty"`
+}
+
+type BulkImportItem struct {
+	ID string `json:"id"`
+	// Sökväg i katalogen eller ZIP-arkivet
+	Path    string               `json:"path"`
+	Kind    BulkImportItemKind   `json:"kind"`
+	Status  BulkImportItemStatus `json:"status"`
+	NodeID  *string              `json:"nodeId,omitempty"`
+	FileID  *string              `json:"fileId,omitempty"`
+	Message *string              `json:"message,omitempty"`
+}
+
+type Crosswalk struct {
+	ID          string  `json:"id"`
+	Name        string  `json:"name"`
+	Description *string `json:"description,omitempty"`
+	// Standardcrosswalken används av OAI-PMH och när ingen crosswalk anges
+	IsDefault bool                `json:"isDefault"`
+	Mappings  []*CrosswalkMapping `json:"mappings"`
+	CreatedAt string              `json:"createdAt"`
+	UpdatedAt string              `json:"updatedAt"`
+}
+
+type CrosswalkInput struct {
+	Name        string                   `json:"name"`
+	Description *string                  `json:"description,omitempty"`
+	IsDefault   *bool                    `json:"isDefault,omitempty"`
+	Mappings    []*CrosswalkMappingInput `json:"mappings"`
+}
+
+type CrosswalkMapping struct {
+	// Källa: id, name, contentType, size, createdAt, updatedAt, checksum, puid, formatName, fileType, nodeName, metadata:<nyckel>, node:<nyckel>, field:<fält> eller const:<text>
+	Source string `json:"source"`
+	// Mål: ett Dublin Core-element (dc:title) eller en DCMI-term (dcterms:spatial)
+	Target string `json:"target"`
+}
+
+type CrosswalkMappingInput struct {
+	Source string `json:"source"`
+	Target string `json:"target"`
+}
+
+type DisposalCertificate struct {
+	ID             string  `json:"id"`
+	RequestID      string  `json:"requestId"`
+	FileID         string  `json:"fileId"`
+	FileName       string  `json:"fileName"`
+	NodeID         *string `json:"nodeId,omitempty"`
+	FileType       *string `json:"fileType,omitempty"`
+	Size           int     `json:"size"`
+	Checksum       *string `json:"checksum,omitempty"`
+	Puid           *string `json:"puid,omitempty"`
+	RuleName       string  `json:"ruleName"`
+	RetentionYears int     `json:"retentionYears"`
+	TriggerField   string  `json:"triggerField"`
+	TriggerDate    string  `json:"triggerDate"`
+	DisposalDate   string  `json:"disposalDate"`
+	DisposedAt     string  `json:"disposedAt"`
+	RequestedBy    string  `json:"requestedBy"`
+	ApprovedBy     string  `json:"approvedBy"`
+}
+
+type DisposalRequest struct {
+	ID            string                 `json:"id"`
+	Status        DisposalRequestStatus  `json:"status"`
+	Reason        *string                `json:"reason,omitempty"`
+	RequestedBy   *User                  `json:"requestedBy,omitempty"`
+	RequestedAt   string                 `json:"requestedAt"`
+	DecidedBy     *User                  `json:"decidedBy,omitempty"`
+	DecidedAt     *string                `json:"decidedAt,omitempty"`
+	DecisionNote  *string                `json:"decisionNote,omitempty"`
+	Files         []*File                `json:"files"`
+	Certificates  []*DisposalCertificate `json:"certificates"`
+	DecidedByID   *string                `json:"-"`
+	RequestedByID string                 `json:"-"`
+}
+
+type Dokument struct {
+	SystemID       string        `json:"systemId"`
+	Tittel         string        `json:"tittel"`
+	Fields         []*TypedField `json:"fields"`
+	Dokumenttype   *string       `json:"dokumenttype,omitempty"`
+	Dokumentstatus *string       `json:"dokumentstatus,omitempty"`
+}
+
+func (Dokument) IsArchiveEntity()         {}
+func (this Dokument) GetSystemID() string { return this.SystemID }
+func (this Dokument) GetTittel() string   { return this.Tittel }
+func (this Dokument) GetFields() []*TypedField {
+	if this.Fields == nil {
+		return nil
+	}
+	interfaceSlice := make([]*TypedField, 0, len(this.Fields))
+	for _, concrete := range this.Fields {
+		interfaceSlice = append(interfaceSlice, concrete)
+	}
+	return interfaceSlice
+}
+
 type File struct {
 	ID          string      `json:"id"`
 	Name        string      `json:"name"`
@@ -17,15 +245,68 @@ type File struct {
 	Metadata    []*Metadata `json:"metadata,omitempty"`
 	NodeID      *string     `json:"nodeId,omitempty"`
 	Node        *Node       `json:"node,omitempty"`
+	// Filens metadata i Dublin Core enligt en crosswalk, standardcrosswalken om ingen anges
+	DublinCore    string                `json:"dublinCore"`
+	Format        *FormatIdentification `json:"format"`
+	LegalHold     *LegalHold            `json:"legalHold,omitempty"`
+	FileType      FileType              `json:"fileType"`
+	ArchiveEntity ArchiveEntity         `json:"archiveEntity,omitempty"`
+	Retention     *FileRetention        `json:"retention,omitempty"`
+}
+
+type FileAccess struct {
+	ID         string         `json:"id"`
+	FileID     string         `json:"fileId"`
+	File       *File          `json:"file,omitempty"`
+	FileName   string         `json:"fileName"`
+	NodeID     *string        `json:"nodeId,omitempty"`
+	UserID     *string        `json:"userId,omitempty"`
+	User       *User          `json:"user,omitempty"`
+	Username   *string        `json:"username,omitempty"`
+	AccessType FileAccessType `json:"accessType"`
+	AccessedAt string         `json:"accessedAt"`
+	ClientIP   *string        `json:"clientIp,omitempty"`
+	UserAgent  *string        `json:"userAgent,omitempty"`
+}
+
+type FileFormat struct {
+	Puid       string   `json:"puid"`
+	Name       string   `json:"name"`
+	Version    *string  `json:"version,omitempty"`
+	MimeType   string   `json:"mimeType"`
+	Extensions []string `json:"extensions"`
 }
 
 type FileInput struct {
-	Name        string           `json:"name"`
-	Size        int              `json:"size"`
-	ContentType string           `json:"contentType"`
-	FileData    string           `json:"fileData"`
-	Metadata    []*MetadataInput `json:"metadata,omitempty"`
-	NodeID      *string          `json:"nodeId,omitempty"`
+	Name        string             `json:"name"`
+	Size        int                `json:"size"`
+	ContentType string             `json:"contentType"`
+	FileData    string             `json:"fileData"`
+	Metadata    []*MetadataInput   `json:"metadata,omitempty"`
+	NodeID      *string            `json:"nodeId,omitempty"`
+	FileType    *FileType          `json:"fileType,omitempty"`
+	Fields      []*TypedFieldInput `json:"fields,omitempty"`
+}
+
+type FileRetention struct {
+	Rule         *RetentionRule `json:"rule"`
+	RuleNodeID   string         `json:"ruleNodeId"`
+	TriggerDate  *string        `json:"triggerDate,omitempty"`
+	DisposalDate *string        `json:"disposalDate,omitempty"`
+	Due          bool           `json:"due"`
+}
+
+type FormatIdentification struct {
+	Puid     *string `json:"puid,omitempty"`
+	Name     *string `json:"name,omitempty"`
+	Version  *string `json:"version,omitempty"`
+	MimeType *string `json:"mimeType,omitempty"`
+	Mismatch bool    `json:"mismatch"`
+}
+
+type FormatPolicy struct {
+	NodeID string   `json:"nodeId"`
+	Puids  []string `json:"puids"`
 }
 
 type Group struct {
@@ -34,41 +315,227 @@ type Group struct {
 	Members []*User `json:"members,omitempty"`
 }
 
+type Job struct {
+	ID       string    `json:"id"`
+	Type     string    `json:"type"`
+	Status   JobStatus `json:"status"`
+	Progress int       `json:"progress"`
+	Message  *string   `json:"message,omitempty"`
+	Result   *string   `json:"result,omitempty"`
+	// Felet från det senaste misslyckade försöket
+	Error       *string `json:"error,omitempty"`
+	Attempts    int     `json:"attempts"`
+	MaxAttempts int     `json:"maxAttempts"`
+	// Tidigast när ett köat jobb körs igen efter ett misslyckat försök (UTC)
+	RunAfter *string `json:"runAfter,omitempty"`
+	// Sant när ett körande jobb har ombetts att avbryta
+	CancelRequested bool    `json:"cancelRequested"`
+	CreatedBy       *string `json:"createdBy,omitempty"`
+	CreatedAt       string  `json:"createdAt"`
+	UpdatedAt       string  `json:"updatedAt"`
+	StartedAt       *string `json:"startedAt,omitempty"`
+	FinishedAt      *string `json:"finishedAt,omitempty"`
+	// Utfallet per mapp och fil för massimporter
+	ImportItems []*BulkImportItem `json:"importItems"`
+}
+
+type Journalpost struct {
+	SystemID             string        `json:"systemId"`
+	Tittel               string        `json:"tittel"`
+	Fields               []*TypedField `json:"fields"`
+	Journalaar           int           `json:"journalaar"`
+	Journalsekvensnummer int           `json:"journalsekvensnummer"`
+	Journalpostnummer    int           `json:"journalpostnummer"`
+	Journalposttype      string        `json:"journalposttype"`
+	Journalstatus        string        `json:"journalstatus"`
+	Journaldato          string        `json:"journaldato"`
+	DokumentetsDato      *string       `json:"dokumentetsDato,omitempty"`
+	OffentligTittel      *string       `json:"offentligTittel,omitempty"`
+	Tilgangsrestriksjon  *string       `json:"tilgangsrestriksjon,omitempty"`
+	Skjermingshjemmel    *string       `json:"skjermingshjemmel,omitempty"`
+}
+
+func (Journalpost) IsArchiveEntity()         {}
+func (this Journalpost) GetSystemID() string { return this.SystemID }
+func (this Journalpost) GetTittel() string   { return this.Tittel }
+func (this Journalpost) GetFields() []*TypedField {
+	if this.Fields == nil {
+		return nil
+	}
+	interfaceSlice := make([]*TypedField, 0, len(this.Fields))
+	for _, concrete := range this.Fields {
+		interfaceSlice = append(interfaceSlice, concrete)
+	}
+	return interfaceSlice
+}
+
+type Klasse struct {
+	SystemID string        `json:"systemId"`
+	Tittel   string        `json:"tittel"`
+	Fields   []*TypedField `json:"fields"`
+	KlasseID string        `json:"klasseID"`
+}
+
+func (Klasse) IsArchiveEntity()         {}
+func (this Klasse) GetSystemID() string { return this.SystemID }
+func (this Klasse) GetTittel() string   { return this.Tittel }
+func (this Klasse) GetFields() []*TypedField {
+	if this.Fields == nil {
+		return nil
+	}
+	interfaceSlice := make([]*TypedField, 0, len(this.Fields))
+	for _, concrete := range this.Fields {
+		interfaceSlice = append(interfaceSlice, concrete)
+	}
+	return interfaceSlice
+}
+
+type LegalHold struct {
+	ID           string  `json:"id"`
+	NodeID       string  `json:"nodeId"`
+	Node         *Node   `json:"node,omitempty"`
+	Reason       string  `json:"reason"`
+	PlacedBy     *User   `json:"placedBy,omitempty"`
+	PlacedAt     string  `json:"placedAt"`
+	ReleasedBy   *User   `json:"releasedBy,omitempty"`
+	ReleasedAt   *string `json:"releasedAt,omitempty"`
+	ReleaseNote  *string `json:"releaseNote,omitempty"`
+	Active       bool    `json:"active"`
+	PlacedByID   string  `json:"-"`
+	ReleasedByID *string `json:"-"`
+}
+
 type Metadata struct {
-	Key   string `json:"key"`
-	Value string `json:"value"`
+	Key            string            `json:"key"`
+	Value          string            `json:"value"`
+	Type           MetadataValueType `json:"type"`
+	IntegerValue   *int              `json:"integerValue,omitempty"`
+	DecimalValue   *float64          `json:"decimalValue,omitempty"`
+	BooleanValue   *bool             `json:"booleanValue,omitempty"`
+	ReferencedUser *User             `json:"referencedUser,omitempty"`
+	ReferencedNode *Node             `json:"referencedNode,omitempty"`
+	// Om filer som laddas upp under noden får värdet som standard. Alltid false för metadata på filer.
+	Inheritable bool `json:"inheritable"`
+}
+
+type MetadataFilter struct {
+	Key    string             `json:"key"`
+	Type   *MetadataValueType `json:"type,omitempty"`
+	Equals *string            `json:"equals,omitempty"`
+	Min    *string            `json:"min,omitempty"`
+	Max    *string            `json:"max,omitempty"`
 }
 
 type MetadataInput struct {
-	Key   string `json:"key"`
-	Value string `json:"value"`
+	Key   string             `json:"key"`
+	Value string             `json:"value"`
+	Type  *MetadataValueType `json:"type,omitempty"`
+	// Gäller bara metadata på noder, se Metadata.inheritable
+	Inheritable *bool `json:"inheritable,omitempty"`
+}
+
+type MetadataSchema struct {
+	ID                  string                 `json:"id"`
+	Name                string                 `json:"name"`
+	Description         *string                `json:"description,omitempty"`
+	AllowAdditionalKeys bool                   `json:"allowAdditionalKeys"`
+	Fields              []*MetadataSchemaField `json:"fields"`
+	CreatedAt           string                 `json:"createdAt"`
+	UpdatedAt           string                 `json:"updatedAt"`
+}
+
+type MetadataSchemaField struct {
+	Name          string            `json:"name"`
+	Type          MetadataFieldType `json:"type"`
+	Required      bool              `json:"required"`
+	Repeatable    bool              `json:"repeatable"`
+	Pattern       *string           `json:"pattern,omitempty"`
+	AllowedValues []string          `json:"allowedValues"`
+	Description   *string           `json:"description,omitempty"`
+	VocabularyID  *string           `json:"vocabularyId,omitempty"`
+	Vocabulary    *Vocabulary       `json:"vocabulary,omitempty"`
+}
+
+type MetadataSchemaFieldInput struct {
+	Name          string            `json:"name"`
+	Type          MetadataFieldType `json:"type"`
+	Required      *bool             `json:"required,omitempty"`
+	Repeatable    *bool             `json:"repeatable,omitempty"`
+	Pattern       *string           `json:"pattern,omitempty"`
+	AllowedValues []string          `json:"allowedValues,omitempty"`
+	Description   *string           `json:"description,omitempty"`
+	VocabularyID  *string           `json:"vocabularyId,omitempty"`
+}
+
+type MetadataSchemaInput struct {
+	Name                string                      `json:"name"`
+	Description         *string                     `json:"description,omitempty"`
+	AllowAdditionalKeys *bool                       `json:"allowAdditionalKeys,omitempty"`
+	Fields              []*MetadataSchemaFieldInput `json:"fields"`
+}
+
+type MetadataSort struct {
+	Key        string `json:"key"`
+	Descending *bool  `json:"descending,omitempty"`
 }
 
 type Mutation struct {
 }
 
 type Node struct {
-	ID           string  `json:"id"`
-	Name         string  `json:"name"`
-	ParentID     *string `json:"parentId,omitempty"`
-	CreatedAt    string  `json:"createdAt"`
-	UpdatedAt    string  `json:"updatedAt"`
-	Children     []*Node `json:"children,omitempty"`
-	Parent       *Node   `json:"parent,omitempty"`
-	Files        []*File `json:"files,omitempty"`
-	OwnerUserID  *string `json:"ownerUserId,omitempty"`
-	OwnerGroupID *string `json:"ownerGroupId,omitempty"`
-	OwnerUser    *User   `json:"ownerUser,omitempty"`
-	OwnerGroup   *Group  `json:"ownerGroup,omitempty"`
-	Permissions  int     `json:"permissions"`
+	ID             string               `json:"id"`
+	Name           string               `json:"name"`
+	ParentID       *string              `json:"parentId,omitempty"`
+	CreatedAt      string               `json:"createdAt"`
+	UpdatedAt      string               `json:"updatedAt"`
+	Children       []*Node              `json:"children,omitempty"`
+	Parent         *Node                `json:"parent,omitempty"`
+	Files          []*File              `json:"files,omitempty"`
+	OwnerUserID    *string              `json:"ownerUserId,omitempty"`
+	OwnerGroupID   *string              `json:"ownerGroupId,omitempty"`
+	OwnerUser      *User                `json:"ownerUser,omitempty"`
+	OwnerGroup     *Group               `json:"ownerGroup,omitempty"`
+	Permissions    int                  `json:"permissions"`
+	AccessLogging  *AccessLoggingPolicy `json:"accessLogging"`
+	Publication    *PublicationPolicy   `json:"publication"`
+	FormatPolicy   *FormatPolicy        `json:"formatPolicy,omitempty"`
+	LegalHold      *LegalHold           `json:"legalHold,omitempty"`
+	MetadataSchema *MetadataSchema      `json:"metadataSchema,omitempty"`
+	NodeType       NodeType             `json:"nodeType"`
+	ArchiveEntity  ArchiveEntity        `json:"archiveEntity,omitempty"`
+	Metadata       []*Metadata          `json:"metadata"`
+	RetentionRule  *RetentionRule       `json:"retentionRule,omitempty"`
+}
+
+type NodeChange struct {
+	NodeID string         `json:"nodeId"`
+	Kind   NodeChangeKind `json:"kind"`
+	// Nodens aktuella tillstånd, null om den har tagits bort
+	Node *Node `json:"node,omitempty"`
+	// Undernoden som ändrats, för CHILD_*
+	ChildNodeID *string `json:"childNodeId,omitempty"`
+	// Filen som ändrats, för FILE_*
+	FileID *string `json:"fileId,omitempty"`
+	// Mutationen eller jobbtypen som orsakade ändringen
+	Action     string  `json:"action"`
+	ActorID    *string `json:"actorId,omitempty"`
+	OccurredAt string  `json:"occurredAt"`
 }
 
 type NodeInput struct {
-	Name         string  `json:"name"`
-	ParentID     *string `json:"parentId,omitempty"`
-	OwnerUserID  *string `json:"ownerUserId,omitempty"`
-	OwnerGroupID *string `json:"ownerGroupId,omitempty"`
-	Permissions  *int    `json:"permissions,omitempty"`
+	Name         string             `json:"name"`
+	ParentID     *string            `json:"parentId,omitempty"`
+	OwnerUserID  *string            `json:"ownerUserId,omitempty"`
+	OwnerGroupID *string            `json:"ownerGroupId,omitempty"`
+	Permissions  *int               `json:"permissions,omitempty"`
+	NodeType     *NodeType          `json:"nodeType,omitempty"`
+	Fields       []*TypedFieldInput `json:"fields,omitempty"`
+}
+
+type NodeTypeFieldVocabulary struct {
+	NodeType   NodeType    `json:"nodeType"`
+	Field      string      `json:"field"`
+	Vocabulary *Vocabulary `json:"vocabulary"`
 }
 
 type NodeUpdateInput struct {
@@ -79,9 +546,64 @@ type NodeUpdateInput struct {
 	Permissions  *int    `json:"permissions,omitempty"`
 }
 
+type PublicationPolicy struct {
+	Published bool    `json:"published"`
+	NodeID    *string `json:"nodeId,omitempty"`
+}
+
 type Query struct {
 }
 
+type RetentionRule struct {
+	ID             string         `json:"id"`
+	Name           string         `json:"name"`
+	RetentionYears int            `json:"retentionYears"`
+	TriggerField   string         `json:"triggerField"`
+	DisposalAction DisposalAction `json:"disposalAction"`
+	Description    *string        `json:"description,omitempty"`
+	CreatedAt      string         `json:"createdAt"`
+}
+
+type RetentionRuleInput struct {
+	Name           string         `json:"name"`
+	RetentionYears int            `json:"retentionYears"`
+	TriggerField   *string        `json:"triggerField,omitempty"`
+	DisposalAction DisposalAction `json:"disposalAction"`
+	Description    *string        `json:"description,omitempty"`
+}
+
+type Saksmappe struct {
+	SystemID            string        `json:"systemId"`
+	Tittel              string        `json:"tittel"`
+	Fields              []*TypedField `json:"fields"`
+	MappeID             string        `json:"mappeID"`
+	Saksaar             int           `json:"saksaar"`
+	Sakssekvensnummer   int           `json:"sakssekvensnummer"`
+	Saksdato            string        `json:"saksdato"`
+	AdministrativEnhet  string        `json:"administrativEnhet"`
+	Saksansvarlig       string        `json:"saksansvarlig"`
+	Saksstatus          string        `json:"saksstatus"`
+	Tilgangsrestriksjon *string       `json:"tilgangsrestriksjon,omitempty"`
+	Skjermingshjemmel   *string       `json:"skjermingshjemmel,omitempty"`
+}
+
+func (Saksmappe) IsArchiveEntity()         {}
+func (this Saksmappe) GetSystemID() string { return this.SystemID }
+func (this Saksmappe) GetTittel() string   { return this.Tittel }
+func (this Saksmappe) GetFields() []*TypedField {
+	if this.Fields == nil {
+		return nil
+	}
+	interfaceSlice := make([]*TypedField, 0, len(this.Fields))
+	for _, concrete := range this.Fields {
+		interfaceSlice = append(interfaceSlice, concrete)
+	}
+	return interfaceSlice
+}
+
+type Subscription struct {
+}
+
 type Todo struct {
 	ID     string `json:"id"`
 	Text   string `json:"text"`
@@ -90,6 +612,16 @@ type Todo struct {
 	userID string `json:"-"`
 }
 
+type TypedField struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type TypedFieldInput struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
 type User struct {
 	ID       string         `json:"id"`
 	Name     string         `json:"name"`
@@ -98,6 +630,13 @@ type User struct {
 	Groups   []*Group       `json:"groups,omitempty"`
 }
 
+type UserAccessReport struct {
+	User          *User         `json:"user"`
+	TotalAccesses int           `json:"totalAccesses"`
+	DistinctFiles int           `json:"distinctFiles"`
+	Accesses      []*FileAccess `json:"accesses"`
+}
+
 type UserSetting struct {
 	ID        string `json:"id"`
 	Key       string `json:"key"`
@@ -105,3 +644,823 @@ type UserSetting struct {
 	CreatedAt string `json:"createdAt"`
 	UpdatedAt string `json:"updatedAt"`
 }
+
+type Vocabulary struct {
+	ID          string            `json:"id"`
+	Name        string            `json:"name"`
+	Description *string           `json:"description,omitempty"`
+	Kind        VocabularyKind    `json:"kind"`
+	Terms       []*VocabularyTerm `json:"terms"`
+	TopTerms    []*VocabularyTerm `json:"topTerms"`
+	CreatedAt   string            `json:"createdAt"`
+	UpdatedAt   string            `json:"updatedAt"`
+}
+
+type VocabularyImportResult struct {
+	Vocabulary *Vocabulary `json:"vocabulary"`
+	Created    int         `json:"created"`
+	Updated    int         `json:"updated"`
+}
+
+type VocabularyInput struct {
+	Name        string          `json:"name"`
+	Description *string         `json:"description,omitempty"`
+	Kind        *VocabularyKind `json:"kind,omitempty"`
+}
+
+type VocabularyTerm struct {
+	ID           string `json:"id"`
+	VocabularyID string `json:"vocabularyId"`
+	// Värdet som sparas i metadata och fält
+	Code        string            `json:"code"`
+	Label       string            `json:"label"`
+	AltLabels   []string          `json:"altLabels"`
+	Description *string           `json:"description,omitempty"`
+	URI         *string           `json:"uri,omitempty"`
+	ParentID    *string           `json:"parentId,omitempty"`
+	Parent      *VocabularyTerm   `json:"parent,omitempty"`
+	Children    []*VocabularyTerm `json:"children"`
+}
+
+type VocabularyTermInput struct {
+	// Utelämnas koden används label som kod
+	Code        *string  `json:"code,omitempty"`
+	Label       string   `json:"label"`
+	AltLabels   []string `json:"altLabels,omitempty"`
+	Description *string  `json:"description,omitempty"`
+	URI         *string  `json:"uri,omitempty"`
+	ParentID    *string  `json:"parentId,omitempty"`
+}
+
+type Webhook struct {
+	ID         string             `json:"id"`
+	URL        string             `json:"url"`
+	EventTypes []WebhookEventType `json:"eventTypes"`
+	// Noden vars underträd händelserna begränsas till, null för hela arkivet
+	NodeID *string `json:"nodeId,omitempty"`
+	Active bool    `json:"active"`
+	// Hemligheten som signerar anropen. Visas bara när webhooken skapas eller hemligheten byts.
+	Secret    *string `json:"secret,omitempty"`
+	CreatedBy *string `json:"createdBy,omitempty"`
+	CreatedAt string  `json:"createdAt"`
+	UpdatedAt string  `json:"updatedAt"`
+}
+
+type WebhookDelivery struct {
+	ID        string           `json:"id"`
+	WebhookID string           `json:"webhookId"`
+	EventType WebhookEventType `json:"eventType"`
+	// JSON-kroppen som skickades
+	Payload     string                `json:"payload"`
+	Status      WebhookDeliveryStatus `json:"status"`
+	Attempts    int                   `json:"attempts"`
+	MaxAttempts int                   `json:"maxAttempts"`
+	// När nästa försök görs (UTC)
+	NextAttemptAt *string `json:"nextAttemptAt,omitempty"`
+	// HTTP-status från mottagaren vid senaste försöket
+	ResponseStatus *int `json:"responseStatus,omitempty"`
+	// Början av svaret från mottagaren vid senaste försöket
+	ResponseBody *string `json:"responseBody,omitempty"`
+	Error        *string `json:"error,omitempty"`
+	CreatedAt    string  `json:"createdAt"`
+	DeliveredAt  *string `json:"deliveredAt,omitempty"`
+}
+
+type WebhookInput struct {
+	URL        string             `json:"url"`
+	EventTypes []WebhookEventType `json:"eventTypes"`
+	NodeID     *string            `json:"nodeId,omitempty"`
+	// Utelämnas den skapas en slumpmässig hemlighet; vid uppdatering behålls den gamla
+	Secret *string `json:"secret,omitempty"`
+	Active *bool   `json:"active,omitempty"`
+}
+
+type BulkImportItemKind string
+
+const (
+	BulkImportItemKindNode BulkImportItemKind = "NODE"
+	BulkImportItemKindFile BulkImportItemKind = "FILE"
+)
+
+var AllBulkImportItemKind = []BulkImportItemKind{
+	BulkImportItemKindNode,
+	BulkImportItemKindFile,
+}
+
+func (e BulkImportItemKind) IsValid() bool {
+	switch e {
+	case BulkImportItemKindNode, BulkImportItemKindFile:
+		return true
+	}
+	return false
+}
+
+func (e BulkImportItemKind) String() string {
+	return string(e)
+}
+
+func (e *BulkImportItemKind) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = BulkImportItemKind(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid BulkImportItemKind", str)
+	}
+	return nil
+}
+
+func (e BulkImportItemKind) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type BulkImportItemStatus string
+
+const (
+	BulkImportItemStatusImported BulkImportItemStatus = "IMPORTED"
+	BulkImportItemStatusSkipped  BulkImportItemStatus = "SKIPPED"
+	BulkImportItemStatusFailed   BulkImportItemStatus = "FAILED"
+)
+
+var AllBulkImportItemStatus = []BulkImportItemStatus{
+	BulkImportItemStatusImported,
+	BulkImportItemStatusSkipped,
+	BulkImportItemStatusFailed,
+}
+
+func (e BulkImportItemStatus) IsValid() bool {
+	switch e {
+	case BulkImportItemStatusImported, BulkImportItemStatusSkipped, BulkImportItemStatusFailed:
+		return true
+	}
+	return false
+}
+
+func (e BulkImportItemStatus) String() string {
+	return string(e)
+}
+
+func (e *BulkImportItemStatus) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = BulkImportItemStatus(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid BulkImportItemStatus", str)
+	}
+	return nil
+}
+
+func (e BulkImportItemStatus) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type DisposalAction string
+
+const (
+	DisposalActionDestroy DisposalAction = "DESTROY"
+	DisposalActionReview  DisposalAction = "REVIEW"
+	DisposalActionRetain  DisposalAction = "RETAIN"
+)
+
+var AllDisposalAction = []DisposalAction{
+	DisposalActionDestroy,
+	DisposalActionReview,
+	DisposalActionRetain,
+}
+
+func (e DisposalAction) IsValid() bool {
+	switch e {
+	case DisposalActionDestroy, DisposalActionReview, DisposalActionRetain:
+		return true
+	}
+	return false
+}
+
+func (e DisposalAction) String() string {
+	return string(e)
+}
+
+func (e *DisposalAction) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = DisposalAction(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid DisposalAction", str)
+	}
+	return nil
+}
+
+func (e DisposalAction) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type DisposalRequestStatus string
+
+const (
+	DisposalRequestStatusPending  DisposalRequestStatus = "PENDING"
+	DisposalRequestStatusApproved DisposalRequestStatus = "APPROVED"
+	DisposalRequestStatusRejected DisposalRequestStatus = "REJECTED"
+)
+
+var AllDisposalRequestStatus = []DisposalRequestStatus{
+	DisposalRequestStatusPending,
+	DisposalRequestStatusApproved,
+	DisposalRequestStatusRejected,
+}
+
+func (e DisposalRequestStatus) IsValid() bool {
+	switch e {
+	case DisposalRequestStatusPending, DisposalRequestStatusApproved, DisposalRequestStatusRejected:
+		return true
+	}
+	return false
+}
+
+func (e DisposalRequestStatus) String() string {
+	return string(e)
+}
+
+func (e *DisposalRequestStatus) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = DisposalRequestStatus(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid DisposalRequestStatus", str)
+	}
+	return nil
+}
+
+func (e DisposalRequestStatus) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type DublinCoreFormat string
+
+const (
+	DublinCoreFormatXML    DublinCoreFormat = "XML"
+	DublinCoreFormatJSONLd DublinCoreFormat = "JSON_LD"
+)
+
+var AllDublinCoreFormat = []DublinCoreFormat{
+	DublinCoreFormatXML,
+	DublinCoreFormatJSONLd,
+}
+
+func (e DublinCoreFormat) IsValid() bool {
+	switch e {
+	case DublinCoreFormatXML, DublinCoreFormatJSONLd:
+		return true
+	}
+	return false
+}
+
+func (e DublinCoreFormat) String() string {
+	return string(e)
+}
+
+func (e *DublinCoreFormat) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = DublinCoreFormat(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid DublinCoreFormat", str)
+	}
+	return nil
+}
+
+func (e DublinCoreFormat) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type FileAccessType string
+
+const (
+	FileAccessTypeView     FileAccessType = "VIEW"
+	FileAccessTypeDownload FileAccessType = "DOWNLOAD"
+	FileAccessTypeStream   FileAccessType = "STREAM"
+	FileAccessTypeExport   FileAccessType = "EXPORT"
+)
+
+var AllFileAccessType = []FileAccessType{
+	FileAccessTypeView,
+	FileAccessTypeDownload,
+	FileAccessTypeStream,
+	FileAccessTypeExport,
+}
+
+func (e FileAccessType) IsValid() bool {
+	switch e {
+	case FileAccessTypeView, FileAccessTypeDownload, FileAccessTypeStream, FileAccessTypeExport:
+		return true
+	}
+	return false
+}
+
+func (e FileAccessType) String() string {
+	return string(e)
+}
+
+func (e *FileAccessType) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = FileAccessType(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid FileAccessType", str)
+	}
+	return nil
+}
+
+func (e FileAccessType) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type FileType string
+
+const (
+	FileTypeDokument    FileType = "DOKUMENT"
+	FileTypeJournalpost FileType = "JOURNALPOST"
+)
+
+var AllFileType = []FileType{
+	FileTypeDokument,
+	FileTypeJournalpost,
+}
+
+func (e FileType) IsValid() bool {
+	switch e {
+	case FileTypeDokument, FileTypeJournalpost:
+		return true
+	}
+	return false
+}
+
+func (e FileType) String() string {
+	return string(e)
+}
+
+func (e *FileType) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = FileType(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid FileType", str)
+	}
+	return nil
+}
+
+func (e FileType) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type JobStatus string
+
+const (
+	JobStatusQueued    JobStatus = "QUEUED"
+	JobStatusRunning   JobStatus = "RUNNING"
+	JobStatusSucceeded JobStatus = "SUCCEEDED"
+	JobStatusFailed    JobStatus = "FAILED"
+	JobStatusCancelled JobStatus = "CANCELLED"
+)
+
+var AllJobStatus = []JobStatus{
+	JobStatusQueued,
+	JobStatusRunning,
+	JobStatusSucceeded,
+	JobStatusFailed,
+	JobStatusCancelled,
+}
+
+func (e JobStatus) IsValid() bool {
+	switch e {
+	case JobStatusQueued, JobStatusRunning, JobStatusSucceeded, JobStatusFailed, JobStatusCancelled:
+		return true
+	}
+	return false
+}
+
+func (e JobStatus) String() string {
+	return string(e)
+}
+
+func (e *JobStatus) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = JobStatus(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid JobStatus", str)
+	}
+	return nil
+}
+
+func (e JobStatus) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type MetadataFieldType string
+
+const (
+	MetadataFieldTypeString MetadataFieldType = "STRING"
+	MetadataFieldTypeNumber MetadataFieldType = "NUMBER"
+	MetadataFieldTypeDate   MetadataFieldType = "DATE"
+	MetadataFieldTypeEnum   MetadataFieldType = "ENUM"
+)
+
+var AllMetadataFieldType = []MetadataFieldType{
+	MetadataFieldTypeString,
+	MetadataFieldTypeNumber,
+	MetadataFieldTypeDate,
+	MetadataFieldTypeEnum,
+}
+
+func (e MetadataFieldType) IsValid() bool {
+	switch e {
+	case MetadataFieldTypeString, MetadataFieldTypeNumber, MetadataFieldTypeDate, MetadataFieldTypeEnum:
+		return true
+	}
+	return false
+}
+
+func (e MetadataFieldType) String() string {
+	return string(e)
+}
+
+func (e *MetadataFieldType) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = MetadataFieldType(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid MetadataFieldType", str)
+	}
+	return nil
+}
+
+func (e MetadataFieldType) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type MetadataValueType string
+
+const (
+	MetadataValueTypeString   MetadataValueType = "STRING"
+	MetadataValueTypeInteger  MetadataValueType = "INTEGER"
+	MetadataValueTypeDecimal  MetadataValueType = "DECIMAL"
+	MetadataValueTypeDate     MetadataValueType = "DATE"
+	MetadataValueTypeDatetime MetadataValueType = "DATETIME"
+	MetadataValueTypeBoolean  MetadataValueType = "BOOLEAN"
+	MetadataValueTypeUser     MetadataValueType = "USER"
+	MetadataValueTypeNode     MetadataValueType = "NODE"
+)
+
+var AllMetadataValueType = []MetadataValueType{
+	MetadataValueTypeString,
+	MetadataValueTypeInteger,
+	MetadataValueTypeDecimal,
+	MetadataValueTypeDate,
+	MetadataValueTypeDatetime,
+	MetadataValueTypeBoolean,
+	MetadataValueTypeUser,
+	MetadataValueTypeNode,
+}
+
+func (e MetadataValueType) IsValid() bool {
+	switch e {
+	case MetadataValueTypeString, MetadataValueTypeInteger, MetadataValueTypeDecimal, MetadataValueTypeDate, MetadataValueTypeDatetime, MetadataValueTypeBoolean, MetadataValueTypeUser, MetadataValueTypeNode:
+		return true
+	}
+	return false
+}
+
+func (e MetadataValueType) String() string {
+	return string(e)
+}
+
+func (e *MetadataValueType) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = MetadataValueType(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid MetadataValueType", str)
+	}
+	return nil
+}
+
+func (e MetadataValueType) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type NodeChangeKind string
+
+const (
+	// Noden själv har ändrats, t.ex. bytt namn, flyttats eller fått ny metadata
+	NodeChangeKindUpdated NodeChangeKind = "UPDATED"
+	// Noden har tagits bort. Prenumerationen avslutas efter händelsen.
+	NodeChangeKindDeleted      NodeChangeKind = "DELETED"
+	NodeChangeKindChildAdded   NodeChangeKind = "CHILD_ADDED"
+	NodeChangeKindChildUpdated NodeChangeKind = "CHILD_UPDATED"
+	NodeChangeKindChildRemoved NodeChangeKind = "CHILD_REMOVED"
+	NodeChangeKindFileAdded    NodeChangeKind = "FILE_ADDED"
+	NodeChangeKindFileUpdated  NodeChangeKind = "FILE_UPDATED"
+	NodeChangeKindFileRemoved  NodeChangeKind = "FILE_REMOVED"
+)
+
+var AllNodeChangeKind = []NodeChangeKind{
+	NodeChangeKindUpdated,
+	NodeChangeKindDeleted,
+	NodeChangeKindChildAdded,
+	NodeChangeKindChildUpdated,
+	NodeChangeKindChildRemoved,
+	NodeChangeKindFileAdded,
+	NodeChangeKindFileUpdated,
+	NodeChangeKindFileRemoved,
+}
+
+func (e NodeChangeKind) IsValid() bool {
+	switch e {
+	case NodeChangeKindUpdated, NodeChangeKindDeleted, NodeChangeKindChildAdded, NodeChangeKindChildUpdated, NodeChangeKindChildRemoved, NodeChangeKindFileAdded, NodeChangeKindFileUpdated, NodeChangeKindFileRemoved:
+		return true
+	}
+	return false
+}
+
+func (e NodeChangeKind) String() string {
+	return string(e)
+}
+
+func (e *NodeChangeKind) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = NodeChangeKind(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid NodeChangeKind", str)
+	}
+	return nil
+}
+
+func (e NodeChangeKind) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type NodeType string
+
+const (
+	NodeTypeFolder    NodeType = "FOLDER"
+	NodeTypeArkiv     NodeType = "ARKIV"
+	NodeTypeArkivdel  NodeType = "ARKIVDEL"
+	NodeTypeKlasse    NodeType = "KLASSE"
+	NodeTypeSaksmappe NodeType = "SAKSMAPPE"
+)
+
+var AllNodeType = []NodeType{
+	NodeTypeFolder,
+	NodeTypeArkiv,
+	NodeTypeArkivdel,
+	NodeTypeKlasse,
+	NodeTypeSaksmappe,
+}
+
+func (e NodeType) IsValid() bool {
+	switch e {
+	case NodeTypeFolder, NodeTypeArkiv, NodeTypeArkivdel, NodeTypeKlasse, NodeTypeSaksmappe:
+		return true
+	}
+	return false
+}
+
+func (e NodeType) String() string {
+	return string(e)
+}
+
+func (e *NodeType) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = NodeType(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid NodeType", str)
+	}
+	return nil
+}
+
+func (e NodeType) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type VocabularyImportFormat string
+
+const (
+	VocabularyImportFormatSkos VocabularyImportFormat = "SKOS"
+	VocabularyImportFormatCSV  VocabularyImportFormat = "CSV"
+)
+
+var AllVocabularyImportFormat = []VocabularyImportFormat{
+	VocabularyImportFormatSkos,
+	VocabularyImportFormatCSV,
+}
+
+func (e VocabularyImportFormat) IsValid() bool {
+	switch e {
+	case VocabularyImportFormatSkos, VocabularyImportFormatCSV:
+		return true
+	}
+	return false
+}
+
+func (e VocabularyImportFormat) String() string {
+	return string(e)
+}
+
+func (e *VocabularyImportFormat) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = VocabularyImportFormat(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid VocabularyImportFormat", str)
+	}
+	return nil
+}
+
+func (e VocabularyImportFormat) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type VocabularyKind string
+
+const (
+	VocabularyKindVocabulary     VocabularyKind = "VOCABULARY"
+	VocabularyKindClassification VocabularyKind = "CLASSIFICATION"
+)
+
+var AllVocabularyKind = []VocabularyKind{
+	VocabularyKindVocabulary,
+	VocabularyKindClassification,
+}
+
+func (e VocabularyKind) IsValid() bool {
+	switch e {
+	case VocabularyKindVocabulary, VocabularyKindClassification:
+		return true
+	}
+	return false
+}
+
+func (e VocabularyKind) String() string {
+	return string(e)
+}
+
+func (e *VocabularyKind) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = VocabularyKind(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid VocabularyKind", str)
+	}
+	return nil
+}
+
+func (e VocabularyKind) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type WebhookDeliveryStatus string
+
+const (
+	WebhookDeliveryStatusPending   WebhookDeliveryStatus = "PENDING"
+	WebhookDeliveryStatusSending   WebhookDeliveryStatus = "SENDING"
+	WebhookDeliveryStatusDelivered WebhookDeliveryStatus = "DELIVERED"
+	WebhookDeliveryStatusFailed    WebhookDeliveryStatus = "FAILED"
+)
+
+var AllWebhookDeliveryStatus = []WebhookDeliveryStatus{
+	WebhookDeliveryStatusPending,
+	WebhookDeliveryStatusSending,
+	WebhookDeliveryStatusDelivered,
+	WebhookDeliveryStatusFailed,
+}
+
+func (e WebhookDeliveryStatus) IsValid() bool {
+	switch e {
+	case WebhookDeliveryStatusPending, WebhookDeliveryStatusSending, WebhookDeliveryStatusDelivered, WebhookDeliveryStatusFailed:
+		return true
+	}
+	return false
+}
+
+func (e WebhookDeliveryStatus) String() string {
+	return string(e)
+}
+
+func (e *WebhookDeliveryStatus) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = WebhookDeliveryStatus(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid WebhookDeliveryStatus", str)
+	}
+	return nil
+}
+
+func (e WebhookDeliveryStatus) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type WebhookEventType string
+
+const (
+	WebhookEventTypeFileCreated WebhookEventType = "FILE_CREATED"
+	WebhookEventTypeFileUpdated WebhookEventType = "FILE_UPDATED"
+	WebhookEventTypeFileMoved   WebhookEventType = "FILE_MOVED"
+	WebhookEventTypeFileDeleted WebhookEventType = "FILE_DELETED"
+	// Metadata på en fil eller nod har ändrats
+	WebhookEventTypeMetadataChanged WebhookEventType = "METADATA_CHANGED"
+	WebhookEventTypeNodeCreated     WebhookEventType = "NODE_CREATED"
+	WebhookEventTypeNodeUpdated     WebhookEventType = "NODE_UPDATED"
+	WebhookEventTypeNodeMoved       WebhookEventType = "NODE_MOVED"
+	WebhookEventTypeNodeDeleted     WebhookEventType = "NODE_DELETED"
+	// Skickas bara av testWebhook
+	WebhookEventTypeTest WebhookEventType = "TEST"
+)
+
+var AllWebhookEventType = []WebhookEventType{
+	WebhookEventTypeFileCreated,
+	WebhookEventTypeFileUpdated,
+	WebhookEventTypeFileMoved,
+	WebhookEventTypeFileDeleted,
+	WebhookEventTypeMetadataChanged,
+	WebhookEventTypeNodeCreated,
+	WebhookEventTypeNodeUpdated,
+	WebhookEventTypeNodeMoved,
+	WebhookEventTypeNodeDeleted,
+	WebhookEventTypeTest,
+}
+
+func (e WebhookEventType) IsValid() bool {
+	switch e {
+	case WebhookEventTypeFileCreated, WebhookEventTypeFileUpdated, WebhookEventTypeFileMoved, WebhookEventTypeFileDeleted, WebhookEventTypeMetadataChanged, WebhookEventTypeNodeCreated, WebhookEventTypeNodeUpdated, WebhookEventTypeNodeMoved, WebhookEventTypeNodeDeleted, WebhookEventTypeTest:
+		return true
+	}
+	return false
+}
+
+func (e WebhookEventType) String() string {
+	return string(e)
+}
+
+func (e *WebhookEventType) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = WebhookEventType(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid WebhookEventType", str)
+	}
+	return nil
+}
+
+func (e WebhookEventType) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
@@ -0,0 +1,216 @@
+package graph
+
+// This file will be automatically regenerated based on the schema, any resolver implementations
+// will be copied through when generating and any unknown code will be moved to the end.
+// Code generated by github.com/99designs/gqlgen version v0.17.69
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"graphql-backend/graph/model"
+	"log"
+)
+
+// FileType is the resolver for the fileType field.
+func (r *fileResolver) FileType(ctx context.Context, obj *model.File) (model.FileType, error) {
+	if r.DB == nil {
+		return "", serviceErrorf(errInternal, "internal server error: database connection is not initialized")
+	}
+
+	return getFileType(r.DB, obj.ID)
+}
+
+// ArchiveEntity is the resolver for the archiveEntity field.
+func (r *fileResolver) ArchiveEntity(ctx context.Context, obj *model.File) (model.ArchiveEntity, error) {
+	if r.DB == nil {
+		return nil, serviceErrorf(errInternal, "internal server error: database connection is not initialized")
+	}
+
+	fileType, err := getFileType(r.DB, obj.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	values, err := loadTypedFields(r.DB, ENTITY_KIND_FILE, obj.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return buildFileArchiveEntity(obj.ID, obj.Name, fileType, values), nil
+}
+
+// StartNoarkExport is the resolver for the startNoarkExport field.
+func (r *mutationResolver) StartNoarkExport(ctx context.Context, nodeID string) (*model.Job, error) {
+	logAction(fmt.Sprintf("Starting Noark 5 export of node %s", nodeID))
+
+	if r.DB == nil {
+		log.Printf("Database connection is nil")
+		return nil, serviceErrorf(errInternal, "internal server error: database connection is not initialized")
+	}
+
+	// Arkivuttrekk omfattar hela underträdet och kräver därför administratörsbehörighet
+	userID, err := requireAdministrator(ctx, r.DB, "export archives")
+	if err != nil {
+		return nil, err
+	}
+
+	return startNoarkExportJob(r.DB, nodeID, userID)
+}
+
+// SetNodeType is the resolver for the setNodeType field.
+func (r *mutationResolver) SetNodeType(ctx context.Context, nodeID string, nodeType model.NodeType, fields []*model.TypedFieldInput) (*model.Node, error) {
+	logAction(fmt.Sprintf("Setting type of node %s to %s", nodeID, nodeType))
+
+	if r.DB == nil {
+		log.Printf("Database connection is nil")
+		return nil, serviceErrorf(errInternal, "internal server error: database connection is not initialized")
+	}
+
+	hasPermission, err := checkPermission(ctx, r.DB, nodeID, PERM_MODIFY)
+	if err != nil {
+		return nil, err
+	}
+	if !hasPermission {
+		return nil, serviceErrorf(errPermissionDenied, "permission denied: cannot modify this node")
+	}
+
+	if err := checkNodeNotHeld(r.DB, nodeID, "change node type"); err != nil {
+		return nil, err
+	}
+
+	values, err := validateTypedFields(string(nodeType), nodeTypeFields[nodeType], fields)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkNodeTypeFieldVocabularies(r.DB, nodeType, values); err != nil {
+		return nil, err
+	}
+
+	// Typbytet får inte bryta mot placeringsreglerna för förälder, barn eller filer
+	if err := checkNodeTypeChange(r.DB, nodeID, nodeType); err != nil {
+		return nil, err
+	}
+
+	tx, err := r.DB.Begin()
+	if err != nil {
+		log.Printf("Error starting transaction: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to start transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec("UPDATE nodes SET node_type = ?, updated_at = datetime('now') WHERE id = ?", nodeType, nodeID)
+	if err != nil {
+		log.Printf("Error updating node type: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to update node type: %v", err)
+	}
+
+	if err := saveTypedFields(tx, ENTITY_KIND_NODE, nodeID, values); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("Error committing transaction: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to commit transaction: %v", err)
+	}
+
+	log.Printf("Node %s is now of type %s", nodeID, nodeType)
+	return getNodeWithPermissions(ctx, r.DB, nodeID)
+}
+
+// SetFileType is the resolver for the setFileType field.
+func (r *mutationResolver) SetFileType(ctx context.Context, fileID string, fileType model.FileType, fields []*model.TypedFieldInput) (*model.File, error) {
+	logAction(fmt.Sprintf("Setting type of file %s to %s", fileID, fileType))
+
+	if r.DB == nil {
+		log.Printf("Database connection is nil")
+		return nil, serviceErrorf(errInternal, "internal server error: database connection is not initialized")
+	}
+
+	var nodeID string
+	err := r.DB.QueryRow("SELECT node_id FROM files WHERE id = ?", fileID).Scan(&nodeID)
+	if err == sql.ErrNoRows {
+		return nil, serviceErrorf(errNotFound, "file not found")
+	} else if err != nil {
+		log.Printf("Error fetching file with ID %s: %v", fileID, err)
+		return nil, serviceErrorf(errInternal, "failed to fetch file: %v", err)
+	}
+
+	hasPermission, err := checkPermission(ctx, r.DB, nodeID, PERM_MODIFY)
+	if err != nil {
+		return nil, err
+	}
+	if !hasPermission {
+		return nil, serviceErrorf(errPermissionDenied, "permission denied: cannot modify files in this node")
+	}
+
+	if err := checkFileNotHeld(r.DB, fileID, "change file type"); err != nil {
+		return nil, err
+	}
+
+	values, err := validateTypedFields(string(fileType), fileTypeFields[fileType], fields)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkFilePlacement(r.DB, fileType, nodeID); err != nil {
+		return nil, err
+	}
+
+	tx, err := r.DB.Begin()
+	if err != nil {
+		log.Printf("Error starting transaction: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to start transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("UPDATE files SET file_type = ?, updated_at = datetime('now') WHERE id = ?", fileType, fileID); err != nil {
+		log.Printf("Error updating file type: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to update file type: %v", err)
+	}
+
+	if err := saveTypedFields(tx, ENTITY_KIND_FILE, fileID, values); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("Error committing transaction: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to commit transaction: %v", err)
+	}
+
+	log.Printf("File %s is now of type %s", fileID, fileType)
+	return r.Files.Get(ctx, fileID)
+}
+
+// NodeType is the resolver for the nodeType field.
+func (r *nodeResolver) NodeType(ctx context.Context, obj *model.Node) (model.NodeType, error) {
+	if r.DB == nil {
+		return "", serviceErrorf(errInternal, "internal server error: database connection is not initialized")
+	}
+
+	return getNodeType(r.DB, obj.ID)
+}
+
+// ArchiveEntity is the resolver for the archiveEntity field.
+func (r *nodeResolver) ArchiveEntity(ctx context.Context, obj *model.Node) (model.ArchiveEntity, error) {
+	if r.DB == nil {
+		return nil, serviceErrorf(errInternal, "internal server error: database connection is not initialized")
+	}
+
+	nodeType, err := getNodeType(r.DB, obj.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Vanliga mappar är inga Noark-entiteter
+	if nodeType == model.NodeTypeFolder {
+		return nil, nil
+	}
+
+	values, err := loadTypedFields(r.DB, ENTITY_KIND_NODE, obj.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return buildNodeArchiveEntity(obj.ID, obj.Name, nodeType, values), nil
+}
@@ -0,0 +1,949 @@
+package graph
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"embed"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"graphql-backend/graph/model"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// =============================================
+// ========== NOARK 5 ARKIVUTTREKK ===========
+// =============================================
+
+// exportBaseDir är katalogen där alla exporter skrivs
+var exportBaseDir = "exports"
+
+// SetExportDir anger katalogen där exporterna skrivs. Anropas en gång vid start.
+func SetExportDir(dir string) {
+	exportBaseDir = dir
+}
+
+// Namnrymder för journalrapporterna, som delar samma Go-struktur
+const (
+	noarkNSLoependeJournal  = "http://www.arkivverket.no/standarder/noark5/loependeJournal"
+	noarkNSOffentligJournal = "http://www.arkivverket.no/standarder/noark5/offentligJournal"
+)
+
+// noarkSchemas innehåller de medföljande XSD-scheman som uttrekket valideras mot
+//
+//go:embed schemas/noark5/*.xsd
+var noarkSchemas embed.FS
+
+// noarkSystemIDNamespace används för att skapa stabila systemID:n (UUID v5) från databas-ID:n
+var noarkSystemIDNamespace = uuid.NewSHA1(uuid.NameSpaceURL, []byte("urn:e-arkive:noark5"))
+
+// ---------- arkivstruktur.xml ----------
+
+type n5Arkiv struct {
+	XMLName        xml.Name      `xml:"http://www.arkivverket.no/standarder/noark5/arkivstruktur arkiv"`
+	SystemID       string        `xml:"systemID"`
+	Tittel         string        `xml:"tittel"`
+	Beskrivelse    string        `xml:"beskrivelse,omitempty"`
+	Arkivstatus    string        `xml:"arkivstatus"`
+	Dokumentmedium string        `xml:"dokumentmedium"`
+	OpprettetDato  string        `xml:"opprettetDato"`
+	OpprettetAv    string        `xml:"opprettetAv"`
+	Arkivdeler     []*n5Arkivdel `xml:"arkivdel"`
+}
+
+type n5Arkivdel struct {
+	SystemID       string            `xml:"systemID"`
+	Tittel         string            `xml:"tittel"`
+	Arkivdelstatus string            `xml:"arkivdelstatus"`
+	Dokumentmedium string            `xml:"dokumentmedium"`
+	OpprettetDato  string            `xml:"opprettetDato"`
+	OpprettetAv    string            `xml:"opprettetAv"`
+	Mapper         []*n5Mappe        `xml:"mappe"`
+	Registreringer []*n5Registrering `xml:"registrering"`
+}
+
+type n5Mappe struct {
+	SystemID       string            `xml:"systemID"`
+	MappeID        string            `xml:"mappeID"`
+	Tittel         string            `xml:"tittel"`
+	OpprettetDato  string            `xml:"opprettetDato"`
+	OpprettetAv    string            `xml:"opprettetAv"`
+	Metadata       *n5Metadata       `xml:"virksomhetsspesifikkeMetadata,omitempty"`
+	Mapper         []*n5Mappe        `xml:"mappe"`
+	Registreringer []*n5Registrering `xml:"registrering"`
+}
+
+type n5Registrering struct {
+	SystemID            string                   `xml:"systemID"`
+	OpprettetDato       string                   `xml:"opprettetDato"`
+	OpprettetAv         string                   `xml:"opprettetAv"`
+	ArkivertDato        string                   `xml:"arkivertDato"`
+	ArkivertAv          string                   `xml:"arkivertAv"`
+	Tittel              string                   `xml:"tittel"`
+	Metadata            *n5Metadata              `xml:"virksomhetsspesifikkeMetadata,omitempty"`
+	Dokumentbeskrivelse []*n5Dokumentbeskrivelse `xml:"dokumentbeskrivelse"`
+}
+
+// n5Metadata bär filens eller mappens fria nyckel/värde-metadata
+type n5Metadata struct {
+	Felt []n5Felt `xml:"felt"`
+}
+
+type n5Felt struct {
+	Navn  string `xml:"navn,attr"`
+	Verdi string `xml:",chardata"`
+}
+
+type n5Dokumentbeskrivelse struct {
+	SystemID                  string              `xml:"systemID"`
+	Dokumenttype              string              `xml:"dokumenttype"`
+	Dokumentstatus            string              `xml:"dokumentstatus"`
+	Tittel                    string              `xml:"tittel"`
+	OpprettetDato             string              `xml:"opprettetDato"`
+	OpprettetAv               string              `xml:"opprettetAv"`
+	TilknyttetRegistreringSom string              `xml:"tilknyttetRegistreringSom"`
+	Dokumentnummer            int                 `xml:"dokumentnummer"`
+	TilknyttetDato            string              `xml:"tilknyttetDato"`
+	TilknyttetAv              string              `xml:"tilknyttetAv"`
+	Dokumentobjekt            []*n5Dokumentobjekt `xml:"dokumentobjekt"`
+}
+
+type n5Dokumentobjekt struct {
+	Versjonsnummer       int    `xml:"versjonsnummer"`
+	Variantformat        string `xml:"variantformat"`
+	Format               string `xml:"format"`
+	OpprettetDato        string `xml:"opprettetDato"`
+	OpprettetAv          string `xml:"opprettetAv"`
+	ReferanseDokumentfil string `xml:"referanseDokumentfil"`
+	Sjekksum             string `xml:"sjekksum"`
+	SjekksumAlgoritme    string `xml:"sjekksumAlgoritme"`
+	Filstoerrelse        int    `xml:"filstoerrelse"`
+}
+
+// ---------- endringslogg.xml ----------
+
+type n5Endringslogg struct {
+	XMLName xml.Name     `xml:"http://www.arkivverket.no/standarder/noark5/endringslogg endringslogg"`
+	Endring []*n5Endring `xml:"endring"`
+}
+
+type n5Endring struct {
+	ReferanseArkivenhet string  `xml:"referanseArkivenhet"`
+	ReferanseMetadata   string  `xml:"referanseMetadata"`
+	EndretDato          string  `xml:"endretDato"`
+	EndretAv            string  `xml:"endretAv"`
+	TidligereVerdi      *string `xml:"tidligereVerdi,omitempty"`
+	NyVerdi             *string `xml:"nyVerdi,omitempty"`
+}
+
+// ---------- loependeJournal.xml / offentligJournal.xml ----------
+
+type n5Journal struct {
+	XMLName             xml.Name                 `xml:""`
+	Journalhode         n5Journalhode            `xml:"journalhode"`
+	Journalregistrering []*n5Journalregistrering `xml:"journalregistrering"`
+}
+
+type n5Journalhode struct {
+	Arkivskaper         string   `xml:"arkivskaper"`
+	Arkiv               string   `xml:"arkiv"`
+	Arkivdel            []string `xml:"arkivdel"`
+	JournalStartDato    string   `xml:"journalStartDato"`
+	JournalSluttDato    string   `xml:"journalSluttDato"`
+	AntallJournalposter int      `xml:"antallJournalposter"`
+}
+
+type n5Journalregistrering struct {
+	Journalpost n5Journalpost `xml:"journalpost"`
+}
+
+type n5Journalpost struct {
+	SystemID             string            `xml:"systemID"`
+	Journalaar           int               `xml:"journalaar"`
+	Journalsekvensnummer int               `xml:"journalsekvensnummer"`
+	Journalpostnummer    int               `xml:"journalpostnummer"`
+	Journalposttype      string            `xml:"journalposttype"`
+	Journalstatus        string            `xml:"journalstatus"`
+	Journaldato          string            `xml:"journaldato"`
+	Tittel               string            `xml:"tittel,omitempty"`
+	OffentligTittel      string            `xml:"offentligTittel,omitempty"`
+	Mappe                *n5Mappereferanse `xml:"mappe,omitempty"`
+}
+
+type n5Mappereferanse struct {
+	MappeID         string `xml:"mappeID"`
+	Tittel          string `xml:"tittel,omitempty"`
+	OffentligTittel string `xml:"offentligTittel,omitempty"`
+}
+
+// ---------- arkivuttrekk.xml (ADDML) ----------
+
+type addmlRoot struct {
+	XMLName xml.Name     `xml:"http://www.arkivverket.no/standarder/addml addml"`
+	Dataset addmlDataset `xml:"dataset"`
+}
+
+type addmlDataset struct {
+	Name        string         `xml:"name,attr"`
+	Description string         `xml:"description"`
+	Reference   addmlReference `xml:"reference"`
+	FlatFiles   addmlFlatFiles `xml:"flatFiles"`
+}
+
+type addmlReference struct {
+	Context addmlContainer `xml:"context"`
+	Content addmlContainer `xml:"content"`
+}
+
+type addmlContainer struct {
+	AdditionalElements addmlAdditionalElements `xml:"additionalElements"`
+}
+
+type addmlAdditionalElements struct {
+	Elements []addmlAdditionalElement `xml:"additionalElement"`
+}
+
+type addmlAdditionalElement struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value"`
+}
+
+type addmlFlatFiles struct {
+	FlatFile []addmlFlatFile `xml:"flatFile"`
+}
+
+type addmlFlatFile struct {
+	Name                string          `xml:"name,attr"`
+	DefinitionReference string          `xml:"definitionReference,attr"`
+	Properties          addmlProperties `xml:"properties"`
+}
+
+type addmlProperties struct {
+	Property []addmlProperty `xml:"property"`
+}
+
+type addmlProperty struct {
+	Name       string           `xml:"name,attr"`
+	Value      string           `xml:"value,omitempty"`
+	Properties *addmlProperties `xml:"properties,omitempty"`
+}
+
+// noarkJournalEntry är en registrering som ska tas med i journalrapporterna.
+// fields innehåller journalpostens egna värden när filen är av typen JOURNALPOST.
+type noarkJournalEntry struct {
+	systemID   string
+	date       time.Time
+	title      string
+	mappeID    string
+	mappeTitle string
+	postnummer int
+	fields     map[string]string
+	screened   bool // Skjermet på registreringen eller en mappe ovanför, utelämnas i offentlig journal
+}
+
+// noarkExport håller tillståndet för ett pågående arkivuttrekk
+type noarkExport struct {
+	ctx        context.Context // Användaren som startade jobbet, för åtkomstloggningen
+	db         *sql.DB
+	job        *jobContext
+	outDir     string
+	exportedBy string
+	total      int
+	processed  int
+	journal    []*noarkJournalEntry
+	screened   map[string]bool // Skjermede noder, se screenedNodes
+}
+
+// NoarkExportResult är resultatet som sparas på jobbet när uttrekket är klart
+type NoarkExportResult struct {
+	Directory string            `json:"directory"`
+	Documents int               `json:"documents"`
+	Checksums map[string]string `json:"checksums"`
+}
+
+// runNoarkExport skapar ett komplett arkivuttrekk för en nod och dess underträd
+func runNoarkExport(db *sql.DB, job *jobContext, nodeID string, exportedBy string) (string, error) {
+	job.setProgress(0, "Reading node tree")
+
+	root, err := loadSubtree(db, nodeID)
+	if err != nil {
+		return "", err
+	}
+
+	outDir := filepath.Join(exportBaseDir, fmt.Sprintf("noark5-%s-%s", job.id, time.Now().Format("20060102150405")))
+	if err := os.MkdirAll(filepath.Join(outDir, "dokumenter"), 0755); err != nil {
+		return "", serviceErrorf(errInternal, "failed to create export directory: %v", err)
+	}
+
+	screened, err := screenedNodes(db, root)
+	if err != nil {
+		return "", err
+	}
+
+	export := &noarkExport{
+		ctx:        job.userContext("", ""),
+		db:         db,
+		job:        job,
+		outDir:     outDir,
+		exportedBy: exportedBy,
+		total:      root.countFiles(),
+		screened:   screened,
+	}
+
+	arkiv, err := export.buildArkiv(root)
+	if err != nil {
+		return "", err
+	}
+
+	job.setProgress(90, "Writing reports")
+
+	endringslogg, err := export.buildEndringslogg(root)
+	if err != nil {
+		return "", err
+	}
+
+	checksums := make(map[string]string)
+	documents := map[string]interface{}{
+		"arkivstruktur.xml":    arkiv,
+		"endringslogg.xml":     endringslogg,
+		"loependeJournal.xml":  export.buildJournal(arkiv, false),
+		"offentligJournal.xml": export.buildJournal(arkiv, true),
+	}
+
+	for _, name := range []string{"arkivstruktur.xml", "endringslogg.xml", "loependeJournal.xml", "offentligJournal.xml"} {
+		checksum, err := export.writeValidatedXML(name, documents[name])
+		if err != nil {
+			return "", err
+		}
+		checksums[name] = checksum
+	}
+
+	if _, err := export.writeValidatedXML("arkivuttrekk.xml", export.buildArkivuttrekk(arkiv, checksums)); err != nil {
+		return "", err
+	}
+
+	if err := export.copySchemas(); err != nil {
+		return "", err
+	}
+
+	result, err := json.Marshal(NoarkExportResult{
+		Directory: outDir,
+		Documents: export.total,
+		Checksums: checksums,
+	})
+	if err != nil {
+		return "", serviceErrorf(errInternal, "failed to encode export result: %v", err)
+	}
+
+	logAction(fmt.Sprintf("Noark 5 export of node %s written to %s", nodeID, outDir))
+	return string(result), nil
+}
+
+// buildArkiv mappar nodträdet till arkiv -> arkivdel -> mappe -> registrering.
+// Barnnoder till den exporterade noden blir arkivdelar och djupare noder blir mappar.
+func (e *noarkExport) buildArkiv(root *treeNode) (*n5Arkiv, error) {
+	arkiv := &n5Arkiv{
+		SystemID:       noarkSystemID("node", root.ID),
+		Tittel:         root.Name,
+		Beskrivelse:    root.Fields["beskrivelse"],
+		Arkivstatus:    fieldOrDefault(root.Fields, "arkivstatus", "Opprettet"),
+		Dokumentmedium: "Elektronisk arkiv",
+		OpprettetDato:  toXSDDateTime(root.CreatedAt),
+		OpprettetAv:    ownerOrSystem(root.OwnerName),
+	}
+
+	// Filer direkt på arkivnivå samlas i en egen arkivdel eftersom Noark inte tillåter
+	// registreringar direkt under arkiv
+	if len(root.Files) > 0 {
+		arkivdel := &n5Arkivdel{
+			SystemID:       noarkSystemID("arkivdel-root", root.ID),
+			Tittel:         root.Name,
+			Arkivdelstatus: "Aktiv periode",
+			Dokumentmedium: "Elektronisk arkiv",
+			OpprettetDato:  toXSDDateTime(root.CreatedAt),
+			OpprettetAv:    ownerOrSystem(root.OwnerName),
+		}
+		for _, file := range root.Files {
+			reg, err := e.buildRegistrering(file, root, nil)
+			if err != nil {
+				return nil, err
+			}
+			arkivdel.Registreringer = append(arkivdel.Registreringer, reg)
+		}
+		arkiv.Arkivdeler = append(arkiv.Arkivdeler, arkivdel)
+	}
+
+	for _, child := range root.Children {
+		arkivdel := &n5Arkivdel{
+			SystemID:       noarkSystemID("node", child.ID),
+			Tittel:         child.Name,
+			Arkivdelstatus: fieldOrDefault(child.Fields, "arkivdelstatus", "Aktiv periode"),
+			Dokumentmedium: "Elektronisk arkiv",
+			OpprettetDato:  toXSDDateTime(child.CreatedAt),
+			OpprettetAv:    ownerOrSystem(child.OwnerName),
+		}
+
+		for _, grandchild := range child.Children {
+			mappe, err := e.buildMappe(grandchild)
+			if err != nil {
+				return nil, err
+			}
+			arkivdel.Mapper = append(arkivdel.Mapper, mappe)
+		}
+
+		for _, file := range child.Files {
+			reg, err := e.buildRegistrering(file, child, nil)
+			if err != nil {
+				return nil, err
+			}
+			arkivdel.Registreringer = append(arkivdel.Registreringer, reg)
+		}
+
+		arkiv.Arkivdeler = append(arkiv.Arkivdeler, arkivdel)
+	}
+
+	return arkiv, nil
+}
+
+// buildMappe skapar en mappe med undermapper och registreringar för en nod
+func (e *noarkExport) buildMappe(node *treeNode) (*n5Mappe, error) {
+	mappe := &n5Mappe{
+		SystemID:      noarkSystemID("node", node.ID),
+		MappeID:       node.ID,
+		Tittel:        node.Name,
+		OpprettetDato: toXSDDateTime(node.CreatedAt),
+		OpprettetAv:   ownerOrSystem(node.OwnerName),
+	}
+	if node.NodeType == model.NodeTypeSaksmappe {
+		mappe.MappeID = saksmappeID(node.Fields)
+	}
+	mappe.Metadata = newN5Metadata(node.Metadata)
+
+	for _, child := range node.Children {
+		undermappe, err := e.buildMappe(child)
+		if err != nil {
+			return nil, err
+		}
+		mappe.Mapper = append(mappe.Mapper, undermappe)
+	}
+
+	for _, file := range node.Files {
+		reg, err := e.buildRegistrering(file, node, mappe)
+		if err != nil {
+			return nil, err
+		}
+		mappe.Registreringer = append(mappe.Registreringer, reg)
+	}
+
+	return mappe, nil
+}
+
+// buildRegistrering skriver filens innehåll till uttrekket och beskriver den som en registrering
+func (e *noarkExport) buildRegistrering(file *treeFile, node *treeNode, mappe *n5Mappe) (*n5Registrering, error) {
+	if err := e.job.err(); err != nil {
+		return nil, err
+	}
+	if err := RecordFileAccess(e.ctx, e.db, file.ID, model.FileAccessTypeExport); err != nil {
+		return nil, err
+	}
+	data, err := readFileData(e.db, file.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	relPath := path.Join("dokumenter", fmt.Sprintf("%s-%s", file.ID, sanitizeFileName(file.Name)))
+	if err := os.WriteFile(filepath.Join(e.outDir, filepath.FromSlash(relPath)), data, 0644); err != nil {
+		return nil, serviceErrorf(errInternal, "failed to write document %s: %v", file.Name, err)
+	}
+
+	sum := sha256.Sum256(data)
+	createdAt := toXSDDateTime(file.CreatedAt)
+	createdBy := ownerOrSystem(node.OwnerName)
+
+	reg := &n5Registrering{
+		SystemID:      noarkSystemID("file", file.ID),
+		OpprettetDato: createdAt,
+		OpprettetAv:   createdBy,
+		ArkivertDato:  createdAt,
+		ArkivertAv:    createdBy,
+		Tittel:        file.Name,
+		Dokumentbeskrivelse: []*n5Dokumentbeskrivelse{{
+			SystemID:                  noarkSystemID("dokumentbeskrivelse", file.ID),
+			Dokumenttype:              fieldOrDefault(file.Fields, "dokumenttype", metadataValue(file.Metadata, "dokumenttype", "Dokument")),
+			Dokumentstatus:            fieldOrDefault(file.Fields, "dokumentstatus", "Dokumentet er ferdigstilt"),
+			Tittel:                    file.Name,
+			OpprettetDato:             createdAt,
+			OpprettetAv:               createdBy,
+			TilknyttetRegistreringSom: "Hoveddokument",
+			Dokumentnummer:            1,
+			TilknyttetDato:            createdAt,
+			TilknyttetAv:              createdBy,
+			Dokumentobjekt: []*n5Dokumentobjekt{{
+				Versjonsnummer:       1,
+				Variantformat:        "Arkivformat",
+				Format:               file.formatCode(),
+				OpprettetDato:        createdAt,
+				OpprettetAv:          createdBy,
+				ReferanseDokumentfil: relPath,
+				Sjekksum:             hex.EncodeToString(sum[:]),
+				SjekksumAlgoritme:    "SHA256",
+				Filstoerrelse:        len(data),
+			}},
+		}},
+	}
+
+	reg.Metadata = newN5Metadata(file.Metadata)
+
+	entry := &noarkJournalEntry{
+		systemID: reg.SystemID,
+		date:     parseDBTime(file.CreatedAt),
+		title:    file.Name,
+		screened: e.screened[node.ID] || isScreened(file.Fields),
+	}
+	if file.FileType == model.FileTypeJournalpost {
+		entry.fields = file.Fields
+		entry.date = parseDBTime(file.Fields["journaldato"])
+	}
+	if mappe != nil {
+		entry.mappeID = mappe.MappeID
+		entry.mappeTitle = mappe.Tittel
+		entry.postnummer = len(mappe.Registreringer) + 1
+	} else {
+		entry.postnummer = 1
+	}
+	e.journal = append(e.journal, entry)
+
+	e.processed++
+	if e.total > 0 {
+		e.job.setProgress(e.processed*90/e.total, fmt.Sprintf("Exported %d of %d documents", e.processed, e.total))
+	}
+
+	return reg, nil
+}
+
+// buildJournal skapar löpande eller offentlig journal från uttrekkets registreringar.
+// Den offentliga journalen utelämnar skjermede registreringar, men sekvensnumren är desamma
+// som i den löpande journalen.
+func (e *noarkExport) buildJournal(arkiv *n5Arkiv, public bool) *n5Journal {
+	entries := make([]*noarkJournalEntry, len(e.journal))
+	copy(entries, e.journal)
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].date.Before(entries[j].date)
+	})
+
+	journal := &n5Journal{
+		XMLName: xml.Name{Space: noarkNSLoependeJournal, Local: "loependeJournal"},
+		Journalhode: n5Journalhode{
+			Arkivskaper: "e-Arkive",
+			Arkiv:       arkiv.Tittel,
+		},
+	}
+	if public {
+		journal.XMLName = xml.Name{Space: noarkNSOffentligJournal, Local: "offentligJournal"}
+	}
+
+	for _, arkivdel := range arkiv.Arkivdeler {
+		journal.Journalhode.Arkivdel = append(journal.Journalhode.Arkivdel, arkivdel.Tittel)
+	}
+
+	var included []*noarkJournalEntry
+	sequence := make(map[int]int)
+	for _, entry := range entries {
+		year := entry.date.Year()
+		sequence[year]++
+		if public && entry.screened {
+			continue
+		}
+		included = append(included, entry)
+
+		post := n5Journalpost{
+			SystemID:             entry.systemID,
+			Journalaar:           year,
+			Journalsekvensnummer: sequence[year],
+			Journalpostnummer:    entry.postnummer,
+			Journalposttype:      "Organinternt dokument uten oppfølging",
+			Journalstatus:        "Arkivert",
+			Journaldato:          entry.date.Format("2006-01-02"),
+		}
+
+		var mappe *n5Mappereferanse
+		if entry.mappeID != "" {
+			mappe = &n5Mappereferanse{MappeID: entry.mappeID}
+		}
+
+		// Journalposter har egna journalfält som ersätter de härledda värdena
+		if entry.fields != nil {
+			post.Journalaar = intValue(entry.fields, "journalaar")
+			post.Journalsekvensnummer = intValue(entry.fields, "journalsekvensnummer")
+			post.Journalpostnummer = intValue(entry.fields, "journalpostnummer")
+			post.Journalposttype = entry.fields["journalposttype"]
+			post.Journalstatus = entry.fields["journalstatus"]
+			post.Journaldato = entry.fields["journaldato"]
+		}
+
+		if public {
+			post.OffentligTittel = fieldOrDefault(entry.fields, "offentligTittel", entry.title)
+			if mappe != nil {
+				mappe.OffentligTittel = entry.mappeTitle
+			}
+		} else {
+			post.Tittel = entry.title
+			if mappe != nil {
+				mappe.Tittel = entry.mappeTitle
+			}
+		}
+		post.Mappe = mappe
+
+		journal.Journalregistrering = append(journal.Journalregistrering, &n5Journalregistrering{Journalpost: post})
+	}
+
+	start, end := time.Now(), time.Now()
+	if len(included) > 0 {
+		start, end = included[0].date, included[len(included)-1].date
+	}
+	journal.Journalhode.JournalStartDato = start.Format("2006-01-02")
+	journal.Journalhode.JournalSluttDato = end.Format("2006-01-02")
+	journal.Journalhode.AntallJournalposter = len(included)
+
+	return journal
+}
+
+// auditEntitySnapshot är de delar av revisionsloggens ögonblicksbild av en nod eller fil som
+// förs in i endringsloggen. Fields saknas i ögonblicksbilder från före Noark 5-fälten loggades.
+type auditEntitySnapshot struct {
+	Name     *string           `json:"name"`
+	Metadata map[string]string `json:"metadata"`
+	Fields   map[string]string `json:"fields"`
+}
+
+// buildEndringslogg skapar endringsloggen från revisionsloggen. Varje lyckad ändring av en
+// exporterad nod eller fil jämförs före och efter, och varje ändrat namn, metadatavärde och
+// Noark 5-fält blir en endring. Namnet loggas som tittel.
+func (e *noarkExport) buildEndringslogg(root *treeNode) (*n5Endringslogg, error) {
+	exported := make(map[string]bool)
+	root.walk(func(node *treeNode) {
+		exported["node:"+node.ID] = true
+		for _, file := range node.Files {
+			exported["file:"+file.ID] = true
+		}
+	})
+
+	rows, err := e.db.Query(`
+		SELECT occurred_at, actor, target_type, target_id, before_value, after_value
+		FROM audit_events
+		WHERE target_type IN ('node', 'file') AND outcome = ?
+			AND before_value IS NOT NULL AND after_value IS NOT NULL
+		ORDER BY id
+	`, AUDIT_OUTCOME_SUCCESS)
+	if err != nil {
+		log.Printf("Error fetching audit events for change log: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to fetch audit events: %v", err)
+	}
+	defer rows.Close()
+
+	logg := &n5Endringslogg{}
+	for rows.Next() {
+		var occurredAt, targetType, targetID, before, after string
+		var actor sql.NullString
+		if err := rows.Scan(&occurredAt, &actor, &targetType, &targetID, &before, &after); err != nil {
+			log.Printf("Error scanning audit event for change log: %v", err)
+			return nil, serviceErrorf(errInternal, "failed to scan audit event: %v", err)
+		}
+		if !exported[targetType+":"+targetID] {
+			continue
+		}
+
+		var previous, current auditEntitySnapshot
+		if json.Unmarshal([]byte(before), &previous) != nil || json.Unmarshal([]byte(after), &current) != nil {
+			log.Printf("Skipping audit event for %s %s with unreadable snapshots", targetType, targetID)
+			continue
+		}
+
+		changed := func(name string, oldValue, newValue *string) {
+			logg.Endring = append(logg.Endring, &n5Endring{
+				ReferanseArkivenhet: noarkSystemID(targetType, targetID),
+				ReferanseMetadata:   name,
+				EndretDato:          toXSDDateTime(occurredAt),
+				EndretAv:            ownerOrSystem(actor.String),
+				TidligereVerdi:      oldValue,
+				NyVerdi:             newValue,
+			})
+		}
+
+		if previous.Name != nil && current.Name != nil && *previous.Name != *current.Name {
+			changed("tittel", previous.Name, current.Name)
+		}
+		diffSnapshotValues(previous.Metadata, current.Metadata, changed)
+		if previous.Fields != nil && current.Fields != nil {
+			diffSnapshotValues(previous.Fields, current.Fields, changed)
+		}
+	}
+
+	return logg, rows.Err()
+}
+
+// diffSnapshotValues anropar changed för varje nyckel vars värde lagts till, ändrats eller tagits
+// bort, i bokstavsordning. Ett värde som saknas eller är tomt skickas som nil.
+func diffSnapshotValues(before, after map[string]string, changed func(name string, oldValue, newValue *string)) {
+	keys := make(map[string]bool)
+	for key := range before {
+		keys[key] = true
+	}
+	for key := range after {
+		keys[key] = true
+	}
+	names := make([]string, 0, len(keys))
+	for key := range keys {
+		names = append(names, key)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		oldValue, newValue := optionalValue(before, name), optionalValue(after, name)
+		if (oldValue == nil && newValue == nil) || (oldValue != nil && newValue != nil && *oldValue == *newValue) {
+			continue
+		}
+		changed(name, oldValue, newValue)
+	}
+}
+
+// screenedNodes avgör vilka noder i underträdet som är skjermet, på noden själv eller på en
+// nod ovanför, även ovanför den exporterade noden
+func screenedNodes(db *sql.DB, root *treeNode) (map[string]bool, error) {
+	screened := make(map[string]bool)
+	if root.ParentID != nil {
+		inherited, err := screenedNode(db, *root.ParentID)
+		if err != nil {
+			return nil, err
+		}
+		screened[*root.ParentID] = inherited
+	}
+
+	root.walk(func(node *treeNode) {
+		screened[node.ID] = isScreened(node.Fields) || (node.ParentID != nil && screened[*node.ParentID])
+	})
+	return screened, nil
+}
+
+// buildArkivuttrekk beskriver uttrekket och listar filerna med kontrollsummor
+func (e *noarkExport) buildArkivuttrekk(arkiv *n5Arkiv, checksums map[string]string) *addmlRoot {
+	root := &addmlRoot{
+		Dataset: addmlDataset{
+			Name:        "e-Arkive arkivuttrekk",
+			Description: fmt.Sprintf("Noark 5 arkivuttrekk av %s", arkiv.Tittel),
+		},
+	}
+
+	root.Dataset.Reference.Context.AdditionalElements.Elements = []addmlAdditionalElement{
+		{Name: "recordCreators", Value: "e-Arkive"},
+		{Name: "systemType", Value: "Noark 5"},
+		{Name: "systemName", Value: "e-Arkive"},
+		{Name: "archive", Value: arkiv.Tittel},
+		{Name: "archiveSystemID", Value: arkiv.SystemID},
+		{Name: "exportedBy", Value: e.exportedBy},
+		{Name: "exportDate", Value: time.Now().Format(time.RFC3339)},
+	}
+	root.Dataset.Reference.Content.AdditionalElements.Elements = []addmlAdditionalElement{
+		{Name: "numberOfArchiveParts", Value: strconv.Itoa(len(arkiv.Arkivdeler))},
+		{Name: "numberOfDocuments", Value: strconv.Itoa(e.total)},
+	}
+
+	names := make([]string, 0, len(checksums))
+	for name := range checksums {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		definition := name[:len(name)-len(filepath.Ext(name))]
+		root.Dataset.FlatFiles.FlatFile = append(root.Dataset.FlatFiles.FlatFile, addmlFlatFile{
+			Name:                definition,
+			DefinitionReference: definition,
+			Properties: addmlProperties{Property: []addmlProperty{{
+				Name: "file",
+				Properties: &addmlProperties{Property: []addmlProperty{
+					{Name: "name", Value: name},
+					{Name: "schema", Value: definition + ".xsd"},
+					{Name: "checksum", Properties: &addmlProperties{Property: []addmlProperty{
+						{Name: "algorithm", Value: "SHA256"},
+						{Name: "value", Value: checksums[name]},
+					}}},
+				}},
+			}}},
+		})
+	}
+
+	return root
+}
+
+// writeValidatedXML serialiserar ett dokument, validerar det mot sitt schema och skriver det.
+// Returnerar filens SHA256-kontrollsumma.
+func (e *noarkExport) writeValidatedXML(name string, document interface{}) (string, error) {
+	body, err := xml.MarshalIndent(document, "", "  ")
+	if err != nil {
+		return "", serviceErrorf(errInternal, "failed to encode %s: %v", name, err)
+	}
+	content := append([]byte(xml.Header), body...)
+
+	schemaName := name[:len(name)-len(filepath.Ext(name))] + ".xsd"
+	if err := validateAgainstNoarkSchema(schemaName, content); err != nil {
+		return "", fmt.Errorf("%s: %v", name, err)
+	}
+
+	if err := os.WriteFile(filepath.Join(e.outDir, name), content, 0644); err != nil {
+		return "", serviceErrorf(errInternal, "failed to write %s: %v", name, err)
+	}
+
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// copySchemas lägger de medföljande XSD-filerna bredvid XML-filerna i uttrekket
+func (e *noarkExport) copySchemas() error {
+	return fs.WalkDir(noarkSchemas, "schemas/noark5", func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := noarkSchemas.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(filepath.Join(e.outDir, path.Base(p)), data, 0644)
+	})
+}
+
+// validateAgainstNoarkSchema validerar ett dokument mot ett av de medföljande scheman
+func validateAgainstNoarkSchema(schemaName string, document []byte) error {
+	data, err := noarkSchemas.ReadFile(path.Join("schemas/noark5", schemaName))
+	if err != nil {
+		return serviceErrorf(errNotFound, "schema %s not found: %v", schemaName, err)
+	}
+
+	schema, err := loadXSD(data)
+	if err != nil {
+		return serviceErrorf(errInternal, "failed to load schema %s: %v", schemaName, err)
+	}
+
+	return schema.validate(document)
+}
+
+// =============================================
+// ========== HJÄLPFUNKTIONER ================
+// =============================================
+
+// noarkSystemID skapar ett stabilt systemID för en entitet baserat på typ och databas-ID
+func noarkSystemID(kind string, id string) string {
+	return uuid.NewSHA1(noarkSystemIDNamespace, []byte(kind+":"+id)).String()
+}
+
+// parseDBTime tolkar tidsstämplar i de format som förekommer i databasen
+func parseDBTime(value string) time.Time {
+	for _, layout := range []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02T15:04:05", "2006-01-02"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t
+		}
+	}
+	return time.Now()
+}
+
+// toXSDDateTime konverterar en tidsstämpel från databasen till xs:dateTime
+func toXSDDateTime(value string) string {
+	return parseDBTime(value).Format(time.RFC3339)
+}
+
+// ownerOrSystem returnerar ägarens användarnamn eller "system" om ägare saknas
+func ownerOrSystem(owner string) string {
+	if owner == "" {
+		return "system"
+	}
+	return owner
+}
+
+// fieldOrDefault hämtar ett Noark 5-fält, eller ett standardvärde om fältet saknas
+func fieldOrDefault(fields map[string]string, name string, fallback string) string {
+	if value := fields[name]; value != "" {
+		return value
+	}
+	return fallback
+}
+
+// newN5Metadata skapar virksomhetsspesifikkeMetadata av fri metadata, eller nil om det inte finns någon
+func newN5Metadata(metadata []*model.Metadata) *n5Metadata {
+	if len(metadata) == 0 {
+		return nil
+	}
+	result := &n5Metadata{}
+	for _, meta := range metadata {
+		result.Felt = append(result.Felt, n5Felt{Navn: meta.Key, Verdi: meta.Value})
+	}
+	return result
+}
+
+// metadataValue hämtar värdet för en metadatanyckel, eller ett standardvärde
+func metadataValue(metadata []*model.Metadata, key string, fallback string) string {
+	for _, meta := range metadata {
+		if meta != nil && meta.Key == key && meta.Value != "" {
+			return meta.Value
+		}
+	}
+	return fallback
+}
+
+var unsafeFileNameChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// sanitizeFileName gör ett filnamn säkert att använda i en sökväg
+func sanitizeFileName(name string) string {
+	clean := unsafeFileNameChars.ReplaceAllString(name, "_")
+	if clean == "" || clean == "." || clean == ".." {
+		return "file"
+	}
+	return clean
+}
+
+// startNoarkExportJob kontrollerar noden och startar uttrekket som ett bakgrundsjobb
+func startNoarkExportJob(db *sql.DB, nodeID string, userID string) (*model.Job, error) {
+	var exists bool
+	err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM nodes WHERE id = ?)", nodeID).Scan(&exists)
+	if err != nil {
+		log.Printf("Error checking if node exists: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to check if node exists: %v", err)
+	}
+	if !exists {
+		return nil, serviceErrorf(errNotFound, "node not found")
+	}
+
+	var username string
+	if err := db.QueryRow("SELECT username FROM users WHERE id = ?", userID).Scan(&username); err != nil {
+		log.Printf("Error fetching username for user %s: %v", userID, err)
+		return nil, serviceErrorf(errInternal, "failed to fetch user: %v", err)
+	}
+
+	return enqueueJob(db, JOB_TYPE_NOARK_EXPORT, userID, noarkExportPayload{NodeID: nodeID, ExportedBy: username}, nil)
+}
+
+// noarkExportPayload är parametrarna för ett uttrekksjobb
+type noarkExportPayload struct {
+	NodeID     string `json:"nodeId"`
+	ExportedBy string `json:"exportedBy"`
+}
+
+// runNoarkExportJob skriver uttrekket till en ny katalog för varje försök
+func runNoarkExportJob(job *jobContext) (string, error) {
+	var payload noarkExportPayload
+	if err := job.decodePayload(&payload); err != nil {
+		return "", err
+	}
+	return runNoarkExport(job.db, job, payload.NodeID, payload.ExportedBy)
+}
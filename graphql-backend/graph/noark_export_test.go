@@ -0,0 +1,306 @@
+package graph
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"graphql-backend/graph/model"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// readTestExport tolkar resultatet från ett uttrekk och läser arkivstrukturen
+func readTestExport(t *testing.T, result string) (*NoarkExportResult, *n5Arkiv) {
+	t.Helper()
+	var export NoarkExportResult
+	if err := json.Unmarshal([]byte(result), &export); err != nil {
+		t.Fatalf("decode result: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(export.Directory, "arkivstruktur.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var arkiv n5Arkiv
+	if err := xml.Unmarshal(data, &arkiv); err != nil {
+		t.Fatalf("decode arkivstruktur.xml: %v", err)
+	}
+	return &export, &arkiv
+}
+
+// insertTestJournalpost lägger in en journalpost med de obligatoriska journalfälten och extra
+func insertTestJournalpost(t *testing.T, db *sql.DB, name string, nodeID string, sequence string, extra map[string]string) string {
+	t.Helper()
+	fileID := insertTestFile(t, db, name, nodeID)
+	mustExec(t, db, "UPDATE files SET file_type = ? WHERE id = ?", model.FileTypeJournalpost, fileID)
+	fields := map[string]string{
+		"journalaar":           "2026",
+		"journalsekvensnummer": sequence,
+		"journalpostnummer":    sequence,
+		"journalposttype":      "Inngående dokument",
+		"journalstatus":        "Journalført",
+		"journaldato":          "2026-03-0" + sequence,
+	}
+	for name, value := range extra {
+		fields[name] = value
+	}
+	if err := saveTypedFields(db, ENTITY_KIND_FILE, fileID, fields); err != nil {
+		t.Fatal(err)
+	}
+	return fileID
+}
+
+// testJournal läser en journalrapport ur ett uttrekk
+func testJournal(t *testing.T, dir string, name string) *n5Journal {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var journal n5Journal
+	if err := xml.Unmarshal(data, &journal); err != nil {
+		t.Fatalf("decode %s: %v", name, err)
+	}
+	return &journal
+}
+
+func TestNoarkExport(t *testing.T) {
+	db := openTestDB(t)
+	t.Chdir(t.TempDir())
+
+	arkivID := insertTestNode(t, db, "Arkiv", "")
+	arkivdelID := insertTestNode(t, db, "Korrespondanse", arkivID)
+	mappeID := insertTestNode(t, db, "Byggesak", arkivdelID)
+	looseID := insertTestFile(t, db, "notat.txt", arkivdelID)
+	letterID := insertTestFile(t, db, "brev/svar.txt", mappeID)
+	mustExec(t, db, "INSERT INTO metadata (file_id, key, value) VALUES (?, 'dokumenttype', 'Brev')", letterID)
+	job := testJobContext(t, db)
+
+	result, err := runNoarkExport(db, job, arkivID, "admin")
+	if err != nil {
+		t.Fatalf("noark export: %v", err)
+	}
+	export, arkiv := readTestExport(t, result)
+	if export.Documents != 2 || len(export.Checksums) != 4 {
+		t.Errorf("result = %+v, want 2 documents and 4 reports", export)
+	}
+
+	// Barnnoder blir arkivdelar och djupare noder mappar
+	if arkiv.Tittel != "Arkiv" || arkiv.SystemID != noarkSystemID("node", arkivID) || len(arkiv.Arkivdeler) != 1 {
+		t.Fatalf("arkiv = %+v", arkiv)
+	}
+	arkivdel := arkiv.Arkivdeler[0]
+	if arkivdel.Tittel != "Korrespondanse" || len(arkivdel.Mapper) != 1 || len(arkivdel.Registreringer) != 1 {
+		t.Fatalf("arkivdel = %+v", arkivdel)
+	}
+	if arkivdel.Registreringer[0].SystemID != noarkSystemID("file", looseID) {
+		t.Errorf("registrering in arkivdel = %+v", arkivdel.Registreringer[0])
+	}
+	mappe := arkivdel.Mapper[0]
+	if mappe.Tittel != "Byggesak" || len(mappe.Registreringer) != 1 {
+		t.Fatalf("mappe = %+v", mappe)
+	}
+
+	// Dokumentet kopieras till uttrekket med sin kontrollsumma
+	letter := mappe.Registreringer[0].Dokumentbeskrivelse[0]
+	if letter.Dokumenttype != "Brev" {
+		t.Errorf("dokumenttype = %q, want the file's metadata", letter.Dokumenttype)
+	}
+	object := letter.Dokumentobjekt[0]
+	sum := sha256.Sum256([]byte("hello"))
+	if object.ReferanseDokumentfil != "dokumenter/"+letterID+"-brev_svar.txt" || object.Sjekksum != hex.EncodeToString(sum[:]) || object.Filstoerrelse != 5 {
+		t.Errorf("dokumentobjekt = %+v", object)
+	}
+	if data, err := os.ReadFile(filepath.Join(export.Directory, filepath.FromSlash(object.ReferanseDokumentfil))); err != nil || string(data) != "hello" {
+		t.Errorf("document = %q, %v", data, err)
+	}
+
+	// Rapporterna har kontrollsummor i arkivuttrekk.xml, och scheman ligger bredvid
+	for name, checksum := range export.Checksums {
+		data, err := os.ReadFile(filepath.Join(export.Directory, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sum := sha256.Sum256(data); hex.EncodeToString(sum[:]) != checksum {
+			t.Errorf("checksum of %s does not match", name)
+		}
+	}
+	for _, name := range []string{"arkivuttrekk.xml", "arkivstruktur.xsd", "offentligJournal.xsd"} {
+		if _, err := os.Stat(filepath.Join(export.Directory, name)); err != nil {
+			t.Errorf("%s missing: %v", name, err)
+		}
+	}
+
+	var progress int
+	if err := db.QueryRow("SELECT progress FROM jobs WHERE id = ?", job.id).Scan(&progress); err != nil || progress != 90 {
+		t.Errorf("job progress = %d, %v, want 90", progress, err)
+	}
+}
+
+func TestStartNoarkExport(t *testing.T) {
+	db := openTestDB(t)
+	t.Chdir(t.TempDir())
+	mutation := NewResolver(db).Mutation()
+	arkivID := insertTestNode(t, db, "Arkiv", "")
+	insertTestFile(t, db, "notat.txt", arkivID)
+
+	bobID := insertTestUser(t, db, "bob")
+	if _, err := mutation.StartNoarkExport(testUserContext(t, bobID, "bob"), arkivID); err == nil || err.Error() != "permission denied: must be an administrator to export archives" {
+		t.Errorf("export as non-administrator: err = %v", err)
+	}
+	if _, err := mutation.StartNoarkExport(testAdminContext(t), "999"); err == nil || err.Error() != "node not found" {
+		t.Errorf("export of missing node: err = %v", err)
+	}
+
+	job, err := mutation.StartNoarkExport(testAdminContext(t), arkivID)
+	if err != nil {
+		t.Fatalf("start export: %v", err)
+	}
+	if job.Type != JOB_TYPE_NOARK_EXPORT || job.Status != model.JobStatusQueued {
+		t.Errorf("job = %+v, want a queued export", job)
+	}
+	job = runQueuedTestJob(t, db)
+	if job.Status != model.JobStatusSucceeded || job.Result == nil {
+		t.Fatalf("job = %s (%v), want SUCCEEDED", job.Status, job.Error)
+	}
+	if export, arkiv := readTestExport(t, *job.Result); export.Documents != 1 || arkiv.Tittel != "Arkiv" {
+		t.Errorf("export = %+v, arkiv %q", export, arkiv.Tittel)
+	}
+}
+
+func TestNoarkExportScreensPublicJournal(t *testing.T) {
+	db := openTestDB(t)
+	t.Chdir(t.TempDir())
+
+	arkivID := insertTestNode(t, db, "Arkiv", "")
+	arkivdelID := insertTestNode(t, db, "Saker", arkivID)
+	sakFields := map[string]string{
+		"saksaar": "2026", "sakssekvensnummer": "1", "saksdato": "2026-03-01",
+		"administrativEnhet": "Kansli", "saksansvarlig": "admin", "saksstatus": "Under behandling",
+	}
+	openCase := insertTestNode(t, db, "Byggesak", arkivdelID)
+	setTestNodeType(t, db, openCase, model.NodeTypeSaksmappe, sakFields)
+	screenedCase := insertTestNode(t, db, "Barnevernssak", arkivdelID)
+	sakFields["sakssekvensnummer"] = "2"
+	sakFields["tilgangsrestriksjon"] = "Unntatt offentlighet"
+	sakFields["skjermingshjemmel"] = "Offl. § 13"
+	setTestNodeType(t, db, screenedCase, model.NodeTypeSaksmappe, sakFields)
+	underScreenedCase := insertTestNode(t, db, "Delsak", screenedCase)
+
+	public := insertTestJournalpost(t, db, "Søknad om byggetillatelse", openCase, "1", map[string]string{"offentligTittel": "Søknad om byggetillatelse"})
+	insertTestJournalpost(t, db, "Klage fra nabo Ola Nordmann", openCase, "2", map[string]string{"tilgangsrestriksjon": "Unntatt offentlighet"})
+	insertTestJournalpost(t, db, "Melding om bekymring", screenedCase, "3", nil)
+	insertTestFile(t, db, "Rapport om barnet.pdf", underScreenedCase)
+
+	jobID := insertTestJob(t, db, model.JobStatusRunning, 1, 3, "", nil)
+	result, err := runNoarkExport(db, &jobContext{db: db, id: jobID}, arkivID, "admin")
+	if err != nil {
+		t.Fatalf("noark export: %v", err)
+	}
+	var export NoarkExportResult
+	if err := json.Unmarshal([]byte(result), &export); err != nil {
+		t.Fatal(err)
+	}
+
+	running := testJournal(t, export.Directory, "loependeJournal.xml")
+	if running.Journalhode.AntallJournalposter != 4 || len(running.Journalregistrering) != 4 {
+		t.Errorf("running journal has %d entries, want 4", len(running.Journalregistrering))
+	}
+
+	offentlig := testJournal(t, export.Directory, "offentligJournal.xml")
+	if offentlig.Journalhode.AntallJournalposter != 1 || len(offentlig.Journalregistrering) != 1 {
+		t.Fatalf("public journal has %d entries, want 1", len(offentlig.Journalregistrering))
+	}
+	post := offentlig.Journalregistrering[0].Journalpost
+	if post.SystemID != noarkSystemID("file", public) || post.OffentligTittel != "Søknad om byggetillatelse" {
+		t.Errorf("public entry = %+v", post)
+	}
+	data, err := os.ReadFile(filepath.Join(export.Directory, "offentligJournal.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, hidden := range []string{"Ola Nordmann", "bekymring", "Barnevernssak", "barnet"} {
+		if strings.Contains(string(data), hidden) {
+			t.Errorf("public journal contains %q", hidden)
+		}
+	}
+}
+
+func TestNoarkExportChangeLog(t *testing.T) {
+	db := openTestDB(t)
+	t.Chdir(t.TempDir())
+	ctx := testAdminContext(t)
+	mutation := NewResolver(db).Mutation()
+
+	arkivID := insertTestNode(t, db, "Arkiv", "")
+	arkivdelID := insertTestNode(t, db, "Korrespondanse", arkivID)
+	fileID := insertTestFile(t, db, "utkast.txt", arkivdelID)
+	otherID := insertTestFile(t, db, "annet.txt", insertTestNode(t, db, "Utenfor", ""))
+
+	call := func(field string, args map[string]interface{}, resolve func(ctx context.Context) (interface{}, error)) {
+		t.Helper()
+		if _, err := callResolver(ctx, db, "Mutation", field, args, resolve); err != nil {
+			t.Fatalf("%s: %v", field, err)
+		}
+	}
+	call("renameFile", map[string]interface{}{"id": fileID, "name": "brev.txt"}, func(ctx context.Context) (interface{}, error) {
+		return mutation.RenameFile(ctx, fileID, "brev.txt")
+	})
+	metadata := []*model.MetadataInput{{Key: "mottaker", Value: "Kommunen"}}
+	call("updateMetadata", map[string]interface{}{"fileId": fileID, "metadataInput": metadata}, func(ctx context.Context) (interface{}, error) {
+		return mutation.UpdateMetadata(ctx, fileID, metadata)
+	})
+	fields := []*model.TypedFieldInput{{Name: "dokumentstatus", Value: "Dokumentet er ferdigstilt"}}
+	call("setFileType", map[string]interface{}{"fileId": fileID, "fileType": model.FileTypeDokument, "fields": fields}, func(ctx context.Context) (interface{}, error) {
+		return mutation.SetFileType(ctx, fileID, model.FileTypeDokument, fields)
+	})
+	// Ändringar av filer utanför uttrekket tas inte med
+	call("renameFile", map[string]interface{}{"id": otherID, "name": "annet2.txt"}, func(ctx context.Context) (interface{}, error) {
+		return mutation.RenameFile(ctx, otherID, "annet2.txt")
+	})
+
+	jobID := insertTestJob(t, db, model.JobStatusRunning, 1, 3, "", nil)
+	result, err := runNoarkExport(db, &jobContext{db: db, id: jobID}, arkivID, "admin")
+	if err != nil {
+		t.Fatalf("noark export: %v", err)
+	}
+	var export NoarkExportResult
+	if err := json.Unmarshal([]byte(result), &export); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(export.Directory, "endringslogg.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var logg n5Endringslogg
+	if err := xml.Unmarshal(data, &logg); err != nil {
+		t.Fatalf("decode change log: %v", err)
+	}
+
+	value := func(s *string) string {
+		if s == nil {
+			return "<nil>"
+		}
+		return *s
+	}
+	var got []string
+	for _, endring := range logg.Endring {
+		if endring.ReferanseArkivenhet != noarkSystemID("file", fileID) || endring.EndretAv != "admin" {
+			t.Errorf("change = %+v, want one of file %s by admin", endring, fileID)
+		}
+		got = append(got, endring.ReferanseMetadata+": "+value(endring.TidligereVerdi)+" -> "+value(endring.NyVerdi))
+	}
+	want := []string{
+		"tittel: utkast.txt -> brev.txt",
+		"mottaker: <nil> -> Kommunen",
+		"dokumentstatus: <nil> -> Dokumentet er ferdigstilt",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("changes:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
@@ -0,0 +1,516 @@
+package graph
+
+import (
+	"database/sql"
+	"fmt"
+	"graphql-backend/graph/model"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// =============================================
+// ========== NOARK 5 STRUKTURTYPER ==========
+// =============================================
+
+// Entitetstyper i tabellen typed_fields
+const (
+	ENTITY_KIND_NODE = "node"
+	ENTITY_KIND_FILE = "file"
+)
+
+// Värdetyper för typade fält
+const (
+	fieldKindString = "string"
+	fieldKindInt    = "int"
+	fieldKindDate   = "date"
+)
+
+// typedFieldDef beskriver ett fält som hör till en nod- eller filtyp
+type typedFieldDef struct {
+	name     string
+	required bool
+	kind     string
+	allowed  []string // Tillåtna värden om fältet är en kodlista
+}
+
+// nodeTypeFields anger vilka fält varje nodtyp har och vilka som är obligatoriska
+var nodeTypeFields = map[model.NodeType][]typedFieldDef{
+	model.NodeTypeFolder: {},
+	model.NodeTypeArkiv: {
+		{name: "arkivstatus", required: true, kind: fieldKindString, allowed: []string{"Opprettet", "Avsluttet"}},
+		{name: "arkivskaper", kind: fieldKindString},
+		{name: "beskrivelse", kind: fieldKindString},
+	},
+	model.NodeTypeArkivdel: {
+		{name: "arkivdelstatus", required: true, kind: fieldKindString, allowed: []string{"Aktiv periode", "Overlappingsperiode", "Avsluttet periode", "Uaktuelle mapper"}},
+		{name: "arkivperiodeStartDato", kind: fieldKindDate},
+		{name: "arkivperiodeSluttDato", kind: fieldKindDate},
+	},
+	model.NodeTypeKlasse: {
+		{name: "klasseID", required: true, kind: fieldKindString},
+	},
+	model.NodeTypeSaksmappe: {
+		{name: "saksaar", required: true, kind: fieldKindInt},
+		{name: "sakssekvensnummer", required: true, kind: fieldKindInt},
+		{name: "saksdato", required: true, kind: fieldKindDate},
+		{name: "administrativEnhet", required: true, kind: fieldKindString},
+		{name: "saksansvarlig", required: true, kind: fieldKindString},
+		{name: "saksstatus", required: true, kind: fieldKindString, allowed: []string{"Under behandling", "Avsluttet", "Utgår", "Opprettet av saksbehandler", "Avsluttet av saksbehandler", "Unntatt prosesstyring"}},
+		{name: "tilgangsrestriksjon", kind: fieldKindString},
+		{name: "skjermingshjemmel", kind: fieldKindString},
+	},
+}
+
+// fileTypeFields anger vilka fält varje filtyp har och vilka som är obligatoriska
+var fileTypeFields = map[model.FileType][]typedFieldDef{
+	model.FileTypeDokument: {
+		{name: "dokumenttype", kind: fieldKindString},
+		{name: "dokumentstatus", kind: fieldKindString, allowed: []string{"Dokumentet er under redigering", "Dokumentet er ferdigstilt"}},
+	},
+	model.FileTypeJournalpost: {
+		{name: "journalaar", required: true, kind: fieldKindInt},
+		{name: "journalsekvensnummer", required: true, kind: fieldKindInt},
+		{name: "journalpostnummer", required: true, kind: fieldKindInt},
+		{name: "journalposttype", required: true, kind: fieldKindString, allowed: []string{"Inngående dokument", "Utgående dokument", "Organinternt dokument for oppfølging", "Organinternt dokument uten oppfølging"}},
+		{name: "journalstatus", required: true, kind: fieldKindString, allowed: []string{"Journalført", "Ferdigstilt fra saksbehandler", "Godkjent av leder", "Ekspedert", "Arkivert", "Utgår"}},
+		{name: "journaldato", required: true, kind: fieldKindDate},
+		{name: "dokumentetsDato", kind: fieldKindDate},
+		{name: "offentligTittel", kind: fieldKindString},
+		{name: "tilgangsrestriksjon", kind: fieldKindString},
+		{name: "skjermingshjemmel", kind: fieldKindString},
+	},
+}
+
+// allowedNodeParents anger vilka föräldratyper varje nodtyp får ha.
+// Noder utan förälder får bara vara FOLDER eller ARKIV.
+var allowedNodeParents = map[model.NodeType][]model.NodeType{
+	model.NodeTypeFolder:    {model.NodeTypeFolder},
+	model.NodeTypeArkiv:     {model.NodeTypeFolder},
+	model.NodeTypeArkivdel:  {model.NodeTypeArkiv},
+	model.NodeTypeKlasse:    {model.NodeTypeArkivdel, model.NodeTypeKlasse},
+	model.NodeTypeSaksmappe: {model.NodeTypeArkivdel, model.NodeTypeKlasse, model.NodeTypeSaksmappe},
+}
+
+// allowedFileNodes anger i vilka nodtyper varje filtyp får ligga.
+// Noark tillåter inte registreringar direkt under ett arkiv.
+var allowedFileNodes = map[model.FileType][]model.NodeType{
+	model.FileTypeDokument:    {model.NodeTypeFolder, model.NodeTypeArkivdel, model.NodeTypeKlasse, model.NodeTypeSaksmappe},
+	model.FileTypeJournalpost: {model.NodeTypeArkivdel, model.NodeTypeKlasse, model.NodeTypeSaksmappe},
+}
+
+// sqlExecer är det gemensamma gränssnittet för *sql.DB och *sql.Tx vid skrivningar
+type sqlExecer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// validateTypedFields kontrollerar fälten mot typens definition och returnerar dem som en map.
+// Okända fält, saknade obligatoriska fält och felaktiga värden ger fel.
+func validateTypedFields(typeName string, defs []typedFieldDef, fields []*model.TypedFieldInput) (map[string]string, error) {
+	known := make(map[string]typedFieldDef)
+	for _, def := range defs {
+		known[def.name] = def
+	}
+
+	values := make(map[string]string)
+	for _, field := range fields {
+		if field == nil {
+			continue
+		}
+		def, ok := known[field.Name]
+		if !ok {
+			return nil, fmt.Errorf("field %q is not defined for type %s", field.Name, typeName)
+		}
+
+		value := strings.TrimSpace(field.Value)
+		if value == "" {
+			continue
+		}
+
+		switch def.kind {
+		case fieldKindInt:
+			if _, err := strconv.Atoi(value); err != nil {
+				return nil, fmt.Errorf("field %q must be an integer", field.Name)
+			}
+		case fieldKindDate:
+			if _, err := time.Parse("2006-01-02", value); err != nil {
+				return nil, fmt.Errorf("field %q must be a date (YYYY-MM-DD)", field.Name)
+			}
+		}
+
+		if len(def.allowed) > 0 && !containsString(def.allowed, value) {
+			return nil, fmt.Errorf("field %q must be one of: %s", field.Name, strings.Join(def.allowed, ", "))
+		}
+
+		values[field.Name] = value
+	}
+
+	var missing []string
+	for _, def := range defs {
+		if def.required && values[def.name] == "" {
+			missing = append(missing, def.name)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("missing mandatory fields for type %s: %s", typeName, strings.Join(missing, ", "))
+	}
+
+	return values, nil
+}
+
+// saveTypedFields ersätter alla typade fält för en nod eller fil
+func saveTypedFields(db sqlExecer, kind string, id string, values map[string]string) error {
+	if _, err := db.Exec("DELETE FROM typed_fields WHERE entity_kind = ? AND entity_id = ?", kind, id); err != nil {
+		log.Printf("Error deleting typed fields for %s %s: %v", kind, id, err)
+		return serviceErrorf(errInternal, "failed to update typed fields: %v", err)
+	}
+
+	for name, value := range values {
+		_, err := db.Exec(
+			"INSERT INTO typed_fields (entity_kind, entity_id, name, value) VALUES (?, ?, ?, ?)",
+			kind, id, name, value,
+		)
+		if err != nil {
+			log.Printf("Error saving typed field %s for %s %s: %v", name, kind, id, err)
+			return serviceErrorf(errInternal, "failed to save typed field: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// loadTypedFields hämtar alla typade fält för en nod eller fil
+func loadTypedFields(db *sql.DB, kind string, id string) (map[string]string, error) {
+	rows, err := db.Query("SELECT name, value FROM typed_fields WHERE entity_kind = ? AND entity_id = ?", kind, id)
+	if err != nil {
+		log.Printf("Error fetching typed fields for %s %s: %v", kind, id, err)
+		return nil, serviceErrorf(errInternal, "failed to fetch typed fields: %v", err)
+	}
+	defer rows.Close()
+
+	values := make(map[string]string)
+	for rows.Next() {
+		var name, value string
+		if err := rows.Scan(&name, &value); err != nil {
+			log.Printf("Error scanning typed field: %v", err)
+			return nil, serviceErrorf(errInternal, "failed to scan typed field: %v", err)
+		}
+		values[name] = value
+	}
+
+	return values, rows.Err()
+}
+
+// getNodeType hämtar typen för en nod
+func getNodeType(db *sql.DB, nodeID string) (model.NodeType, error) {
+	var nodeType string
+	err := db.QueryRow("SELECT node_type FROM nodes WHERE id = ?", nodeID).Scan(&nodeType)
+	if err == sql.ErrNoRows {
+		return "", serviceErrorf(errNotFound, "node not found")
+	} else if err != nil {
+		log.Printf("Error fetching node type for node %s: %v", nodeID, err)
+		return "", serviceErrorf(errInternal, "failed to fetch node type: %v", err)
+	}
+	return model.NodeType(nodeType), nil
+}
+
+// getFileType hämtar typen för en fil
+func getFileType(db *sql.DB, fileID string) (model.FileType, error) {
+	var fileType string
+	err := db.QueryRow("SELECT file_type FROM files WHERE id = ?", fileID).Scan(&fileType)
+	if err == sql.ErrNoRows {
+		return "", serviceErrorf(errNotFound, "file not found")
+	} else if err != nil {
+		log.Printf("Error fetching file type for file %s: %v", fileID, err)
+		return "", serviceErrorf(errInternal, "failed to fetch file type: %v", err)
+	}
+	return model.FileType(fileType), nil
+}
+
+// checkNodePlacement kontrollerar att en nod av given typ får ligga under föräldern
+func checkNodePlacement(db *sql.DB, nodeType model.NodeType, parentID *string) error {
+	if parentID == nil || *parentID == "" {
+		return validateNodePlacement(nodeType, nil)
+	}
+
+	parentType, err := getNodeType(db, *parentID)
+	if err != nil {
+		return err
+	}
+
+	return validateNodePlacement(nodeType, &parentType)
+}
+
+// validateNodePlacement kontrollerar placeringsreglerna för en nodtyp.
+// parentType är nil för noder på rotnivå.
+func validateNodePlacement(nodeType model.NodeType, parentType *model.NodeType) error {
+	if _, ok := nodeTypeFields[nodeType]; !ok {
+		return fmt.Errorf("unknown node type %s", nodeType)
+	}
+
+	if parentType == nil {
+		if nodeType != model.NodeTypeFolder && nodeType != model.NodeTypeArkiv {
+			return fmt.Errorf("a node of type %s must have a parent", nodeType)
+		}
+		return nil
+	}
+
+	if !containsNodeType(allowedNodeParents[nodeType], *parentType) {
+		return fmt.Errorf("a node of type %s cannot be placed under a node of type %s", nodeType, *parentType)
+	}
+	return nil
+}
+
+// checkFilePlacement kontrollerar att en fil av given typ får ligga i noden
+func checkFilePlacement(db *sql.DB, fileType model.FileType, nodeID string) error {
+	nodeType, err := getNodeType(db, nodeID)
+	if err != nil {
+		return err
+	}
+
+	return validateFilePlacement(fileType, nodeType)
+}
+
+// validateFilePlacement kontrollerar placeringsreglerna för en filtyp
+func validateFilePlacement(fileType model.FileType, nodeType model.NodeType) error {
+	if !containsNodeType(allowedFileNodes[fileType], nodeType) {
+		return fmt.Errorf("a file of type %s cannot be placed in a node of type %s", fileType, nodeType)
+	}
+	return nil
+}
+
+// checkNodeTypeChange kontrollerar att en nod kan byta typ utan att bryta mot
+// reglerna för dess förälder, barnnoder och filer
+func checkNodeTypeChange(db *sql.DB, nodeID string, nodeType model.NodeType) error {
+	var parentID sql.NullString
+	if err := db.QueryRow("SELECT parent_id FROM nodes WHERE id = ?", nodeID).Scan(&parentID); err != nil {
+		if err == sql.ErrNoRows {
+			return serviceErrorf(errNotFound, "node not found")
+		}
+		log.Printf("Error fetching parent for node %s: %v", nodeID, err)
+		return serviceErrorf(errInternal, "failed to fetch node: %v", err)
+	}
+
+	if err := checkNodePlacement(db, nodeType, nullStringPtr(parentID)); err != nil {
+		return err
+	}
+
+	rows, err := db.Query("SELECT node_type FROM nodes WHERE parent_id = ?", nodeID)
+	if err != nil {
+		log.Printf("Error fetching child node types: %v", err)
+		return serviceErrorf(errInternal, "failed to fetch child nodes: %v", err)
+	}
+	var childTypes []model.NodeType
+	for rows.Next() {
+		var childType string
+		if err := rows.Scan(&childType); err != nil {
+			rows.Close()
+			return serviceErrorf(errInternal, "failed to scan child node: %v", err)
+		}
+		childTypes = append(childTypes, model.NodeType(childType))
+	}
+	rows.Close()
+
+	for _, childType := range childTypes {
+		if !containsNodeType(allowedNodeParents[childType], nodeType) {
+			return fmt.Errorf("node has children of type %s which cannot be placed under a node of type %s", childType, nodeType)
+		}
+	}
+
+	rows, err = db.Query("SELECT DISTINCT file_type FROM files WHERE node_id = ?", nodeID)
+	if err != nil {
+		log.Printf("Error fetching file types: %v", err)
+		return serviceErrorf(errInternal, "failed to fetch files: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var fileType string
+		if err := rows.Scan(&fileType); err != nil {
+			return serviceErrorf(errInternal, "failed to scan file: %v", err)
+		}
+		if !containsNodeType(allowedFileNodes[model.FileType(fileType)], nodeType) {
+			return fmt.Errorf("node contains files of type %s which cannot be placed in a node of type %s", fileType, nodeType)
+		}
+	}
+
+	return rows.Err()
+}
+
+// typedFieldInputs konverterar sparade fält till indata så att de kan valideras på nytt
+func typedFieldInputs(values map[string]string) []*model.TypedFieldInput {
+	inputs := make([]*model.TypedFieldInput, 0, len(values))
+	for name, value := range values {
+		inputs = append(inputs, &model.TypedFieldInput{Name: name, Value: value})
+	}
+	return inputs
+}
+
+// typedFieldList konverterar fält till en sorterad lista för GraphQL
+func typedFieldList(values map[string]string) []*model.TypedField {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fields := make([]*model.TypedField, 0, len(names))
+	for _, name := range names {
+		fields = append(fields, &model.TypedField{Name: name, Value: values[name]})
+	}
+	return fields
+}
+
+// buildNodeArchiveEntity skapar GraphQL-representationen av en typad nod
+func buildNodeArchiveEntity(nodeID string, name string, nodeType model.NodeType, values map[string]string) model.ArchiveEntity {
+	systemID := noarkSystemID("node", nodeID)
+	fields := typedFieldList(values)
+
+	switch nodeType {
+	case model.NodeTypeArkiv:
+		return &model.Arkiv{
+			SystemID:    systemID,
+			Tittel:      name,
+			Fields:      fields,
+			Arkivstatus: values["arkivstatus"],
+			Arkivskaper: optionalValue(values, "arkivskaper"),
+			Beskrivelse: optionalValue(values, "beskrivelse"),
+		}
+	case model.NodeTypeArkivdel:
+		return &model.Arkivdel{
+			SystemID:              systemID,
+			Tittel:                name,
+			Fields:                fields,
+			Arkivdelstatus:        values["arkivdelstatus"],
+			ArkivperiodeStartDato: optionalValue(values, "arkivperiodeStartDato"),
+			ArkivperiodeSluttDato: optionalValue(values, "arkivperiodeSluttDato"),
+		}
+	case model.NodeTypeKlasse:
+		return &model.Klasse{
+			SystemID: systemID,
+			Tittel:   name,
+			Fields:   fields,
+			KlasseID: values["klasseID"],
+		}
+	case model.NodeTypeSaksmappe:
+		return &model.Saksmappe{
+			SystemID:            systemID,
+			Tittel:              name,
+			Fields:              fields,
+			MappeID:             saksmappeID(values),
+			Saksaar:             intValue(values, "saksaar"),
+			Sakssekvensnummer:   intValue(values, "sakssekvensnummer"),
+			Saksdato:            values["saksdato"],
+			AdministrativEnhet:  values["administrativEnhet"],
+			Saksansvarlig:       values["saksansvarlig"],
+			Saksstatus:          values["saksstatus"],
+			Tilgangsrestriksjon: optionalValue(values, "tilgangsrestriksjon"),
+			Skjermingshjemmel:   optionalValue(values, "skjermingshjemmel"),
+		}
+	}
+
+	return nil
+}
+
+// buildFileArchiveEntity skapar GraphQL-representationen av en typad fil
+func buildFileArchiveEntity(fileID string, name string, fileType model.FileType, values map[string]string) model.ArchiveEntity {
+	systemID := noarkSystemID("file", fileID)
+	fields := typedFieldList(values)
+
+	if fileType == model.FileTypeJournalpost {
+		return &model.Journalpost{
+			SystemID:             systemID,
+			Tittel:               name,
+			Fields:               fields,
+			Journalaar:           intValue(values, "journalaar"),
+			Journalsekvensnummer: intValue(values, "journalsekvensnummer"),
+			Journalpostnummer:    intValue(values, "journalpostnummer"),
+			Journalposttype:      values["journalposttype"],
+			Journalstatus:        values["journalstatus"],
+			Journaldato:          values["journaldato"],
+			DokumentetsDato:      optionalValue(values, "dokumentetsDato"),
+			OffentligTittel:      optionalValue(values, "offentligTittel"),
+			Tilgangsrestriksjon:  optionalValue(values, "tilgangsrestriksjon"),
+			Skjermingshjemmel:    optionalValue(values, "skjermingshjemmel"),
+		}
+	}
+
+	return &model.Dokument{
+		SystemID:       systemID,
+		Tittel:         name,
+		Fields:         fields,
+		Dokumenttype:   optionalValue(values, "dokumenttype"),
+		Dokumentstatus: optionalValue(values, "dokumentstatus"),
+	}
+}
+
+// isScreened avgör om en saksmappe eller journalpost är skjermet, dvs. har en tilgangsrestriksjon
+func isScreened(values map[string]string) bool {
+	return values["tilgangsrestriksjon"] != ""
+}
+
+// screenedNode avgör om en nod är skjermet, på noden själv eller på en förälder
+func screenedNode(db *sql.DB, nodeID string) (bool, error) {
+	visited := make(map[string]bool)
+	for currentID := nodeID; currentID != "" && !visited[currentID]; {
+		visited[currentID] = true
+
+		values, err := loadTypedFields(db, ENTITY_KIND_NODE, currentID)
+		if err != nil {
+			return false, err
+		}
+		if isScreened(values) {
+			return true, nil
+		}
+
+		var parentID sql.NullString
+		err = db.QueryRow("SELECT parent_id FROM nodes WHERE id = ?", currentID).Scan(&parentID)
+		if err == sql.ErrNoRows {
+			return false, nil
+		} else if err != nil {
+			log.Printf("Error fetching parent of node %s: %v", currentID, err)
+			return false, serviceErrorf(errInternal, "failed to fetch parent node: %v", err)
+		}
+		currentID = parentID.String
+	}
+	return false, nil
+}
+
+// saksmappeID bildar mappeID för en saksmappe som "saksår/sekvensnummer"
+func saksmappeID(values map[string]string) string {
+	return fmt.Sprintf("%s/%s", values["saksaar"], values["sakssekvensnummer"])
+}
+
+// optionalValue returnerar en pekare till fältets värde, eller nil om det saknas
+func optionalValue(values map[string]string, name string) *string {
+	value, ok := values[name]
+	if !ok || value == "" {
+		return nil
+	}
+	return &value
+}
+
+// intValue tolkar ett heltalsfält (0 om det saknas)
+func intValue(values map[string]string, name string) int {
+	n, _ := strconv.Atoi(values[name])
+	return n
+}
+
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+func containsNodeType(list []model.NodeType, value model.NodeType) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,140 @@
+package graph
+
+import (
+	"graphql-backend/graph/model"
+	"strings"
+	"testing"
+)
+
+// typedFields bygger indata för typade fält från par av namn och värde
+func typedFields(pairs ...string) []*model.TypedFieldInput {
+	var fields []*model.TypedFieldInput
+	for i := 0; i+1 < len(pairs); i += 2 {
+		fields = append(fields, &model.TypedFieldInput{Name: pairs[i], Value: pairs[i+1]})
+	}
+	return fields
+}
+
+// saksmappeFields är de obligatoriska fälten för en saksmappe
+func saksmappeFields() []*model.TypedFieldInput {
+	return typedFields(
+		"saksaar", "2026", "sakssekvensnummer", "12", "saksdato", "2026-02-01",
+		"administrativEnhet", "Kansli", "saksansvarlig", "admin", "saksstatus", "Under behandling",
+	)
+}
+
+func TestValidateTypedFields(t *testing.T) {
+	defs := nodeTypeFields[model.NodeTypeSaksmappe]
+
+	values, err := validateTypedFields("SAKSMAPPE", defs, append(saksmappeFields(), typedFields("tilgangsrestriksjon", " Unntatt offentlighet ")...))
+	if err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	if values["saksaar"] != "2026" || values["tilgangsrestriksjon"] != "Unntatt offentlighet" {
+		t.Errorf("values = %v", values)
+	}
+
+	tests := []struct {
+		name   string
+		fields []*model.TypedFieldInput
+		want   string
+	}{
+		{"unknown field", append(saksmappeFields(), typedFields("farge", "blå")...), `field "farge" is not defined for type SAKSMAPPE`},
+		{"not an integer", append(saksmappeFields(), typedFields("saksaar", "i år")...), `field "saksaar" must be an integer`},
+		{"not a date", append(saksmappeFields(), typedFields("saksdato", "01.02.2026")...), `field "saksdato" must be a date (YYYY-MM-DD)`},
+		{"not in code list", append(saksmappeFields(), typedFields("saksstatus", "Glemt")...), `field "saksstatus" must be one of: Under behandling`},
+		{"missing mandatory", typedFields("saksaar", "2026", "saksdato", "  "), "missing mandatory fields for type SAKSMAPPE: sakssekvensnummer, saksdato, administrativEnhet"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := validateTypedFields("SAKSMAPPE", defs, tt.fields); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckNodePlacement(t *testing.T) {
+	db := openTestDB(t)
+	folder, arkiv, arkivdel, klasse, saksmappe := model.NodeTypeFolder, model.NodeTypeArkiv, model.NodeTypeArkivdel, model.NodeTypeKlasse, model.NodeTypeSaksmappe
+	parents := map[model.NodeType]*string{}
+	for _, nodeType := range []model.NodeType{folder, arkiv, arkivdel, klasse, saksmappe} {
+		id := insertTestNode(t, db, string(nodeType), "")
+		mustExec(t, db, "UPDATE nodes SET node_type = ? WHERE id = ?", nodeType, id)
+		parents[nodeType] = &id
+	}
+
+	allowed := []struct {
+		node   model.NodeType
+		parent *string
+	}{
+		{folder, nil}, {arkiv, nil}, {arkiv, parents[folder]}, {arkivdel, parents[arkiv]}, {klasse, parents[arkivdel]},
+		{klasse, parents[klasse]}, {saksmappe, parents[klasse]}, {saksmappe, parents[saksmappe]},
+	}
+	for _, tt := range allowed {
+		if err := checkNodePlacement(db, tt.node, tt.parent); err != nil {
+			t.Errorf("%s under %v: %v", tt.node, tt.parent, err)
+		}
+	}
+
+	if err := checkNodePlacement(db, arkivdel, nil); err == nil || err.Error() != "a node of type ARKIVDEL must have a parent" {
+		t.Errorf("arkivdel at root: err = %v", err)
+	}
+	if err := checkNodePlacement(db, saksmappe, parents[arkiv]); err == nil || err.Error() != "a node of type SAKSMAPPE cannot be placed under a node of type ARKIV" {
+		t.Errorf("saksmappe under arkiv: err = %v", err)
+	}
+	if err := checkFilePlacement(db, model.FileTypeJournalpost, *parents[folder]); err == nil {
+		t.Error("journalpost in a folder was allowed")
+	}
+	if err := checkFilePlacement(db, model.FileTypeDokument, *parents[arkiv]); err == nil {
+		t.Error("dokument directly in an arkiv was allowed")
+	}
+}
+
+func TestTypedNodesInResolvers(t *testing.T) {
+	db := openTestDB(t)
+	mutation := NewResolver(db).Mutation()
+	ctx := testAdminContext(t)
+	nodeType := func(nodeType model.NodeType) *model.NodeType { return &nodeType }
+
+	arkiv, err := mutation.CreateNode(ctx, model.NodeInput{Name: "Arkiv", NodeType: nodeType(model.NodeTypeArkiv), Fields: typedFields("arkivstatus", "Opprettet")})
+	if err != nil {
+		t.Fatalf("create arkiv: %v", err)
+	}
+	arkivdel, err := mutation.CreateNode(ctx, model.NodeInput{Name: "Saker", ParentID: &arkiv.ID, NodeType: nodeType(model.NodeTypeArkivdel), Fields: typedFields("arkivdelstatus", "Aktiv periode")})
+	if err != nil {
+		t.Fatalf("create arkivdel: %v", err)
+	}
+	sak, err := mutation.CreateNode(ctx, model.NodeInput{Name: "Byggesak", ParentID: &arkivdel.ID, NodeType: nodeType(model.NodeTypeSaksmappe), Fields: saksmappeFields()})
+	if err != nil {
+		t.Fatalf("create saksmappe: %v", err)
+	}
+
+	if _, err := mutation.CreateNode(ctx, model.NodeInput{Name: "Fel", ParentID: &arkiv.ID, NodeType: nodeType(model.NodeTypeSaksmappe), Fields: saksmappeFields()}); err == nil {
+		t.Error("created a saksmappe directly under an arkiv")
+	}
+	if _, err := mutation.CreateNode(ctx, model.NodeInput{Name: "Tom", ParentID: &arkivdel.ID, NodeType: nodeType(model.NodeTypeSaksmappe)}); err == nil || !strings.Contains(err.Error(), "missing mandatory fields") {
+		t.Errorf("create saksmappe without fields: err = %v", err)
+	}
+	if _, err := mutation.MoveNode(ctx, sak.ID, arkiv.ID); err == nil {
+		t.Error("moved a saksmappe directly under an arkiv")
+	}
+
+	entity, err := NewResolver(db).Node().ArchiveEntity(ctx, sak)
+	if err != nil {
+		t.Fatalf("archive entity: %v", err)
+	}
+	saksmappe, ok := entity.(*model.Saksmappe)
+	if !ok || saksmappe.MappeID != "2026/12" || saksmappe.Saksstatus != "Under behandling" || saksmappe.SystemID != noarkSystemID("node", sak.ID) {
+		t.Errorf("archive entity = %+v", entity)
+	}
+
+	// En arkivdel med en saksmappe under sig kan inte bli en klasse som saknar klasseID,
+	// och inte heller ett arkiv eftersom den ligger under ett arkiv
+	if _, err := mutation.SetNodeType(ctx, arkivdel.ID, model.NodeTypeKlasse, nil); err == nil {
+		t.Error("changed type without the mandatory klasseID")
+	}
+	if _, err := mutation.SetNodeType(ctx, arkivdel.ID, model.NodeTypeArkiv, nil); err == nil {
+		t.Error("changed an arkivdel under an arkiv into an arkiv")
+	}
+}
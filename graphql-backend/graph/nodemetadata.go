@@ -0,0 +1,121 @@
+package graph
+
+import (
+	"database/sql"
+	"fmt"
+	"graphql-backend/graph/model"
+	"log"
+)
+
+// =============================================
+// ========== METADATA PÅ NODER ==============
+// =============================================
+
+// nodeMetadataEntriesFromInput gör om GraphQL-indata till nyckel/värde-par för en nod, med arvsflaggan
+func nodeMetadataEntriesFromInput(inputs []*model.MetadataInput) []metadataEntry {
+	entries := []metadataEntry{}
+	for _, input := range inputs {
+		if input != nil {
+			entry := metadataEntry{Key: input.Key, Value: input.Value}
+			if input.Type != nil {
+				entry.Type = *input.Type
+			}
+			entry.Inheritable = input.Inheritable != nil && *input.Inheritable
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// insertNodeMetadata sparar ett metadatavärde på en nod. Värdet ska redan vara validerat.
+func insertNodeMetadata(db sqlExecer, nodeID string, entry metadataEntry) error {
+	valueType := metadataValueTypeOrDefault(entry.Type)
+	typed, err := parseMetadataValue(valueType, entry.Value)
+	if err != nil {
+		return fmt.Errorf("metadata %s %v", entry.Key, err)
+	}
+
+	_, err = db.Exec(
+		"INSERT INTO node_metadata (node_id, key, value, value_type, value_number, value_time, inheritable) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		nodeID, entry.Key, typed.Value, valueType, typed.Number, typed.Time, entry.Inheritable,
+	)
+	if err != nil {
+		log.Printf("Error saving metadata of node %s: %v", nodeID, err)
+		return serviceErrorf(errInternal, "failed to save node metadata: %v", err)
+	}
+	return nil
+}
+
+// loadNodeMetadata hämtar all metadata för en nod
+func loadNodeMetadata(db sqlQueryer, nodeID string) ([]*model.Metadata, error) {
+	rows, err := db.Query("SELECT key, value, value_type, inheritable FROM node_metadata WHERE node_id = ? ORDER BY id ASC", nodeID)
+	if err != nil {
+		log.Printf("Error fetching metadata for node ID %s: %v", nodeID, err)
+		return nil, serviceErrorf(errInternal, "failed to fetch node metadata: %v", err)
+	}
+	defer rows.Close()
+
+	metadata := []*model.Metadata{}
+	for rows.Next() {
+		var meta model.Metadata
+		var valueType string
+		if err := rows.Scan(&meta.Key, &meta.Value, &valueType, &meta.Inheritable); err != nil {
+			log.Printf("Error scanning node metadata row: %v", err)
+			return nil, serviceErrorf(errInternal, "failed to scan node metadata row: %v", err)
+		}
+		meta.Type = metadataValueTypeOrDefault(model.MetadataValueType(valueType))
+		metadata = append(metadata, &meta)
+	}
+	return metadata, rows.Err()
+}
+
+// inheritedMetadataDefaults hämtar de ärvbara värden som filer i en nod får som standard.
+// Närmaste nod uppåt i trädet som har ärvbara värden för en nyckel avgör alla värden för den nyckeln.
+func inheritedMetadataDefaults(db sqlQueryer, nodeID string) ([]metadataEntry, error) {
+	var defaults []metadataEntry
+	decided := make(map[string]bool)
+	visited := make(map[string]bool)
+	for currentID := nodeID; currentID != "" && !visited[currentID]; {
+		visited[currentID] = true
+
+		metadata, err := loadNodeMetadata(db, currentID)
+		if err != nil {
+			return nil, err
+		}
+		found := make(map[string]bool)
+		for _, meta := range metadata {
+			if meta.Inheritable && !decided[meta.Key] {
+				defaults = append(defaults, metadataEntry{Key: meta.Key, Value: meta.Value, Type: meta.Type})
+				found[meta.Key] = true
+			}
+		}
+		for key := range found {
+			decided[key] = true
+		}
+
+		var parentID sql.NullString
+		err = db.QueryRow("SELECT parent_id FROM nodes WHERE id = ?", currentID).Scan(&parentID)
+		if err == sql.ErrNoRows {
+			break
+		} else if err != nil {
+			log.Printf("Error fetching parent of node %s: %v", currentID, err)
+			return nil, serviceErrorf(errInternal, "failed to fetch parent node: %v", err)
+		}
+		currentID = parentID.String
+	}
+	return defaults, nil
+}
+
+// withInheritedMetadata lägger till standardvärden för nycklar som inte angetts vid uppladdningen
+func withInheritedMetadata(entries []metadataEntry, defaults []metadataEntry) []metadataEntry {
+	given := make(map[string]bool)
+	for _, entry := range entries {
+		given[entry.Key] = true
+	}
+	for _, entry := range defaults {
+		if !given[entry.Key] {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
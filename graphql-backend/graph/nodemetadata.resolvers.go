@@ -0,0 +1,211 @@
+package graph
+
+// This file will be automatically regenerated based on the schema, any resolver implementations
+// will be copied through when generating and any unknown code will be moved to the end.
+// Code generated by github.com/99designs/gqlgen version v0.17.69
+
+import (
+	"context"
+	"fmt"
+	"graphql-backend/graph/model"
+	"log"
+	"strings"
+)
+
+// UpdateNodeMetadata is the resolver for the updateNodeMetadata field.
+func (r *mutationResolver) UpdateNodeMetadata(ctx context.Context, nodeID string, metadataInput []*model.MetadataInput) (*model.Node, error) {
+	logAction(fmt.Sprintf("Attempting to update metadata for node with ID: %s", nodeID))
+
+	if r.DB == nil {
+		log.Printf("Database connection is nil")
+		return nil, serviceErrorf(errInternal, "internal server error: database connection is not initialized")
+	}
+
+	hasPermission, err := checkPermission(ctx, r.DB, nodeID, PERM_MODIFY)
+	if err != nil {
+		return nil, err
+	}
+	if !hasPermission {
+		return nil, serviceErrorf(errPermissionDenied, "permission denied: cannot modify this node")
+	}
+
+	if err := checkNodeNotHeld(r.DB, nodeID, "update node metadata"); err != nil {
+		return nil, err
+	}
+
+	// Nodens metadata kontrolleras mot sina typer; metadatascheman gäller bara filer
+	metadataEntries, err := checkMetadataValid(r.DB, "", nodeMetadataEntriesFromInput(metadataInput))
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := r.DB.Begin()
+	if err != nil {
+		log.Printf("Error starting transaction: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to start transaction: %v", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// Den nya metadatan ersätter den gamla
+	_, err = tx.Exec("DELETE FROM node_metadata WHERE node_id = ?", nodeID)
+	if err != nil {
+		log.Printf("Error deleting existing node metadata: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to delete existing metadata: %v", err)
+	}
+
+	for _, entry := range metadataEntries {
+		if err = insertNodeMetadata(tx, nodeID, entry); err != nil {
+			return nil, err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		log.Printf("Error committing transaction: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to commit transaction: %v", err)
+	}
+
+	log.Printf("Successfully updated metadata for node with ID: %s", nodeID)
+	return getNodeWithPermissions(ctx, r.DB, nodeID)
+}
+
+// DeleteNodeMetadata is the resolver for the deleteNodeMetadata field.
+func (r *mutationResolver) DeleteNodeMetadata(ctx context.Context, nodeID string, keys []string) (*model.Node, error) {
+	logAction(fmt.Sprintf("Attempting to delete metadata for node with ID: %s", nodeID))
+
+	if r.DB == nil {
+		log.Printf("Database connection is nil")
+		return nil, serviceErrorf(errInternal, "internal server error: database connection is not initialized")
+	}
+
+	hasPermission, err := checkPermission(ctx, r.DB, nodeID, PERM_MODIFY)
+	if err != nil {
+		return nil, err
+	}
+	if !hasPermission {
+		return nil, serviceErrorf(errPermissionDenied, "permission denied: cannot modify this node")
+	}
+
+	if err := checkNodeNotHeld(r.DB, nodeID, "delete node metadata"); err != nil {
+		return nil, err
+	}
+
+	tx, err := r.DB.Begin()
+	if err != nil {
+		log.Printf("Error starting transaction: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to start transaction: %v", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	for _, key := range keys {
+		_, err = tx.Exec("DELETE FROM node_metadata WHERE node_id = ? AND key = ?", nodeID, key)
+		if err != nil {
+			log.Printf("Error deleting node metadata with key %s: %v", key, err)
+			return nil, serviceErrorf(errInternal, "failed to delete metadata: %v", err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		log.Printf("Error committing transaction: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to commit transaction: %v", err)
+	}
+
+	log.Printf("Successfully deleted metadata for node with ID: %s", nodeID)
+	return getNodeWithPermissions(ctx, r.DB, nodeID)
+}
+
+// Metadata is the resolver for the metadata field.
+func (r *nodeResolver) Metadata(ctx context.Context, obj *model.Node) ([]*model.Metadata, error) {
+	if r.DB == nil {
+		return nil, serviceErrorf(errInternal, "internal server error: database connection is not initialized")
+	}
+	return loadNodeMetadata(r.DB, obj.ID)
+}
+
+// SearchNodes is the resolver for the searchNodes field.
+func (r *queryResolver) SearchNodes(ctx context.Context, nodeID *string, filters []*model.MetadataFilter, sort *model.MetadataSort, limit *int, offset *int) ([]*model.Node, error) {
+	logAction("Searching nodes by metadata")
+
+	if r.DB == nil {
+		log.Printf("Database connection is nil")
+		return nil, serviceErrorf(errInternal, "internal server error: database connection is not initialized")
+	}
+
+	if _, err := getUserIDFromContext(ctx); err != nil {
+		return nil, err
+	}
+
+	conditions := []string{"1 = 1"}
+	args := []interface{}{}
+
+	if nodeID != nil {
+		nodeIDs, err := subtreeNodeIDs(r.DB, *nodeID)
+		if err != nil {
+			return nil, err
+		}
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(nodeIDs)), ", ")
+		conditions = append(conditions, "id IN ("+placeholders+")")
+		for _, id := range nodeIDs {
+			args = append(args, id)
+		}
+	}
+
+	for _, filter := range filters {
+		condition, filterArgs, err := metadataFilterCondition(filter, "node_metadata", "node_id", "nodes.id")
+		if err != nil {
+			return nil, err
+		}
+		conditions = append(conditions, condition)
+		args = append(args, filterArgs...)
+	}
+
+	rows, err := r.DB.Query("SELECT id FROM nodes WHERE "+strings.Join(conditions, " AND ")+" ORDER BY name ASC", args...)
+	if err != nil {
+		log.Printf("Error searching nodes: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to search nodes: %v", err)
+	}
+	ids, err := scanIDs(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	// Endast noder som användaren får se tas med
+	visible := []string{}
+	for _, id := range ids {
+		allowed, err := checkPermission(ctx, r.DB, id, PERM_VIEW)
+		if err != nil {
+			return nil, err
+		}
+		if allowed {
+			visible = append(visible, id)
+		}
+	}
+
+	if sort != nil {
+		metadata := make(map[string][]*model.Metadata, len(visible))
+		for _, id := range visible {
+			if metadata[id], err = loadNodeMetadata(r.DB, id); err != nil {
+				return nil, err
+			}
+		}
+		sortByMetadata(visible, func(id string) []*model.Metadata { return metadata[id] }, sort.Key, sort.Descending != nil && *sort.Descending)
+	}
+
+	start, end := pageBounds(len(visible), limit, offset)
+	nodes := make([]*model.Node, 0, end-start)
+	for _, id := range visible[start:end] {
+		node, err := getNodeWithPermissions(ctx, r.DB, id)
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes, nil
+}
@@ -0,0 +1,142 @@
+package graph
+
+import (
+	"encoding/base64"
+	"graphql-backend/graph/model"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// inheritableMetadata bygger metadataindata för en nod som filer under noden får som standard
+func inheritableMetadata(key string, value string) *model.MetadataInput {
+	return &model.MetadataInput{Key: key, Value: value, Inheritable: boolPtr(true)}
+}
+
+func TestNodeMetadata(t *testing.T) {
+	db := openTestDB(t)
+	ctx := testAdminContext(t)
+	resolver := NewResolver(db)
+	mutation := resolver.Mutation()
+
+	sak := createTestNode(t, db, "Byggesak 2026/14", nil)
+	date := model.MetadataValueTypeDate
+	_, err := mutation.UpdateNodeMetadata(ctx, sak.ID, []*model.MetadataInput{
+		{Key: "diarienummer", Value: "2026/14"},
+		{Key: "status", Value: "Öppen"},
+		{Key: "inkom", Value: "2026-02-01", Type: &date},
+	})
+	if err != nil {
+		t.Fatalf("update node metadata: %v", err)
+	}
+	// Ny metadata ersätter den gamla, precis som för filer
+	updated, err := mutation.UpdateNodeMetadata(ctx, sak.ID, []*model.MetadataInput{
+		{Key: "diarienummer", Value: "2026/14"},
+		{Key: "inkom", Value: "2026-02-01", Type: &date},
+	})
+	if err != nil {
+		t.Fatalf("replace node metadata: %v", err)
+	}
+	metadata, err := resolver.Node().Metadata(ctx, updated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := metadataSummary(metadata); got != "diarienummer:STRING=2026/14 inkom:DATE=2026-02-01" {
+		t.Errorf("node metadata = %s", got)
+	}
+
+	if _, err := mutation.UpdateNodeMetadata(ctx, sak.ID, []*model.MetadataInput{{Key: "inkom", Value: "i fjol", Type: &date}}); err == nil {
+		t.Error("saved an invalid date on a node")
+	}
+	bobID := insertTestUser(t, db, "bob")
+	if _, err := mutation.UpdateNodeMetadata(testUserContext(t, bobID, "bob"), sak.ID, nil); err == nil || !strings.HasPrefix(err.Error(), "permission denied") {
+		t.Errorf("update as user without modify permission: err = %v", err)
+	}
+
+	if _, err := mutation.DeleteNodeMetadata(ctx, sak.ID, []string{"inkom", "saknas"}); err != nil {
+		t.Fatalf("delete node metadata: %v", err)
+	}
+	if metadata, _ := loadNodeMetadata(db, sak.ID); metadataSummary(metadata) != "diarienummer:STRING=2026/14" {
+		t.Errorf("node metadata after delete = %s", metadataSummary(metadata))
+	}
+
+	other := createTestNode(t, db, "Byggesak 2026/15", nil)
+	if _, err := mutation.UpdateNodeMetadata(ctx, other.ID, []*model.MetadataInput{{Key: "diarienummer", Value: "2026/15"}}); err != nil {
+		t.Fatal(err)
+	}
+	nodes, err := resolver.Query().SearchNodes(ctx, nil, []*model.MetadataFilter{{Key: "diarienummer", Equals: strPtr("2026/15")}}, nil, nil, nil)
+	if err != nil || len(nodes) != 1 || nodes[0].ID != other.ID {
+		t.Errorf("search nodes = %v, %v", nodes, err)
+	}
+}
+
+func TestInheritedFileMetadata(t *testing.T) {
+	db := openTestDB(t)
+	ctx := testAdminContext(t)
+	mutation := NewResolver(db).Mutation()
+
+	arkiv := createTestNode(t, db, "Arkiv", nil)
+	sak := createTestNode(t, db, "Byggesak", &arkiv.ID)
+	if _, err := mutation.UpdateNodeMetadata(ctx, arkiv.ID, []*model.MetadataInput{
+		inheritableMetadata("enhet", "Kansli"),
+		inheritableMetadata("amnesord", "arkiv"),
+		{Key: "intern", Value: "ärvs inte"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	// Närmaste nod med ärvbara värden för en nyckel avgör alla värden för nyckeln
+	if _, err := mutation.UpdateNodeMetadata(ctx, sak.ID, []*model.MetadataInput{
+		inheritableMetadata("amnesord", "bygg"),
+		inheritableMetadata("amnesord", "tillstånd"),
+		inheritableMetadata("diarienummer", "2026/14"),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	defaults, err := inheritedMetadataDefaults(db, sak.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, entry := range defaults {
+		got = append(got, entry.Key+"="+entry.Value)
+	}
+	if strings.Join(got, " ") != "amnesord=bygg amnesord=tillstånd diarienummer=2026/14 enhet=Kansli" {
+		t.Errorf("defaults = %v", got)
+	}
+
+	// Värden som anges vid uppladdningen går före standardvärdena
+	file, err := mutation.SaveFile(ctx, model.FileInput{
+		Name: "ritning.txt", Size: 1, ContentType: "text/plain", FileData: base64.StdEncoding.EncodeToString([]byte("x")), NodeID: &sak.ID,
+		Metadata: []*model.MetadataInput{{Key: "enhet", Value: "Byggavdelningen"}},
+	})
+	if err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	if got := metadataSummary(file.Metadata); got != "enhet:STRING=Byggavdelningen amnesord:STRING=bygg amnesord:STRING=tillstånd diarienummer:STRING=2026/14" {
+		t.Errorf("file metadata = %s", got)
+	}
+	for _, meta := range file.Metadata {
+		if meta.Inheritable {
+			t.Errorf("file metadata %s is inheritable", meta.Key)
+		}
+	}
+
+	// Metadatan och arvsflaggan följer med noderna i ett BagIt-paket
+	bagDir := filepath.Join(t.TempDir(), "bag")
+	if _, err := ExportBag(db, arkiv.ID, bagDir, "1"); err != nil {
+		t.Fatalf("export bag: %v", err)
+	}
+	target := createTestNode(t, db, "Mottaget", nil)
+	result, err := ImportBag(db, bagDir, target.ID, "1")
+	if err != nil {
+		t.Fatalf("import bag: %v", err)
+	}
+	imported, err := loadNodeMetadata(db, result.NodeID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := metadataSummary(imported); got != "enhet:STRING=Kansli amnesord:STRING=arkiv intern:STRING=ärvs inte" || !imported[0].Inheritable || imported[2].Inheritable {
+		t.Errorf("imported node metadata = %s", got)
+	}
+}
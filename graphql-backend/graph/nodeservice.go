@@ -0,0 +1,590 @@
+package graph
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"graphql-backend/graph/model"
+	"log"
+	"time"
+)
+
+// =============================================
+// ========== NODTJÄNST ======================
+// =============================================
+
+// nodeService implementerar NodeService mot databasen
+type nodeService struct {
+	db *sql.DB
+}
+
+// Get hämtar en nod som användaren får se
+func (s *nodeService) Get(ctx context.Context, id string) (*model.Node, error) {
+	logAction(fmt.Sprintf("Fetching node with ID: %s", id))
+
+	if s.db == nil {
+		return nil, serviceErrorf(errInternal, "database connection is not initialized")
+	}
+
+	// Use the helper function that also checks permissions
+	return getNodeWithPermissions(ctx, s.db, id)
+}
+
+// Roots hämtar noderna utan förälder
+func (s *nodeService) Roots(ctx context.Context) ([]*model.Node, error) {
+	logAction("Fetching root nodes")
+
+	if s.db == nil {
+		return nil, serviceErrorf(errInternal, "database connection is not initialized")
+	}
+
+	rows, err := s.db.Query(`
+		SELECT id, name, parent_id, created_at, updated_at 
+		FROM nodes 
+		WHERE parent_id IS NULL
+		ORDER BY name ASC
+	`)
+	if err != nil {
+		log.Printf("Error fetching root nodes: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to fetch root nodes: %v", err)
+	}
+	defer rows.Close()
+
+	return scanNodeRows(rows)
+}
+
+// Children hämtar en nods barn
+func (s *nodeService) Children(ctx context.Context, parentID string) ([]*model.Node, error) {
+	logAction(fmt.Sprintf("Fetching child nodes for parent ID: %s", parentID))
+
+	if s.db == nil {
+		return nil, serviceErrorf(errInternal, "database connection is not initialized")
+	}
+
+	// Kontrollera att föräldern finns
+	var exists bool
+	err := s.db.QueryRow("SELECT EXISTS(SELECT 1 FROM nodes WHERE id = ?)", parentID).Scan(&exists)
+	if err != nil {
+		log.Printf("Error checking if parent node exists: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to check if parent node exists: %v", err)
+	}
+
+	if !exists {
+		log.Printf("Parent node with ID %s does not exist", parentID)
+		return nil, serviceErrorf(errNotFound, "parent node not found")
+	}
+
+	rows, err := s.db.Query(`
+		SELECT id, name, parent_id, created_at, updated_at 
+		FROM nodes 
+		WHERE parent_id = ?
+		ORDER BY name ASC
+	`, parentID)
+	if err != nil {
+		log.Printf("Error fetching child nodes: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to fetch child nodes: %v", err)
+	}
+	defer rows.Close()
+
+	return scanNodeRows(rows)
+}
+
+// Create skapar en nod
+func (s *nodeService) Create(ctx context.Context, input model.NodeInput) (*model.Node, error) {
+	logAction(fmt.Sprintf("Attempting to create node with name: %s", input.Name))
+
+	if s.db == nil {
+		log.Printf("Database connection is nil")
+		return nil, serviceErrorf(errInternal, "internal server error: database connection is not initialized")
+	}
+
+	// Validera parent_id om det är angivet
+	if input.ParentID != nil {
+		var exists bool
+		err := s.db.QueryRow("SELECT EXISTS(SELECT 1 FROM nodes WHERE id = ?)", *input.ParentID).Scan(&exists)
+		if err != nil {
+			log.Printf("Error checking if parent node exists: %v", err)
+			return nil, serviceErrorf(errInternal, "failed to check if parent node exists: %v", err)
+		}
+
+		if !exists {
+			log.Printf("Parent node with ID %s does not exist", *input.ParentID)
+			return nil, serviceErrorf(errNotFound, "parent node not found")
+		}
+	}
+
+	// Kontrollera Noark 5-typ, obligatoriska fält och placering
+	nodeType := model.NodeTypeFolder
+	if input.NodeType != nil {
+		nodeType = *input.NodeType
+	}
+	typedValues, err := validateTypedFields(string(nodeType), nodeTypeFields[nodeType], input.Fields)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkNodeTypeFieldVocabularies(s.db, nodeType, typedValues); err != nil {
+		return nil, err
+	}
+	if err := checkNodePlacement(s.db, nodeType, input.ParentID); err != nil {
+		return nil, err
+	}
+
+	// Skapa den nya noden
+	now := time.Now().Format(time.RFC3339)
+	var result sql.Result
+
+	if input.ParentID != nil {
+		result, err = s.db.Exec(
+			"INSERT INTO nodes (name, parent_id, node_type, created_at, updated_at) VALUES (?, ?, ?, ?, ?)",
+			input.Name, input.ParentID, nodeType, now, now,
+		)
+	} else {
+		result, err = s.db.Exec(
+			"INSERT INTO nodes (name, node_type, created_at, updated_at) VALUES (?, ?, ?, ?)",
+			input.Name, nodeType, now, now,
+		)
+	}
+
+	if err != nil {
+		log.Printf("Error creating node: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to create node: %v", err)
+	}
+
+	nodeID, err := result.LastInsertId()
+	if err != nil {
+		log.Printf("Error retrieving last insert ID: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to retrieve node ID: %v", err)
+	}
+
+	log.Printf("Node created successfully with ID: %d", nodeID)
+
+	if err := saveTypedFields(s.db, ENTITY_KIND_NODE, fmt.Sprintf("%d", nodeID), typedValues); err != nil {
+		return nil, err
+	}
+
+	// Returnera den skapade noden
+	node := &model.Node{
+		ID:        fmt.Sprintf("%d", nodeID),
+		Name:      input.Name,
+		CreatedAt: now,
+		UpdatedAt: now,
+		NodeType:  nodeType,
+	}
+
+	if input.ParentID != nil {
+		node.ParentID = input.ParentID
+	}
+
+	return node, nil
+}
+
+// Update ändrar namn, förälder, ägare eller behörigheter på en nod
+func (s *nodeService) Update(ctx context.Context, id string, input model.NodeUpdateInput) (*model.Node, error) {
+	logAction(fmt.Sprintf("Attempting to update node with ID: %s", id))
+
+	if s.db == nil {
+		log.Printf("Database connection is nil")
+		return nil, serviceErrorf(errInternal, "internal server error: database connection is not initialized")
+	}
+
+	// Kontrollera att noden finns
+	var exists bool
+	err := s.db.QueryRow("SELECT EXISTS(SELECT 1 FROM nodes WHERE id = ?)", id).Scan(&exists)
+	if err != nil {
+		log.Printf("Error checking if node exists: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to check if node exists: %v", err)
+	}
+
+	if !exists {
+		log.Printf("Node with ID %s does not exist", id)
+		return nil, serviceErrorf(errNotFound, "node not found")
+	}
+
+	// Noder med tilbakehold på sig själva, föräldrar eller undernoder får inte ändras eller flyttas
+	if err := checkSubtreeNotHeld(s.db, id, "update node"); err != nil {
+		return nil, err
+	}
+
+	// Om parentId är uppdaterat, kontrollera att den nya föräldern existerar
+	if input.ParentID != nil {
+		// Kontrollera om den nya föräldern finns
+		var parentExists bool
+		err := s.db.QueryRow("SELECT EXISTS(SELECT 1 FROM nodes WHERE id = ?)", *input.ParentID).Scan(&parentExists)
+		if err != nil {
+			log.Printf("Error checking if parent node exists: %v", err)
+			return nil, serviceErrorf(errInternal, "failed to check if parent node exists: %v", err)
+		}
+
+		if !parentExists {
+			log.Printf("Parent node with ID %s does not exist", *input.ParentID)
+			return nil, serviceErrorf(errNotFound, "parent node not found")
+		}
+
+		// Kontrollera att den nya föräldern inte är en av barnens barn (cykeldetektion)
+		var isCycle bool
+		err = s.detectCycle(id, *input.ParentID, &isCycle)
+		if err != nil {
+			log.Printf("Error detecting cycle: %v", err)
+			return nil, serviceErrorf(errInternal, "failed to validate hierarchy: %v", err)
+		}
+
+		if isCycle {
+			log.Printf("Cannot update node: would create a cycle in the hierarchy")
+			return nil, serviceErrorf(errConflict, "cannot update node: would create a cycle in the hierarchy")
+		}
+	}
+
+	// Kontrollera att nodtypen får ligga under den nya föräldern
+	nodeType, err := getNodeType(s.db, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkNodePlacement(s.db, nodeType, input.ParentID); err != nil {
+		return nil, err
+	}
+
+	// Kontrollera att filerna under noden godtas under den nya föräldern
+	if input.ParentID != nil {
+		if err := checkSubtreeFormatsAccepted(s.db, id, *input.ParentID); err != nil {
+			return nil, err
+		}
+	}
+
+	// Bygga update query dynamiskt baserat på vilka fält som ska uppdateras
+	query := "UPDATE nodes SET updated_at = datetime('now')"
+	args := []interface{}{}
+
+	if input.Name != nil {
+		query += ", name = ?"
+		args = append(args, *input.Name)
+	}
+
+	if input.ParentID != nil {
+		query += ", parent_id = ?"
+		args = append(args, *input.ParentID)
+	} else if input.ParentID == nil {
+		// Om parent_id explicit sätts till null
+		query += ", parent_id = NULL"
+	}
+
+	query += " WHERE id = ?"
+	args = append(args, id)
+
+	// Utför uppdateringen
+	_, err = s.db.Exec(query, args...)
+	if err != nil {
+		log.Printf("Error updating node: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to update node: %v", err)
+	}
+
+	log.Printf("Node with ID %s updated successfully", id)
+
+	// Hämta den uppdaterade noden från databasen
+	return getNodeById(ctx, s.db, id)
+}
+
+// Move flyttar en nod till en annan förälder. Kräver ändringsbehörighet på båda.
+func (s *nodeService) Move(ctx context.Context, id string, newParentID string) (*model.Node, error) {
+	logAction(fmt.Sprintf("Moving node %s to new parent %s", id, newParentID))
+
+	// Check if user has permission to modify this node
+	hasPermission, err := checkPermission(ctx, s.db, id, PERM_MODIFY)
+	if err != nil {
+		return nil, err
+	}
+
+	if !hasPermission {
+		return nil, serviceErrorf(errPermissionDenied, "permission denied: cannot modify this node")
+	}
+
+	// Check if user has permission to modify the new parent node
+	hasPermission, err = checkPermission(ctx, s.db, newParentID, PERM_MODIFY)
+	if err != nil {
+		return nil, err
+	}
+
+	if !hasPermission {
+		return nil, serviceErrorf(errPermissionDenied, "permission denied: cannot modify the target node")
+	}
+
+	// Check if the new parent node exists
+	var parentExists bool
+	err = s.db.QueryRow("SELECT EXISTS(SELECT 1 FROM nodes WHERE id = ?)", newParentID).Scan(&parentExists)
+	if err != nil {
+		log.Printf("Error checking if parent node exists: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to check if parent node exists: %v", err)
+	}
+
+	if !parentExists {
+		log.Printf("Parent node with ID %s does not exist", newParentID)
+		return nil, serviceErrorf(errNotFound, "parent node not found")
+	}
+
+	// Check for cycles
+	var isCycle bool
+	err = s.detectCycle(id, newParentID, &isCycle)
+	if err != nil {
+		log.Printf("Error detecting cycle: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to validate hierarchy: %v", err)
+	}
+
+	if isCycle {
+		log.Printf("Cannot update node: would create a cycle in the hierarchy")
+		return nil, serviceErrorf(errConflict, "cannot update node: would create a cycle in the hierarchy")
+	}
+
+	// Held content cannot be moved, neither the node itself nor anything below it
+	if err := checkSubtreeNotHeld(s.db, id, "move node"); err != nil {
+		return nil, err
+	}
+
+	// Check that the node type is allowed under the new parent
+	nodeType, err := getNodeType(s.db, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkNodePlacement(s.db, nodeType, &newParentID); err != nil {
+		return nil, err
+	}
+
+	// Check that the files below the node are accepted under the new parent
+	if err := checkSubtreeFormatsAccepted(s.db, id, newParentID); err != nil {
+		return nil, err
+	}
+
+	// Update the node's parent
+	_, err = s.db.Exec("UPDATE nodes SET parent_id = ? WHERE id = ?", newParentID, id)
+	if err != nil {
+		log.Printf("Error updating node parent: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to update node parent: %v", err)
+	}
+
+	// Get the updated node
+	return getNodeWithPermissions(ctx, s.db, id)
+}
+
+// Delete tar bort en nod utan barn
+func (s *nodeService) Delete(ctx context.Context, id string) (bool, error) {
+	logAction(fmt.Sprintf("Attempting to delete node with ID: %s", id))
+
+	if s.db == nil {
+		log.Printf("Database connection is nil")
+		return false, serviceErrorf(errInternal, "internal server error: database connection is not initialized")
+	}
+
+	// Kontrollera att noden finns
+	var exists bool
+	err := s.db.QueryRow("SELECT EXISTS(SELECT 1 FROM nodes WHERE id = ?)", id).Scan(&exists)
+	if err != nil {
+		log.Printf("Error checking if node exists: %v", err)
+		return false, serviceErrorf(errInternal, "failed to check if node exists: %v", err)
+	}
+
+	if !exists {
+		log.Printf("Node with ID %s does not exist", id)
+		return false, serviceErrorf(errNotFound, "node not found")
+	}
+
+	// Noder som omfattas av ett tilbakehold får inte tas bort
+	if err := checkNodeNotHeld(s.db, id, "delete node"); err != nil {
+		return false, err
+	}
+
+	// Kontrollera om noden har barn
+	var hasChildren bool
+	err = s.db.QueryRow("SELECT EXISTS(SELECT 1 FROM nodes WHERE parent_id = ?)", id).Scan(&hasChildren)
+	if err != nil {
+		log.Printf("Error checking if node has children: %v", err)
+		return false, serviceErrorf(errInternal, "failed to check if node has children: %v", err)
+	}
+
+	if hasChildren {
+		log.Printf("Cannot delete node with ID %s: has children nodes", id)
+		return false, serviceErrorf(errConflict, "cannot delete node: has children nodes")
+	}
+
+	// Ta bort noden
+	result, err := s.db.Exec("DELETE FROM nodes WHERE id = ?", id)
+	if err != nil {
+		log.Printf("Error deleting node with ID %s: %v", id, err)
+		return false, serviceErrorf(errInternal, "failed to delete node: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		log.Printf("Error getting rows affected: %v", err)
+		return false, fmt.Errorf("error confirming deletion: %v", err)
+	}
+
+	if rowsAffected == 0 {
+		log.Printf("No rows deleted for node with ID %s", id)
+		return false, nil
+	}
+
+	// Ta bort nodens Noark 5-fält
+	if err := saveTypedFields(s.db, ENTITY_KIND_NODE, id, nil); err != nil {
+		return false, err
+	}
+
+	// Ta bort nodens lista över godkända format
+	if _, err := s.db.Exec("DELETE FROM node_accepted_formats WHERE node_id = ?", id); err != nil {
+		log.Printf("Error deleting accepted formats for node %s: %v", id, err)
+		return false, serviceErrorf(errInternal, "failed to delete accepted formats: %v", err)
+	}
+
+	// Ta bort nodens metadata
+	if _, err := s.db.Exec("DELETE FROM node_metadata WHERE node_id = ?", id); err != nil {
+		log.Printf("Error deleting metadata for node %s: %v", id, err)
+		return false, serviceErrorf(errInternal, "failed to delete node metadata: %v", err)
+	}
+
+	log.Printf("Node with ID %s deleted successfully", id)
+	return true, nil
+}
+
+// SetPermissions sätter behörighetsbitarna på en nod
+func (s *nodeService) SetPermissions(ctx context.Context, nodeID string, permissions int) (*model.Node, error) {
+	logAction(fmt.Sprintf("Setting permissions for node %s to %d", nodeID, permissions))
+
+	// Check if user has permission to change permissions
+	hasPermission, err := checkPermission(ctx, s.db, nodeID, PERM_VIEW_PERMISSIONS)
+	if err != nil {
+		return nil, err
+	}
+
+	if !hasPermission {
+		return nil, serviceErrorf(errPermissionDenied, "permission denied: cannot view or modify permissions for this node")
+	}
+
+	// Update the permissions
+	_, err = s.db.Exec("UPDATE nodes SET permissions = ? WHERE id = ?", permissions, nodeID)
+	if err != nil {
+		log.Printf("Error updating node permissions: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to update node permissions: %v", err)
+	}
+
+	// Get the updated node
+	return getNodeWithPermissions(ctx, s.db, nodeID)
+}
+
+// SetOwnership sätter ägande användare och grupp på en nod
+func (s *nodeService) SetOwnership(ctx context.Context, nodeID string, ownerUserID *string, ownerGroupID *string) (*model.Node, error) {
+	logAction(fmt.Sprintf("Setting ownership for node %s", nodeID))
+
+	// Check if user has permission to change permissions
+	hasPermission, err := checkPermission(ctx, s.db, nodeID, PERM_VIEW_PERMISSIONS)
+	if err != nil {
+		return nil, err
+	}
+
+	if !hasPermission {
+		return nil, serviceErrorf(errPermissionDenied, "permission denied: cannot view or modify ownership for this node")
+	}
+
+	// Validate ownerUserID if provided
+	if ownerUserID != nil && *ownerUserID != "" {
+		var userExists bool
+		err = s.db.QueryRow("SELECT EXISTS(SELECT 1 FROM users WHERE id = ?)", *ownerUserID).Scan(&userExists)
+		if err != nil {
+			log.Printf("Error checking if user exists: %v", err)
+			return nil, serviceErrorf(errInternal, "failed to check if user exists: %v", err)
+		}
+
+		if !userExists {
+			return nil, serviceErrorf(errNotFound, "user not found")
+		}
+	}
+
+	// Validate ownerGroupID if provided
+	if ownerGroupID != nil && *ownerGroupID != "" {
+		var groupExists bool
+		err = s.db.QueryRow("SELECT EXISTS(SELECT 1 FROM groups WHERE id = ?)", *ownerGroupID).Scan(&groupExists)
+		if err != nil {
+			log.Printf("Error checking if group exists: %v", err)
+			return nil, serviceErrorf(errInternal, "failed to check if group exists: %v", err)
+		}
+
+		if !groupExists {
+			return nil, serviceErrorf(errNotFound, "group not found")
+		}
+	}
+
+	// Update the ownership
+	query := "UPDATE nodes SET"
+	args := []interface{}{}
+
+	if ownerUserID != nil {
+		if *ownerUserID == "" {
+			query += " owner_user_id = NULL"
+		} else {
+			query += " owner_user_id = ?"
+			args = append(args, *ownerUserID)
+		}
+	}
+
+	if ownerGroupID != nil {
+		if len(args) > 0 {
+			query += ","
+		}
+
+		if *ownerGroupID == "" {
+			query += " owner_group_id = NULL"
+		} else {
+			query += " owner_group_id = ?"
+			args = append(args, *ownerGroupID)
+		}
+	}
+
+	query += " WHERE id = ?"
+	args = append(args, nodeID)
+
+	_, err = s.db.Exec(query, args...)
+	if err != nil {
+		log.Printf("Error updating node ownership: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to update node ownership: %v", err)
+	}
+
+	// Get the updated node
+	return getNodeWithPermissions(ctx, s.db, nodeID)
+}
+
+// detectCycle checks if updating a node's parent would create a cycle in the node hierarchy
+func (s *nodeService) detectCycle(nodeID string, newParentID string, isCycle *bool) error {
+	*isCycle = false
+
+	// If the new parent is the same as the node itself, it's a cycle
+	if nodeID == newParentID {
+		*isCycle = true
+		return nil
+	}
+
+	// Check if any ancestors of the potential parent are actually the node we're moving
+	currentID := newParentID
+	for currentID != "" {
+		// If currentID ever becomes the node we're moving, we have a cycle
+		if currentID == nodeID {
+			*isCycle = true
+			return nil
+		}
+
+		// Get parent of current node
+		var parentID sql.NullString
+		err := s.db.QueryRow("SELECT parent_id FROM nodes WHERE id = ?", currentID).Scan(&parentID)
+		if err == sql.ErrNoRows {
+			// Node not found, break the loop
+			break
+		} else if err != nil {
+			return fmt.Errorf("error checking hierarchy: %v", err)
+		}
+
+		// If no parent, break the loop
+		if !parentID.Valid {
+			break
+		}
+
+		// Move up the hierarchy
+		currentID = parentID.String
+	}
+
+	return nil
+}
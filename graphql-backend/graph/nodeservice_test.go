@@ -0,0 +1,139 @@
+package graph
+
+import (
+	"graphql-backend/graph/model"
+	"testing"
+)
+
+func TestNodeServiceCreateAndList(t *testing.T) {
+	db := openTestDB(t)
+	nodes := NewServices(db).Nodes
+	ctx := testAdminContext(t)
+
+	root := createTestNode(t, db, "Arkiv", nil)
+	child := createTestNode(t, db, "Serie", &root.ID)
+	if child.ParentID == nil || *child.ParentID != root.ID {
+		t.Fatalf("child parent = %v, want %s", child.ParentID, root.ID)
+	}
+	if child.NodeType != model.NodeTypeFolder {
+		t.Errorf("node type = %s, want FOLDER", child.NodeType)
+	}
+
+	got, err := nodes.Get(ctx, child.ID)
+	if err != nil {
+		t.Fatalf("get node: %v", err)
+	}
+	if got.Name != "Serie" {
+		t.Errorf("name = %q, want Serie", got.Name)
+	}
+
+	roots, err := nodes.Roots(ctx)
+	if err != nil {
+		t.Fatalf("list roots: %v", err)
+	}
+	// Databasskriptet skapar en rotnod, och rotnoderna sorteras på namn
+	if len(roots) != 2 || roots[0].ID != root.ID {
+		t.Errorf("roots = %d nodes, want %s and the script's root", len(roots), root.ID)
+	}
+
+	children, err := nodes.Children(ctx, root.ID)
+	if err != nil {
+		t.Fatalf("list children: %v", err)
+	}
+	if len(children) != 1 || children[0].ID != child.ID {
+		t.Errorf("children = %v, want only %s", children, child.ID)
+	}
+
+	if _, err := nodes.Create(ctx, model.NodeInput{Name: "Föräldralös", ParentID: strPtr("99")}); err == nil || err.Error() != "parent node not found" {
+		t.Errorf("create under missing parent: err = %v, want parent node not found", err)
+	}
+}
+
+func TestNodeServiceUpdateAndMove(t *testing.T) {
+	db := openTestDB(t)
+	nodes := NewServices(db).Nodes
+	ctx := testAdminContext(t)
+
+	first := createTestNode(t, db, "Första", nil)
+	second := createTestNode(t, db, "Andra", nil)
+	child := createTestNode(t, db, "Barn", &first.ID)
+
+	updated, err := nodes.Update(ctx, child.ID, model.NodeUpdateInput{Name: strPtr("Omdöpt")})
+	if err != nil {
+		t.Fatalf("update node: %v", err)
+	}
+	if updated.Name != "Omdöpt" {
+		t.Errorf("name = %q, want Omdöpt", updated.Name)
+	}
+
+	moved, err := nodes.Move(ctx, child.ID, second.ID)
+	if err != nil {
+		t.Fatalf("move node: %v", err)
+	}
+	if moved.ParentID == nil || *moved.ParentID != second.ID {
+		t.Errorf("parent after move = %v, want %s", moved.ParentID, second.ID)
+	}
+
+	// En nod kan inte flyttas in under sig själv eller sina barn
+	if _, err := nodes.Move(ctx, second.ID, child.ID); err == nil {
+		t.Error("moving a node below its own child succeeded")
+	}
+	if _, err := nodes.Update(ctx, second.ID, model.NodeUpdateInput{ParentID: &second.ID}); err == nil || err.Error() != "cannot update node: would create a cycle in the hierarchy" {
+		t.Errorf("making a node its own parent: err = %v", err)
+	}
+}
+
+func TestNodeServiceDelete(t *testing.T) {
+	db := openTestDB(t)
+	nodes := NewServices(db).Nodes
+	ctx := testAdminContext(t)
+
+	parent := createTestNode(t, db, "Förälder", nil)
+	child := createTestNode(t, db, "Barn", &parent.ID)
+
+	if _, err := nodes.Delete(ctx, parent.ID); err == nil || err.Error() != "cannot delete node: has children nodes" {
+		t.Errorf("delete node with children: err = %v", err)
+	}
+	if ok, err := nodes.Delete(ctx, child.ID); err != nil || !ok {
+		t.Fatalf("delete child: %v, %v", ok, err)
+	}
+	if ok, err := nodes.Delete(ctx, parent.ID); err != nil || !ok {
+		t.Fatalf("delete parent: %v, %v", ok, err)
+	}
+	if _, err := nodes.Delete(ctx, parent.ID); err == nil || err.Error() != "node not found" {
+		t.Errorf("delete missing node: err = %v, want node not found", err)
+	}
+}
+
+func TestNodeServicePermissions(t *testing.T) {
+	db := openTestDB(t)
+	nodes := NewServices(db).Nodes
+	bobID := insertTestUser(t, db, "bob")
+	bob := testUserContext(t, bobID, "bob")
+
+	node := createTestNode(t, db, "Privat", nil)
+	if _, err := nodes.Get(bob, node.ID); err == nil || err.Error() != "permission denied: cannot view this node" {
+		t.Fatalf("get without permission: err = %v", err)
+	}
+
+	ctx := testAdminContext(t)
+	if _, err := nodes.SetOwnership(ctx, node.ID, &bobID, nil); err != nil {
+		t.Fatalf("set ownership: %v", err)
+	}
+	if _, err := nodes.SetPermissions(ctx, node.ID, PERM_VIEW); err != nil {
+		t.Fatalf("set permissions: %v", err)
+	}
+
+	got, err := nodes.Get(bob, node.ID)
+	if err != nil {
+		t.Fatalf("get as owner: %v", err)
+	}
+	if got.OwnerUserID == nil || *got.OwnerUserID != bobID || got.Permissions != PERM_VIEW {
+		t.Errorf("owner = %v, permissions = %d", got.OwnerUserID, got.Permissions)
+	}
+
+	// Läsbehörighet räcker inte för att ändra noden
+	if _, err := nodes.Move(bob, node.ID, "1"); err == nil || err.Error() != "permission denied: cannot modify this node" {
+		t.Errorf("move without modify permission: err = %v", err)
+	}
+}
@@ -0,0 +1,735 @@
+package graph
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// =============================================
+// ========== OAI-PMH =========================
+// =============================================
+
+const (
+	oaiNamespace       = "http://www.openarchives.org/OAI/2.0/"
+	oaiSchema          = "http://www.openarchives.org/OAI/2.0/OAI-PMH.xsd"
+	oaiDCNamespace     = "http://www.openarchives.org/OAI/2.0/oai_dc/"
+	oaiDCSchema        = "http://www.openarchives.org/OAI/2.0/oai_dc.xsd"
+	oaiDCPrefix        = "oai_dc"
+	oaiPageSize        = 100
+	oaiDayGranularity  = "2006-01-02"
+	oaiTimeGranularity = "2006-01-02T15:04:05Z"
+)
+
+// OAIConfig beskriver arkivet för skördare
+type OAIConfig struct {
+	RepositoryName       string
+	RepositoryIdentifier string // Namnrymd i OAI-identifierare, t.ex. arkiv.example.se
+	AdminEmail           string
+}
+
+// oaiError är ett fel enligt OAI-PMH, t.ex. badArgument
+type oaiError struct {
+	Code    string `xml:"code,attr"`
+	Message string `xml:",chardata"`
+}
+
+func (e *oaiError) Error() string {
+	return e.Code + ": " + e.Message
+}
+
+func newOAIError(code string, format string, args ...interface{}) *oaiError {
+	return &oaiError{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// ---------- Svarsstrukturer ----------
+
+type oaiResponse struct {
+	XMLName        xml.Name    `xml:"OAI-PMH"`
+	Xmlns          string      `xml:"xmlns,attr"`
+	XmlnsXsi       string      `xml:"xmlns:xsi,attr"`
+	SchemaLocation string      `xml:"xsi:schemaLocation,attr"`
+	ResponseDate   string      `xml:"responseDate"`
+	Request        oaiRequest  `xml:"request"`
+	Errors         []*oaiError `xml:"error"`
+	Body           interface{}
+}
+
+type oaiRequest struct {
+	Verb            string `xml:"verb,attr,omitempty"`
+	Identifier      string `xml:"identifier,attr,omitempty"`
+	MetadataPrefix  string `xml:"metadataPrefix,attr,omitempty"`
+	From            string `xml:"from,attr,omitempty"`
+	Until           string `xml:"until,attr,omitempty"`
+	Set             string `xml:"set,attr,omitempty"`
+	ResumptionToken string `xml:"resumptionToken,attr,omitempty"`
+	BaseURL         string `xml:",chardata"`
+}
+
+type oaiIdentify struct {
+	XMLName           xml.Name `xml:"Identify"`
+	RepositoryName    string   `xml:"repositoryName"`
+	BaseURL           string   `xml:"baseURL"`
+	ProtocolVersion   string   `xml:"protocolVersion"`
+	AdminEmail        string   `xml:"adminEmail"`
+	EarliestDatestamp string   `xml:"earliestDatestamp"`
+	DeletedRecord     string   `xml:"deletedRecord"`
+	Granularity       string   `xml:"granularity"`
+}
+
+type oaiMetadataFormat struct {
+	MetadataPrefix    string `xml:"metadataPrefix"`
+	Schema            string `xml:"schema"`
+	MetadataNamespace string `xml:"metadataNamespace"`
+}
+
+type oaiListMetadataFormats struct {
+	XMLName xml.Name            `xml:"ListMetadataFormats"`
+	Formats []oaiMetadataFormat `xml:"metadataFormat"`
+}
+
+type oaiSet struct {
+	SetSpec string `xml:"setSpec"`
+	SetName string `xml:"setName"`
+}
+
+type oaiListSets struct {
+	XMLName xml.Name `xml:"ListSets"`
+	Sets    []oaiSet `xml:"set"`
+}
+
+type oaiHeader struct {
+	Identifier string   `xml:"identifier"`
+	Datestamp  string   `xml:"datestamp"`
+	SetSpecs   []string `xml:"setSpec"`
+}
+
+type oaiDC struct {
+	XMLName        xml.Name               `xml:"oai_dc:dc"`
+	XmlnsOAIDC     string                 `xml:"xmlns:oai_dc,attr"`
+	XmlnsDC        string                 `xml:"xmlns:dc,attr"`
+	XmlnsXsi       string                 `xml:"xmlns:xsi,attr"`
+	SchemaLocation string                 `xml:"xsi:schemaLocation,attr"`
+	Elements       []dublinCoreXMLElement `xml:",any"`
+}
+
+type oaiRecord struct {
+	Header   oaiHeader `xml:"header"`
+	Metadata *oaiDC    `xml:"metadata>oai_dc:dc"`
+}
+
+type oaiResumptionToken struct {
+	CompleteListSize int    `xml:"completeListSize,attr"`
+	Cursor           int    `xml:"cursor,attr"`
+	Token            string `xml:",chardata"`
+}
+
+type oaiListIdentifiers struct {
+	XMLName         xml.Name            `xml:"ListIdentifiers"`
+	Headers         []oaiHeader         `xml:"header"`
+	ResumptionToken *oaiResumptionToken `xml:"resumptionToken"`
+}
+
+type oaiListRecords struct {
+	XMLName         xml.Name            `xml:"ListRecords"`
+	Records         []oaiRecord         `xml:"record"`
+	ResumptionToken *oaiResumptionToken `xml:"resumptionToken"`
+}
+
+type oaiGetRecord struct {
+	XMLName xml.Name  `xml:"GetRecord"`
+	Record  oaiRecord `xml:"record"`
+}
+
+// ---------- Hanterare ----------
+
+// oaiProvider besvarar OAI-PMH-förfrågningar om filerna i publicerade noder
+type oaiProvider struct {
+	db     *sql.DB
+	config OAIConfig
+}
+
+// oaiVerbArguments är de argument som varje verb tillåter utöver verb
+var oaiVerbArguments = map[string][]string{
+	"Identify":            {},
+	"ListMetadataFormats": {"identifier"},
+	"ListSets":            {"resumptionToken"},
+	"ListIdentifiers":     {"metadataPrefix", "from", "until", "set", "resumptionToken"},
+	"ListRecords":         {"metadataPrefix", "from", "until", "set", "resumptionToken"},
+	"GetRecord":           {"identifier", "metadataPrefix"},
+}
+
+// OAIHandler skapar en OAI-PMH-ändpunkt som skördare kan hämta publicerade filer från med oai_dc.
+// Posterna beskrivs med standardcrosswalken.
+func OAIHandler(db *sql.DB, config OAIConfig) http.Handler {
+	provider := &oaiProvider{db: db, config: config}
+	return http.HandlerFunc(provider.serveHTTP)
+}
+
+func (p *oaiProvider) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	response := &oaiResponse{
+		Xmlns:          oaiNamespace,
+		XmlnsXsi:       xsiNamespace,
+		SchemaLocation: oaiNamespace + " " + oaiSchema,
+		ResponseDate:   time.Now().UTC().Format(oaiTimeGranularity),
+		Request:        oaiRequest{BaseURL: oaiBaseURL(r)},
+	}
+
+	body, err := p.handle(r, response)
+	if oaiErr, ok := err.(*oaiError); ok {
+		// Vid badVerb och badArgument får request-elementet inte upprepa argumenten
+		if oaiErr.Code == "badVerb" || oaiErr.Code == "badArgument" {
+			response.Request = oaiRequest{BaseURL: response.Request.BaseURL}
+		}
+		response.Errors = []*oaiError{oaiErr}
+	} else if err != nil {
+		log.Printf("Error handling OAI-PMH request: %v", err)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	} else {
+		response.Body = body
+	}
+
+	data, err := xml.MarshalIndent(response, "", "  ")
+	if err != nil {
+		log.Printf("Error writing OAI-PMH response: %v", err)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/xml; charset=utf-8")
+	w.Write([]byte(xml.Header))
+	w.Write(data)
+}
+
+// handle kontrollerar argumenten och besvarar verbet
+func (p *oaiProvider) handle(r *http.Request, response *oaiResponse) (interface{}, error) {
+	if err := r.ParseForm(); err != nil {
+		return nil, newOAIError("badArgument", "malformed request: %v", err)
+	}
+
+	verbs := r.Form["verb"]
+	if len(verbs) != 1 {
+		return nil, newOAIError("badVerb", "exactly one verb is required")
+	}
+	verb := verbs[0]
+	allowed, ok := oaiVerbArguments[verb]
+	if !ok {
+		return nil, newOAIError("badVerb", "illegal verb %s", verb)
+	}
+
+	args := make(map[string]string)
+	for name, values := range r.Form {
+		if name == "verb" {
+			continue
+		}
+		if !containsString(allowed, name) {
+			return nil, newOAIError("badArgument", "illegal argument %s for verb %s", name, verb)
+		}
+		if len(values) != 1 {
+			return nil, newOAIError("badArgument", "argument %s is repeated", name)
+		}
+		args[name] = values[0]
+	}
+	if token, ok := args["resumptionToken"]; ok && len(args) > 1 {
+		return nil, newOAIError("badArgument", "resumptionToken is an exclusive argument")
+	} else if ok && token == "" {
+		return nil, newOAIError("badArgument", "resumptionToken is empty")
+	}
+
+	response.Request = oaiRequest{
+		Verb:            verb,
+		Identifier:      args["identifier"],
+		MetadataPrefix:  args["metadataPrefix"],
+		From:            args["from"],
+		Until:           args["until"],
+		Set:             args["set"],
+		ResumptionToken: args["resumptionToken"],
+		BaseURL:         response.Request.BaseURL,
+	}
+
+	switch verb {
+	case "Identify":
+		return p.identify(response.Request.BaseURL)
+	case "ListMetadataFormats":
+		return p.listMetadataFormats(args)
+	case "ListSets":
+		return p.listSets(args)
+	case "GetRecord":
+		return p.getRecord(args)
+	default:
+		return p.listRecords(verb, args)
+	}
+}
+
+// oaiBaseURL bygger ändpunktens adress från förfrågan
+func oaiBaseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if forwarded := r.Header.Get("X-Forwarded-Proto"); forwarded != "" {
+		scheme = forwarded
+	}
+	return scheme + "://" + r.Host + r.URL.Path
+}
+
+func (p *oaiProvider) identify(baseURL string) (interface{}, error) {
+	records, err := p.publishedRecords()
+	if err != nil {
+		return nil, err
+	}
+	earliest := time.Now().UTC().Format(oaiTimeGranularity)
+	for _, record := range records {
+		if record.Datestamp < earliest {
+			earliest = record.Datestamp
+		}
+	}
+
+	return &oaiIdentify{
+		RepositoryName:    p.config.RepositoryName,
+		BaseURL:           baseURL,
+		ProtocolVersion:   "2.0",
+		AdminEmail:        p.config.AdminEmail,
+		EarliestDatestamp: earliest,
+		DeletedRecord:     "no",
+		Granularity:       "YYYY-MM-DDThh:mm:ssZ",
+	}, nil
+}
+
+func (p *oaiProvider) listMetadataFormats(args map[string]string) (interface{}, error) {
+	if identifier, ok := args["identifier"]; ok {
+		if _, err := p.recordByIdentifier(identifier); err != nil {
+			return nil, err
+		}
+	}
+	return &oaiListMetadataFormats{Formats: []oaiMetadataFormat{{
+		MetadataPrefix:    oaiDCPrefix,
+		Schema:            oaiDCSchema,
+		MetadataNamespace: oaiDCNamespace,
+	}}}, nil
+}
+
+// listSets listar de publicerade noderna. Alla mängder ryms i ett svar, så ett återupptagningstoken är alltid fel.
+func (p *oaiProvider) listSets(args map[string]string) (interface{}, error) {
+	if _, ok := args["resumptionToken"]; ok {
+		return nil, newOAIError("badResumptionToken", "the set list is never split")
+	}
+
+	tree, err := p.publishedTree()
+	if err != nil {
+		return nil, err
+	}
+	var nodeIDs []string
+	for nodeID := range tree.published {
+		nodeIDs = append(nodeIDs, nodeID)
+	}
+	sort.Slice(nodeIDs, func(i, j int) bool { return lessNumericID(nodeIDs[i], nodeIDs[j]) })
+
+	list := &oaiListSets{}
+	for _, nodeID := range nodeIDs {
+		list.Sets = append(list.Sets, oaiSet{SetSpec: oaiSetSpec(nodeID), SetName: tree.names[nodeID]})
+	}
+	if len(list.Sets) == 0 {
+		return nil, newOAIError("noSetHierarchy", "no nodes are published")
+	}
+	return list, nil
+}
+
+func (p *oaiProvider) getRecord(args map[string]string) (interface{}, error) {
+	identifier, hasIdentifier := args["identifier"]
+	prefix, hasPrefix := args["metadataPrefix"]
+	if !hasIdentifier || !hasPrefix {
+		return nil, newOAIError("badArgument", "GetRecord requires identifier and metadataPrefix")
+	}
+
+	record, err := p.recordByIdentifier(identifier)
+	if err != nil {
+		return nil, err
+	}
+	if prefix != oaiDCPrefix {
+		return nil, newOAIError("cannotDisseminateFormat", "metadata format %s is not supported", prefix)
+	}
+
+	records, err := p.withMetadata([]*oaiPublishedRecord{record})
+	if err != nil {
+		return nil, err
+	}
+	return &oaiGetRecord{Record: records[0]}, nil
+}
+
+// listRecords besvarar ListIdentifiers och ListRecords, en sida i taget
+func (p *oaiProvider) listRecords(verb string, args map[string]string) (interface{}, error) {
+	query := oaiListQuery{}
+	if token, ok := args["resumptionToken"]; ok {
+		var err error
+		if query, err = parseOAIResumptionToken(token); err != nil {
+			return nil, err
+		}
+	} else {
+		query = oaiListQuery{MetadataPrefix: args["metadataPrefix"], From: args["from"], Until: args["until"], Set: args["set"]}
+		if query.MetadataPrefix == "" {
+			return nil, newOAIError("badArgument", "%s requires metadataPrefix", verb)
+		}
+	}
+
+	from, until, err := parseOAIDateRange(query.From, query.Until)
+	if err != nil {
+		return nil, err
+	}
+	if query.MetadataPrefix != oaiDCPrefix {
+		return nil, newOAIError("cannotDisseminateFormat", "metadata format %s is not supported", query.MetadataPrefix)
+	}
+
+	records, err := p.publishedRecords()
+	if err != nil {
+		return nil, err
+	}
+	if query.Set != "" {
+		tree, err := p.publishedTree()
+		if err != nil {
+			return nil, err
+		}
+		nodeID, ok := strings.CutPrefix(query.Set, "node-")
+		if !ok || !tree.published[nodeID] {
+			return nil, newOAIError("badArgument", "unknown set %s", query.Set)
+		}
+		records = filterRecords(records, func(record *oaiPublishedRecord) bool {
+			return containsString(record.SetSpecs, query.Set)
+		})
+	}
+	records = filterRecords(records, func(record *oaiPublishedRecord) bool {
+		return (from == "" || record.Datestamp >= from) && (until == "" || record.Datestamp <= until)
+	})
+	if len(records) == 0 {
+		return nil, newOAIError("noRecordsMatch", "no records match the request")
+	}
+	if query.Offset >= len(records) {
+		return nil, newOAIError("badResumptionToken", "the resumption token is past the end of the list")
+	}
+
+	end := query.Offset + oaiPageSize
+	if end > len(records) {
+		end = len(records)
+	}
+	page := records[query.Offset:end]
+
+	// Sista sidan får ett tomt token så att skördaren vet att listan är slut
+	var resumption *oaiResumptionToken
+	if query.Offset > 0 || end < len(records) {
+		resumption = &oaiResumptionToken{CompleteListSize: len(records), Cursor: query.Offset}
+		if end < len(records) {
+			next := query
+			next.Offset = end
+			resumption.Token = next.token()
+		}
+	}
+
+	if verb == "ListIdentifiers" {
+		list := &oaiListIdentifiers{ResumptionToken: resumption}
+		for _, record := range page {
+			list.Headers = append(list.Headers, p.header(record))
+		}
+		return list, nil
+	}
+
+	fullRecords, err := p.withMetadata(page)
+	if err != nil {
+		return nil, err
+	}
+	return &oaiListRecords{Records: fullRecords, ResumptionToken: resumption}, nil
+}
+
+// ---------- Publicerade poster ----------
+
+// oaiPublishedTree är nodträdet med de noder vars filer skördas
+type oaiPublishedTree struct {
+	published map[string]bool
+	parents   map[string]string
+	names     map[string]string
+}
+
+// publishedTree läser alla noder och avgör vilka som är publicerade, med arv från föräldrarna
+func (p *oaiProvider) publishedTree() (*oaiPublishedTree, error) {
+	rows, err := p.db.Query("SELECT id, parent_id, name, published FROM nodes")
+	if err != nil {
+		log.Printf("Error fetching nodes for OAI-PMH: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to fetch nodes: %v", err)
+	}
+	defer rows.Close()
+
+	tree := &oaiPublishedTree{
+		published: make(map[string]bool),
+		parents:   make(map[string]string),
+		names:     make(map[string]string),
+	}
+	settings := make(map[string]sql.NullBool)
+	for rows.Next() {
+		var id, name string
+		var parentID sql.NullString
+		var published sql.NullBool
+		if err := rows.Scan(&id, &parentID, &name, &published); err != nil {
+			log.Printf("Error scanning node row: %v", err)
+			return nil, serviceErrorf(errInternal, "failed to scan node row: %v", err)
+		}
+		tree.parents[id] = parentID.String
+		tree.names[id] = name
+		settings[id] = published
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Samma regel som publicationPolicy: närmaste nod uppåt med en egen inställning avgör
+	for id := range tree.names {
+		visited := make(map[string]bool)
+		for currentID := id; currentID != "" && !visited[currentID]; currentID = tree.parents[currentID] {
+			visited[currentID] = true
+			if setting := settings[currentID]; setting.Valid {
+				if setting.Bool {
+					tree.published[id] = true
+				}
+				break
+			}
+		}
+	}
+	return tree, nil
+}
+
+// setSpecs är mängderna en nod ingår i: noden själv och de publicerade noderna ovanför den
+func (t *oaiPublishedTree) setSpecs(nodeID string) []string {
+	var specs []string
+	visited := make(map[string]bool)
+	for currentID := nodeID; currentID != "" && !visited[currentID]; currentID = t.parents[currentID] {
+		visited[currentID] = true
+		if t.published[currentID] {
+			specs = append(specs, oaiSetSpec(currentID))
+		}
+	}
+	return specs
+}
+
+// oaiPublishedRecord är en publicerad fil med dess datumstämpel och mängder
+type oaiPublishedRecord struct {
+	FileID    string
+	Datestamp string
+	UpdatedAt string
+	SetSpecs  []string
+}
+
+// publishedRecords hämtar alla filer i publicerade noder, sorterade på id
+func (p *oaiProvider) publishedRecords() ([]*oaiPublishedRecord, error) {
+	tree, err := p.publishedTree()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := p.db.Query("SELECT id, node_id, created_at, updated_at FROM files ORDER BY id ASC")
+	if err != nil {
+		log.Printf("Error fetching files for OAI-PMH: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to fetch files: %v", err)
+	}
+	defer rows.Close()
+
+	var records []*oaiPublishedRecord
+	for rows.Next() {
+		var fileID, createdAt string
+		var nodeID, updatedAt sql.NullString
+		if err := rows.Scan(&fileID, &nodeID, &createdAt, &updatedAt); err != nil {
+			log.Printf("Error scanning file row: %v", err)
+			return nil, serviceErrorf(errInternal, "failed to scan file row: %v", err)
+		}
+		if !tree.published[nodeID.String] {
+			continue
+		}
+		datestamp := createdAt
+		if updatedAt.Valid {
+			datestamp = updatedAt.String
+		}
+		records = append(records, &oaiPublishedRecord{
+			FileID:    fileID,
+			Datestamp: normalizeDatestamp(datestamp),
+			UpdatedAt: updatedAt.String,
+			SetSpecs:  tree.setSpecs(nodeID.String),
+		})
+	}
+	return records, rows.Err()
+}
+
+// recordByIdentifier hittar en publicerad fil från dess OAI-identifierare
+func (p *oaiProvider) recordByIdentifier(identifier string) (*oaiPublishedRecord, error) {
+	fileID, ok := strings.CutPrefix(identifier, p.identifierPrefix())
+	if ok {
+		records, err := p.publishedRecords()
+		if err != nil {
+			return nil, err
+		}
+		for _, record := range records {
+			if record.FileID == fileID {
+				return record, nil
+			}
+		}
+	}
+	return nil, newOAIError("idDoesNotExist", "%s is not a known identifier", identifier)
+}
+
+func (p *oaiProvider) identifierPrefix() string {
+	return "oai:" + p.config.RepositoryIdentifier + ":"
+}
+
+func (p *oaiProvider) header(record *oaiPublishedRecord) oaiHeader {
+	return oaiHeader{
+		Identifier: p.identifierPrefix() + record.FileID,
+		Datestamp:  record.Datestamp,
+		SetSpecs:   record.SetSpecs,
+	}
+}
+
+// withMetadata gör om poster till oai_dc med standardcrosswalken. dcterms-element ingår inte i oai_dc.
+func (p *oaiProvider) withMetadata(records []*oaiPublishedRecord) ([]oaiRecord, error) {
+	mappings, err := crosswalkMappings(p.db, nil)
+	if err != nil {
+		return nil, err
+	}
+	walker := newCrosswalker(p.db, mappings)
+
+	var result []oaiRecord
+	for _, record := range records {
+		file, err := loadTreeFile(p.db, record.FileID)
+		if err != nil {
+			return nil, err
+		}
+		elements, err := walker.record(file, record.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		elements = filterElements(elements, func(element dublinCoreElement) bool { return element.Prefix == "dc" })
+
+		result = append(result, oaiRecord{
+			Header: p.header(record),
+			Metadata: &oaiDC{
+				XmlnsOAIDC:     oaiDCNamespace,
+				XmlnsDC:        dcNamespace,
+				XmlnsXsi:       xsiNamespace,
+				SchemaLocation: oaiDCNamespace + " " + oaiDCSchema,
+				Elements:       dublinCoreXMLElements(elements),
+			},
+		})
+	}
+	return result, nil
+}
+
+// oaiSetSpec är mängdens namn för en nod
+func oaiSetSpec(nodeID string) string {
+	return "node-" + nodeID
+}
+
+func filterRecords(records []*oaiPublishedRecord, keep func(*oaiPublishedRecord) bool) []*oaiPublishedRecord {
+	var kept []*oaiPublishedRecord
+	for _, record := range records {
+		if keep(record) {
+			kept = append(kept, record)
+		}
+	}
+	return kept
+}
+
+func filterElements(elements []dublinCoreElement, keep func(dublinCoreElement) bool) []dublinCoreElement {
+	var kept []dublinCoreElement
+	for _, element := range elements {
+		if keep(element) {
+			kept = append(kept, element)
+		}
+	}
+	return kept
+}
+
+// lessNumericID sorterar id:n som tal
+func lessNumericID(a, b string) bool {
+	numberA, errA := strconv.Atoi(a)
+	numberB, errB := strconv.Atoi(b)
+	if errA != nil || errB != nil {
+		return a < b
+	}
+	return numberA < numberB
+}
+
+// ---------- Datum och återupptagningstoken ----------
+
+// parseOAIDateRange kontrollerar from och until och gör om dem till datumstämplar som kan jämföras.
+// until med dagsupplösning omfattar hela dagen.
+func parseOAIDateRange(from, until string) (string, string, error) {
+	parse := func(name, value string, endOfDay bool) (string, string, error) {
+		if value == "" {
+			return "", "", nil
+		}
+		if t, err := time.Parse(oaiTimeGranularity, value); err == nil {
+			return t.Format(oaiTimeGranularity), "time", nil
+		}
+		if t, err := time.Parse(oaiDayGranularity, value); err == nil {
+			if endOfDay {
+				t = t.Add(24*time.Hour - time.Second)
+			}
+			return t.Format(oaiTimeGranularity), "day", nil
+		}
+		return "", "", newOAIError("badArgument", "%s must be YYYY-MM-DD or YYYY-MM-DDThh:mm:ssZ", name)
+	}
+
+	fromStamp, fromGranularity, err := parse("from", from, false)
+	if err != nil {
+		return "", "", err
+	}
+	untilStamp, untilGranularity, err := parse("until", until, true)
+	if err != nil {
+		return "", "", err
+	}
+	if fromGranularity != "" && untilGranularity != "" && fromGranularity != untilGranularity {
+		return "", "", newOAIError("badArgument", "from and until must have the same granularity")
+	}
+	if fromStamp != "" && untilStamp != "" && fromStamp > untilStamp {
+		return "", "", newOAIError("badArgument", "from must not be later than until")
+	}
+	return fromStamp, untilStamp, nil
+}
+
+// oaiListQuery är en listförfrågan. Den sparas i återupptagningstoken så att servern inte behöver hålla tillstånd.
+type oaiListQuery struct {
+	MetadataPrefix string
+	From           string
+	Until          string
+	Set            string
+	Offset         int
+}
+
+func (q oaiListQuery) token() string {
+	raw := strings.Join([]string{strconv.Itoa(q.Offset), q.MetadataPrefix, q.From, q.Until, q.Set}, "|")
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func parseOAIResumptionToken(token string) (oaiListQuery, error) {
+	invalid := newOAIError("badResumptionToken", "the resumption token is invalid")
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return oaiListQuery{}, invalid
+	}
+	parts := strings.Split(string(raw), "|")
+	if len(parts) != 5 {
+		return oaiListQuery{}, invalid
+	}
+	offset, err := strconv.Atoi(parts[0])
+	if err != nil || offset < 0 {
+		return oaiListQuery{}, invalid
+	}
+	return oaiListQuery{Offset: offset, MetadataPrefix: parts[1], From: parts[2], Until: parts[3], Set: parts[4]}, nil
+}
@@ -0,0 +1,184 @@
+package graph
+
+import (
+	"database/sql"
+	"encoding/xml"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// oaiTestResponse är de delar av ett OAI-PMH-svar som testerna kontrollerar
+type oaiTestResponse struct {
+	Errors []struct {
+		Code string `xml:"code,attr"`
+	} `xml:"error"`
+	Headers []struct {
+		Identifier string   `xml:"identifier"`
+		SetSpecs   []string `xml:"setSpec"`
+	} `xml:"ListIdentifiers>header"`
+	Token *struct {
+		Cursor           int    `xml:"cursor,attr"`
+		CompleteListSize int    `xml:"completeListSize,attr"`
+		Value            string `xml:",chardata"`
+	} `xml:"ListIdentifiers>resumptionToken"`
+	Records []string `xml:"ListRecords>record>header>identifier"`
+	Sets    []string `xml:"ListSets>set>setSpec"`
+	Title   string   `xml:"GetRecord>record>metadata>dc>title"`
+}
+
+// oaiRequestFor skickar en OAI-PMH-förfrågan med query-strängen och tolkar svaret
+func oaiRequestFor(t *testing.T, db *sql.DB, query string) *oaiTestResponse {
+	t.Helper()
+	recorder := httptest.NewRecorder()
+	OAIHandler(db, OAIConfig{RepositoryName: "Testarkiv", RepositoryIdentifier: "arkiv.example.se"}).
+		ServeHTTP(recorder, httptest.NewRequest("GET", "/oai?"+query, nil))
+	if recorder.Code != 200 {
+		t.Fatalf("%s: status %d: %s", query, recorder.Code, recorder.Body.String())
+	}
+	var response oaiTestResponse
+	if err := xml.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatalf("%s: parse response: %v", query, err)
+	}
+	return &response
+}
+
+// errorCode är koden för svarets OAI-fel, eller tom om svaret saknar fel
+func (r *oaiTestResponse) errorCode() string {
+	if len(r.Errors) == 0 {
+		return ""
+	}
+	return r.Errors[0].Code
+}
+
+func (r *oaiTestResponse) identifiers() string {
+	var ids []string
+	for _, header := range r.Headers {
+		ids = append(ids, header.Identifier)
+	}
+	return strings.Join(ids, ",")
+}
+
+func TestOAIHarvestsOnlyPublishedFiles(t *testing.T) {
+	db := openTestDB(t)
+	ctx := testAdminContext(t)
+	mutation := NewResolver(db).Mutation()
+
+	open := insertTestNode(t, db, "Offentligt", "")
+	closed := insertTestNode(t, db, "Sekretess", open)
+	reopened := insertTestNode(t, db, "Kungörelser", closed)
+	internal := insertTestNode(t, db, "Internt", "")
+	published, unpublished := true, false
+	for nodeID, setting := range map[string]*bool{open: &published, closed: &unpublished, reopened: &published} {
+		if _, err := mutation.SetNodePublished(ctx, nodeID, setting); err != nil {
+			t.Fatal(err)
+		}
+	}
+	openFile := insertTestFile(t, db, "protokoll.txt", open)
+	secretFile := insertTestFile(t, db, "utredning.txt", closed)
+	noticeFile := insertTestFile(t, db, "kungorelse.txt", reopened)
+	internalFile := insertTestFile(t, db, "anteckning.txt", internal)
+
+	prefix := "oai:arkiv.example.se:"
+	response := oaiRequestFor(t, db, "verb=ListIdentifiers&metadataPrefix=oai_dc")
+	if got := response.identifiers(); got != prefix+openFile+","+prefix+noticeFile {
+		t.Fatalf("identifiers = %s, want only the files in published nodes", got)
+	}
+	// En fil ingår i sin nods mängd och i de publicerade noderna ovanför
+	if specs := strings.Join(response.Headers[1].SetSpecs, ","); specs != "node-"+reopened+",node-"+open {
+		t.Errorf("set specs = %s", specs)
+	}
+	if records := oaiRequestFor(t, db, "verb=ListRecords&metadataPrefix=oai_dc").Records; strings.Join(records, ",") != prefix+openFile+","+prefix+noticeFile {
+		t.Errorf("records = %v", records)
+	}
+	if sets := oaiRequestFor(t, db, "verb=ListSets").Sets; strings.Join(sets, ",") != "node-"+open+",node-"+reopened {
+		t.Errorf("sets = %v", sets)
+	}
+	if got := oaiRequestFor(t, db, "verb=ListIdentifiers&metadataPrefix=oai_dc&set=node-"+reopened).identifiers(); got != prefix+noticeFile {
+		t.Errorf("identifiers in set = %s", got)
+	}
+
+	if record := oaiRequestFor(t, db, "verb=GetRecord&metadataPrefix=oai_dc&identifier="+prefix+openFile); record.errorCode() != "" || record.Title != "protokoll.txt" {
+		t.Errorf("get published record: error %q, title %q", record.errorCode(), record.Title)
+	}
+	// Opublicerade filer finns inte för skördaren, inte ens med rätt identifierare
+	for _, fileID := range []string{secretFile, internalFile} {
+		if code := oaiRequestFor(t, db, "verb=GetRecord&metadataPrefix=oai_dc&identifier="+prefix+fileID).errorCode(); code != "idDoesNotExist" {
+			t.Errorf("get unpublished record %s: error %q", fileID, code)
+		}
+	}
+	if code := oaiRequestFor(t, db, "verb=ListIdentifiers&metadataPrefix=oai_dc&set=node-"+closed).errorCode(); code != "badArgument" {
+		t.Errorf("harvest unpublished set: error %q", code)
+	}
+
+	// Att ta bort publiceringen döljer filerna igen
+	if _, err := mutation.SetNodePublished(ctx, open, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := oaiRequestFor(t, db, "verb=ListIdentifiers&metadataPrefix=oai_dc").identifiers(); got != prefix+noticeFile {
+		t.Errorf("identifiers after unpublishing = %s", got)
+	}
+}
+
+func TestOAIRequests(t *testing.T) {
+	db := openTestDB(t)
+	open := insertTestNode(t, db, "Offentligt", "")
+	mustExec(t, db, "UPDATE nodes SET published = 1 WHERE id = ?", open)
+	for i := 0; i < oaiPageSize+2; i++ {
+		insertTestFile(t, db, "fil.txt", open)
+	}
+
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"verb=Radera", "badVerb"},
+		{"verb=ListIdentifiers", "badArgument"},
+		{"verb=ListIdentifiers&metadataPrefix=oai_dc&extra=1", "badArgument"},
+		{"verb=ListIdentifiers&metadataPrefix=mods", "cannotDisseminateFormat"},
+		{"verb=ListIdentifiers&metadataPrefix=oai_dc&from=1+mars", "badArgument"},
+		{"verb=ListIdentifiers&metadataPrefix=oai_dc&from=2026-03-01&until=2026-03-02T00:00:00Z", "badArgument"},
+		{"verb=ListIdentifiers&metadataPrefix=oai_dc&from=2026-03-02&until=2026-03-01", "badArgument"},
+		{"verb=ListIdentifiers&metadataPrefix=oai_dc&from=2999-01-01", "noRecordsMatch"},
+		{"verb=ListIdentifiers&resumptionToken=inte-ett-token", "badResumptionToken"},
+		{"verb=ListIdentifiers&metadataPrefix=oai_dc&resumptionToken=x", "badArgument"},
+		{"verb=ListSets&resumptionToken=x", "badResumptionToken"},
+		{"verb=GetRecord&identifier=oai:annat.example.se:1", "badArgument"},
+		{"verb=GetRecord&metadataPrefix=oai_dc&identifier=oai:annat.example.se:1", "idDoesNotExist"},
+	}
+	for _, tt := range tests {
+		if code := oaiRequestFor(t, db, tt.query).errorCode(); code != tt.want {
+			t.Errorf("%s: error %q, want %q", tt.query, code, tt.want)
+		}
+	}
+
+	// Listan delas i sidor, och sista sidan har ett tomt återupptagningstoken
+	first := oaiRequestFor(t, db, "verb=ListIdentifiers&metadataPrefix=oai_dc&until="+url.QueryEscape("2999-01-01T00:00:00Z"))
+	if len(first.Headers) != oaiPageSize || first.Token == nil || first.Token.Value == "" || first.Token.CompleteListSize != oaiPageSize+2 {
+		t.Fatalf("first page: %d headers, token %+v", len(first.Headers), first.Token)
+	}
+	last := oaiRequestFor(t, db, "verb=ListIdentifiers&resumptionToken="+url.QueryEscape(first.Token.Value))
+	if len(last.Headers) != 2 || last.Token == nil || last.Token.Value != "" || last.Token.Cursor != oaiPageSize {
+		t.Errorf("last page: %d headers, token %+v", len(last.Headers), last.Token)
+	}
+	if first.Headers[0].Identifier == last.Headers[0].Identifier {
+		t.Error("the second page repeats the first")
+	}
+
+	query, err := parseOAIResumptionToken(first.Token.Value)
+	if err != nil || query.Offset != oaiPageSize || query.Until != "2999-01-01T00:00:00Z" || query.MetadataPrefix != "oai_dc" {
+		t.Errorf("resumption token = %+v, %v", query, err)
+	}
+}
+
+func TestParseOAIDateRange(t *testing.T) {
+	// until med dagsupplösning omfattar hela dagen
+	from, until, err := parseOAIDateRange("2026-03-01", "2026-03-01")
+	if err != nil || from != "2026-03-01T00:00:00Z" || until != "2026-03-01T23:59:59Z" {
+		t.Errorf("day range = %s, %s, %v", from, until, err)
+	}
+	if from, until, err := parseOAIDateRange("", "2026-03-01T12:00:00Z"); err != nil || from != "" || until != "2026-03-01T12:00:00Z" {
+		t.Errorf("open range = %s, %s, %v", from, until, err)
+	}
+}
@@ -0,0 +1,666 @@
+package graph
+
+import (
+	"context"
+	"database/sql"
+	"encoding/xml"
+	"fmt"
+	"graphql-backend/graph/model"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// =============================================
+// ========== OAIS-PAKET (AIP/DIP) ===========
+// =============================================
+
+// Typer av informationspaket
+const (
+	packageTypeAIP = "AIP"
+	packageTypeDIP = "DIP"
+)
+
+// Namnrymder och schemaplatser för METS och PREMIS
+const (
+	metsNamespace     = "http://www.loc.gov/METS/"
+	metsSchema        = "http://www.loc.gov/standards/mets/mets.xsd"
+	premisNamespace   = "http://www.loc.gov/premis/v3"
+	premisSchema      = "http://www.loc.gov/standards/premis/v3/premis.xsd"
+	xlinkNamespace    = "http://www.w3.org/1999/xlink"
+	xsiNamespace      = "http://www.w3.org/2001/XMLSchema-instance"
+	recordNamespace   = "urn:e-arkive:metadata"
+	premisPackagePath = "metadata/preservation/premis.xml"
+)
+
+// ---------- METS.xml ----------
+
+type metsDocument struct {
+	XMLName        xml.Name      `xml:"mets"`
+	Xmlns          string        `xml:"xmlns,attr"`
+	XmlnsXlink     string        `xml:"xmlns:xlink,attr"`
+	XmlnsXsi       string        `xml:"xmlns:xsi,attr"`
+	SchemaLocation string        `xml:"xsi:schemaLocation,attr"`
+	ObjID          string        `xml:"OBJID,attr"`
+	Label          string        `xml:"LABEL,attr"`
+	Type           string        `xml:"TYPE,attr"`
+	Header         metsHeader    `xml:"metsHdr"`
+	DmdSecs        []*metsDmdSec `xml:"dmdSec"`
+	AmdSec         *metsAmdSec   `xml:"amdSec,omitempty"`
+	FileSec        metsFileSec   `xml:"fileSec"`
+	StructMap      metsStructMap `xml:"structMap"`
+}
+
+type metsHeader struct {
+	CreateDate   string      `xml:"CREATEDATE,attr"`
+	RecordStatus string      `xml:"RECORDSTATUS,attr"`
+	Agents       []metsAgent `xml:"agent"`
+}
+
+type metsAgent struct {
+	Role      string `xml:"ROLE,attr"`
+	Type      string `xml:"TYPE,attr"`
+	OtherType string `xml:"OTHERTYPE,attr,omitempty"`
+	Name      string `xml:"name"`
+}
+
+type metsDmdSec struct {
+	ID     string     `xml:"ID,attr"`
+	MdWrap metsMdWrap `xml:"mdWrap"`
+}
+
+type metsMdWrap struct {
+	MdType      string     `xml:"MDTYPE,attr"`
+	OtherMdType string     `xml:"OTHERMDTYPE,attr"`
+	XMLData     metsRecord `xml:"xmlData>record"`
+}
+
+// metsRecord bär nodens eller filens beskrivande metadata och Noark 5-fält
+type metsRecord struct {
+	Xmlns  string            `xml:"xmlns,attr"`
+	Type   string            `xml:"type,attr"`
+	Fields []metsRecordField `xml:"field"`
+}
+
+type metsRecordField struct {
+	Name   string `xml:"name,attr"`
+	Source string `xml:"source,attr"`
+	Value  string `xml:",chardata"`
+}
+
+type metsAmdSec struct {
+	ID         string         `xml:"ID,attr"`
+	DigiprovMD metsDigiprovMD `xml:"digiprovMD"`
+}
+
+type metsDigiprovMD struct {
+	ID    string    `xml:"ID,attr"`
+	MdRef metsMdRef `xml:"mdRef"`
+}
+
+type metsMdRef struct {
+	LocType      string `xml:"LOCTYPE,attr"`
+	MdType       string `xml:"MDTYPE,attr"`
+	MimeType     string `xml:"MIMETYPE,attr"`
+	Checksum     string `xml:"CHECKSUM,attr"`
+	ChecksumType string `xml:"CHECKSUMTYPE,attr"`
+	XlinkType    string `xml:"xlink:type,attr"`
+	Href         string `xml:"xlink:href,attr"`
+}
+
+type metsFileSec struct {
+	FileGrp metsFileGrp `xml:"fileGrp"`
+}
+
+type metsFileGrp struct {
+	Use   string      `xml:"USE,attr"`
+	Files []*metsFile `xml:"file"`
+}
+
+type metsFile struct {
+	ID           string     `xml:"ID,attr"`
+	MimeType     string     `xml:"MIMETYPE,attr"`
+	Size         int        `xml:"SIZE,attr"`
+	Created      string     `xml:"CREATED,attr"`
+	Checksum     string     `xml:"CHECKSUM,attr"`
+	ChecksumType string     `xml:"CHECKSUMTYPE,attr"`
+	DmdID        string     `xml:"DMDID,attr,omitempty"`
+	AdmID        string     `xml:"ADMID,attr,omitempty"`
+	FLocat       metsFLocat `xml:"FLocat"`
+}
+
+type metsFLocat struct {
+	LocType   string `xml:"LOCTYPE,attr"`
+	XlinkType string `xml:"xlink:type,attr"`
+	Href      string `xml:"xlink:href,attr"`
+}
+
+type metsStructMap struct {
+	Type  string   `xml:"TYPE,attr"`
+	Label string   `xml:"LABEL,attr"`
+	Div   *metsDiv `xml:"div"`
+}
+
+type metsDiv struct {
+	Type  string     `xml:"TYPE,attr"`
+	Label string     `xml:"LABEL,attr"`
+	DmdID string     `xml:"DMDID,attr,omitempty"`
+	Fptr  *metsFptr  `xml:"fptr,omitempty"`
+	Divs  []*metsDiv `xml:"div"`
+}
+
+type metsFptr struct {
+	FileID string `xml:"FILEID,attr"`
+}
+
+// ---------- premis.xml ----------
+
+type premisDocument struct {
+	XMLName        xml.Name        `xml:"premis"`
+	Xmlns          string          `xml:"xmlns,attr"`
+	XmlnsXsi       string          `xml:"xmlns:xsi,attr"`
+	SchemaLocation string          `xml:"xsi:schemaLocation,attr"`
+	Version        string          `xml:"version,attr"`
+	Objects        []*premisObject `xml:"object"`
+	Events         []*premisEvent  `xml:"event"`
+	Agents         []*premisAgent  `xml:"agent"`
+}
+
+type premisObject struct {
+	XsiType         string                  `xml:"xsi:type,attr"`
+	Identifier      premisObjectIdentifier  `xml:"objectIdentifier"`
+	Characteristics premisCharacteristics   `xml:"objectCharacteristics"`
+	OriginalName    string                  `xml:"originalName"`
+	Storage         premisStorage           `xml:"storage"`
+	LinkingEvents   []premisLinkingEventRef `xml:"linkingEventIdentifier"`
+}
+
+type premisObjectIdentifier struct {
+	Type  string `xml:"objectIdentifierType"`
+	Value string `xml:"objectIdentifierValue"`
+}
+
+type premisCharacteristics struct {
+	CompositionLevel int          `xml:"compositionLevel"`
+	Fixity           premisFixity `xml:"fixity"`
+	Size             int          `xml:"size"`
+	Format           premisFormat `xml:"format"`
+}
+
+type premisFormat struct {
+	Name     string                `xml:"formatDesignation>formatName"`
+	Version  string                `xml:"formatDesignation>formatVersion,omitempty"`
+	Registry *premisFormatRegistry `xml:"formatRegistry,omitempty"`
+}
+
+type premisFormatRegistry struct {
+	Name string `xml:"formatRegistryName"`
+	Key  string `xml:"formatRegistryKey"`
+	Role string `xml:"formatRegistryRole"`
+}
+
+type premisFixity struct {
+	Algorithm  string `xml:"messageDigestAlgorithm"`
+	Digest     string `xml:"messageDigest"`
+	Originator string `xml:"messageDigestOriginator"`
+}
+
+type premisStorage struct {
+	LocationType  string `xml:"contentLocation>contentLocationType"`
+	LocationValue string `xml:"contentLocation>contentLocationValue"`
+}
+
+type premisLinkingEventRef struct {
+	Type  string `xml:"linkingEventIdentifierType"`
+	Value string `xml:"linkingEventIdentifierValue"`
+}
+
+type premisEvent struct {
+	IdentifierType  string              `xml:"eventIdentifier>eventIdentifierType"`
+	IdentifierValue string              `xml:"eventIdentifier>eventIdentifierValue"`
+	Type            string              `xml:"eventType"`
+	DateTime        string              `xml:"eventDateTime"`
+	Detail          string              `xml:"eventDetailInformation>eventDetail,omitempty"`
+	Outcome         premisEventOutcome  `xml:"eventOutcomeInformation"`
+	LinkingAgent    premisLinkingAgent  `xml:"linkingAgentIdentifier"`
+	LinkingObject   premisLinkingObject `xml:"linkingObjectIdentifier"`
+}
+
+type premisEventOutcome struct {
+	Outcome string `xml:"eventOutcome"`
+	Note    string `xml:"eventOutcomeDetail>eventOutcomeDetailNote,omitempty"`
+}
+
+type premisLinkingAgent struct {
+	Type  string `xml:"linkingAgentIdentifierType"`
+	Value string `xml:"linkingAgentIdentifierValue"`
+}
+
+type premisLinkingObject struct {
+	Type  string `xml:"linkingObjectIdentifierType"`
+	Value string `xml:"linkingObjectIdentifierValue"`
+}
+
+type premisAgent struct {
+	IdentifierType  string `xml:"agentIdentifier>agentIdentifierType"`
+	IdentifierValue string `xml:"agentIdentifier>agentIdentifierValue"`
+	Name            string `xml:"agentName"`
+	Type            string `xml:"agentType"`
+}
+
+// InformationPackageResult är resultatet som sparas på jobbet när paketet är klart
+type InformationPackageResult struct {
+	Type      string `json:"type"`
+	Directory string `json:"directory"`
+	Files     int    `json:"files"`
+}
+
+// informationPackage håller tillståndet medan ett AIP eller DIP byggs
+type informationPackage struct {
+	ctx        context.Context // Användaren som startade jobbet, för åtkomstloggningen
+	db         *sql.DB
+	job        *jobContext
+	kind       string
+	outDir     string
+	contentDir string // Katalog för filinnehållet relativt paketet
+	createdBy  string
+	total      int
+	processed  int
+	mets       *metsDocument
+	premis     *premisDocument
+	agents     map[string]bool
+	fixityErrs []string
+}
+
+// runInformationPackage bygger ett AIP (bevarande) eller DIP (åtkomst) för en nod och dess underträd
+func runInformationPackage(db *sql.DB, job *jobContext, kind string, nodeID string, createdBy string) (string, error) {
+	job.setProgress(0, "Reading node tree")
+
+	root, err := loadSubtree(db, nodeID)
+	if err != nil {
+		return "", err
+	}
+
+	// PREMIS kräver minst ett objekt, så tomma paket skapas inte
+	if root.countFiles() == 0 {
+		return "", fmt.Errorf("node %s contains no files to package", nodeID)
+	}
+
+	outDir := filepath.Join(exportBaseDir, fmt.Sprintf("%s-%s-%s", strings.ToLower(kind), job.id, time.Now().Format("20060102150405")))
+	pkg := &informationPackage{
+		ctx:       job.userContext("", ""),
+		db:        db,
+		job:       job,
+		kind:      kind,
+		outDir:    outDir,
+		createdBy: createdBy,
+		total:     root.countFiles(),
+		agents:    make(map[string]bool),
+	}
+
+	// AIP följer E-ARK-strukturen med representationer, DIP har bara åtkomstkopiorna
+	use := "Access"
+	pkg.contentDir = "objects"
+	if kind == packageTypeAIP {
+		use = "Original"
+		pkg.contentDir = "representations/rep1/data"
+		pkg.premis = &premisDocument{
+			Xmlns:          premisNamespace,
+			XmlnsXsi:       xsiNamespace,
+			SchemaLocation: premisNamespace + " " + premisSchema,
+			Version:        "3.0",
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Join(outDir, filepath.FromSlash(pkg.contentDir)), 0755); err != nil {
+		return "", serviceErrorf(errInternal, "failed to create package directory: %v", err)
+	}
+
+	now := time.Now().Format(time.RFC3339)
+	pkg.mets = &metsDocument{
+		Xmlns:          metsNamespace,
+		XmlnsXlink:     xlinkNamespace,
+		XmlnsXsi:       xsiNamespace,
+		SchemaLocation: metsNamespace + " " + metsSchema,
+		ObjID:          noarkSystemID("node", root.ID),
+		Label:          root.Name,
+		Type:           kind,
+		Header: metsHeader{
+			CreateDate:   now,
+			RecordStatus: "NEW",
+			Agents: []metsAgent{
+				{Role: "CREATOR", Type: "OTHER", OtherType: "SOFTWARE", Name: "e-Arkive"},
+				{Role: "CREATOR", Type: "INDIVIDUAL", Name: createdBy},
+			},
+		},
+		FileSec:   metsFileSec{FileGrp: metsFileGrp{Use: use}},
+		StructMap: metsStructMap{Type: "physical", Label: "e-Arkive node hierarchy"},
+	}
+
+	pkg.mets.StructMap.Div, err = pkg.addNode(root, pkg.contentDir)
+	if err != nil {
+		return "", err
+	}
+
+	job.setProgress(90, "Writing package metadata")
+
+	if pkg.premis != nil {
+		pkg.addAgent(createdBy, "person")
+		checksum, err := writeXMLFile(outDir, premisPackagePath, pkg.premis)
+		if err != nil {
+			return "", err
+		}
+
+		pkg.mets.AmdSec = &metsAmdSec{
+			ID: "AMD",
+			DigiprovMD: metsDigiprovMD{
+				ID: "PREMIS",
+				MdRef: metsMdRef{
+					LocType:      "URL",
+					MdType:       "PREMIS",
+					MimeType:     "text/xml",
+					Checksum:     checksum,
+					ChecksumType: "SHA-256",
+					XlinkType:    "simple",
+					Href:         premisPackagePath,
+				},
+			},
+		}
+	}
+
+	if _, err := writeXMLFile(outDir, "METS.xml", pkg.mets); err != nil {
+		return "", err
+	}
+
+	// Paketet skrivs färdigt så att det går att granska, men jobbet misslyckas vid fixitetsfel
+	if len(pkg.fixityErrs) > 0 {
+		return "", fmt.Errorf("fixity check failed for %d files: %s", len(pkg.fixityErrs), strings.Join(pkg.fixityErrs, ", "))
+	}
+
+	logAction(fmt.Sprintf("%s for node %s written to %s", kind, nodeID, outDir))
+
+	return encodeJobResult(InformationPackageResult{
+		Type:      kind,
+		Directory: outDir,
+		Files:     pkg.processed,
+	})
+}
+
+// addNode lägger till en nod med filer och barnnoder i paketet och returnerar dess div i strukturkartan
+func (p *informationPackage) addNode(node *treeNode, dir string) (*metsDiv, error) {
+	div := &metsDiv{
+		Type:  string(node.NodeType),
+		Label: node.Name,
+	}
+
+	if len(node.Fields) > 0 || len(node.Metadata) > 0 {
+		dmdID := "DMD-NODE-" + node.ID
+		p.mets.DmdSecs = append(p.mets.DmdSecs, newMetsDmdSec(dmdID, string(node.NodeType), node.Metadata, node.Fields))
+		div.DmdID = dmdID
+	}
+
+	used := make(map[string]bool)
+
+	for _, file := range node.Files {
+		fileDiv, err := p.addFile(file, node, path.Join(dir, uniqueBagName(used, file.Name)))
+		if err != nil {
+			return nil, err
+		}
+		div.Divs = append(div.Divs, fileDiv)
+	}
+
+	for _, child := range node.Children {
+		childDir := path.Join(dir, uniqueBagName(used, child.Name))
+		if err := os.MkdirAll(filepath.Join(p.outDir, filepath.FromSlash(childDir)), 0755); err != nil {
+			return nil, serviceErrorf(errInternal, "failed to create directory %s: %v", childDir, err)
+		}
+
+		childDiv, err := p.addNode(child, childDir)
+		if err != nil {
+			return nil, err
+		}
+		div.Divs = append(div.Divs, childDiv)
+	}
+
+	return div, nil
+}
+
+// addFile kopierar en fil till paketet och beskriver den i METS och, för AIP, i PREMIS
+func (p *informationPackage) addFile(file *treeFile, node *treeNode, filePath string) (*metsDiv, error) {
+	if err := p.job.err(); err != nil {
+		return nil, err
+	}
+	if err := RecordFileAccess(p.ctx, p.db, file.ID, model.FileAccessTypeExport); err != nil {
+		return nil, err
+	}
+	data, err := readFileData(p.db, file.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	checksum, err := writeBagFile(p.outDir, filePath, data)
+	if err != nil {
+		return nil, err
+	}
+
+	metsFileID := "FILE-" + file.ID
+	entry := &metsFile{
+		ID:           metsFileID,
+		MimeType:     file.mimeType(),
+		Size:         len(data),
+		Created:      toXSDDateTime(file.CreatedAt),
+		Checksum:     checksum,
+		ChecksumType: "SHA-256",
+		FLocat: metsFLocat{
+			LocType:   "URL",
+			XlinkType: "simple",
+			Href:      filePath,
+		},
+	}
+
+	if len(file.Metadata) > 0 || len(file.Fields) > 0 {
+		entry.DmdID = "DMD-" + metsFileID
+		p.mets.DmdSecs = append(p.mets.DmdSecs, newMetsDmdSec(entry.DmdID, string(file.FileType), file.Metadata, file.Fields))
+	}
+
+	if p.premis != nil {
+		entry.AdmID = "AMD"
+		if err := p.addPremisObject(file, filePath, checksum, len(data)); err != nil {
+			return nil, err
+		}
+	}
+
+	p.mets.FileSec.FileGrp.Files = append(p.mets.FileSec.FileGrp.Files, entry)
+
+	p.processed++
+	if p.total > 0 {
+		p.job.setProgress(p.processed*90/p.total, fmt.Sprintf("Packaged %d of %d files", p.processed, p.total))
+	}
+
+	return &metsDiv{
+		Type:  string(file.FileType),
+		Label: file.Name,
+		Fptr:  &metsFptr{FileID: metsFileID},
+	}, nil
+}
+
+// addPremisObject utför en fixitetskontroll mot kontrollsumman från mottagandet, registrerar
+// händelsen och beskriver filen med alla dess bevarandehändelser i PREMIS
+func (p *informationPackage) addPremisObject(file *treeFile, filePath string, checksum string, size int) error {
+	intact, err := checkFileFixity(p.db, file, checksum, p.createdBy)
+	if err != nil {
+		return err
+	}
+	if !intact {
+		p.fixityErrs = append(p.fixityErrs, file.Name)
+	}
+
+	events, err := loadPreservationEvents(p.db, file.ID)
+	if err != nil {
+		return err
+	}
+
+	objectID := noarkSystemID("file", file.ID)
+	object := &premisObject{
+		XsiType:    "file",
+		Identifier: premisObjectIdentifier{Type: "UUID", Value: objectID},
+		Characteristics: premisCharacteristics{
+			CompositionLevel: 0,
+			Fixity: premisFixity{
+				Algorithm:  "SHA-256",
+				Digest:     checksum,
+				Originator: "e-Arkive",
+			},
+			Size:   size,
+			Format: premisFileFormat(file),
+		},
+		OriginalName: file.Name,
+		Storage: premisStorage{
+			LocationType:  "URL",
+			LocationValue: filePath,
+		},
+	}
+
+	for _, event := range events {
+		eventID := noarkSystemID("premis-event", event.ID)
+		agent := event.Agent
+		if agent == "" {
+			agent = "system"
+		}
+
+		premisEvt := &premisEvent{
+			IdentifierType:  "UUID",
+			IdentifierValue: eventID,
+			Type:            event.Type,
+			DateTime:        toXSDDateTime(event.Date),
+			Detail:          event.Detail,
+			Outcome:         premisEventOutcome{Outcome: event.Outcome},
+			LinkingAgent:    premisLinkingAgent{Type: "local", Value: agent},
+			LinkingObject:   premisLinkingObject{Type: "UUID", Value: objectID},
+		}
+		if event.Checksum != "" {
+			premisEvt.Outcome.Note = "SHA-256 " + event.Checksum
+		}
+
+		p.premis.Events = append(p.premis.Events, premisEvt)
+		object.LinkingEvents = append(object.LinkingEvents, premisLinkingEventRef{Type: "UUID", Value: eventID})
+
+		agentType := "person"
+		if agent == "system" {
+			agentType = "software"
+		}
+		p.addAgent(agent, agentType)
+	}
+
+	p.premis.Objects = append(p.premis.Objects, object)
+	return nil
+}
+
+// addAgent lägger till en agent i PREMIS en gång per namn
+func (p *informationPackage) addAgent(name string, agentType string) {
+	if p.agents[name] {
+		return
+	}
+	p.agents[name] = true
+	p.premis.Agents = append(p.premis.Agents, &premisAgent{
+		IdentifierType:  "local",
+		IdentifierValue: name,
+		Name:            name,
+		Type:            agentType,
+	})
+}
+
+// newMetsDmdSec skapar en beskrivande sektion med fri metadata och Noark 5-fält
+func newMetsDmdSec(id string, recordType string, metadata []*model.Metadata, fields map[string]string) *metsDmdSec {
+	record := metsRecord{Xmlns: recordNamespace, Type: recordType}
+
+	for _, meta := range metadata {
+		if meta != nil {
+			record.Fields = append(record.Fields, metsRecordField{Name: meta.Key, Source: "metadata", Value: meta.Value})
+		}
+	}
+
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		record.Fields = append(record.Fields, metsRecordField{Name: name, Source: "noark5", Value: fields[name]})
+	}
+
+	return &metsDmdSec{
+		ID: id,
+		MdWrap: metsMdWrap{
+			MdType:      "OTHER",
+			OtherMdType: "E-ARKIVE",
+			XMLData:     record,
+		},
+	}
+}
+
+// premisFileFormat beskriver filens format, med PRONOM-referens om formatet identifierats
+func premisFileFormat(file *treeFile) premisFormat {
+	format := file.format()
+	if format == nil {
+		return premisFormat{Name: file.ContentType}
+	}
+	return premisFormat{
+		Name:    format.Name,
+		Version: format.Version,
+		Registry: &premisFormatRegistry{
+			Name: "PRONOM",
+			Key:  format.PUID,
+			Role: "identification",
+		},
+	}
+}
+
+// writeXMLFile serialiserar ett dokument till en fil i paketet och returnerar dess SHA256-kontrollsumma
+func writeXMLFile(dir string, name string, document interface{}) (string, error) {
+	body, err := xml.MarshalIndent(document, "", "  ")
+	if err != nil {
+		return "", serviceErrorf(errInternal, "failed to encode %s: %v", name, err)
+	}
+	return writeBagFile(dir, name, append([]byte(xml.Header), body...))
+}
+
+// startInformationPackageJob kontrollerar noden och startar paketeringen som ett bakgrundsjobb
+func startInformationPackageJob(db *sql.DB, kind string, nodeID string, userID string) (*model.Job, error) {
+	if _, err := getNodeType(db, nodeID); err != nil {
+		return nil, err
+	}
+
+	var username string
+	if err := db.QueryRow("SELECT username FROM users WHERE id = ?", userID).Scan(&username); err != nil {
+		log.Printf("Error fetching username for user %s: %v", userID, err)
+		return nil, serviceErrorf(errInternal, "failed to fetch user: %v", err)
+	}
+
+	jobType := JOB_TYPE_AIP_EXPORT
+	if kind == packageTypeDIP {
+		jobType = JOB_TYPE_DIP_EXPORT
+	}
+
+	return enqueueJob(db, jobType, userID, informationPackagePayload{Kind: kind, NodeID: nodeID, CreatedBy: username}, nil)
+}
+
+// informationPackagePayload är parametrarna för ett paketeringsjobb
+type informationPackagePayload struct {
+	Kind      string `json:"kind"`
+	NodeID    string `json:"nodeId"`
+	CreatedBy string `json:"createdBy"`
+}
+
+// runInformationPackageJob bygger ett AIP eller DIP i en ny katalog för varje försök
+func runInformationPackageJob(job *jobContext) (string, error) {
+	var payload informationPackagePayload
+	if err := job.decodePayload(&payload); err != nil {
+		return "", err
+	}
+	return runInformationPackage(job.db, job, payload.Kind, payload.NodeID, payload.CreatedBy)
+}
@@ -0,0 +1,46 @@
+package graph
+
+// This file will be automatically regenerated based on the schema, any resolver implementations
+// will be copied through when generating and any unknown code will be moved to the end.
+// Code generated by github.com/99designs/gqlgen version v0.17.69
+
+import (
+	"context"
+	"fmt"
+	"graphql-backend/graph/model"
+	"log"
+)
+
+// StartAipExport is the resolver for the startAipExport field.
+func (r *mutationResolver) StartAipExport(ctx context.Context, nodeID string) (*model.Job, error) {
+	logAction(fmt.Sprintf("Starting AIP export of node %s", nodeID))
+
+	if r.DB == nil {
+		log.Printf("Database connection is nil")
+		return nil, serviceErrorf(errInternal, "internal server error: database connection is not initialized")
+	}
+
+	userID, err := requireAdministrator(ctx, r.DB, "create archival information packages")
+	if err != nil {
+		return nil, err
+	}
+
+	return startInformationPackageJob(r.DB, packageTypeAIP, nodeID, userID)
+}
+
+// StartDipExport is the resolver for the startDipExport field.
+func (r *mutationResolver) StartDipExport(ctx context.Context, nodeID string) (*model.Job, error) {
+	logAction(fmt.Sprintf("Starting DIP export of node %s", nodeID))
+
+	if r.DB == nil {
+		log.Printf("Database connection is nil")
+		return nil, serviceErrorf(errInternal, "internal server error: database connection is not initialized")
+	}
+
+	userID, err := requireAdministrator(ctx, r.DB, "create dissemination information packages")
+	if err != nil {
+		return nil, err
+	}
+
+	return startInformationPackageJob(r.DB, packageTypeDIP, nodeID, userID)
+}
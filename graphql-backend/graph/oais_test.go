@@ -0,0 +1,119 @@
+package graph
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// runTestPackage bygger ett informationspaket för noden och returnerar paketets katalog
+func runTestPackage(t *testing.T, db *sql.DB, kind string, nodeID string) (string, error) {
+	t.Helper()
+	result, err := runInformationPackage(db, testJobContext(t, db), kind, nodeID, "admin")
+	if err != nil {
+		return "", err
+	}
+	var pkg InformationPackageResult
+	if err := json.Unmarshal([]byte(result), &pkg); err != nil {
+		t.Fatal(err)
+	}
+	if pkg.Type != kind {
+		t.Errorf("package type = %s, want %s", pkg.Type, kind)
+	}
+	return pkg.Directory, nil
+}
+
+// readTestXML läser ett XML-dokument i paketet
+func readTestXML(t *testing.T, dir string, name string, v interface{}) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := xml.Unmarshal(data, v); err != nil {
+		t.Fatalf("decode %s: %v", name, err)
+	}
+	return string(data)
+}
+
+func TestInformationPackages(t *testing.T) {
+	db := openTestDB(t)
+	t.Chdir(t.TempDir())
+	root := createTestNode(t, db, "Arkiv", nil)
+	serie := createTestNode(t, db, "Serie", &root.ID)
+	saveTestFile(t, db, "brev.txt", root.ID, "brevet")
+	saveTestFile(t, db, "protokoll.txt", serie.ID, "protokollet")
+
+	aip, err := runTestPackage(t, db, packageTypeAIP, root.ID)
+	if err != nil {
+		t.Fatalf("create AIP: %v", err)
+	}
+	var mets metsDocument
+	readTestXML(t, aip, "METS.xml", &mets)
+	if mets.Type != packageTypeAIP || len(mets.FileSec.FileGrp.Files) != 2 || mets.FileSec.FileGrp.Use != "Original" {
+		t.Fatalf("METS = %s with %d files in %s", mets.Type, len(mets.FileSec.FileGrp.Files), mets.FileSec.FileGrp.Use)
+	}
+	data, err := os.ReadFile(filepath.Join(aip, "representations", "rep1", "data", "Serie", "protokoll.txt"))
+	if err != nil {
+		t.Fatalf("read packaged file: %v", err)
+	}
+	sum := sha256.Sum256(data)
+	found := false
+	for _, file := range mets.FileSec.FileGrp.Files {
+		found = found || file.Checksum == hex.EncodeToString(sum[:])
+	}
+	if !found {
+		t.Error("METS does not list the checksum of protokoll.txt")
+	}
+	if mets.StructMap.Div == nil || mets.StructMap.Div.Label != "Arkiv" || len(mets.StructMap.Div.Divs) != 2 {
+		t.Errorf("structMap = %+v", mets.StructMap.Div)
+	}
+
+	var premis premisDocument
+	body := readTestXML(t, aip, premisPackagePath, &premis)
+	if len(premis.Objects) != 2 {
+		t.Errorf("PREMIS objects = %d, want 2", len(premis.Objects))
+	}
+	// Paketeringen gör en fixitetskontroll av varje fil som registreras som bevarandehändelse
+	if !strings.Contains(body, "<eventType>"+PRESERVATION_EVENT_FIXITY+"</eventType>") {
+		t.Errorf("PREMIS has no fixity check event:\n%s", body)
+	}
+
+	dip, err := runTestPackage(t, db, packageTypeDIP, serie.ID)
+	if err != nil {
+		t.Fatalf("create DIP: %v", err)
+	}
+	var dipMets metsDocument
+	readTestXML(t, dip, "METS.xml", &dipMets)
+	if dipMets.FileSec.FileGrp.Use != "Access" || len(dipMets.FileSec.FileGrp.Files) != 1 || dipMets.AmdSec != nil {
+		t.Errorf("DIP METS = %+v", dipMets.FileSec)
+	}
+	if _, err := os.Stat(filepath.Join(dip, "objects", "protokoll.txt")); err != nil {
+		t.Errorf("DIP file: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dip, filepath.FromSlash(premisPackagePath))); !os.IsNotExist(err) {
+		t.Errorf("DIP contains PREMIS: %v", err)
+	}
+}
+
+func TestInformationPackageFailures(t *testing.T) {
+	db := openTestDB(t)
+	t.Chdir(t.TempDir())
+	empty := createTestNode(t, db, "Tom", nil)
+	if _, err := runTestPackage(t, db, packageTypeAIP, empty.ID); err == nil || !strings.Contains(err.Error(), "contains no files") {
+		t.Errorf("package empty node: err = %v", err)
+	}
+
+	node := createTestNode(t, db, "Skadad", nil)
+	file := saveTestFile(t, db, "brev.txt", node.ID, "brevet")
+	mustExec(t, db, "UPDATE files SET file_data = ? WHERE id = ?", []byte("ändrat"), file.ID)
+	if _, err := runTestPackage(t, db, packageTypeAIP, node.ID); err == nil || !strings.Contains(err.Error(), "fixity check failed for 1 files: brev.txt") {
+		t.Errorf("package damaged file: err = %v", err)
+	}
+}
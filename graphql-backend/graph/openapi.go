@@ -0,0 +1,221 @@
+package graph
+
+import (
+	"fmt"
+	"graphql-backend/graph/model"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// =============================================
+// ========== OPENAPI =========================
+// =============================================
+
+// openAPIEnums är de tillåtna värdena för uppräkningstyperna som används i REST-API:t
+var openAPIEnums = map[reflect.Type][]string{
+	reflect.TypeOf(model.NodeType("")):          enumStrings(model.AllNodeType),
+	reflect.TypeOf(model.FileType("")):          enumStrings(model.AllFileType),
+	reflect.TypeOf(model.MetadataValueType("")): enumStrings(model.AllMetadataValueType),
+}
+
+func enumStrings[T ~string](values []T) []string {
+	result := make([]string, len(values))
+	for i, value := range values {
+		result[i] = string(value)
+	}
+	return result
+}
+
+// openAPISchemas samlar de namngivna scheman som dokumentets operationer refererar till
+type openAPISchemas map[string]interface{}
+
+// openAPIDocument beskriver REST-API:t som ett OpenAPI 3-dokument. Scheman härleds från Go-typerna
+// som anropen avkodas till och svaren kodas från, så dokumentet kan inte glida isär från koden.
+func openAPIDocument(routes []restRoute) map[string]interface{} {
+	schemas := openAPISchemas{}
+	errorSchema := schemas.schema(reflect.TypeOf(restError{}))
+
+	paths := map[string]map[string]interface{}{}
+	for _, route := range routes {
+		path := strings.TrimSuffix(RestAPIPath, "/") + "/" + route.pattern
+		if paths[path] == nil {
+			paths[path] = map[string]interface{}{}
+		}
+
+		operation := map[string]interface{}{
+			"operationId": route.operationID,
+			"summary":     route.summary,
+			"description": fmt.Sprintf("Motsvarar GraphQL-fältet `%s`.", route.field),
+			"tags":        []string{route.tag},
+		}
+		if route.public {
+			operation["security"] = []interface{}{}
+		}
+
+		var parameters []interface{}
+		if strings.Contains(route.pattern, "{id}") {
+			parameters = append(parameters, map[string]interface{}{
+				"name":     "id",
+				"in":       "path",
+				"required": true,
+				"schema":   map[string]interface{}{"type": "string"},
+			})
+		}
+		for _, param := range route.query {
+			schema := map[string]interface{}{"type": "string"}
+			if param.repeated {
+				schema = map[string]interface{}{"type": "array", "items": schema}
+			}
+			parameters = append(parameters, map[string]interface{}{
+				"name":        param.name,
+				"in":          "query",
+				"description": param.description,
+				"required":    param.required,
+				"schema":      schema,
+			})
+		}
+		if parameters != nil {
+			operation["parameters"] = parameters
+		}
+
+		if route.body != nil {
+			operation["requestBody"] = map[string]interface{}{
+				"required": true,
+				"content": map[string]interface{}{
+					"application/json": map[string]interface{}{"schema": schemas.schema(reflect.TypeOf(route.body))},
+				},
+			}
+		}
+
+		success := map[string]interface{}{"description": http.StatusText(route.status)}
+		switch route.response.(type) {
+		case nil:
+		case *restContent:
+			success["content"] = map[string]interface{}{
+				"application/octet-stream": map[string]interface{}{
+					"schema": map[string]interface{}{"type": "string", "format": "binary"},
+				},
+			}
+		default:
+			success["content"] = map[string]interface{}{
+				"application/json": map[string]interface{}{"schema": schemas.schema(reflect.TypeOf(route.response))},
+			}
+		}
+		operation["responses"] = map[string]interface{}{
+			strconv.Itoa(route.status): success,
+			"default": map[string]interface{}{
+				"description": "Felet från resolvern. Status 400, 401, 403, 404, 409 eller 500 beroende på felet.",
+				"content": map[string]interface{}{
+					"application/json": map[string]interface{}{"schema": errorSchema},
+				},
+			},
+		}
+
+		paths[path][strings.ToLower(route.method)] = operation
+	}
+
+	paths[RestAPIPath+"openapi.json"] = map[string]interface{}{
+		"get": map[string]interface{}{
+			"operationId": "getOpenAPIDocument",
+			"summary":     "Hämta det här dokumentet",
+			"tags":        []string{"meta"},
+			"security":    []interface{}{},
+			"responses": map[string]interface{}{
+				"200": map[string]interface{}{
+					"description": "OpenAPI-dokumentet",
+					"content":     map[string]interface{}{"application/json": map[string]interface{}{"schema": map[string]interface{}{"type": "object"}}},
+				},
+			},
+		},
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":       "e-Arkive REST API",
+			"version":     "1.0.0",
+			"description": "Versionerat REST-API för noder, filer och användare. Operationerna anropar samma resolvers som GraphQL-API:t och beter sig likadant.",
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": schemas,
+			"securitySchemes": map[string]interface{}{
+				"bearerAuth": map[string]interface{}{"type": "http", "scheme": "bearer", "bearerFormat": "JWT"},
+			},
+		},
+		"security": []interface{}{map[string]interface{}{"bearerAuth": []string{}}},
+	}
+}
+
+// schema ger schemat för en Go-typ. Strukturer läggs i components och refereras med $ref.
+func (s openAPISchemas) schema(t reflect.Type) map[string]interface{} {
+	if t.Kind() == reflect.Ptr {
+		schema := s.schema(t.Elem())
+		if _, isRef := schema["$ref"]; isRef {
+			// I OpenAPI 3.0 kan $ref inte kombineras med nullable direkt
+			return map[string]interface{}{"allOf": []interface{}{schema}, "nullable": true}
+		}
+		schema["nullable"] = true
+		return schema
+	}
+
+	if values, ok := openAPIEnums[t]; ok {
+		return map[string]interface{}{"type": "string", "enum": values}
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		name := openAPISchemaName(t)
+		if _, exists := s[name]; !exists {
+			s[name] = nil // Reservera namnet innan fälten beskrivs, så att rekursiva typer fungerar
+			s[name] = s.object(t)
+		}
+		return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+	case reflect.Slice:
+		return map[string]interface{}{"type": "array", "items": s.schema(t.Elem())}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	default:
+		return map[string]interface{}{}
+	}
+}
+
+// object beskriver en strukturs fält. Obligatoriska fält väljs som när anropen avkodas.
+func (s openAPISchemas) object(t reflect.Type) map[string]interface{} {
+	properties := map[string]interface{}{}
+	var required []string
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, isRequired := restJSONField(field)
+		if name == "" {
+			continue
+		}
+		properties[name] = s.schema(field.Type)
+		if isRequired {
+			required = append(required, name)
+		}
+	}
+
+	object := map[string]interface{}{"type": "object", "properties": properties}
+	if required != nil {
+		object["required"] = required
+	}
+	return object
+}
+
+// openAPISchemaName ger schemanamnet för en struktur, t.ex. Node för restNode
+func openAPISchemaName(t reflect.Type) string {
+	name := t.Name()
+	if trimmed := strings.TrimPrefix(name, "rest"); trimmed != name {
+		return strings.ToUpper(trimmed[:1]) + trimmed[1:]
+	}
+	return name
+}
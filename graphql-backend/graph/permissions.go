@@ -21,39 +21,7 @@ type groupResolver struct{ *Resolver }
 
 // Members resolver for Group type
 func (r *groupResolver) Members(ctx context.Context, obj *model.Group) ([]*model.User, error) {
-	logAction(fmt.Sprintf("Fetching members for group ID: %s", obj.ID))
-
-	// Query members of the group
-	rows, err := r.DB.Query(`
-		SELECT u.id, u.username
-		FROM users u
-		JOIN group_members gm ON u.id = gm.user_id
-		WHERE gm.group_id = ?
-		ORDER BY u.username ASC
-	`, obj.ID)
-	if err != nil {
-		log.Printf("Error fetching group members: %v", err)
-		return nil, fmt.Errorf("failed to fetch group members: %v", err)
-	}
-	defer rows.Close()
-
-	var members []*model.User
-	for rows.Next() {
-		var user model.User
-		if err := rows.Scan(&user.ID, &user.Username); err != nil {
-			log.Printf("Error scanning user row: %v", err)
-			return nil, fmt.Errorf("failed to scan user row: %v", err)
-		}
-		user.Name = user.Username // Using username as name for simplicity
-		members = append(members, &user)
-	}
-
-	if err := rows.Err(); err != nil {
-		log.Printf("Error iterating over member rows: %v", err)
-		return nil, fmt.Errorf("failed to iterate over member rows: %v", err)
-	}
-
-	return members, nil
+	return r.Groups.Members(ctx, obj.ID)
 }
 
 // OwnerUser resolver for Node type
@@ -75,7 +43,7 @@ func (r *nodeResolver) OwnerUser(ctx context.Context, obj *model.Node) (*model.U
 		return nil, nil
 	} else if err != nil {
 		log.Printf("Error fetching owner user: %v", err)
-		return nil, fmt.Errorf("failed to fetch owner user: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to fetch owner user: %v", err)
 	}
 
 	user.Name = user.Username // Using username as name for simplicity
@@ -101,7 +69,7 @@ func (r *nodeResolver) OwnerGroup(ctx context.Context, obj *model.Node) (*model.
 		return nil, nil
 	} else if err != nil {
 		log.Printf("Error fetching owner group: %v", err)
-		return nil, fmt.Errorf("failed to fetch owner group: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to fetch owner group: %v", err)
 	}
 
 	return &group, nil
@@ -134,13 +102,53 @@ func (r *userResolver) Groups(ctx context.Context, obj *model.User) ([]*model.Gr
 
 		if err != nil {
 			log.Printf("Error checking admin status: %v", err)
-			return nil, fmt.Errorf("failed to check administrator status: %v", err)
+			return nil, serviceErrorf(errInternal, "failed to check administrator status: %v", err)
 		}
 
 		if !isAdmin {
-			return nil, fmt.Errorf("permission denied: can only view your own groups")
+			return nil, serviceErrorf(errPermissionDenied, "permission denied: can only view your own groups")
 		}
 	}
 
 	return getUserGroups(ctx, r.DB, obj.ID)
 }
+
+// isAdministrator kontrollerar om en användare är medlem i gruppen Administrators
+func isAdministrator(db *sql.DB, userID string) (bool, error) {
+	var isAdmin bool
+	err := db.QueryRow(`
+		SELECT EXISTS(
+			SELECT 1 
+			FROM group_members gm
+			JOIN groups g ON gm.group_id = g.id
+			WHERE gm.user_id = ? AND g.name = 'Administrators'
+		)
+	`, userID).Scan(&isAdmin)
+
+	if err != nil {
+		log.Printf("Error checking admin status: %v", err)
+		return false, serviceErrorf(errInternal, "failed to check administrator status: %v", err)
+	}
+
+	return isAdmin, nil
+}
+
+// requireAdministrator hämtar användaren från context och kräver att den är administratör.
+// Returnerar användarens ID om kontrollen lyckas.
+func requireAdministrator(ctx context.Context, db *sql.DB, action string) (string, error) {
+	userID, err := getUserIDFromContext(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	isAdmin, err := isAdministrator(db, userID)
+	if err != nil {
+		return "", err
+	}
+
+	if !isAdmin {
+		return "", serviceErrorf(errPermissionDenied, "permission denied: must be an administrator to %s", action)
+	}
+
+	return userID, nil
+}
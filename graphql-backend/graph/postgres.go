@@ -0,0 +1,240 @@
+package graph
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/stdlib"
+)
+
+// =============================================
+// ========== POSTGRESQL-DRIVRUTIN ============
+// =============================================
+
+// Frågorna i resolvers och tjänster skrivs för SQLite. Drivrutinen här ligger ovanpå pgx och
+// skriver om dem för PostgreSQL när de körs, så att samma frågor fungerar mot båda dialekterna:
+//   - ?-parametrar blir $1, $2, ...
+//   - datetime('now') blir motsvarande tidsstämpel i UTC
+//   - parametrar skickas som text och tolkas av databasen efter kolumnens typ, på samma sätt som
+//     SQLite tar emot t.ex. ett ID som sträng i en heltalskolumn. Sanningsvärden blir 1 och 0,
+//     eftersom flaggor lagras som heltal.
+//   - INSERT i tabeller med löpnummer får RETURNING id, så att LastInsertId fungerar
+
+// postgresDriverName är namnet som drivrutinen registreras under i database/sql
+const postgresDriverName = "earkive-postgres"
+
+// postgresNow motsvarar SQLite:s datetime('now'): tiden i UTC som "YYYY-MM-DD HH:MM:SS"
+const postgresNow = "to_char(timezone('UTC', now()), 'YYYY-MM-DD HH24:MI:SS')"
+
+// postgresTimeFormat är formatet som time.Time-parametrar skrivs i, samma som SQLite-drivrutinen använder
+const postgresTimeFormat = "2006-01-02 15:04:05.999999999-07:00"
+
+// postgresInsertPattern hittar tabellen i en INSERT-sats
+var postgresInsertPattern = regexp.MustCompile(`(?is)^\s*INSERT\s+INTO\s+"?([a-z_][a-z0-9_]*)"?`)
+
+// postgresSerialTables minns vilka tabeller som har ett id med löpnummer
+var postgresSerialTables sync.Map
+
+func init() {
+	sql.Register(postgresDriverName, postgresDriver{})
+}
+
+// postgresDriver öppnar pgx-anslutningar som skriver om frågorna
+type postgresDriver struct{}
+
+func (postgresDriver) Open(dsn string) (driver.Conn, error) {
+	conn, err := stdlib.GetDefaultDriver().Open(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return &postgresConn{Conn: conn.(*stdlib.Conn)}, nil
+}
+
+// postgresConn är en pgx-anslutning som skriver om frågor och parametrar innan de skickas
+type postgresConn struct {
+	*stdlib.Conn
+}
+
+// CheckNamedValue skickar parametrarna som text, se kommentaren överst i filen
+func (c *postgresConn) CheckNamedValue(nv *driver.NamedValue) error {
+	value, err := driver.DefaultParameterConverter.ConvertValue(nv.Value)
+	if err != nil {
+		return err
+	}
+
+	switch v := value.(type) {
+	case bool:
+		if v {
+			nv.Value = "1"
+		} else {
+			nv.Value = "0"
+		}
+	case int64:
+		nv.Value = strconv.FormatInt(v, 10)
+	case float64:
+		nv.Value = strconv.FormatFloat(v, 'g', -1, 64)
+	case time.Time:
+		nv.Value = v.Format(postgresTimeFormat)
+	default:
+		nv.Value = value
+	}
+	return nil
+}
+
+func (c *postgresConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	return c.Conn.PrepareContext(ctx, rebindPostgres(query))
+}
+
+func (c *postgresConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	return c.Conn.QueryContext(ctx, rebindPostgres(query), args)
+}
+
+func (c *postgresConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	query = rebindPostgres(query)
+
+	table, ok := postgresInsertTable(query)
+	if !ok {
+		return c.Conn.ExecContext(ctx, query, args)
+	}
+	serial, err := c.hasSerialID(ctx, table)
+	if err != nil {
+		return nil, err
+	}
+	if !serial {
+		return c.Conn.ExecContext(ctx, query, args)
+	}
+
+	// Kör satsen med RETURNING id och räkna raderna, så att resultatet motsvarar SQLite:s
+	rows, err := c.Conn.QueryContext(ctx, strings.TrimRight(query, "; \t\r\n")+" RETURNING id", args)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result postgresResult
+	dest := make([]driver.Value, len(rows.Columns()))
+	for {
+		if err := rows.Next(dest); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		result.rowsAffected++
+		if id, err := strconv.ParseInt(fmt.Sprint(dest[0]), 10, 64); err == nil {
+			result.lastInsertID = id
+		}
+	}
+	return result, nil
+}
+
+// hasSerialID avgör om tabellen har en id-kolumn med löpnummer
+func (c *postgresConn) hasSerialID(ctx context.Context, table string) (bool, error) {
+	if serial, ok := postgresSerialTables.Load(table); ok {
+		return serial.(bool), nil
+	}
+
+	rows, err := c.Conn.QueryContext(ctx, `
+		SELECT COUNT(*) FROM information_schema.columns
+		WHERE table_schema = current_schema() AND table_name = $1 AND column_name = 'id'
+			AND column_default LIKE 'nextval(%'
+	`, []driver.NamedValue{{Ordinal: 1, Value: table}})
+	if err != nil {
+		return false, serviceErrorf(errInternal, "failed to look up id column of %s: %v", table, err)
+	}
+	defer rows.Close()
+
+	dest := make([]driver.Value, 1)
+	if err := rows.Next(dest); err != nil {
+		return false, serviceErrorf(errInternal, "failed to look up id column of %s: %v", table, err)
+	}
+	serial := fmt.Sprint(dest[0]) != "0"
+	postgresSerialTables.Store(table, serial)
+	return serial, nil
+}
+
+// postgresResult är resultatet av en INSERT som körts med RETURNING id
+type postgresResult struct {
+	lastInsertID int64
+	rowsAffected int64
+}
+
+func (r postgresResult) LastInsertId() (int64, error) {
+	return r.lastInsertID, nil
+}
+
+func (r postgresResult) RowsAffected() (int64, error) {
+	return r.rowsAffected, nil
+}
+
+// postgresInsertTable ger tabellen för en enskild INSERT-sats utan egen RETURNING. Skript med
+// flera satser, som migreringarna, körs oförändrade.
+func postgresInsertTable(query string) (string, bool) {
+	match := postgresInsertPattern.FindStringSubmatch(query)
+	if match == nil {
+		return "", false
+	}
+	body := strings.TrimRight(query, "; \t\r\n")
+	if strings.Contains(body, ";") || strings.Contains(strings.ToUpper(body), "RETURNING") {
+		return "", false
+	}
+	return strings.ToLower(match[1]), true
+}
+
+// rebindPostgres skriver om en fråga skriven för SQLite till PostgreSQL. Strängar, citerade namn
+// och kommentarer lämnas orörda.
+func rebindPostgres(query string) string {
+	query = strings.ReplaceAll(query, "datetime('now')", postgresNow)
+
+	var sb strings.Builder
+	sb.Grow(len(query) + 8)
+	param := 0
+	for i := 0; i < len(query); {
+		c := query[i]
+		var end int
+		switch {
+		case c == '\'' || c == '"':
+			// En dubblerad citattecken inuti strängen läses som två strängar efter varandra
+			end = strings.IndexByte(query[i+1:], c)
+			if end < 0 {
+				end = len(query)
+			} else {
+				end += i + 2
+			}
+		case c == '-' && strings.HasPrefix(query[i:], "--"):
+			end = strings.IndexByte(query[i:], '\n')
+			if end < 0 {
+				end = len(query)
+			} else {
+				end += i + 1
+			}
+		case c == '/' && strings.HasPrefix(query[i:], "/*"):
+			end = strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				end = len(query)
+			} else {
+				end += i + 4
+			}
+		case c == '?':
+			param++
+			sb.WriteByte('$')
+			sb.WriteString(strconv.Itoa(param))
+			i++
+			continue
+		default:
+			sb.WriteByte(c)
+			i++
+			continue
+		}
+		sb.WriteString(query[i:end])
+		i = end
+	}
+	return sb.String()
+}
@@ -0,0 +1,108 @@
+package graph
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"log"
+	"time"
+)
+
+// =============================================
+// ========== BEVARANDEHÄNDELSER (PREMIS) ====
+// =============================================
+
+// Händelsetyper enligt PREMIS eventType-vokabulären
+const (
+	PRESERVATION_EVENT_INGEST                = "ingestion"
+	PRESERVATION_EVENT_FIXITY                = "fixity check"
+	PRESERVATION_EVENT_MIGRATION             = "migration"
+	PRESERVATION_EVENT_FORMAT_IDENTIFICATION = "format identification"
+)
+
+// Utfall för bevarandehändelser
+const (
+	PRESERVATION_OUTCOME_SUCCESS = "success"
+	PRESERVATION_OUTCOME_FAILURE = "failure"
+)
+
+// preservationEvent är en registrerad bevarandehändelse för en fil
+type preservationEvent struct {
+	ID       string
+	FileID   string
+	Type     string
+	Date     string
+	Outcome  string
+	Detail   string
+	Agent    string
+	Checksum string // Kontrollsumma som händelsen avser, om någon
+}
+
+// recordPreservationEvent sparar en bevarandehändelse för en fil
+func recordPreservationEvent(db sqlExecer, fileID string, eventType string, outcome string, detail string, agent string, checksum string) error {
+	_, err := db.Exec(`
+		INSERT INTO preservation_events (file_id, event_type, event_date, outcome, detail, agent, checksum)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, fileID, eventType, time.Now().Format(time.RFC3339), outcome, detail, agent, checksum)
+	if err != nil {
+		log.Printf("Error recording %s event for file %s: %v", eventType, fileID, err)
+		return serviceErrorf(errInternal, "failed to record preservation event: %v", err)
+	}
+	return nil
+}
+
+// loadPreservationEvents hämtar alla bevarandehändelser för en fil i tidsordning
+func loadPreservationEvents(db *sql.DB, fileID string) ([]*preservationEvent, error) {
+	rows, err := db.Query(`
+		SELECT id, file_id, event_type, event_date, outcome, detail, agent, checksum
+		FROM preservation_events
+		WHERE file_id = ?
+		ORDER BY id ASC
+	`, fileID)
+	if err != nil {
+		log.Printf("Error fetching preservation events for file %s: %v", fileID, err)
+		return nil, serviceErrorf(errInternal, "failed to fetch preservation events: %v", err)
+	}
+	defer rows.Close()
+
+	var events []*preservationEvent
+	for rows.Next() {
+		var event preservationEvent
+		var detail, agent, checksum sql.NullString
+		if err := rows.Scan(&event.ID, &event.FileID, &event.Type, &event.Date, &event.Outcome, &detail, &agent, &checksum); err != nil {
+			log.Printf("Error scanning preservation event: %v", err)
+			return nil, serviceErrorf(errInternal, "failed to scan preservation event: %v", err)
+		}
+		event.Detail = detail.String
+		event.Agent = agent.String
+		event.Checksum = checksum.String
+		events = append(events, &event)
+	}
+
+	return events, rows.Err()
+}
+
+// checksumSHA256 beräknar SHA256-kontrollsumman för filinnehåll
+func checksumSHA256(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// eventAgent returnerar användarnamnet från JWT-token, eller "system" om ingen är inloggad
+func eventAgent(ctx context.Context) string {
+	token, ok := GetAuthToken(ctx)
+	if !ok {
+		return "system"
+	}
+
+	claims, err := validateJWT(token)
+	if err != nil {
+		return "system"
+	}
+
+	if username, ok := claims["username"].(string); ok && username != "" {
+		return username
+	}
+	return "system"
+}
@@ -23,28 +23,17 @@ import (
 
 // Resolver är den huvudsakliga resolver-typen för GraphQL
 // Hantera alla grafrelaterade funktioner och databasanslutning
+// Noder, filer, användare och grupper hanteras av tjänsterna i Services
 type Resolver struct {
 	DB *sql.DB
+	*Services
 }
 
 // authTokenKey används för att lagra JWT token i context
 type authTokenKey struct{}
 
-// NodeResolver interface definition
-type NodeResolver interface {
-	Children(ctx context.Context, obj *model.Node) ([]*model.Node, error)
-	Parent(ctx context.Context, obj *model.Node) (*model.Node, error)
-	Files(ctx context.Context, obj *model.Node) ([]*model.File, error)
-}
-
-// FileResolver interface definition
-type FileResolver interface {
-	Node(ctx context.Context, obj *model.File) (*model.Node, error)
-}
-
 // Resolver implementations
-type nodeResolver struct{ *Resolver }
-type fileResolver struct{ *Resolver }
+// nodeResolver och fileResolver genereras av gqlgen
 type userResolver struct{ *Resolver }
 
 // =============================================
@@ -75,7 +64,7 @@ var tokenBlacklistMutex sync.Mutex
 
 // NewResolver skapar en ny resolver med en databasanslutning
 func NewResolver(db *sql.DB) *Resolver {
-	return &Resolver{DB: db}
+	return &Resolver{DB: db, Services: NewServices(db)}
 }
 
 // =============================================
@@ -104,7 +93,7 @@ func getUserGroups(ctx context.Context, db *sql.DB, userID string) ([]*model.Gro
 	`, userID)
 	if err != nil {
 		log.Printf("Error fetching user groups: %v", err)
-		return nil, fmt.Errorf("failed to fetch user groups: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to fetch user groups: %v", err)
 	}
 	defer rows.Close()
 
@@ -113,14 +102,14 @@ func getUserGroups(ctx context.Context, db *sql.DB, userID string) ([]*model.Gro
 		var group model.Group
 		if err := rows.Scan(&group.ID, &group.Name); err != nil {
 			log.Printf("Error scanning group row: %v", err)
-			return nil, fmt.Errorf("failed to scan group row: %v", err)
+			return nil, serviceErrorf(errInternal, "failed to scan group row: %v", err)
 		}
 		groups = append(groups, &group)
 	}
 
 	if err := rows.Err(); err != nil {
 		log.Printf("Error iterating over group rows: %v", err)
-		return nil, fmt.Errorf("failed to iterate over group rows: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to iterate over group rows: %v", err)
 	}
 
 	return groups, nil
@@ -147,7 +136,7 @@ func checkPermission(ctx context.Context, db *sql.DB, nodeID string, permissionB
 
 	if err != nil {
 		log.Printf("Error checking admin status: %v", err)
-		return false, fmt.Errorf("failed to check administrator status: %v", err)
+		return false, serviceErrorf(errInternal, "failed to check administrator status: %v", err)
 	}
 
 	if isAdmin {
@@ -167,7 +156,7 @@ func checkPermission(ctx context.Context, db *sql.DB, nodeID string, permissionB
 
 	if err != nil {
 		log.Printf("Error checking node ownership permission: %v", err)
-		return false, fmt.Errorf("failed to check node ownership: %v", err)
+		return false, serviceErrorf(errInternal, "failed to check node ownership: %v", err)
 	}
 
 	if hasPermission {
@@ -186,7 +175,7 @@ func checkPermission(ctx context.Context, db *sql.DB, nodeID string, permissionB
 
 	if err != nil {
 		log.Printf("Error checking group permission: %v", err)
-		return false, fmt.Errorf("failed to check group permission: %v", err)
+		return false, serviceErrorf(errInternal, "failed to check group permission: %v", err)
 	}
 
 	return hasPermission, nil
@@ -195,7 +184,7 @@ func checkPermission(ctx context.Context, db *sql.DB, nodeID string, permissionB
 // getNodeWithPermissions fetches a node and checks if the user has permission to view it
 func getNodeWithPermissions(ctx context.Context, db *sql.DB, id string) (*model.Node, error) {
 	if db == nil {
-		return nil, fmt.Errorf("database connection is not initialized")
+		return nil, serviceErrorf(errInternal, "database connection is not initialized")
 	}
 
 	// First check if the user has permission to view this node
@@ -205,7 +194,7 @@ func getNodeWithPermissions(ctx context.Context, db *sql.DB, id string) (*model.
 	}
 
 	if !hasPermission {
-		return nil, fmt.Errorf("permission denied: cannot view this node")
+		return nil, serviceErrorf(errPermissionDenied, "permission denied: cannot view this node")
 	}
 
 	// Query the node with ownership and permission information
@@ -225,10 +214,10 @@ func getNodeWithPermissions(ctx context.Context, db *sql.DB, id string) (*model.
 
 	if err == sql.ErrNoRows {
 		log.Printf("Node with ID %s not found", id)
-		return nil, fmt.Errorf("node not found")
+		return nil, serviceErrorf(errNotFound, "node not found")
 	} else if err != nil {
 		log.Printf("Error fetching node with ID %s: %v", id, err)
-		return nil, fmt.Errorf("failed to fetch node: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to fetch node: %v", err)
 	}
 
 	// Set nullable fields
@@ -253,7 +242,7 @@ func getNodeWithPermissions(ctx context.Context, db *sql.DB, id string) (*model.
 // getChildNodesWithPermissions fetches all child nodes of a parent that the user has permission to view
 func getChildNodesWithPermissions(ctx context.Context, db *sql.DB, parentID string) ([]*model.Node, error) {
 	if db == nil {
-		return nil, fmt.Errorf("database connection is not initialized")
+		return nil, serviceErrorf(errInternal, "database connection is not initialized")
 	}
 
 	// First check if the parent node exists
@@ -261,12 +250,12 @@ func getChildNodesWithPermissions(ctx context.Context, db *sql.DB, parentID stri
 	err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM nodes WHERE id = ?)", parentID).Scan(&exists)
 	if err != nil {
 		log.Printf("Error checking if parent node exists: %v", err)
-		return nil, fmt.Errorf("failed to check if parent node exists: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to check if parent node exists: %v", err)
 	}
 
 	if !exists {
 		log.Printf("Parent node with ID %s does not exist", parentID)
-		return nil, fmt.Errorf("parent node not found")
+		return nil, serviceErrorf(errNotFound, "parent node not found")
 	}
 
 	// Get the user ID from context
@@ -288,7 +277,7 @@ func getChildNodesWithPermissions(ctx context.Context, db *sql.DB, parentID stri
 
 	if err != nil {
 		log.Printf("Error checking admin status: %v", err)
-		return nil, fmt.Errorf("failed to check administrator status: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to check administrator status: %v", err)
 	}
 
 	var rows *sql.Rows
@@ -320,7 +309,7 @@ func getChildNodesWithPermissions(ctx context.Context, db *sql.DB, parentID stri
 
 	if err != nil {
 		log.Printf("Error fetching child nodes: %v", err)
-		return nil, fmt.Errorf("failed to fetch child nodes: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to fetch child nodes: %v", err)
 	}
 	defer rows.Close()
 
@@ -336,7 +325,7 @@ func getChildNodesWithPermissions(ctx context.Context, db *sql.DB, parentID stri
 
 		if err != nil {
 			log.Printf("Error scanning node: %v", err)
-			return nil, fmt.Errorf("failed to process node data: %v", err)
+			return nil, serviceErrorf(errInternal, "failed to process node data: %v", err)
 		}
 
 		if pID.Valid {
@@ -385,7 +374,7 @@ func scanNodeRows(rows *sql.Rows) ([]*model.Node, error) {
 
 		if err := rows.Scan(&node.ID, &node.Name, &parentID, &createdAt, &updatedAt); err != nil {
 			log.Printf("Error scanning node row: %v", err)
-			return nil, fmt.Errorf("failed to scan node row: %v", err)
+			return nil, serviceErrorf(errInternal, "failed to scan node row: %v", err)
 		}
 
 		node.CreatedAt = createdAt
@@ -400,138 +389,12 @@ func scanNodeRows(rows *sql.Rows) ([]*model.Node, error) {
 
 	if err := rows.Err(); err != nil {
 		log.Printf("Error iterating over node rows: %v", err)
-		return nil, fmt.Errorf("failed to iterate over node rows: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to iterate over node rows: %v", err)
 	}
 
 	return nodes, nil
 }
 
-// detectCycle checks if updating a node's parent would create a cycle in the node hierarchy
-func (r *Resolver) detectCycle(nodeID string, newParentID string, isCycle *bool) error {
-	*isCycle = false
-
-	// If the new parent is the same as the node itself, it's a cycle
-	if nodeID == newParentID {
-		*isCycle = true
-		return nil
-	}
-
-	// Check if any ancestors of the potential parent are actually the node we're moving
-	currentID := newParentID
-	for currentID != "" {
-		// If currentID ever becomes the node we're moving, we have a cycle
-		if currentID == nodeID {
-			*isCycle = true
-			return nil
-		}
-
-		// Get parent of current node
-		var parentID sql.NullString
-		err := r.DB.QueryRow("SELECT parent_id FROM nodes WHERE id = ?", currentID).Scan(&parentID)
-		if err == sql.ErrNoRows {
-			// Node not found, break the loop
-			break
-		} else if err != nil {
-			return fmt.Errorf("error checking hierarchy: %v", err)
-		}
-
-		// If no parent, break the loop
-		if !parentID.Valid {
-			break
-		}
-
-		// Move up the hierarchy
-		currentID = parentID.String
-	}
-
-	return nil
-}
-
-// =============================================
-// ========== FILE FUNKTIONER ===============
-// =============================================
-
-// Implementering av GetFilesByNodeId för queryResolver
-func (r *queryResolver) GetFilesByNodeId(ctx context.Context, nodeID string) ([]*model.File, error) {
-	logAction(fmt.Sprintf("Fetching files for node ID: %s", nodeID))
-
-	if r.DB == nil {
-		log.Printf("Database connection is nil")
-		return nil, fmt.Errorf("internal server error: database connection is not initialized")
-	}
-
-	// First check if user has permission to view this node
-	hasPermission, err := checkPermission(ctx, r.DB, nodeID, PERM_VIEW)
-	if err != nil {
-		return nil, err
-	}
-
-	if !hasPermission {
-		return nil, fmt.Errorf("permission denied: cannot view files in this node")
-	}
-
-	// Query files for this specific node
-	rows, err := r.DB.Query(`
-		SELECT id, name, size, content_type, created_at, file_data
-		FROM files
-		WHERE node_id = ?
-		ORDER BY name ASC
-	`, nodeID)
-	if err != nil {
-		log.Printf("Error fetching files for node ID %s: %v", nodeID, err)
-		return nil, fmt.Errorf("failed to fetch files: %v", err)
-	}
-	defer rows.Close()
-
-	var files []*model.File
-	for rows.Next() {
-		var file model.File
-		var createdAt string
-		var fileData []byte
-		if err := rows.Scan(&file.ID, &file.Name, &file.Size, &file.ContentType, &createdAt, &fileData); err != nil {
-			log.Printf("Error scanning file row: %v", err)
-			return nil, fmt.Errorf("failed to scan file row: %v", err)
-		}
-		file.CreatedAt = createdAt
-
-		// Don't send file data in listing, only metadata
-		// We'll only include the file data when explicitly downloading the file
-
-		// Set the nodeId on the file
-		file.NodeID = &nodeID
-
-		// Fetch metadata for each file
-		metaRows, err := r.DB.Query("SELECT key, value FROM metadata WHERE file_id = ?", file.ID)
-		if err != nil {
-			log.Printf("Error fetching metadata for file ID %s: %v", file.ID, err)
-			return nil, fmt.Errorf("failed to fetch metadata: %v", err)
-		}
-
-		var metadata []*model.Metadata
-		for metaRows.Next() {
-			var meta model.Metadata
-			if err := metaRows.Scan(&meta.Key, &meta.Value); err != nil {
-				log.Printf("Error scanning metadata row: %v", err)
-				metaRows.Close()
-				return nil, fmt.Errorf("failed to scan metadata row: %v", err)
-			}
-			metadata = append(metadata, &meta)
-		}
-		metaRows.Close()
-
-		file.Metadata = metadata
-		files = append(files, &file)
-	}
-
-	if err := rows.Err(); err != nil {
-		log.Printf("Error iterating over file rows: %v", err)
-		return nil, fmt.Errorf("failed to iterate over file rows: %v", err)
-	}
-
-	log.Printf("Successfully fetched %d files for node ID %s", len(files), nodeID)
-	return files, nil
-}
-
 // =============================================
 // ========== AUTENTISERING ==================
 // =============================================
@@ -555,18 +418,38 @@ func BlacklistToken(token string) {
 	TokenBlacklist[token] = true
 }
 
+// DefaultJWTSecret är hemligheten som token signeras med i utvecklingsmiljön. I drift anges en
+// egen hemlighet i konfigurationen.
+const DefaultJWTSecret = "your-256-bit-secret"
+
+// jwtSecret är hemligheten som token signeras och kontrolleras med
+var jwtSecret = []byte(DefaultJWTSecret)
+
+// SetJWTSecret anger hemligheten för token. Anropas en gång vid start, innan servern tar emot
+// förfrågningar. Token som signerats med en tidigare hemlighet blir ogiltiga.
+func SetJWTSecret(secret string) {
+	jwtSecret = []byte(secret)
+}
+
+// tokenLifetime är hur länge en token gäller efter inloggningen
+var tokenLifetime = 7 * 24 * time.Hour
+
+// SetTokenLifetime anger hur länge nya token gäller. Anropas en gång vid start. Token som redan
+// delats ut behåller sin giltighetstid.
+func SetTokenLifetime(lifetime time.Duration) {
+	tokenLifetime = lifetime
+}
+
 // generateJWT genererar en JWT-token för en användare
 func generateJWT(userID, username string) (string, error) {
 	// Create a new JWT token
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
 		"user_id":  userID,
 		"username": username,
-		"exp":      time.Now().Add(time.Hour * 24 * 7).Unix(), // Token expires in 7 days
+		"exp":      time.Now().Add(tokenLifetime).Unix(),
 	})
 
 	// Sign the token with the secret key
-	// In a production environment, this should be stored securely and not hardcoded
-	jwtSecret := []byte("your-256-bit-secret") // Change this to a secure secret in production
 	tokenString, err := token.SignedString(jwtSecret)
 	if err != nil {
 		return "", err
@@ -579,7 +462,7 @@ func generateJWT(userID, username string) (string, error) {
 func validateJWT(tokenString string) (jwt.MapClaims, error) {
 	// Check if token is blacklisted
 	if IsTokenBlacklisted(tokenString) {
-		return nil, fmt.Errorf("token has been invalidated")
+		return nil, errTokenInvalidated
 	}
 
 	// Parse the token
@@ -590,7 +473,6 @@ func validateJWT(tokenString string) (jwt.MapClaims, error) {
 		}
 
 		// Return the secret key
-		jwtSecret := []byte("your-256-bit-secret") // Change this to a secure secret in production
 		return jwtSecret, nil
 	})
 
@@ -603,7 +485,7 @@ func validateJWT(tokenString string) (jwt.MapClaims, error) {
 		return claims, nil
 	}
 
-	return nil, fmt.Errorf("invalid token")
+	return nil, serviceErrorf(errNotAuthenticated, "invalid token")
 }
 
 // GetAuthToken hämtar JWT-token från context och validerar den
@@ -629,24 +511,29 @@ func GetAuthToken(ctx context.Context) (string, bool) {
 
 // getUserIDFromContext extracts user ID from the JWT token in the context
 func getUserIDFromContext(ctx context.Context) (string, error) {
+	// Bakgrundsjobb körs som användaren som startade dem
+	if userID, ok := ctx.Value(jobUserKey{}).(string); ok && userID != "" {
+		return userID, nil
+	}
+
 	// Get the token from the context
 	token, ok := GetAuthToken(ctx)
 	if !ok {
-		return "", fmt.Errorf("not authenticated")
+		return "", errNotAuthenticated
 	}
 
 	// Parse and validate the token
 	claims, err := validateJWT(token)
 	if err != nil {
 		log.Printf("Error validating JWT token: %v", err)
-		return "", fmt.Errorf("not authenticated")
+		return "", errNotAuthenticated
 	}
 
 	// Get user ID from claims
 	userID, ok := claims["user_id"].(string)
 	if !ok {
 		log.Printf("Invalid token claims: user_id not found")
-		return "", fmt.Errorf("invalid authentication token")
+		return "", serviceErrorf(errNotAuthenticated, "invalid authentication token")
 	}
 
 	return userID, nil
@@ -670,7 +557,7 @@ func (r *userResolver) Settings(ctx context.Context, obj *model.User) ([]*model.
 
 	if err != nil {
 		log.Printf("Error fetching user settings: %v", err)
-		return nil, fmt.Errorf("failed to fetch user settings: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to fetch user settings: %v", err)
 	}
 	defer rows.Close()
 
@@ -680,7 +567,7 @@ func (r *userResolver) Settings(ctx context.Context, obj *model.User) ([]*model.
 		var createdAt, updatedAt string
 		if err := rows.Scan(&setting.ID, &setting.Key, &setting.Value, &createdAt, &updatedAt); err != nil {
 			log.Printf("Error scanning user setting row: %v", err)
-			return nil, fmt.Errorf("failed to read user settings: %v", err)
+			return nil, serviceErrorf(errInternal, "failed to read user settings: %v", err)
 		}
 		setting.CreatedAt = createdAt
 		setting.UpdatedAt = updatedAt
@@ -700,35 +587,5 @@ func (r *userResolver) Settings(ctx context.Context, obj *model.User) ([]*model.
 // =============================================
 
 func (r *Resolver) GetNodeById(ctx context.Context, id string) (*model.Node, error) {
-	return getNodeById(ctx, r.DB, id)
-}
-
-// =============================================
-// ========== USER FUNKTIONER ===========
-// =============================================
-
-// getUserById retrieves a user by ID
-func (r *queryResolver) getUserById(ctx context.Context, id string) (*model.User, error) {
-	var user model.User
-	err := r.DB.QueryRow("SELECT id, username, name FROM users WHERE id = ?", id).Scan(
-		&user.ID, &user.Username, &user.Name)
-
-	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("user not found")
-		}
-		log.Printf("Error querying user by ID: %v", err)
-		return nil, fmt.Errorf("failed to query user: %v", err)
-	}
-
-	// Get user's groups
-	groups, err := getUserGroups(ctx, r.DB, id)
-	if err != nil {
-		log.Printf("Warning: Failed to fetch user's groups: %v", err)
-		// Continue without groups, it's not critical
-	} else {
-		user.Groups = groups
-	}
-
-	return &user, nil
+	return r.Nodes.Get(ctx, id)
 }
@@ -0,0 +1,1010 @@
+package graph
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"graphql-backend/graph/model"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/99designs/gqlgen/graphql"
+)
+
+// =============================================
+// ========== REST-API ========================
+// =============================================
+
+// RestAPIPath är sökvägen där den versionerade REST-API:t finns
+const RestAPIPath = "/api/v1/"
+
+// Största tillåtna kropp i ett REST-anrop. Filer skickas base64-kodade, precis som i GraphQL.
+const restMaxBodySize = 256 << 20
+
+// restAPI hanterar REST-anrop. Varje operation anropar samma tjänst som resolvern för motsvarande
+// GraphQL-fält, genom samma middleware, så kontroller, revisionslogg och ändringshändelser blir desamma.
+type restAPI struct {
+	db       *sql.DB
+	services *Services
+	mutation MutationResolver
+	node     NodeResolver
+	file     FileResolver
+	mux      *http.ServeMux
+	document []byte
+}
+
+// restRoute beskriver en operation i REST-API:t. OpenAPI-dokumentet byggs från samma beskrivning.
+type restRoute struct {
+	method      string
+	pattern     string // Sökvägen under RestAPIPath, med {id} för identiteter
+	operationID string
+	tag         string
+	summary     string
+	field       string // GraphQL-fältet som operationen motsvarar
+	query       []restQueryParam
+	body        interface{} // Ett nollvärde av kroppens typ, nil om operationen inte tar någon kropp
+	response    interface{} // Ett nollvärde av svarets typ, nil om svaret saknar innehåll
+	status      int
+	public      bool // Kräver ingen inloggning
+	handle      func(c *restCall) (interface{}, error)
+}
+
+// restQueryParam är en parameter i frågesträngen
+type restQueryParam struct {
+	name        string
+	description string
+	repeated    bool
+	required    bool
+}
+
+// restCall är ett pågående REST-anrop
+type restCall struct {
+	api     *restAPI
+	ctx     context.Context
+	request *http.Request
+	route   *restRoute
+}
+
+// restContent är ett svar med en fils innehåll i stället för JSON
+type restContent struct {
+	name        string
+	contentType string
+	data        []byte
+}
+
+// restInputError är ett fel i anropets kropp eller parametrar
+type restInputError struct {
+	message string
+}
+
+func (e *restInputError) Error() string {
+	return e.message
+}
+
+// ---------- Representationer ----------
+
+// restNode är en nod, med samma fält och värden som i GraphQL
+type restNode struct {
+	ID           string          `json:"id"`
+	Name         string          `json:"name"`
+	ParentID     *string         `json:"parentId"`
+	NodeType     model.NodeType  `json:"nodeType"`
+	OwnerUserID  *string         `json:"ownerUserId"`
+	OwnerGroupID *string         `json:"ownerGroupId"`
+	Permissions  int             `json:"permissions"`
+	CreatedAt    string          `json:"createdAt"`
+	UpdatedAt    string          `json:"updatedAt"`
+	Metadata     []*restMetadata `json:"metadata"`
+}
+
+// restFile är en fil utan innehåll, som hämtas separat
+type restFile struct {
+	ID          string          `json:"id"`
+	Name        string          `json:"name"`
+	Size        int             `json:"size"`
+	ContentType string          `json:"contentType"`
+	CreatedAt   string          `json:"createdAt"`
+	NodeID      *string         `json:"nodeId"`
+	FileType    model.FileType  `json:"fileType"`
+	Metadata    []*restMetadata `json:"metadata"`
+}
+
+// restMetadata är ett metadatavärde på en nod eller fil
+type restMetadata struct {
+	Key         string                  `json:"key"`
+	Value       string                  `json:"value"`
+	Type        model.MetadataValueType `json:"type"`
+	Inheritable bool                    `json:"inheritable"`
+}
+
+// restUser är en användare
+type restUser struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Username string `json:"username"`
+}
+
+// restAuthPayload är svaret på en inloggning
+type restAuthPayload struct {
+	Token string    `json:"token"`
+	User  *restUser `json:"user"`
+}
+
+// restError är svaret när ett anrop misslyckas
+type restError struct {
+	Error string `json:"error"`
+}
+
+// ---------- Kroppar ----------
+
+type restMoveNodeInput struct {
+	ParentID string `json:"parentId"`
+}
+
+type restMoveFileInput struct {
+	NodeID string `json:"nodeId"`
+}
+
+type restRenameFileInput struct {
+	Name string `json:"name"`
+}
+
+type restLoginInput struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+type restUserInput struct {
+	Username string  `json:"username"`
+	Password string  `json:"password"`
+	Name     *string `json:"name,omitempty"`
+}
+
+type restUserUpdateInput struct {
+	Username *string `json:"username,omitempty"`
+	Name     *string `json:"name,omitempty"`
+}
+
+type restPasswordInput struct {
+	NewPassword string `json:"newPassword"`
+}
+
+// RestAPIHandler svarar på REST-anrop under RestAPIPath. Anroparen autentiseras med JWT i
+// Authorization-headern, precis som mot GraphQL, och OpenAPI-dokumentet finns på openapi.json.
+func RestAPIHandler(db *sql.DB) http.Handler {
+	resolver := NewResolver(db)
+	api := &restAPI{
+		db:       db,
+		services: resolver.Services,
+		mutation: resolver.Mutation(),
+		node:     resolver.Node(),
+		file:     resolver.File(),
+		mux:      http.NewServeMux(),
+	}
+
+	routes := api.routes()
+	for i := range routes {
+		route := &routes[i]
+		api.mux.HandleFunc(route.method+" "+RestAPIPath+route.pattern, func(w http.ResponseWriter, r *http.Request) {
+			api.serve(w, r, route)
+		})
+	}
+
+	document, err := json.MarshalIndent(openAPIDocument(routes), "", "  ")
+	if err != nil {
+		// Dokumentet byggs bara från typerna i den här filen, så ett fel här är ett programfel
+		panic(fmt.Sprintf("failed to build OpenAPI document: %v", err))
+	}
+	api.document = document
+	api.mux.HandleFunc("GET "+RestAPIPath+"openapi.json", api.serveDocument)
+
+	return api
+}
+
+func (a *restAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	a.mux.ServeHTTP(w, r)
+}
+
+// serve kör en operation och skriver svaret
+func (a *restAPI) serve(w http.ResponseWriter, r *http.Request, route *restRoute) {
+	logAction(fmt.Sprintf("REST %s %s", r.Method, r.URL.Path))
+
+	// Operationer som inte är öppna kräver en giltig token, oavsett vad tjänsten själv kontrollerar
+	if !route.public {
+		if _, err := getUserIDFromContext(r.Context()); err != nil {
+			writeRestError(w, http.StatusUnauthorized, resolverErrorMessage(err))
+			return
+		}
+	}
+
+	result, err := route.handle(&restCall{api: a, ctx: r.Context(), request: r, route: route})
+	if err != nil {
+		writeRestError(w, restErrorStatus(err), resolverErrorMessage(err))
+		return
+	}
+
+	if content, ok := result.(*restContent); ok {
+		w.Header().Set("Content-Type", content.contentType)
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": content.name}))
+		w.WriteHeader(route.status)
+		if _, err := w.Write(content.data); err != nil {
+			log.Printf("Error writing file content: %v", err)
+		}
+		return
+	}
+
+	if route.status == http.StatusNoContent {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	writeRestJSON(w, route.status, result)
+}
+
+func (a *restAPI) serveDocument(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if _, err := w.Write(a.document); err != nil {
+		log.Printf("Error writing OpenAPI document: %v", err)
+	}
+}
+
+func writeRestJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		log.Printf("Error encoding REST response: %v", err)
+	}
+}
+
+func writeRestError(w http.ResponseWriter, status int, message string) {
+	writeRestJSON(w, status, restError{Error: message})
+}
+
+// restErrorStatus väljer HTTP-status utifrån felets sort. Fel utan sort beror på indata.
+func restErrorStatus(err error) int {
+	var inputErr *restInputError
+	switch {
+	case errors.As(err, &inputErr):
+		return http.StatusBadRequest
+	case errors.Is(err, errPermissionDenied):
+		return http.StatusForbidden
+	case errors.Is(err, errNotAuthenticated):
+		return http.StatusUnauthorized
+	case errors.Is(err, errNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, errConflict):
+		return http.StatusConflict
+	case errors.Is(err, errInternal):
+		return http.StatusInternalServerError
+	default:
+		return http.StatusBadRequest
+	}
+}
+
+// ---------- Anrop ----------
+
+// id är identiteten i sökvägen
+func (c *restCall) id() string {
+	return c.request.PathValue("id")
+}
+
+// resolve anropar en resolver som GraphQL-fältet för operationen, med GraphQL-argumenten args
+func (c *restCall) resolve(object string, args map[string]interface{}, resolve graphql.Resolver) (interface{}, error) {
+	return callResolver(c.ctx, c.api.db, object, c.route.field, args, resolve)
+}
+
+// decode läser anropets kropp till v. Okända fält, saknade obligatoriska fält och ogiltiga
+// uppräkningsvärden avvisas som i GraphQL.
+func (c *restCall) decode(v interface{}) error {
+	body, err := io.ReadAll(io.LimitReader(c.request.Body, restMaxBodySize+1))
+	if err != nil {
+		return &restInputError{message: fmt.Sprintf("failed to read request body: %v", err)}
+	}
+	if len(body) > restMaxBodySize {
+		return &restInputError{message: "request body is too large"}
+	}
+	if len(strings.TrimSpace(string(body))) == 0 {
+		return &restInputError{message: "request body is required"}
+	}
+
+	decoder := json.NewDecoder(strings.NewReader(string(body)))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return &restInputError{message: fmt.Sprintf("invalid request body: %v", err)}
+	}
+	if err := checkRestRequired(body, reflect.TypeOf(v).Elem(), ""); err != nil {
+		return err
+	}
+	return checkRestEnums(reflect.ValueOf(v))
+}
+
+// checkRestRequired kontrollerar att obligatoriska fält finns. Fält utan omitempty som inte är
+// pekare eller listor är obligatoriska, precis som de icke-nullbara fälten i GraphQL-indata.
+func checkRestRequired(raw json.RawMessage, t reflect.Type, path string) error {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &fields); err != nil || fields == nil {
+			return nil
+		}
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			name, required := restJSONField(field)
+			if name == "" {
+				continue
+			}
+			value, ok := fields[name]
+			if !ok || string(value) == "null" {
+				if required {
+					return &restInputError{message: fmt.Sprintf("%s%s is required", path, name)}
+				}
+				continue
+			}
+			if err := checkRestRequired(value, field.Type, path+name+"."); err != nil {
+				return err
+			}
+		}
+	case reflect.Slice:
+		var items []json.RawMessage
+		if err := json.Unmarshal(raw, &items); err != nil {
+			return nil
+		}
+		for i, item := range items {
+			if string(item) == "null" {
+				continue
+			}
+			if err := checkRestRequired(item, t.Elem(), fmt.Sprintf("%s%d.", path, i)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// restEnum är de genererade uppräkningstyperna i model
+type restEnum interface {
+	IsValid() bool
+	String() string
+}
+
+// checkRestEnums avvisar uppräkningsvärden som GraphQL inte skulle ha godtagit
+func checkRestEnums(v reflect.Value) error {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return checkRestEnums(v.Elem())
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if err := checkRestEnums(v.Field(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			if err := checkRestEnums(v.Index(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.String:
+		if enum, ok := v.Interface().(restEnum); ok && !enum.IsValid() {
+			return &restInputError{message: fmt.Sprintf("%s is not a valid %s", enum.String(), v.Type().Name())}
+		}
+	}
+	return nil
+}
+
+// restJSONField ger fältets namn i JSON och om det är obligatoriskt
+func restJSONField(field reflect.StructField) (string, bool) {
+	tag := field.Tag.Get("json")
+	if tag == "-" || !field.IsExported() {
+		return "", false
+	}
+	name, options, _ := strings.Cut(tag, ",")
+	if name == "" {
+		name = field.Name
+	}
+	kind := field.Type.Kind()
+	required := !strings.Contains(options, "omitempty") && kind != reflect.Ptr && kind != reflect.Slice
+	return name, required
+}
+
+// ---------- Operationer ----------
+
+func (a *restAPI) routes() []restRoute {
+	return []restRoute{
+		// Noder
+		{
+			method: http.MethodGet, pattern: "nodes", operationID: "listRootNodes", tag: "nodes",
+			summary: "Lista rotnoderna", field: "getRootNodes",
+			response: []*restNode{}, status: http.StatusOK,
+			handle: func(c *restCall) (interface{}, error) {
+				result, err := c.resolve("Query", map[string]interface{}{}, func(ctx context.Context) (interface{}, error) {
+					return a.services.Nodes.Roots(ctx)
+				})
+				if err != nil {
+					return nil, err
+				}
+				return a.nodes(c.ctx, result.([]*model.Node))
+			},
+		},
+		{
+			method: http.MethodGet, pattern: "nodes/{id}", operationID: "getNode", tag: "nodes",
+			summary: "Hämta en nod", field: "getNodeById",
+			response: &restNode{}, status: http.StatusOK,
+			handle: func(c *restCall) (interface{}, error) {
+				id := c.id()
+				result, err := c.resolve("Query", map[string]interface{}{"id": id}, func(ctx context.Context) (interface{}, error) {
+					return a.services.Nodes.Get(ctx, id)
+				})
+				if err != nil {
+					return nil, err
+				}
+				return a.nodeOrNotFound(c.ctx, result.(*model.Node))
+			},
+		},
+		{
+			method: http.MethodGet, pattern: "nodes/{id}/children", operationID: "listChildNodes", tag: "nodes",
+			summary: "Lista en nods barn", field: "getChildNodes",
+			response: []*restNode{}, status: http.StatusOK,
+			handle: func(c *restCall) (interface{}, error) {
+				id := c.id()
+				result, err := c.resolve("Query", map[string]interface{}{"parentId": id}, func(ctx context.Context) (interface{}, error) {
+					return a.services.Nodes.Children(ctx, id)
+				})
+				if err != nil {
+					return nil, err
+				}
+				return a.nodes(c.ctx, result.([]*model.Node))
+			},
+		},
+		{
+			method: http.MethodGet, pattern: "nodes/{id}/files", operationID: "listNodeFiles", tag: "nodes",
+			summary: "Lista filerna i en nod", field: "getFilesByNodeId",
+			response: []*restFile{}, status: http.StatusOK,
+			handle: func(c *restCall) (interface{}, error) {
+				id := c.id()
+				result, err := c.resolve("Query", map[string]interface{}{"nodeId": id}, func(ctx context.Context) (interface{}, error) {
+					return a.services.Files.ListByNode(ctx, id)
+				})
+				if err != nil {
+					return nil, err
+				}
+				return a.files(c.ctx, result.([]*model.File))
+			},
+		},
+		{
+			method: http.MethodPost, pattern: "nodes", operationID: "createNode", tag: "nodes",
+			summary: "Skapa en nod", field: "createNode",
+			body: model.NodeInput{}, response: &restNode{}, status: http.StatusCreated,
+			handle: func(c *restCall) (interface{}, error) {
+				var input model.NodeInput
+				if err := c.decode(&input); err != nil {
+					return nil, err
+				}
+				result, err := c.resolve("Mutation", map[string]interface{}{"input": input}, func(ctx context.Context) (interface{}, error) {
+					return a.services.Nodes.Create(ctx, input)
+				})
+				if err != nil {
+					return nil, err
+				}
+				return a.restNode(c.ctx, result.(*model.Node))
+			},
+		},
+		{
+			method: http.MethodPatch, pattern: "nodes/{id}", operationID: "updateNode", tag: "nodes",
+			summary: "Ändra en nod", field: "updateNode",
+			body: model.NodeUpdateInput{}, response: &restNode{}, status: http.StatusOK,
+			handle: func(c *restCall) (interface{}, error) {
+				id := c.id()
+				var input model.NodeUpdateInput
+				if err := c.decode(&input); err != nil {
+					return nil, err
+				}
+				result, err := c.resolve("Mutation", map[string]interface{}{"id": id, "input": input}, func(ctx context.Context) (interface{}, error) {
+					return a.services.Nodes.Update(ctx, id, input)
+				})
+				if err != nil {
+					return nil, err
+				}
+				return a.restNode(c.ctx, result.(*model.Node))
+			},
+		},
+		{
+			method: http.MethodDelete, pattern: "nodes/{id}", operationID: "deleteNode", tag: "nodes",
+			summary: "Ta bort en nod utan barn", field: "deleteNode",
+			status: http.StatusNoContent,
+			handle: func(c *restCall) (interface{}, error) {
+				id := c.id()
+				return c.resolveDeletion(map[string]interface{}{"id": id}, "node", func(ctx context.Context) (interface{}, error) {
+					return a.services.Nodes.Delete(ctx, id)
+				})
+			},
+		},
+		{
+			method: http.MethodPost, pattern: "nodes/{id}/move", operationID: "moveNode", tag: "nodes",
+			summary: "Flytta en nod till en annan förälder", field: "moveNode",
+			body: restMoveNodeInput{}, response: &restNode{}, status: http.StatusOK,
+			handle: func(c *restCall) (interface{}, error) {
+				id := c.id()
+				var input restMoveNodeInput
+				if err := c.decode(&input); err != nil {
+					return nil, err
+				}
+				result, err := c.resolve("Mutation", map[string]interface{}{"id": id, "newParentId": input.ParentID}, func(ctx context.Context) (interface{}, error) {
+					return a.services.Nodes.Move(ctx, id, input.ParentID)
+				})
+				if err != nil {
+					return nil, err
+				}
+				return a.restNode(c.ctx, result.(*model.Node))
+			},
+		},
+		{
+			method: http.MethodPut, pattern: "nodes/{id}/metadata", operationID: "updateNodeMetadata", tag: "nodes",
+			summary: "Sätt metadata på en nod", field: "updateNodeMetadata",
+			body: []*model.MetadataInput{}, response: &restNode{}, status: http.StatusOK,
+			handle: func(c *restCall) (interface{}, error) {
+				id := c.id()
+				var input []*model.MetadataInput
+				if err := c.decode(&input); err != nil {
+					return nil, err
+				}
+				result, err := c.resolve("Mutation", map[string]interface{}{"nodeId": id, "metadataInput": input}, func(ctx context.Context) (interface{}, error) {
+					return a.mutation.UpdateNodeMetadata(ctx, id, input)
+				})
+				if err != nil {
+					return nil, err
+				}
+				return a.restNode(c.ctx, result.(*model.Node))
+			},
+		},
+
+		// Filer
+		{
+			method: http.MethodGet, pattern: "files", operationID: "listFiles", tag: "files",
+			summary: "Lista alla filer", field: "getFiles",
+			response: []*restFile{}, status: http.StatusOK,
+			handle: func(c *restCall) (interface{}, error) {
+				result, err := c.resolve("Query", map[string]interface{}{}, func(ctx context.Context) (interface{}, error) {
+					return a.services.Files.List(ctx)
+				})
+				if err != nil {
+					return nil, err
+				}
+				return a.files(c.ctx, result.([]*model.File))
+			},
+		},
+		{
+			method: http.MethodGet, pattern: "files/{id}", operationID: "getFile", tag: "files",
+			summary: "Hämta en fil", field: "getFile",
+			response: &restFile{}, status: http.StatusOK,
+			handle: func(c *restCall) (interface{}, error) {
+				id := c.id()
+				result, err := c.resolve("Query", map[string]interface{}{"id": id}, func(ctx context.Context) (interface{}, error) {
+					return a.services.Files.Get(ctx, id)
+				})
+				if err != nil {
+					return nil, err
+				}
+				file := result.(*model.File)
+				if file == nil {
+					return nil, serviceErrorf(errNotFound, "file not found")
+				}
+				return a.restFile(c.ctx, file)
+			},
+		},
+		{
+			method: http.MethodGet, pattern: "files/{id}/content", operationID: "downloadFile", tag: "files",
+			summary: "Ladda ned en fils innehåll", field: "downloadFile",
+			response: &restContent{}, status: http.StatusOK,
+			handle: func(c *restCall) (interface{}, error) {
+				id := c.id()
+				result, err := c.resolve("Query", map[string]interface{}{"id": id}, func(ctx context.Context) (interface{}, error) {
+					return a.services.Files.Download(ctx, id)
+				})
+				if err != nil {
+					return nil, err
+				}
+				file := result.(*model.File)
+				if file == nil {
+					return nil, serviceErrorf(errNotFound, "file not found")
+				}
+				content := &restContent{name: file.Name, contentType: file.ContentType, data: []byte{}}
+				if content.contentType == "" {
+					content.contentType = "application/octet-stream"
+				}
+				if file.FileData != nil {
+					if content.data, err = base64.StdEncoding.DecodeString(*file.FileData); err != nil {
+						return nil, serviceErrorf(errInternal, "failed to decode file data: %v", err)
+					}
+				}
+				return content, nil
+			},
+		},
+		{
+			method: http.MethodPost, pattern: "files", operationID: "saveFile", tag: "files",
+			summary: "Ladda upp en fil, med innehållet base64-kodat", field: "saveFile",
+			body: model.FileInput{}, response: &restFile{}, status: http.StatusCreated,
+			handle: func(c *restCall) (interface{}, error) {
+				var input model.FileInput
+				if err := c.decode(&input); err != nil {
+					return nil, err
+				}
+				result, err := c.resolve("Mutation", map[string]interface{}{"input": input}, func(ctx context.Context) (interface{}, error) {
+					return a.services.Files.Save(ctx, input)
+				})
+				if err != nil {
+					return nil, err
+				}
+				return a.restFile(c.ctx, result.(*model.File))
+			},
+		},
+		{
+			method: http.MethodPatch, pattern: "files/{id}", operationID: "renameFile", tag: "files",
+			summary: "Byt namn på en fil", field: "renameFile",
+			body: restRenameFileInput{}, response: &restFile{}, status: http.StatusOK,
+			handle: func(c *restCall) (interface{}, error) {
+				id := c.id()
+				var input restRenameFileInput
+				if err := c.decode(&input); err != nil {
+					return nil, err
+				}
+				result, err := c.resolve("Mutation", map[string]interface{}{"id": id, "name": input.Name}, func(ctx context.Context) (interface{}, error) {
+					return a.services.Files.Rename(ctx, id, input.Name)
+				})
+				if err != nil {
+					return nil, err
+				}
+				return a.restFile(c.ctx, result.(*model.File))
+			},
+		},
+		{
+			method: http.MethodDelete, pattern: "files/{id}", operationID: "deleteFile", tag: "files",
+			summary: "Ta bort en fil", field: "deleteFile",
+			status: http.StatusNoContent,
+			handle: func(c *restCall) (interface{}, error) {
+				id := c.id()
+				return c.resolveDeletion(map[string]interface{}{"id": id}, "file", func(ctx context.Context) (interface{}, error) {
+					return a.services.Files.Delete(ctx, id)
+				})
+			},
+		},
+		{
+			method: http.MethodPost, pattern: "files/{id}/move", operationID: "moveFile", tag: "files",
+			summary: "Flytta en fil till en annan nod", field: "moveFile",
+			body: restMoveFileInput{}, response: &restFile{}, status: http.StatusOK,
+			handle: func(c *restCall) (interface{}, error) {
+				id := c.id()
+				var input restMoveFileInput
+				if err := c.decode(&input); err != nil {
+					return nil, err
+				}
+				result, err := c.resolve("Mutation", map[string]interface{}{"fileId": id, "nodeId": input.NodeID}, func(ctx context.Context) (interface{}, error) {
+					return a.services.Files.Move(ctx, id, input.NodeID)
+				})
+				if err != nil {
+					return nil, err
+				}
+				return a.restFile(c.ctx, result.(*model.File))
+			},
+		},
+		{
+			method: http.MethodPut, pattern: "files/{id}/metadata", operationID: "updateFileMetadata", tag: "files",
+			summary: "Sätt metadata på en fil", field: "updateMetadata",
+			body: []*model.MetadataInput{}, response: &restFile{}, status: http.StatusOK,
+			handle: func(c *restCall) (interface{}, error) {
+				id := c.id()
+				var input []*model.MetadataInput
+				if err := c.decode(&input); err != nil {
+					return nil, err
+				}
+				result, err := c.resolve("Mutation", map[string]interface{}{"fileId": id, "metadataInput": input}, func(ctx context.Context) (interface{}, error) {
+					return a.services.Files.UpdateMetadata(ctx, id, input)
+				})
+				if err != nil {
+					return nil, err
+				}
+				return a.restFile(c.ctx, result.(*model.File))
+			},
+		},
+		{
+			method: http.MethodDelete, pattern: "files/{id}/metadata", operationID: "deleteFileMetadata", tag: "files",
+			summary: "Ta bort metadata från en fil", field: "deleteMetadata",
+			query:    []restQueryParam{{name: "key", description: "Nyckel att ta bort, kan anges flera gånger", repeated: true, required: true}},
+			response: &restFile{}, status: http.StatusOK,
+			handle: func(c *restCall) (interface{}, error) {
+				id := c.id()
+				keys := c.request.URL.Query()["key"]
+				if len(keys) == 0 {
+					return nil, &restInputError{message: "key is required"}
+				}
+				result, err := c.resolve("Mutation", map[string]interface{}{"fileId": id, "keys": keys}, func(ctx context.Context) (interface{}, error) {
+					return a.services.Files.DeleteMetadata(ctx, id, keys)
+				})
+				if err != nil {
+					return nil, err
+				}
+				return a.restFile(c.ctx, result.(*model.File))
+			},
+		},
+
+		// Användare
+		{
+			method: http.MethodGet, pattern: "users", operationID: "listUsers", tag: "users",
+			summary: "Lista alla användare, kräver administratör", field: "getUsers",
+			response: []*restUser{}, status: http.StatusOK,
+			handle: func(c *restCall) (interface{}, error) {
+				result, err := c.resolve("Query", map[string]interface{}{}, func(ctx context.Context) (interface{}, error) {
+					return a.services.Users.List(ctx)
+				})
+				if err != nil {
+					return nil, err
+				}
+				users := make([]*restUser, 0)
+				for _, user := range result.([]*model.User) {
+					users = append(users, toRestUser(user))
+				}
+				return users, nil
+			},
+		},
+		{
+			method: http.MethodGet, pattern: "users/me", operationID: "getCurrentUser", tag: "users",
+			summary: "Hämta den inloggade användaren", field: "me",
+			response: &restUser{}, status: http.StatusOK,
+			handle: func(c *restCall) (interface{}, error) {
+				result, err := c.resolve("Query", map[string]interface{}{}, func(ctx context.Context) (interface{}, error) {
+					return a.services.Users.Current(ctx)
+				})
+				if err != nil {
+					return nil, err
+				}
+				return userOrNotFound(result.(*model.User))
+			},
+		},
+		{
+			method: http.MethodGet, pattern: "users/{id}", operationID: "getUser", tag: "users",
+			summary: "Hämta en användare", field: "getUserById",
+			response: &restUser{}, status: http.StatusOK,
+			handle: func(c *restCall) (interface{}, error) {
+				id := c.id()
+				result, err := c.resolve("Query", map[string]interface{}{"id": id}, func(ctx context.Context) (interface{}, error) {
+					return a.services.Users.Get(ctx, id)
+				})
+				if err != nil {
+					return nil, err
+				}
+				return userOrNotFound(result.(*model.User))
+			},
+		},
+		{
+			method: http.MethodPost, pattern: "users", operationID: "createUser", tag: "users",
+			summary: "Skapa en användare, kräver administratör", field: "createUser",
+			body: restUserInput{}, response: &restUser{}, status: http.StatusCreated,
+			handle: func(c *restCall) (interface{}, error) {
+				var input restUserInput
+				if err := c.decode(&input); err != nil {
+					return nil, err
+				}
+				args := map[string]interface{}{"username": input.Username, "password": input.Password, "name": input.Name}
+				result, err := c.resolve("Mutation", args, func(ctx context.Context) (interface{}, error) {
+					return a.services.Users.Create(ctx, input.Username, input.Password, input.Name)
+				})
+				if err != nil {
+					return nil, err
+				}
+				return toRestUser(result.(*model.User)), nil
+			},
+		},
+		{
+			method: http.MethodPatch, pattern: "users/{id}", operationID: "updateUser", tag: "users",
+			summary: "Ändra en användare", field: "updateUser",
+			body: restUserUpdateInput{}, response: &restUser{}, status: http.StatusOK,
+			handle: func(c *restCall) (interface{}, error) {
+				id := c.id()
+				var input restUserUpdateInput
+				if err := c.decode(&input); err != nil {
+					return nil, err
+				}
+				args := map[string]interface{}{"id": id, "username": input.Username, "name": input.Name}
+				result, err := c.resolve("Mutation", args, func(ctx context.Context) (interface{}, error) {
+					return a.services.Users.Update(ctx, id, input.Username, input.Name)
+				})
+				if err != nil {
+					return nil, err
+				}
+				return toRestUser(result.(*model.User)), nil
+			},
+		},
+		{
+			method: http.MethodPut, pattern: "users/{id}/password", operationID: "updateUserPassword", tag: "users",
+			summary: "Byt lösenord för en användare", field: "updateUserPassword",
+			body: restPasswordInput{}, status: http.StatusNoContent,
+			handle: func(c *restCall) (interface{}, error) {
+				id := c.id()
+				var input restPasswordInput
+				if err := c.decode(&input); err != nil {
+					return nil, err
+				}
+				return c.resolveDeletion(map[string]interface{}{"userId": id, "newPassword": input.NewPassword}, "user", func(ctx context.Context) (interface{}, error) {
+					return a.services.Users.SetPassword(ctx, id, input.NewPassword)
+				})
+			},
+		},
+		{
+			method: http.MethodDelete, pattern: "users/{id}", operationID: "deleteUser", tag: "users",
+			summary: "Ta bort en användare, kräver administratör", field: "deleteUser",
+			status: http.StatusNoContent,
+			handle: func(c *restCall) (interface{}, error) {
+				id := c.id()
+				return c.resolveDeletion(map[string]interface{}{"id": id}, "user", func(ctx context.Context) (interface{}, error) {
+					return a.services.Users.Delete(ctx, id)
+				})
+			},
+		},
+
+		// Inloggning
+		{
+			method: http.MethodPost, pattern: "auth/login", operationID: "login", tag: "auth",
+			summary: "Logga in och få en JWT", field: "login", public: true,
+			body: restLoginInput{}, response: &restAuthPayload{}, status: http.StatusOK,
+			handle: func(c *restCall) (interface{}, error) {
+				var input restLoginInput
+				if err := c.decode(&input); err != nil {
+					return nil, err
+				}
+				args := map[string]interface{}{"username": input.Username, "password": input.Password}
+				result, err := c.resolve("Mutation", args, func(ctx context.Context) (interface{}, error) {
+					return a.services.Users.Login(ctx, input.Username, input.Password)
+				})
+				if err != nil {
+					return nil, err
+				}
+				payload := result.(*model.AuthPayload)
+				return &restAuthPayload{Token: payload.Token, User: toRestUser(payload.User)}, nil
+			},
+		},
+		{
+			method: http.MethodPost, pattern: "auth/logout", operationID: "logout", tag: "auth",
+			summary: "Logga ut och ogiltigförklara anropets JWT", field: "logout",
+			status: http.StatusNoContent,
+			handle: func(c *restCall) (interface{}, error) {
+				token, ok := GetAuthToken(c.ctx)
+				if !ok {
+					return nil, errNotAuthenticated
+				}
+				return c.resolveDeletion(map[string]interface{}{"token": token}, "token", func(ctx context.Context) (interface{}, error) {
+					return a.services.Users.Logout(ctx, token)
+				})
+			},
+		},
+	}
+}
+
+// resolveDeletion anropar en resolver som svarar med Boolean. Ett falskt svar utan fel betyder
+// att det som skulle ändras inte fanns.
+func (c *restCall) resolveDeletion(args map[string]interface{}, what string, resolve graphql.Resolver) (interface{}, error) {
+	result, err := c.resolve("Mutation", args, resolve)
+	if err != nil {
+		return nil, err
+	}
+	if ok, _ := result.(bool); !ok {
+		return nil, serviceErrorf(errNotFound, "%s not found", what)
+	}
+	return nil, nil
+}
+
+// ---------- Konvertering ----------
+
+func (a *restAPI) nodeOrNotFound(ctx context.Context, node *model.Node) (*restNode, error) {
+	if node == nil {
+		return nil, serviceErrorf(errNotFound, "node not found")
+	}
+	return a.restNode(ctx, node)
+}
+
+func (a *restAPI) nodes(ctx context.Context, nodes []*model.Node) ([]*restNode, error) {
+	result := make([]*restNode, 0, len(nodes))
+	for _, node := range nodes {
+		converted, err := a.restNode(ctx, node)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, converted)
+	}
+	return result, nil
+}
+
+// restNode hämtar nodtyp och metadata genom samma fältresolvers som GraphQL
+func (a *restAPI) restNode(ctx context.Context, node *model.Node) (*restNode, error) {
+	nodeType, err := a.node.NodeType(ctx, node)
+	if err != nil {
+		return nil, err
+	}
+	metadata, err := a.node.Metadata(ctx, node)
+	if err != nil {
+		return nil, err
+	}
+	return &restNode{
+		ID:           node.ID,
+		Name:         node.Name,
+		ParentID:     node.ParentID,
+		NodeType:     nodeType,
+		OwnerUserID:  node.OwnerUserID,
+		OwnerGroupID: node.OwnerGroupID,
+		Permissions:  node.Permissions,
+		CreatedAt:    node.CreatedAt,
+		UpdatedAt:    node.UpdatedAt,
+		Metadata:     toRestMetadata(metadata),
+	}, nil
+}
+
+func (a *restAPI) files(ctx context.Context, files []*model.File) ([]*restFile, error) {
+	result := make([]*restFile, 0, len(files))
+	for _, file := range files {
+		converted, err := a.restFile(ctx, file)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, converted)
+	}
+	return result, nil
+}
+
+func (a *restAPI) restFile(ctx context.Context, file *model.File) (*restFile, error) {
+	fileType, err := a.file.FileType(ctx, file)
+	if err != nil {
+		return nil, err
+	}
+	return &restFile{
+		ID:          file.ID,
+		Name:        file.Name,
+		Size:        file.Size,
+		ContentType: file.ContentType,
+		CreatedAt:   file.CreatedAt,
+		NodeID:      file.NodeID,
+		FileType:    fileType,
+		Metadata:    toRestMetadata(file.Metadata),
+	}, nil
+}
+
+// toRestMetadata behåller skillnaden mellan en tom lista och ingen lista, som i GraphQL
+func toRestMetadata(metadata []*model.Metadata) []*restMetadata {
+	if metadata == nil {
+		return nil
+	}
+	result := make([]*restMetadata, 0, len(metadata))
+	for _, m := range metadata {
+		if m == nil {
+			result = append(result, nil)
+			continue
+		}
+		result = append(result, &restMetadata{Key: m.Key, Value: m.Value, Type: m.Type, Inheritable: m.Inheritable})
+	}
+	return result
+}
+
+func toRestUser(user *model.User) *restUser {
+	if user == nil {
+		return nil
+	}
+	return &restUser{ID: user.ID, Name: user.Name, Username: user.Username}
+}
+
+func userOrNotFound(user *model.User) (*restUser, error) {
+	if user == nil {
+		return nil, serviceErrorf(errNotFound, "user not found")
+	}
+	return toRestUser(user), nil
+}
@@ -0,0 +1,690 @@
+package graph
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"graphql-backend/graph/model"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+)
+
+// contractStep är ett anrop som görs både mot GraphQL och mot REST. Svaren ska vara identiska:
+// samma data, eller samma felmeddelande.
+type contractStep struct {
+	name  string
+	token string // Nyckel i contractEnv.tokens, tom för anrop utan inloggning
+	route string // operationID för REST-operationen
+
+	query     string // GraphQL-dokument där %NODE%, %FILE%, %USER% och %AUTH% ersätts med urval
+	variables map[string]interface{}
+	field     string // Fältet i GraphQL-svaret
+
+	method string
+	path   string
+	body   interface{}
+	status int // Förväntad HTTP-status från REST
+
+	saveToken string // Spara token från ett inloggningssvar under det här namnet
+}
+
+// contractEnv är en server med egen databas
+type contractEnv struct {
+	db      *sql.DB
+	graphQL *httptest.Server
+	rest    *httptest.Server
+	tokens  map[string]string
+}
+
+// contractResult är svaret på ett steg, antingen data eller ett felmeddelande
+type contractResult struct {
+	data    interface{}
+	message string
+}
+
+func newContractEnv(t *testing.T) *contractEnv {
+	t.Helper()
+	db := openTestDB(t)
+
+	srv := handler.New(NewExecutableSchema(Config{Resolvers: NewResolver(db)}))
+	srv.AddTransport(transport.POST{})
+	srv.AroundFields(AuditMiddleware(db))
+	srv.AroundFields(ChangeEventMiddleware(db))
+
+	env := &contractEnv{
+		db:      db,
+		graphQL: httptest.NewServer(withTestBearerToken(srv)),
+		rest:    httptest.NewServer(withTestBearerToken(RestAPIHandler(db))),
+		tokens:  map[string]string{},
+	}
+	t.Cleanup(env.graphQL.Close)
+	t.Cleanup(env.rest.Close)
+
+	for name, userID := range map[string]string{"admin": "1", "bob": "2"} {
+		token, err := generateJWT(userID, name)
+		if err != nil {
+			t.Fatalf("generate token: %v", err)
+		}
+		env.tokens[name] = token
+	}
+	return env
+}
+
+func (env *contractEnv) request(t *testing.T, method string, url string, token string, body []byte) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+env.tokens[token])
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, url, err)
+	}
+	return resp
+}
+
+// viaGraphQL kör steget mot GraphQL
+func (env *contractEnv) viaGraphQL(t *testing.T, step contractStep) contractResult {
+	t.Helper()
+	query := strings.NewReplacer(
+		"%NODE%", graphQLSelection(reflect.TypeOf(restNode{})),
+		"%FILE%", graphQLSelection(reflect.TypeOf(restFile{})),
+		"%USER%", graphQLSelection(reflect.TypeOf(restUser{})),
+		"%AUTH%", graphQLSelection(reflect.TypeOf(restAuthPayload{})),
+	).Replace(step.query)
+	body, _ := json.Marshal(map[string]interface{}{"query": query, "variables": step.variables})
+
+	resp := env.request(t, http.MethodPost, env.graphQL.URL, step.token, body)
+	defer resp.Body.Close()
+
+	var result struct {
+		Data   map[string]interface{} `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		t.Fatalf("decode GraphQL response: %v", err)
+	}
+	if len(result.Errors) > 0 {
+		return contractResult{message: result.Errors[0].Message}
+	}
+	return contractResult{data: result.Data[step.field]}
+}
+
+// viaREST kör steget mot REST och kontrollerar statusen
+func (env *contractEnv) viaREST(t *testing.T, step contractStep) contractResult {
+	t.Helper()
+	var body []byte
+	if step.body != nil {
+		body, _ = json.Marshal(step.body)
+	}
+
+	resp := env.request(t, step.method, env.rest.URL+RestAPIPath+step.path, step.token, body)
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("read REST response: %v", err)
+	}
+
+	if resp.StatusCode != step.status {
+		t.Errorf("%s: REST status = %d, want %d (body %s)", step.name, resp.StatusCode, step.status, data)
+	}
+
+	switch {
+	case resp.StatusCode >= 400:
+		var restErr restError
+		if err := json.Unmarshal(data, &restErr); err != nil {
+			t.Fatalf("decode REST error %q: %v", data, err)
+		}
+		return contractResult{message: restErr.Error}
+	case resp.StatusCode == http.StatusNoContent:
+		// Operationerna utan innehåll motsvarar GraphQL-fält som svarar true
+		return contractResult{data: true}
+	case !strings.HasPrefix(resp.Header.Get("Content-Type"), "application/json"):
+		// Filinnehåll jämförs med fileData från downloadFile
+		return contractResult{data: map[string]interface{}{"fileData": base64.StdEncoding.EncodeToString(data)}}
+	}
+
+	var result interface{}
+	if err := json.Unmarshal(data, &result); err != nil {
+		t.Fatalf("decode REST response %q: %v", data, err)
+	}
+	return contractResult{data: result}
+}
+
+// graphQLSelection bygger ett GraphQL-urval med samma fält som REST-representationen
+func graphQLSelection(t reflect.Type) string {
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return ""
+	}
+	var fields []string
+	for i := 0; i < t.NumField(); i++ {
+		name, _ := restJSONField(t.Field(i))
+		fields = append(fields, strings.TrimSpace(name+" "+graphQLSelection(t.Field(i).Type)))
+	}
+	return "{ " + strings.Join(fields, " ") + " }"
+}
+
+// normalizeContract ersätter tidsstämplar och token, som skiljer sig mellan två körningar
+func normalizeContract(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			if (key == "createdAt" || key == "updatedAt" || key == "token") && item != nil {
+				v[key] = "set"
+				continue
+			}
+			v[key] = normalizeContract(item)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = normalizeContract(item)
+		}
+	}
+	return value
+}
+
+func contractSteps() []contractStep {
+	metadata := []map[string]interface{}{{"key": "year", "value": "2024", "type": "INTEGER"}}
+	upload := map[string]interface{}{
+		"name": "a.txt", "size": 5, "contentType": "text/plain", "fileData": "aGVsbG8=", "nodeId": "3",
+		"metadata": []map[string]interface{}{{"key": "k", "value": "v"}},
+	}
+
+	return []contractStep{
+		// Användare och inloggning
+		{
+			name: "create user", token: "admin", route: "createUser",
+			query:     `mutation($username: String!, $password: String!, $name: String) { createUser(username: $username, password: $password, name: $name) %USER% }`,
+			variables: map[string]interface{}{"username": "bob", "password": "secret", "name": "Bob"}, field: "createUser",
+			method: http.MethodPost, path: "users", body: map[string]interface{}{"username": "bob", "password": "secret", "name": "Bob"}, status: http.StatusCreated,
+		},
+		{
+			name: "create user as non-admin", token: "bob", route: "createUser",
+			query:     `mutation($username: String!, $password: String!) { createUser(username: $username, password: $password) %USER% }`,
+			variables: map[string]interface{}{"username": "eve", "password": "secret"}, field: "createUser",
+			method: http.MethodPost, path: "users", body: map[string]interface{}{"username": "eve", "password": "secret"}, status: http.StatusForbidden,
+		},
+		{
+			name: "create duplicate user", token: "admin", route: "createUser",
+			query:     `mutation($username: String!, $password: String!) { createUser(username: $username, password: $password) %USER% }`,
+			variables: map[string]interface{}{"username": "bob", "password": "secret"}, field: "createUser",
+			method: http.MethodPost, path: "users", body: map[string]interface{}{"username": "bob", "password": "secret"}, status: http.StatusConflict,
+		},
+		{
+			name: "login", route: "login",
+			query:     `mutation($username: String!, $password: String!) { login(username: $username, password: $password) %AUTH% }`,
+			variables: map[string]interface{}{"username": "bob", "password": "secret"}, field: "login",
+			method: http.MethodPost, path: "auth/login", body: map[string]interface{}{"username": "bob", "password": "secret"}, status: http.StatusOK,
+			saveToken: "session",
+		},
+		{
+			name: "login with wrong password", route: "login",
+			query:     `mutation($username: String!, $password: String!) { login(username: $username, password: $password) %AUTH% }`,
+			variables: map[string]interface{}{"username": "bob", "password": "wrong"}, field: "login",
+			method: http.MethodPost, path: "auth/login", body: map[string]interface{}{"username": "bob", "password": "wrong"}, status: http.StatusUnauthorized,
+		},
+		{
+			name: "current user", token: "session", route: "getCurrentUser",
+			query: `{ me %USER% }`, field: "me",
+			method: http.MethodGet, path: "users/me", status: http.StatusOK,
+		},
+		{
+			name: "current user without token", route: "getCurrentUser",
+			query: `{ me %USER% }`, field: "me",
+			method: http.MethodGet, path: "users/me", status: http.StatusUnauthorized,
+		},
+		{
+			name: "list users", token: "admin", route: "listUsers",
+			query: `{ getUsers %USER% }`, field: "getUsers",
+			method: http.MethodGet, path: "users", status: http.StatusOK,
+		},
+		{
+			name: "list users as non-admin", token: "bob", route: "listUsers",
+			query: `{ getUsers %USER% }`, field: "getUsers",
+			method: http.MethodGet, path: "users", status: http.StatusForbidden,
+		},
+		{
+			name: "get user", token: "admin", route: "getUser",
+			query: `{ getUserById(id: "2") %USER% }`, field: "getUserById",
+			method: http.MethodGet, path: "users/2", status: http.StatusOK,
+		},
+		{
+			name: "get other user as non-admin", token: "bob", route: "getUser",
+			query: `{ getUserById(id: "1") %USER% }`, field: "getUserById",
+			method: http.MethodGet, path: "users/1", status: http.StatusForbidden,
+		},
+		{
+			name: "update user", token: "admin", route: "updateUser",
+			query: `mutation { updateUser(id: "2", name: "Robert") %USER% }`, field: "updateUser",
+			method: http.MethodPatch, path: "users/2", body: map[string]interface{}{"name": "Robert"}, status: http.StatusOK,
+		},
+		{
+			name: "update own password", token: "bob", route: "updateUserPassword",
+			query: `mutation { updateUserPassword(userId: "2", newPassword: "secret2") }`, field: "updateUserPassword",
+			method: http.MethodPut, path: "users/2/password", body: map[string]interface{}{"newPassword": "secret2"}, status: http.StatusNoContent,
+		},
+		{
+			name: "update admin password as non-admin", token: "bob", route: "updateUserPassword",
+			query: `mutation { updateUserPassword(userId: "1", newPassword: "secret2") }`, field: "updateUserPassword",
+			method: http.MethodPut, path: "users/1/password", body: map[string]interface{}{"newPassword": "secret2"}, status: http.StatusForbidden,
+		},
+
+		// Noder
+		{
+			name: "create root node", token: "admin", route: "createNode",
+			query: `mutation($input: NodeInput!) { createNode(input: $input) %NODE% }`, variables: map[string]interface{}{"input": map[string]interface{}{"name": "Arkiv"}}, field: "createNode",
+			method: http.MethodPost, path: "nodes", body: map[string]interface{}{"name": "Arkiv"}, status: http.StatusCreated,
+		},
+		{
+			name: "create child node", token: "admin", route: "createNode",
+			query: `mutation($input: NodeInput!) { createNode(input: $input) %NODE% }`, variables: map[string]interface{}{"input": map[string]interface{}{"name": "Serie", "parentId": "2"}}, field: "createNode",
+			method: http.MethodPost, path: "nodes", body: map[string]interface{}{"name": "Serie", "parentId": "2"}, status: http.StatusCreated,
+		},
+		{
+			name: "create second root node", token: "admin", route: "createNode",
+			query: `mutation($input: NodeInput!) { createNode(input: $input) %NODE% }`, variables: map[string]interface{}{"input": map[string]interface{}{"name": "Annat"}}, field: "createNode",
+			method: http.MethodPost, path: "nodes", body: map[string]interface{}{"name": "Annat"}, status: http.StatusCreated,
+		},
+		{
+			name: "list root nodes", token: "admin", route: "listRootNodes",
+			query: `{ getRootNodes %NODE% }`, field: "getRootNodes",
+			method: http.MethodGet, path: "nodes", status: http.StatusOK,
+		},
+		{
+			name: "get node", token: "admin", route: "getNode",
+			query: `{ getNodeById(id: "2") %NODE% }`, field: "getNodeById",
+			method: http.MethodGet, path: "nodes/2", status: http.StatusOK,
+		},
+		{
+			name: "get node as non-admin", token: "bob", route: "getNode",
+			query: `{ getNodeById(id: "2") %NODE% }`, field: "getNodeById",
+			method: http.MethodGet, path: "nodes/2", status: http.StatusForbidden,
+		},
+		{
+			name: "get missing node", token: "admin", route: "getNode",
+			query: `{ getNodeById(id: "99") %NODE% }`, field: "getNodeById",
+			method: http.MethodGet, path: "nodes/99", status: http.StatusNotFound,
+		},
+		{
+			name: "list child nodes", token: "admin", route: "listChildNodes",
+			query: `{ getChildNodes(parentId: "2") %NODE% }`, field: "getChildNodes",
+			method: http.MethodGet, path: "nodes/2/children", status: http.StatusOK,
+		},
+		{
+			name: "list children of missing node", token: "admin", route: "listChildNodes",
+			query: `{ getChildNodes(parentId: "99") %NODE% }`, field: "getChildNodes",
+			method: http.MethodGet, path: "nodes/99/children", status: http.StatusNotFound,
+		},
+		{
+			name: "update node", token: "admin", route: "updateNode",
+			query: `mutation { updateNode(id: "3", input: {name: "Serie A"}) %NODE% }`, field: "updateNode",
+			method: http.MethodPatch, path: "nodes/3", body: map[string]interface{}{"name": "Serie A"}, status: http.StatusOK,
+		},
+		{
+			name: "update node metadata", token: "admin", route: "updateNodeMetadata",
+			query: `mutation($metadata: [MetadataInput]!) { updateNodeMetadata(nodeId: "3", metadataInput: $metadata) %NODE% }`, variables: map[string]interface{}{"metadata": metadata}, field: "updateNodeMetadata",
+			method: http.MethodPut, path: "nodes/3/metadata", body: metadata, status: http.StatusOK,
+		},
+		{
+			name: "move node", token: "admin", route: "moveNode",
+			query: `mutation { moveNode(id: "3", newParentId: "4") %NODE% }`, field: "moveNode",
+			method: http.MethodPost, path: "nodes/3/move", body: map[string]interface{}{"parentId": "4"}, status: http.StatusOK,
+		},
+		{
+			name: "move node below itself", token: "admin", route: "moveNode",
+			query: `mutation { moveNode(id: "4", newParentId: "3") %NODE% }`, field: "moveNode",
+			method: http.MethodPost, path: "nodes/4/move", body: map[string]interface{}{"parentId": "3"}, status: http.StatusConflict,
+		},
+
+		// Filer
+		{
+			name: "save file", token: "admin", route: "saveFile",
+			query: `mutation($input: FileInput!) { saveFile(input: $input) %FILE% }`, variables: map[string]interface{}{"input": upload}, field: "saveFile",
+			method: http.MethodPost, path: "files", body: upload, status: http.StatusCreated,
+		},
+		{
+			name: "list files", token: "admin", route: "listFiles",
+			query: `{ getFiles %FILE% }`, field: "getFiles",
+			method: http.MethodGet, path: "files", status: http.StatusOK,
+		},
+		{
+			name: "get file", token: "admin", route: "getFile",
+			query: `{ getFile(id: "1") %FILE% }`, field: "getFile",
+			method: http.MethodGet, path: "files/1", status: http.StatusOK,
+		},
+		{
+			name: "get missing file", token: "admin", route: "getFile",
+			query: `{ getFile(id: "99") %FILE% }`, field: "getFile",
+			method: http.MethodGet, path: "files/99", status: http.StatusNotFound,
+		},
+		{
+			name: "list node files", token: "admin", route: "listNodeFiles",
+			query: `{ getFilesByNodeId(nodeId: "3") %FILE% }`, field: "getFilesByNodeId",
+			method: http.MethodGet, path: "nodes/3/files", status: http.StatusOK,
+		},
+		{
+			name: "download file", token: "admin", route: "downloadFile",
+			query: `{ downloadFile(id: "1") { fileData } }`, field: "downloadFile",
+			method: http.MethodGet, path: "files/1/content", status: http.StatusOK,
+		},
+		{
+			name: "rename file as non-admin", token: "bob", route: "renameFile",
+			query: `mutation { renameFile(id: "1", name: "b.txt") %FILE% }`, field: "renameFile",
+			method: http.MethodPatch, path: "files/1", body: map[string]interface{}{"name": "b.txt"}, status: http.StatusForbidden,
+		},
+		{
+			name: "rename file", token: "admin", route: "renameFile",
+			query: `mutation { renameFile(id: "1", name: "b.txt") %FILE% }`, field: "renameFile",
+			method: http.MethodPatch, path: "files/1", body: map[string]interface{}{"name": "b.txt"}, status: http.StatusOK,
+		},
+		{
+			name: "update file metadata", token: "admin", route: "updateFileMetadata",
+			query: `mutation($metadata: [MetadataInput]!) { updateMetadata(fileId: "1", metadataInput: $metadata) %FILE% }`, variables: map[string]interface{}{"metadata": metadata}, field: "updateMetadata",
+			method: http.MethodPut, path: "files/1/metadata", body: metadata, status: http.StatusOK,
+		},
+		{
+			name: "delete file metadata", token: "admin", route: "deleteFileMetadata",
+			query: `mutation { deleteMetadata(fileId: "1", keys: ["k"]) %FILE% }`, field: "deleteMetadata",
+			method: http.MethodDelete, path: "files/1/metadata?key=k", status: http.StatusOK,
+		},
+		{
+			name: "move file", token: "admin", route: "moveFile",
+			query: `mutation { moveFile(fileId: "1", nodeId: "2") %FILE% }`, field: "moveFile",
+			method: http.MethodPost, path: "files/1/move", body: map[string]interface{}{"nodeId": "2"}, status: http.StatusOK,
+		},
+		{
+			name: "delete file", token: "admin", route: "deleteFile",
+			query: `mutation { deleteFile(id: "1") }`, field: "deleteFile",
+			method: http.MethodDelete, path: "files/1", status: http.StatusNoContent,
+		},
+		{
+			name: "delete missing file", token: "admin", route: "deleteFile",
+			query: `mutation { deleteFile(id: "1") }`, field: "deleteFile",
+			method: http.MethodDelete, path: "files/1", status: http.StatusNotFound,
+		},
+		{
+			name: "delete node with children", token: "admin", route: "deleteNode",
+			query: `mutation { deleteNode(id: "4") }`, field: "deleteNode",
+			method: http.MethodDelete, path: "nodes/4", status: http.StatusConflict,
+		},
+		{
+			name: "delete node", token: "admin", route: "deleteNode",
+			query: `mutation { deleteNode(id: "2") }`, field: "deleteNode",
+			method: http.MethodDelete, path: "nodes/2", status: http.StatusNoContent,
+		},
+
+		// Borttagning av användaren och utloggning
+		{
+			name: "delete user as non-admin", token: "bob", route: "deleteUser",
+			query: `mutation { deleteUser(id: "2") }`, field: "deleteUser",
+			method: http.MethodDelete, path: "users/2", status: http.StatusForbidden,
+		},
+		{
+			name: "delete user", token: "admin", route: "deleteUser",
+			query: `mutation { deleteUser(id: "2") }`, field: "deleteUser",
+			method: http.MethodDelete, path: "users/2", status: http.StatusNoContent,
+		},
+		{
+			name: "logout", token: "session", route: "logout",
+			query: `mutation($token: String!) { logout(token: $token) }`, field: "logout",
+			method: http.MethodPost, path: "auth/logout", status: http.StatusNoContent,
+		},
+		{
+			name: "current user after logout", token: "session", route: "getCurrentUser",
+			query: `{ me %USER% }`, field: "me",
+			method: http.MethodGet, path: "users/me", status: http.StatusUnauthorized,
+		},
+	}
+}
+
+// TestRestAPIMatchesGraphQL kör samma anrop mot GraphQL och REST, med var sin databas, och kräver
+// att svaren och felen är desamma
+func TestRestAPIMatchesGraphQL(t *testing.T) {
+	graphQLEnv, restEnv := newContractEnv(t), newContractEnv(t)
+
+	// Utloggningen spärrar token för hela processen. Samma användare inloggad samma sekund får samma
+	// token, så spärren tas bort för att inte påverka andra tester.
+	t.Cleanup(func() {
+		tokenBlacklistMutex.Lock()
+		defer tokenBlacklistMutex.Unlock()
+		for _, env := range []*contractEnv{graphQLEnv, restEnv} {
+			for _, token := range env.tokens {
+				delete(TokenBlacklist, token)
+			}
+		}
+	})
+
+	covered := map[string]bool{}
+	for _, step := range contractSteps() {
+		covered[step.route] = true
+
+		// Utloggningen tar token som argument i GraphQL och från Authorization-headern i REST
+		if step.field == "logout" {
+			step.variables = map[string]interface{}{"token": graphQLEnv.tokens[step.token]}
+		}
+
+		// REST går först: samma token i båda miljöerna spärras av den första utloggningen, och
+		// GraphQL godtar en redan spärrad token medan REST kräver en giltig
+		got := restEnv.viaREST(t, step)
+		want := graphQLEnv.viaGraphQL(t, step)
+
+		if step.saveToken != "" {
+			graphQLEnv.tokens[step.saveToken] = want.data.(map[string]interface{})["token"].(string)
+			restEnv.tokens[step.saveToken] = got.data.(map[string]interface{})["token"].(string)
+		}
+
+		if want.message != got.message {
+			t.Errorf("%s: error = %q via REST, %q via GraphQL", step.name, got.message, want.message)
+			continue
+		}
+		if want.message == "" && (step.status >= 400) {
+			t.Errorf("%s: GraphQL succeeded but REST was expected to fail", step.name)
+			continue
+		}
+		if !reflect.DeepEqual(normalizeContract(want.data), normalizeContract(got.data)) {
+			wantJSON, _ := json.Marshal(want.data)
+			gotJSON, _ := json.Marshal(got.data)
+			t.Errorf("%s: REST returned\n%s\nGraphQL returned\n%s", step.name, gotJSON, wantJSON)
+		}
+	}
+
+	for _, route := range (&restAPI{}).routes() {
+		if !covered[route.operationID] {
+			t.Errorf("operation %s is not covered by the contract test", route.operationID)
+		}
+	}
+}
+
+// TestRestAPIInputValidation kontrollerar att kroppar avvisas på samma grunder som GraphQL-indata
+func TestRestAPIInputValidation(t *testing.T) {
+	env := newContractEnv(t)
+
+	tests := []struct {
+		name    string
+		body    string
+		message string
+	}{
+		{"missing required field", `{"parentId": "1"}`, "name is required"},
+		{"unknown field", `{"name": "x", "title": "x"}`, `invalid request body: json: unknown field "title"`},
+		{"invalid enum", `{"name": "x", "nodeType": "SHELF"}`, "SHELF is not a valid NodeType"},
+		{"empty body", ``, "request body is required"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := env.request(t, http.MethodPost, env.rest.URL+RestAPIPath+"nodes", "admin", []byte(tt.body))
+			defer resp.Body.Close()
+
+			var restErr restError
+			json.NewDecoder(resp.Body).Decode(&restErr)
+			if resp.StatusCode != http.StatusBadRequest || restErr.Error != tt.message {
+				t.Errorf("got %d %q, want 400 %q", resp.StatusCode, restErr.Error, tt.message)
+			}
+		})
+	}
+}
+
+// TestRestAPIRequiresAuthentication anropar varje operation som inte är öppen utan token
+func TestRestAPIRequiresAuthentication(t *testing.T) {
+	env := newContractEnv(t)
+	insertTestNode(t, env.db, "Arkiv", "")
+	insertTestFile(t, env.db, "brev.txt", "1")
+
+	for _, route := range (&restAPI{}).routes() {
+		if route.public {
+			continue
+		}
+		t.Run(route.operationID, func(t *testing.T) {
+			var body []byte
+			if route.body != nil {
+				body = []byte("{}")
+			}
+			resp := env.request(t, route.method, env.rest.URL+RestAPIPath+strings.ReplaceAll(route.pattern, "{id}", "1"), "", body)
+			defer resp.Body.Close()
+
+			var restErr restError
+			json.NewDecoder(resp.Body).Decode(&restErr)
+			if resp.StatusCode != http.StatusUnauthorized || restErr.Error != "not authenticated" {
+				t.Errorf("got %d %q, want 401 not authenticated", resp.StatusCode, restErr.Error)
+			}
+		})
+	}
+
+	// En utloggad token räknas som ingen token. Spärren gäller hela processen, så token får en
+	// egen användare som inga andra tester loggar in som.
+	token, err := generateJWT("utloggad", "utloggad")
+	if err != nil {
+		t.Fatal(err)
+	}
+	BlacklistToken(token)
+	t.Cleanup(func() {
+		tokenBlacklistMutex.Lock()
+		defer tokenBlacklistMutex.Unlock()
+		delete(TokenBlacklist, token)
+	})
+	env.tokens["utloggad"] = token
+	resp := env.request(t, http.MethodGet, env.rest.URL+RestAPIPath+"files", "utloggad", nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("list files with invalidated token: status %d, want 401", resp.StatusCode)
+	}
+}
+
+// TestErrorStatusFollowsErrorKind kontrollerar att REST, WebDAV och ZIP-exporten väljer status
+// efter felets sort och inte efter meddelandet
+func TestErrorStatusFollowsErrorKind(t *testing.T) {
+	db := openTestDB(t)
+	services := NewServices(db)
+	admin := testAdminContext(t)
+	bobID := insertTestUser(t, db, "bob")
+
+	parent := createTestNode(t, db, "Arkiv", nil)
+	createTestNode(t, db, "Serie", &parent.ID)
+	file := saveTestFile(t, db, "brev.txt", parent.ID, "brevet")
+
+	serviceError := func(_ interface{}, err error) error {
+		t.Helper()
+		if err == nil {
+			t.Fatal("expected an error")
+		}
+		return err
+	}
+	_, unauthenticated := getUserIDFromContext(context.Background())
+	notFound := serviceError(services.Nodes.Delete(admin, "999"))
+
+	tests := []struct {
+		name                 string
+		err                  error
+		rest, dav, zipExport int
+	}{
+		{"permission denied", serviceError(services.Files.Delete(testUserContext(t, bobID, "bob"), file.ID)),
+			http.StatusForbidden, http.StatusForbidden, http.StatusForbidden},
+		{"not authenticated", unauthenticated, http.StatusUnauthorized, http.StatusUnauthorized, http.StatusUnauthorized},
+		{"not found", notFound, http.StatusNotFound, http.StatusNotFound, http.StatusNotFound},
+		{"wrapped not found", fmt.Errorf("export: %w", notFound), http.StatusNotFound, http.StatusNotFound, http.StatusNotFound},
+		{"conflict", serviceError(services.Nodes.Delete(admin, parent.ID)),
+			http.StatusConflict, http.StatusForbidden, http.StatusInternalServerError},
+		{"legal hold", legalHoldError(&model.LegalHold{ID: "1", NodeID: parent.ID, Reason: "tvist"}, "delete node"),
+			http.StatusConflict, http.StatusForbidden, http.StatusInternalServerError},
+		{"internal", serviceErrorf(errInternal, "failed to fetch node: %v", io.ErrUnexpectedEOF),
+			http.StatusInternalServerError, http.StatusInternalServerError, http.StatusInternalServerError},
+		// Ett fel utan sort beror på indata, även om meddelandet liknar ett annat fel
+		{"invalid input", fmt.Errorf("node not found"), http.StatusBadRequest, http.StatusForbidden, http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if status := restErrorStatus(tt.err); status != tt.rest {
+				t.Errorf("REST status for %q = %d, want %d", tt.err, status, tt.rest)
+			}
+			if status := davErrorStatus(tt.err); status != tt.dav {
+				t.Errorf("WebDAV status for %q = %d, want %d", tt.err, status, tt.dav)
+			}
+			if status := zipExportErrorStatus(tt.err); status != tt.zipExport {
+				t.Errorf("ZIP export status for %q = %d, want %d", tt.err, status, tt.zipExport)
+			}
+		})
+	}
+}
+
+// TestOpenAPIDocumentListsRoutes kontrollerar att dokumentet beskriver varje operation
+func TestOpenAPIDocumentListsRoutes(t *testing.T) {
+	env := newContractEnv(t)
+
+	resp := env.request(t, http.MethodGet, env.rest.URL+RestAPIPath+"openapi.json", "", nil)
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+
+	var document struct {
+		OpenAPI    string                                       `json:"openapi"`
+		Paths      map[string]map[string]map[string]interface{} `json:"paths"`
+		Components struct {
+			Schemas map[string]interface{} `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&document); err != nil {
+		t.Fatalf("decode document: %v", err)
+	}
+	if document.OpenAPI != "3.0.3" {
+		t.Errorf("openapi = %q, want 3.0.3", document.OpenAPI)
+	}
+
+	for _, route := range (&restAPI{}).routes() {
+		operation, ok := document.Paths[RestAPIPath+route.pattern][strings.ToLower(route.method)]
+		if !ok {
+			t.Errorf("%s %s is missing from the document", route.method, route.pattern)
+			continue
+		}
+		if operation["operationId"] != route.operationID {
+			t.Errorf("%s %s has operationId %v, want %s", route.method, route.pattern, operation["operationId"], route.operationID)
+		}
+	}
+
+	// Varje referens ska peka på ett schema i components
+	raw, _ := json.Marshal(document.Paths)
+	for _, ref := range strings.Split(string(raw), `"$ref":"#/components/schemas/`)[1:] {
+		name := ref[:strings.Index(ref, `"`)]
+		if _, ok := document.Components.Schemas[name]; !ok {
+			t.Errorf("schema %s is referenced but not defined", name)
+		}
+	}
+}
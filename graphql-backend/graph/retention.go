@@ -0,0 +1,443 @@
+package graph
+
+import (
+	"database/sql"
+	"fmt"
+	"graphql-backend/graph/model"
+	"log"
+	"strings"
+	"time"
+)
+
+// =============================================
+// ========== BEVARINGSTID OCH KASSATION =====
+// =============================================
+
+// Utlösande fält som räknar bevaringstiden från filens skapandedatum
+const RETENTION_TRIGGER_CREATED_AT = "createdAt"
+
+// Datumformat för beräknade datum i API:et
+const retentionDateLayout = "2006-01-02"
+
+// fileRetentionInfo är den beräknade bevaringstiden för en fil
+type fileRetentionInfo struct {
+	Rule         *model.RetentionRule
+	RuleNodeID   string
+	TriggerDate  *time.Time // nil om det utlösande fältet saknas eller inte är ett datum
+	DisposalDate *time.Time
+	Due          bool
+}
+
+// retentionNode är de uppgifter om en nod som behövs för att beräkna bevaringstid
+type retentionNode struct {
+	parentID string
+	ruleID   string
+	fields   map[string]string
+}
+
+// retentionCalculator beräknar kassationsdatum och cachar noder och regler under en beräkning
+type retentionCalculator struct {
+	db    *sql.DB
+	asOf  time.Time
+	nodes map[string]*retentionNode
+	rules map[string]*model.RetentionRule
+}
+
+// newRetentionCalculator skapar en kalkylator där filer med kassationsdatum senast asOf räknas som förfallna
+func newRetentionCalculator(db *sql.DB, asOf time.Time) *retentionCalculator {
+	return &retentionCalculator{
+		db:    db,
+		asOf:  asOf,
+		nodes: make(map[string]*retentionNode),
+		rules: make(map[string]*model.RetentionRule),
+	}
+}
+
+// node hämtar en nods förälder, bevaringsregel och Noark 5-fält
+func (c *retentionCalculator) node(nodeID string) (*retentionNode, error) {
+	if node, ok := c.nodes[nodeID]; ok {
+		return node, nil
+	}
+
+	var parentID, ruleID sql.NullString
+	err := c.db.QueryRow("SELECT parent_id, retention_rule_id FROM nodes WHERE id = ?", nodeID).Scan(&parentID, &ruleID)
+	if err == sql.ErrNoRows {
+		return nil, serviceErrorf(errNotFound, "node not found")
+	} else if err != nil {
+		log.Printf("Error fetching retention rule of node %s: %v", nodeID, err)
+		return nil, serviceErrorf(errInternal, "failed to fetch node: %v", err)
+	}
+
+	fields, err := loadTypedFields(c.db, ENTITY_KIND_NODE, nodeID)
+	if err != nil {
+		return nil, err
+	}
+
+	node := &retentionNode{parentID: parentID.String, ruleID: ruleID.String, fields: fields}
+	c.nodes[nodeID] = node
+	return node, nil
+}
+
+// rule hämtar en bevaringsregel
+func (c *retentionCalculator) rule(ruleID string) (*model.RetentionRule, error) {
+	if rule, ok := c.rules[ruleID]; ok {
+		return rule, nil
+	}
+
+	rule, err := getRetentionRule(c.db, ruleID)
+	if err != nil {
+		return nil, err
+	}
+	c.rules[ruleID] = rule
+	return rule, nil
+}
+
+// effectiveRule hämtar regeln som gäller för en nod, ärvd från närmaste förälder med en regel
+func (c *retentionCalculator) effectiveRule(nodeID string) (*model.RetentionRule, string, error) {
+	visited := make(map[string]bool)
+	for currentID := nodeID; currentID != "" && !visited[currentID]; {
+		visited[currentID] = true
+
+		node, err := c.node(currentID)
+		if err != nil {
+			return nil, "", err
+		}
+		if node.ruleID != "" {
+			rule, err := c.rule(node.ruleID)
+			if err != nil {
+				return nil, "", err
+			}
+			return rule, currentID, nil
+		}
+		currentID = node.parentID
+	}
+	return nil, "", nil
+}
+
+// triggerValue letar upp det utlösande fältet i filens Noark 5-fält, filens metadata
+// och sedan i nodernas Noark 5-fält uppåt i trädet (t.ex. saksdato på saksmappen)
+func (c *retentionCalculator) triggerValue(file *treeFile, field string) (string, error) {
+	if field == RETENTION_TRIGGER_CREATED_AT {
+		return file.CreatedAt, nil
+	}
+	if value := file.Fields[field]; value != "" {
+		return value, nil
+	}
+	if value := metadataValue(file.Metadata, field, ""); value != "" {
+		return value, nil
+	}
+
+	visited := make(map[string]bool)
+	for currentID := file.NodeID; currentID != "" && !visited[currentID]; {
+		visited[currentID] = true
+
+		node, err := c.node(currentID)
+		if err != nil {
+			return "", err
+		}
+		if value := node.fields[field]; value != "" {
+			return value, nil
+		}
+		currentID = node.parentID
+	}
+	return "", nil
+}
+
+// fileRetention beräknar bevaringstid och kassationsdatum för en fil, eller nil om ingen regel gäller
+func (c *retentionCalculator) fileRetention(file *treeFile) (*fileRetentionInfo, error) {
+	rule, ruleNodeID, err := c.effectiveRule(file.NodeID)
+	if err != nil || rule == nil {
+		return nil, err
+	}
+
+	info := &fileRetentionInfo{Rule: rule, RuleNodeID: ruleNodeID}
+
+	value, err := c.triggerValue(file, rule.TriggerField)
+	if err != nil {
+		return nil, err
+	}
+	triggerDate, ok := parseRecordDate(value)
+	if !ok {
+		return info, nil
+	}
+
+	disposalDate := triggerDate.AddDate(rule.RetentionYears, 0, 0)
+	info.TriggerDate = &triggerDate
+	info.DisposalDate = &disposalDate
+	info.Due = rule.DisposalAction != model.DisposalActionRetain && !disposalDate.After(c.asOf)
+	return info, nil
+}
+
+// dueFiles hämtar alla filer under en nod (eller i hela arkivet) vars kassationsdatum har passerats
+func (c *retentionCalculator) dueFiles(nodeID *string) ([]*treeFile, error) {
+	var startIDs []string
+	if nodeID != nil {
+		startIDs = []string{*nodeID}
+	} else {
+		rows, err := c.db.Query("SELECT id FROM nodes WHERE parent_id IS NULL")
+		if err != nil {
+			log.Printf("Error fetching root nodes: %v", err)
+			return nil, serviceErrorf(errInternal, "failed to fetch root nodes: %v", err)
+		}
+		if startIDs, err = scanIDs(rows); err != nil {
+			return nil, err
+		}
+	}
+
+	var due []*treeFile
+	pending := startIDs
+	for len(pending) > 0 {
+		currentID := pending[0]
+		pending = pending[1:]
+
+		files, err := loadTreeFiles(c.db, currentID)
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			info, err := c.fileRetention(file)
+			if err != nil {
+				return nil, err
+			}
+			if info != nil && info.Due {
+				due = append(due, file)
+			}
+		}
+
+		rows, err := c.db.Query("SELECT id FROM nodes WHERE parent_id = ? ORDER BY name ASC", currentID)
+		if err != nil {
+			log.Printf("Error fetching child nodes of %s: %v", currentID, err)
+			return nil, serviceErrorf(errInternal, "failed to fetch child nodes: %v", err)
+		}
+		childIDs, err := scanIDs(rows)
+		if err != nil {
+			return nil, err
+		}
+		pending = append(pending, childIDs...)
+	}
+
+	return due, nil
+}
+
+// parseRecordDate tolkar ett datum- eller tidsfält; ok är false om värdet inte är ett datum
+func parseRecordDate(value string) (time.Time, bool) {
+	value = strings.TrimSpace(value)
+	for _, layout := range []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02T15:04:05", retentionDateLayout} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// fileRetentionModel konverterar en beräknad bevaringstid till GraphQL-modellen
+func fileRetentionModel(info *fileRetentionInfo) *model.FileRetention {
+	retention := &model.FileRetention{
+		Rule:       info.Rule,
+		RuleNodeID: info.RuleNodeID,
+		Due:        info.Due,
+	}
+	if info.TriggerDate != nil {
+		triggerDate := info.TriggerDate.Format(retentionDateLayout)
+		disposalDate := info.DisposalDate.Format(retentionDateLayout)
+		retention.TriggerDate = &triggerDate
+		retention.DisposalDate = &disposalDate
+	}
+	return retention
+}
+
+// treeFileModel konverterar en fil i ett nodträd till GraphQL-modellen (utan binärdata)
+func treeFileModel(file *treeFile) *model.File {
+	nodeID := file.NodeID
+	return &model.File{
+		ID:          file.ID,
+		Name:        file.Name,
+		Size:        file.Size,
+		ContentType: file.ContentType,
+		CreatedAt:   file.CreatedAt,
+		Metadata:    file.Metadata,
+		NodeID:      &nodeID,
+		FileType:    file.FileType,
+	}
+}
+
+// =============================================
+// ========== BEVARINGSREGLER =================
+// =============================================
+
+// validateRetentionRuleInput kontrollerar en bevaringsregel och sätter standardvärden
+func validateRetentionRuleInput(input model.RetentionRuleInput) (string, string, error) {
+	name := strings.TrimSpace(input.Name)
+	if name == "" {
+		return "", "", fmt.Errorf("retention rule name is required")
+	}
+	if input.RetentionYears < 0 {
+		return "", "", fmt.Errorf("retention period cannot be negative")
+	}
+	if !input.DisposalAction.IsValid() {
+		return "", "", fmt.Errorf("invalid disposal action %s", input.DisposalAction)
+	}
+
+	triggerField := RETENTION_TRIGGER_CREATED_AT
+	if input.TriggerField != nil && strings.TrimSpace(*input.TriggerField) != "" {
+		triggerField = strings.TrimSpace(*input.TriggerField)
+	}
+	return name, triggerField, nil
+}
+
+// getRetentionRule hämtar en bevaringsregel
+func getRetentionRule(db sqlQueryer, ruleID string) (*model.RetentionRule, error) {
+	var rule model.RetentionRule
+	var action string
+	var description sql.NullString
+	err := db.QueryRow(`
+		SELECT id, name, retention_years, trigger_field, disposal_action, description, created_at
+		FROM retention_rules WHERE id = ?
+	`, ruleID).Scan(&rule.ID, &rule.Name, &rule.RetentionYears, &rule.TriggerField, &action, &description, &rule.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, serviceErrorf(errNotFound, "retention rule not found")
+	} else if err != nil {
+		log.Printf("Error fetching retention rule %s: %v", ruleID, err)
+		return nil, serviceErrorf(errInternal, "failed to fetch retention rule: %v", err)
+	}
+
+	rule.DisposalAction = model.DisposalAction(action)
+	rule.Description = nullStringPtr(description)
+	return &rule, nil
+}
+
+// =============================================
+// ========== KASSATIONSBEGÄRANDEN ============
+// =============================================
+
+// getDisposalRequest hämtar en kassationsbegäran
+func getDisposalRequest(db sqlQueryer, requestID string) (*model.DisposalRequest, error) {
+	var request model.DisposalRequest
+	var status string
+	var reason, decidedBy, decidedAt, note sql.NullString
+	err := db.QueryRow(`
+		SELECT id, status, reason, requested_by, requested_at, decided_by, decided_at, decision_note
+		FROM disposal_requests WHERE id = ?
+	`, requestID).Scan(&request.ID, &status, &reason, &request.RequestedByID, &request.RequestedAt, &decidedBy, &decidedAt, &note)
+	if err == sql.ErrNoRows {
+		return nil, serviceErrorf(errNotFound, "disposal request not found")
+	} else if err != nil {
+		log.Printf("Error fetching disposal request %s: %v", requestID, err)
+		return nil, serviceErrorf(errInternal, "failed to fetch disposal request: %v", err)
+	}
+
+	request.Status = model.DisposalRequestStatus(status)
+	request.Reason = nullStringPtr(reason)
+	request.DecidedByID = nullStringPtr(decidedBy)
+	request.DecidedAt = nullStringPtr(decidedAt)
+	request.DecisionNote = nullStringPtr(note)
+	return &request, nil
+}
+
+// loadDisposalRequestFileIDs hämtar ID för filerna i en kassationsbegäran
+func loadDisposalRequestFileIDs(db sqlQueryer, requestID string) ([]string, error) {
+	rows, err := db.Query("SELECT file_id FROM disposal_request_files WHERE request_id = ? ORDER BY file_id ASC", requestID)
+	if err != nil {
+		log.Printf("Error fetching files of disposal request %s: %v", requestID, err)
+		return nil, serviceErrorf(errInternal, "failed to fetch disposal request files: %v", err)
+	}
+	return scanIDs(rows)
+}
+
+// loadDisposalCertificates hämtar kassationsbevis, för en begäran eller alla
+func loadDisposalCertificates(db sqlQueryer, requestID *string) ([]*model.DisposalCertificate, error) {
+	query := `
+		SELECT id, request_id, file_id, file_name, node_id, file_type, size, checksum, puid,
+			rule_name, retention_years, trigger_field, trigger_date, disposal_date, disposed_at, requested_by, approved_by
+		FROM disposal_certificates`
+	args := []interface{}{}
+	if requestID != nil {
+		query += " WHERE request_id = ?"
+		args = append(args, *requestID)
+	}
+	query += " ORDER BY id ASC"
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		log.Printf("Error fetching disposal certificates: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to fetch disposal certificates: %v", err)
+	}
+	defer rows.Close()
+
+	certificates := []*model.DisposalCertificate{}
+	for rows.Next() {
+		var certificate model.DisposalCertificate
+		var nodeID, fileType, checksum, puid sql.NullString
+		if err := rows.Scan(&certificate.ID, &certificate.RequestID, &certificate.FileID, &certificate.FileName, &nodeID, &fileType,
+			&certificate.Size, &checksum, &puid, &certificate.RuleName, &certificate.RetentionYears, &certificate.TriggerField,
+			&certificate.TriggerDate, &certificate.DisposalDate, &certificate.DisposedAt, &certificate.RequestedBy, &certificate.ApprovedBy); err != nil {
+			log.Printf("Error scanning disposal certificate: %v", err)
+			return nil, serviceErrorf(errInternal, "failed to scan disposal certificate: %v", err)
+		}
+		certificate.NodeID = nullStringPtr(nodeID)
+		certificate.FileType = nullStringPtr(fileType)
+		certificate.Checksum = nullStringPtr(checksum)
+		certificate.Puid = nullStringPtr(puid)
+		certificates = append(certificates, &certificate)
+	}
+
+	return certificates, rows.Err()
+}
+
+// disposeFile tar bort en fil med allt innehåll och skriver ett kassationsbevis i samma transaktion
+func disposeFile(tx *sql.Tx, requestID string, file *treeFile, info *fileRetentionInfo, requestedBy string, approvedBy string) error {
+	_, err := tx.Exec(`
+		INSERT INTO disposal_certificates (request_id, file_id, file_name, node_id, file_type, size, checksum, puid,
+			rule_name, retention_years, trigger_field, trigger_date, disposal_date, disposed_at, requested_by, approved_by)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, requestID, file.ID, file.Name, file.NodeID, file.FileType, file.Size, optionalString(file.Checksum), optionalString(file.PUID),
+		info.Rule.Name, info.Rule.RetentionYears, info.Rule.TriggerField, info.TriggerDate.Format(retentionDateLayout),
+		info.DisposalDate.Format(retentionDateLayout), time.Now().Format(time.RFC3339), requestedBy, approvedBy)
+	if err != nil {
+		log.Printf("Error writing disposal certificate for file %s: %v", file.ID, err)
+		return serviceErrorf(errInternal, "failed to write disposal certificate: %v", err)
+	}
+
+	// Filen tas bort tillsammans med metadata, Noark 5-fält och bevarandehändelser
+	for _, statement := range []string{
+		"DELETE FROM metadata WHERE file_id = ?",
+		"DELETE FROM preservation_events WHERE file_id = ?",
+		"DELETE FROM typed_fields WHERE entity_kind = '" + ENTITY_KIND_FILE + "' AND entity_id = ?",
+		"DELETE FROM files WHERE id = ?",
+	} {
+		if _, err := tx.Exec(statement, file.ID); err != nil {
+			log.Printf("Error disposing of file %s: %v", file.ID, err)
+			return serviceErrorf(errInternal, "failed to dispose of file %s: %v", file.ID, err)
+		}
+	}
+
+	return nil
+}
+
+// lookupUsername hämtar användarnamnet för ett användar-ID
+func lookupUsername(db sqlQueryer, userID string) (string, error) {
+	var username string
+	err := db.QueryRow("SELECT username FROM users WHERE id = ?", userID).Scan(&username)
+	if err == sql.ErrNoRows {
+		return "", serviceErrorf(errNotFound, "user not found")
+	} else if err != nil {
+		log.Printf("Error fetching username for user %s: %v", userID, err)
+		return "", serviceErrorf(errInternal, "failed to fetch user: %v", err)
+	}
+	return username, nil
+}
+
+// getUserByID hämtar en användare, eller nil om den inte finns
+func getUserByID(db sqlQueryer, userID string) (*model.User, error) {
+	var user model.User
+	err := db.QueryRow("SELECT id, username FROM users WHERE id = ?", userID).Scan(&user.ID, &user.Username)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		log.Printf("Error fetching user %s: %v", userID, err)
+		return nil, serviceErrorf(errInternal, "failed to fetch user: %v", err)
+	}
+
+	user.Name = user.Username // Using username as name for simplicity
+	return &user, nil
+}
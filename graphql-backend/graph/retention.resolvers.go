@@ -0,0 +1,577 @@
+package graph
+
+// This file will be automatically regenerated based on the schema, any resolver implementations
+// will be copied through when generating and any unknown code will be moved to the end.
+// Code generated by github.com/99designs/gqlgen version v0.17.69
+
+import (
+	"context"
+	"fmt"
+	"graphql-backend/graph/model"
+	"log"
+	"strings"
+	"time"
+)
+
+// RequestedBy is the resolver for the requestedBy field.
+func (r *disposalRequestResolver) RequestedBy(ctx context.Context, obj *model.DisposalRequest) (*model.User, error) {
+	return getUserByID(r.DB, obj.RequestedByID)
+}
+
+// DecidedBy is the resolver for the decidedBy field.
+func (r *disposalRequestResolver) DecidedBy(ctx context.Context, obj *model.DisposalRequest) (*model.User, error) {
+	if obj.DecidedByID == nil {
+		return nil, nil
+	}
+	return getUserByID(r.DB, *obj.DecidedByID)
+}
+
+// Files is the resolver for the files field.
+func (r *disposalRequestResolver) Files(ctx context.Context, obj *model.DisposalRequest) ([]*model.File, error) {
+	fileIDs, err := loadDisposalRequestFileIDs(r.DB, obj.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Kasserade filer finns inte längre och redovisas i stället genom kassationsbevisen
+	files := []*model.File{}
+	for _, fileID := range fileIDs {
+		file, err := loadTreeFile(r.DB, fileID)
+		if err != nil {
+			continue
+		}
+		files = append(files, treeFileModel(file))
+	}
+	return files, nil
+}
+
+// Certificates is the resolver for the certificates field.
+func (r *disposalRequestResolver) Certificates(ctx context.Context, obj *model.DisposalRequest) ([]*model.DisposalCertificate, error) {
+	return loadDisposalCertificates(r.DB, &obj.ID)
+}
+
+// Retention is the resolver for the retention field.
+func (r *fileResolver) Retention(ctx context.Context, obj *model.File) (*model.FileRetention, error) {
+	if r.DB == nil {
+		return nil, serviceErrorf(errInternal, "internal server error: database connection is not initialized")
+	}
+
+	file, err := loadTreeFile(r.DB, obj.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := newRetentionCalculator(r.DB, time.Now()).fileRetention(file)
+	if err != nil || info == nil {
+		return nil, err
+	}
+	return fileRetentionModel(info), nil
+}
+
+// CreateRetentionRule is the resolver for the createRetentionRule field.
+func (r *mutationResolver) CreateRetentionRule(ctx context.Context, input model.RetentionRuleInput) (*model.RetentionRule, error) {
+	logAction(fmt.Sprintf("Creating retention rule %s", input.Name))
+
+	if r.DB == nil {
+		log.Printf("Database connection is nil")
+		return nil, serviceErrorf(errInternal, "internal server error: database connection is not initialized")
+	}
+
+	if _, err := requireAdministrator(ctx, r.DB, "manage retention rules"); err != nil {
+		return nil, err
+	}
+
+	name, triggerField, err := validateRetentionRuleInput(input)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := r.DB.Exec(`
+		INSERT INTO retention_rules (name, retention_years, trigger_field, disposal_action, description, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, name, input.RetentionYears, triggerField, input.DisposalAction, input.Description, time.Now().Format(time.RFC3339))
+	if err != nil {
+		log.Printf("Error creating retention rule: %v", err)
+		if isUniqueViolation(err) {
+			return nil, fmt.Errorf("a retention rule named %s already exists", name)
+		}
+		return nil, serviceErrorf(errInternal, "failed to create retention rule: %v", err)
+	}
+
+	ruleID, err := result.LastInsertId()
+	if err != nil {
+		log.Printf("Error retrieving last insert ID: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to retrieve retention rule ID: %v", err)
+	}
+
+	log.Printf("Retention rule %s created with ID %d", name, ruleID)
+	return getRetentionRule(r.DB, fmt.Sprintf("%d", ruleID))
+}
+
+// UpdateRetentionRule is the resolver for the updateRetentionRule field.
+func (r *mutationResolver) UpdateRetentionRule(ctx context.Context, id string, input model.RetentionRuleInput) (*model.RetentionRule, error) {
+	logAction(fmt.Sprintf("Updating retention rule %s", id))
+
+	if r.DB == nil {
+		log.Printf("Database connection is nil")
+		return nil, serviceErrorf(errInternal, "internal server error: database connection is not initialized")
+	}
+
+	if _, err := requireAdministrator(ctx, r.DB, "manage retention rules"); err != nil {
+		return nil, err
+	}
+
+	name, triggerField, err := validateRetentionRuleInput(input)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := r.DB.Exec(`
+		UPDATE retention_rules
+		SET name = ?, retention_years = ?, trigger_field = ?, disposal_action = ?, description = ?
+		WHERE id = ?
+	`, name, input.RetentionYears, triggerField, input.DisposalAction, input.Description, id)
+	if err != nil {
+		log.Printf("Error updating retention rule %s: %v", id, err)
+		if isUniqueViolation(err) {
+			return nil, fmt.Errorf("a retention rule named %s already exists", name)
+		}
+		return nil, serviceErrorf(errInternal, "failed to update retention rule: %v", err)
+	}
+
+	if rowsAffected, err := result.RowsAffected(); err == nil && rowsAffected == 0 {
+		return nil, serviceErrorf(errNotFound, "retention rule not found")
+	}
+
+	return getRetentionRule(r.DB, id)
+}
+
+// DeleteRetentionRule is the resolver for the deleteRetentionRule field.
+func (r *mutationResolver) DeleteRetentionRule(ctx context.Context, id string) (bool, error) {
+	logAction(fmt.Sprintf("Deleting retention rule %s", id))
+
+	if r.DB == nil {
+		log.Printf("Database connection is nil")
+		return false, serviceErrorf(errInternal, "internal server error: database connection is not initialized")
+	}
+
+	if _, err := requireAdministrator(ctx, r.DB, "manage retention rules"); err != nil {
+		return false, err
+	}
+
+	// En regel som används av noder kan inte tas bort
+	var inUse bool
+	err := r.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM nodes WHERE retention_rule_id = ?)", id).Scan(&inUse)
+	if err != nil {
+		log.Printf("Error checking if retention rule %s is in use: %v", id, err)
+		return false, serviceErrorf(errInternal, "failed to check retention rule: %v", err)
+	}
+	if inUse {
+		return false, serviceErrorf(errConflict, "cannot delete retention rule: it is attached to one or more nodes")
+	}
+
+	result, err := r.DB.Exec("DELETE FROM retention_rules WHERE id = ?", id)
+	if err != nil {
+		log.Printf("Error deleting retention rule %s: %v", id, err)
+		return false, serviceErrorf(errInternal, "failed to delete retention rule: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		log.Printf("Error getting rows affected: %v", err)
+		return false, fmt.Errorf("error confirming deletion: %v", err)
+	}
+	if rowsAffected == 0 {
+		return false, serviceErrorf(errNotFound, "retention rule not found")
+	}
+
+	log.Printf("Retention rule %s deleted", id)
+	return true, nil
+}
+
+// SetNodeRetentionRule is the resolver for the setNodeRetentionRule field.
+func (r *mutationResolver) SetNodeRetentionRule(ctx context.Context, nodeID string, ruleID *string) (*model.Node, error) {
+	logAction(fmt.Sprintf("Setting retention rule of node %s", nodeID))
+
+	if r.DB == nil {
+		log.Printf("Database connection is nil")
+		return nil, serviceErrorf(errInternal, "internal server error: database connection is not initialized")
+	}
+
+	if _, err := requireAdministrator(ctx, r.DB, "manage retention rules"); err != nil {
+		return nil, err
+	}
+
+	if ruleID != nil {
+		if _, err := getRetentionRule(r.DB, *ruleID); err != nil {
+			return nil, err
+		}
+	}
+
+	result, err := r.DB.Exec("UPDATE nodes SET retention_rule_id = ?, updated_at = datetime('now') WHERE id = ?", ruleID, nodeID)
+	if err != nil {
+		log.Printf("Error setting retention rule of node %s: %v", nodeID, err)
+		return nil, serviceErrorf(errInternal, "failed to set retention rule: %v", err)
+	}
+	if rowsAffected, err := result.RowsAffected(); err == nil && rowsAffected == 0 {
+		return nil, serviceErrorf(errNotFound, "node not found")
+	}
+
+	return getNodeWithPermissions(ctx, r.DB, nodeID)
+}
+
+// RequestDisposal is the resolver for the requestDisposal field.
+func (r *mutationResolver) RequestDisposal(ctx context.Context, fileIds []string, reason *string) (*model.DisposalRequest, error) {
+	logAction(fmt.Sprintf("Requesting disposal of %d files", len(fileIds)))
+
+	if r.DB == nil {
+		log.Printf("Database connection is nil")
+		return nil, serviceErrorf(errInternal, "internal server error: database connection is not initialized")
+	}
+
+	userID, err := getUserIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(fileIds) == 0 {
+		return nil, fmt.Errorf("at least one file is required")
+	}
+
+	// Endast filer som användaren får ta bort och vars kassationsdatum har passerats kan begäras
+	calculator := newRetentionCalculator(r.DB, time.Now())
+	for _, fileID := range fileIds {
+		file, err := loadTreeFile(r.DB, fileID)
+		if err != nil {
+			return nil, fmt.Errorf("file %s: %v", fileID, err)
+		}
+
+		hasPermission, err := checkPermission(ctx, r.DB, file.NodeID, PERM_DELETE)
+		if err != nil {
+			return nil, err
+		}
+		if !hasPermission {
+			return nil, serviceErrorf(errPermissionDenied, "permission denied: cannot delete file %s", fileID)
+		}
+
+		if err := checkFileNotHeld(r.DB, fileID, "dispose of file "+fileID); err != nil {
+			return nil, err
+		}
+
+		info, err := calculator.fileRetention(file)
+		if err != nil {
+			return nil, err
+		}
+		if info == nil || !info.Due {
+			return nil, fmt.Errorf("file %s is not due for disposal", fileID)
+		}
+
+		var pending bool
+		err = r.DB.QueryRow(`
+			SELECT EXISTS(
+				SELECT 1 FROM disposal_request_files f
+				JOIN disposal_requests d ON f.request_id = d.id
+				WHERE f.file_id = ? AND d.status = ?
+			)
+		`, fileID, model.DisposalRequestStatusPending).Scan(&pending)
+		if err != nil {
+			log.Printf("Error checking pending disposal requests for file %s: %v", fileID, err)
+			return nil, serviceErrorf(errInternal, "failed to check disposal requests: %v", err)
+		}
+		if pending {
+			return nil, fmt.Errorf("file %s already has a pending disposal request", fileID)
+		}
+	}
+
+	tx, err := r.DB.Begin()
+	if err != nil {
+		log.Printf("Error starting transaction: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to start transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(
+		"INSERT INTO disposal_requests (status, reason, requested_by, requested_at) VALUES (?, ?, ?, ?)",
+		model.DisposalRequestStatusPending, reason, userID, time.Now().Format(time.RFC3339),
+	)
+	if err != nil {
+		log.Printf("Error creating disposal request: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to create disposal request: %v", err)
+	}
+
+	requestID, err := result.LastInsertId()
+	if err != nil {
+		log.Printf("Error retrieving last insert ID: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to retrieve disposal request ID: %v", err)
+	}
+
+	for _, fileID := range fileIds {
+		if _, err := tx.Exec("INSERT INTO disposal_request_files (request_id, file_id) VALUES (?, ?) ON CONFLICT DO NOTHING", requestID, fileID); err != nil {
+			log.Printf("Error adding file %s to disposal request: %v", fileID, err)
+			return nil, serviceErrorf(errInternal, "failed to create disposal request: %v", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("Error committing transaction: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to commit transaction: %v", err)
+	}
+
+	log.Printf("Disposal request %d created for %d files", requestID, len(fileIds))
+	return getDisposalRequest(r.DB, fmt.Sprintf("%d", requestID))
+}
+
+// ApproveDisposal is the resolver for the approveDisposal field.
+func (r *mutationResolver) ApproveDisposal(ctx context.Context, requestID string, note *string) (*model.DisposalRequest, error) {
+	logAction(fmt.Sprintf("Approving disposal request %s", requestID))
+
+	if r.DB == nil {
+		log.Printf("Database connection is nil")
+		return nil, serviceErrorf(errInternal, "internal server error: database connection is not initialized")
+	}
+
+	userID, err := requireAdministrator(ctx, r.DB, "approve disposal")
+	if err != nil {
+		return nil, err
+	}
+
+	request, err := getDisposalRequest(r.DB, requestID)
+	if err != nil {
+		return nil, err
+	}
+	if request.Status != model.DisposalRequestStatusPending {
+		return nil, fmt.Errorf("disposal request has already been %s", strings.ToLower(string(request.Status)))
+	}
+	// Kassation kräver att någon annan än den som begärde den godkänner
+	if request.RequestedByID == userID {
+		return nil, serviceErrorf(errPermissionDenied, "permission denied: a disposal request must be approved by another user")
+	}
+
+	requestedBy, err := lookupUsername(r.DB, request.RequestedByID)
+	if err != nil {
+		return nil, err
+	}
+	approvedBy, err := lookupUsername(r.DB, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	fileIDs, err := loadDisposalRequestFileIDs(r.DB, requestID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Bevaringstiden kontrolleras på nytt eftersom regler och fält kan ha ändrats sedan begäran
+	calculator := newRetentionCalculator(r.DB, time.Now())
+	files := make([]*treeFile, len(fileIDs))
+	infos := make([]*fileRetentionInfo, len(fileIDs))
+	for i, fileID := range fileIDs {
+		files[i], err = loadTreeFile(r.DB, fileID)
+		if err != nil {
+			return nil, fmt.Errorf("file %s: %v", fileID, err)
+		}
+		infos[i], err = calculator.fileRetention(files[i])
+		if err != nil {
+			return nil, err
+		}
+		if infos[i] == nil || !infos[i].Due {
+			return nil, fmt.Errorf("file %s is no longer due for disposal", fileID)
+		}
+		// Ett tilbakehold kan ha lagts efter att kassationen begärdes
+		if err := checkFileNotHeld(r.DB, fileID, "dispose of file "+fileID); err != nil {
+			return nil, err
+		}
+	}
+
+	tx, err := r.DB.Begin()
+	if err != nil {
+		log.Printf("Error starting transaction: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to start transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	for i, file := range files {
+		if err := disposeFile(tx, requestID, file, infos[i], requestedBy, approvedBy); err != nil {
+			return nil, err
+		}
+	}
+
+	_, err = tx.Exec(
+		"UPDATE disposal_requests SET status = ?, decided_by = ?, decided_at = ?, decision_note = ? WHERE id = ?",
+		model.DisposalRequestStatusApproved, userID, time.Now().Format(time.RFC3339), note, requestID,
+	)
+	if err != nil {
+		log.Printf("Error updating disposal request %s: %v", requestID, err)
+		return nil, serviceErrorf(errInternal, "failed to update disposal request: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("Error committing transaction: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to commit transaction: %v", err)
+	}
+
+	log.Printf("Disposal request %s approved by %s, %d files disposed of", requestID, approvedBy, len(files))
+	return getDisposalRequest(r.DB, requestID)
+}
+
+// RejectDisposal is the resolver for the rejectDisposal field.
+func (r *mutationResolver) RejectDisposal(ctx context.Context, requestID string, note *string) (*model.DisposalRequest, error) {
+	logAction(fmt.Sprintf("Rejecting disposal request %s", requestID))
+
+	if r.DB == nil {
+		log.Printf("Database connection is nil")
+		return nil, serviceErrorf(errInternal, "internal server error: database connection is not initialized")
+	}
+
+	userID, err := requireAdministrator(ctx, r.DB, "reject disposal")
+	if err != nil {
+		return nil, err
+	}
+
+	request, err := getDisposalRequest(r.DB, requestID)
+	if err != nil {
+		return nil, err
+	}
+	if request.Status != model.DisposalRequestStatusPending {
+		return nil, fmt.Errorf("disposal request has already been %s", strings.ToLower(string(request.Status)))
+	}
+
+	_, err = r.DB.Exec(
+		"UPDATE disposal_requests SET status = ?, decided_by = ?, decided_at = ?, decision_note = ? WHERE id = ?",
+		model.DisposalRequestStatusRejected, userID, time.Now().Format(time.RFC3339), note, requestID,
+	)
+	if err != nil {
+		log.Printf("Error updating disposal request %s: %v", requestID, err)
+		return nil, serviceErrorf(errInternal, "failed to update disposal request: %v", err)
+	}
+
+	log.Printf("Disposal request %s rejected", requestID)
+	return getDisposalRequest(r.DB, requestID)
+}
+
+// RetentionRule is the resolver for the retentionRule field.
+func (r *nodeResolver) RetentionRule(ctx context.Context, obj *model.Node) (*model.RetentionRule, error) {
+	if r.DB == nil {
+		return nil, serviceErrorf(errInternal, "internal server error: database connection is not initialized")
+	}
+
+	rule, _, err := newRetentionCalculator(r.DB, time.Now()).effectiveRule(obj.ID)
+	return rule, err
+}
+
+// RetentionRules is the resolver for the retentionRules field.
+func (r *queryResolver) RetentionRules(ctx context.Context) ([]*model.RetentionRule, error) {
+	if r.DB == nil {
+		return nil, serviceErrorf(errInternal, "internal server error: database connection is not initialized")
+	}
+
+	rows, err := r.DB.Query("SELECT id FROM retention_rules ORDER BY name ASC")
+	if err != nil {
+		log.Printf("Error fetching retention rules: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to fetch retention rules: %v", err)
+	}
+	ruleIDs, err := scanIDs(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	rules := []*model.RetentionRule{}
+	for _, ruleID := range ruleIDs {
+		rule, err := getRetentionRule(r.DB, ruleID)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// DueForDisposal is the resolver for the dueForDisposal field.
+func (r *queryResolver) DueForDisposal(ctx context.Context, nodeID *string, asOf *string) ([]*model.File, error) {
+	logAction("Listing files due for disposal")
+
+	if r.DB == nil {
+		log.Printf("Database connection is nil")
+		return nil, serviceErrorf(errInternal, "internal server error: database connection is not initialized")
+	}
+
+	if _, err := requireAdministrator(ctx, r.DB, "list files due for disposal"); err != nil {
+		return nil, err
+	}
+
+	date := time.Now()
+	if asOf != nil {
+		var ok bool
+		if date, ok = parseRecordDate(*asOf); !ok {
+			return nil, fmt.Errorf("invalid date %s", *asOf)
+		}
+	}
+
+	due, err := newRetentionCalculator(r.DB, date).dueFiles(nodeID)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]*model.File, len(due))
+	for i, file := range due {
+		files[i] = treeFileModel(file)
+	}
+	return files, nil
+}
+
+// DisposalRequests is the resolver for the disposalRequests field.
+func (r *queryResolver) DisposalRequests(ctx context.Context, status *model.DisposalRequestStatus) ([]*model.DisposalRequest, error) {
+	if r.DB == nil {
+		return nil, serviceErrorf(errInternal, "internal server error: database connection is not initialized")
+	}
+
+	if _, err := requireAdministrator(ctx, r.DB, "view disposal requests"); err != nil {
+		return nil, err
+	}
+
+	query := "SELECT id FROM disposal_requests"
+	args := []interface{}{}
+	if status != nil {
+		query += " WHERE status = ?"
+		args = append(args, *status)
+	}
+	query += " ORDER BY id DESC"
+
+	rows, err := r.DB.Query(query, args...)
+	if err != nil {
+		log.Printf("Error fetching disposal requests: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to fetch disposal requests: %v", err)
+	}
+	requestIDs, err := scanIDs(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	requests := []*model.DisposalRequest{}
+	for _, requestID := range requestIDs {
+		request, err := getDisposalRequest(r.DB, requestID)
+		if err != nil {
+			return nil, err
+		}
+		requests = append(requests, request)
+	}
+	return requests, nil
+}
+
+// DisposalCertificates is the resolver for the disposalCertificates field.
+func (r *queryResolver) DisposalCertificates(ctx context.Context, requestID *string) ([]*model.DisposalCertificate, error) {
+	if r.DB == nil {
+		return nil, serviceErrorf(errInternal, "internal server error: database connection is not initialized")
+	}
+
+	if _, err := requireAdministrator(ctx, r.DB, "view disposal certificates"); err != nil {
+		return nil, err
+	}
+
+	return loadDisposalCertificates(r.DB, requestID)
+}
+
+// DisposalRequest returns DisposalRequestResolver implementation.
+func (r *Resolver) DisposalRequest() DisposalRequestResolver { return &disposalRequestResolver{r} }
+
+type disposalRequestResolver struct{ *Resolver }
@@ -0,0 +1,219 @@
+package graph
+
+import (
+	"database/sql"
+	"graphql-backend/graph/model"
+	"strings"
+	"testing"
+	"time"
+)
+
+// createTestRetentionRule skapar en bevaringsregel och kopplar den till en nod
+func createTestRetentionRule(t *testing.T, db *sql.DB, nodeID string, name string, years int, trigger string, action model.DisposalAction) *model.RetentionRule {
+	t.Helper()
+	ctx := testAdminContext(t)
+	mutation := NewResolver(db).Mutation()
+	rule, err := mutation.CreateRetentionRule(ctx, model.RetentionRuleInput{Name: name, RetentionYears: years, TriggerField: &trigger, DisposalAction: action})
+	if err != nil {
+		t.Fatalf("create retention rule: %v", err)
+	}
+	if _, err := mutation.SetNodeRetentionRule(ctx, nodeID, &rule.ID); err != nil {
+		t.Fatalf("set retention rule: %v", err)
+	}
+	return rule
+}
+
+// testFileRetention beräknar bevaringstiden för en fil per datumet asOf
+func testFileRetention(t *testing.T, db *sql.DB, fileID string, asOf string) *fileRetentionInfo {
+	t.Helper()
+	date, ok := parseRecordDate(asOf)
+	if !ok {
+		t.Fatalf("invalid date %q", asOf)
+	}
+	file, err := loadTreeFile(db, fileID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := newRetentionCalculator(db, date).fileRetention(file)
+	if err != nil {
+		t.Fatalf("file retention: %v", err)
+	}
+	return info
+}
+
+func TestParseRecordDate(t *testing.T) {
+	for value, want := range map[string]string{
+		"2026-02-01":                "2026-02-01",
+		" 2026-02-01 ":              "2026-02-01",
+		"2026-02-01T10:30:00Z":      "2026-02-01",
+		"2026-02-01T23:30:00-05:00": "2026-02-01",
+		"2026-02-01 10:30:00":       "2026-02-01",
+		"2026-02-01T10:30:00":       "2026-02-01",
+	} {
+		got, ok := parseRecordDate(value)
+		if !ok || got.Format(retentionDateLayout) != want {
+			t.Errorf("parseRecordDate(%q) = %s, %v, want %s", value, got, ok, want)
+		}
+	}
+	for _, value := range []string{"", "i fjol", "01.02.2026", "2026-02-30"} {
+		if got, ok := parseRecordDate(value); ok {
+			t.Errorf("parseRecordDate(%q) = %s, want it rejected", value, got)
+		}
+	}
+}
+
+func TestValidateRetentionRuleInput(t *testing.T) {
+	name, trigger, err := validateRetentionRuleInput(model.RetentionRuleInput{Name: " Personal ", RetentionYears: 10, DisposalAction: model.DisposalActionDestroy})
+	if err != nil || name != "Personal" || trigger != RETENTION_TRIGGER_CREATED_AT {
+		t.Errorf("validate = %q, %q, %v, want createdAt as the default trigger", name, trigger, err)
+	}
+
+	if _, _, err := validateRetentionRuleInput(model.RetentionRuleInput{Name: "  ", DisposalAction: model.DisposalActionDestroy}); err == nil || err.Error() != "retention rule name is required" {
+		t.Errorf("empty name: err = %v", err)
+	}
+	if _, _, err := validateRetentionRuleInput(model.RetentionRuleInput{Name: "Fel", RetentionYears: -1, DisposalAction: model.DisposalActionDestroy}); err == nil || err.Error() != "retention period cannot be negative" {
+		t.Errorf("negative period: err = %v", err)
+	}
+}
+
+func TestFileRetention(t *testing.T) {
+	db := openTestDB(t)
+	arkivID := insertTestNode(t, db, "Arkiv", "")
+	sakID := insertTestNode(t, db, "Byggesak", arkivID)
+	setTestNodeType(t, db, sakID, model.NodeTypeSaksmappe, map[string]string{"saksdato": "2020-02-29"})
+	delsakID := insertTestNode(t, db, "Delsak", sakID)
+	rule := createTestRetentionRule(t, db, arkivID, "Byggesaker", 5, "saksdato", model.DisposalActionDestroy)
+
+	// Regeln ärvs från arkivet och saksdato hämtas från saksmappen ovanför filen
+	inherited := insertTestFile(t, db, "ritning.pdf", delsakID)
+	info := testFileRetention(t, db, inherited, "2025-02-28")
+	if info == nil || info.Rule.ID != rule.ID || info.RuleNodeID != arkivID {
+		t.Fatalf("retention = %+v, want the rule inherited from the arkiv", info)
+	}
+	// Skottdagen plus fem år blir den 1 mars eftersom 2025 inte har någon 29 februari
+	if info.TriggerDate.Format(retentionDateLayout) != "2020-02-29" || info.DisposalDate.Format(retentionDateLayout) != "2025-03-01" || info.Due {
+		t.Errorf("retention = %s -> %s (due %v)", info.TriggerDate, info.DisposalDate, info.Due)
+	}
+	if !testFileRetention(t, db, inherited, "2025-03-01").Due {
+		t.Error("file is not due on its disposal date")
+	}
+
+	// Filens eget fält och metadata går före saksmappens fält
+	withField := insertTestFile(t, db, "vedtak.pdf", delsakID)
+	if err := saveTypedFields(db, ENTITY_KIND_FILE, withField, map[string]string{"saksdato": "2018-06-01"}); err != nil {
+		t.Fatal(err)
+	}
+	withMetadata := insertTestFile(t, db, "brev.pdf", delsakID)
+	mustExec(t, db, "INSERT INTO metadata (file_id, key, value) VALUES (?, 'saksdato', '2019-06-01')", withMetadata)
+	for fileID, want := range map[string]string{withField: "2023-06-01", withMetadata: "2024-06-01"} {
+		if info := testFileRetention(t, db, fileID, "2026-01-01"); info.DisposalDate == nil || info.DisposalDate.Format(retentionDateLayout) != want {
+			t.Errorf("disposal date of %s = %v, want %s", fileID, info.DisposalDate, want)
+		}
+	}
+
+	// Utan ett utlösande datum finns regeln men inget kassationsdatum
+	undated := insertTestFile(t, db, "odaterad.pdf", insertTestNode(t, db, "Lös", arkivID))
+	if info := testFileRetention(t, db, undated, "2100-01-01"); info == nil || info.TriggerDate != nil || info.DisposalDate != nil || info.Due {
+		t.Errorf("retention without trigger = %+v", info)
+	}
+
+	date, _ := parseRecordDate("2026-01-01")
+	due, err := newRetentionCalculator(db, date).dueFiles(&arkivID)
+	if err != nil {
+		t.Fatalf("due files: %v", err)
+	}
+	var names []string
+	for _, file := range due {
+		names = append(names, file.Name)
+	}
+	if strings.Join(names, ",") != "brev.pdf,ritning.pdf,vedtak.pdf" {
+		t.Errorf("due files = %v, want the three dated files in Delsak", names)
+	}
+
+	// En regel längre ner i trädet går före den ärvda, och RETAIN förfaller aldrig
+	createTestRetentionRule(t, db, delsakID, "Bevares", 0, RETENTION_TRIGGER_CREATED_AT, model.DisposalActionRetain)
+	if info := testFileRetention(t, db, inherited, "2100-01-01"); info.Rule.Name != "Bevares" || info.RuleNodeID != delsakID || info.Due {
+		t.Errorf("retention under a RETAIN rule = %+v", info)
+	}
+
+	// Filer utan regel har ingen bevaringstid
+	free := insertTestFile(t, db, "fri.txt", insertTestNode(t, db, "Utan regel", ""))
+	if info := testFileRetention(t, db, free, "2100-01-01"); info != nil {
+		t.Errorf("retention without rule = %+v", info)
+	}
+}
+
+func TestDisposalWorkflow(t *testing.T) {
+	db := openTestDB(t)
+	mutation := NewResolver(db).Mutation()
+	admin := testAdminContext(t)
+	archivistID := insertTestUser(t, db, "arkivarie")
+	mustExec(t, db, "INSERT INTO group_members (user_id, group_id, created_at) SELECT ?, id, ? FROM groups WHERE name = 'Administrators'", archivistID, time.Now().Format(time.RFC3339))
+	archivist := testUserContext(t, archivistID, "arkivarie")
+
+	nodeID := insertTestNode(t, db, "Korrespondanse", "")
+	createTestRetentionRule(t, db, nodeID, "Korrespondanse", 3, "journaldato", model.DisposalActionDestroy)
+	oldID := insertTestFile(t, db, "gammalt.txt", nodeID)
+	newID := insertTestFile(t, db, "nytt.txt", nodeID)
+	for fileID, date := range map[string]string{oldID: "2019-05-01", newID: time.Now().Format(retentionDateLayout)} {
+		if err := saveTypedFields(db, ENTITY_KIND_FILE, fileID, map[string]string{"journaldato": date}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := mutation.RequestDisposal(admin, []string{oldID, newID}, nil); err == nil || err.Error() != "file "+newID+" is not due for disposal" {
+		t.Errorf("request disposal of a file that is not due: err = %v", err)
+	}
+	request, err := mutation.RequestDisposal(admin, []string{oldID}, strPtr("Bevaringstiden är ute"))
+	if err != nil {
+		t.Fatalf("request disposal: %v", err)
+	}
+	if _, err := mutation.RequestDisposal(admin, []string{oldID}, nil); err == nil || !strings.Contains(err.Error(), "already has a pending disposal request") {
+		t.Errorf("second request: err = %v", err)
+	}
+
+	// Den som begärde kassationen får inte godkänna den själv
+	if _, err := mutation.ApproveDisposal(admin, request.ID, nil); err == nil || !strings.Contains(err.Error(), "must be approved by another user") {
+		t.Errorf("approve own request: err = %v", err)
+	}
+	approved, err := mutation.ApproveDisposal(archivist, request.ID, strPtr("Godkänt"))
+	if err != nil {
+		t.Fatalf("approve disposal: %v", err)
+	}
+	if approved.Status != model.DisposalRequestStatusApproved {
+		t.Errorf("status = %s", approved.Status)
+	}
+	if _, err := mutation.ApproveDisposal(archivist, request.ID, nil); err == nil || err.Error() != "disposal request has already been approved" {
+		t.Errorf("approve twice: err = %v", err)
+	}
+
+	if _, err := loadTreeFile(db, oldID); err == nil {
+		t.Error("disposed file still exists")
+	}
+	if _, err := loadTreeFile(db, newID); err != nil {
+		t.Errorf("file that was not disposed of: %v", err)
+	}
+
+	certificates, err := NewResolver(db).Query().DisposalCertificates(admin, &request.ID)
+	if err != nil || len(certificates) != 1 {
+		t.Fatalf("certificates = %v, %v", certificates, err)
+	}
+	certificate := certificates[0]
+	if certificate.FileID != oldID || certificate.FileName != "gammalt.txt" || certificate.RuleName != "Korrespondanse" || certificate.TriggerField != "journaldato" ||
+		certificate.TriggerDate != "2019-05-01" || certificate.DisposalDate != "2022-05-01" || certificate.RequestedBy != "admin" || certificate.ApprovedBy != "arkivarie" {
+		t.Errorf("certificate = %+v", certificate)
+	}
+}
+
+func TestDisposalRequiresPermission(t *testing.T) {
+	db := openTestDB(t)
+	nodeID := insertTestNode(t, db, "Arkiv", "")
+	createTestRetentionRule(t, db, nodeID, "Kort", 0, RETENTION_TRIGGER_CREATED_AT, model.DisposalActionDestroy)
+	fileID := insertTestFile(t, db, "brev.txt", nodeID)
+	bobID := insertTestUser(t, db, "bob")
+
+	ctx := testUserContext(t, bobID, "bob")
+	if _, err := NewResolver(db).Mutation().RequestDisposal(ctx, []string{fileID}, nil); err == nil || !strings.HasPrefix(err.Error(), "permission denied") {
+		t.Errorf("request disposal without delete permission: err = %v", err)
+	}
+}
@@ -0,0 +1,94 @@
+package graph
+
+import (
+	"context"
+	"database/sql"
+	"graphql-backend/graph/model"
+)
+
+// =============================================
+// ========== TJÄNSTELAGER ===================
+// =============================================
+
+// Tjänsterna innehåller affärslogiken, SQL-frågorna och behörighetskontrollerna för noder, filer,
+// användare och grupper. GraphQL-resolvers, REST-API:t, WebDAV och kommandoradsverktygen anropar
+// dem i stället för att själva gå mot databasen. Anroparen identifieras med token i context, precis
+// som i resolvers.
+
+// NodeService hanterar noderna i nodträdet
+type NodeService interface {
+	Get(ctx context.Context, id string) (*model.Node, error)
+	Roots(ctx context.Context) ([]*model.Node, error)
+	Children(ctx context.Context, parentID string) ([]*model.Node, error)
+	Create(ctx context.Context, input model.NodeInput) (*model.Node, error)
+	Update(ctx context.Context, id string, input model.NodeUpdateInput) (*model.Node, error)
+	Move(ctx context.Context, id string, newParentID string) (*model.Node, error)
+	Delete(ctx context.Context, id string) (bool, error)
+	SetPermissions(ctx context.Context, nodeID string, permissions int) (*model.Node, error)
+	SetOwnership(ctx context.Context, nodeID string, ownerUserID *string, ownerGroupID *string) (*model.Node, error)
+}
+
+// FileService hanterar filerna i noderna och deras metadata
+type FileService interface {
+	Get(ctx context.Context, id string) (*model.File, error)
+	List(ctx context.Context) ([]*model.File, error)
+	ListByNode(ctx context.Context, nodeID string) ([]*model.File, error)
+	Download(ctx context.Context, id string) (*model.File, error)
+	Save(ctx context.Context, input model.FileInput) (*model.File, error)
+	Rename(ctx context.Context, id string, name string) (*model.File, error)
+	Move(ctx context.Context, fileID string, nodeID string) (*model.File, error)
+	Delete(ctx context.Context, id string) (bool, error)
+	UpdateMetadata(ctx context.Context, fileID string, metadataInput []*model.MetadataInput) (*model.File, error)
+	DeleteMetadata(ctx context.Context, fileID string, keys []string) (*model.File, error)
+}
+
+// UserService hanterar användare, inloggning och användarinställningar
+type UserService interface {
+	Get(ctx context.Context, id string) (*model.User, error)
+	GetByUsername(ctx context.Context, username string) (*model.User, error)
+	List(ctx context.Context) ([]*model.User, error)
+	Current(ctx context.Context) (*model.User, error)
+	Create(ctx context.Context, username string, password string, name *string) (*model.User, error)
+	Update(ctx context.Context, id string, username *string, name *string) (*model.User, error)
+	SetPassword(ctx context.Context, userID string, newPassword string) (bool, error)
+	Delete(ctx context.Context, id string) (bool, error)
+	Login(ctx context.Context, username string, password string) (*model.AuthPayload, error)
+	Logout(ctx context.Context, token string) (bool, error)
+	Register(ctx context.Context, username string, password string) (*model.AuthPayload, error)
+	ChangePassword(ctx context.Context, currentPassword string, newPassword string) (bool, error)
+	Settings(ctx context.Context) ([]*model.UserSetting, error)
+	Setting(ctx context.Context, key string) (*model.UserSetting, error)
+	SaveSetting(ctx context.Context, key string, value string) (*model.UserSetting, error)
+	DeleteSetting(ctx context.Context, key string) (bool, error)
+}
+
+// GroupService hanterar grupper och gruppmedlemskap
+type GroupService interface {
+	Get(ctx context.Context, id string) (*model.Group, error)
+	List(ctx context.Context) ([]*model.Group, error)
+	ForCurrentUser(ctx context.Context) ([]*model.Group, error)
+	Members(ctx context.Context, groupID string) ([]*model.User, error)
+	Create(ctx context.Context, name string) (*model.Group, error)
+	Update(ctx context.Context, id string, name string) (*model.Group, error)
+	Delete(ctx context.Context, id string) (bool, error)
+	AddMember(ctx context.Context, userID string, groupID string) (bool, error)
+	RemoveMember(ctx context.Context, userID string, groupID string) (bool, error)
+}
+
+// Services samlar tjänsterna för en databas
+type Services struct {
+	Nodes  NodeService
+	Files  FileService
+	Users  UserService
+	Groups GroupService
+}
+
+// NewServices skapar tjänsterna för en databasanslutning
+func NewServices(db *sql.DB) *Services {
+	return &Services{
+		Nodes:  &nodeService{db: db},
+		Files:  &fileService{db: db},
+		Users:  &userService{db: db},
+		Groups: &groupService{db: db},
+	}
+}
@@ -0,0 +1,330 @@
+package graph
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"graphql-backend/graph/model"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+)
+
+// =============================================
+// ========== PRENUMERATIONER ================
+// =============================================
+
+// Antal händelser som kan vänta hos en prenumerant innan nya händelser kastas
+const eventBufferSize = 64
+
+// eventHub fördelar händelser inom servern till prenumeranter per ämne, t.ex. "node:12" eller "job:3".
+// Publicering blockerar aldrig; en prenumerant som inte hinner med missar händelser.
+type eventHub struct {
+	mu          sync.Mutex
+	subscribers map[string]map[chan interface{}]struct{}
+}
+
+// events är serverns gemensamma händelsenav
+var events = &eventHub{subscribers: make(map[string]map[chan interface{}]struct{})}
+
+// subscribe börjar ta emot händelser för ett ämne. Den returnerade funktionen avslutar prenumerationen.
+func (h *eventHub) subscribe(topic string) (<-chan interface{}, func()) {
+	ch := make(chan interface{}, eventBufferSize)
+
+	h.mu.Lock()
+	if h.subscribers[topic] == nil {
+		h.subscribers[topic] = make(map[chan interface{}]struct{})
+	}
+	h.subscribers[topic][ch] = struct{}{}
+	h.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			h.mu.Lock()
+			delete(h.subscribers[topic], ch)
+			if len(h.subscribers[topic]) == 0 {
+				delete(h.subscribers, topic)
+			}
+			h.mu.Unlock()
+		})
+	}
+}
+
+// publish skickar en händelse till ämnets alla prenumeranter
+func (h *eventHub) publish(topic string, event interface{}) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for ch := range h.subscribers[topic] {
+		select {
+		case ch <- event:
+		default:
+			log.Printf("Dropping event for slow subscriber on %s", topic)
+		}
+	}
+}
+
+// nodeTopic och jobTopic är ämnena för en nods respektive ett jobbs händelser
+func nodeTopic(nodeID string) string { return "node:" + nodeID }
+func jobTopic(jobID string) string   { return "job:" + jobID }
+
+// nodeEvent är en ändring i en nod eller dess direkta innehåll. Noden i sig hämtas först hos
+// prenumeranten, med dennes behörigheter.
+type nodeEvent struct {
+	nodeID      string
+	kind        model.NodeChangeKind
+	childNodeID string
+	fileID      string
+	action      string
+	actorID     string
+	occurredAt  string
+}
+
+// publishNodeChange meddelar prenumeranterna på en nod att noden eller dess innehåll har ändrats.
+// childNodeID och fileID är tomma när de inte berörs.
+func publishNodeChange(nodeID string, kind model.NodeChangeKind, childNodeID string, fileID string, action string, actorID string) {
+	if nodeID == "" {
+		return
+	}
+	events.publish(nodeTopic(nodeID), nodeEvent{
+		nodeID:      nodeID,
+		kind:        kind,
+		childNodeID: childNodeID,
+		fileID:      fileID,
+		action:      action,
+		actorID:     actorID,
+		occurredAt:  time.Now().Format(time.RFC3339),
+	})
+}
+
+// publishJobUpdate meddelar prenumeranterna på ett jobb att jobbets rad har ändrats
+func publishJobUpdate(jobID string) {
+	events.publish(jobTopic(jobID), struct{}{})
+}
+
+// toModel gör om händelsen till det som skickas till klienten
+func (e nodeEvent) toModel() *model.NodeChange {
+	change := &model.NodeChange{
+		NodeID:     e.nodeID,
+		Kind:       e.kind,
+		Action:     e.action,
+		OccurredAt: e.occurredAt,
+	}
+	if e.childNodeID != "" {
+		change.ChildNodeID = &e.childNodeID
+	}
+	if e.fileID != "" {
+		change.FileID = &e.fileID
+	}
+	if e.actorID != "" {
+		change.ActorID = &e.actorID
+	}
+	return change
+}
+
+// =============================================
+// ========== ÄNDRINGAR FRÅN MUTATIONER ======
+// =============================================
+
+// changeTarget är en nods eller fils läge före eller efter en mutation
+type changeTarget struct {
+	exists   bool
+	parentID string // Nodens föräldernod, eller noden som filen ligger i
+	snapshot string
+	metadata string // Metadatadelen av ögonblicksbilden
+	fields   string // Övriga fält i ögonblicksbilden, utan updated_at
+}
+
+// ChangeEventMiddleware publicerar ändringar av noder och filer som mutationer gör, så att
+// prenumeranter på de berörda noderna och webhooks får veta det. Målet hittas på samma sätt som i
+// revisionsloggen och en ändring publiceras bara om raden faktiskt har ändrats.
+func ChangeEventMiddleware(db *sql.DB) graphql.FieldMiddleware {
+	return func(ctx context.Context, next graphql.Resolver) (interface{}, error) {
+		fc := graphql.GetFieldContext(ctx)
+		if fc == nil || fc.Object != "Mutation" || fc.Field.Field == nil {
+			return next(ctx)
+		}
+
+		action := fc.Field.Name
+		targetType, targetID := auditTargetFromArgs(action, fc.Args)
+		if targetType != "" && targetType != "file" && targetType != "node" {
+			return next(ctx)
+		}
+		before := loadChangeTarget(db, targetType, targetID)
+
+		result, err := next(ctx)
+		if err != nil {
+			return result, err
+		}
+
+		if targetID == "" {
+			targetType, targetID = auditTargetFromResult(result)
+			if targetType != "file" && targetType != "node" {
+				return result, err
+			}
+		}
+		after := loadChangeTarget(db, targetType, targetID)
+
+		actorID, _ := getUserIDFromContext(ctx)
+		notifyChange(db, targetType, targetID, before, after, action, actorID)
+		return result, err
+	}
+}
+
+// notifyChange meddelar prenumeranter och webhooks att en nod eller fil har ändrats
+func notifyChange(db *sql.DB, targetType string, targetID string, before changeTarget, after changeTarget, action string, actorID string) {
+	publishTargetChange(targetType, targetID, before, after, action, actorID)
+	queueChangeWebhooks(db, targetType, targetID, before, after, action, actorID)
+}
+
+// notifyCreated meddelar att en nod eller fil har skapats utanför en mutation, t.ex. av ett importjobb
+func notifyCreated(db *sql.DB, targetType string, targetID string, action string, actorID string) {
+	notifyChange(db, targetType, targetID, changeTarget{}, loadChangeTarget(db, targetType, targetID), action, actorID)
+}
+
+// loadChangeTarget hämtar var en nod eller fil ligger och hur den ser ut just nu
+func loadChangeTarget(db *sql.DB, targetType string, targetID string) changeTarget {
+	var target changeTarget
+	if targetID == "" {
+		return target
+	}
+
+	query := "SELECT parent_id FROM nodes WHERE id = ?"
+	if targetType == "file" {
+		query = "SELECT node_id FROM files WHERE id = ?"
+	}
+	var parentID sql.NullString
+	if err := db.QueryRow(query, targetID).Scan(&parentID); err != nil {
+		if err != sql.ErrNoRows {
+			log.Printf("Error locating %s %s for change events: %v", targetType, targetID, err)
+		}
+		return target
+	}
+
+	target.exists = true
+	target.parentID = parentID.String
+	target.snapshot = auditSnapshot(db, targetType, targetID)
+	target.metadata, target.fields = splitChangeSnapshot(target.snapshot)
+	return target
+}
+
+// splitChangeSnapshot delar en ögonblicksbild i metadata och övriga fält. updated_at tas bort så
+// att en ändring av enbart metadata inte ser ut som en ändring av själva raden.
+func splitChangeSnapshot(snapshot string) (string, string) {
+	var values map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(snapshot), &values); err != nil {
+		return "", snapshot
+	}
+	metadata := string(values["metadata"])
+	delete(values, "metadata")
+	delete(values, "updated_at")
+
+	fields, _ := json.Marshal(values) // Nycklarna sorteras, så lika fält ger samma sträng
+	return metadata, string(fields)
+}
+
+// publishTargetChange publicerar händelserna för en nod eller fil som har skapats, ändrats,
+// flyttats eller tagits bort
+func publishTargetChange(targetType string, targetID string, before changeTarget, after changeTarget, action string, actorID string) {
+	if before.exists && after.exists && before.snapshot == after.snapshot {
+		return
+	}
+
+	if targetType == "file" {
+		switch {
+		case !before.exists && after.exists:
+			publishNodeChange(after.parentID, model.NodeChangeKindFileAdded, "", targetID, action, actorID)
+		case before.exists && !after.exists:
+			publishNodeChange(before.parentID, model.NodeChangeKindFileRemoved, "", targetID, action, actorID)
+		case before.exists && after.exists && before.parentID == after.parentID:
+			publishNodeChange(after.parentID, model.NodeChangeKindFileUpdated, "", targetID, action, actorID)
+		case before.exists && after.exists:
+			publishNodeChange(before.parentID, model.NodeChangeKindFileRemoved, "", targetID, action, actorID)
+			publishNodeChange(after.parentID, model.NodeChangeKindFileAdded, "", targetID, action, actorID)
+		}
+		return
+	}
+
+	switch {
+	case !before.exists && after.exists:
+		publishNodeChange(after.parentID, model.NodeChangeKindChildAdded, targetID, "", action, actorID)
+	case before.exists && !after.exists:
+		publishNodeChange(targetID, model.NodeChangeKindDeleted, "", "", action, actorID)
+		publishNodeChange(before.parentID, model.NodeChangeKindChildRemoved, targetID, "", action, actorID)
+	case before.exists && after.exists:
+		publishNodeChange(targetID, model.NodeChangeKindUpdated, "", "", action, actorID)
+		if before.parentID == after.parentID {
+			publishNodeChange(after.parentID, model.NodeChangeKindChildUpdated, targetID, "", action, actorID)
+		} else {
+			publishNodeChange(before.parentID, model.NodeChangeKindChildRemoved, targetID, "", action, actorID)
+			publishNodeChange(after.parentID, model.NodeChangeKindChildAdded, targetID, "", action, actorID)
+		}
+	}
+}
+
+// =============================================
+// ========== WEBSOCKET-ANSLUTNINGAR =========
+// =============================================
+
+// WebsocketInit autentiserar en websocket-anslutning med token i init-payloadens Authorization,
+// eftersom webbläsare inte kan sätta headers på websocket-anrop. Token läggs i anslutningens
+// context på samma sätt som för vanliga anrop.
+func WebsocketInit(ctx context.Context, initPayload transport.InitPayload) (context.Context, *transport.InitPayload, error) {
+	token := strings.TrimPrefix(initPayload.Authorization(), "Bearer ")
+	if token == "" {
+		// En klient som kan sätta headers får använda Authorization-headern i stället
+		token, _ = GetAuthToken(ctx)
+	}
+	if token == "" {
+		return nil, nil, errNotAuthenticated
+	}
+	if _, err := validateJWT(token); err != nil {
+		log.Printf("Rejected websocket connection: %v", err)
+		return nil, nil, errNotAuthenticated
+	}
+
+	ctx = context.WithValue(ctx, "Authorization", token)
+	ctx = context.WithValue(ctx, "Authenticate", token)
+	return ctx, nil, nil
+}
+
+// =============================================
+// ========== HJÄLPFUNKTIONER ================
+// =============================================
+
+// loadFile hämtar en fil med metadata men utan innehåll
+func loadFile(db *sql.DB, fileID string) (*model.File, error) {
+	var file model.File
+	var nodeID sql.NullString
+	err := db.QueryRow(
+		"SELECT id, name, size, content_type, created_at, node_id FROM files WHERE id = ?", fileID,
+	).Scan(&file.ID, &file.Name, &file.Size, &file.ContentType, &file.CreatedAt, &nodeID)
+	if err == sql.ErrNoRows {
+		return nil, serviceErrorf(errNotFound, "file not found")
+	} else if err != nil {
+		log.Printf("Error fetching file %s: %v", fileID, err)
+		return nil, serviceErrorf(errInternal, "failed to fetch file: %v", err)
+	}
+	file.NodeID = nullStringPtr(nodeID)
+
+	metadata, err := loadFileMetadata(db, file.ID)
+	if err != nil {
+		return nil, err
+	}
+	file.Metadata = metadata
+	return &file, nil
+}
+
+// isJobFinished avgör om ett jobb har nått en slutstatus
+func isJobFinished(job *model.Job) bool {
+	switch job.Status {
+	case model.JobStatusSucceeded, model.JobStatusFailed, model.JobStatusCancelled:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,188 @@
+package graph
+
+// This file will be automatically regenerated based on the schema, any resolver implementations
+// will be copied through when generating and any unknown code will be moved to the end.
+// Code generated by github.com/99designs/gqlgen version v0.17.69
+
+import (
+	"context"
+	"fmt"
+	"graphql-backend/graph/model"
+	"log"
+)
+
+// NodeChanged is the resolver for the nodeChanged field.
+func (r *subscriptionResolver) NodeChanged(ctx context.Context, nodeID string) (<-chan *model.NodeChange, error) {
+	logAction(fmt.Sprintf("Subscribing to changes in node %s", nodeID))
+
+	if r.DB == nil {
+		log.Printf("Database connection is nil")
+		return nil, serviceErrorf(errInternal, "internal server error: database connection is not initialized")
+	}
+
+	hasPermission, err := checkPermission(ctx, r.DB, nodeID, PERM_VIEW)
+	if err != nil {
+		return nil, err
+	}
+	if !hasPermission {
+		return nil, serviceErrorf(errPermissionDenied, "permission denied: cannot view this node")
+	}
+
+	source, unsubscribe := events.subscribe(nodeTopic(nodeID))
+	changes := make(chan *model.NodeChange, 1)
+
+	go func() {
+		defer close(changes)
+		defer unsubscribe()
+
+		for {
+			var event nodeEvent
+			select {
+			case <-ctx.Done():
+				return
+			case e := <-source:
+				event = e.(nodeEvent)
+			}
+
+			change := event.toModel()
+			if event.kind != model.NodeChangeKindDeleted {
+				// Behörigheten kan ha ändrats sedan prenumerationen började; en ogiltig token avslutar den
+				allowed, err := checkPermission(ctx, r.DB, nodeID, PERM_VIEW)
+				if err != nil {
+					return
+				}
+				if !allowed {
+					continue
+				}
+				// Noden kan ha tagits bort efter händelsen och är då null
+				change.Node, _ = getNodeWithPermissions(ctx, r.DB, nodeID)
+			}
+
+			select {
+			case changes <- change:
+			case <-ctx.Done():
+				return
+			}
+			if event.kind == model.NodeChangeKindDeleted {
+				return
+			}
+		}
+	}()
+
+	return changes, nil
+}
+
+// FileAdded is the resolver for the fileAdded field.
+func (r *subscriptionResolver) FileAdded(ctx context.Context, nodeID string) (<-chan *model.File, error) {
+	logAction(fmt.Sprintf("Subscribing to files added to node %s", nodeID))
+
+	if r.DB == nil {
+		log.Printf("Database connection is nil")
+		return nil, serviceErrorf(errInternal, "internal server error: database connection is not initialized")
+	}
+
+	hasPermission, err := checkPermission(ctx, r.DB, nodeID, PERM_VIEW)
+	if err != nil {
+		return nil, err
+	}
+	if !hasPermission {
+		return nil, serviceErrorf(errPermissionDenied, "permission denied: cannot view files in this node")
+	}
+
+	source, unsubscribe := events.subscribe(nodeTopic(nodeID))
+	files := make(chan *model.File, 1)
+
+	go func() {
+		defer close(files)
+		defer unsubscribe()
+
+		for {
+			var event nodeEvent
+			select {
+			case <-ctx.Done():
+				return
+			case e := <-source:
+				event = e.(nodeEvent)
+			}
+
+			if event.kind == model.NodeChangeKindDeleted {
+				return
+			}
+			if event.kind != model.NodeChangeKindFileAdded {
+				continue
+			}
+
+			allowed, err := checkPermission(ctx, r.DB, nodeID, PERM_VIEW)
+			if err != nil {
+				return
+			}
+			if !allowed {
+				continue
+			}
+			file, err := loadFile(r.DB, event.fileID)
+			if err != nil {
+				continue
+			}
+
+			select {
+			case files <- file:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return files, nil
+}
+
+// JobProgress is the resolver for the jobProgress field.
+func (r *subscriptionResolver) JobProgress(ctx context.Context, jobID string) (<-chan *model.Job, error) {
+	logAction(fmt.Sprintf("Subscribing to progress of job %s", jobID))
+
+	if r.DB == nil {
+		log.Printf("Database connection is nil")
+		return nil, serviceErrorf(errInternal, "internal server error: database connection is not initialized")
+	}
+
+	// Prenumerera innan jobbet hämtas så att ingen ändring däremellan går förlorad
+	source, unsubscribe := events.subscribe(jobTopic(jobID))
+	job, err := getJobForUser(ctx, r.DB, jobID)
+	if err != nil {
+		unsubscribe()
+		return nil, err
+	}
+
+	jobs := make(chan *model.Job, 1)
+	jobs <- job
+
+	go func() {
+		defer close(jobs)
+		defer unsubscribe()
+
+		for !isJobFinished(job) {
+			select {
+			case <-ctx.Done():
+				return
+			case <-source:
+			}
+
+			// Händelsen säger bara att jobbet har ändrats; skicka dess aktuella tillstånd
+			if job, err = getJob(r.DB, jobID); err != nil {
+				return
+			}
+
+			select {
+			case jobs <- job:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return jobs, nil
+}
+
+// Subscription returns SubscriptionResolver implementation.
+func (r *Resolver) Subscription() SubscriptionResolver { return &subscriptionResolver{r} }
+
+type subscriptionResolver struct{ *Resolver }
@@ -0,0 +1,208 @@
+package graph
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"graphql-backend/graph/model"
+	"testing"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+)
+
+// receiveEvent väntar på nästa värde från en prenumeration
+func receiveEvent[T any](t *testing.T, ch <-chan T) T {
+	t.Helper()
+	select {
+	case value, ok := <-ch:
+		if !ok {
+			t.Fatal("subscription closed")
+		}
+		return value
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for subscription event")
+	}
+	panic("unreachable")
+}
+
+// expectNoEvent kontrollerar att prenumerationen inte levererar något en kort stund
+func expectNoEvent[T any](t *testing.T, ch <-chan T) {
+	t.Helper()
+	select {
+	case value, ok := <-ch:
+		if ok {
+			t.Errorf("unexpected event %+v", value)
+		}
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+// subscriptionContext avslutas när testet är klart, så att prenumerationerna stängs
+func subscriptionContext(t *testing.T, ctx context.Context) context.Context {
+	ctx, cancel := context.WithCancel(ctx)
+	t.Cleanup(cancel)
+	return ctx
+}
+
+// saveFileViaMutation sparar en fil genom samma middleware som saveFile i GraphQL, så att ändringen publiceras
+func saveFileViaMutation(t *testing.T, ctx context.Context, db *sql.DB, name string, nodeID string) *model.File {
+	t.Helper()
+	input := model.FileInput{Name: name, Size: 1, ContentType: "text/plain", FileData: base64.StdEncoding.EncodeToString([]byte("x")), NodeID: &nodeID}
+	mutation := NewResolver(db).Mutation()
+	result, err := callResolver(ctx, db, "Mutation", "saveFile", map[string]interface{}{"input": input}, func(ctx context.Context) (interface{}, error) {
+		return mutation.SaveFile(ctx, input)
+	})
+	if err != nil {
+		t.Fatalf("save %s: %v", name, err)
+	}
+	return result.(*model.File)
+}
+
+func TestSubscriptionsRequireViewPermission(t *testing.T) {
+	db := openTestDB(t)
+	admin := testAdminContext(t)
+	subscription := NewResolver(db).Subscription()
+	bobID := insertTestUser(t, db, "bob")
+	bob := subscriptionContext(t, testUserContext(t, bobID, "bob"))
+	carolID := insertTestUser(t, db, "carol")
+	carol := subscriptionContext(t, testUserContext(t, carolID, "carol"))
+
+	shared := createTestNode(t, db, "Delad", nil)
+	if _, err := NewResolver(db).Mutation().SetNodeOwnership(admin, shared.ID, &bobID, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewResolver(db).Mutation().SetNodePermissions(admin, shared.ID, PERM_VIEW); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := subscription.NodeChanged(carol, shared.ID); err == nil || err.Error() != "permission denied: cannot view this node" {
+		t.Errorf("subscribe to node without permission: err = %v", err)
+	}
+	if _, err := subscription.FileAdded(carol, shared.ID); err == nil || err.Error() != "permission denied: cannot view files in this node" {
+		t.Errorf("subscribe to files without permission: err = %v", err)
+	}
+
+	changes, err := subscription.NodeChanged(bob, shared.ID)
+	if err != nil {
+		t.Fatalf("subscribe to node changes: %v", err)
+	}
+	files, err := subscription.FileAdded(bob, shared.ID)
+	if err != nil {
+		t.Fatalf("subscribe to added files: %v", err)
+	}
+
+	saved := saveFileViaMutation(t, admin, db, "protokoll.txt", shared.ID)
+	change := receiveEvent(t, changes)
+	if change.Kind != model.NodeChangeKindFileAdded || change.FileID == nil || *change.FileID != saved.ID || change.Action != "saveFile" ||
+		change.ActorID == nil || *change.ActorID != "1" || change.Node == nil || change.Node.ID != shared.ID {
+		t.Errorf("node change = %+v", change)
+	}
+	if file := receiveEvent(t, files); file.ID != saved.ID || file.Name != "protokoll.txt" {
+		t.Errorf("added file = %+v", file)
+	}
+
+	// Behörigheten kontrolleras vid varje händelse, så en prenumerant som förlorat den får inget mer
+	if _, err := NewResolver(db).Mutation().SetNodePermissions(admin, shared.ID, 0); err != nil {
+		t.Fatal(err)
+	}
+	saveFileViaMutation(t, admin, db, "hemlig.txt", shared.ID)
+	expectNoEvent(t, changes)
+	expectNoEvent(t, files)
+}
+
+func TestNodeChangedEndsWhenNodeIsDeleted(t *testing.T) {
+	db := openTestDB(t)
+	admin := testAdminContext(t)
+	resolver := NewResolver(db)
+
+	node := createTestNode(t, db, "Tillfällig", nil)
+	changes, err := resolver.Subscription().NodeChanged(subscriptionContext(t, admin), node.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mutation := resolver.Mutation()
+	if _, err := callResolver(admin, db, "Mutation", "deleteNode", map[string]interface{}{"id": node.ID}, func(ctx context.Context) (interface{}, error) {
+		return mutation.DeleteNode(ctx, node.ID)
+	}); err != nil {
+		t.Fatalf("delete node: %v", err)
+	}
+
+	change := receiveEvent(t, changes)
+	if change.Kind != model.NodeChangeKindDeleted || change.Node != nil {
+		t.Errorf("change = %+v, want DELETED without the node", change)
+	}
+	select {
+	case _, ok := <-changes:
+		if ok {
+			t.Error("subscription continued after the node was deleted")
+		}
+	case <-time.After(2 * time.Second):
+		t.Error("subscription was not closed after the node was deleted")
+	}
+}
+
+func TestJobProgressSubscription(t *testing.T) {
+	db := openTestDB(t)
+	bobID := insertTestUser(t, db, "bob")
+	bob := subscriptionContext(t, testUserContext(t, bobID, "bob"))
+	carolID := insertTestUser(t, db, "carol")
+	subscription := NewResolver(db).Subscription()
+	registerTestJob(t, func(job *jobContext) (string, error) {
+		job.setProgress(50, "Halvvägs")
+		return "klart", nil
+	}, 1)
+
+	queued, err := enqueueJob(db, testJobType, bobID, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := subscription.JobProgress(testUserContext(t, carolID, "carol"), queued.ID); err == nil || err.Error() != "permission denied: cannot view this job" {
+		t.Errorf("subscribe to another user's job: err = %v", err)
+	}
+
+	progress, err := subscription.JobProgress(bob, queued.ID)
+	if err != nil {
+		t.Fatalf("subscribe to job progress: %v", err)
+	}
+	if job := receiveEvent(t, progress); job.Status != model.JobStatusQueued {
+		t.Errorf("first state = %s, want QUEUED", job.Status)
+	}
+
+	runQueuedTestJob(t, db)
+	// Varje ändring ger jobbets aktuella tillstånd, så snabba steg kan slås ihop, och
+	// prenumerationen avslutas när jobbet är klart
+	var last *model.Job
+	for job := range progress {
+		last = job
+	}
+	if last == nil || last.Status != model.JobStatusSucceeded || last.Result == nil || *last.Result != "klart" {
+		t.Errorf("last state = %+v, want SUCCEEDED", last)
+	}
+}
+
+func TestWebsocketInit(t *testing.T) {
+	db := openTestDB(t)
+	token, err := generateJWT("1", "admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, authorization := range []string{token, "Bearer " + token} {
+		ctx, _, err := WebsocketInit(context.Background(), transport.InitPayload{"Authorization": authorization})
+		if err != nil {
+			t.Fatalf("init with %q: %v", authorization, err)
+		}
+		// Anslutningens context används för prenumerationerna, med samma behörigheter som vanliga anrop
+		if allowed, err := checkPermission(ctx, db, "1", PERM_VIEW); err != nil || !allowed {
+			t.Errorf("permission on authenticated connection = %v, %v", allowed, err)
+		}
+	}
+
+	for _, payload := range []transport.InitPayload{{}, {"Authorization": "Bearer inte-en-token"}} {
+		if _, _, err := WebsocketInit(context.Background(), payload); err == nil || err.Error() != "not authenticated" {
+			t.Errorf("init with %v: err = %v", payload, err)
+		}
+	}
+}
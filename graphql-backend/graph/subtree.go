@@ -0,0 +1,228 @@
+package graph
+
+import (
+	"database/sql"
+	"graphql-backend/graph/model"
+	"log"
+)
+
+// =============================================
+// ========== NODTRÄD FÖR EXPORT =============
+// =============================================
+
+// treeNode är en nod med alla barnnoder och filer inlästa, används vid exporter
+type treeNode struct {
+	ID        string
+	Name      string
+	ParentID  *string
+	CreatedAt string
+	UpdatedAt string
+	OwnerName string
+	NodeType  model.NodeType
+	Fields    map[string]string // Noark 5-fält för typade noder
+	Metadata  []*model.Metadata
+	Children  []*treeNode
+	Files     []*treeFile
+}
+
+// treeFile beskriver en fil i ett nodträd utan binärdata.
+// Filinnehållet läses vid behov med readFileData.
+type treeFile struct {
+	ID          string
+	Name        string
+	Size        int
+	ContentType string
+	CreatedAt   string
+	NodeID      string
+	FileType    model.FileType
+	Checksum    string            // SHA256 från mottagandet, tom om den saknas
+	PUID        string            // Identifierat format, tom om formatet är okänt
+	Fields      map[string]string // Noark 5-fält för typade filer
+	Metadata    []*model.Metadata
+}
+
+// loadSubtree läser in en nod och hela dess underträd med filer och metadata
+func loadSubtree(db *sql.DB, nodeID string) (*treeNode, error) {
+	var node treeNode
+	var parentID sql.NullString
+	var ownerName sql.NullString
+	var nodeType string
+
+	err := db.QueryRow(`
+		SELECT n.id, n.name, n.parent_id, n.created_at, n.updated_at, n.node_type, u.username
+		FROM nodes n
+		LEFT JOIN users u ON n.owner_user_id = u.id
+		WHERE n.id = ?
+	`, nodeID).Scan(&node.ID, &node.Name, &parentID, &node.CreatedAt, &node.UpdatedAt, &nodeType, &ownerName)
+
+	if err == sql.ErrNoRows {
+		return nil, serviceErrorf(errNotFound, "node not found")
+	} else if err != nil {
+		log.Printf("Error fetching node with ID %s: %v", nodeID, err)
+		return nil, serviceErrorf(errInternal, "failed to fetch node: %v", err)
+	}
+
+	node.ParentID = nullStringPtr(parentID)
+	node.OwnerName = ownerName.String
+	node.NodeType = model.NodeType(nodeType)
+
+	node.Fields, err = loadTypedFields(db, ENTITY_KIND_NODE, node.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	node.Metadata, err = loadNodeMetadata(db, node.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	node.Files, err = loadTreeFiles(db, node.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query("SELECT id FROM nodes WHERE parent_id = ? ORDER BY name ASC", node.ID)
+	if err != nil {
+		log.Printf("Error fetching child nodes: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to fetch child nodes: %v", err)
+	}
+
+	var childIDs []string
+	for rows.Next() {
+		var childID string
+		if err := rows.Scan(&childID); err != nil {
+			rows.Close()
+			log.Printf("Error scanning child node: %v", err)
+			return nil, serviceErrorf(errInternal, "failed to scan child node: %v", err)
+		}
+		childIDs = append(childIDs, childID)
+	}
+	rows.Close()
+
+	for _, childID := range childIDs {
+		child, err := loadSubtree(db, childID)
+		if err != nil {
+			return nil, err
+		}
+		node.Children = append(node.Children, child)
+	}
+
+	return &node, nil
+}
+
+// loadTreeFiles läser in alla filer (utan binärdata) som ligger direkt i en nod
+func loadTreeFiles(db *sql.DB, nodeID string) ([]*treeFile, error) {
+	return queryTreeFiles(db, "node_id = ?", nodeID)
+}
+
+// loadTreeFile läser in en enskild fil (utan binärdata)
+func loadTreeFile(db *sql.DB, fileID string) (*treeFile, error) {
+	files, err := queryTreeFiles(db, "id = ?", fileID)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, serviceErrorf(errNotFound, "file not found")
+	}
+	return files[0], nil
+}
+
+// queryTreeFiles läser in filer som matchar ett villkor, med metadata och Noark 5-fält
+func queryTreeFiles(db *sql.DB, where string, args ...interface{}) ([]*treeFile, error) {
+	rows, err := db.Query(`
+		SELECT id, name, size, content_type, created_at, node_id, file_type, checksum, puid
+		FROM files
+		WHERE `+where+`
+		ORDER BY name ASC
+	`, args...)
+	if err != nil {
+		log.Printf("Error fetching files: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to fetch files: %v", err)
+	}
+	defer rows.Close()
+
+	var files []*treeFile
+	for rows.Next() {
+		file := &treeFile{}
+		var fileType string
+		var nodeID, checksum, puid sql.NullString
+		if err := rows.Scan(&file.ID, &file.Name, &file.Size, &file.ContentType, &file.CreatedAt, &nodeID, &fileType, &checksum, &puid); err != nil {
+			log.Printf("Error scanning file row: %v", err)
+			return nil, serviceErrorf(errInternal, "failed to scan file row: %v", err)
+		}
+		file.NodeID = nodeID.String
+		file.FileType = model.FileType(fileType)
+		file.Checksum = checksum.String
+		file.PUID = puid.String
+		files = append(files, file)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Printf("Error iterating over file rows: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to iterate over file rows: %v", err)
+	}
+
+	for _, file := range files {
+		file.Metadata, err = loadFileMetadata(db, file.ID)
+		if err != nil {
+			return nil, err
+		}
+		file.Fields, err = loadTypedFields(db, ENTITY_KIND_FILE, file.ID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return files, nil
+}
+
+// loadFileMetadata hämtar all metadata för en fil
+func loadFileMetadata(db *sql.DB, fileID string) ([]*model.Metadata, error) {
+	rows, err := db.Query("SELECT key, value, value_type FROM metadata WHERE file_id = ? ORDER BY id ASC", fileID)
+	if err != nil {
+		log.Printf("Error fetching metadata for file ID %s: %v", fileID, err)
+		return nil, serviceErrorf(errInternal, "failed to fetch metadata: %v", err)
+	}
+	defer rows.Close()
+
+	var metadata []*model.Metadata
+	for rows.Next() {
+		meta, err := scanMetadataRow(rows)
+		if err != nil {
+			return nil, err
+		}
+		metadata = append(metadata, meta)
+	}
+
+	return metadata, rows.Err()
+}
+
+// readFileData läser det binära innehållet för en fil
+func readFileData(db *sql.DB, fileID string) ([]byte, error) {
+	var data []byte
+	err := db.QueryRow("SELECT file_data FROM files WHERE id = ?", fileID).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, serviceErrorf(errNotFound, "file not found")
+	} else if err != nil {
+		log.Printf("Error reading data for file ID %s: %v", fileID, err)
+		return nil, serviceErrorf(errInternal, "failed to read file data: %v", err)
+	}
+	return data, nil
+}
+
+// walk anropar fn för noden och alla dess underliggande noder (djupet först)
+func (n *treeNode) walk(fn func(node *treeNode)) {
+	fn(n)
+	for _, child := range n.Children {
+		child.walk(fn)
+	}
+}
+
+// countFiles räknar antalet filer i hela underträdet
+func (n *treeNode) countFiles() int {
+	count := 0
+	n.walk(func(node *treeNode) {
+		count += len(node.Files)
+	})
+	return count
+}
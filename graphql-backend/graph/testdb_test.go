@@ -0,0 +1,237 @@
+package graph
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"fmt"
+	"graphql-backend/graph/model"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// testDBCounter ger varje testdatabas i minnet ett eget namn
+var testDBCounter atomic.Int64
+
+// openTestDB skapar en databas i minnet med samma schema som servern. Databasen delas mellan
+// anslutningarna, eftersom resolvers och tjänster ibland ställer en fråga medan de läser en annan.
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	name := fmt.Sprintf("file:testdb%d?mode=memory&cache=shared", testDBCounter.Add(1))
+	db, err := sql.Open("sqlite3", name)
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	// Databasen försvinner när den sista anslutningen stängs, så en anslutning hålls öppen
+	keepAlive, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatalf("open connection: %v", err)
+	}
+	t.Cleanup(func() {
+		keepAlive.Close()
+		db.Close()
+	})
+
+	createTestSchema(t, db)
+	return db
+}
+
+func createTestSchema(t *testing.T, db *sql.DB) {
+	t.Helper()
+	if err := Migrate(db, DialectSQLite); err != nil {
+		t.Fatalf("create schema: %v", err)
+	}
+}
+
+func mustExec(t *testing.T, db *sql.DB, query string, args ...interface{}) {
+	t.Helper()
+	if _, err := db.Exec(query, args...); err != nil {
+		t.Fatalf("%s: %v", query, err)
+	}
+}
+
+// insertTestNode skapar en nod direkt i databasen, utan förälder om parentID är tom
+func insertTestNode(t *testing.T, db *sql.DB, name string, parentID string) string {
+	t.Helper()
+	var parent interface{}
+	if parentID != "" {
+		parent = parentID
+	}
+	now := time.Now().Format(time.RFC3339)
+	result, err := db.Exec("INSERT INTO nodes (name, parent_id, created_at, updated_at) VALUES (?, ?, ?, ?)", name, parent, now, now)
+	if err != nil {
+		t.Fatalf("insert node: %v", err)
+	}
+	id, _ := result.LastInsertId()
+	return strconv.FormatInt(id, 10)
+}
+
+func insertTestFile(t *testing.T, db *sql.DB, name string, nodeID string) string {
+	t.Helper()
+	result, err := db.Exec(
+		"INSERT INTO files (name, size, content_type, created_at, file_data, node_id) VALUES (?, 5, 'text/plain', ?, 'hello', ?)",
+		name, time.Now().Format(time.RFC3339), nodeID,
+	)
+	if err != nil {
+		t.Fatalf("insert file: %v", err)
+	}
+	id, _ := result.LastInsertId()
+	return strconv.FormatInt(id, 10)
+}
+
+// insertTestUser skapar en användare utan gruppmedlemskap med lösenordet "secret"
+func insertTestUser(t *testing.T, db *sql.DB, username string) string {
+	t.Helper()
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("hash password: %v", err)
+	}
+	result, err := db.Exec(
+		"INSERT INTO users (username, password_hash, name, created_at) VALUES (?, ?, ?, ?)",
+		username, string(hash), username, time.Now().Format(time.RFC3339),
+	)
+	if err != nil {
+		t.Fatalf("insert user: %v", err)
+	}
+	id, _ := result.LastInsertId()
+	return strconv.FormatInt(id, 10)
+}
+
+// testUserContext ger en context med en token för användaren, som servern skapar för inloggade anrop
+func testUserContext(t *testing.T, userID string, username string) context.Context {
+	t.Helper()
+	token, err := generateJWT(userID, username)
+	if err != nil {
+		t.Fatalf("generate token: %v", err)
+	}
+	ctx := context.WithValue(context.Background(), "Authorization", token)
+	return context.WithValue(ctx, "Authenticate", token)
+}
+
+// testAdminContext ger en context för administratören som databasskriptet skapar
+func testAdminContext(t *testing.T) context.Context {
+	return testUserContext(t, "1", "admin")
+}
+
+// createTestNode skapar en nod som administratör genom nodtjänsten
+func createTestNode(t *testing.T, db *sql.DB, name string, parentID *string) *model.Node {
+	t.Helper()
+	node, err := NewServices(db).Nodes.Create(testAdminContext(t), model.NodeInput{Name: name, ParentID: parentID})
+	if err != nil {
+		t.Fatalf("create node %s: %v", name, err)
+	}
+	return node
+}
+
+// saveTestFile sparar en textfil med metadata som administratör
+func saveTestFile(t *testing.T, db *sql.DB, name string, nodeID string, content string) *model.File {
+	t.Helper()
+	file, err := NewServices(db).Files.Save(testAdminContext(t), model.FileInput{
+		Name:        name,
+		Size:        len(content),
+		ContentType: "text/plain",
+		FileData:    base64.StdEncoding.EncodeToString([]byte(content)),
+		NodeID:      &nodeID,
+		Metadata:    []*model.MetadataInput{{Key: "author", Value: "Anna"}},
+	})
+	if err != nil {
+		t.Fatalf("save file %s: %v", name, err)
+	}
+	return file
+}
+
+func fileMetadataValue(file *model.File, key string) (string, bool) {
+	for _, m := range file.Metadata {
+		if m != nil && m.Key == key {
+			return m.Value, true
+		}
+	}
+	return "", false
+}
+
+func writeTestFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// testJobContext lägger in ett körande jobb och ger den context som jobbfunktionerna får
+func testJobContext(t *testing.T, db *sql.DB) *jobContext {
+	t.Helper()
+	now := time.Now().Format(time.RFC3339)
+	result, err := db.Exec("INSERT INTO jobs (type, status, created_at, updated_at) VALUES ('TEST', ?, ?, ?)", model.JobStatusRunning, now, now)
+	if err != nil {
+		t.Fatalf("insert job: %v", err)
+	}
+	id, _ := result.LastInsertId()
+	return &jobContext{db: db, id: strconv.FormatInt(id, 10)}
+}
+
+// testZipArchive packar filerna i ett ZIP-arkiv, med sökvägen som nyckel
+func testZipArchive(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	writer := zip.NewWriter(&buf)
+	for _, name := range names {
+		w, err := writer.Create(name)
+		if err != nil {
+			t.Fatalf("create zip entry %s: %v", name, err)
+		}
+		if _, err := w.Write([]byte(files[name])); err != nil {
+			t.Fatalf("write zip entry %s: %v", name, err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("close zip archive: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// setTestNodeType ger en nod en Noark 5-typ och fält utan att kontrollera placeringsreglerna
+func setTestNodeType(t *testing.T, db *sql.DB, nodeID string, nodeType model.NodeType, fields map[string]string) {
+	t.Helper()
+	mustExec(t, db, "UPDATE nodes SET node_type = ? WHERE id = ?", nodeType, nodeID)
+	if err := saveTypedFields(db, ENTITY_KIND_NODE, nodeID, fields); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func strPtr(s string) *string {
+	return &s
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
+
+// withTestBearerToken lägger token i context som servern gör för inkommande anrop
+func withTestBearerToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+			ctx = context.WithValue(ctx, "Authorization", token)
+			ctx = context.WithValue(ctx, "Authenticate", token)
+		}
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
@@ -0,0 +1,966 @@
+package graph
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"graphql-backend/graph/model"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// =============================================
+// ========== ANVÄNDARTJÄNST =================
+// =============================================
+
+// userService implementerar UserService mot databasen
+type userService struct {
+	db *sql.DB
+}
+
+// Get hämtar en användare. Andra användare än den inloggade kräver administratör.
+func (s *userService) Get(ctx context.Context, id string) (*model.User, error) {
+	logAction(fmt.Sprintf("Fetching user with ID: %s", id))
+
+	// Only users with permission to view user information should be able to do this
+	currentUserID, err := getUserIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// If user is requesting their own info, allow it
+	if currentUserID == id {
+		// Self-lookup is always allowed
+	} else {
+		// Otherwise, check if user has admin privileges
+		var isAdmin bool
+		err = s.db.QueryRow(`
+			SELECT EXISTS(
+				SELECT 1 
+				FROM group_members gm
+				JOIN groups g ON gm.group_id = g.id
+				WHERE gm.user_id = ? AND g.name = 'Administrators'
+			)
+		`, currentUserID).Scan(&isAdmin)
+
+		if err != nil {
+			log.Printf("Error checking admin status: %v", err)
+			return nil, serviceErrorf(errInternal, "failed to check administrator status: %v", err)
+		}
+
+		if !isAdmin {
+			return nil, serviceErrorf(errPermissionDenied, "permission denied: must be an administrator to view other users")
+		}
+	}
+
+	// Query the user
+	var user model.User
+	err = s.db.QueryRow(`
+		SELECT id, username
+		FROM users
+		WHERE id = ?
+	`, id).Scan(&user.ID, &user.Username)
+
+	if err == sql.ErrNoRows {
+		return nil, serviceErrorf(errNotFound, "user not found")
+	} else if err != nil {
+		log.Printf("Error fetching user: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to fetch user: %v", err)
+	}
+
+	user.Name = user.Username // Using username as name for simplicity
+
+	// Get groups for the user
+	groups, err := getUserGroups(ctx, s.db, id)
+	if err != nil {
+		log.Printf("Error fetching user groups: %v", err)
+		// Continue without groups - not a critical error
+	} else {
+		user.Groups = groups
+	}
+
+	return &user, nil
+}
+
+// GetByUsername hämtar en användare med användarnamnet, utan behörighetskontroll. Används av
+// kommandoradsverktygen, som redan arbetar direkt mot databasen.
+func (s *userService) GetByUsername(ctx context.Context, username string) (*model.User, error) {
+	var id string
+	err := s.db.QueryRow("SELECT id FROM users WHERE username = ?", username).Scan(&id)
+	if err == sql.ErrNoRows {
+		return nil, serviceErrorf(errNotFound, "user not found")
+	} else if err != nil {
+		log.Printf("Error querying user by username: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to query user: %v", err)
+	}
+	return s.load(ctx, id)
+}
+
+// List hämtar alla användare. Kräver administratör.
+func (s *userService) List(ctx context.Context) ([]*model.User, error) {
+	logAction("Fetching all users")
+
+	// Get current user from context to verify permissions
+	currentUserID, err := getUserIDFromContext(ctx)
+	if err != nil {
+		return nil, serviceErrorf(errNotAuthenticated, "unauthorized: %v", err)
+	}
+
+	// Check if the current user is an administrator
+	var isAdmin bool
+	err = s.db.QueryRow(`
+		SELECT EXISTS(
+			SELECT 1 
+			FROM group_members gm
+			JOIN groups g ON gm.group_id = g.id
+			WHERE gm.user_id = ? AND g.name = 'Administrators'
+		)
+	`, currentUserID).Scan(&isAdmin)
+
+	if err != nil {
+		log.Printf("Error checking admin status: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to check administrator status: %v", err)
+	}
+
+	if !isAdmin {
+		return nil, serviceErrorf(errPermissionDenied, "permission denied: must be an administrator to list all users")
+	}
+
+	// Query all users
+	rows, err := s.db.Query("SELECT id, username, name FROM users")
+	if err != nil {
+		log.Printf("Error querying users: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to query users: %v", err)
+	}
+	defer rows.Close()
+
+	var users []*model.User
+	for rows.Next() {
+		var user model.User
+		if err := rows.Scan(&user.ID, &user.Username, &user.Name); err != nil {
+			log.Printf("Error scanning user row: %v", err)
+			return nil, serviceErrorf(errInternal, "failed to scan user data: %v", err)
+		}
+		users = append(users, &user)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Printf("Error iterating through users: %v", err)
+		return nil, fmt.Errorf("error reading user data: %v", err)
+	}
+
+	return users, nil
+}
+
+// Current hämtar användaren som anropets token tillhör
+func (s *userService) Current(ctx context.Context) (*model.User, error) {
+	logAction("Fetching current user from JWT token")
+
+	// Get the token from the context
+	token, ok := GetAuthToken(ctx)
+	if !ok {
+		return nil, errNotAuthenticated
+	}
+
+	// Parse and validate the token
+	claims, err := validateJWT(token)
+	if err != nil {
+		log.Printf("Error validating JWT token: %v", err)
+		return nil, errNotAuthenticated
+	}
+
+	// Get user ID from claims
+	userID, ok := claims["user_id"].(string)
+	if !ok {
+		log.Printf("Invalid token claims: user_id not found")
+		return nil, serviceErrorf(errNotAuthenticated, "invalid authentication token")
+	}
+
+	// Get username from claims
+	username, ok := claims["username"].(string)
+	if !ok {
+		log.Printf("Invalid token claims: username not found")
+		return nil, serviceErrorf(errNotAuthenticated, "invalid authentication token")
+	}
+
+	// Return user information
+	return &model.User{
+		ID:       userID,
+		Name:     username, // Using username as name for simplicity
+		Username: username,
+	}, nil
+}
+
+// Create skapar en användare. Kräver administratör.
+func (s *userService) Create(ctx context.Context, username string, password string, name *string) (*model.User, error) {
+	logAction(fmt.Sprintf("Creating new user with username: %s", username))
+
+	// Get current user from context to verify permissions
+	currentUserID, err := getUserIDFromContext(ctx)
+	if err != nil {
+		return nil, serviceErrorf(errNotAuthenticated, "unauthorized: %v", err)
+	}
+
+	// Check if the current user is an administrator or has PERM_MANAGE_USER permission
+	var isAdmin bool
+	err = s.db.QueryRow(`
+		SELECT EXISTS(
+			SELECT 1 
+			FROM group_members gm
+			JOIN groups g ON gm.group_id = g.id
+			WHERE gm.user_id = ? AND g.name = 'Administrators'
+		)
+	`, currentUserID).Scan(&isAdmin)
+
+	if err != nil {
+		log.Printf("Error checking admin status: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to check administrator status: %v", err)
+	}
+
+	if !isAdmin {
+		return nil, serviceErrorf(errPermissionDenied, "permission denied: must be an administrator to create users")
+	}
+
+	// Check if username already exists
+	var exists bool
+	err = s.db.QueryRow("SELECT EXISTS(SELECT 1 FROM users WHERE username = ?)", username).Scan(&exists)
+	if err != nil {
+		log.Printf("Error checking if user exists: %v", err)
+		return nil, serviceErrorf(errInternal, "internal server error: %v", err)
+	}
+
+	if exists {
+		return nil, serviceErrorf(errConflict, "username already taken")
+	}
+
+	// Default name to username if not provided
+	userName := username
+	if name != nil && *name != "" {
+		userName = *name
+	}
+
+	// Hash the password
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		log.Printf("Error hashing password: %v", err)
+		return nil, serviceErrorf(errInternal, "internal server error: %v", err)
+	}
+
+	// Insert the new user
+	result, err := s.db.Exec(
+		"INSERT INTO users (username, name, password_hash, created_at) VALUES (?, ?, ?, datetime('now'))",
+		username, userName, string(hashedPassword),
+	)
+	if err != nil {
+		log.Printf("Error creating user: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to create user: %v", err)
+	}
+
+	userID, err := result.LastInsertId()
+	if err != nil {
+		log.Printf("Error retrieving user ID: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to retrieve user ID: %v", err)
+	}
+
+	log.Printf("User created successfully with ID: %d", userID)
+
+	// Return the created user
+	return &model.User{
+		ID:       fmt.Sprintf("%d", userID),
+		Name:     userName,
+		Username: username,
+	}, nil
+}
+
+// Update ändrar användarnamn och namn på en användare
+func (s *userService) Update(ctx context.Context, id string, username *string, name *string) (*model.User, error) {
+	logAction(fmt.Sprintf("Updating user with ID: %s", id))
+
+	// Get current user from context to verify permissions
+	currentUserID, err := getUserIDFromContext(ctx)
+	if err != nil {
+		return nil, serviceErrorf(errNotAuthenticated, "unauthorized: %v", err)
+	}
+
+	// Check if the user exists
+	var exists bool
+	err = s.db.QueryRow("SELECT EXISTS(SELECT 1 FROM users WHERE id = ?)", id).Scan(&exists)
+	if err != nil {
+		log.Printf("Error checking if user exists: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to check if user exists: %v", err)
+	}
+
+	if !exists {
+		return nil, serviceErrorf(errNotFound, "user not found")
+	}
+
+	// Special protection for user 1 (admin)
+	userIDInt, _ := strconv.Atoi(id)
+	if userIDInt == 1 {
+		// For user 1, only allow updates if the current user is user 1
+		currentUserIDInt, _ := strconv.Atoi(currentUserID)
+		if currentUserIDInt != 1 {
+			return nil, serviceErrorf(errPermissionDenied, "permission denied: only user 1 can update their own account information")
+		}
+	} else {
+		// For other users, check if current user is an admin
+		var isAdmin bool
+		err = s.db.QueryRow(`
+			SELECT EXISTS(
+				SELECT 1 
+				FROM group_members gm
+				JOIN groups g ON gm.group_id = g.id
+				WHERE gm.user_id = ? AND g.name = 'Administrators'
+			)
+		`, currentUserID).Scan(&isAdmin)
+
+		if err != nil {
+			log.Printf("Error checking admin status: %v", err)
+			return nil, serviceErrorf(errInternal, "failed to check administrator status: %v", err)
+		}
+
+		if !isAdmin {
+			return nil, serviceErrorf(errPermissionDenied, "permission denied: must be an administrator to update users")
+		}
+	}
+
+	// Check if username already exists (if username is being changed)
+	if username != nil && *username != "" {
+		var usernameExists bool
+		err = s.db.QueryRow("SELECT EXISTS(SELECT 1 FROM users WHERE username = ? AND id != ?)", *username, id).Scan(&usernameExists)
+		if err != nil {
+			log.Printf("Error checking if username exists: %v", err)
+			return nil, serviceErrorf(errInternal, "failed to check if username exists: %v", err)
+		}
+
+		if usernameExists {
+			return nil, serviceErrorf(errConflict, "username already taken")
+		}
+	}
+
+	// Build update query dynamically based on which fields are provided
+	query := "UPDATE users SET"
+	args := []interface{}{}
+	requiresComma := false
+
+	if username != nil && *username != "" {
+		query += " username = ?"
+		args = append(args, *username)
+		requiresComma = true
+	}
+
+	if name != nil {
+		if requiresComma {
+			query += ","
+		}
+		query += " name = ?"
+		args = append(args, *name)
+	}
+
+	// If no fields were provided, return the user without updating
+	if len(args) == 0 {
+		return s.load(ctx, id)
+	}
+
+	query += " WHERE id = ?"
+	args = append(args, id)
+
+	// Execute the update
+	_, err = s.db.Exec(query, args...)
+	if err != nil {
+		log.Printf("Error updating user: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to update user: %v", err)
+	}
+
+	log.Printf("User with ID %s updated successfully", id)
+
+	// Return the updated user
+	return s.load(ctx, id)
+}
+
+// SetPassword sätter en användares lösenord utan att kräva det gamla
+func (s *userService) SetPassword(ctx context.Context, userID string, newPassword string) (bool, error) {
+	logAction(fmt.Sprintf("Updating password for user ID: %s", userID))
+
+	// Get current user from context to verify permissions
+	currentUserID, err := getUserIDFromContext(ctx)
+	if err != nil {
+		return false, serviceErrorf(errNotAuthenticated, "unauthorized: %v", err)
+	}
+
+	// Check if target user exists
+	var exists bool
+	err = s.db.QueryRow("SELECT EXISTS(SELECT 1 FROM users WHERE id = ?)", userID).Scan(&exists)
+	if err != nil {
+		log.Printf("Error checking if user exists: %v", err)
+		return false, serviceErrorf(errInternal, "failed to check if user exists: %v", err)
+	}
+
+	if !exists {
+		return false, serviceErrorf(errNotFound, "user not found")
+	}
+
+	// Special protection for user 1 (admin)
+	userIDInt, _ := strconv.Atoi(userID)
+	currentUserIDInt, _ := strconv.Atoi(currentUserID)
+
+	if userIDInt == 1 {
+		// Only user 1 can update user 1's password
+		if currentUserIDInt != 1 {
+			return false, serviceErrorf(errPermissionDenied, "permission denied: only user 1 can update their own password")
+		}
+	} else {
+		// For other users, check if current user is an admin or the user themselves
+		var isAdmin bool
+		if currentUserIDInt != userIDInt {
+			// If not updating own password, must be admin
+			err = s.db.QueryRow(`
+				SELECT EXISTS(
+					SELECT 1 
+					FROM group_members gm
+					JOIN groups g ON gm.group_id = g.id
+					WHERE gm.user_id = ? AND g.name = 'Administrators'
+				)
+			`, currentUserID).Scan(&isAdmin)
+
+			if err != nil {
+				log.Printf("Error checking admin status: %v", err)
+				return false, serviceErrorf(errInternal, "failed to check administrator status: %v", err)
+			}
+
+			if !isAdmin {
+				return false, serviceErrorf(errPermissionDenied, "permission denied: must be an administrator to update another user's password")
+			}
+		}
+	}
+
+	// Hash the new password
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	if err != nil {
+		log.Printf("Error hashing password: %v", err)
+		return false, serviceErrorf(errInternal, "internal server error: %v", err)
+	}
+
+	// Update the password
+	_, err = s.db.Exec("UPDATE users SET password_hash = ? WHERE id = ?", string(hashedPassword), userID)
+	if err != nil {
+		log.Printf("Error updating password: %v", err)
+		return false, serviceErrorf(errInternal, "failed to update password: %v", err)
+	}
+
+	log.Printf("Password updated successfully for user ID: %s", userID)
+	return true, nil
+}
+
+// Delete tar bort en användare. Kräver administratör.
+func (s *userService) Delete(ctx context.Context, id string) (bool, error) {
+	logAction(fmt.Sprintf("Attempting to delete user with ID: %s", id))
+
+	// Get current user from context to verify permissions
+	currentUserID, err := getUserIDFromContext(ctx)
+	if err != nil {
+		return false, serviceErrorf(errNotAuthenticated, "unauthorized: %v", err)
+	}
+
+	// Special protection for user 1 (admin) - cannot be deleted
+	userIDInt, err := strconv.Atoi(id)
+	if err != nil {
+		return false, fmt.Errorf("invalid user ID: %v", err)
+	}
+
+	if userIDInt == 1 {
+		return false, serviceErrorf(errPermissionDenied, "permission denied: the admin user (ID 1) cannot be deleted")
+	}
+
+	// Check if the user exists
+	var exists bool
+	err = s.db.QueryRow("SELECT EXISTS(SELECT 1 FROM users WHERE id = ?)", id).Scan(&exists)
+	if err != nil {
+		log.Printf("Error checking if user exists: %v", err)
+		return false, serviceErrorf(errInternal, "failed to check if user exists: %v", err)
+	}
+
+	if !exists {
+		return false, serviceErrorf(errNotFound, "user not found")
+	}
+
+	// Check if the current user is an administrator
+	var isAdmin bool
+	err = s.db.QueryRow(`
+		SELECT EXISTS(
+			SELECT 1 
+			FROM group_members gm
+			JOIN groups g ON gm.group_id = g.id
+			WHERE gm.user_id = ? AND g.name = 'Administrators'
+		)
+	`, currentUserID).Scan(&isAdmin)
+
+	if err != nil {
+		log.Printf("Error checking admin status: %v", err)
+		return false, serviceErrorf(errInternal, "failed to check administrator status: %v", err)
+	}
+
+	if !isAdmin {
+		return false, serviceErrorf(errPermissionDenied, "permission denied: must be an administrator to delete users")
+	}
+
+	// Begin a transaction to handle user deletion
+	tx, err := s.db.Begin()
+	if err != nil {
+		log.Printf("Error starting transaction: %v", err)
+		return false, serviceErrorf(errInternal, "internal server error: %v", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// Delete user's group memberships
+	_, err = tx.Exec("DELETE FROM group_members WHERE user_id = ?", id)
+	if err != nil {
+		log.Printf("Error deleting user group memberships: %v", err)
+		return false, serviceErrorf(errInternal, "failed to delete user group memberships: %v", err)
+	}
+
+	// Delete user's settings
+	_, err = tx.Exec("DELETE FROM user_settings WHERE user_id = ?", id)
+	if err != nil {
+		log.Printf("Error deleting user settings: %v", err)
+		return false, serviceErrorf(errInternal, "failed to delete user settings: %v", err)
+	}
+
+	// Set any nodes owned by this user to NULL owner
+	_, err = tx.Exec("UPDATE nodes SET owner_user_id = NULL WHERE owner_user_id = ?", id)
+	if err != nil {
+		log.Printf("Error updating node ownership: %v", err)
+		return false, serviceErrorf(errInternal, "failed to update node ownership: %v", err)
+	}
+
+	// Finally, delete the user
+	result, err := tx.Exec("DELETE FROM users WHERE id = ?", id)
+	if err != nil {
+		log.Printf("Error deleting user: %v", err)
+		return false, serviceErrorf(errInternal, "failed to delete user: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		log.Printf("Error getting rows affected: %v", err)
+		return false, fmt.Errorf("error confirming deletion: %v", err)
+	}
+
+	if rowsAffected == 0 {
+		tx.Rollback()
+		return false, serviceErrorf(errNotFound, "user not found")
+	}
+
+	// Commit the transaction
+	if err = tx.Commit(); err != nil {
+		log.Printf("Error committing transaction: %v", err)
+		return false, serviceErrorf(errInternal, "failed to commit transaction: %v", err)
+	}
+
+	log.Printf("User with ID %s deleted successfully", id)
+	return true, nil
+}
+
+// Login kontrollerar användarnamn och lösenord och skapar en token
+func (s *userService) Login(ctx context.Context, username string, password string) (*model.AuthPayload, error) {
+	logAction(fmt.Sprintf("Login attempt for user: %s", username))
+
+	if s.db == nil {
+		log.Printf("Database connection is nil")
+		return nil, serviceErrorf(errInternal, "internal server error: database connection is not initialized")
+	}
+
+	// Query the database for the user with the provided username
+	var id string
+	var passwordHash string
+	var name string
+	err := s.db.QueryRow("SELECT id, password_hash, name FROM users WHERE username = ?", username).Scan(&id, &passwordHash, &name)
+
+	// Check if user exists
+	if err == sql.ErrNoRows {
+		log.Printf("User not found: %s", username)
+		return nil, serviceErrorf(errNotAuthenticated, "invalid username or password")
+	} else if err != nil {
+		log.Printf("Error querying user: %v", err)
+		return nil, serviceErrorf(errInternal, "internal server error: %v", err)
+	}
+
+	// Compare the provided password with the stored hash
+	err = bcrypt.CompareHashAndPassword([]byte(passwordHash), []byte(password))
+	if err != nil {
+		log.Printf("Invalid password for user: %s", username)
+		return nil, serviceErrorf(errNotAuthenticated, "invalid username or password")
+	}
+
+	// Generate JWT token
+	token, err := generateJWT(id, username)
+	if err != nil {
+		log.Printf("Error generating JWT token: %v", err)
+		return nil, serviceErrorf(errInternal, "internal server error: %v", err)
+	}
+
+	// Return the auth payload
+	return &model.AuthPayload{
+		Token: token,
+		User: &model.User{
+			ID:       id,
+			Name:     name,
+			Username: username,
+		},
+	}, nil
+}
+
+// Logout ogiltigförklarar en token
+func (s *userService) Logout(ctx context.Context, token string) (bool, error) {
+	logAction("Logout request received")
+
+	// Parse and validate the token first to ensure it's a valid token
+	_, err := validateJWT(token)
+	if err != nil {
+		// If token is already invalid, we consider logout successful
+		if errors.Is(err, errTokenInvalidated) {
+			return true, nil
+		}
+		log.Printf("Error validating token during logout: %v", err)
+		return false, serviceErrorf(errNotAuthenticated, "invalid token")
+	}
+
+	// Add the token to the blacklist
+	BlacklistToken(token)
+	log.Printf("Successfully invalidated token")
+
+	return true, nil
+}
+
+// Register skapar ett konto och loggar in det
+func (s *userService) Register(ctx context.Context, username string, password string) (*model.AuthPayload, error) {
+	logAction(fmt.Sprintf("Registration attempt for user: %s", username))
+
+	if s.db == nil {
+		log.Printf("Database connection is nil")
+		return nil, serviceErrorf(errInternal, "internal server error: database connection is not initialized")
+	}
+
+	// Check if username already exists
+	var exists bool
+	err := s.db.QueryRow("SELECT EXISTS(SELECT 1 FROM users WHERE username = ?)", username).Scan(&exists)
+	if err != nil {
+		log.Printf("Error checking if user exists: %v", err)
+		return nil, serviceErrorf(errInternal, "internal server error: %v", err)
+	}
+
+	if exists {
+		log.Printf("Username already taken: %s", username)
+		return nil, serviceErrorf(errConflict, "username already taken")
+	}
+
+	// Hash the password
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		log.Printf("Error hashing password: %v", err)
+		return nil, serviceErrorf(errInternal, "internal server error: %v", err)
+	}
+
+	// Start a transaction
+	tx, err := s.db.Begin()
+	if err != nil {
+		log.Printf("Error starting transaction: %v", err)
+		return nil, serviceErrorf(errInternal, "internal server error: %v", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// Insert the new user (use username as name)
+	result, err := tx.Exec(
+		"INSERT INTO users (username, name, password_hash, created_at) VALUES (?, ?, ?, datetime('now'))",
+		username, username, string(hashedPassword),
+	)
+	if err != nil {
+		log.Printf("Error inserting user: %v", err)
+		return nil, serviceErrorf(errInternal, "internal server error: %v", err)
+	}
+
+	// Get the user ID
+	userID, err := result.LastInsertId()
+	if err != nil {
+		log.Printf("Error retrieving user ID: %v", err)
+		return nil, serviceErrorf(errInternal, "internal server error: %v", err)
+	}
+
+	// Commit the transaction
+	if err = tx.Commit(); err != nil {
+		log.Printf("Error committing transaction: %v", err)
+		return nil, serviceErrorf(errInternal, "internal server error: %v", err)
+	}
+
+	// Generate JWT token
+	token, err := generateJWT(fmt.Sprintf("%d", userID), username)
+	if err != nil {
+		log.Printf("Error generating JWT token: %v", err)
+		return nil, serviceErrorf(errInternal, "internal server error: %v", err)
+	}
+
+	log.Printf("User registered successfully: %s", username)
+
+	// Return the auth payload
+	return &model.AuthPayload{
+		Token: token,
+		User: &model.User{
+			ID:       fmt.Sprintf("%d", userID),
+			Name:     username,
+			Username: username,
+		},
+	}, nil
+}
+
+// ChangePassword byter den inloggade användarens lösenord
+func (s *userService) ChangePassword(ctx context.Context, currentPassword string, newPassword string) (bool, error) {
+	logAction("Attempting to update password")
+
+	// Get authorization token from context
+	authToken, ok := ctx.Value("Authorization").(string)
+	if !ok || authToken == "" {
+		log.Printf("No authorization token found in context")
+		return false, serviceErrorf(errNotAuthenticated, "unauthorized: missing token")
+	}
+
+	// Remove "Bearer " prefix if present
+	authToken = strings.TrimPrefix(authToken, "Bearer ")
+
+	// Get user claims from JWT token
+	claims, err := validateJWT(authToken)
+	if err != nil {
+		log.Printf("Invalid token: %v", err)
+		return false, serviceErrorf(errNotAuthenticated, "unauthorized: invalid token")
+	}
+
+	// Get user ID from claims
+	userID, ok := claims["user_id"].(string)
+	if !ok {
+		log.Printf("User ID not found in token claims")
+		return false, serviceErrorf(errNotAuthenticated, "unauthorized: invalid token claims")
+	}
+
+	// Verify current password
+	var storedHash string
+	err = s.db.QueryRow("SELECT password_hash FROM users WHERE id = ?", userID).Scan(&storedHash)
+	if err != nil {
+		log.Printf("Error fetching user password hash: %v", err)
+		return false, serviceErrorf(errInternal, "internal server error")
+	}
+
+	// Compare current password with stored hash
+	if err := bcrypt.CompareHashAndPassword([]byte(storedHash), []byte(currentPassword)); err != nil {
+		log.Printf("Invalid current password for user ID %s: %v", userID, err)
+		return false, fmt.Errorf("invalid current password")
+	}
+
+	// Hash the new password
+	newHash, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	if err != nil {
+		log.Printf("Error hashing new password: %v", err)
+		return false, serviceErrorf(errInternal, "internal server error")
+	}
+
+	// Update the password in the database
+	_, err = s.db.Exec("UPDATE users SET password_hash = ? WHERE id = ?", string(newHash), userID)
+	if err != nil {
+		log.Printf("Error updating password: %v", err)
+		return false, serviceErrorf(errInternal, "failed to update password")
+	}
+
+	log.Printf("Successfully updated password for user ID: %s", userID)
+	return true, nil
+}
+
+// Settings hämtar den inloggade användarens inställningar
+func (s *userService) Settings(ctx context.Context) ([]*model.UserSetting, error) {
+	logAction("Fetching current user settings")
+
+	// Get user ID from JWT token
+	userID, err := getUserIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Query the database for all settings for this user
+	rows, err := s.db.Query(`
+		SELECT id, key, value, created_at, updated_at
+		FROM user_settings
+		WHERE user_id = ?
+		ORDER BY key ASC
+	`, userID)
+
+	if err != nil {
+		log.Printf("Error fetching user settings: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to fetch user settings: %v", err)
+	}
+	defer rows.Close()
+
+	var settings []*model.UserSetting
+	for rows.Next() {
+		var setting model.UserSetting
+		var createdAt, updatedAt string
+		if err := rows.Scan(&setting.ID, &setting.Key, &setting.Value, &createdAt, &updatedAt); err != nil {
+			log.Printf("Error scanning user setting row: %v", err)
+			return nil, serviceErrorf(errInternal, "failed to read user settings: %v", err)
+		}
+		setting.CreatedAt = createdAt
+		setting.UpdatedAt = updatedAt
+		settings = append(settings, &setting)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Printf("Error iterating through user settings rows: %v", err)
+		return nil, fmt.Errorf("error reading user settings: %v", err)
+	}
+
+	return settings, nil
+}
+
+// Setting hämtar en inställning för den inloggade användaren
+func (s *userService) Setting(ctx context.Context, key string) (*model.UserSetting, error) {
+	logAction(fmt.Sprintf("Fetching user setting with key: %s", key))
+
+	// Get user ID from JWT token
+	userID, err := getUserIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Query the database for the specific setting
+	var setting model.UserSetting
+	var createdAt, updatedAt string
+	err = s.db.QueryRow(`
+		SELECT id, key, value, created_at, updated_at
+		FROM user_settings
+		WHERE user_id = ? AND key = ?
+	`, userID, key).Scan(&setting.ID, &setting.Key, &setting.Value, &createdAt, &updatedAt)
+
+	if err == sql.ErrNoRows {
+		return nil, nil // Return nil if setting not found
+	} else if err != nil {
+		log.Printf("Error fetching user setting: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to fetch user setting: %v", err)
+	}
+
+	setting.CreatedAt = createdAt
+	setting.UpdatedAt = updatedAt
+
+	return &setting, nil
+}
+
+// SaveSetting sparar en inställning för den inloggade användaren
+func (s *userService) SaveSetting(ctx context.Context, key string, value string) (*model.UserSetting, error) {
+	logAction(fmt.Sprintf("Saving user setting: %s = %s", key, value))
+
+	// Get user ID from JWT token
+	userID, err := getUserIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get current time for timestamps
+	now := time.Now().Format(time.RFC3339)
+
+	// Try to insert or update the setting using UPSERT pattern
+	result, err := s.db.Exec(`
+		INSERT INTO user_settings (user_id, key, value, created_at, updated_at) 
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(user_id, key) DO UPDATE SET
+		value = ?,
+		updated_at = ?
+	`, userID, key, value, now, now, value, now)
+
+	if err != nil {
+		log.Printf("Error saving user setting: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to save user setting: %v", err)
+	}
+
+	// Get the ID of the inserted/updated setting
+	var settingID int64
+	if lastInsertID, err := result.LastInsertId(); err == nil && lastInsertID > 0 {
+		// If this was a new insert
+		settingID = lastInsertID
+	} else {
+		// If this was an update, query for the ID
+		err = s.db.QueryRow("SELECT id FROM user_settings WHERE user_id = ? AND key = ?", userID, key).Scan(&settingID)
+		if err != nil {
+			log.Printf("Error retrieving setting ID: %v", err)
+			return nil, fmt.Errorf("setting was saved but failed to retrieve ID: %v", err)
+		}
+	}
+
+	return &model.UserSetting{
+		ID:        fmt.Sprintf("%d", settingID),
+		Key:       key,
+		Value:     value,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}, nil
+}
+
+// DeleteSetting tar bort en inställning för den inloggade användaren
+func (s *userService) DeleteSetting(ctx context.Context, key string) (bool, error) {
+	logAction(fmt.Sprintf("Deleting user setting with key: %s", key))
+
+	// Get user ID from JWT token
+	userID, err := getUserIDFromContext(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	// Delete the setting
+	result, err := s.db.Exec("DELETE FROM user_settings WHERE user_id = ? AND key = ?", userID, key)
+	if err != nil {
+		log.Printf("Error deleting user setting: %v", err)
+		return false, serviceErrorf(errInternal, "failed to delete user setting: %v", err)
+	}
+
+	// Check if any rows were affected
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		log.Printf("Error checking rows affected: %v", err)
+		return false, fmt.Errorf("error confirming deletion: %v", err)
+	}
+
+	return rowsAffected > 0, nil
+}
+
+// load hämtar en användare med grupper, utan behörighetskontroll
+func (s *userService) load(ctx context.Context, id string) (*model.User, error) {
+	var user model.User
+	err := s.db.QueryRow("SELECT id, username, name FROM users WHERE id = ?", id).Scan(
+		&user.ID, &user.Username, &user.Name)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, serviceErrorf(errNotFound, "user not found")
+		}
+		log.Printf("Error querying user by ID: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to query user: %v", err)
+	}
+
+	// Get user's groups
+	groups, err := getUserGroups(ctx, s.db, id)
+	if err != nil {
+		log.Printf("Warning: Failed to fetch user's groups: %v", err)
+		// Continue without groups, it's not critical
+	} else {
+		user.Groups = groups
+	}
+
+	return &user, nil
+}
@@ -0,0 +1,158 @@
+package graph
+
+import (
+	"testing"
+)
+
+func TestUserServiceCreateAndLookup(t *testing.T) {
+	db := openTestDB(t)
+	users := NewServices(db).Users
+	ctx := testAdminContext(t)
+
+	created, err := users.Create(ctx, "anna", "hemligt", strPtr("Anna Andersson"))
+	if err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	if created.Username != "anna" || created.Name != "Anna Andersson" {
+		t.Errorf("created %s (%s), want anna (Anna Andersson)", created.Username, created.Name)
+	}
+	if _, err := users.Create(ctx, "anna", "annat", nil); err == nil || err.Error() != "username already taken" {
+		t.Errorf("create duplicate: err = %v, want username already taken", err)
+	}
+
+	byName, err := users.GetByUsername(ctx, "anna")
+	if err != nil {
+		t.Fatalf("get by username: %v", err)
+	}
+	if byName.ID != created.ID {
+		t.Errorf("id = %s, want %s", byName.ID, created.ID)
+	}
+	if _, err := users.GetByUsername(ctx, "okänd"); err == nil || err.Error() != "user not found" {
+		t.Errorf("get unknown username: err = %v, want user not found", err)
+	}
+
+	all, err := users.List(ctx)
+	if err != nil {
+		t.Fatalf("list users: %v", err)
+	}
+	if len(all) != 2 {
+		t.Errorf("users = %d, want admin and anna", len(all))
+	}
+
+	// Vanliga användare får inte skapa konton
+	anna := testUserContext(t, created.ID, "anna")
+	if _, err := users.Create(anna, "bertil", "hemligt", nil); err == nil || err.Error() != "permission denied: must be an administrator to create users" {
+		t.Errorf("create as non-admin: err = %v", err)
+	}
+	current, err := users.Current(anna)
+	if err != nil {
+		t.Fatalf("current user: %v", err)
+	}
+	if current.ID != created.ID {
+		t.Errorf("current user = %s, want %s", current.ID, created.ID)
+	}
+}
+
+func TestUserServiceLoginAndPasswords(t *testing.T) {
+	db := openTestDB(t)
+	users := NewServices(db).Users
+	bobID := insertTestUser(t, db, "bob")
+
+	if _, err := users.Login(t.Context(), "bob", "fel"); err == nil || err.Error() != "invalid username or password" {
+		t.Errorf("login with wrong password: err = %v", err)
+	}
+	payload, err := users.Login(t.Context(), "bob", "secret")
+	if err != nil {
+		t.Fatalf("login: %v", err)
+	}
+	if payload.User.ID != bobID || payload.Token == "" {
+		t.Errorf("login payload = %+v, want bob with a token", payload.User)
+	}
+
+	bob := testUserContext(t, bobID, "bob")
+	if _, err := users.ChangePassword(bob, "fel", "nytt"); err == nil || err.Error() != "invalid current password" {
+		t.Errorf("change password with wrong current: err = %v", err)
+	}
+	if ok, err := users.ChangePassword(bob, "secret", "nytt"); err != nil || !ok {
+		t.Fatalf("change password: %v, %v", ok, err)
+	}
+	if _, err := users.Login(t.Context(), "bob", "nytt"); err != nil {
+		t.Errorf("login with changed password: %v", err)
+	}
+
+	if ok, err := users.SetPassword(testAdminContext(t), bobID, "återställt"); err != nil || !ok {
+		t.Fatalf("set password: %v, %v", ok, err)
+	}
+	if _, err := users.Login(t.Context(), "bob", "återställt"); err != nil {
+		t.Errorf("login with reset password: %v", err)
+	}
+
+	// Utloggningen spärrar token för hela processen, så spärren tas bort efter testet. Samma
+	// användare får samma token inom samma sekund, så utloggningen görs sist.
+	t.Cleanup(func() {
+		tokenBlacklistMutex.Lock()
+		defer tokenBlacklistMutex.Unlock()
+		delete(TokenBlacklist, payload.Token)
+	})
+	if ok, err := users.Logout(t.Context(), payload.Token); err != nil || !ok {
+		t.Fatalf("logout: %v, %v", ok, err)
+	}
+	if _, err := validateJWT(payload.Token); err == nil {
+		t.Error("token is still valid after logout")
+	}
+}
+
+func TestUserServiceSettings(t *testing.T) {
+	db := openTestDB(t)
+	users := NewServices(db).Users
+	bobID := insertTestUser(t, db, "bob")
+	bob := testUserContext(t, bobID, "bob")
+
+	if _, err := users.SaveSetting(bob, "theme", "light"); err != nil {
+		t.Fatalf("save setting: %v", err)
+	}
+	if _, err := users.SaveSetting(bob, "theme", "dark"); err != nil {
+		t.Fatalf("overwrite setting: %v", err)
+	}
+
+	setting, err := users.Setting(bob, "theme")
+	if err != nil {
+		t.Fatalf("get setting: %v", err)
+	}
+	if setting == nil || setting.Value != "dark" {
+		t.Errorf("theme = %+v, want dark", setting)
+	}
+
+	// Inställningarna är personliga
+	if others, err := users.Settings(testAdminContext(t)); err != nil || len(others) != 0 {
+		t.Errorf("admin settings = %d, %v, want none", len(others), err)
+	}
+
+	if ok, err := users.DeleteSetting(bob, "theme"); err != nil || !ok {
+		t.Fatalf("delete setting: %v, %v", ok, err)
+	}
+	settings, err := users.Settings(bob)
+	if err != nil {
+		t.Fatalf("list settings: %v", err)
+	}
+	if len(settings) != 0 {
+		t.Errorf("settings after delete = %d, want none", len(settings))
+	}
+}
+
+func TestUserServiceDelete(t *testing.T) {
+	db := openTestDB(t)
+	users := NewServices(db).Users
+	ctx := testAdminContext(t)
+	bobID := insertTestUser(t, db, "bob")
+
+	if _, err := users.Delete(ctx, "1"); err == nil || err.Error() != "permission denied: the admin user (ID 1) cannot be deleted" {
+		t.Errorf("delete admin: err = %v", err)
+	}
+	if ok, err := users.Delete(ctx, bobID); err != nil || !ok {
+		t.Fatalf("delete user: %v, %v", ok, err)
+	}
+	if _, err := users.Get(ctx, bobID); err == nil || err.Error() != "user not found" {
+		t.Errorf("get deleted user: err = %v, want user not found", err)
+	}
+}
@@ -0,0 +1,622 @@
+package graph
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"graphql-backend/graph/model"
+	"io"
+	"log"
+	"strings"
+)
+
+// =============================================
+// ========== VOKABULÄRER ====================
+// =============================================
+
+// Namnrymder i SKOS-filer (RDF/XML)
+const (
+	rdfNamespace  = "http://www.w3.org/1999/02/22-rdf-syntax-ns#"
+	skosNamespace = "http://www.w3.org/2004/02/skos/core#"
+)
+
+// Kolumner som läses för en term, i den ordning loadVocabularyTerms förväntar sig
+const vocabularyTermColumns = "id, vocabulary_id, code, label, alt_labels, description, uri, parent_id"
+
+// getVocabulary hämtar en vokabulär utan termer; termerna hämtas av fältresolvern
+func getVocabulary(db sqlQueryer, vocabularyID string) (*model.Vocabulary, error) {
+	var vocabulary model.Vocabulary
+	var description sql.NullString
+	var kind string
+	err := db.QueryRow(`
+		SELECT id, name, description, kind, created_at, updated_at
+		FROM vocabularies WHERE id = ?
+	`, vocabularyID).Scan(&vocabulary.ID, &vocabulary.Name, &description, &kind, &vocabulary.CreatedAt, &vocabulary.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, serviceErrorf(errNotFound, "vocabulary not found")
+	} else if err != nil {
+		log.Printf("Error fetching vocabulary %s: %v", vocabularyID, err)
+		return nil, serviceErrorf(errInternal, "failed to fetch vocabulary: %v", err)
+	}
+	vocabulary.Description = nullStringPtr(description)
+	vocabulary.Kind = model.VocabularyKind(kind)
+	return &vocabulary, nil
+}
+
+// loadVocabularyTerms hämtar termer som matchar ett villkor, i den ordning de skapades
+func loadVocabularyTerms(db sqlQueryer, where string, args ...interface{}) ([]*model.VocabularyTerm, error) {
+	rows, err := db.Query("SELECT "+vocabularyTermColumns+" FROM vocabulary_terms WHERE "+where+" ORDER BY id ASC", args...)
+	if err != nil {
+		log.Printf("Error fetching vocabulary terms: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to fetch vocabulary terms: %v", err)
+	}
+	defer rows.Close()
+
+	terms := []*model.VocabularyTerm{}
+	for rows.Next() {
+		var term model.VocabularyTerm
+		var altLabels, description, uri, parentID sql.NullString
+		err := rows.Scan(&term.ID, &term.VocabularyID, &term.Code, &term.Label, &altLabels, &description, &uri, &parentID)
+		if err != nil {
+			log.Printf("Error scanning vocabulary term: %v", err)
+			return nil, serviceErrorf(errInternal, "failed to read vocabulary term: %v", err)
+		}
+
+		term.AltLabels = []string{}
+		if altLabels.Valid {
+			if err := json.Unmarshal([]byte(altLabels.String), &term.AltLabels); err != nil {
+				log.Printf("Error parsing alternative labels of term %s: %v", term.ID, err)
+				return nil, serviceErrorf(errInternal, "failed to read vocabulary term: %v", err)
+			}
+		}
+		term.Description = nullStringPtr(description)
+		term.URI = nullStringPtr(uri)
+		term.ParentID = nullStringPtr(parentID)
+		terms = append(terms, &term)
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("Error iterating vocabulary terms: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to read vocabulary terms: %v", err)
+	}
+	return terms, nil
+}
+
+// getVocabularyTerm hämtar en enskild term
+func getVocabularyTerm(db sqlQueryer, termID string) (*model.VocabularyTerm, error) {
+	terms, err := loadVocabularyTerms(db, "id = ?", termID)
+	if err != nil {
+		return nil, err
+	}
+	if len(terms) == 0 {
+		return nil, serviceErrorf(errNotFound, "vocabulary term not found")
+	}
+	return terms[0], nil
+}
+
+// matchVocabularyTerm hittar termen som ett värde avser. Koden har företräde framför namnet och
+// namnet framför alternativa namn. Versaler och gemener räknas som samma tecken.
+func matchVocabularyTerm(terms []*model.VocabularyTerm, value string) *model.VocabularyTerm {
+	value = strings.TrimSpace(value)
+	for _, term := range terms {
+		if strings.EqualFold(term.Code, value) {
+			return term
+		}
+	}
+	for _, term := range terms {
+		if strings.EqualFold(term.Label, value) {
+			return term
+		}
+	}
+	for _, term := range terms {
+		for _, altLabel := range term.AltLabels {
+			if strings.EqualFold(altLabel, value) {
+				return term
+			}
+		}
+	}
+	return nil
+}
+
+// resolveVocabularyValue gör om ett värde till termens kod. Meddelandet är tomt om värdet är en term.
+func resolveVocabularyValue(db sqlQueryer, vocabularyID string, value string) (string, string, error) {
+	vocabulary, err := getVocabulary(db, vocabularyID)
+	if err != nil {
+		return "", "", err
+	}
+	terms, err := loadVocabularyTerms(db, "vocabulary_id = ?", vocabularyID)
+	if err != nil {
+		return "", "", err
+	}
+	term := matchVocabularyTerm(terms, value)
+	if term == nil {
+		return "", fmt.Sprintf("%q is not a term in vocabulary %s", value, vocabulary.Name), nil
+	}
+	return term.Code, "", nil
+}
+
+// checkNodeTypeFieldVocabularies kontrollerar fält för en nodtyp som är kopplade till en vokabulär
+// och ersätter värdena med termernas koder
+func checkNodeTypeFieldVocabularies(db sqlQueryer, nodeType model.NodeType, values map[string]string) error {
+	bindings, err := loadNodeTypeFieldVocabularies(db, "node_type = ?", nodeType)
+	if err != nil {
+		return err
+	}
+	for _, binding := range bindings {
+		value, ok := values[binding.Field]
+		if !ok || value == "" {
+			continue
+		}
+		code, message, err := resolveVocabularyValue(db, binding.Vocabulary.ID, value)
+		if err != nil {
+			return err
+		}
+		if message != "" {
+			return fmt.Errorf("field %s for %s: %s", binding.Field, nodeType, message)
+		}
+		values[binding.Field] = code
+	}
+	return nil
+}
+
+// loadNodeTypeFieldVocabularies hämtar kopplingar mellan nodtypers fält och vokabulärer
+func loadNodeTypeFieldVocabularies(db sqlQueryer, where string, args ...interface{}) ([]*model.NodeTypeFieldVocabulary, error) {
+	rows, err := db.Query("SELECT node_type, field_name, vocabulary_id FROM node_type_field_vocabularies WHERE "+where+" ORDER BY node_type, field_name", args...)
+	if err != nil {
+		log.Printf("Error fetching node type field vocabularies: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to fetch node type field vocabularies: %v", err)
+	}
+
+	type binding struct {
+		nodeType, field, vocabularyID string
+	}
+	var found []binding
+	for rows.Next() {
+		var b binding
+		if err := rows.Scan(&b.nodeType, &b.field, &b.vocabularyID); err != nil {
+			rows.Close()
+			log.Printf("Error scanning node type field vocabulary: %v", err)
+			return nil, serviceErrorf(errInternal, "failed to read node type field vocabularies: %v", err)
+		}
+		found = append(found, b)
+	}
+	rows.Close()
+
+	bindings := []*model.NodeTypeFieldVocabulary{}
+	for _, b := range found {
+		vocabulary, err := getVocabulary(db, b.vocabularyID)
+		if err != nil {
+			return nil, err
+		}
+		bindings = append(bindings, &model.NodeTypeFieldVocabulary{
+			NodeType:   model.NodeType(b.nodeType),
+			Field:      b.field,
+			Vocabulary: vocabulary,
+		})
+	}
+	return bindings, nil
+}
+
+// =============================================
+// ========== TERMER =========================
+// =============================================
+
+// vocabularyTermValues är en terms värden efter kontroll, som de sparas
+type vocabularyTermValues struct {
+	Code        string
+	Label       string
+	AltLabels   []string
+	Description *string
+	URI         *string
+	ParentCode  string // Används vid import, där föräldern anges med kod
+	ParentURI   string // Förälder i en SKOS-fil som inte finns i filen, söks bland befintliga termer
+}
+
+// termValuesFromInput kontrollerar indata för en term. Utan kod används namnet som kod.
+func termValuesFromInput(input model.VocabularyTermInput) (*vocabularyTermValues, error) {
+	values := &vocabularyTermValues{
+		Label:       strings.TrimSpace(input.Label),
+		Description: input.Description,
+		URI:         input.URI,
+	}
+	if input.Code != nil {
+		values.Code = strings.TrimSpace(*input.Code)
+	}
+	for _, altLabel := range input.AltLabels {
+		if altLabel = strings.TrimSpace(altLabel); altLabel != "" {
+			values.AltLabels = append(values.AltLabels, altLabel)
+		}
+	}
+	return values, values.normalize()
+}
+
+// normalize sätter koden till namnet om den saknas och kontrollerar att termen har ett namn
+func (v *vocabularyTermValues) normalize() error {
+	if v.Label == "" {
+		return fmt.Errorf("vocabulary term label is required")
+	}
+	if v.Code == "" {
+		v.Code = v.Label
+	}
+	return nil
+}
+
+// altLabelsJSON ger de alternativa namnen som de sparas i alt_labels
+func (v *vocabularyTermValues) altLabelsJSON() (interface{}, error) {
+	if len(v.AltLabels) == 0 {
+		return nil, nil
+	}
+	data, err := json.Marshal(v.AltLabels)
+	if err != nil {
+		return nil, serviceErrorf(errInternal, "failed to serialize alternative labels: %v", err)
+	}
+	return string(data), nil
+}
+
+// checkTermCodeAvailable kontrollerar att ingen annan term i vokabulären har samma kod, oavsett versaler
+func checkTermCodeAvailable(db sqlQueryer, vocabularyID string, code string, termID string) error {
+	terms, err := loadVocabularyTerms(db, "vocabulary_id = ? AND id != ?", vocabularyID, termID)
+	if err != nil {
+		return err
+	}
+	for _, term := range terms {
+		if strings.EqualFold(term.Code, code) {
+			return fmt.Errorf("vocabulary already has a term with code %s", term.Code)
+		}
+	}
+	return nil
+}
+
+// checkTermParent kontrollerar att föräldern finns i samma vokabulär, att vokabulären är ett
+// klassifikationsschema och att termen inte blir sin egen förfader
+func checkTermParent(db sqlQueryer, vocabulary *model.Vocabulary, termID string, parentID string) error {
+	if vocabulary.Kind != model.VocabularyKindClassification {
+		return fmt.Errorf("vocabulary %s is a flat list; only classification schemes can have parent terms", vocabulary.Name)
+	}
+
+	visited := make(map[string]bool)
+	for currentID := parentID; currentID != ""; {
+		if currentID == termID || visited[currentID] {
+			return fmt.Errorf("a vocabulary term cannot be placed under itself")
+		}
+		visited[currentID] = true
+
+		term, err := getVocabularyTerm(db, currentID)
+		if err != nil {
+			return err
+		}
+		if term.VocabularyID != vocabulary.ID {
+			return fmt.Errorf("parent term belongs to another vocabulary")
+		}
+		if term.ParentID == nil {
+			break
+		}
+		currentID = *term.ParentID
+	}
+	return nil
+}
+
+// =============================================
+// ========== IMPORT FRÅN SKOS OCH CSV =======
+// =============================================
+
+// skosLiteral är ett språkmärkt värde, t.ex. skos:prefLabel
+type skosLiteral struct {
+	Lang  string `xml:"http://www.w3.org/XML/1998/namespace lang,attr"`
+	Value string `xml:",chardata"`
+}
+
+// skosResource är en referens till en annan resurs, t.ex. skos:broader
+type skosResource struct {
+	Resource string `xml:"http://www.w3.org/1999/02/22-rdf-syntax-ns# resource,attr"`
+}
+
+// skosConcept är ett skos:Concept, eller en rdf:Description med typen skos:Concept
+type skosConcept struct {
+	About      string         `xml:"http://www.w3.org/1999/02/22-rdf-syntax-ns# about,attr"`
+	Types      []skosResource `xml:"http://www.w3.org/1999/02/22-rdf-syntax-ns# type"`
+	PrefLabels []skosLiteral  `xml:"http://www.w3.org/2004/02/skos/core# prefLabel"`
+	AltLabels  []skosLiteral  `xml:"http://www.w3.org/2004/02/skos/core# altLabel"`
+	Notations  []string       `xml:"http://www.w3.org/2004/02/skos/core# notation"`
+	Definition []skosLiteral  `xml:"http://www.w3.org/2004/02/skos/core# definition"`
+	Broader    []skosResource `xml:"http://www.w3.org/2004/02/skos/core# broader"`
+}
+
+// preferredSKOSLabel väljer namnet på svenska eller utan språk, annars det första.
+// Övriga namn returneras så att de kan sparas som alternativa namn.
+func preferredSKOSLabel(labels []skosLiteral) (string, []string) {
+	preferred := -1
+	for i, label := range labels {
+		lang := strings.ToLower(label.Lang)
+		if lang == "sv" || strings.HasPrefix(lang, "sv-") {
+			preferred = i
+			break
+		}
+		if lang == "" && preferred < 0 {
+			preferred = i
+		}
+	}
+	if preferred < 0 && len(labels) > 0 {
+		preferred = 0
+	}
+
+	var label string
+	var others []string
+	for i, literal := range labels {
+		value := strings.TrimSpace(literal.Value)
+		if i == preferred {
+			label = value
+		} else if value != "" {
+			others = append(others, value)
+		}
+	}
+	return label, others
+}
+
+// parseSKOS läser begrepp ur en SKOS-fil i RDF/XML. Koden tas från skos:notation, annars från namnet,
+// och föräldern från skos:broader.
+func parseSKOS(data string) ([]*vocabularyTermValues, error) {
+	decoder := xml.NewDecoder(strings.NewReader(data))
+
+	var concepts []*skosConcept
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("invalid SKOS file: %v", err)
+		}
+
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		isConcept := start.Name.Space == skosNamespace && start.Name.Local == "Concept"
+		isDescription := start.Name.Space == rdfNamespace && start.Name.Local == "Description"
+		if !isConcept && !isDescription {
+			continue
+		}
+
+		var concept skosConcept
+		if err := decoder.DecodeElement(&concept, &start); err != nil {
+			return nil, fmt.Errorf("invalid SKOS concept: %v", err)
+		}
+		if isDescription {
+			typed := false
+			for _, t := range concept.Types {
+				typed = typed || t.Resource == skosNamespace+"Concept"
+			}
+			if !typed {
+				continue
+			}
+		}
+		concepts = append(concepts, &concept)
+	}
+
+	// Föräldrar anges med URI i filen men med kod i importen
+	codes := make(map[string]string)
+	terms := make([]*vocabularyTermValues, 0, len(concepts))
+	for _, concept := range concepts {
+		label, otherLabels := preferredSKOSLabel(concept.PrefLabels)
+		term := &vocabularyTermValues{Label: label, AltLabels: otherLabels}
+		for _, notation := range concept.Notations {
+			if notation = strings.TrimSpace(notation); notation != "" {
+				term.Code = notation
+				break
+			}
+		}
+		for _, altLabel := range concept.AltLabels {
+			if value := strings.TrimSpace(altLabel.Value); value != "" {
+				term.AltLabels = append(term.AltLabels, value)
+			}
+		}
+		if definition, _ := preferredSKOSLabel(concept.Definition); definition != "" {
+			term.Description = &definition
+		}
+		if concept.About != "" {
+			uri := concept.About
+			term.URI = &uri
+		}
+		if err := term.normalize(); err != nil {
+			return nil, fmt.Errorf("SKOS concept %s: %v", concept.About, err)
+		}
+		codes[concept.About] = term.Code
+		terms = append(terms, term)
+	}
+
+	for i, concept := range concepts {
+		if len(concept.Broader) == 0 {
+			continue
+		}
+		broader := concept.Broader[0].Resource
+		if code, ok := codes[broader]; ok {
+			terms[i].ParentCode = code
+		} else {
+			terms[i].ParentURI = broader
+		}
+	}
+
+	if len(terms) == 0 {
+		return nil, fmt.Errorf("SKOS file contains no concepts")
+	}
+	return terms, nil
+}
+
+// newCSVReader läser CSV med komma eller semikolon som avgränsare, beroende på vilket som
+// förekommer oftast i rubrikraden. Ett inledande byte order mark tas bort.
+func newCSVReader(data string) *csv.Reader {
+	data = strings.TrimPrefix(data, "\ufeff")
+	reader := csv.NewReader(strings.NewReader(data))
+	if header, _, _ := strings.Cut(data, "\n"); strings.Count(header, ";") > strings.Count(header, ",") {
+		reader.Comma = ';'
+	}
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	return reader
+}
+
+// parseVocabularyCSV läser termer ur en CSV-fil med rubrikrad. Kolumnen label krävs; code, parent
+// (förälderns kod), altLabels (separerade med |), description och uri är valfria. Semikolon
+// godtas som avgränsare.
+func parseVocabularyCSV(data string) ([]*vocabularyTermValues, error) {
+	records, err := newCSVReader(data).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV file: %v", err)
+	}
+	if len(records) < 2 {
+		return nil, fmt.Errorf("CSV file must have a header row and at least one term")
+	}
+
+	columns := make(map[string]int)
+	for i, name := range records[0] {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := columns["label"]; !ok {
+		return nil, fmt.Errorf("CSV file must have a label column")
+	}
+	column := func(record []string, name string) string {
+		if i, ok := columns[strings.ToLower(name)]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	var terms []*vocabularyTermValues
+	for line, record := range records[1:] {
+		if len(record) == 1 && strings.TrimSpace(record[0]) == "" {
+			continue
+		}
+		term := &vocabularyTermValues{
+			Code:       column(record, "code"),
+			Label:      column(record, "label"),
+			ParentCode: column(record, "parent"),
+		}
+		for _, altLabel := range strings.Split(column(record, "altLabels"), "|") {
+			if altLabel = strings.TrimSpace(altLabel); altLabel != "" {
+				term.AltLabels = append(term.AltLabels, altLabel)
+			}
+		}
+		if description := column(record, "description"); description != "" {
+			term.Description = &description
+		}
+		if uri := column(record, "uri"); uri != "" {
+			term.URI = &uri
+		}
+		if err := term.normalize(); err != nil {
+			return nil, fmt.Errorf("line %d: %v", line+2, err)
+		}
+		terms = append(terms, term)
+	}
+	return terms, nil
+}
+
+// importVocabularyTerms sparar importerade termer. Termer med en kod som redan finns uppdateras,
+// övriga skapas. Med replace tas vokabulärens befintliga termer bort först.
+func importVocabularyTerms(tx *sql.Tx, vocabulary *model.Vocabulary, terms []*vocabularyTermValues, replace bool) (int, int, error) {
+	seen := make(map[string]bool)
+	for _, term := range terms {
+		key := strings.ToLower(term.Code)
+		if seen[key] {
+			return 0, 0, fmt.Errorf("code %s occurs more than once in the import", term.Code)
+		}
+		seen[key] = true
+		if (term.ParentCode != "" || term.ParentURI != "") && vocabulary.Kind != model.VocabularyKindClassification {
+			return 0, 0, fmt.Errorf("vocabulary %s is a flat list; import hierarchical terms into a classification scheme", vocabulary.Name)
+		}
+	}
+
+	if replace {
+		if _, err := tx.Exec("DELETE FROM vocabulary_terms WHERE vocabulary_id = ?", vocabulary.ID); err != nil {
+			log.Printf("Error deleting terms of vocabulary %s: %v", vocabulary.ID, err)
+			return 0, 0, serviceErrorf(errInternal, "failed to replace vocabulary terms: %v", err)
+		}
+	}
+
+	existing, err := loadVocabularyTerms(tx, "vocabulary_id = ?", vocabulary.ID)
+	if err != nil {
+		return 0, 0, err
+	}
+	ids := make(map[string]string)
+	uris := make(map[string]string)
+	for _, term := range existing {
+		ids[strings.ToLower(term.Code)] = term.ID
+		if term.URI != nil {
+			uris[*term.URI] = term.ID
+		}
+	}
+
+	created, updated := 0, 0
+	for _, term := range terms {
+		altLabels, err := term.altLabelsJSON()
+		if err != nil {
+			return 0, 0, err
+		}
+
+		if id, ok := ids[strings.ToLower(term.Code)]; ok {
+			_, err = tx.Exec(`
+				UPDATE vocabulary_terms SET code = ?, label = ?, alt_labels = ?, description = ?, uri = ?
+				WHERE id = ?
+			`, term.Code, term.Label, altLabels, term.Description, term.URI, id)
+			updated++
+		} else {
+			var result sql.Result
+			result, err = tx.Exec(`
+				INSERT INTO vocabulary_terms (vocabulary_id, code, label, alt_labels, description, uri)
+				VALUES (?, ?, ?, ?, ?, ?)
+			`, vocabulary.ID, term.Code, term.Label, altLabels, term.Description, term.URI)
+			if err == nil {
+				var id int64
+				id, err = result.LastInsertId()
+				ids[strings.ToLower(term.Code)] = fmt.Sprintf("%d", id)
+			}
+			created++
+		}
+		if err != nil {
+			log.Printf("Error saving term %s of vocabulary %s: %v", term.Code, vocabulary.ID, err)
+			return 0, 0, serviceErrorf(errInternal, "failed to save vocabulary term %s: %v", term.Code, err)
+		}
+	}
+
+	// Föräldrar sätts när alla termer finns, eftersom en term kan stå före sin förälder i filen
+	for _, term := range terms {
+		var parentID interface{}
+		if term.ParentCode != "" {
+			id, ok := ids[strings.ToLower(term.ParentCode)]
+			if !ok {
+				return 0, 0, serviceErrorf(errNotFound, "parent %s of term %s not found", term.ParentCode, term.Code)
+			}
+			parentID = id
+		} else if term.ParentURI != "" {
+			id, ok := uris[term.ParentURI]
+			if !ok {
+				return 0, 0, serviceErrorf(errNotFound, "parent %s of term %s not found", term.ParentURI, term.Code)
+			}
+			parentID = id
+		}
+		if _, err := tx.Exec("UPDATE vocabulary_terms SET parent_id = ? WHERE id = ?", parentID, ids[strings.ToLower(term.Code)]); err != nil {
+			log.Printf("Error setting parent of term %s: %v", term.Code, err)
+			return 0, 0, serviceErrorf(errInternal, "failed to save vocabulary term %s: %v", term.Code, err)
+		}
+	}
+
+	for _, term := range terms {
+		id := ids[strings.ToLower(term.Code)]
+		saved, err := getVocabularyTerm(tx, id)
+		if err != nil {
+			return 0, 0, err
+		}
+		if saved.ParentID != nil {
+			if err := checkTermParent(tx, vocabulary, id, *saved.ParentID); err != nil {
+				return 0, 0, fmt.Errorf("term %s: %v", term.Code, err)
+			}
+		}
+	}
+
+	return created, updated, nil
+}
+
+// looksLikeXML används för att ge ett tydligt fel när en CSV-fil skickas som SKOS eller tvärtom
+func looksLikeXML(data string) bool {
+	return strings.HasPrefix(strings.TrimSpace(strings.TrimPrefix(data, "\ufeff")), "<")
+}
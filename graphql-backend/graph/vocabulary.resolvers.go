@@ -0,0 +1,531 @@
+package graph
+
+// This file will be automatically regenerated based on the schema, any resolver implementations
+// will be copied through when generating and any unknown code will be moved to the end.
+// Code generated by github.com/99designs/gqlgen version v0.17.69
+
+import (
+	"context"
+	"fmt"
+	"graphql-backend/graph/model"
+	"log"
+	"strings"
+	"time"
+)
+
+// Vocabulary is the resolver for the vocabulary field.
+func (r *metadataSchemaFieldResolver) Vocabulary(ctx context.Context, obj *model.MetadataSchemaField) (*model.Vocabulary, error) {
+	if obj.VocabularyID == nil || r.DB == nil {
+		return nil, nil
+	}
+	return getVocabulary(r.DB, *obj.VocabularyID)
+}
+
+// CreateVocabulary is the resolver for the createVocabulary field.
+func (r *mutationResolver) CreateVocabulary(ctx context.Context, input model.VocabularyInput) (*model.Vocabulary, error) {
+	logAction(fmt.Sprintf("Creating vocabulary %s", input.Name))
+
+	if r.DB == nil {
+		log.Printf("Database connection is nil")
+		return nil, serviceErrorf(errInternal, "internal server error: database connection is not initialized")
+	}
+
+	if _, err := requireAdministrator(ctx, r.DB, "manage vocabularies"); err != nil {
+		return nil, err
+	}
+
+	name := strings.TrimSpace(input.Name)
+	if name == "" {
+		return nil, fmt.Errorf("vocabulary name is required")
+	}
+	kind := model.VocabularyKindVocabulary
+	if input.Kind != nil {
+		kind = *input.Kind
+	}
+
+	now := time.Now().Format(time.RFC3339)
+	result, err := r.DB.Exec(`
+		INSERT INTO vocabularies (name, description, kind, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?)
+	`, name, input.Description, kind, now, now)
+	if err != nil {
+		log.Printf("Error creating vocabulary: %v", err)
+		if isUniqueViolation(err) {
+			return nil, fmt.Errorf("a vocabulary named %s already exists", name)
+		}
+		return nil, serviceErrorf(errInternal, "failed to create vocabulary: %v", err)
+	}
+
+	vocabularyID, err := result.LastInsertId()
+	if err != nil {
+		log.Printf("Error retrieving last insert ID: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to retrieve vocabulary ID: %v", err)
+	}
+
+	log.Printf("Vocabulary %s created with ID %d", name, vocabularyID)
+	return getVocabulary(r.DB, fmt.Sprintf("%d", vocabularyID))
+}
+
+// UpdateVocabulary is the resolver for the updateVocabulary field.
+func (r *mutationResolver) UpdateVocabulary(ctx context.Context, id string, input model.VocabularyInput) (*model.Vocabulary, error) {
+	logAction(fmt.Sprintf("Updating vocabulary %s", id))
+
+	if r.DB == nil {
+		log.Printf("Database connection is nil")
+		return nil, serviceErrorf(errInternal, "internal server error: database connection is not initialized")
+	}
+
+	if _, err := requireAdministrator(ctx, r.DB, "manage vocabularies"); err != nil {
+		return nil, err
+	}
+
+	vocabulary, err := getVocabulary(r.DB, id)
+	if err != nil {
+		return nil, err
+	}
+	name := strings.TrimSpace(input.Name)
+	if name == "" {
+		return nil, fmt.Errorf("vocabulary name is required")
+	}
+	kind := vocabulary.Kind
+	if input.Kind != nil {
+		kind = *input.Kind
+	}
+
+	// En vokabulär med hierarki kan inte göras om till en platt lista
+	if kind == model.VocabularyKindVocabulary && vocabulary.Kind == model.VocabularyKindClassification {
+		var hierarchical bool
+		err := r.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM vocabulary_terms WHERE vocabulary_id = ? AND parent_id IS NOT NULL)", id).Scan(&hierarchical)
+		if err != nil {
+			log.Printf("Error checking hierarchy of vocabulary %s: %v", id, err)
+			return nil, serviceErrorf(errInternal, "failed to check vocabulary terms: %v", err)
+		}
+		if hierarchical {
+			return nil, serviceErrorf(errConflict, "cannot make vocabulary %s a flat list: it has terms with parent terms", vocabulary.Name)
+		}
+	}
+
+	_, err = r.DB.Exec(`
+		UPDATE vocabularies SET name = ?, description = ?, kind = ?, updated_at = ?
+		WHERE id = ?
+	`, name, input.Description, kind, time.Now().Format(time.RFC3339), id)
+	if err != nil {
+		log.Printf("Error updating vocabulary %s: %v", id, err)
+		if isUniqueViolation(err) {
+			return nil, fmt.Errorf("a vocabulary named %s already exists", name)
+		}
+		return nil, serviceErrorf(errInternal, "failed to update vocabulary: %v", err)
+	}
+
+	return getVocabulary(r.DB, id)
+}
+
+// DeleteVocabulary is the resolver for the deleteVocabulary field.
+func (r *mutationResolver) DeleteVocabulary(ctx context.Context, id string) (bool, error) {
+	logAction(fmt.Sprintf("Deleting vocabulary %s", id))
+
+	if r.DB == nil {
+		log.Printf("Database connection is nil")
+		return false, serviceErrorf(errInternal, "internal server error: database connection is not initialized")
+	}
+
+	if _, err := requireAdministrator(ctx, r.DB, "manage vocabularies"); err != nil {
+		return false, err
+	}
+
+	// En vokabulär som används av ett schemafält eller en nodtyp kan inte tas bort
+	var inUse bool
+	err := r.DB.QueryRow(`
+		SELECT EXISTS(SELECT 1 FROM metadata_schema_fields WHERE vocabulary_id = ?)
+			OR EXISTS(SELECT 1 FROM node_type_field_vocabularies WHERE vocabulary_id = ?)
+	`, id, id).Scan(&inUse)
+	if err != nil {
+		log.Printf("Error checking if vocabulary %s is in use: %v", id, err)
+		return false, serviceErrorf(errInternal, "failed to check vocabulary: %v", err)
+	}
+	if inUse {
+		return false, serviceErrorf(errConflict, "cannot delete vocabulary: it is used by a metadata schema or node type")
+	}
+
+	tx, err := r.DB.Begin()
+	if err != nil {
+		log.Printf("Error starting transaction: %v", err)
+		return false, serviceErrorf(errInternal, "failed to start transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("DELETE FROM vocabularies WHERE id = ?", id)
+	if err != nil {
+		log.Printf("Error deleting vocabulary %s: %v", id, err)
+		return false, serviceErrorf(errInternal, "failed to delete vocabulary: %v", err)
+	}
+	if rowsAffected, err := result.RowsAffected(); err == nil && rowsAffected == 0 {
+		return false, serviceErrorf(errNotFound, "vocabulary not found")
+	}
+
+	if _, err := tx.Exec("DELETE FROM vocabulary_terms WHERE vocabulary_id = ?", id); err != nil {
+		log.Printf("Error deleting terms of vocabulary %s: %v", id, err)
+		return false, serviceErrorf(errInternal, "failed to delete vocabulary: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("Error committing transaction: %v", err)
+		return false, serviceErrorf(errInternal, "failed to commit transaction: %v", err)
+	}
+
+	log.Printf("Vocabulary %s deleted", id)
+	return true, nil
+}
+
+// CreateVocabularyTerm is the resolver for the createVocabularyTerm field.
+func (r *mutationResolver) CreateVocabularyTerm(ctx context.Context, vocabularyID string, input model.VocabularyTermInput) (*model.VocabularyTerm, error) {
+	logAction(fmt.Sprintf("Creating term %s in vocabulary %s", input.Label, vocabularyID))
+
+	if r.DB == nil {
+		log.Printf("Database connection is nil")
+		return nil, serviceErrorf(errInternal, "internal server error: database connection is not initialized")
+	}
+
+	if _, err := requireAdministrator(ctx, r.DB, "manage vocabularies"); err != nil {
+		return nil, err
+	}
+
+	vocabulary, err := getVocabulary(r.DB, vocabularyID)
+	if err != nil {
+		return nil, err
+	}
+	values, err := termValuesFromInput(input)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkTermCodeAvailable(r.DB, vocabularyID, values.Code, ""); err != nil {
+		return nil, err
+	}
+	if input.ParentID != nil {
+		if err := checkTermParent(r.DB, vocabulary, "", *input.ParentID); err != nil {
+			return nil, err
+		}
+	}
+	altLabels, err := values.altLabelsJSON()
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := r.DB.Exec(`
+		INSERT INTO vocabulary_terms (vocabulary_id, code, label, alt_labels, parent_id, description, uri)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, vocabularyID, values.Code, values.Label, altLabels, input.ParentID, values.Description, values.URI)
+	if err != nil {
+		log.Printf("Error creating vocabulary term: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to create vocabulary term: %v", err)
+	}
+
+	termID, err := result.LastInsertId()
+	if err != nil {
+		log.Printf("Error retrieving last insert ID: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to retrieve vocabulary term ID: %v", err)
+	}
+
+	return getVocabularyTerm(r.DB, fmt.Sprintf("%d", termID))
+}
+
+// UpdateVocabularyTerm is the resolver for the updateVocabularyTerm field.
+func (r *mutationResolver) UpdateVocabularyTerm(ctx context.Context, id string, input model.VocabularyTermInput) (*model.VocabularyTerm, error) {
+	logAction(fmt.Sprintf("Updating vocabulary term %s", id))
+
+	if r.DB == nil {
+		log.Printf("Database connection is nil")
+		return nil, serviceErrorf(errInternal, "internal server error: database connection is not initialized")
+	}
+
+	if _, err := requireAdministrator(ctx, r.DB, "manage vocabularies"); err != nil {
+		return nil, err
+	}
+
+	term, err := getVocabularyTerm(r.DB, id)
+	if err != nil {
+		return nil, err
+	}
+	vocabulary, err := getVocabulary(r.DB, term.VocabularyID)
+	if err != nil {
+		return nil, err
+	}
+	values, err := termValuesFromInput(input)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkTermCodeAvailable(r.DB, term.VocabularyID, values.Code, id); err != nil {
+		return nil, err
+	}
+	if input.ParentID != nil {
+		if err := checkTermParent(r.DB, vocabulary, id, *input.ParentID); err != nil {
+			return nil, err
+		}
+	}
+	altLabels, err := values.altLabelsJSON()
+	if err != nil {
+		return nil, err
+	}
+
+	// Värden som redan sparats med den gamla koden ändras inte
+	_, err = r.DB.Exec(`
+		UPDATE vocabulary_terms SET code = ?, label = ?, alt_labels = ?, parent_id = ?, description = ?, uri = ?
+		WHERE id = ?
+	`, values.Code, values.Label, altLabels, input.ParentID, values.Description, values.URI, id)
+	if err != nil {
+		log.Printf("Error updating vocabulary term %s: %v", id, err)
+		return nil, serviceErrorf(errInternal, "failed to update vocabulary term: %v", err)
+	}
+
+	return getVocabularyTerm(r.DB, id)
+}
+
+// DeleteVocabularyTerm is the resolver for the deleteVocabularyTerm field.
+func (r *mutationResolver) DeleteVocabularyTerm(ctx context.Context, id string) (bool, error) {
+	logAction(fmt.Sprintf("Deleting vocabulary term %s", id))
+
+	if r.DB == nil {
+		log.Printf("Database connection is nil")
+		return false, serviceErrorf(errInternal, "internal server error: database connection is not initialized")
+	}
+
+	if _, err := requireAdministrator(ctx, r.DB, "manage vocabularies"); err != nil {
+		return false, err
+	}
+
+	var hasChildren bool
+	if err := r.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM vocabulary_terms WHERE parent_id = ?)", id).Scan(&hasChildren); err != nil {
+		log.Printf("Error checking children of vocabulary term %s: %v", id, err)
+		return false, serviceErrorf(errInternal, "failed to check vocabulary term: %v", err)
+	}
+	if hasChildren {
+		return false, serviceErrorf(errConflict, "cannot delete vocabulary term: it has child terms")
+	}
+
+	result, err := r.DB.Exec("DELETE FROM vocabulary_terms WHERE id = ?", id)
+	if err != nil {
+		log.Printf("Error deleting vocabulary term %s: %v", id, err)
+		return false, serviceErrorf(errInternal, "failed to delete vocabulary term: %v", err)
+	}
+	if rowsAffected, err := result.RowsAffected(); err == nil && rowsAffected == 0 {
+		return false, serviceErrorf(errNotFound, "vocabulary term not found")
+	}
+	return true, nil
+}
+
+// ImportVocabulary is the resolver for the importVocabulary field.
+func (r *mutationResolver) ImportVocabulary(ctx context.Context, vocabularyID string, format model.VocabularyImportFormat, data string, replace *bool) (*model.VocabularyImportResult, error) {
+	logAction(fmt.Sprintf("Importing %s into vocabulary %s", format, vocabularyID))
+
+	if r.DB == nil {
+		log.Printf("Database connection is nil")
+		return nil, serviceErrorf(errInternal, "internal server error: database connection is not initialized")
+	}
+
+	if _, err := requireAdministrator(ctx, r.DB, "manage vocabularies"); err != nil {
+		return nil, err
+	}
+
+	vocabulary, err := getVocabulary(r.DB, vocabularyID)
+	if err != nil {
+		return nil, err
+	}
+
+	var terms []*vocabularyTermValues
+	switch format {
+	case model.VocabularyImportFormatSkos:
+		if !looksLikeXML(data) {
+			return nil, fmt.Errorf("SKOS import expects RDF/XML")
+		}
+		terms, err = parseSKOS(data)
+	case model.VocabularyImportFormatCSV:
+		if looksLikeXML(data) {
+			return nil, fmt.Errorf("CSV import got XML; use format SKOS for RDF/XML files")
+		}
+		terms, err = parseVocabularyCSV(data)
+	default:
+		err = fmt.Errorf("unknown import format %s", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := r.DB.Begin()
+	if err != nil {
+		log.Printf("Error starting transaction: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to start transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	created, updated, err := importVocabularyTerms(tx, vocabulary, terms, replace != nil && *replace)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec("UPDATE vocabularies SET updated_at = ? WHERE id = ?", time.Now().Format(time.RFC3339), vocabularyID); err != nil {
+		log.Printf("Error updating vocabulary %s: %v", vocabularyID, err)
+		return nil, serviceErrorf(errInternal, "failed to update vocabulary: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("Error committing transaction: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to commit transaction: %v", err)
+	}
+
+	log.Printf("Imported %d new and %d updated terms into vocabulary %s", created, updated, vocabularyID)
+	vocabulary, err = getVocabulary(r.DB, vocabularyID)
+	if err != nil {
+		return nil, err
+	}
+	return &model.VocabularyImportResult{Vocabulary: vocabulary, Created: created, Updated: updated}, nil
+}
+
+// SetNodeTypeFieldVocabulary is the resolver for the setNodeTypeFieldVocabulary field.
+func (r *mutationResolver) SetNodeTypeFieldVocabulary(ctx context.Context, nodeType model.NodeType, field string, vocabularyID *string) ([]*model.NodeTypeFieldVocabulary, error) {
+	logAction(fmt.Sprintf("Setting vocabulary of field %s for node type %s", field, nodeType))
+
+	if r.DB == nil {
+		log.Printf("Database connection is nil")
+		return nil, serviceErrorf(errInternal, "internal server error: database connection is not initialized")
+	}
+
+	if _, err := requireAdministrator(ctx, r.DB, "manage vocabularies"); err != nil {
+		return nil, err
+	}
+
+	// Bara textfält utan fast kodlista kan kopplas till en vokabulär
+	var def *typedFieldDef
+	for i := range nodeTypeFields[nodeType] {
+		if nodeTypeFields[nodeType][i].name == field {
+			def = &nodeTypeFields[nodeType][i]
+		}
+	}
+	if def == nil {
+		return nil, fmt.Errorf("node type %s has no field %s", nodeType, field)
+	}
+	if def.kind != fieldKindString || len(def.allowed) > 0 {
+		return nil, fmt.Errorf("field %s for %s has a fixed type or code list and cannot use a vocabulary", field, nodeType)
+	}
+
+	if vocabularyID == nil {
+		_, err := r.DB.Exec("DELETE FROM node_type_field_vocabularies WHERE node_type = ? AND field_name = ?", nodeType, field)
+		if err != nil {
+			log.Printf("Error removing vocabulary of field %s for %s: %v", field, nodeType, err)
+			return nil, serviceErrorf(errInternal, "failed to remove field vocabulary: %v", err)
+		}
+	} else {
+		if _, err := getVocabulary(r.DB, *vocabularyID); err != nil {
+			return nil, err
+		}
+		_, err := r.DB.Exec("INSERT INTO node_type_field_vocabularies (node_type, field_name, vocabulary_id) VALUES (?, ?, ?) ON CONFLICT(node_type, field_name) DO UPDATE SET vocabulary_id = excluded.vocabulary_id",
+			nodeType, field, *vocabularyID)
+		if err != nil {
+			log.Printf("Error setting vocabulary of field %s for %s: %v", field, nodeType, err)
+			return nil, serviceErrorf(errInternal, "failed to set field vocabulary: %v", err)
+		}
+	}
+
+	return loadNodeTypeFieldVocabularies(r.DB, "1 = 1")
+}
+
+// Vocabularies is the resolver for the vocabularies field.
+func (r *queryResolver) Vocabularies(ctx context.Context) ([]*model.Vocabulary, error) {
+	if r.DB == nil {
+		return nil, serviceErrorf(errInternal, "internal server error: database connection is not initialized")
+	}
+
+	rows, err := r.DB.Query("SELECT id FROM vocabularies ORDER BY name ASC")
+	if err != nil {
+		log.Printf("Error fetching vocabularies: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to fetch vocabularies: %v", err)
+	}
+	vocabularyIDs, err := scanIDs(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	vocabularies := []*model.Vocabulary{}
+	for _, vocabularyID := range vocabularyIDs {
+		vocabulary, err := getVocabulary(r.DB, vocabularyID)
+		if err != nil {
+			return nil, err
+		}
+		vocabularies = append(vocabularies, vocabulary)
+	}
+	return vocabularies, nil
+}
+
+// Vocabulary is the resolver for the vocabulary field.
+func (r *queryResolver) Vocabulary(ctx context.Context, id string) (*model.Vocabulary, error) {
+	if r.DB == nil {
+		return nil, serviceErrorf(errInternal, "internal server error: database connection is not initialized")
+	}
+
+	return getVocabulary(r.DB, id)
+}
+
+// VocabularyTerms is the resolver for the vocabularyTerms field.
+func (r *queryResolver) VocabularyTerms(ctx context.Context, vocabularyID string, search *string) ([]*model.VocabularyTerm, error) {
+	if r.DB == nil {
+		return nil, serviceErrorf(errInternal, "internal server error: database connection is not initialized")
+	}
+
+	terms, err := loadVocabularyTerms(r.DB, "vocabulary_id = ?", vocabularyID)
+	if err != nil || search == nil || strings.TrimSpace(*search) == "" {
+		return terms, err
+	}
+
+	// Sökningen görs här och inte i SQL, eftersom SQLite bara skiljer på versaler för ASCII
+	query := strings.ToLower(strings.TrimSpace(*search))
+	matches := []*model.VocabularyTerm{}
+	for _, term := range terms {
+		found := strings.Contains(strings.ToLower(term.Code), query) || strings.Contains(strings.ToLower(term.Label), query)
+		for _, altLabel := range term.AltLabels {
+			found = found || strings.Contains(strings.ToLower(altLabel), query)
+		}
+		if found {
+			matches = append(matches, term)
+		}
+	}
+	return matches, nil
+}
+
+// NodeTypeFieldVocabularies is the resolver for the nodeTypeFieldVocabularies field.
+func (r *queryResolver) NodeTypeFieldVocabularies(ctx context.Context) ([]*model.NodeTypeFieldVocabulary, error) {
+	if r.DB == nil {
+		return nil, serviceErrorf(errInternal, "internal server error: database connection is not initialized")
+	}
+
+	return loadNodeTypeFieldVocabularies(r.DB, "1 = 1")
+}
+
+// Terms is the resolver for the terms field.
+func (r *vocabularyResolver) Terms(ctx context.Context, obj *model.Vocabulary) ([]*model.VocabularyTerm, error) {
+	return loadVocabularyTerms(r.DB, "vocabulary_id = ?", obj.ID)
+}
+
+// TopTerms is the resolver for the topTerms field.
+func (r *vocabularyResolver) TopTerms(ctx context.Context, obj *model.Vocabulary) ([]*model.VocabularyTerm, error) {
+	return loadVocabularyTerms(r.DB, "vocabulary_id = ? AND parent_id IS NULL", obj.ID)
+}
+
+// Parent is the resolver for the parent field.
+func (r *vocabularyTermResolver) Parent(ctx context.Context, obj *model.VocabularyTerm) (*model.VocabularyTerm, error) {
+	if obj.ParentID == nil {
+		return nil, nil
+	}
+	return getVocabularyTerm(r.DB, *obj.ParentID)
+}
+
+// Children is the resolver for the children field.
+func (r *vocabularyTermResolver) Children(ctx context.Context, obj *model.VocabularyTerm) ([]*model.VocabularyTerm, error) {
+	return loadVocabularyTerms(r.DB, "parent_id = ?", obj.ID)
+}
+
+// Vocabulary returns VocabularyResolver implementation.
+func (r *Resolver) Vocabulary() VocabularyResolver { return &vocabularyResolver{r} }
+
+// VocabularyTerm returns VocabularyTermResolver implementation.
+func (r *Resolver) VocabularyTerm() VocabularyTermResolver { return &vocabularyTermResolver{r} }
+
+type vocabularyResolver struct{ *Resolver }
+type vocabularyTermResolver struct{ *Resolver }
@@ -0,0 +1,187 @@
+package graph
+
+import (
+	"context"
+	"encoding/base64"
+	"graphql-backend/graph/model"
+	"strings"
+	"testing"
+)
+
+// createTestVocabulary skapar en vokabulär och importerar termer från CSV
+func createTestVocabulary(t *testing.T, ctx context.Context, mutation MutationResolver, name string, kind model.VocabularyKind, csv string) *model.Vocabulary {
+	t.Helper()
+	vocabulary, err := mutation.CreateVocabulary(ctx, model.VocabularyInput{Name: name, Kind: &kind})
+	if err != nil {
+		t.Fatalf("create vocabulary: %v", err)
+	}
+	if _, err := mutation.ImportVocabulary(ctx, vocabulary.ID, model.VocabularyImportFormatCSV, csv, nil); err != nil {
+		t.Fatalf("import vocabulary: %v", err)
+	}
+	return vocabulary
+}
+
+// termSummary skriver termer som "kod=namn<förälder" i ordning
+func termSummary(terms []*vocabularyTermValues) string {
+	parts := make([]string, len(terms))
+	for i, term := range terms {
+		parts[i] = term.Code + "=" + term.Label
+		if len(term.AltLabels) > 0 {
+			parts[i] += "|" + strings.Join(term.AltLabels, "|")
+		}
+		if term.ParentCode != "" {
+			parts[i] += "<" + term.ParentCode
+		}
+		if term.ParentURI != "" {
+			parts[i] += "<" + term.ParentURI
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+func TestParseSKOS(t *testing.T) {
+	data := `<?xml version="1.0" encoding="UTF-8"?>
+<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#" xmlns:skos="http://www.w3.org/2004/02/skos/core#">
+  <skos:Concept rdf:about="http://example.org/ks/1">
+    <skos:notation>1</skos:notation>
+    <skos:prefLabel xml:lang="en">Governance</skos:prefLabel>
+    <skos:prefLabel xml:lang="sv">Ledning och styrning</skos:prefLabel>
+  </skos:Concept>
+  <rdf:Description rdf:about="http://example.org/ks/1.1">
+    <rdf:type rdf:resource="http://www.w3.org/2004/02/skos/core#Concept"/>
+    <skos:notation>1.1</skos:notation>
+    <skos:prefLabel>Ekonomi</skos:prefLabel>
+    <skos:altLabel>ekonomistyrning</skos:altLabel>
+    <skos:definition xml:lang="sv">Budget och redovisning</skos:definition>
+    <skos:broader rdf:resource="http://example.org/ks/1"/>
+  </rdf:Description>
+  <rdf:Description rdf:about="http://example.org/annat">
+    <skos:prefLabel>Inte ett begrepp</skos:prefLabel>
+  </rdf:Description>
+  <skos:Concept rdf:about="http://example.org/ks/9">
+    <skos:prefLabel>Utan notation</skos:prefLabel>
+    <skos:broader rdf:resource="http://example.org/ks/0"/>
+  </skos:Concept>
+</rdf:RDF>`
+
+	terms, err := parseSKOS(data)
+	if err != nil {
+		t.Fatalf("parse SKOS: %v", err)
+	}
+	want := "1=Ledning och styrning|Governance 1.1=Ekonomi|ekonomistyrning<1 Utan notation=Utan notation<http://example.org/ks/0"
+	if got := termSummary(terms); got != want {
+		t.Errorf("terms:\n%s\nwant:\n%s", got, want)
+	}
+	if terms[1].Description == nil || *terms[1].Description != "Budget och redovisning" || terms[1].URI == nil || *terms[1].URI != "http://example.org/ks/1.1" {
+		t.Errorf("term 1.1 = %+v", terms[1])
+	}
+
+	if _, err := parseSKOS(`<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#"/>`); err == nil || err.Error() != "SKOS file contains no concepts" {
+		t.Errorf("empty SKOS: err = %v", err)
+	}
+}
+
+func TestParseVocabularyCSV(t *testing.T) {
+	terms, err := parseVocabularyCSV("\ufeffcode;label;parent;altLabels\n1;Ledning;;\n1.1;Ekonomi;1;economy | ekonomi\n\n;Personal;1;\n")
+	if err != nil {
+		t.Fatalf("parse CSV: %v", err)
+	}
+	if got := termSummary(terms); got != "1=Ledning 1.1=Ekonomi|economy|ekonomi<1 Personal=Personal<1" {
+		t.Errorf("terms = %s", got)
+	}
+
+	for data, want := range map[string]string{
+		"code,namn\n1,Ledning\n": "CSV file must have a label column",
+		"code,label\n":           "CSV file must have a header row and at least one term",
+		"code,label\n1,\n":       "line 2: vocabulary term label is required",
+	} {
+		if _, err := parseVocabularyCSV(data); err == nil || err.Error() != want {
+			t.Errorf("parse %q: err = %v, want %q", data, err, want)
+		}
+	}
+}
+
+func TestVocabulariesOnWrite(t *testing.T) {
+	db := openTestDB(t)
+	ctx := testAdminContext(t)
+	resolver := NewResolver(db)
+	mutation := resolver.Mutation()
+
+	scheme := createTestVocabulary(t, ctx, mutation, "Klassifikasjonssystem", model.VocabularyKindClassification,
+		"code,label,parent,altLabels\n1.1,Ekonomi,1,Economy\n1,Ledning och styrning,,\n2,Personal,,\n")
+	top, err := resolver.Vocabulary().TopTerms(ctx, scheme)
+	if err != nil || len(top) != 2 {
+		t.Fatalf("top terms = %v, %v", top, err)
+	}
+	children, err := resolver.VocabularyTerm().Children(ctx, top[0])
+	if err != nil || len(children) != 1 || children[0].Code != "1.1" {
+		t.Errorf("children of %s = %v, %v", top[0].Code, children, err)
+	}
+	// En term kan inte flyttas under sitt eget barn
+	if _, err := mutation.UpdateVocabularyTerm(ctx, top[0].ID, model.VocabularyTermInput{Code: strPtr("1"), Label: "Ledning", ParentID: &children[0].ID}); err == nil || err.Error() != "a vocabulary term cannot be placed under itself" {
+		t.Errorf("move term under its child: err = %v", err)
+	}
+	if _, err := mutation.DeleteVocabularyTerm(ctx, top[0].ID); err == nil || !strings.Contains(err.Error(), "it has child terms") {
+		t.Errorf("delete term with children: err = %v", err)
+	}
+
+	flat, err := mutation.CreateVocabulary(ctx, model.VocabularyInput{Name: "Ämnesord"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mutation.ImportVocabulary(ctx, flat.ID, model.VocabularyImportFormatCSV, "code,label,parent\n1,A,\n2,B,1\n", nil); err == nil || !strings.Contains(err.Error(), "is a flat list") {
+		t.Errorf("import hierarchy into flat vocabulary: err = %v", err)
+	}
+
+	schema, err := mutation.CreateMetadataSchema(ctx, model.MetadataSchemaInput{
+		Name:                "Klassificerat",
+		AllowAdditionalKeys: boolPtr(true),
+		Fields:              []*model.MetadataSchemaFieldInput{{Name: "klass", Type: model.MetadataFieldTypeString, VocabularyID: &scheme.ID}},
+	})
+	if err != nil {
+		t.Fatalf("create metadata schema: %v", err)
+	}
+	root := createTestNode(t, db, "Handlingar", nil)
+	if _, err := mutation.SetNodeMetadataSchema(ctx, root.ID, &schema.ID); err != nil {
+		t.Fatal(err)
+	}
+	save := func(value string) (*model.File, error) {
+		return mutation.SaveFile(ctx, model.FileInput{
+			Name: "budget.txt", Size: 1, ContentType: "text/plain", FileData: base64.StdEncoding.EncodeToString([]byte("x")), NodeID: &root.ID,
+			Metadata: []*model.MetadataInput{{Key: "klass", Value: value}},
+		})
+	}
+
+	// Alternativa namn och versaler godtas men värdet sparas som termens kod
+	file, err := save("economy")
+	if err != nil {
+		t.Fatalf("save with alternative label: %v", err)
+	}
+	if value, _ := fileMetadataValue(file, "klass"); value != "1.1" {
+		t.Errorf("klass = %q, want the code 1.1", value)
+	}
+	if _, err := save("Okänd"); err == nil || !strings.Contains(err.Error(), `"Okänd" is not a term in vocabulary Klassifikasjonssystem`) {
+		t.Errorf("save with unknown term: err = %v", err)
+	}
+
+	units := createTestVocabulary(t, ctx, mutation, "Enheter", model.VocabularyKindVocabulary, "code,label\nKAN,Kansli\nBYG,Byggavdelningen\n")
+	if _, err := mutation.SetNodeTypeFieldVocabulary(ctx, model.NodeTypeSaksmappe, "administrativEnhet", &units.ID); err != nil {
+		t.Fatalf("bind vocabulary to node type field: %v", err)
+	}
+	arkiv := insertTestNode(t, db, "Arkiv", "")
+	arkivdel := insertTestNode(t, db, "Saker", arkiv)
+	setTestNodeType(t, db, arkiv, model.NodeTypeArkiv, map[string]string{"arkivstatus": "Opprettet"})
+	setTestNodeType(t, db, arkivdel, model.NodeTypeArkivdel, map[string]string{"arkivdelstatus": "Aktiv periode"})
+	nodeType := model.NodeTypeSaksmappe
+	sak, err := mutation.CreateNode(ctx, model.NodeInput{Name: "Byggesak", ParentID: &arkivdel, NodeType: &nodeType, Fields: saksmappeFields()})
+	if err != nil {
+		t.Fatalf("create saksmappe with unit from vocabulary: %v", err)
+	}
+	if fields, _ := loadTypedFields(db, ENTITY_KIND_NODE, sak.ID); fields["administrativEnhet"] != "KAN" {
+		t.Errorf("administrativEnhet = %q, want the code KAN", fields["administrativEnhet"])
+	}
+	fields := append(saksmappeFields(), typedFields("administrativEnhet", "Ekonomi")...)
+	if _, err := mutation.CreateNode(ctx, model.NodeInput{Name: "Fel", ParentID: &arkivdel, NodeType: &nodeType, Fields: fields}); err == nil || !strings.Contains(err.Error(), "field administrativEnhet for SAKSMAPPE") {
+		t.Errorf("create saksmappe with unknown unit: err = %v", err)
+	}
+}
@@ -0,0 +1,1546 @@
+package graph
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"graphql-backend/graph/model"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// =============================================
+// ========== WEBDAV ==========================
+// =============================================
+
+// WebDAVPath är sökvägen där nodträdet och filerna finns tillgängliga över WebDAV
+const WebDAVPath = "/dav/"
+
+const (
+	davCredentialTTL = 5 * time.Minute // Hur länge ett godkänt användarnamn och lösenord återanvänds
+
+	davMaxFileSize    = restMaxBodySize / 4 * 3 // Största fil i PUT; base64-kodad blir den lika stor som största REST-kroppen
+	davMaxXMLBodySize = 1 << 20                 // Största XML-innehåll i PROPFIND och LOCK
+)
+
+var (
+	davLockDefaultTimeout = 10 * time.Minute // Lås utan begärd tidsgräns
+	davLockMaxTimeout     = time.Hour        // Längsta tid ett lås gäller innan det måste förnyas
+)
+
+// SetWebDAVLockTimeouts anger tidsgränsen för lås utan begärd tidsgräns och den längsta tid ett lås
+// gäller. Anropas en gång vid start.
+func SetWebDAVLockTimeouts(defaultTimeout time.Duration, maxTimeout time.Duration) {
+	davLockDefaultTimeout = defaultTimeout
+	davLockMaxTimeout = maxTimeout
+}
+
+// errDavBodyTooLarge returneras när anropets kropp är större än tillåtet
+var errDavBodyTooLarge = errors.New("request body is too large")
+
+// Metoderna som WebDAV-servern stöder
+const davAllowedMethods = "OPTIONS, PROPFIND, GET, HEAD, PUT, MKCOL, DELETE, MOVE, COPY, LOCK, UNLOCK"
+
+// davLockTokenPattern hittar låstoken i en If-header
+var davLockTokenPattern = regexp.MustCompile(`<(opaquelocktoken:[^>]+)>`)
+
+// davHandler hanterar WebDAV-anrop. Ändringar görs genom samma tjänster som GraphQL-anropen, och
+// går därför igenom samma kontroller, revisionslogg och ändringshändelser.
+type davHandler struct {
+	db       *sql.DB
+	services *Services
+	mutation MutationResolver
+	locks    *davLockManager
+
+	mu          sync.Mutex
+	credentials map[string]davCredential
+}
+
+// davCredential är en token för ett användarnamn och lösenord som nyligen godkänts, så att bcrypt
+// inte behöver köras för varje anrop från en filhanterare
+type davCredential struct {
+	token   string
+	expires time.Time
+}
+
+// WebDAVHandler visar noderna som kataloger och filerna som filer under WebDAVPath. Anroparen
+// autentiseras med JWT i Authorization-headern eller med användarnamn och lösenord (Basic).
+func WebDAVHandler(db *sql.DB) http.Handler {
+	resolver := NewResolver(db)
+	return &davHandler{
+		db:          db,
+		services:    resolver.Services,
+		mutation:    resolver.Mutation(),
+		locks:       &davLockManager{locks: make(map[string]*davLock)},
+		credentials: make(map[string]davCredential),
+	}
+}
+
+func (h *davHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	logAction(fmt.Sprintf("WebDAV %s %s", r.Method, r.URL.Path))
+
+	// Filhanterare frågar efter vad servern klarar innan de skickar inloggningsuppgifter
+	if r.Method == http.MethodOptions {
+		w.Header().Set("Allow", davAllowedMethods)
+		w.Header().Set("DAV", "1, 2")
+		w.Header().Set("MS-Author-Via", "DAV")
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	ctx, userID, ok := h.authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	p, err := parseDavPath(r.URL.EscapedPath())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case "PROPFIND":
+		h.handlePropfind(ctx, w, r, p)
+	case http.MethodGet, http.MethodHead:
+		h.handleGet(ctx, w, r, p)
+	case http.MethodPut:
+		h.handlePut(ctx, w, r, userID, p)
+	case "MKCOL":
+		h.handleMkcol(ctx, w, r, userID, p)
+	case http.MethodDelete:
+		h.handleDelete(ctx, w, r, userID, p)
+	case "MOVE", "COPY":
+		h.handleMoveCopy(ctx, w, r, userID, p)
+	case "LOCK":
+		h.handleLock(ctx, w, r, userID, p)
+	case "UNLOCK":
+		h.handleUnlock(w, r, userID, p)
+	default:
+		w.Header().Set("Allow", davAllowedMethods)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// ---------- Autentisering ----------
+
+// authenticate hämtar användaren från token i context eller från Basic-inloggning och lägger
+// token i context som för GraphQL-anrop. Utan giltiga uppgifter svarar den med 401.
+func (h *davHandler) authenticate(w http.ResponseWriter, r *http.Request) (context.Context, string, bool) {
+	ctx := r.Context()
+	if username, password, ok := r.BasicAuth(); ok {
+		token, err := h.basicAuthToken(username, password)
+		if err != nil {
+			log.Printf("WebDAV login failed for user %s: %v", username, err)
+			davChallenge(w)
+			return nil, "", false
+		}
+		ctx = context.WithValue(ctx, "Authorization", token)
+		ctx = context.WithValue(ctx, "Authenticate", token)
+	}
+
+	userID, err := getUserIDFromContext(ctx)
+	if err != nil {
+		davChallenge(w)
+		return nil, "", false
+	}
+	return ctx, userID, true
+}
+
+// basicAuthToken kontrollerar användarnamn och lösenord som vid inloggning och ger en token för
+// användaren. Godkända uppgifter sparas en kort stund, så ett ändrat lösenord slår igenom inom
+// davCredentialTTL.
+func (h *davHandler) basicAuthToken(username string, password string) (string, error) {
+	sum := sha256.Sum256([]byte(username + "\x00" + password))
+	key := hex.EncodeToString(sum[:])
+
+	h.mu.Lock()
+	credential, ok := h.credentials[key]
+	h.mu.Unlock()
+	if ok && time.Now().Before(credential.expires) {
+		return credential.token, nil
+	}
+
+	var userID, passwordHash string
+	err := h.db.QueryRow("SELECT id, password_hash FROM users WHERE username = ?", username).Scan(&userID, &passwordHash)
+	if err == sql.ErrNoRows {
+		return "", serviceErrorf(errNotAuthenticated, "invalid username or password")
+	} else if err != nil {
+		log.Printf("Error querying user: %v", err)
+		return "", serviceErrorf(errInternal, "internal server error: %v", err)
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(passwordHash), []byte(password)); err != nil {
+		return "", serviceErrorf(errNotAuthenticated, "invalid username or password")
+	}
+
+	token, err := generateJWT(userID, username)
+	if err != nil {
+		log.Printf("Error generating JWT token: %v", err)
+		return "", serviceErrorf(errInternal, "internal server error: %v", err)
+	}
+
+	now := time.Now()
+	h.mu.Lock()
+	for k, c := range h.credentials {
+		if now.After(c.expires) {
+			delete(h.credentials, k)
+		}
+	}
+	h.credentials[key] = davCredential{token: token, expires: now.Add(davCredentialTTL)}
+	h.mu.Unlock()
+	return token, nil
+}
+
+// davChallenge ber klienten logga in
+func davChallenge(w http.ResponseWriter) {
+	w.Header().Set("WWW-Authenticate", `Basic realm="e-Arkive", charset="UTF-8"`)
+	http.Error(w, "not authenticated", http.StatusUnauthorized)
+}
+
+// ---------- Sökvägar och resurser ----------
+
+// davPath är en sökväg under WebDAVPath som namnen på noderna och filen längs vägen
+type davPath []string
+
+// parseDavPath läser en URL-kodad sökväg. Namnsegmenten avkodas var för sig så att namn som
+// innehåller snedstreck kan adresseras som %2F.
+func parseDavPath(escaped string) (davPath, error) {
+	rest := strings.TrimPrefix(escaped, strings.TrimSuffix(WebDAVPath, "/"))
+	if rest == escaped && escaped != strings.TrimSuffix(WebDAVPath, "/") {
+		return nil, fmt.Errorf("path must be below %s", WebDAVPath)
+	}
+
+	var p davPath
+	for _, segment := range strings.Split(rest, "/") {
+		if segment == "" {
+			continue
+		}
+		name, err := url.PathUnescape(segment)
+		if err != nil {
+			return nil, fmt.Errorf("invalid path segment %q", segment)
+		}
+		if name == "." || name == ".." {
+			return nil, fmt.Errorf("relative path segments are not allowed")
+		}
+		p = append(p, name)
+	}
+	return p, nil
+}
+
+// key är sökvägen i kanonisk URL-kodning, som används för lås
+func (p davPath) key() string {
+	segments := make([]string, len(p))
+	for i, name := range p {
+		segments[i] = url.PathEscape(name)
+	}
+	return strings.Join(segments, "/")
+}
+
+// href är sökvägen som den visas för klienten, med avslutande snedstreck för kataloger
+func (p davPath) href(collection bool) string {
+	href := WebDAVPath + p.key()
+	if collection && len(p) > 0 {
+		href += "/"
+	}
+	return href
+}
+
+func (p davPath) parent() davPath {
+	if len(p) == 0 {
+		return p
+	}
+	return p[:len(p)-1]
+}
+
+func (p davPath) name() string {
+	if len(p) == 0 {
+		return ""
+	}
+	return p[len(p)-1]
+}
+
+func (p davPath) child(name string) davPath {
+	return append(append(davPath{}, p...), name)
+}
+
+// davUnder avgör om sökvägen key är base eller ligger under den
+func davUnder(key string, base string) bool {
+	return base == "" || key == base || strings.HasPrefix(key, base+"/")
+}
+
+// davResource är en nod eller fil som WebDAV-klienten ser som katalog eller fil. Roten, som
+// listar rotnoderna, har varken nod eller fil.
+type davResource struct {
+	path        davPath
+	nodeID      string // Noden, eller noden som filen ligger i
+	parentID    string // Nodens föräldernod, tom för rotnoder och filer
+	fileID      string // Tom för kataloger
+	size        int
+	contentType string
+	createdAt   string
+	updatedAt   string
+	checksum    string
+}
+
+func (res *davResource) isCollection() bool { return res.fileID == "" }
+
+func (res *davResource) href() string { return res.path.href(res.isCollection()) }
+
+// etag ändras när filens innehåll ändras; kontrollsumman räcker eftersom innehållet aldrig
+// skrivs över på plats
+func (res *davResource) etag() string {
+	if res.checksum != "" {
+		return `"` + res.checksum + `"`
+	}
+	return fmt.Sprintf(`"%s-%s"`, res.fileID, res.updatedAt)
+}
+
+// resolve hittar resursen för en sökväg genom att följa nodnamnen från roten. Noder som
+// användaren inte får se finns inte för WebDAV-klienten. Finns inget med namnet returneras nil.
+// Har flera noder eller filer samma namn används den första.
+func (h *davHandler) resolve(ctx context.Context, p davPath) (*davResource, error) {
+	res := &davResource{path: davPath{}}
+	for i, name := range p {
+		if !res.isCollection() {
+			return nil, nil
+		}
+
+		nodes, err := h.childNodes(ctx, res.nodeID)
+		if err != nil {
+			return nil, err
+		}
+		var found *davResource
+		for _, node := range nodes {
+			if node.Name == name {
+				found = nodeDavResource(p[:i+1], node)
+				break
+			}
+		}
+		if found == nil && i == len(p)-1 && res.nodeID != "" {
+			found, err = h.findFile(res.nodeID, p)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if found == nil {
+			return nil, nil
+		}
+		res = found
+	}
+	return res, nil
+}
+
+// childNodes hämtar de barnnoder användaren får se, eller rotnoderna om parentID är tomt
+func (h *davHandler) childNodes(ctx context.Context, parentID string) ([]*model.Node, error) {
+	if parentID != "" {
+		return getChildNodesWithPermissions(ctx, h.db, parentID)
+	}
+
+	roots, err := h.services.Nodes.Roots(ctx)
+	if err != nil {
+		return nil, err
+	}
+	visible := roots[:0]
+	for _, node := range roots {
+		allowed, err := checkPermission(ctx, h.db, node.ID, PERM_VIEW)
+		if err != nil {
+			return nil, err
+		}
+		if allowed {
+			visible = append(visible, node)
+		}
+	}
+	return visible, nil
+}
+
+// members hämtar innehållet i en katalog: barnnoderna och nodens filer
+func (h *davHandler) members(ctx context.Context, res *davResource) ([]*davResource, error) {
+	nodes, err := h.childNodes(ctx, res.nodeID)
+	if err != nil {
+		return nil, err
+	}
+	members := make([]*davResource, 0, len(nodes))
+	for _, node := range nodes {
+		members = append(members, nodeDavResource(res.path.child(node.Name), node))
+	}
+	if res.nodeID == "" {
+		return members, nil
+	}
+
+	rows, err := h.db.Query("SELECT "+davFileColumns+" FROM files WHERE node_id = ? ORDER BY name ASC, id ASC", res.nodeID)
+	if err != nil {
+		log.Printf("Error fetching files for node ID %s: %v", res.nodeID, err)
+		return nil, serviceErrorf(errInternal, "failed to fetch files: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		file, err := scanDavFile(rows, res.path)
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, file)
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("Error iterating over file rows: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to iterate over file rows: %v", err)
+	}
+	return members, nil
+}
+
+// Kolumnerna som scanDavFile läser, utan filinnehållet
+const davFileColumns = "id, node_id, name, size, content_type, created_at, COALESCE(updated_at, created_at), COALESCE(checksum, '')"
+
+// findFile hittar filen med sökvägens namn i noden
+func (h *davHandler) findFile(nodeID string, p davPath) (*davResource, error) {
+	row := h.db.QueryRow("SELECT "+davFileColumns+" FROM files WHERE node_id = ? AND name = ? ORDER BY id ASC LIMIT 1", nodeID, p.name())
+	file, err := scanDavFile(row, p.parent())
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return file, err
+}
+
+// scanDavFile läser en filrad som en resurs i katalogen dir
+func scanDavFile(row interface{ Scan(...interface{}) error }, dir davPath) (*davResource, error) {
+	var res davResource
+	var name string
+	err := row.Scan(&res.fileID, &res.nodeID, &name, &res.size, &res.contentType, &res.createdAt, &res.updatedAt, &res.checksum)
+	if err == sql.ErrNoRows {
+		return nil, err
+	} else if err != nil {
+		log.Printf("Error scanning file row: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to scan file row: %v", err)
+	}
+	res.path = dir.child(name)
+	return &res, nil
+}
+
+// nodeDavResource gör om en nod till en katalog
+func nodeDavResource(p davPath, node *model.Node) *davResource {
+	res := &davResource{path: p, nodeID: node.ID, createdAt: node.CreatedAt, updatedAt: node.UpdatedAt}
+	if node.ParentID != nil {
+		res.parentID = *node.ParentID
+	}
+	return res
+}
+
+// ---------- PROPFIND ----------
+
+// handlePropfind listar egenskaperna för en resurs och, med Depth: 1, dess innehåll. Alla
+// egenskaper skickas oavsett vilka klienten frågar efter. Depth: infinity stöds inte, eftersom
+// ett helt arkiv inte ska kunna listas i ett anrop.
+func (h *davHandler) handlePropfind(ctx context.Context, w http.ResponseWriter, r *http.Request, p davPath) {
+	depth := r.Header.Get("Depth")
+	if depth != "0" && depth != "1" {
+		writeDavError(w, http.StatusForbidden, "<D:propfind-finite-depth/>")
+		return
+	}
+	if err := readDavBody(w, r, nil); err != nil && err != io.EOF {
+		writeDavBodyErr(w, err)
+		return
+	}
+
+	res, err := h.resolve(ctx, p)
+	if err != nil {
+		writeDavErr(w, err)
+		return
+	}
+	if res == nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+
+	resources := []*davResource{res}
+	if depth == "1" && res.isCollection() {
+		members, err := h.members(ctx, res)
+		if err != nil {
+			writeDavErr(w, err)
+			return
+		}
+		resources = append(resources, members...)
+	}
+
+	status := davStatus(http.StatusOK)
+	multistatus := davMultistatus{XmlnsD: "DAV:"}
+	for _, resource := range resources {
+		multistatus.Responses = append(multistatus.Responses, davResponse{
+			Href:     resource.href(),
+			Propstat: &davPropstat{Prop: h.props(resource), Status: status},
+		})
+	}
+	writeDavXML(w, http.StatusMultiStatus, multistatus)
+}
+
+// props tar fram egenskaperna som PROPFIND visar för en resurs
+func (h *davHandler) props(res *davResource) davProp {
+	prop := davProp{
+		DisplayName:   res.path.name(),
+		SupportedLock: davSupportedLock{Entry: davLockEntry{}},
+		LockDiscovery: davLockDiscovery{Locks: h.locks.activeLocks(res.path.key())},
+	}
+	if res.createdAt != "" {
+		prop.CreationDate = parseZipTime(res.createdAt).UTC().Format(time.RFC3339)
+		prop.LastModified = parseZipTime(res.updatedAt).UTC().Format(http.TimeFormat)
+	}
+	if res.isCollection() {
+		prop.ResourceType.Collection = &struct{}{}
+	} else {
+		size := res.size
+		prop.ContentLength = &size
+		prop.ContentType = res.contentType
+		prop.ETag = res.etag()
+	}
+	return prop
+}
+
+// ---------- GET och HEAD ----------
+
+// handleGet lämnar ut en fil. Innehållet hämtas som nedladdning genom downloadFile, så det
+// revisions- och åtkomstloggas. HEAD svarar med filens egenskaper utan att läsa innehållet.
+func (h *davHandler) handleGet(ctx context.Context, w http.ResponseWriter, r *http.Request, p davPath) {
+	res, err := h.resolve(ctx, p)
+	if err != nil {
+		writeDavErr(w, err)
+		return
+	}
+	if res == nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	if res.isCollection() {
+		w.Header().Set("Allow", "OPTIONS, PROPFIND, MKCOL, DELETE, MOVE, COPY, LOCK, UNLOCK")
+		http.Error(w, "collections cannot be downloaded, use PROPFIND to list them", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", res.contentType)
+	w.Header().Set("ETag", res.etag())
+	modified := parseZipTime(res.updatedAt)
+	if r.Method == http.MethodHead {
+		w.Header().Set("Content-Length", strconv.Itoa(res.size))
+		w.Header().Set("Last-Modified", modified.UTC().Format(http.TimeFormat))
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	data, err := h.readFile(ctx, res)
+	if err != nil {
+		writeDavErr(w, err)
+		return
+	}
+	http.ServeContent(w, r, res.path.name(), modified, bytes.NewReader(data))
+}
+
+// readFile hämtar en fils innehåll genom downloadFile
+func (h *davHandler) readFile(ctx context.Context, res *davResource) ([]byte, error) {
+	result, err := callResolver(ctx, h.db, "Query", "downloadFile", map[string]interface{}{"id": res.fileID},
+		func(ctx context.Context) (interface{}, error) {
+			return h.services.Files.Download(ctx, res.fileID)
+		})
+	if err != nil {
+		return nil, err
+	}
+	file := result.(*model.File)
+	if file.FileData == nil {
+		return []byte{}, nil
+	}
+	data, err := base64.StdEncoding.DecodeString(*file.FileData)
+	if err != nil {
+		return nil, serviceErrorf(errInternal, "failed to decode file data: %v", err)
+	}
+	return data, nil
+}
+
+// ---------- PUT ----------
+
+// handlePut laddar upp en fil till en nod. En befintlig fil med samma namn ersätts av en ny fil
+// med samma metadata, typ och fält, eftersom arkiverade filer aldrig skrivs över på plats.
+func (h *davHandler) handlePut(ctx context.Context, w http.ResponseWriter, r *http.Request, userID string, p davPath) {
+	if len(p) < 2 {
+		http.Error(w, "files must be placed in a node", http.StatusForbidden)
+		return
+	}
+	if h.isLocked(r, userID, p.key(), false) || h.isLocked(r, userID, p.parent().key(), false) {
+		http.Error(w, "resource is locked", http.StatusLocked)
+		return
+	}
+
+	parent, err := h.resolve(ctx, p.parent())
+	if err != nil {
+		writeDavErr(w, err)
+		return
+	}
+	if parent == nil || !parent.isCollection() {
+		http.Error(w, "parent collection not found", http.StatusConflict)
+		return
+	}
+	existing, err := h.resolve(ctx, p)
+	if err != nil {
+		writeDavErr(w, err)
+		return
+	}
+	if existing != nil && existing.isCollection() {
+		http.Error(w, "a collection with this name already exists", http.StatusMethodNotAllowed)
+		return
+	}
+
+	data, err := readDavLimited(w, r, davMaxFileSize)
+	if err != nil {
+		writeDavBodyErr(w, err)
+		return
+	}
+	contentType := r.Header.Get("Content-Type")
+	if contentType == "" || contentType == "application/octet-stream" {
+		contentType = detectContentType(p.name(), data)
+	}
+
+	var file *model.File
+	status := http.StatusCreated
+	if existing == nil {
+		file, err = h.saveFile(ctx, parent.nodeID, p.name(), data, contentType, "")
+	} else {
+		file, err = h.replaceFile(ctx, existing, data, contentType)
+		status = http.StatusNoContent
+	}
+	if err != nil {
+		writeDavErr(w, err)
+		return
+	}
+
+	w.Header().Set("ETag", `"`+checksumSHA256(data)+`"`)
+	log.Printf("WebDAV stored file %s (ID: %s) in node %s", file.Name, file.ID, parent.nodeID)
+	w.WriteHeader(status)
+}
+
+// saveFile sparar en fil i en nod genom saveFile. Anges fromFileID får filen den filens
+// metadata, typ och fält, som vid kopiering och ersättning.
+func (h *davHandler) saveFile(ctx context.Context, nodeID string, name string, data []byte, contentType string, fromFileID string) (*model.File, error) {
+	input := model.FileInput{
+		Name:        name,
+		Size:        len(data),
+		ContentType: contentType,
+		FileData:    base64.StdEncoding.EncodeToString(data),
+		NodeID:      &nodeID,
+	}
+	if fromFileID != "" {
+		fileType, err := getFileType(h.db, fromFileID)
+		if err != nil {
+			return nil, err
+		}
+		fields, err := loadTypedFields(h.db, ENTITY_KIND_FILE, fromFileID)
+		if err != nil {
+			return nil, err
+		}
+		metadata, err := loadFileMetadata(h.db, fromFileID)
+		if err != nil {
+			return nil, err
+		}
+		input.FileType = &fileType
+		input.Fields = typedFieldInputs(fields)
+		input.Metadata = metadataInputs(metadata)
+	}
+
+	result, err := callResolver(ctx, h.db, "Mutation", "saveFile", map[string]interface{}{"input": input},
+		func(ctx context.Context) (interface{}, error) {
+			return h.services.Files.Save(ctx, input)
+		})
+	if err != nil {
+		return nil, err
+	}
+	return result.(*model.File), nil
+}
+
+// replaceFile ersätter en fil med nytt innehåll. Den nya filen sparas innan den gamla tas bort,
+// så att innehållet inte går förlorat om sparandet misslyckas.
+func (h *davHandler) replaceFile(ctx context.Context, existing *davResource, data []byte, contentType string) (*model.File, error) {
+	if err := checkFileNotHeld(h.db, existing.fileID, "replace file"); err != nil {
+		return nil, err
+	}
+
+	file, err := h.saveFile(ctx, existing.nodeID, existing.path.name(), data, contentType, existing.fileID)
+	if err != nil {
+		return nil, err
+	}
+	if err := h.deleteFile(ctx, existing.fileID); err != nil {
+		if rollbackErr := h.deleteFile(ctx, file.ID); rollbackErr != nil {
+			log.Printf("Error removing replacement file %s: %v", file.ID, rollbackErr)
+		}
+		return nil, err
+	}
+	return file, nil
+}
+
+// deleteFile tar bort en fil genom deleteFile
+func (h *davHandler) deleteFile(ctx context.Context, fileID string) error {
+	_, err := callResolver(ctx, h.db, "Mutation", "deleteFile", map[string]interface{}{"id": fileID},
+		func(ctx context.Context) (interface{}, error) {
+			return h.services.Files.Delete(ctx, fileID)
+		})
+	return err
+}
+
+// ---------- MKCOL ----------
+
+// handleMkcol skapar en nod. Rotnoder kan bara skapas av administratörer.
+func (h *davHandler) handleMkcol(ctx context.Context, w http.ResponseWriter, r *http.Request, userID string, p davPath) {
+	if len(p) == 0 {
+		http.Error(w, "collection already exists", http.StatusMethodNotAllowed)
+		return
+	}
+	if r.ContentLength > 0 {
+		http.Error(w, "MKCOL with a request body is not supported", http.StatusUnsupportedMediaType)
+		return
+	}
+	if h.isLocked(r, userID, p.key(), false) || h.isLocked(r, userID, p.parent().key(), false) {
+		http.Error(w, "resource is locked", http.StatusLocked)
+		return
+	}
+
+	existing, err := h.resolve(ctx, p)
+	if err != nil {
+		writeDavErr(w, err)
+		return
+	}
+	if existing != nil {
+		http.Error(w, "resource already exists", http.StatusMethodNotAllowed)
+		return
+	}
+	parent, err := h.resolve(ctx, p.parent())
+	if err != nil {
+		writeDavErr(w, err)
+		return
+	}
+	if parent == nil || !parent.isCollection() {
+		http.Error(w, "parent collection not found", http.StatusConflict)
+		return
+	}
+
+	node, err := h.createNode(ctx, parent.nodeID, p.name(), "")
+	if err != nil {
+		writeDavErr(w, err)
+		return
+	}
+	log.Printf("WebDAV created node %s (ID: %s)", node.Name, node.ID)
+	w.WriteHeader(http.StatusCreated)
+}
+
+// createNode skapar en nod genom createNode. Anges fromNodeID får noden den nodens typ och fält.
+func (h *davHandler) createNode(ctx context.Context, parentID string, name string, fromNodeID string) (*model.Node, error) {
+	input := model.NodeInput{Name: name}
+	if parentID == "" {
+		if _, err := requireAdministrator(ctx, h.db, "create root nodes"); err != nil {
+			return nil, err
+		}
+	} else {
+		if err := requireNodePermission(ctx, h.db, parentID, PERM_MODIFY, "cannot modify this node"); err != nil {
+			return nil, err
+		}
+		input.ParentID = &parentID
+	}
+	if fromNodeID != "" {
+		nodeType, err := getNodeType(h.db, fromNodeID)
+		if err != nil {
+			return nil, err
+		}
+		fields, err := loadTypedFields(h.db, ENTITY_KIND_NODE, fromNodeID)
+		if err != nil {
+			return nil, err
+		}
+		input.NodeType = &nodeType
+		input.Fields = typedFieldInputs(fields)
+	}
+
+	result, err := callResolver(ctx, h.db, "Mutation", "createNode", map[string]interface{}{"input": input},
+		func(ctx context.Context) (interface{}, error) {
+			return h.services.Nodes.Create(ctx, input)
+		})
+	if err != nil {
+		return nil, err
+	}
+	return result.(*model.Node), nil
+}
+
+// ---------- DELETE ----------
+
+// davFailure är en resurs som inte kunde tas bort eller kopieras
+type davFailure struct {
+	href   string
+	status int
+	err    error
+}
+
+// handleDelete tar bort en fil, eller en nod med allt innehåll. Det som inte kan tas bort lämnas
+// kvar tillsammans med noderna ovanför och rapporteras i ett multistatus-svar.
+func (h *davHandler) handleDelete(ctx context.Context, w http.ResponseWriter, r *http.Request, userID string, p davPath) {
+	if len(p) == 0 {
+		http.Error(w, "the root cannot be deleted", http.StatusForbidden)
+		return
+	}
+	if h.isLocked(r, userID, p.key(), true) || h.isLocked(r, userID, p.parent().key(), false) {
+		http.Error(w, "resource is locked", http.StatusLocked)
+		return
+	}
+
+	res, err := h.resolve(ctx, p)
+	if err != nil {
+		writeDavErr(w, err)
+		return
+	}
+	if res == nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+
+	if failures := h.deleteTree(ctx, res); len(failures) > 0 {
+		writeDavFailures(w, res, failures)
+		return
+	}
+	h.locks.removeTree(p.key())
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// deleteTree tar bort en fil, eller en nods filer och barnnoder innan noden själv tas bort
+func (h *davHandler) deleteTree(ctx context.Context, res *davResource) []davFailure {
+	if !res.isCollection() {
+		if err := h.deleteFile(ctx, res.fileID); err != nil {
+			return []davFailure{{href: res.href(), status: davErrorStatus(err), err: err}}
+		}
+		return nil
+	}
+
+	// Kontrolleras först så att innehållet inte töms i en nod som sedan inte får tas bort
+	if err := requireNodePermission(ctx, h.db, res.nodeID, PERM_DELETE, "cannot delete this node"); err != nil {
+		return []davFailure{{href: res.href(), status: davErrorStatus(err), err: err}}
+	}
+
+	members, err := h.members(ctx, res)
+	if err != nil {
+		return []davFailure{{href: res.href(), status: davErrorStatus(err), err: err}}
+	}
+	var failures []davFailure
+	for _, member := range members {
+		failures = append(failures, h.deleteTree(ctx, member)...)
+	}
+	if len(failures) > 0 {
+		return failures
+	}
+
+	_, err = callResolver(ctx, h.db, "Mutation", "deleteNode", map[string]interface{}{"id": res.nodeID},
+		func(ctx context.Context) (interface{}, error) {
+			return h.services.Nodes.Delete(ctx, res.nodeID)
+		})
+	if err != nil {
+		return []davFailure{{href: res.href(), status: davErrorStatus(err), err: err}}
+	}
+	return nil
+}
+
+// ---------- MOVE och COPY ----------
+
+// handleMoveCopy flyttar eller kopierar en resurs till Destination. Ett befintligt mål tas bort
+// först om inte Overwrite: F har angetts.
+func (h *davHandler) handleMoveCopy(ctx context.Context, w http.ResponseWriter, r *http.Request, userID string, p davPath) {
+	move := r.Method == "MOVE"
+	if len(p) == 0 {
+		http.Error(w, "the root cannot be moved or copied", http.StatusForbidden)
+		return
+	}
+
+	destination, err := url.Parse(r.Header.Get("Destination"))
+	if err != nil || r.Header.Get("Destination") == "" {
+		http.Error(w, "invalid or missing Destination header", http.StatusBadRequest)
+		return
+	}
+	if destination.Host != "" && destination.Host != r.Host {
+		http.Error(w, "destination is on another server", http.StatusBadGateway)
+		return
+	}
+	dst, err := parseDavPath(destination.EscapedPath())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(dst) == 0 || dst.key() == p.key() {
+		http.Error(w, "source and destination must differ", http.StatusForbidden)
+		return
+	}
+	if davUnder(dst.key(), p.key()) {
+		http.Error(w, "cannot move or copy a collection into itself", http.StatusConflict)
+		return
+	}
+
+	recursive := true
+	if !move {
+		switch r.Header.Get("Depth") {
+		case "", "infinity":
+		case "0":
+			recursive = false
+		default:
+			http.Error(w, "Depth must be 0 or infinity", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if (move && (h.isLocked(r, userID, p.key(), true) || h.isLocked(r, userID, p.parent().key(), false))) ||
+		h.isLocked(r, userID, dst.key(), true) || h.isLocked(r, userID, dst.parent().key(), false) {
+		http.Error(w, "resource is locked", http.StatusLocked)
+		return
+	}
+
+	src, err := h.resolve(ctx, p)
+	if err != nil {
+		writeDavErr(w, err)
+		return
+	}
+	if src == nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	parent, err := h.resolve(ctx, dst.parent())
+	if err != nil {
+		writeDavErr(w, err)
+		return
+	}
+	if parent == nil || !parent.isCollection() {
+		http.Error(w, "destination collection not found", http.StatusConflict)
+		return
+	}
+	if parent.nodeID == "" && !src.isCollection() {
+		http.Error(w, "files must be placed in a node", http.StatusForbidden)
+		return
+	}
+	// moveNode kräver en ny förälder, så noder kan bara flyttas till roten genom namnbyte
+	if move && parent.nodeID == "" && src.parentID != "" {
+		http.Error(w, "nodes cannot be moved to the root", http.StatusForbidden)
+		return
+	}
+
+	existing, err := h.resolve(ctx, dst)
+	if err != nil {
+		writeDavErr(w, err)
+		return
+	}
+	status := http.StatusCreated
+	if existing != nil {
+		if r.Header.Get("Overwrite") == "F" {
+			http.Error(w, "destination already exists", http.StatusPreconditionFailed)
+			return
+		}
+		if failures := h.deleteTree(ctx, existing); len(failures) > 0 {
+			writeDavFailures(w, existing, failures)
+			return
+		}
+		h.locks.removeTree(dst.key())
+		status = http.StatusNoContent
+	}
+
+	if move {
+		if err := h.move(ctx, src, parent.nodeID, dst.name()); err != nil {
+			writeDavErr(w, err)
+			return
+		}
+		h.locks.removeTree(p.key())
+		log.Printf("WebDAV moved %s to %s", src.href(), dst.href(src.isCollection()))
+		w.WriteHeader(status)
+		return
+	}
+
+	if failures := h.copyTree(ctx, src, parent.nodeID, dst, recursive); len(failures) > 0 {
+		writeDavFailures(w, &davResource{path: dst, fileID: src.fileID}, failures)
+		return
+	}
+	log.Printf("WebDAV copied %s to %s", src.href(), dst.href(src.isCollection()))
+	w.WriteHeader(status)
+}
+
+// move flyttar en nod eller fil till noden parentID och byter namn på den om namnet ändrats
+func (h *davHandler) move(ctx context.Context, src *davResource, parentID string, name string) error {
+	if !src.isCollection() {
+		if parentID != src.nodeID {
+			_, err := callResolver(ctx, h.db, "Mutation", "moveFile", map[string]interface{}{"fileId": src.fileID, "nodeId": parentID},
+				func(ctx context.Context) (interface{}, error) {
+					return h.services.Files.Move(ctx, src.fileID, parentID)
+				})
+			if err != nil {
+				return err
+			}
+		}
+		if name != src.path.name() {
+			_, err := callResolver(ctx, h.db, "Mutation", "renameFile", map[string]interface{}{"id": src.fileID, "name": name},
+				func(ctx context.Context) (interface{}, error) {
+					return h.services.Files.Rename(ctx, src.fileID, name)
+				})
+			return err
+		}
+		return nil
+	}
+
+	if parentID != src.parentID {
+		_, err := callResolver(ctx, h.db, "Mutation", "moveNode", map[string]interface{}{"id": src.nodeID, "newParentId": parentID},
+			func(ctx context.Context) (interface{}, error) {
+				return h.services.Nodes.Move(ctx, src.nodeID, parentID)
+			})
+		if err != nil {
+			return err
+		}
+	}
+	if name != src.path.name() {
+		if err := requireNodePermission(ctx, h.db, src.nodeID, PERM_MODIFY, "cannot modify this node"); err != nil {
+			return err
+		}
+		// updateNode sätter föräldern till parentId, så den nuvarande föräldern måste anges
+		input := model.NodeUpdateInput{Name: &name}
+		if parentID != "" {
+			input.ParentID = &parentID
+		}
+		_, err := callResolver(ctx, h.db, "Mutation", "updateNode", map[string]interface{}{"id": src.nodeID, "input": input},
+			func(ctx context.Context) (interface{}, error) {
+				return h.services.Nodes.Update(ctx, src.nodeID, input)
+			})
+		return err
+	}
+	return nil
+}
+
+// copyTree kopierar en fil, eller en nod med metadata och, om recursive, allt innehåll, till dst
+// under noden parentID. Kopior får samma metadata, typ och fält som originalen.
+func (h *davHandler) copyTree(ctx context.Context, src *davResource, parentID string, dst davPath, recursive bool) []davFailure {
+	href := dst.href(src.isCollection())
+	if !src.isCollection() {
+		data, err := h.readFile(ctx, src)
+		if err == nil {
+			_, err = h.saveFile(ctx, parentID, dst.name(), data, src.contentType, src.fileID)
+		}
+		if err != nil {
+			return []davFailure{{href: href, status: davErrorStatus(err), err: err}}
+		}
+		return nil
+	}
+
+	node, err := h.createNode(ctx, parentID, dst.name(), src.nodeID)
+	if err == nil {
+		err = h.copyNodeMetadata(ctx, src.nodeID, node.ID)
+	}
+	if err != nil {
+		return []davFailure{{href: href, status: davErrorStatus(err), err: err}}
+	}
+	if !recursive {
+		return nil
+	}
+
+	members, err := h.members(ctx, src)
+	if err != nil {
+		return []davFailure{{href: href, status: davErrorStatus(err), err: err}}
+	}
+	var failures []davFailure
+	for _, member := range members {
+		failures = append(failures, h.copyTree(ctx, member, node.ID, dst.child(member.path.name()), true)...)
+	}
+	return failures
+}
+
+// copyNodeMetadata ger en kopierad nod originalets metadata genom updateNodeMetadata
+func (h *davHandler) copyNodeMetadata(ctx context.Context, fromNodeID string, nodeID string) error {
+	metadata, err := loadNodeMetadata(h.db, fromNodeID)
+	if err != nil || len(metadata) == 0 {
+		return err
+	}
+	inputs := metadataInputs(metadata)
+	_, err = callResolver(ctx, h.db, "Mutation", "updateNodeMetadata", map[string]interface{}{"nodeId": nodeID, "metadataInput": inputs},
+		func(ctx context.Context) (interface{}, error) {
+			return h.mutation.UpdateNodeMetadata(ctx, nodeID, inputs)
+		})
+	return err
+}
+
+// metadataInputs gör om sparad metadata till indata, t.ex. för att ge en kopia samma metadata
+func metadataInputs(metadata []*model.Metadata) []*model.MetadataInput {
+	inputs := make([]*model.MetadataInput, 0, len(metadata))
+	for _, meta := range metadata {
+		valueType := meta.Type
+		input := &model.MetadataInput{Key: meta.Key, Value: meta.Value, Type: &valueType}
+		if meta.Inheritable {
+			inheritable := true
+			input.Inheritable = &inheritable
+		}
+		inputs = append(inputs, input)
+	}
+	return inputs
+}
+
+// ---------- LOCK och UNLOCK ----------
+
+// davLock är ett exklusivt skrivlås. Låsen finns bara i minnet och försvinner när servern startas om.
+type davLock struct {
+	token    string
+	key      string // Den låsta sökvägen
+	href     string
+	infinite bool   // Låset gäller även allt under sökvägen
+	userID   string // Bara användaren som tog låset kan använda det
+	owner    string // Klientens owner-element, som det skickades
+	timeout  time.Duration
+	expires  time.Time
+}
+
+// davLockManager håller reda på serverns lås
+type davLockManager struct {
+	mu    sync.Mutex
+	locks map[string]*davLock
+}
+
+// conflicts returnerar låsen som påverkas av en ändring av key och, om recursive, allt under key.
+// Anroparen måste hålla mu.
+func (m *davLockManager) conflicts(key string, recursive bool) []*davLock {
+	now := time.Now()
+	var conflicts []*davLock
+	for token, lock := range m.locks {
+		if now.After(lock.expires) {
+			delete(m.locks, token)
+			continue
+		}
+		if lock.key == key || (lock.infinite && davUnder(key, lock.key)) || (recursive && davUnder(lock.key, key)) {
+			conflicts = append(conflicts, lock)
+		}
+	}
+	return conflicts
+}
+
+// allowed avgör om användaren får ändra key, dvs. om varje lås som berörs har skickats i If-headern
+// och tillhör användaren
+func (m *davLockManager) allowed(key string, recursive bool, userID string, tokens []string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, lock := range m.conflicts(key, recursive) {
+		if lock.userID != userID || !containsString(tokens, lock.token) {
+			return false
+		}
+	}
+	return true
+}
+
+// create tar ett nytt lås om inget annat lås står i vägen
+func (m *davLockManager) create(lock *davLock) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.conflicts(lock.key, lock.infinite)) > 0 {
+		return false
+	}
+	m.locks[lock.token] = lock
+	return true
+}
+
+// refresh förlänger ett av användarens lås som gäller key
+func (m *davLockManager) refresh(key string, userID string, tokens []string, timeout time.Duration) *davLock {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, lock := range m.conflicts(key, false) {
+		if lock.userID == userID && containsString(tokens, lock.token) {
+			lock.timeout = timeout
+			lock.expires = time.Now().Add(timeout)
+			refreshed := *lock
+			return &refreshed
+		}
+	}
+	return nil
+}
+
+// unlock tar bort användarens lås med token om det gäller key
+func (m *davLockManager) unlock(key string, userID string, token string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	lock, ok := m.locks[token]
+	if !ok || lock.userID != userID || !(lock.key == key || (lock.infinite && davUnder(key, lock.key))) {
+		return false
+	}
+	delete(m.locks, token)
+	return true
+}
+
+// removeTree tar bort låsen på key och allt under den, när resurserna tagits bort eller flyttats
+func (m *davLockManager) removeTree(key string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for token, lock := range m.locks {
+		if davUnder(lock.key, key) {
+			delete(m.locks, token)
+		}
+	}
+}
+
+// activeLocks returnerar låsen som gäller key, som de visas i lockdiscovery
+func (m *davLockManager) activeLocks(key string) []davActiveLock {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var active []davActiveLock
+	for _, lock := range m.conflicts(key, false) {
+		active = append(active, lock.activeLock())
+	}
+	return active
+}
+
+func (lock *davLock) activeLock() davActiveLock {
+	active := davActiveLock{
+		Depth:   "0",
+		Timeout: fmt.Sprintf("Second-%d", int(lock.timeout.Seconds())),
+		Token:   davHref{Href: lock.token},
+		Root:    davHref{Href: lock.href},
+	}
+	if lock.infinite {
+		active.Depth = "infinity"
+	}
+	if lock.owner != "" {
+		active.Owner = &davOwner{InnerXML: lock.owner}
+	}
+	return active
+}
+
+// isLocked avgör om ett lås hindrar användaren från att ändra key med de token som skickats
+func (h *davHandler) isLocked(r *http.Request, userID string, key string, recursive bool) bool {
+	return !h.locks.allowed(key, recursive, userID, davIfTokens(r))
+}
+
+// davIfTokens hämtar låstoken ur If-headern. Villkor på ETag och Not utvärderas inte.
+func davIfTokens(r *http.Request) []string {
+	var tokens []string
+	for _, match := range davLockTokenPattern.FindAllStringSubmatch(r.Header.Get("If"), -1) {
+		tokens = append(tokens, match[1])
+	}
+	return tokens
+}
+
+// davLockInfo är innehållet i en LOCK-förfrågan
+type davLockInfo struct {
+	Exclusive *struct{} `xml:"lockscope>exclusive"`
+	Shared    *struct{} `xml:"lockscope>shared"`
+	Write     *struct{} `xml:"locktype>write"`
+	Owner     struct {
+		InnerXML string `xml:",innerxml"`
+	} `xml:"owner"`
+}
+
+// handleLock tar eller förnyar ett exklusivt skrivlås. Ett lås på en sökväg där det inte finns
+// någon fil skapar en tom fil, som filhanterare och kontorsprogram förväntar sig. Bara den som får
+// ändra en resurs får låsa den.
+func (h *davHandler) handleLock(ctx context.Context, w http.ResponseWriter, r *http.Request, userID string, p davPath) {
+	if len(p) == 0 {
+		http.Error(w, "the root cannot be locked", http.StatusForbidden)
+		return
+	}
+	timeout := davLockTimeout(r.Header.Get("Timeout"))
+
+	var info davLockInfo
+	if err := readDavBody(w, r, &info); err == io.EOF {
+		// Utan innehåll är det en förnyelse av ett lås som anges i If-headern
+		lock := h.locks.refresh(p.key(), userID, davIfTokens(r), timeout)
+		if lock == nil {
+			http.Error(w, "no matching lock to refresh", http.StatusPreconditionFailed)
+			return
+		}
+		writeDavXML(w, http.StatusOK, davLockResponse{XmlnsD: "DAV:", LockDiscovery: davLockDiscovery{Locks: []davActiveLock{lock.activeLock()}}})
+		return
+	} else if err != nil {
+		writeDavBodyErr(w, err)
+		return
+	}
+	if info.Exclusive == nil || info.Write == nil {
+		http.Error(w, "only exclusive write locks are supported", http.StatusPreconditionFailed)
+		return
+	}
+
+	lock := &davLock{
+		token:    "opaquelocktoken:" + uuid.New().String(),
+		key:      p.key(),
+		infinite: true,
+		userID:   userID,
+		owner:    strings.TrimSpace(info.Owner.InnerXML),
+		timeout:  timeout,
+		expires:  time.Now().Add(timeout),
+	}
+	switch r.Header.Get("Depth") {
+	case "", "infinity":
+	case "0":
+		lock.infinite = false
+	default:
+		http.Error(w, "Depth must be 0 or infinity", http.StatusBadRequest)
+		return
+	}
+
+	res, err := h.resolve(ctx, p)
+	if err != nil {
+		writeDavErr(w, err)
+		return
+	}
+	status := http.StatusOK
+	if res == nil {
+		if h.isLocked(r, userID, p.parent().key(), false) {
+			http.Error(w, "resource is locked", http.StatusLocked)
+			return
+		}
+		parent, err := h.resolve(ctx, p.parent())
+		if err != nil {
+			writeDavErr(w, err)
+			return
+		}
+		if parent == nil || parent.nodeID == "" {
+			http.Error(w, "parent collection not found", http.StatusConflict)
+			return
+		}
+		if _, err := h.saveFile(ctx, parent.nodeID, p.name(), []byte{}, detectContentType(p.name(), nil), ""); err != nil {
+			writeDavErr(w, err)
+			return
+		}
+		if res, err = h.resolve(ctx, p); err != nil || res == nil {
+			http.Error(w, "failed to find the created file", http.StatusInternalServerError)
+			return
+		}
+		status = http.StatusCreated
+	} else if err := requireNodePermission(ctx, h.db, res.nodeID, PERM_MODIFY, "cannot modify this node"); err != nil {
+		writeDavErr(w, err)
+		return
+	}
+	lock.href = res.href()
+
+	if !h.locks.create(lock) {
+		http.Error(w, "resource is locked", http.StatusLocked)
+		return
+	}
+	log.Printf("WebDAV lock %s taken on %s", lock.token, lock.href)
+	w.Header().Set("Lock-Token", "<"+lock.token+">")
+	writeDavXML(w, status, davLockResponse{XmlnsD: "DAV:", LockDiscovery: davLockDiscovery{Locks: []davActiveLock{lock.activeLock()}}})
+}
+
+// handleUnlock släpper ett lås som anges i Lock-Token
+func (h *davHandler) handleUnlock(w http.ResponseWriter, r *http.Request, userID string, p davPath) {
+	token := strings.Trim(strings.TrimSpace(r.Header.Get("Lock-Token")), "<>")
+	if token == "" {
+		http.Error(w, "missing Lock-Token header", http.StatusBadRequest)
+		return
+	}
+	if !h.locks.unlock(p.key(), userID, token) {
+		writeDavError(w, http.StatusConflict, "<D:lock-token-matches-request-uri/>")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// davLockTimeout läser Timeout-headern, t.ex. "Second-600" eller "Infinite, Second-3600". Längre
+// tider än davLockMaxTimeout kortas av.
+func davLockTimeout(header string) time.Duration {
+	for _, value := range strings.Split(header, ",") {
+		value = strings.TrimSpace(value)
+		if value == "Infinite" {
+			return davLockMaxTimeout
+		}
+		if seconds, err := strconv.Atoi(strings.TrimPrefix(value, "Second-")); err == nil && seconds > 0 {
+			timeout := time.Duration(seconds) * time.Second
+			if timeout > davLockMaxTimeout {
+				return davLockMaxTimeout
+			}
+			return timeout
+		}
+	}
+	return davLockDefaultTimeout
+}
+
+// ---------- XML och fel ----------
+
+// Elementnamnen har DAV:-prefixet D, som deklareras på rotelementet
+
+type davMultistatus struct {
+	XMLName   xml.Name      `xml:"D:multistatus"`
+	XmlnsD    string        `xml:"xmlns:D,attr"`
+	Responses []davResponse `xml:"D:response"`
+}
+
+type davResponse struct {
+	Href     string       `xml:"D:href"`
+	Propstat *davPropstat `xml:"D:propstat,omitempty"`
+	Status   string       `xml:"D:status,omitempty"`
+	Error    string       `xml:"D:responsedescription,omitempty"`
+}
+
+type davPropstat struct {
+	Prop   davProp `xml:"D:prop"`
+	Status string  `xml:"D:status"`
+}
+
+type davProp struct {
+	DisplayName   string           `xml:"D:displayname,omitempty"`
+	ResourceType  davResourceType  `xml:"D:resourcetype"`
+	CreationDate  string           `xml:"D:creationdate,omitempty"`
+	LastModified  string           `xml:"D:getlastmodified,omitempty"`
+	ContentLength *int             `xml:"D:getcontentlength,omitempty"`
+	ContentType   string           `xml:"D:getcontenttype,omitempty"`
+	ETag          string           `xml:"D:getetag,omitempty"`
+	SupportedLock davSupportedLock `xml:"D:supportedlock"`
+	LockDiscovery davLockDiscovery `xml:"D:lockdiscovery"`
+}
+
+type davResourceType struct {
+	Collection *struct{} `xml:"D:collection,omitempty"`
+}
+
+type davSupportedLock struct {
+	Entry davLockEntry `xml:"D:lockentry"`
+}
+
+type davLockEntry struct {
+	Scope davLockScope `xml:"D:lockscope"`
+	Type  davLockType  `xml:"D:locktype"`
+}
+
+type davLockScope struct {
+	Exclusive struct{} `xml:"D:exclusive"`
+}
+
+type davLockType struct {
+	Write struct{} `xml:"D:write"`
+}
+
+type davLockDiscovery struct {
+	Locks []davActiveLock `xml:"D:activelock"`
+}
+
+type davActiveLock struct {
+	Type    davLockType  `xml:"D:locktype"`
+	Scope   davLockScope `xml:"D:lockscope"`
+	Depth   string       `xml:"D:depth"`
+	Owner   *davOwner    `xml:"D:owner,omitempty"`
+	Timeout string       `xml:"D:timeout"`
+	Token   davHref      `xml:"D:locktoken"`
+	Root    davHref      `xml:"D:lockroot"`
+}
+
+type davOwner struct {
+	InnerXML string `xml:",innerxml"`
+}
+
+type davHref struct {
+	Href string `xml:"D:href"`
+}
+
+type davLockResponse struct {
+	XMLName       xml.Name         `xml:"D:prop"`
+	XmlnsD        string           `xml:"xmlns:D,attr"`
+	LockDiscovery davLockDiscovery `xml:"D:lockdiscovery"`
+}
+
+type davErrorBody struct {
+	XMLName   xml.Name `xml:"D:error"`
+	XmlnsD    string   `xml:"xmlns:D,attr"`
+	Condition string   `xml:",innerxml"`
+}
+
+// readDavBody läser ett XML-innehåll till v. Saknas innehåll returneras io.EOF.
+func readDavBody(w http.ResponseWriter, r *http.Request, v interface{}) error {
+	data, err := readDavLimited(w, r, davMaxXMLBodySize)
+	if err != nil {
+		return err
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return io.EOF
+	}
+	if v == nil {
+		v = &struct{}{}
+	}
+	if err := xml.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("invalid XML in request body: %v", err)
+	}
+	return nil
+}
+
+// readDavLimited läser anropets kropp, som får vara högst limit byte
+func readDavLimited(w http.ResponseWriter, r *http.Request, limit int64) ([]byte, error) {
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, limit))
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return nil, errDavBodyTooLarge
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read request body: %v", err)
+	}
+	return data, nil
+}
+
+// writeDavBodyErr svarar på ett fel från readDavBody eller readDavLimited
+func writeDavBodyErr(w http.ResponseWriter, err error) {
+	status := http.StatusBadRequest
+	if errors.Is(err, errDavBodyTooLarge) {
+		status = http.StatusRequestEntityTooLarge
+	}
+	http.Error(w, err.Error(), status)
+}
+
+// writeDavXML skriver ett XML-svar
+func writeDavXML(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", `application/xml; charset="utf-8"`)
+	w.WriteHeader(status)
+	io.WriteString(w, xml.Header)
+	if err := xml.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Error writing WebDAV response: %v", err)
+	}
+}
+
+// writeDavError svarar med ett fel och det villkor i RFC 4918 som inte uppfylldes
+func writeDavError(w http.ResponseWriter, status int, condition string) {
+	writeDavXML(w, status, davErrorBody{XmlnsD: "DAV:", Condition: condition})
+}
+
+// writeDavFailures rapporterar resurser som inte kunde tas bort eller kopieras. Gäller felet
+// bara resursen som anropet avsåg svaras med dess status direkt.
+func writeDavFailures(w http.ResponseWriter, res *davResource, failures []davFailure) {
+	if len(failures) == 1 && failures[0].href == res.href() {
+		http.Error(w, resolverErrorMessage(failures[0].err), failures[0].status)
+		return
+	}
+	multistatus := davMultistatus{XmlnsD: "DAV:"}
+	for _, failure := range failures {
+		multistatus.Responses = append(multistatus.Responses, davResponse{
+			Href:   failure.href,
+			Status: davStatus(failure.status),
+			Error:  resolverErrorMessage(failure.err),
+		})
+	}
+	writeDavXML(w, http.StatusMultiStatus, multistatus)
+}
+
+// writeDavErr svarar med felet från en resolver
+func writeDavErr(w http.ResponseWriter, err error) {
+	http.Error(w, resolverErrorMessage(err), davErrorStatus(err))
+}
+
+// davErrorStatus väljer HTTP-status för ett fel från resolvers. Fel som inte beror på servern,
+// t.ex. tilbakehold, placeringsregler och formatkrav, ger 403.
+func davErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, errPermissionDenied):
+		return http.StatusForbidden
+	case errors.Is(err, errNotAuthenticated):
+		return http.StatusUnauthorized
+	case errors.Is(err, errNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, errInternal):
+		return http.StatusInternalServerError
+	default:
+		return http.StatusForbidden
+	}
+}
+
+func davStatus(status int) string {
+	return fmt.Sprintf("HTTP/1.1 %d %s", status, http.StatusText(status))
+}
@@ -0,0 +1,191 @@
+package graph
+
+import (
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// davTestLockBody är innehållet i en LOCK-förfrågan för ett exklusivt skrivlås
+const davTestLockBody = `<?xml version="1.0" encoding="utf-8"?>
+<D:lockinfo xmlns:D="DAV:"><D:lockscope><D:exclusive/></D:lockscope><D:locktype><D:write/></D:locktype><D:owner>test</D:owner></D:lockinfo>`
+
+// davTestEnv är en WebDAV-server med ett arkiv som administratören äger
+type davTestEnv struct {
+	db      *sql.DB
+	handler http.Handler
+	admin   string
+}
+
+func newDavTestEnv(t *testing.T) *davTestEnv {
+	t.Helper()
+	db := openTestDB(t)
+	admin, err := generateJWT("1", "admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &davTestEnv{db: db, handler: withTestBearerToken(WebDAVHandler(db)), admin: admin}
+}
+
+// request skickar ett WebDAV-anrop med token som Bearer-token. headers anges som namn och värde
+// i par.
+func (env *davTestEnv) request(t *testing.T, token string, method string, path string, body string, headers ...string) *httptest.ResponseRecorder {
+	t.Helper()
+	request := httptest.NewRequest(method, WebDAVPath+path, strings.NewReader(body))
+	if token != "" {
+		request.Header.Set("Authorization", "Bearer "+token)
+	}
+	for i := 0; i+1 < len(headers); i += 2 {
+		request.Header.Set(headers[i], headers[i+1])
+	}
+	recorder := httptest.NewRecorder()
+	env.handler.ServeHTTP(recorder, request)
+	return recorder
+}
+
+// expect skickar ett anrop och kontrollerar svarets status
+func (env *davTestEnv) expect(t *testing.T, status int, token string, method string, path string, body string, headers ...string) *httptest.ResponseRecorder {
+	t.Helper()
+	recorder := env.request(t, token, method, path, body, headers...)
+	if recorder.Code != status {
+		t.Errorf("%s %s: status %d, want %d (body %q)", method, path, recorder.Code, status, strings.TrimSpace(recorder.Body.String()))
+	}
+	return recorder
+}
+
+func TestWebDAVPropfind(t *testing.T) {
+	env := newDavTestEnv(t)
+	root := createTestNode(t, env.db, "Arkiv", nil)
+	createTestNode(t, env.db, "Underlag", &root.ID)
+	saveTestFile(t, env.db, "brev.txt", root.ID, "brevet")
+
+	// Depth: 0 ger bara resursen själv
+	single := env.expect(t, http.StatusMultiStatus, env.admin, "PROPFIND", "Arkiv", "", "Depth", "0")
+	if count := strings.Count(single.Body.String(), "<D:response>"); count != 1 {
+		t.Errorf("depth 0 listed %d resources, want 1", count)
+	}
+
+	// Depth: 1 ger även barnnoder och filer
+	listing := env.expect(t, http.StatusMultiStatus, env.admin, "PROPFIND", "Arkiv", "", "Depth", "1")
+	if count := strings.Count(listing.Body.String(), "<D:response>"); count != 3 {
+		t.Errorf("depth 1 listed %d resources, want 3", count)
+	}
+	for _, href := range []string{WebDAVPath + "Arkiv/Underlag/", WebDAVPath + "Arkiv/brev.txt"} {
+		if !strings.Contains(listing.Body.String(), "<D:href>"+href+"</D:href>") {
+			t.Errorf("depth 1 listing lacks %s", href)
+		}
+	}
+
+	env.expect(t, http.StatusForbidden, env.admin, "PROPFIND", "Arkiv", "", "Depth", "infinity")
+	env.expect(t, http.StatusNotFound, env.admin, "PROPFIND", "Saknas", "", "Depth", "0")
+
+	// XML-innehållet är begränsat
+	env.expect(t, http.StatusRequestEntityTooLarge, env.admin, "PROPFIND", "Arkiv", strings.Repeat(" ", davMaxXMLBodySize+1), "Depth", "0")
+}
+
+func TestWebDAVReadAndWrite(t *testing.T) {
+	env := newDavTestEnv(t)
+	createTestNode(t, env.db, "Arkiv", nil)
+
+	env.expect(t, http.StatusCreated, env.admin, http.MethodPut, "Arkiv/ny.txt", "första", "Content-Type", "text/plain")
+	if got := env.expect(t, http.StatusOK, env.admin, http.MethodGet, "Arkiv/ny.txt", "").Body.String(); got != "första" {
+		t.Errorf("GET after PUT = %q, want första", got)
+	}
+
+	// En befintlig fil ersätts av en ny fil med det nya innehållet
+	env.expect(t, http.StatusNoContent, env.admin, http.MethodPut, "Arkiv/ny.txt", "andra", "Content-Type", "text/plain")
+	if got := env.expect(t, http.StatusOK, env.admin, http.MethodGet, "Arkiv/ny.txt", "").Body.String(); got != "andra" {
+		t.Errorf("GET after replacing PUT = %q, want andra", got)
+	}
+
+	env.expect(t, http.StatusCreated, env.admin, "MKCOL", "Arkiv/Mapp", "")
+	env.expect(t, http.StatusMethodNotAllowed, env.admin, "MKCOL", "Arkiv/Mapp", "")
+	env.expect(t, http.StatusConflict, env.admin, "MKCOL", "Saknas/Mapp", "")
+
+	env.expect(t, http.StatusCreated, env.admin, "MOVE", "Arkiv/ny.txt", "", "Destination", WebDAVPath+"Arkiv/Mapp/flyttad.txt")
+	env.expect(t, http.StatusNotFound, env.admin, http.MethodGet, "Arkiv/ny.txt", "")
+	env.expect(t, http.StatusCreated, env.admin, "COPY", "Arkiv/Mapp/flyttad.txt", "", "Destination", WebDAVPath+"Arkiv/kopia.txt")
+	for _, path := range []string{"Arkiv/Mapp/flyttad.txt", "Arkiv/kopia.txt"} {
+		if got := env.expect(t, http.StatusOK, env.admin, http.MethodGet, path, "").Body.String(); got != "andra" {
+			t.Errorf("GET %s = %q, want andra", path, got)
+		}
+	}
+
+	// DELETE på en nod tar bort dess filer och barnnoder
+	env.expect(t, http.StatusNoContent, env.admin, http.MethodDelete, "Arkiv/Mapp", "")
+	env.expect(t, http.StatusNotFound, env.admin, "PROPFIND", "Arkiv/Mapp", "", "Depth", "0")
+	env.expect(t, http.StatusNoContent, env.admin, http.MethodDelete, "Arkiv/kopia.txt", "")
+	env.expect(t, http.StatusNotFound, env.admin, http.MethodGet, "Arkiv/kopia.txt", "")
+}
+
+func TestWebDAVLock(t *testing.T) {
+	env := newDavTestEnv(t)
+	root := createTestNode(t, env.db, "Arkiv", nil)
+	saveTestFile(t, env.db, "brev.txt", root.ID, "brevet")
+
+	locked := env.expect(t, http.StatusOK, env.admin, "LOCK", "Arkiv/brev.txt", davTestLockBody, "Timeout", "Second-60")
+	token := locked.Header().Get("Lock-Token")
+	if !strings.HasPrefix(token, "<opaquelocktoken:") {
+		t.Fatalf("Lock-Token = %q", token)
+	}
+
+	// Den låsta filen kan bara ändras med låstoken i If-headern
+	env.expect(t, http.StatusLocked, env.admin, http.MethodPut, "Arkiv/brev.txt", "utan lås")
+	env.expect(t, http.StatusLocked, env.admin, "LOCK", "Arkiv/brev.txt", davTestLockBody)
+	env.expect(t, http.StatusNoContent, env.admin, http.MethodPut, "Arkiv/brev.txt", "med lås", "If", "("+token+")")
+
+	env.expect(t, http.StatusNoContent, env.admin, "UNLOCK", "Arkiv/brev.txt", "", "Lock-Token", token)
+	env.expect(t, http.StatusConflict, env.admin, "UNLOCK", "Arkiv/brev.txt", "", "Lock-Token", token)
+	env.expect(t, http.StatusNoContent, env.admin, http.MethodPut, "Arkiv/brev.txt", "upplåst")
+
+	// Ett lås på en sökväg utan fil skapar en tom fil
+	env.expect(t, http.StatusCreated, env.admin, "LOCK", "Arkiv/ny.txt", davTestLockBody)
+	if got := env.expect(t, http.StatusOK, env.admin, http.MethodGet, "Arkiv/ny.txt", ""); got.Body.Len() != 0 {
+		t.Errorf("locked new file has content %q", got.Body.String())
+	}
+}
+
+func TestWebDAVRequiresPermission(t *testing.T) {
+	env := newDavTestEnv(t)
+	services := NewServices(env.db)
+	bobID := insertTestUser(t, env.db, "bob")
+	bob, err := generateJWT(bobID, "bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Bob får läsa i Läsbar men inte se Hemlig
+	readable := createTestNode(t, env.db, "Läsbar", nil)
+	if _, err := services.Nodes.SetOwnership(testAdminContext(t), readable.ID, &bobID, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := services.Nodes.SetPermissions(testAdminContext(t), readable.ID, PERM_VIEW); err != nil {
+		t.Fatal(err)
+	}
+	secret := createTestNode(t, env.db, "Hemlig", nil)
+	saveTestFile(t, env.db, "läsbar.txt", readable.ID, "läsbar")
+	saveTestFile(t, env.db, "hemlig.txt", secret.ID, "hemlig")
+
+	env.expect(t, http.StatusUnauthorized, "", "PROPFIND", "", "", "Depth", "1")
+	env.expect(t, http.StatusUnauthorized, "", http.MethodGet, "L%C3%A4sbar/l%C3%A4sbar.txt", "")
+
+	env.expect(t, http.StatusOK, bob, http.MethodGet, "L%C3%A4sbar/l%C3%A4sbar.txt", "")
+	env.expect(t, http.StatusNotFound, bob, http.MethodGet, "Hemlig/hemlig.txt", "")
+	if listing := env.expect(t, http.StatusMultiStatus, bob, "PROPFIND", "", "", "Depth", "1"); strings.Contains(listing.Body.String(), "Hemlig") {
+		t.Error("root listing shows a node bob cannot view")
+	}
+
+	// Läsbehörighet räcker inte för att ändra
+	env.expect(t, http.StatusForbidden, bob, http.MethodPut, "L%C3%A4sbar/ny.txt", "ny")
+	env.expect(t, http.StatusForbidden, bob, http.MethodPut, "L%C3%A4sbar/l%C3%A4sbar.txt", "ändrad")
+	env.expect(t, http.StatusForbidden, bob, "MKCOL", "L%C3%A4sbar/Mapp", "")
+	env.expect(t, http.StatusForbidden, bob, http.MethodDelete, "L%C3%A4sbar/l%C3%A4sbar.txt", "")
+	env.expect(t, http.StatusForbidden, bob, "MOVE", "L%C3%A4sbar/l%C3%A4sbar.txt", "", "Destination", WebDAVPath+"L%C3%A4sbar/flyttad.txt")
+	env.expect(t, http.StatusForbidden, bob, "LOCK", "L%C3%A4sbar/l%C3%A4sbar.txt", davTestLockBody)
+
+	if got := env.expect(t, http.StatusOK, env.admin, http.MethodGet, "L%C3%A4sbar/l%C3%A4sbar.txt", "").Body.String(); got != "läsbar" {
+		t.Errorf("refused changes altered the file: %q", got)
+	}
+}
@@ -0,0 +1,654 @@
+package graph
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"graphql-backend/graph/model"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// =============================================
+// ========== WEBHOOKS =======================
+// =============================================
+
+// Headers som skickas med varje leverans. Signaturen är HMAC-SHA256 över kroppen med webhookens
+// hemlighet, hexkodad och med prefixet "sha256=".
+const (
+	WebhookEventHeader     = "X-Earkive-Event"
+	WebhookDeliveryHeader  = "X-Earkive-Delivery"
+	WebhookSignatureHeader = "X-Earkive-Signature"
+)
+
+// Inställningar för leveranser
+const (
+	webhookMaxAttempts   = 6
+	webhookPollInterval  = time.Second
+	webhookTimeout       = 10 * time.Second
+	webhookResponseLimit = 1024 // Så mycket av mottagarens svar som sparas i leveransloggen
+	webhookSecretBytes   = 32
+	webhookUserAgent     = "e-Arkive-Webhook/1.0"
+	webhookEventTypeSep  = ","
+)
+
+// Fördröjningen före första omförsöket, som fördubblas för varje försök upp till maxfördröjningen
+var (
+	webhookRetryBaseDelay = 30 * time.Second
+	webhookRetryMaxDelay  = time.Hour
+)
+
+// SetWebhookRetryDelays anger fördröjningen före första omförsöket och den längsta fördröjningen.
+// Anropas en gång vid start.
+func SetWebhookRetryDelays(baseDelay time.Duration, maxDelay time.Duration) {
+	webhookRetryBaseDelay = baseDelay
+	webhookRetryMaxDelay = maxDelay
+}
+
+// Kolumnerna som scanWebhookDeliveries läser, i ordning
+const webhookDeliveryColumns = `id, webhook_id, event_type, payload, status, attempts, max_attempts, next_attempt_at,
+	response_status, response_body, error, created_at, delivered_at`
+
+// webhookClient skickar leveranserna; tidsgränsen hindrar att en långsam mottagare blockerar kön
+var webhookClient = newWebhookClient()
+
+// newWebhookClient skapar klienten för leveranser. Adressen som värdnamnet slås upp till
+// kontrolleras vid varje anslutning, även efter omdirigeringar, så att ett namn som pekar på det
+// interna nätet inte kan användas för att nå det. Leveranser går därför aldrig via en proxy.
+func newWebhookClient() *http.Client {
+	dialer := &net.Dialer{Timeout: webhookTimeout, Control: controlWebhookDial}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{Timeout: webhookTimeout, Transport: transport}
+}
+
+// controlWebhookDial avbryter anslutningar till adresser som webhooks inte får nå
+func controlWebhookDial(network string, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return fmt.Errorf("invalid webhook address %s: %v", address, err)
+	}
+	if ip := net.ParseIP(host); ip == nil || !webhookTargetAllowed(ip) {
+		return fmt.Errorf("webhook target %s is a loopback, link-local or private address", host)
+	}
+	return nil
+}
+
+// webhookTargetAllowed svarar på om en webhook får skickas till adressen. Servern själv och det
+// interna nätet får inte nås, så loopback-, länklokala, privata, ospecificerade och
+// multicastadresser avvisas.
+func webhookTargetAllowed(ip net.IP) bool {
+	return !ip.IsLoopback() && !ip.IsLinkLocalUnicast() && !ip.IsLinkLocalMulticast() &&
+		!ip.IsPrivate() && !ip.IsUnspecified() && !ip.IsMulticast()
+}
+
+// webhookWake väcker leveransarbetaren när nya leveranser har lagts i kön
+var webhookWake = make(chan struct{}, 1)
+
+// webhookPayload är JSON-kroppen som skickas till mottagaren
+type webhookPayload struct {
+	EventID    string                 `json:"eventId"`
+	Type       model.WebhookEventType `json:"type"`
+	OccurredAt string                 `json:"occurredAt"`
+	WebhookID  string                 `json:"webhookId"`
+	Action     string                 `json:"action,omitempty"`
+	ActorID    string                 `json:"actorId,omitempty"`
+	NodeID     string                 `json:"nodeId,omitempty"`
+	FileID     string                 `json:"fileId,omitempty"`
+	// Filens eller nodens rad med metadata efter ändringen, eller före om den tagits bort
+	Data json.RawMessage `json:"data,omitempty"`
+}
+
+// webhookEvent är en händelse i arkivet som ska skickas till de webhooks som bevakar den
+type webhookEvent struct {
+	eventType model.WebhookEventType
+	nodeID    string // Noden som ändrats, eller noden som filen ligger i
+	fileID    string
+	action    string
+	actorID   string
+	data      string
+	// Noder vars förfäder avgör om händelsen ligger inom en webhooks omfång, t.ex. både den
+	// gamla och den nya föräldern vid en flytt
+	locations []string
+}
+
+// queueChangeWebhooks lägger leveranser i kön för en nod eller fil som har skapats, ändrats,
+// flyttats eller tagits bort
+func queueChangeWebhooks(db *sql.DB, targetType string, targetID string, before changeTarget, after changeTarget, action string, actorID string) {
+	created, deleted := model.WebhookEventTypeNodeCreated, model.WebhookEventTypeNodeDeleted
+	updated, moved := model.WebhookEventTypeNodeUpdated, model.WebhookEventTypeNodeMoved
+	if targetType == "file" {
+		created, deleted = model.WebhookEventTypeFileCreated, model.WebhookEventTypeFileDeleted
+		updated, moved = model.WebhookEventTypeFileUpdated, model.WebhookEventTypeFileMoved
+	}
+
+	var eventTypes []model.WebhookEventType
+	switch {
+	case !before.exists && after.exists:
+		eventTypes = append(eventTypes, created)
+	case before.exists && !after.exists:
+		eventTypes = append(eventTypes, deleted)
+	case before.exists && after.exists:
+		if before.parentID != after.parentID {
+			eventTypes = append(eventTypes, moved)
+		} else if before.fields != after.fields {
+			eventTypes = append(eventTypes, updated)
+		}
+		if before.metadata != after.metadata {
+			eventTypes = append(eventTypes, model.WebhookEventTypeMetadataChanged)
+		}
+	}
+
+	current := after
+	if !after.exists {
+		current = before
+	}
+	event := webhookEvent{
+		action:    action,
+		actorID:   actorID,
+		data:      current.snapshot,
+		locations: []string{before.parentID, after.parentID},
+	}
+	if targetType == "file" {
+		event.nodeID = current.parentID
+		event.fileID = targetID
+	} else {
+		event.nodeID = targetID
+	}
+
+	for _, eventType := range eventTypes {
+		event.eventType = eventType
+		queueWebhookEvent(db, event)
+	}
+}
+
+// queueWebhookEvent lägger en leverans i kön för varje aktiv webhook som bevakar händelsen.
+// Fel loggas men stoppar inte ändringen som orsakade händelsen.
+func queueWebhookEvent(db *sql.DB, event webhookEvent) {
+	webhooks, err := queryWebhooks(db, "active = 1")
+	if err != nil || len(webhooks) == 0 {
+		return
+	}
+
+	var scope map[string]bool
+	payload := webhookPayload{
+		EventID:    uuid.New().String(),
+		Type:       event.eventType,
+		OccurredAt: time.Now().Format(time.RFC3339),
+		Action:     event.action,
+		ActorID:    event.actorID,
+		NodeID:     event.nodeID,
+		FileID:     event.fileID,
+	}
+	if event.data != "" {
+		payload.Data = json.RawMessage(event.data)
+	}
+
+	queued := 0
+	for _, webhook := range webhooks {
+		if !webhookHasEventType(webhook, event.eventType) {
+			continue
+		}
+		if webhook.NodeID != nil {
+			if scope == nil {
+				scope = webhookEventScope(db, event)
+			}
+			if !scope[*webhook.NodeID] {
+				continue
+			}
+		}
+
+		payload.WebhookID = webhook.ID
+		if _, err := insertWebhookDelivery(db, webhook.ID, payload, model.WebhookDeliveryStatusPending, webhookMaxAttempts); err != nil {
+			log.Printf("Error queueing %s delivery for webhook %s: %v", event.eventType, webhook.ID, err)
+			continue
+		}
+		queued++
+	}
+
+	if queued > 0 {
+		select {
+		case webhookWake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// webhookEventScope returnerar noderna som en händelse ligger under: noden själv och alla
+// förfäder till händelsens platser
+func webhookEventScope(db *sql.DB, event webhookEvent) map[string]bool {
+	scope := map[string]bool{event.nodeID: true}
+	for _, location := range append(event.locations, event.nodeID) {
+		ancestors, err := nodeAncestors(db, location)
+		if err != nil {
+			continue
+		}
+		for _, id := range ancestors {
+			scope[id] = true
+		}
+	}
+	return scope
+}
+
+// nodeAncestors returnerar noden och alla dess förfäder, närmast först
+func nodeAncestors(db *sql.DB, nodeID string) ([]string, error) {
+	var ancestors []string
+	visited := make(map[string]bool)
+	for currentID := nodeID; currentID != "" && !visited[currentID]; {
+		visited[currentID] = true
+
+		var parentID sql.NullString
+		err := db.QueryRow("SELECT parent_id FROM nodes WHERE id = ?", currentID).Scan(&parentID)
+		if err == sql.ErrNoRows {
+			break
+		} else if err != nil {
+			log.Printf("Error fetching parent of node %s: %v", currentID, err)
+			return nil, serviceErrorf(errInternal, "failed to fetch parent node: %v", err)
+		}
+		ancestors = append(ancestors, currentID)
+		currentID = parentID.String
+	}
+	return ancestors, nil
+}
+
+// webhookHasEventType avgör om en webhook bevakar en händelsetyp
+func webhookHasEventType(webhook *model.Webhook, eventType model.WebhookEventType) bool {
+	for _, t := range webhook.EventTypes {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// =============================================
+// ========== LEVERANSER =====================
+// =============================================
+
+// StartWebhookWorker startar arbetaren som skickar webhookleveranser. Leveranser som höll på att
+// skickas när servern stoppades skickas igen.
+func StartWebhookWorker(db *sql.DB) {
+	_, err := db.Exec(
+		"UPDATE webhook_deliveries SET status = ?, next_attempt_at = ? WHERE status = ?",
+		model.WebhookDeliveryStatusPending, time.Now().UTC().Format(time.RFC3339), model.WebhookDeliveryStatusSending,
+	)
+	if err != nil {
+		log.Printf("Error recovering interrupted webhook deliveries: %v", err)
+	}
+
+	go func() {
+		for {
+			if _, err := processWebhookDeliveries(db, webhookClient); err != nil {
+				log.Printf("Error processing webhook deliveries: %v", err)
+			}
+			select {
+			case <-webhookWake:
+			case <-time.After(webhookPollInterval):
+			}
+		}
+	}()
+	log.Printf("Started webhook delivery worker")
+}
+
+// processWebhookDeliveries skickar alla leveranser som är redo och returnerar hur många som skickades
+func processWebhookDeliveries(db *sql.DB, client *http.Client) (int, error) {
+	sent := 0
+	for {
+		delivery, err := claimWebhookDelivery(db)
+		if err != nil {
+			return sent, err
+		}
+		if delivery == nil {
+			return sent, nil
+		}
+		deliverWebhook(db, client, delivery)
+		sent++
+	}
+}
+
+// claimWebhookDelivery markerar den äldsta leveransen som är redo som under sändning och
+// returnerar den, eller nil om ingen leverans väntar
+func claimWebhookDelivery(db *sql.DB) (*model.WebhookDelivery, error) {
+	deliveries, err := scanWebhookDeliveries(db.Query(`
+		UPDATE webhook_deliveries SET status = ?, attempts = attempts + 1
+		WHERE id = (
+			SELECT id FROM webhook_deliveries
+			WHERE status = ? AND (next_attempt_at IS NULL OR next_attempt_at <= ?)
+			ORDER BY id ASC LIMIT 1
+		) AND status = ?
+		RETURNING `+webhookDeliveryColumns,
+		model.WebhookDeliveryStatusSending, model.WebhookDeliveryStatusPending,
+		time.Now().UTC().Format(time.RFC3339), model.WebhookDeliveryStatusPending,
+	))
+	if err != nil {
+		return nil, serviceErrorf(errInternal, "failed to claim webhook delivery: %v", err)
+	}
+	if len(deliveries) == 0 {
+		return nil, nil
+	}
+	return deliveries[0], nil
+}
+
+// deliverWebhook gör ett försök att skicka en leverans och sparar utfallet. Ett misslyckat försök
+// görs om med en fördröjning som fördubblas för varje försök tills försöken är slut.
+func deliverWebhook(db *sql.DB, client *http.Client, delivery *model.WebhookDelivery) {
+	var targetURL, secret string
+	var active bool
+	err := db.QueryRow("SELECT url, secret, active FROM webhooks WHERE id = ?", delivery.WebhookID).Scan(&targetURL, &secret, &active)
+	if err == sql.ErrNoRows {
+		err = fmt.Errorf("webhook has been deleted")
+	} else if err == nil && !active && delivery.EventType != model.WebhookEventTypeTest {
+		err = fmt.Errorf("webhook is inactive")
+	}
+	if err != nil {
+		// Utan en aktiv webhook finns det inget att försöka igen med
+		delivery.MaxAttempts = delivery.Attempts
+		recordWebhookAttempt(db, delivery, 0, "", err)
+		return
+	}
+
+	status, body, err := sendWebhook(client, targetURL, secret, delivery)
+	recordWebhookAttempt(db, delivery, status, body, err)
+}
+
+// sendWebhook skickar leveransens kropp signerad med hemligheten. Ett svar utanför 2xx är ett fel.
+func sendWebhook(client *http.Client, targetURL string, secret string, delivery *model.WebhookDelivery) (int, string, error) {
+	body := []byte(delivery.Payload)
+	req, err := http.NewRequest(http.MethodPost, targetURL, bytes.NewReader(body))
+	if err != nil {
+		return 0, "", fmt.Errorf("invalid webhook request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", webhookUserAgent)
+	req.Header.Set(WebhookEventHeader, string(delivery.EventType))
+	req.Header.Set(WebhookDeliveryHeader, delivery.ID)
+	req.Header.Set(WebhookSignatureHeader, SignWebhookPayload(secret, body))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, "", fmt.Errorf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	responseBody, _ := io.ReadAll(io.LimitReader(resp.Body, webhookResponseLimit))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, string(responseBody), fmt.Errorf("receiver responded with %s", resp.Status)
+	}
+	return resp.StatusCode, string(responseBody), nil
+}
+
+// SignWebhookPayload beräknar signaturen som skickas i X-Earkive-Signature. Mottagaren räknar
+// fram samma värde över den mottagna kroppen och jämför.
+func SignWebhookPayload(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// recordWebhookAttempt sparar utfallet av ett försök i leveransloggen
+func recordWebhookAttempt(db *sql.DB, delivery *model.WebhookDelivery, responseStatus int, responseBody string, sendErr error) {
+	now := time.Now()
+	status := nullIfZero(responseStatus)
+	var err error
+
+	switch {
+	case sendErr == nil:
+		_, err = db.Exec(
+			"UPDATE webhook_deliveries SET status = ?, response_status = ?, response_body = ?, error = NULL, next_attempt_at = NULL, delivered_at = ? WHERE id = ?",
+			model.WebhookDeliveryStatusDelivered, status, nullIfEmpty(responseBody), now.Format(time.RFC3339), delivery.ID,
+		)
+	case delivery.Attempts < delivery.MaxAttempts:
+		delay := webhookRetryBaseDelay << (delivery.Attempts - 1)
+		if delay > webhookRetryMaxDelay || delay <= 0 {
+			delay = webhookRetryMaxDelay
+		}
+		log.Printf("Webhook delivery %s failed on attempt %d of %d, retrying in %s: %v", delivery.ID, delivery.Attempts, delivery.MaxAttempts, delay, sendErr)
+		_, err = db.Exec(
+			"UPDATE webhook_deliveries SET status = ?, response_status = ?, response_body = ?, error = ?, next_attempt_at = ? WHERE id = ?",
+			model.WebhookDeliveryStatusPending, status, nullIfEmpty(responseBody), sendErr.Error(), now.Add(delay).UTC().Format(time.RFC3339), delivery.ID,
+		)
+	default:
+		log.Printf("Webhook delivery %s failed: %v", delivery.ID, sendErr)
+		_, err = db.Exec(
+			"UPDATE webhook_deliveries SET status = ?, response_status = ?, response_body = ?, error = ?, next_attempt_at = NULL WHERE id = ?",
+			model.WebhookDeliveryStatusFailed, status, nullIfEmpty(responseBody), sendErr.Error(), delivery.ID,
+		)
+	}
+
+	if err != nil {
+		log.Printf("Error saving outcome of webhook delivery %s: %v", delivery.ID, err)
+	}
+}
+
+// testWebhookDelivery skickar en TEST-händelse direkt till en webhook, även om den är inaktiv,
+// och returnerar leveransen med utfallet
+func testWebhookDelivery(db *sql.DB, client *http.Client, webhookID string, actorID string) (*model.WebhookDelivery, error) {
+	payload := webhookPayload{
+		EventID:    uuid.New().String(),
+		Type:       model.WebhookEventTypeTest,
+		OccurredAt: time.Now().Format(time.RFC3339),
+		WebhookID:  webhookID,
+		Action:     "testWebhook",
+		ActorID:    actorID,
+	}
+
+	// Leveransen läggs in som under sändning så att arbetaren inte också tar den
+	id, err := insertWebhookDelivery(db, webhookID, payload, model.WebhookDeliveryStatusSending, 1)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec("UPDATE webhook_deliveries SET attempts = 1 WHERE id = ?", id); err != nil {
+		log.Printf("Error updating webhook delivery %s: %v", id, err)
+		return nil, serviceErrorf(errInternal, "failed to update webhook delivery: %v", err)
+	}
+
+	delivery, err := getWebhookDelivery(db, id)
+	if err != nil {
+		return nil, err
+	}
+	deliverWebhook(db, client, delivery)
+	return getWebhookDelivery(db, id)
+}
+
+// insertWebhookDelivery sparar en leverans i leveransloggen och returnerar dess ID
+func insertWebhookDelivery(db *sql.DB, webhookID string, payload webhookPayload, status model.WebhookDeliveryStatus, maxAttempts int) (string, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return "", serviceErrorf(errInternal, "failed to serialize webhook payload: %v", err)
+	}
+
+	now := time.Now()
+	result, err := db.Exec(
+		"INSERT INTO webhook_deliveries (webhook_id, event_type, payload, status, max_attempts, next_attempt_at, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		webhookID, payload.Type, string(body), status, maxAttempts, now.UTC().Format(time.RFC3339), now.Format(time.RFC3339),
+	)
+	if err != nil {
+		log.Printf("Error saving webhook delivery: %v", err)
+		return "", serviceErrorf(errInternal, "failed to save webhook delivery: %v", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return "", serviceErrorf(errInternal, "failed to retrieve webhook delivery ID: %v", err)
+	}
+	return strconv.FormatInt(id, 10), nil
+}
+
+// =============================================
+// ========== HANTERING AV WEBHOOKS ==========
+// =============================================
+
+// validateWebhookInput kontrollerar adressen, händelsetyperna och noden för en webhook
+func validateWebhookInput(db *sql.DB, input model.WebhookInput) error {
+	parsed, err := url.Parse(input.URL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return fmt.Errorf("webhook URL must be an absolute http or https URL")
+	}
+	// Namn som slås upp först vid leveransen kontrolleras av webhookClient
+	host := strings.ToLower(strings.TrimSuffix(parsed.Hostname(), "."))
+	if ip := net.ParseIP(host); host == "localhost" || strings.HasSuffix(host, ".localhost") || (ip != nil && !webhookTargetAllowed(ip)) {
+		return fmt.Errorf("webhook URL must not point to a loopback, link-local or private address")
+	}
+
+	if len(input.EventTypes) == 0 {
+		return fmt.Errorf("webhook must have at least one event type")
+	}
+	for _, eventType := range input.EventTypes {
+		if eventType == model.WebhookEventTypeTest {
+			return fmt.Errorf("TEST events are only sent by testWebhook")
+		}
+	}
+
+	if input.NodeID != nil {
+		var exists bool
+		if err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM nodes WHERE id = ?)", *input.NodeID).Scan(&exists); err != nil {
+			log.Printf("Error checking node %s: %v", *input.NodeID, err)
+			return serviceErrorf(errInternal, "failed to check node: %v", err)
+		}
+		if !exists {
+			return serviceErrorf(errNotFound, "node not found")
+		}
+	}
+	return nil
+}
+
+// generateWebhookSecret skapar en slumpmässig hemlighet för signaturen
+func generateWebhookSecret() (string, error) {
+	secret := make([]byte, webhookSecretBytes)
+	if _, err := rand.Read(secret); err != nil {
+		return "", serviceErrorf(errInternal, "failed to generate webhook secret: %v", err)
+	}
+	return hex.EncodeToString(secret), nil
+}
+
+// joinWebhookEventTypes och splitWebhookEventTypes översätter mellan händelsetyperna och kolumnen event_types
+func joinWebhookEventTypes(eventTypes []model.WebhookEventType) string {
+	values := make([]string, len(eventTypes))
+	for i, eventType := range eventTypes {
+		values[i] = string(eventType)
+	}
+	return strings.Join(values, webhookEventTypeSep)
+}
+
+func splitWebhookEventTypes(value string) []model.WebhookEventType {
+	eventTypes := []model.WebhookEventType{}
+	for _, eventType := range strings.Split(value, webhookEventTypeSep) {
+		if eventType != "" {
+			eventTypes = append(eventTypes, model.WebhookEventType(eventType))
+		}
+	}
+	return eventTypes
+}
+
+// getWebhook hämtar en webhook utan dess hemlighet
+func getWebhook(db *sql.DB, id string) (*model.Webhook, error) {
+	webhooks, err := queryWebhooks(db, "id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	if len(webhooks) == 0 {
+		return nil, serviceErrorf(errNotFound, "webhook not found")
+	}
+	return webhooks[0], nil
+}
+
+// queryWebhooks hämtar webhooks som matchar ett villkor, utan deras hemligheter
+func queryWebhooks(db *sql.DB, where string, args ...interface{}) ([]*model.Webhook, error) {
+	rows, err := db.Query(`
+		SELECT id, url, event_types, node_id, active, created_by, created_at, updated_at
+		FROM webhooks
+		WHERE `+where+`
+		ORDER BY id ASC
+	`, args...)
+	if err != nil {
+		log.Printf("Error fetching webhooks: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to fetch webhooks: %v", err)
+	}
+	defer rows.Close()
+
+	webhooks := []*model.Webhook{}
+	for rows.Next() {
+		var webhook model.Webhook
+		var eventTypes string
+		var nodeID, createdBy sql.NullString
+		if err := rows.Scan(&webhook.ID, &webhook.URL, &eventTypes, &nodeID, &webhook.Active,
+			&createdBy, &webhook.CreatedAt, &webhook.UpdatedAt); err != nil {
+			log.Printf("Error scanning webhook row: %v", err)
+			return nil, serviceErrorf(errInternal, "failed to scan webhook row: %v", err)
+		}
+		webhook.EventTypes = splitWebhookEventTypes(eventTypes)
+		webhook.NodeID = nullStringPtr(nodeID)
+		webhook.CreatedBy = nullStringPtr(createdBy)
+		webhooks = append(webhooks, &webhook)
+	}
+	return webhooks, rows.Err()
+}
+
+// getWebhookDelivery hämtar en leverans ur leveransloggen
+func getWebhookDelivery(db *sql.DB, id string) (*model.WebhookDelivery, error) {
+	deliveries, err := scanWebhookDeliveries(db.Query("SELECT "+webhookDeliveryColumns+" FROM webhook_deliveries WHERE id = ?", id))
+	if err != nil {
+		return nil, err
+	}
+	if len(deliveries) == 0 {
+		return nil, serviceErrorf(errNotFound, "webhook delivery not found")
+	}
+	return deliveries[0], nil
+}
+
+// scanWebhookDeliveries läser leveranser från resultatet av en fråga med webhookDeliveryColumns
+func scanWebhookDeliveries(rows *sql.Rows, err error) ([]*model.WebhookDelivery, error) {
+	if err != nil {
+		log.Printf("Error fetching webhook deliveries: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to fetch webhook deliveries: %v", err)
+	}
+	defer rows.Close()
+
+	deliveries := []*model.WebhookDelivery{}
+	for rows.Next() {
+		var delivery model.WebhookDelivery
+		var eventType, status string
+		var nextAttemptAt, responseBody, deliveryErr, deliveredAt sql.NullString
+		var responseStatus sql.NullInt64
+		if err := rows.Scan(&delivery.ID, &delivery.WebhookID, &eventType, &delivery.Payload, &status,
+			&delivery.Attempts, &delivery.MaxAttempts, &nextAttemptAt, &responseStatus, &responseBody,
+			&deliveryErr, &delivery.CreatedAt, &deliveredAt); err != nil {
+			log.Printf("Error scanning webhook delivery row: %v", err)
+			return nil, serviceErrorf(errInternal, "failed to scan webhook delivery row: %v", err)
+		}
+		delivery.EventType = model.WebhookEventType(eventType)
+		delivery.Status = model.WebhookDeliveryStatus(status)
+		delivery.NextAttemptAt = nullStringPtr(nextAttemptAt)
+		delivery.ResponseBody = nullStringPtr(responseBody)
+		delivery.Error = nullStringPtr(deliveryErr)
+		delivery.DeliveredAt = nullStringPtr(deliveredAt)
+		if responseStatus.Valid {
+			value := int(responseStatus.Int64)
+			delivery.ResponseStatus = &value
+		}
+		deliveries = append(deliveries, &delivery)
+	}
+	return deliveries, rows.Err()
+}
+
+// nullIfZero lagrar 0 som NULL, t.ex. en HTTP-status när inget svar kom
+func nullIfZero(value int) interface{} {
+	if value == 0 {
+		return nil
+	}
+	return value
+}
@@ -0,0 +1,230 @@
+package graph
+
+// This file will be automatically regenerated based on the schema, any resolver implementations
+// will be copied through when generating and any unknown code will be moved to the end.
+// Code generated by github.com/99designs/gqlgen version v0.17.69
+
+import (
+	"context"
+	"fmt"
+	"graphql-backend/graph/model"
+	"log"
+	"strconv"
+	"time"
+)
+
+// CreateWebhook is the resolver for the createWebhook field.
+func (r *mutationResolver) CreateWebhook(ctx context.Context, input model.WebhookInput) (*model.Webhook, error) {
+	logAction(fmt.Sprintf("Creating webhook for %s", input.URL))
+
+	if r.DB == nil {
+		log.Printf("Database connection is nil")
+		return nil, serviceErrorf(errInternal, "internal server error: database connection is not initialized")
+	}
+
+	userID, err := requireAdministrator(ctx, r.DB, "manage webhooks")
+	if err != nil {
+		return nil, err
+	}
+	if err := validateWebhookInput(r.DB, input); err != nil {
+		return nil, err
+	}
+
+	secret := ""
+	if input.Secret != nil && *input.Secret != "" {
+		secret = *input.Secret
+	} else if secret, err = generateWebhookSecret(); err != nil {
+		return nil, err
+	}
+	active := input.Active == nil || *input.Active
+
+	now := time.Now().Format(time.RFC3339)
+	result, err := r.DB.Exec(
+		"INSERT INTO webhooks (url, event_types, node_id, secret, active, created_by, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		input.URL, joinWebhookEventTypes(input.EventTypes), input.NodeID, secret, active, userID, now, now,
+	)
+	if err != nil {
+		log.Printf("Error creating webhook: %v", err)
+		return nil, serviceErrorf(errInternal, "failed to create webhook: %v", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, serviceErrorf(errInternal, "failed to retrieve webhook ID: %v", err)
+	}
+
+	webhook, err := getWebhook(r.DB, strconv.FormatInt(id, 10))
+	if err != nil {
+		return nil, err
+	}
+	// Hemligheten visas bara nu, så att mottagaren kan konfigureras
+	webhook.Secret = &secret
+	return webhook, nil
+}
+
+// UpdateWebhook is the resolver for the updateWebhook field.
+func (r *mutationResolver) UpdateWebhook(ctx context.Context, id string, input model.WebhookInput) (*model.Webhook, error) {
+	logAction(fmt.Sprintf("Updating webhook %s", id))
+
+	if r.DB == nil {
+		log.Printf("Database connection is nil")
+		return nil, serviceErrorf(errInternal, "internal server error: database connection is not initialized")
+	}
+
+	if _, err := requireAdministrator(ctx, r.DB, "manage webhooks"); err != nil {
+		return nil, err
+	}
+	current, err := getWebhook(r.DB, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateWebhookInput(r.DB, input); err != nil {
+		return nil, err
+	}
+
+	active := current.Active
+	if input.Active != nil {
+		active = *input.Active
+	}
+
+	query := "UPDATE webhooks SET url = ?, event_types = ?, node_id = ?, active = ?, updated_at = ?"
+	args := []interface{}{input.URL, joinWebhookEventTypes(input.EventTypes), input.NodeID, active, time.Now().Format(time.RFC3339)}
+	if input.Secret != nil && *input.Secret != "" {
+		query += ", secret = ?"
+		args = append(args, *input.Secret)
+	}
+	if _, err := r.DB.Exec(query+" WHERE id = ?", append(args, id)...); err != nil {
+		log.Printf("Error updating webhook %s: %v", id, err)
+		return nil, serviceErrorf(errInternal, "failed to update webhook: %v", err)
+	}
+
+	webhook, err := getWebhook(r.DB, id)
+	if err != nil {
+		return nil, err
+	}
+	if input.Secret != nil && *input.Secret != "" {
+		webhook.Secret = input.Secret
+	}
+	return webhook, nil
+}
+
+// DeleteWebhook is the resolver for the deleteWebhook field.
+func (r *mutationResolver) DeleteWebhook(ctx context.Context, id string) (bool, error) {
+	logAction(fmt.Sprintf("Deleting webhook %s", id))
+
+	if r.DB == nil {
+		log.Printf("Database connection is nil")
+		return false, serviceErrorf(errInternal, "internal server error: database connection is not initialized")
+	}
+
+	if _, err := requireAdministrator(ctx, r.DB, "manage webhooks"); err != nil {
+		return false, err
+	}
+
+	tx, err := r.DB.Begin()
+	if err != nil {
+		log.Printf("Error starting transaction: %v", err)
+		return false, serviceErrorf(errInternal, "failed to start transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM webhook_deliveries WHERE webhook_id = ?", id); err != nil {
+		log.Printf("Error deleting deliveries of webhook %s: %v", id, err)
+		return false, serviceErrorf(errInternal, "failed to delete webhook deliveries: %v", err)
+	}
+	result, err := tx.Exec("DELETE FROM webhooks WHERE id = ?", id)
+	if err != nil {
+		log.Printf("Error deleting webhook %s: %v", id, err)
+		return false, serviceErrorf(errInternal, "failed to delete webhook: %v", err)
+	}
+	if count, _ := result.RowsAffected(); count == 0 {
+		return false, serviceErrorf(errNotFound, "webhook not found")
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("Error committing transaction: %v", err)
+		return false, serviceErrorf(errInternal, "failed to commit transaction: %v", err)
+	}
+	return true, nil
+}
+
+// TestWebhook is the resolver for the testWebhook field.
+func (r *mutationResolver) TestWebhook(ctx context.Context, id string) (*model.WebhookDelivery, error) {
+	logAction(fmt.Sprintf("Testing webhook %s", id))
+
+	if r.DB == nil {
+		log.Printf("Database connection is nil")
+		return nil, serviceErrorf(errInternal, "internal server error: database connection is not initialized")
+	}
+
+	userID, err := requireAdministrator(ctx, r.DB, "manage webhooks")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := getWebhook(r.DB, id); err != nil {
+		return nil, err
+	}
+
+	return testWebhookDelivery(r.DB, webhookClient, id, userID)
+}
+
+// Webhooks is the resolver for the webhooks field.
+func (r *queryResolver) Webhooks(ctx context.Context) ([]*model.Webhook, error) {
+	logAction("Fetching webhooks")
+
+	if r.DB == nil {
+		log.Printf("Database connection is nil")
+		return nil, serviceErrorf(errInternal, "internal server error: database connection is not initialized")
+	}
+
+	if _, err := requireAdministrator(ctx, r.DB, "view webhooks"); err != nil {
+		return nil, err
+	}
+	return queryWebhooks(r.DB, "1 = 1")
+}
+
+// Webhook is the resolver for the webhook field.
+func (r *queryResolver) Webhook(ctx context.Context, id string) (*model.Webhook, error) {
+	logAction(fmt.Sprintf("Fetching webhook %s", id))
+
+	if r.DB == nil {
+		log.Printf("Database connection is nil")
+		return nil, serviceErrorf(errInternal, "internal server error: database connection is not initialized")
+	}
+
+	if _, err := requireAdministrator(ctx, r.DB, "view webhooks"); err != nil {
+		return nil, err
+	}
+	return getWebhook(r.DB, id)
+}
+
+// WebhookDeliveries is the resolver for the webhookDeliveries field.
+func (r *queryResolver) WebhookDeliveries(ctx context.Context, webhookID string, status *model.WebhookDeliveryStatus, limit *int, offset *int) ([]*model.WebhookDelivery, error) {
+	logAction(fmt.Sprintf("Fetching deliveries for webhook %s", webhookID))
+
+	if r.DB == nil {
+		log.Printf("Database connection is nil")
+		return nil, serviceErrorf(errInternal, "internal server error: database connection is not initialized")
+	}
+
+	if _, err := requireAdministrator(ctx, r.DB, "view webhooks"); err != nil {
+		return nil, err
+	}
+
+	pageSize := 100
+	if limit != nil && *limit > 0 {
+		pageSize = *limit
+	}
+	skip := 0
+	if offset != nil && *offset > 0 {
+		skip = *offset
+	}
+
+	query := "SELECT " + webhookDeliveryColumns + " FROM webhook_deliveries WHERE webhook_id = ?"
+	args := []interface{}{webhookID}
+	if status != nil {
+		query += " AND status = ?"
+		args = append(args, *status)
+	}
+	query += " ORDER BY id DESC LIMIT ? OFFSET ?"
+	return scanWebhookDeliveries(r.DB.Query(query, append(args, pageSize, skip)...))
+}
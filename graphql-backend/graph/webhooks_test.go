@@ -0,0 +1,330 @@
+package graph
+
+import (
+	"database/sql"
+	"encoding/json"
+	"graphql-backend/graph/model"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// webhookReceiver är en lokal mottagare som sparar anropen och svarar med statuskoderna i tur och ordning
+type webhookReceiver struct {
+	mu       sync.Mutex
+	requests []*receivedWebhook
+	statuses []int
+}
+
+type receivedWebhook struct {
+	header http.Header
+	body   []byte
+}
+
+func (rcv *webhookReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+
+	rcv.mu.Lock()
+	rcv.requests = append(rcv.requests, &receivedWebhook{header: r.Header.Clone(), body: body})
+	status := http.StatusOK
+	if len(rcv.statuses) > 0 {
+		status = rcv.statuses[0]
+		if len(rcv.statuses) > 1 {
+			rcv.statuses = rcv.statuses[1:]
+		}
+	}
+	rcv.mu.Unlock()
+
+	w.WriteHeader(status)
+	w.Write([]byte("ok"))
+}
+
+func (rcv *webhookReceiver) received() []*receivedWebhook {
+	rcv.mu.Lock()
+	defer rcv.mu.Unlock()
+	return append([]*receivedWebhook(nil), rcv.requests...)
+}
+
+func startWebhookReceiver(t *testing.T, statuses ...int) (*webhookReceiver, *httptest.Server) {
+	t.Helper()
+	receiver := &webhookReceiver{statuses: statuses}
+	server := httptest.NewServer(receiver)
+	t.Cleanup(server.Close)
+	return receiver, server
+}
+
+func insertTestWebhook(t *testing.T, db *sql.DB, url string, eventTypes string, nodeID interface{}, secret string) string {
+	t.Helper()
+	now := time.Now().Format(time.RFC3339)
+	result, err := db.Exec(
+		"INSERT INTO webhooks (url, event_types, node_id, secret, active, created_by, created_at, updated_at) VALUES (?, ?, ?, ?, 1, 1, ?, ?)",
+		url, eventTypes, nodeID, secret, now, now,
+	)
+	if err != nil {
+		t.Fatalf("insert webhook: %v", err)
+	}
+	id, _ := result.LastInsertId()
+	return strconv.FormatInt(id, 10)
+}
+
+func loadTestDeliveries(t *testing.T, db *sql.DB, webhookID string) []*model.WebhookDelivery {
+	t.Helper()
+	deliveries, err := scanWebhookDeliveries(db.Query("SELECT "+webhookDeliveryColumns+" FROM webhook_deliveries WHERE webhook_id = ? ORDER BY id ASC", webhookID))
+	if err != nil {
+		t.Fatalf("load deliveries: %v", err)
+	}
+	return deliveries
+}
+
+// makeDeliveriesDue flyttar fram alla väntande omförsök så att de kan skickas direkt
+func makeDeliveriesDue(t *testing.T, db *sql.DB) {
+	t.Helper()
+	past := time.Now().Add(-time.Minute).UTC().Format(time.RFC3339)
+	if _, err := db.Exec("UPDATE webhook_deliveries SET next_attempt_at = ? WHERE status = ?", past, model.WebhookDeliveryStatusPending); err != nil {
+		t.Fatalf("update deliveries: %v", err)
+	}
+}
+
+func TestWebhookDeliveryIsSignedAndLogged(t *testing.T) {
+	db := openTestDB(t)
+	receiver, server := startWebhookReceiver(t)
+	webhookID := insertTestWebhook(t, db, server.URL, "FILE_CREATED", nil, "s3cret")
+
+	fileID := insertTestFile(t, db, "rapport.txt", "1")
+	notifyCreated(db, "file", fileID, "saveFile", "1")
+
+	sent, err := processWebhookDeliveries(db, server.Client())
+	if err != nil || sent != 1 {
+		t.Fatalf("processWebhookDeliveries = %d, %v; want 1, nil", sent, err)
+	}
+
+	requests := receiver.received()
+	if len(requests) != 1 {
+		t.Fatalf("receiver got %d requests, want 1", len(requests))
+	}
+	request := requests[0]
+	if got, want := request.header.Get(WebhookSignatureHeader), SignWebhookPayload("s3cret", request.body); got != want {
+		t.Errorf("signature = %q, want %q", got, want)
+	}
+	if got := request.header.Get(WebhookEventHeader); got != "FILE_CREATED" {
+		t.Errorf("event header = %q, want FILE_CREATED", got)
+	}
+
+	var payload webhookPayload
+	if err := json.Unmarshal(request.body, &payload); err != nil {
+		t.Fatalf("decode payload: %v", err)
+	}
+	if payload.Type != model.WebhookEventTypeFileCreated || payload.FileID != fileID || payload.NodeID != "1" || payload.WebhookID != webhookID {
+		t.Errorf("unexpected payload %+v", payload)
+	}
+	var data map[string]interface{}
+	if err := json.Unmarshal(payload.Data, &data); err != nil || data["name"] != "rapport.txt" {
+		t.Errorf("payload data = %s, want the file row", payload.Data)
+	}
+	if _, ok := data["file_data"]; ok {
+		t.Errorf("payload data contains the file content")
+	}
+
+	deliveries := loadTestDeliveries(t, db, webhookID)
+	if len(deliveries) != 1 {
+		t.Fatalf("got %d deliveries, want 1", len(deliveries))
+	}
+	delivery := deliveries[0]
+	if delivery.Status != model.WebhookDeliveryStatusDelivered || delivery.Attempts != 1 || delivery.DeliveredAt == nil {
+		t.Errorf("delivery = %+v, want delivered after one attempt", delivery)
+	}
+	if delivery.ResponseStatus == nil || *delivery.ResponseStatus != http.StatusOK {
+		t.Errorf("response status = %v, want 200", delivery.ResponseStatus)
+	}
+	if request.header.Get(WebhookDeliveryHeader) != delivery.ID {
+		t.Errorf("delivery header = %q, want %q", request.header.Get(WebhookDeliveryHeader), delivery.ID)
+	}
+}
+
+func TestWebhookDeliveryRetriesWithBackoff(t *testing.T) {
+	db := openTestDB(t)
+	receiver, server := startWebhookReceiver(t, http.StatusInternalServerError, http.StatusOK)
+	webhookID := insertTestWebhook(t, db, server.URL, "FILE_CREATED", nil, "s3cret")
+
+	notifyCreated(db, "file", insertTestFile(t, db, "a.txt", "1"), "saveFile", "1")
+
+	if _, err := processWebhookDeliveries(db, server.Client()); err != nil {
+		t.Fatalf("processWebhookDeliveries: %v", err)
+	}
+	delivery := loadTestDeliveries(t, db, webhookID)[0]
+	if delivery.Status != model.WebhookDeliveryStatusPending || delivery.Attempts != 1 || delivery.Error == nil {
+		t.Fatalf("delivery after failed attempt = %+v, want pending with an error", delivery)
+	}
+	nextAttempt, err := time.Parse(time.RFC3339, *delivery.NextAttemptAt)
+	if err != nil {
+		t.Fatalf("parse nextAttemptAt: %v", err)
+	}
+	if delay := time.Until(nextAttempt); delay < webhookRetryBaseDelay-5*time.Second || delay > webhookRetryBaseDelay+5*time.Second {
+		t.Errorf("retry delay = %s, want about %s", delay, webhookRetryBaseDelay)
+	}
+
+	// Omförsöket skickas inte förrän fördröjningen har gått
+	if sent, _ := processWebhookDeliveries(db, server.Client()); sent != 0 {
+		t.Errorf("sent %d deliveries before the retry was due, want 0", sent)
+	}
+
+	makeDeliveriesDue(t, db)
+	if _, err := processWebhookDeliveries(db, server.Client()); err != nil {
+		t.Fatalf("processWebhookDeliveries: %v", err)
+	}
+	delivery = loadTestDeliveries(t, db, webhookID)[0]
+	if delivery.Status != model.WebhookDeliveryStatusDelivered || delivery.Attempts != 2 || delivery.Error != nil {
+		t.Errorf("delivery after retry = %+v, want delivered on the second attempt", delivery)
+	}
+	if len(receiver.received()) != 2 {
+		t.Errorf("receiver got %d requests, want 2", len(receiver.received()))
+	}
+}
+
+func TestWebhookDeliveryFailsAfterMaxAttempts(t *testing.T) {
+	db := openTestDB(t)
+	receiver, server := startWebhookReceiver(t, http.StatusServiceUnavailable)
+	webhookID := insertTestWebhook(t, db, server.URL, "FILE_CREATED", nil, "s3cret")
+
+	notifyCreated(db, "file", insertTestFile(t, db, "a.txt", "1"), "saveFile", "1")
+
+	for i := 0; i < webhookMaxAttempts+2; i++ {
+		makeDeliveriesDue(t, db)
+		if _, err := processWebhookDeliveries(db, server.Client()); err != nil {
+			t.Fatalf("processWebhookDeliveries: %v", err)
+		}
+	}
+
+	delivery := loadTestDeliveries(t, db, webhookID)[0]
+	if delivery.Status != model.WebhookDeliveryStatusFailed || delivery.Attempts != webhookMaxAttempts || delivery.NextAttemptAt != nil {
+		t.Errorf("delivery = %+v, want failed after %d attempts", delivery, webhookMaxAttempts)
+	}
+	if delivery.ResponseStatus == nil || *delivery.ResponseStatus != http.StatusServiceUnavailable {
+		t.Errorf("response status = %v, want 503", delivery.ResponseStatus)
+	}
+	if len(receiver.received()) != webhookMaxAttempts {
+		t.Errorf("receiver got %d requests, want %d", len(receiver.received()), webhookMaxAttempts)
+	}
+}
+
+func TestWebhookNodeScopeAndEventTypes(t *testing.T) {
+	db := openTestDB(t)
+	_, server := startWebhookReceiver(t)
+
+	inside := insertTestNode(t, db, "Ärenden", "1")
+	child := insertTestNode(t, db, "2024", inside)
+	outside := insertTestNode(t, db, "Övrigt", "1")
+	webhookID := insertTestWebhook(t, db, server.URL, "FILE_CREATED,FILE_MOVED,METADATA_CHANGED", inside, "s3cret")
+
+	// Filer utanför underträdet ger ingen leverans, filer längre ner i trädet gör det
+	notifyCreated(db, "file", insertTestFile(t, db, "utanfor.txt", outside), "saveFile", "1")
+	fileID := insertTestFile(t, db, "innanfor.txt", child)
+	notifyCreated(db, "file", fileID, "saveFile", "1")
+
+	// En ändring av enbart metadata ger METADATA_CHANGED men inte FILE_UPDATED, som webhooken inte bevakar
+	before := loadChangeTarget(db, "file", fileID)
+	if _, err := db.Exec("INSERT INTO metadata (file_id, key, value) VALUES (?, 'title', 'Beslut')", fileID); err != nil {
+		t.Fatalf("insert metadata: %v", err)
+	}
+	if _, err := db.Exec("UPDATE files SET updated_at = ? WHERE id = ?", time.Now().Add(time.Minute).Format(time.RFC3339), fileID); err != nil {
+		t.Fatalf("update file: %v", err)
+	}
+	notifyChange(db, "file", fileID, before, loadChangeTarget(db, "file", fileID), "updateMetadata", "1")
+
+	// En fil som flyttas in i underträdet ger FILE_MOVED
+	movedID := insertTestFile(t, db, "flyttad.txt", outside)
+	before = loadChangeTarget(db, "file", movedID)
+	if _, err := db.Exec("UPDATE files SET node_id = ? WHERE id = ?", inside, movedID); err != nil {
+		t.Fatalf("move file: %v", err)
+	}
+	notifyChange(db, "file", movedID, before, loadChangeTarget(db, "file", movedID), "moveFile", "1")
+
+	deliveries := loadTestDeliveries(t, db, webhookID)
+	want := []model.WebhookEventType{
+		model.WebhookEventTypeFileCreated,
+		model.WebhookEventTypeMetadataChanged,
+		model.WebhookEventTypeFileMoved,
+	}
+	if len(deliveries) != len(want) {
+		t.Fatalf("got %d deliveries, want %d", len(deliveries), len(want))
+	}
+	for i, delivery := range deliveries {
+		if delivery.EventType != want[i] {
+			t.Errorf("delivery %d has event type %s, want %s", i, delivery.EventType, want[i])
+		}
+	}
+}
+
+func TestTestWebhookDelivery(t *testing.T) {
+	db := openTestDB(t)
+	receiver, server := startWebhookReceiver(t)
+	webhookID := insertTestWebhook(t, db, server.URL, "FILE_CREATED", nil, "s3cret")
+
+	delivery, err := testWebhookDelivery(db, server.Client(), webhookID, "1")
+	if err != nil {
+		t.Fatalf("testWebhookDelivery: %v", err)
+	}
+	if delivery.EventType != model.WebhookEventTypeTest || delivery.Status != model.WebhookDeliveryStatusDelivered || delivery.Attempts != 1 {
+		t.Errorf("delivery = %+v, want a delivered TEST event", delivery)
+	}
+
+	requests := receiver.received()
+	if len(requests) != 1 || requests[0].header.Get(WebhookEventHeader) != "TEST" {
+		t.Fatalf("receiver did not get a TEST event")
+	}
+
+	// Testleveransen görs bara en gång och tas inte av arbetaren
+	if sent, _ := processWebhookDeliveries(db, server.Client()); sent != 0 {
+		t.Errorf("worker sent %d deliveries, want 0", sent)
+	}
+}
+
+func TestWebhookRejectsInternalTargets(t *testing.T) {
+	db := openTestDB(t)
+
+	for _, target := range []string{
+		"http://127.0.0.1:8080/hook",
+		"http://localhost/hook",
+		"http://arkiv.localhost./hook",
+		"http://[::1]/hook",
+		"http://[::ffff:127.0.0.1]/hook",
+		"http://0.0.0.0/hook",
+		"http://169.254.169.254/latest/meta-data",
+		"http://10.0.0.5/hook",
+		"http://172.16.1.1/hook",
+		"https://192.168.1.10/hook",
+		"http://[fd00::1]/hook",
+	} {
+		input := model.WebhookInput{URL: target, EventTypes: []model.WebhookEventType{model.WebhookEventTypeFileCreated}}
+		if err := validateWebhookInput(db, input); err == nil || err.Error() != "webhook URL must not point to a loopback, link-local or private address" {
+			t.Errorf("%s: err = %v", target, err)
+		}
+	}
+	for _, target := range []string{"https://example.com/hook", "http://93.184.216.34:8080/hook"} {
+		input := model.WebhookInput{URL: target, EventTypes: []model.WebhookEventType{model.WebhookEventTypeFileCreated}}
+		if err := validateWebhookInput(db, input); err != nil {
+			t.Errorf("%s: %v", target, err)
+		}
+	}
+
+	// Även en adress som först vid leveransen visar sig vara intern avvisas, innan något skickas
+	receiver, server := startWebhookReceiver(t)
+	webhookID := insertTestWebhook(t, db, server.URL, "FILE_CREATED", nil, "s3cret")
+	delivery, err := testWebhookDelivery(db, webhookClient, webhookID, "1")
+	if err != nil {
+		t.Fatalf("testWebhookDelivery: %v", err)
+	}
+	if delivery.Status != model.WebhookDeliveryStatusFailed || delivery.Error == nil || !strings.Contains(*delivery.Error, "loopback, link-local or private address") {
+		t.Errorf("delivery = %+v, want a failed delivery to an internal address", delivery)
+	}
+	if len(receiver.received()) != 0 {
+		t.Error("the internal receiver got the delivery")
+	}
+}
@@ -0,0 +1,521 @@
+package graph
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// =============================================
+// ========== XSD-VALIDERING =================
+// =============================================
+
+// Validatorn stödjer den delmängd av XML Schema som de medföljande scheman använder:
+// globala element, namngivna och inbäddade complexType/simpleType, xs:sequence med
+// minOccurs/maxOccurs, xs:any (sist i en sekvens, innehållet valideras inte), attribut
+// samt restriktioner med enumeration, pattern och minLength på inbyggda typer.
+
+// maxValidationErrors begränsar hur många fel som rapporteras för ett dokument
+const maxValidationErrors = 50
+
+// xsdSchema är ett inläst XML-schema redo för validering
+type xsdSchema struct {
+	targetNamespace string
+	elements        map[string]*xsdElement
+	complexTypes    map[string]*xsdComplexType
+	simpleTypes     map[string]*xsdSimpleType
+}
+
+// xsdElement är en elementdeklaration (eller xs:any) i en sekvens
+type xsdElement struct {
+	name      string
+	typeName  string
+	complex   *xsdComplexType
+	simple    *xsdSimpleType
+	minOccurs int
+	maxOccurs int // -1 betyder unbounded
+	any       bool
+}
+
+// xsdComplexType beskriver tillåtna barnelement och attribut
+type xsdComplexType struct {
+	sequence   []*xsdElement
+	attributes []*xsdAttribute
+	mixed      bool
+}
+
+// xsdAttribute är en attributdeklaration
+type xsdAttribute struct {
+	name     string
+	typeName string
+	required bool
+}
+
+// xsdSimpleType är en restriktion av en inbyggd typ
+type xsdSimpleType struct {
+	base        string
+	enumeration []string
+	pattern     *regexp.Regexp
+	minLength   int
+}
+
+// Råa strukturer för att läsa in XSD-filer med encoding/xml
+type rawXSDSchema struct {
+	TargetNamespace string              `xml:"targetNamespace,attr"`
+	Elements        []*rawXSDElement    `xml:"element"`
+	ComplexTypes    []*rawXSDComplex    `xml:"complexType"`
+	SimpleTypes     []*rawXSDSimpleType `xml:"simpleType"`
+}
+
+type rawXSDElement struct {
+	Name        string            `xml:"name,attr"`
+	Type        string            `xml:"type,attr"`
+	MinOccurs   string            `xml:"minOccurs,attr"`
+	MaxOccurs   string            `xml:"maxOccurs,attr"`
+	ComplexType *rawXSDComplex    `xml:"complexType"`
+	SimpleType  *rawXSDSimpleType `xml:"simpleType"`
+}
+
+type rawXSDComplex struct {
+	Name       string            `xml:"name,attr"`
+	Mixed      bool              `xml:"mixed,attr"`
+	Sequence   *rawXSDSequence   `xml:"sequence"`
+	Attributes []rawXSDAttribute `xml:"attribute"`
+}
+
+type rawXSDSequence struct {
+	Elements []*rawXSDElement `xml:"element"`
+	Any      []rawXSDAny      `xml:"any"`
+}
+
+type rawXSDAny struct {
+	MinOccurs string `xml:"minOccurs,attr"`
+	MaxOccurs string `xml:"maxOccurs,attr"`
+}
+
+type rawXSDAttribute struct {
+	Name string `xml:"name,attr"`
+	Type string `xml:"type,attr"`
+	Use  string `xml:"use,attr"`
+}
+
+type rawXSDSimpleType struct {
+	Name        string `xml:"name,attr"`
+	Restriction *struct {
+		Base        string `xml:"base,attr"`
+		Enumeration []struct {
+			Value string `xml:"value,attr"`
+		} `xml:"enumeration"`
+		Pattern *struct {
+			Value string `xml:"value,attr"`
+		} `xml:"pattern"`
+		MinLength *struct {
+			Value int `xml:"value,attr"`
+		} `xml:"minLength"`
+	} `xml:"restriction"`
+}
+
+// loadXSD läser in ett XML-schema från bytes
+func loadXSD(data []byte) (*xsdSchema, error) {
+	var raw rawXSDSchema
+	if err := xml.Unmarshal(data, &raw); err != nil {
+		return nil, serviceErrorf(errInternal, "failed to parse schema: %v", err)
+	}
+
+	schema := &xsdSchema{
+		targetNamespace: raw.TargetNamespace,
+		elements:        make(map[string]*xsdElement),
+		complexTypes:    make(map[string]*xsdComplexType),
+		simpleTypes:     make(map[string]*xsdSimpleType),
+	}
+
+	for _, st := range raw.SimpleTypes {
+		simple, err := convertSimpleType(st)
+		if err != nil {
+			return nil, err
+		}
+		schema.simpleTypes[st.Name] = simple
+	}
+
+	for _, ct := range raw.ComplexTypes {
+		complex, err := convertComplexType(ct)
+		if err != nil {
+			return nil, err
+		}
+		schema.complexTypes[ct.Name] = complex
+	}
+
+	for _, el := range raw.Elements {
+		element, err := convertElement(el)
+		if err != nil {
+			return nil, err
+		}
+		schema.elements[el.Name] = element
+	}
+
+	return schema, nil
+}
+
+func convertElement(raw *rawXSDElement) (*xsdElement, error) {
+	element := &xsdElement{name: raw.Name, typeName: localName(raw.Type)}
+
+	var err error
+	if element.minOccurs, err = parseOccurs(raw.MinOccurs); err != nil {
+		return nil, err
+	}
+	if element.maxOccurs, err = parseOccurs(raw.MaxOccurs); err != nil {
+		return nil, err
+	}
+
+	if raw.ComplexType != nil {
+		if element.complex, err = convertComplexType(raw.ComplexType); err != nil {
+			return nil, err
+		}
+	}
+	if raw.SimpleType != nil {
+		if element.simple, err = convertSimpleType(raw.SimpleType); err != nil {
+			return nil, err
+		}
+	}
+
+	return element, nil
+}
+
+func convertComplexType(raw *rawXSDComplex) (*xsdComplexType, error) {
+	complex := &xsdComplexType{mixed: raw.Mixed}
+
+	if raw.Sequence != nil {
+		for _, el := range raw.Sequence.Elements {
+			element, err := convertElement(el)
+			if err != nil {
+				return nil, err
+			}
+			complex.sequence = append(complex.sequence, element)
+		}
+		for _, a := range raw.Sequence.Any {
+			minOccurs, err := parseOccurs(a.MinOccurs)
+			if err != nil {
+				return nil, err
+			}
+			maxOccurs, err := parseOccurs(a.MaxOccurs)
+			if err != nil {
+				return nil, err
+			}
+			complex.sequence = append(complex.sequence, &xsdElement{any: true, minOccurs: minOccurs, maxOccurs: maxOccurs})
+		}
+	}
+
+	for _, a := range raw.Attributes {
+		complex.attributes = append(complex.attributes, &xsdAttribute{
+			name:     a.Name,
+			typeName: localName(a.Type),
+			required: a.Use == "required",
+		})
+	}
+
+	return complex, nil
+}
+
+func convertSimpleType(raw *rawXSDSimpleType) (*xsdSimpleType, error) {
+	if raw.Restriction == nil {
+		return nil, fmt.Errorf("simple type %q: only restrictions are supported", raw.Name)
+	}
+
+	simple := &xsdSimpleType{base: localName(raw.Restriction.Base)}
+	for _, e := range raw.Restriction.Enumeration {
+		simple.enumeration = append(simple.enumeration, e.Value)
+	}
+	if raw.Restriction.Pattern != nil {
+		pattern, err := regexp.Compile("^(?:" + raw.Restriction.Pattern.Value + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("simple type %q: invalid pattern: %v", raw.Name, err)
+		}
+		simple.pattern = pattern
+	}
+	if raw.Restriction.MinLength != nil {
+		simple.minLength = raw.Restriction.MinLength.Value
+	}
+
+	return simple, nil
+}
+
+// parseOccurs tolkar minOccurs/maxOccurs där tomt värde betyder 1
+func parseOccurs(value string) (int, error) {
+	switch value {
+	case "":
+		return 1, nil
+	case "unbounded":
+		return -1, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid occurrence value %q", value)
+	}
+	return n, nil
+}
+
+// localName tar bort prefix från ett QName, t.ex. "xs:string" blir "string"
+func localName(qname string) string {
+	if i := strings.LastIndex(qname, ":"); i >= 0 {
+		return qname[i+1:]
+	}
+	return qname
+}
+
+// xmlTreeNode är ett element i ett inläst XML-dokument
+type xmlTreeNode struct {
+	name     xml.Name
+	attrs    []xml.Attr
+	children []*xmlTreeNode
+	text     strings.Builder
+}
+
+// parseXMLTree läser in ett XML-dokument som ett träd av element
+func parseXMLTree(doc []byte) (*xmlTreeNode, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(doc))
+	var stack []*xmlTreeNode
+	var root *xmlTreeNode
+
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("malformed XML: %v", err)
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			node := &xmlTreeNode{name: t.Name, attrs: t.Attr}
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				parent.children = append(parent.children, node)
+			} else if root == nil {
+				root = node
+			}
+			stack = append(stack, node)
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+		case xml.CharData:
+			if len(stack) > 0 {
+				stack[len(stack)-1].text.Write(t)
+			}
+		}
+	}
+
+	if root == nil {
+		return nil, fmt.Errorf("document has no root element")
+	}
+	return root, nil
+}
+
+// xsdValidation samlar fel under valideringen av ett dokument
+type xsdValidation struct {
+	schema *xsdSchema
+	errors []string
+}
+
+func (v *xsdValidation) addError(path string, format string, args ...interface{}) {
+	if len(v.errors) < maxValidationErrors {
+		v.errors = append(v.errors, fmt.Sprintf("%s: %s", path, fmt.Sprintf(format, args...)))
+	}
+}
+
+// validate kontrollerar att ett dokument följer schemat
+func (s *xsdSchema) validate(doc []byte) error {
+	root, err := parseXMLTree(doc)
+	if err != nil {
+		return err
+	}
+
+	if root.name.Space != s.targetNamespace {
+		return fmt.Errorf("root element is in namespace %q, expected %q", root.name.Space, s.targetNamespace)
+	}
+
+	decl, ok := s.elements[root.name.Local]
+	if !ok {
+		return fmt.Errorf("root element %q is not declared in the schema", root.name.Local)
+	}
+
+	v := &xsdValidation{schema: s}
+	v.validateElement(decl, root, "/"+root.name.Local)
+
+	if len(v.errors) > 0 {
+		return fmt.Errorf("schema validation failed: %s", strings.Join(v.errors, "; "))
+	}
+	return nil
+}
+
+func (v *xsdValidation) validateElement(decl *xsdElement, node *xmlTreeNode, path string) {
+	if decl.complex != nil {
+		v.validateComplex(decl.complex, node, path)
+		return
+	}
+	if decl.simple != nil {
+		v.validateSimpleContent(decl.simple, node, path)
+		return
+	}
+
+	if complex, ok := v.schema.complexTypes[decl.typeName]; ok {
+		v.validateComplex(complex, node, path)
+		return
+	}
+
+	if len(node.children) > 0 {
+		v.addError(path, "element must not contain child elements")
+		return
+	}
+	v.checkValue(decl.typeName, node.text.String(), path)
+}
+
+func (v *xsdValidation) validateSimpleContent(simple *xsdSimpleType, node *xmlTreeNode, path string) {
+	if len(node.children) > 0 {
+		v.addError(path, "element must not contain child elements")
+		return
+	}
+	v.checkSimpleValue(simple, node.text.String(), path)
+}
+
+func (v *xsdValidation) validateComplex(complex *xsdComplexType, node *xmlTreeNode, path string) {
+	// Attribut
+	declared := make(map[string]*xsdAttribute)
+	for _, attr := range complex.attributes {
+		declared[attr.name] = attr
+	}
+	present := make(map[string]bool)
+	for _, attr := range node.attrs {
+		if attr.Name.Space == "xmlns" || attr.Name.Local == "xmlns" || attr.Name.Space == "http://www.w3.org/2001/XMLSchema-instance" {
+			continue
+		}
+		decl, ok := declared[attr.Name.Local]
+		if !ok {
+			v.addError(path, "attribute %q is not allowed", attr.Name.Local)
+			continue
+		}
+		present[attr.Name.Local] = true
+		v.checkValue(decl.typeName, attr.Value, path+"/@"+attr.Name.Local)
+	}
+	for _, attr := range complex.attributes {
+		if attr.required && !present[attr.name] {
+			v.addError(path, "missing required attribute %q", attr.name)
+		}
+	}
+
+	if !complex.mixed && strings.TrimSpace(node.text.String()) != "" {
+		v.addError(path, "text content is not allowed")
+	}
+
+	// Barnelement i sekvens
+	index := 0
+	for _, particle := range complex.sequence {
+		count := 0
+		for index < len(node.children) && (particle.maxOccurs < 0 || count < particle.maxOccurs) {
+			child := node.children[index]
+			if !particle.any {
+				if child.name.Local != particle.name || child.name.Space != v.schema.targetNamespace {
+					break
+				}
+				v.validateElement(particle, child, path+"/"+child.name.Local)
+			}
+			index++
+			count++
+		}
+		if count < particle.minOccurs {
+			if particle.any {
+				v.addError(path, "expected at least %d child elements", particle.minOccurs)
+			} else {
+				v.addError(path, "missing element %q", particle.name)
+			}
+		}
+	}
+
+	for ; index < len(node.children); index++ {
+		v.addError(path, "unexpected element %q", node.children[index].name.Local)
+	}
+}
+
+func (v *xsdValidation) checkValue(typeName string, value string, path string) {
+	if simple, ok := v.schema.simpleTypes[typeName]; ok {
+		v.checkSimpleValue(simple, value, path)
+		return
+	}
+	if err := checkBuiltinValue(typeName, value); err != nil {
+		v.addError(path, "%v", err)
+	}
+}
+
+func (v *xsdValidation) checkSimpleValue(simple *xsdSimpleType, value string, path string) {
+	if err := checkBuiltinValue(simple.base, value); err != nil {
+		v.addError(path, "%v", err)
+		return
+	}
+
+	value = strings.TrimSpace(value)
+	if len(simple.enumeration) > 0 {
+		found := false
+		for _, allowed := range simple.enumeration {
+			if value == allowed {
+				found = true
+				break
+			}
+		}
+		if !found {
+			v.addError(path, "value %q is not one of the allowed values", value)
+		}
+	}
+	if simple.pattern != nil && !simple.pattern.MatchString(value) {
+		v.addError(path, "value %q does not match pattern", value)
+	}
+	if len(value) < simple.minLength {
+		v.addError(path, "value must be at least %d characters", simple.minLength)
+	}
+}
+
+// checkBuiltinValue kontrollerar ett värde mot en inbyggd XML Schema-typ
+func checkBuiltinValue(typeName string, value string) error {
+	value = strings.TrimSpace(value)
+
+	switch typeName {
+	case "", "string", "normalizedString", "token", "anyURI", "anyType", "anySimpleType":
+		return nil
+	case "integer", "int", "long", "short":
+		if _, err := strconv.ParseInt(value, 10, 64); err != nil {
+			return fmt.Errorf("value %q is not a valid integer", value)
+		}
+	case "nonNegativeInteger", "positiveInteger":
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || n < 0 || (typeName == "positiveInteger" && n == 0) {
+			return fmt.Errorf("value %q is not a valid %s", value, typeName)
+		}
+	case "decimal", "double", "float":
+		if _, err := strconv.ParseFloat(value, 64); err != nil {
+			return fmt.Errorf("value %q is not a valid decimal", value)
+		}
+	case "boolean":
+		switch value {
+		case "true", "false", "1", "0":
+		default:
+			return fmt.Errorf("value %q is not a valid boolean", value)
+		}
+	case "dateTime":
+		if _, err := time.Parse(time.RFC3339, value); err != nil {
+			if _, err := time.Parse("2006-01-02T15:04:05", value); err != nil {
+				return fmt.Errorf("value %q is not a valid dateTime", value)
+			}
+		}
+	case "date":
+		if _, err := time.Parse("2006-01-02", value); err != nil {
+			return fmt.Errorf("value %q is not a valid date", value)
+		}
+	default:
+		return fmt.Errorf("unknown type %q", typeName)
+	}
+
+	return nil
+}
@@ -0,0 +1,202 @@
+package graph
+
+import (
+	"strings"
+	"testing"
+)
+
+// testXSD täcker de delar av XML Schema som validatorn stödjer
+const testXSD = `<?xml version="1.0" encoding="UTF-8"?>
+<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema"
+           targetNamespace="urn:test"
+           elementFormDefault="qualified">
+  <xs:element name="arkiv" type="arkiv"/>
+  <xs:complexType name="arkiv">
+    <xs:sequence>
+      <xs:element name="tittel" type="xs:string"/>
+      <xs:element name="status" type="status"/>
+      <xs:element name="opprettet" type="xs:dateTime"/>
+      <xs:element name="antall" type="xs:positiveInteger" minOccurs="0"/>
+      <xs:element name="kode" minOccurs="0" maxOccurs="2">
+        <xs:simpleType>
+          <xs:restriction base="xs:string">
+            <xs:pattern value="[A-Z]{3}"/>
+          </xs:restriction>
+        </xs:simpleType>
+      </xs:element>
+      <xs:element name="del" type="del" minOccurs="0" maxOccurs="unbounded"/>
+    </xs:sequence>
+    <xs:attribute name="versjon" type="xs:integer" use="required"/>
+  </xs:complexType>
+  <xs:complexType name="del">
+    <xs:sequence>
+      <xs:element name="navn" type="xs:string"/>
+      <xs:any minOccurs="0" maxOccurs="unbounded"/>
+    </xs:sequence>
+  </xs:complexType>
+  <xs:simpleType name="status">
+    <xs:restriction base="xs:string">
+      <xs:enumeration value="Opprettet"/>
+      <xs:enumeration value="Avsluttet"/>
+    </xs:restriction>
+  </xs:simpleType>
+</xs:schema>`
+
+func TestXSDValidate(t *testing.T) {
+	schema, err := loadXSD([]byte(testXSD))
+	if err != nil {
+		t.Fatalf("load schema: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		body string
+		attr string
+		ns   string
+		want string // Tom om dokumentet är giltigt
+	}{
+		{
+			name: "valid",
+			body: `<tittel>Arkiv</tittel><status>Opprettet</status><opprettet>2026-01-02T10:00:00Z</opprettet>
+				<antall>3</antall><kode>ABC</kode><kode>DEF</kode><del><navn>A</navn></del>
+				<del><navn>B</navn><annet><fritt>innhåll</fritt></annet></del>`,
+		},
+		{
+			name: "optional elements left out",
+			body: `<tittel>Arkiv</tittel><status>Avsluttet</status><opprettet>2026-01-02T10:00:00</opprettet>`,
+		},
+		{
+			name: "missing element",
+			body: `<tittel>Arkiv</tittel><opprettet>2026-01-02T10:00:00Z</opprettet>`,
+			want: `/arkiv: missing element "status"`,
+		},
+		{
+			name: "value outside enumeration",
+			body: `<tittel>Arkiv</tittel><status>Slettet</status><opprettet>2026-01-02T10:00:00Z</opprettet>`,
+			want: `/arkiv/status: value "Slettet" is not one of the allowed values`,
+		},
+		{
+			name: "invalid dateTime",
+			body: `<tittel>Arkiv</tittel><status>Opprettet</status><opprettet>2 januar</opprettet>`,
+			want: `/arkiv/opprettet: value "2 januar" is not a valid dateTime`,
+		},
+		{
+			name: "zero is not positive",
+			body: `<tittel>Arkiv</tittel><status>Opprettet</status><opprettet>2026-01-02T10:00:00Z</opprettet><antall>0</antall>`,
+			want: `/arkiv/antall: value "0" is not a valid positiveInteger`,
+		},
+		{
+			name: "pattern mismatch",
+			body: `<tittel>Arkiv</tittel><status>Opprettet</status><opprettet>2026-01-02T10:00:00Z</opprettet><kode>abc</kode>`,
+			want: `/arkiv/kode: value "abc" does not match pattern`,
+		},
+		{
+			name: "too many occurrences",
+			body: `<tittel>Arkiv</tittel><status>Opprettet</status><opprettet>2026-01-02T10:00:00Z</opprettet>
+				<kode>ABC</kode><kode>DEF</kode><kode>GHI</kode>`,
+			want: `/arkiv: unexpected element "kode"`,
+		},
+		{
+			name: "wrong order",
+			body: `<status>Opprettet</status><tittel>Arkiv</tittel><opprettet>2026-01-02T10:00:00Z</opprettet>`,
+			want: `/arkiv: missing element "tittel"`,
+		},
+		{
+			name: "child in a simple element",
+			body: `<tittel><b>Arkiv</b></tittel><status>Opprettet</status><opprettet>2026-01-02T10:00:00Z</opprettet>`,
+			want: `/arkiv/tittel: element must not contain child elements`,
+		},
+		{
+			name: "text in a complex element",
+			body: `løs tekst<tittel>Arkiv</tittel><status>Opprettet</status><opprettet>2026-01-02T10:00:00Z</opprettet>`,
+			want: `/arkiv: text content is not allowed`,
+		},
+		{
+			name: "missing required attribute",
+			body: `<tittel>Arkiv</tittel><status>Opprettet</status><opprettet>2026-01-02T10:00:00Z</opprettet>`,
+			attr: " ",
+			want: `/arkiv: missing required attribute "versjon"`,
+		},
+		{
+			name: "unknown attribute",
+			body: `<tittel>Arkiv</tittel><status>Opprettet</status><opprettet>2026-01-02T10:00:00Z</opprettet>`,
+			attr: ` versjon="1" farge="rød"`,
+			want: `/arkiv: attribute "farge" is not allowed`,
+		},
+		{
+			name: "wrong namespace",
+			body: `<tittel>Arkiv</tittel>`,
+			ns:   "urn:annet",
+			want: `root element is in namespace "urn:annet", expected "urn:test"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attr, ns := tt.attr, tt.ns
+			if attr == "" {
+				attr = ` versjon="1"`
+			}
+			if ns == "" {
+				ns = "urn:test"
+			}
+			doc := `<?xml version="1.0" encoding="UTF-8"?><arkiv xmlns="` + ns + `"` + attr + `>` + tt.body + `</arkiv>`
+
+			err := schema.validate([]byte(doc))
+			if tt.want == "" {
+				if err != nil {
+					t.Errorf("validate: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("validate: err = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestXSDValidateRejectsUndeclaredRoot(t *testing.T) {
+	schema, err := loadXSD([]byte(testXSD))
+	if err != nil {
+		t.Fatalf("load schema: %v", err)
+	}
+	if err := schema.validate([]byte(`<del xmlns="urn:test"><navn>A</navn></del>`)); err == nil || !strings.Contains(err.Error(), `root element "del" is not declared`) {
+		t.Errorf("validate: err = %v", err)
+	}
+	if err := schema.validate([]byte(`<arkiv xmlns="urn:test" versjon="1"><tittel>`)); err == nil {
+		t.Error("validate accepted a truncated document")
+	}
+}
+
+func TestLoadBundledNoarkSchemas(t *testing.T) {
+	entries, err := noarkSchemas.ReadDir("schemas/noark5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 5 {
+		t.Errorf("schemas = %d, want 5", len(entries))
+	}
+	for _, entry := range entries {
+		data, err := noarkSchemas.ReadFile("schemas/noark5/" + entry.Name())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := loadXSD(data); err != nil {
+			t.Errorf("load %s: %v", entry.Name(), err)
+		}
+	}
+
+	// En tom endringslogg är giltig, men en endring utan obligatoriska element är det inte
+	empty := `<endringslogg xmlns="http://www.arkivverket.no/standarder/noark5/endringslogg"/>`
+	if err := validateAgainstNoarkSchema("endringslogg.xsd", []byte(empty)); err != nil {
+		t.Errorf("validate empty change log: %v", err)
+	}
+	incomplete := `<endringslogg xmlns="http://www.arkivverket.no/standarder/noark5/endringslogg"><endring><referanseArkivenhet>x</referanseArkivenhet></endring></endringslogg>`
+	if err := validateAgainstNoarkSchema("endringslogg.xsd", []byte(incomplete)); err == nil {
+		t.Error("validate accepted a change without referanseMetadata")
+	}
+	if err := validateAgainstNoarkSchema("saknas.xsd", []byte(empty)); err == nil {
+		t.Error("validate against a missing schema succeeded")
+	}
+}
@@ -0,0 +1,471 @@
+package graph
+
+import (
+	"archive/zip"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"graphql-backend/graph/model"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// =============================================
+// ========== ZIP-EXPORT ======================
+// =============================================
+
+// Manifestens filnamn i arkivets rot
+const (
+	zipManifestJSONFile = "manifest.json"
+	zipManifestCSVFile  = "manifest.csv"
+)
+
+// ZipExportPath är sökvägen där ZIP-exporter strömmas och färdiga exportjobb hämtas
+const ZipExportPath = "/export/zip"
+
+// ZipExportResult är resultatet av en ZIP-export
+type ZipExportResult struct {
+	File        string `json:"file,omitempty"` // Sökväg på servern för exporter som körts som jobb
+	DownloadURL string `json:"downloadUrl,omitempty"`
+	Files       int    `json:"files"`
+	Bytes       int64  `json:"bytes"`
+}
+
+// zipManifest beskriver arkivets innehåll
+type zipManifest struct {
+	Source     string               `json:"source"`
+	ExportedAt string               `json:"exportedAt"`
+	NodeID     string               `json:"nodeId"`
+	NodeName   string               `json:"nodeName"`
+	Files      []*zipManifestFile   `json:"files"`
+	Folders    []*zipManifestFolder `json:"folders"`
+}
+
+type zipManifestFolder struct {
+	Path     string            `json:"path"`
+	NodeID   string            `json:"nodeId"`
+	NodeType model.NodeType    `json:"nodeType"`
+	Metadata []*model.Metadata `json:"metadata,omitempty"`
+}
+
+type zipManifestFile struct {
+	Path        string            `json:"path"`
+	FileID      string            `json:"fileId"`
+	NodeID      string            `json:"nodeId"`
+	Name        string            `json:"name"`
+	Size        int               `json:"size"`
+	ContentType string            `json:"contentType"`
+	FileType    model.FileType    `json:"fileType"`
+	PUID        string            `json:"puid,omitempty"`
+	CreatedAt   string            `json:"createdAt"`
+	SHA256      string            `json:"sha256"`
+	Metadata    []*model.Metadata `json:"metadata,omitempty"`
+}
+
+// zipExport skriver ett nodträd till ett ZIP-arkiv. Kataloger läggs under rotnodens namn
+// och manifesten sist, när kontrollsummorna för alla filer är kända.
+type zipExport struct {
+	ctx       context.Context
+	db        *sql.DB
+	job       *jobContext
+	writer    *zip.Writer
+	manifest  *zipManifest
+	total     int
+	processed int
+	bytes     int64
+}
+
+// loadPermittedSubtree läser in nodens underträd utan de undernoder användaren inte får se.
+// En undernod som inte får ses utelämnas med hela sitt underträd, som i nodlistningen.
+func loadPermittedSubtree(ctx context.Context, db *sql.DB, nodeID string) (*treeNode, error) {
+	hasPermission, err := checkPermission(ctx, db, nodeID, PERM_VIEW)
+	if err != nil {
+		return nil, err
+	}
+	if !hasPermission {
+		return nil, serviceErrorf(errPermissionDenied, "permission denied: cannot view this node")
+	}
+
+	root, err := loadSubtree(db, nodeID)
+	if err != nil {
+		return nil, err
+	}
+	if err := pruneSubtree(ctx, db, root); err != nil {
+		return nil, err
+	}
+	return root, nil
+}
+
+// pruneSubtree tar bort barnnoder som användaren inte har läsbehörighet till
+func pruneSubtree(ctx context.Context, db *sql.DB, node *treeNode) error {
+	children := node.Children[:0]
+	for _, child := range node.Children {
+		allowed, err := checkPermission(ctx, db, child.ID, PERM_VIEW)
+		if err != nil {
+			return err
+		}
+		if !allowed {
+			continue
+		}
+		if err := pruneSubtree(ctx, db, child); err != nil {
+			return err
+		}
+		children = append(children, child)
+	}
+	node.Children = children
+	return nil
+}
+
+// writeZipExport skriver trädet som ZIP till w. Varje fil registreras som en strömmad läsning
+// i åtkomstloggen; en fil som inte kan loggas avbryter exporten.
+func writeZipExport(ctx context.Context, db *sql.DB, job *jobContext, root *treeNode, w io.Writer) (*ZipExportResult, error) {
+	export := &zipExport{
+		ctx:    ctx,
+		db:     db,
+		job:    job,
+		writer: zip.NewWriter(w),
+		total:  root.countFiles(),
+		manifest: &zipManifest{
+			Source:     "e-Arkive",
+			ExportedAt: time.Now().Format(time.RFC3339),
+			NodeID:     root.ID,
+			NodeName:   root.Name,
+			Files:      []*zipManifestFile{},
+			Folders:    []*zipManifestFolder{},
+		},
+	}
+
+	// Manifesten ligger i roten, så rotkatalogen får inte heta som dem
+	used := map[string]bool{zipManifestJSONFile: true, zipManifestCSVFile: true}
+	if err := export.writeNode(root, uniqueBagName(used, root.Name)); err != nil {
+		return nil, err
+	}
+
+	job.setProgress(95, "Writing manifest")
+	if err := export.writeManifest(); err != nil {
+		return nil, err
+	}
+	if err := export.writer.Close(); err != nil {
+		return nil, serviceErrorf(errInternal, "failed to finish ZIP archive: %v", err)
+	}
+
+	return &ZipExportResult{Files: len(export.manifest.Files), Bytes: export.bytes}, nil
+}
+
+// writeNode skriver en nods filer och barnnoder under katalogen dir i arkivet
+func (e *zipExport) writeNode(node *treeNode, dir string) error {
+	if _, err := e.writer.Create(dir + "/"); err != nil {
+		return serviceErrorf(errInternal, "failed to write directory %s: %v", dir, err)
+	}
+	e.manifest.Folders = append(e.manifest.Folders, &zipManifestFolder{
+		Path:     dir,
+		NodeID:   node.ID,
+		NodeType: node.NodeType,
+		Metadata: node.Metadata,
+	})
+
+	// Namn måste vara unika inom katalogen, även på filsystem som inte skiljer på versaler
+	used := make(map[string]bool)
+
+	for _, file := range node.Files {
+		if err := e.job.err(); err != nil {
+			return err
+		}
+		if err := RecordFileAccess(e.ctx, e.db, file.ID, model.FileAccessTypeStream); err != nil {
+			return err
+		}
+		data, err := readFileData(e.db, file.ID)
+		if err != nil {
+			return err
+		}
+
+		filePath := path.Join(dir, uniqueBagName(used, file.Name))
+		writer, err := e.writer.CreateHeader(&zip.FileHeader{Name: filePath, Method: zip.Deflate, Modified: parseZipTime(file.CreatedAt)})
+		if err != nil {
+			return serviceErrorf(errInternal, "failed to write %s: %v", filePath, err)
+		}
+		if _, err := writer.Write(data); err != nil {
+			return serviceErrorf(errInternal, "failed to write %s: %v", filePath, err)
+		}
+
+		e.manifest.Files = append(e.manifest.Files, &zipManifestFile{
+			Path:        filePath,
+			FileID:      file.ID,
+			NodeID:      node.ID,
+			Name:        file.Name,
+			Size:        len(data),
+			ContentType: file.ContentType,
+			FileType:    file.FileType,
+			PUID:        file.PUID,
+			CreatedAt:   file.CreatedAt,
+			SHA256:      checksumSHA256(data),
+			Metadata:    file.Metadata,
+		})
+		e.bytes += int64(len(data))
+
+		e.processed++
+		if e.total > 0 {
+			e.job.setProgress(e.processed*95/e.total, fmt.Sprintf("Exported %d of %d files", e.processed, e.total))
+		}
+	}
+
+	for _, child := range node.Children {
+		if err := e.writeNode(child, path.Join(dir, uniqueBagName(used, child.Name))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeManifest skriver manifestet som JSON och som CSV med en kolumn per metadatanyckel
+func (e *zipExport) writeManifest() error {
+	manifestJSON, err := json.MarshalIndent(e.manifest, "", "  ")
+	if err != nil {
+		return serviceErrorf(errInternal, "failed to encode manifest: %v", err)
+	}
+	writer, err := e.writer.Create(zipManifestJSONFile)
+	if err != nil {
+		return serviceErrorf(errInternal, "failed to write manifest: %v", err)
+	}
+	if _, err := writer.Write(manifestJSON); err != nil {
+		return serviceErrorf(errInternal, "failed to write manifest: %v", err)
+	}
+
+	keySet := make(map[string]bool)
+	for _, file := range e.manifest.Files {
+		for _, meta := range file.Metadata {
+			keySet[meta.Key] = true
+		}
+	}
+	keys := make([]string, 0, len(keySet))
+	for key := range keySet {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	writer, err = e.writer.Create(zipManifestCSVFile)
+	if err != nil {
+		return serviceErrorf(errInternal, "failed to write manifest: %v", err)
+	}
+	csvWriter := csv.NewWriter(writer)
+	header := append([]string{"path", "fileId", "nodeId", "name", "size", "contentType", "fileType", "puid", "createdAt", "sha256"}, keys...)
+	if err := csvWriter.Write(header); err != nil {
+		return serviceErrorf(errInternal, "failed to write manifest: %v", err)
+	}
+	for _, file := range e.manifest.Files {
+		// Flera värden för samma nyckel skrivs i samma cell, åtskilda med |
+		values := make(map[string][]string)
+		for _, meta := range file.Metadata {
+			values[meta.Key] = append(values[meta.Key], meta.Value)
+		}
+		record := []string{file.Path, file.FileID, file.NodeID, file.Name, fmt.Sprint(file.Size),
+			file.ContentType, string(file.FileType), file.PUID, file.CreatedAt, file.SHA256}
+		for _, key := range keys {
+			record = append(record, strings.Join(values[key], " | "))
+		}
+		if err := csvWriter.Write(record); err != nil {
+			return serviceErrorf(errInternal, "failed to write manifest: %v", err)
+		}
+	}
+	csvWriter.Flush()
+	return csvWriter.Error()
+}
+
+// parseZipTime tolkar en tidpunkt från databasen för arkivets filhuvuden
+func parseZipTime(value string) time.Time {
+	for _, layout := range []string{time.RFC3339, "2006-01-02 15:04:05"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t
+		}
+	}
+	return time.Now()
+}
+
+// zipExportFileName ger arkivets filnamn vid nedladdning
+func zipExportFileName(root *treeNode) string {
+	return sanitizeFileName(root.Name) + ".zip"
+}
+
+// ---------- Jobb ----------
+
+// startZipExportJob kontrollerar behörigheten och lägger exporten i jobbkön. Klientens IP-adress
+// och User-Agent sparas med jobbet så att åtkomstloggen visar varifrån exporten begärdes.
+func startZipExportJob(ctx context.Context, db *sql.DB, nodeID string, userID string) (*model.Job, error) {
+	hasPermission, err := checkPermission(ctx, db, nodeID, PERM_VIEW)
+	if err != nil {
+		return nil, err
+	}
+	if !hasPermission {
+		return nil, serviceErrorf(errPermissionDenied, "permission denied: cannot view this node")
+	}
+
+	payload := zipExportPayload{NodeID: nodeID, ClientIP: clientIPFromContext(ctx), UserAgent: userAgentFromContext(ctx)}
+	return enqueueJob(db, JOB_TYPE_ZIP_EXPORT, userID, payload, nil)
+}
+
+// zipExportPayload är parametrarna för ett exportjobb
+type zipExportPayload struct {
+	NodeID    string `json:"nodeId"`
+	ClientIP  string `json:"clientIp,omitempty"`
+	UserAgent string `json:"userAgent,omitempty"`
+}
+
+// runZipExportJob skriver arkivet till exports/ som användaren som startade jobbet, så att
+// behörigheter och åtkomstloggning gäller den användaren
+func runZipExportJob(job *jobContext) (string, error) {
+	var payload zipExportPayload
+	if err := job.decodePayload(&payload); err != nil {
+		return "", err
+	}
+	ctx := job.userContext(payload.ClientIP, payload.UserAgent)
+
+	job.setProgress(0, "Reading node tree")
+	root, err := loadPermittedSubtree(ctx, job.db, payload.NodeID)
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(exportBaseDir, 0755); err != nil {
+		return "", serviceErrorf(errInternal, "failed to create export directory: %v", err)
+	}
+	outFile := filepath.Join(exportBaseDir, fmt.Sprintf("zip-%s-%s.zip", job.id, time.Now().Format("20060102150405")))
+	out, err := os.Create(outFile)
+	if err != nil {
+		return "", serviceErrorf(errInternal, "failed to create %s: %v", outFile, err)
+	}
+
+	result, err := writeZipExport(ctx, job.db, job, root, out)
+	if closeErr := out.Close(); err == nil && closeErr != nil {
+		err = serviceErrorf(errInternal, "failed to write %s: %v", outFile, closeErr)
+	}
+	if err != nil {
+		os.Remove(outFile)
+		return "", err
+	}
+
+	logAction(fmt.Sprintf("ZIP export of node %s written to %s", payload.NodeID, outFile))
+	result.File = outFile
+	result.DownloadURL = fmt.Sprintf("%s?jobId=%s", ZipExportPath, job.id)
+	return encodeJobResult(result)
+}
+
+// ---------- HTTP ----------
+
+// ZipExportHandler strömmar en nod med underträd som ZIP (?nodeId=<id>) eller lämnar ut arkivet
+// från ett färdigt exportjobb (?jobId=<id>). Anroparens token ska finnas i request-context.
+// Varje försök skrivs till revisionsloggen som exportZip, även de som nekas.
+func ZipExportHandler(db *sql.DB) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		ctx := r.Context()
+		entry := AuditEntry{
+			Action:   "exportZip",
+			ClientIP: clientIPFromContext(ctx),
+			Outcome:  AUDIT_OUTCOME_SUCCESS,
+		}
+		entry.ActorID, entry.Actor = auditActor(ctx, db, nil, nil)
+		defer func() {
+			if err := RecordAuditEvent(db, entry); err != nil {
+				log.Printf("Error recording audit event for ZIP export: %v", err)
+			}
+		}()
+		fail := func(message string, status int) {
+			entry.Outcome = AUDIT_OUTCOME_FAILURE
+			entry.Error = message
+			http.Error(w, message, status)
+		}
+
+		jobID := r.URL.Query().Get("jobId")
+		nodeID := r.URL.Query().Get("nodeId")
+		switch {
+		case jobID != "":
+			entry.TargetType, entry.TargetID = "job", jobID
+			entry.Arguments = fmt.Sprintf(`{"jobId":%q}`, jobID)
+		case nodeID != "":
+			entry.TargetType, entry.TargetID = "node", nodeID
+			entry.Arguments = fmt.Sprintf(`{"nodeId":%q}`, nodeID)
+		}
+
+		if _, err := getUserIDFromContext(ctx); err != nil {
+			fail(err.Error(), http.StatusUnauthorized)
+			return
+		}
+		if jobID != "" {
+			if message, status := serveZipExportJob(w, r, db, jobID); message != "" {
+				fail(message, status)
+			}
+			return
+		}
+		if nodeID == "" {
+			fail("nodeId or jobId is required", http.StatusBadRequest)
+			return
+		}
+		logAction(fmt.Sprintf("Streaming ZIP export of node %s", nodeID))
+
+		root, err := loadPermittedSubtree(ctx, db, nodeID)
+		if err != nil {
+			fail(err.Error(), zipExportErrorStatus(err))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/zip")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", zipExportFileName(root)))
+
+		// Svaret har redan börjat skickas, så ett fel kan bara avbryta arkivet
+		if _, err := writeZipExport(ctx, db, nil, root, w); err != nil {
+			log.Printf("Error streaming ZIP export of node %s: %v", nodeID, err)
+			entry.Outcome = AUDIT_OUTCOME_FAILURE
+			entry.Error = err.Error()
+		}
+	})
+}
+
+// serveZipExportJob lämnar ut arkivet från ett lyckat exportjobb till den som startade det.
+// Returnerar felmeddelandet och statusen om arkivet inte kan lämnas ut, utan att ha svarat.
+func serveZipExportJob(w http.ResponseWriter, r *http.Request, db *sql.DB, jobID string) (string, int) {
+	job, err := getJobForUser(r.Context(), db, jobID)
+	if err != nil {
+		return err.Error(), zipExportErrorStatus(err)
+	}
+	if job.Type != JOB_TYPE_ZIP_EXPORT || job.Status != model.JobStatusSucceeded || job.Result == nil {
+		return "job is not a finished ZIP export", http.StatusNotFound
+	}
+
+	var result ZipExportResult
+	if err := json.Unmarshal([]byte(*job.Result), &result); err != nil || result.File == "" {
+		return "job has no ZIP archive", http.StatusNotFound
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filepath.Base(result.File)))
+	http.ServeFile(w, r, result.File)
+	return "", 0
+}
+
+// zipExportErrorStatus väljer HTTP-status för fel som uppstår innan arkivet börjar skickas
+func zipExportErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, errPermissionDenied):
+		return http.StatusForbidden
+	case errors.Is(err, errNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, errNotAuthenticated):
+		return http.StatusUnauthorized
+	default:
+		return http.StatusInternalServerError
+	}
+}
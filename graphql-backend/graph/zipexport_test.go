@@ -0,0 +1,194 @@
+package graph
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"graphql-backend/graph/model"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// testZipExportRequest hämtar en ZIP-export med användarens token, eller utan token om den är tom
+func testZipExportRequest(t *testing.T, handler http.Handler, token string, query string) *httptest.ResponseRecorder {
+	t.Helper()
+	request := httptest.NewRequest(http.MethodGet, ZipExportPath+"?"+query, nil)
+	if token != "" {
+		request.Header.Set("Authorization", "Bearer "+token)
+	}
+	recorder := httptest.NewRecorder()
+	withTestBearerToken(handler).ServeHTTP(recorder, request)
+	return recorder
+}
+
+// readTestZip läser alla poster i ett ZIP-arkiv, med sökvägen som nyckel
+func readTestZip(t *testing.T, data []byte) (map[string]string, []string) {
+	t.Helper()
+	reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("read ZIP archive: %v", err)
+	}
+	contents := make(map[string]string)
+	var names []string
+	for _, file := range reader.File {
+		content, err := file.Open()
+		if err != nil {
+			t.Fatalf("open %s: %v", file.Name, err)
+		}
+		data, err := io.ReadAll(content)
+		content.Close()
+		if err != nil {
+			t.Fatalf("read %s: %v", file.Name, err)
+		}
+		contents[file.Name] = string(data)
+		names = append(names, file.Name)
+	}
+	return contents, names
+}
+
+func TestZipExport(t *testing.T) {
+	db := openTestDB(t)
+	admin := testAdminContext(t)
+	bobID := insertTestUser(t, db, "bob")
+	bobToken, err := generateJWT(bobID, "bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := ZipExportHandler(db)
+
+	root := createTestNode(t, db, "Arkiv", nil)
+	open := createTestNode(t, db, "Offentlig", &root.ID)
+	secret := createTestNode(t, db, "Hemlig", &root.ID)
+	for _, nodeID := range []string{root.ID, open.ID} {
+		if _, err := NewResolver(db).Mutation().SetNodeOwnership(admin, nodeID, &bobID, nil); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := NewResolver(db).Mutation().SetNodePermissions(admin, nodeID, PERM_VIEW); err != nil {
+			t.Fatal(err)
+		}
+	}
+	letter, err := NewResolver(db).Mutation().SaveFile(admin, model.FileInput{
+		Name: "brev.txt", Size: 6, ContentType: "text/plain", FileData: "YnJldmV0", NodeID: &root.ID,
+		Metadata: []*model.MetadataInput{{Key: "amnesord", Value: "bygg"}, {Key: "amnesord", Value: "tillstånd"}, {Key: "diarienummer", Value: "2026/14"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	saveTestFile(t, db, "Brev.txt", root.ID, "kopia")
+	saveTestFile(t, db, "plan.txt", open.ID, "planen")
+	saveTestFile(t, db, "utredning.txt", secret.ID, "hemligt")
+
+	recorder := testZipExportRequest(t, handler, bobToken, "nodeId="+root.ID)
+	if recorder.Code != http.StatusOK || recorder.Header().Get("Content-Disposition") != `attachment; filename="Arkiv.zip"` {
+		t.Fatalf("stream export: status %d, %s", recorder.Code, recorder.Body.String())
+	}
+	// Mappstrukturen bevaras, namn som bara skiljer sig i versaler blir unika och noder som
+	// användaren inte får se utelämnas
+	contents, names := readTestZip(t, recorder.Body.Bytes())
+	want := "Arkiv/ Arkiv/Brev.txt Arkiv/brev-2.txt Arkiv/Offentlig/ Arkiv/Offentlig/plan.txt manifest.json manifest.csv"
+	if got := strings.Join(names, " "); got != want {
+		t.Errorf("entries:\n%s\nwant:\n%s", got, want)
+	}
+	if contents["Arkiv/brev-2.txt"] != "brevet" || contents["Arkiv/Offentlig/plan.txt"] != "planen" {
+		t.Errorf("file contents = %q, %q", contents["Arkiv/brev-2.txt"], contents["Arkiv/Offentlig/plan.txt"])
+	}
+
+	var manifest zipManifest
+	if err := json.Unmarshal([]byte(contents["manifest.json"]), &manifest); err != nil {
+		t.Fatalf("decode manifest: %v", err)
+	}
+	if manifest.NodeID != root.ID || len(manifest.Files) != 3 || len(manifest.Folders) != 2 {
+		t.Fatalf("manifest = %+v", manifest)
+	}
+	letterEntry := manifest.Files[1]
+	if letterEntry.FileID != letter.ID || letterEntry.SHA256 != checksumSHA256([]byte("brevet")) || letterEntry.Size != 6 || len(letterEntry.Metadata) != 3 {
+		t.Errorf("manifest file = %+v", letterEntry)
+	}
+	records, err := csv.NewReader(strings.NewReader(contents["manifest.csv"])).ReadAll()
+	if err != nil || len(records) != 4 {
+		t.Fatalf("CSV manifest = %v, %v", records, err)
+	}
+	// En kolumn per metadatanyckel, och flera värden i samma cell
+	if header := strings.Join(records[0][len(records[0])-3:], ","); header != "amnesord,author,diarienummer" {
+		t.Errorf("metadata columns = %s", header)
+	}
+	if row := records[2]; row[0] != "Arkiv/brev-2.txt" || row[9] != letterEntry.SHA256 || row[10] != "bygg | tillstånd" || row[11] != "" || row[12] != "2026/14" {
+		t.Errorf("CSV row = %v", row)
+	}
+
+	tests := []struct {
+		name   string
+		token  string
+		query  string
+		status int
+	}{
+		{"without token", "", "nodeId=" + root.ID, http.StatusUnauthorized},
+		{"without node", bobToken, "", http.StatusBadRequest},
+		{"node without permission", bobToken, "nodeId=" + secret.ID, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		if recorder := testZipExportRequest(t, handler, tt.token, tt.query); recorder.Code != tt.status {
+			t.Errorf("%s: status %d, want %d", tt.name, recorder.Code, tt.status)
+		}
+	}
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM audit_events WHERE action = 'exportZip'").Scan(&count); err != nil || count != 4 {
+		t.Errorf("audit events = %d, %v, want one per request", count, err)
+	}
+}
+
+func TestZipExportJob(t *testing.T) {
+	db := openTestDB(t)
+	t.Chdir(t.TempDir())
+	handler := ZipExportHandler(db)
+	admin := testAdminContext(t)
+	adminToken, err := generateJWT("1", "admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bobID := insertTestUser(t, db, "bob")
+	bobToken, err := generateJWT(bobID, "bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	root := createTestNode(t, db, "Arkiv", nil)
+	saveTestFile(t, db, "brev.txt", root.ID, "brevet")
+
+	if _, err := NewResolver(db).Mutation().StartZipExport(testUserContext(t, bobID, "bob"), root.ID); err == nil || err.Error() != "permission denied: cannot view this node" {
+		t.Errorf("start export without permission: err = %v", err)
+	}
+	queued, err := NewResolver(db).Mutation().StartZipExport(admin, root.ID)
+	if err != nil {
+		t.Fatalf("start export: %v", err)
+	}
+	// Arkivet kan inte hämtas innan jobbet är klart
+	if recorder := testZipExportRequest(t, handler, adminToken, "jobId="+queued.ID); recorder.Code != http.StatusNotFound {
+		t.Errorf("download queued export: status %d", recorder.Code)
+	}
+
+	job := runQueuedTestJob(t, db)
+	if job.Status != model.JobStatusSucceeded || job.Result == nil {
+		t.Fatalf("job = %s (%v), want SUCCEEDED", job.Status, job.Error)
+	}
+	var result ZipExportResult
+	if err := json.Unmarshal([]byte(*job.Result), &result); err != nil || result.Files != 1 || result.DownloadURL != ZipExportPath+"?jobId="+job.ID {
+		t.Fatalf("result = %+v, %v", result, err)
+	}
+
+	recorder := testZipExportRequest(t, handler, adminToken, "jobId="+job.ID)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("download export: status %d, %s", recorder.Code, recorder.Body.String())
+	}
+	if contents, _ := readTestZip(t, recorder.Body.Bytes()); contents["Arkiv/brev.txt"] != "brevet" {
+		t.Errorf("downloaded archive = %v", contents)
+	}
+	// Bara den som startade jobbet och administratörer får hämta arkivet
+	if recorder := testZipExportRequest(t, handler, bobToken, "jobId="+job.ID); recorder.Code != http.StatusForbidden {
+		t.Errorf("download as another user: status %d", recorder.Code)
+	}
+}
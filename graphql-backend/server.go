@@ -5,19 +5,24 @@ import (
 	"bytes"
 	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
 	"graphql-backend/graph"
 	"log"
 	"net"
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
-
-	_ "github.com/mattn/go-sqlite3"
+	"time"
 
 	"github.com/99designs/gqlgen/graphql/handler"
 	"github.com/99designs/gqlgen/graphql/handler/extension"
 	"github.com/99designs/gqlgen/graphql/handler/lru"
 	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/gorilla/websocket"
 	"github.com/rs/cors"
 	"github.com/vektah/gqlparser/v2/ast"
 )
@@ -27,10 +32,18 @@ import (
 // =============================================
 
 // Globala variabler
-var db *sql.DB // Databasanslutningen som delas genom hela applikationen
+var db *sql.DB              // Databasanslutningen som delas genom hela applikationen
+var dbDialect graph.Dialect // Databasens dialekt, SQLite eller PostgreSQL
+var appConfig *Config       // Konfigurationen som lästes vid start, se config.go
 
 // Standardport för servern om ingen annan specificerats
-const defaultPort = "8080"
+const defaultPort = 8080
+
+// Antal arbetare som kör bakgrundsjobb om inget annat konfigurerats
+const defaultJobWorkers = 2
+
+// Hur ofta servern pingar websocket-anslutningar för prenumerationer så att de hålls öppna
+const websocketKeepAlive = 10 * time.Second
 
 // =============================================
 // ========== HJÄLPSTRUKTURER ================
@@ -51,39 +64,46 @@ func (rl *responseLogger) Write(b []byte) (int, error) {
 // ========== DATABAS-FUNKTIONER =============
 // =============================================
 
-// initDB initierar anslutningen till SQLite-databasen
-// Skapar en ny databasfil om den inte redan finns
+// initDB initierar anslutningen till databasen och för schemat till senaste version.
+// Är database.update_script satt körs skriptet först mot en SQLite-databas, se README.
 func initDB() {
-	var err error
-	connString := "./e-Arkive.db"
-	db, err = sql.Open("sqlite3", connString)
-	if err != nil {
-		log.Fatalf("Failed to connect to SQLite database: %v", err)
-	}
-
-	// Verifiera databasanslutningen
-	if err = db.Ping(); err != nil {
-		log.Fatalf("Failed to ping SQLite database: %v", err)
-	}
+	openDB()
 
 	// Skapa tabellerna om de inte redan finns
 	createTables()
 
-	log.Println("Connected to SQLite database successfully!")
+	log.Printf("Connected to %s database successfully!", dbDialect)
 }
 
-// createTables skapar alla nödvändiga tabeller i databasen om de inte redan finns
-func createTables() {
-	// Läs SQL från filen
-	sqlBytes, err := os.ReadFile("update_database.sql")
+// openDB öppnar anslutningen till den konfigurerade databasen, utan att köra migreringarna.
+// Används direkt av kommandoradsverktygen som arbetar mot en befintlig databas.
+func openDB() {
+	var err error
+	db, dbDialect, err = graph.OpenDatabase(appConfig.Database.DSN)
 	if err != nil {
-		log.Fatalf("Failed to read update_database.sql: %v", err)
+		log.Fatalf("Failed to open database: %v", err)
 	}
+}
 
-	// Exekvera SQL-skriptet
-	_, err = db.Exec(string(sqlBytes))
-	if err != nil {
-		log.Fatalf("Failed to execute SQL script: %v", err)
+// createTables återställer utvecklingsdatabasen i SQLite och kör sedan migreringarna. Utan
+// återställningsskript behålls SQLite-databasen mellan starterna.
+func createTables() {
+	if dbDialect == graph.DialectSQLite && appConfig.Database.UpdateScript != "" {
+		// Läs SQL från filen
+		sqlBytes, err := os.ReadFile(appConfig.Database.UpdateScript)
+		if err != nil {
+			log.Fatalf("Failed to read %s: %v", appConfig.Database.UpdateScript, err)
+		}
+
+		// Exekvera SQL-skriptet
+		_, err = db.Exec(string(sqlBytes))
+		if err != nil {
+			log.Fatalf("Failed to execute SQL script: %v", err)
+		}
+	}
+
+	if err := graph.Migrate(db, dbDialect); err != nil {
+		log.Fatalf("Failed to migrate database: %v", err)
 	}
 
 	log.Println("Database tables created or updated successfully")
@@ -99,11 +119,63 @@ func logRequest(r *http.Request) {
 	log.Printf("%s %s %s", r.Method, r.URL.Path, r.RemoteAddr)
 }
 
+// clientIP hämtar klientens IP-adress från förfrågan, utan portnummer
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// requestContext lägger klientens IP-adress, User-Agent och token från Authorization-headern i
+// förfrågans context, så att resolvers och HTTP-ändpunkter kan identifiera användaren
+func requestContext(r *http.Request) context.Context {
+	// Lägg klientens IP-adress och User-Agent i context för revisions- och åtkomstloggen
+	ctx := graph.WithClientIP(r.Context(), clientIP(r))
+	ctx = graph.WithUserAgent(ctx, r.UserAgent())
+
+	// Extract JWT token from Authorization header
+	authHeader := r.Header.Get("Authorization")
+	if authHeader == "" {
+		// Try Authenticate header if Authorization is not present
+		authHeader = r.Header.Get("Authenticate")
+	}
+
+	if authHeader != "" {
+		// Check if the header contains a Bearer token
+		if strings.HasPrefix(authHeader, "Bearer ") {
+			token := strings.TrimPrefix(authHeader, "Bearer ")
+			// Add token to context using both keys for compatibility
+			ctx = context.WithValue(ctx, "Authorization", token)
+			ctx = context.WithValue(ctx, "Authenticate", token)
+		}
+	}
+
+	return ctx
+}
+
 // logAction loggar viktiga händelser i systemet
 func logAction(action string) {
 	log.Printf("[ACTION] %s", action)
 }
 
+// checkWebsocketOrigin tillåter websocket-anslutningar från frontend, från samma värd och från
+// klienter utan webbläsare som inte skickar Origin
+func checkWebsocketOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" || origin == "http://"+r.Host || origin == "https://"+r.Host {
+		return true
+	}
+	for _, allowed := range appConfig.Server.CORSOrigins {
+		if origin == allowed {
+			return true
+		}
+	}
+	log.Printf("Rejected websocket connection from origin %s", origin)
+	return false
+}
+
 // getLocalIP hämtar serverns lokala IP-adress
 // Används för att visa korrekt serveradress i loggarna
 func getLocalIP() string {
@@ -131,7 +203,13 @@ func setupGraphQLHandler() *handler.Server {
 	// Skapar en ny GraphQL-server med vår schema och resolver
 	srv := handler.New(graph.NewExecutableSchema(graph.Config{Resolvers: graph.NewResolver(db)}))
 
-	// Konfigurerar tillåtna transportmetoder
+	// Konfigurerar tillåtna transportmetoder. Prenumerationer går över websocket och
+	// autentiseras med token i anslutningens init-payload.
+	srv.AddTransport(transport.Websocket{
+		KeepAlivePingInterval: websocketKeepAlive,
+		Upgrader:              websocket.Upgrader{CheckOrigin: checkWebsocketOrigin},
+		InitFunc:              graph.WebsocketInit,
+	})
 	srv.AddTransport(transport.Options{})
 	srv.AddTransport(transport.GET{})
 	srv.AddTransport(transport.POST{})
@@ -145,6 +223,11 @@ func setupGraphQLHandler() *handler.Server {
 		Cache: lru.New[string](100),
 	})
 
+	// Skriver alla mutationer och filnedladdningar till revisionsloggen
+	srv.AroundFields(graph.AuditMiddleware(db))
+	// Meddelar prenumeranter när mutationer ändrar noder och filer
+	srv.AroundFields(graph.ChangeEventMiddleware(db))
+
 	return srv
 }
 
@@ -154,24 +237,12 @@ func setupQueryEndpoint(srv *handler.Server) {
 		logRequest(r)
 		logAction("GraphQL query received")
 
-		// Extract JWT token from Authorization header
-		ctx := r.Context()
-		authHeader := r.Header.Get("Authorization")
-		if authHeader == "" {
-			// Try Authenticate header if Authorization is not present
-			authHeader = r.Header.Get("Authenticate")
-		}
+		r = r.WithContext(requestContext(r))
 
-		if authHeader != "" {
-			// Check if the header contains a Bearer token
-			if strings.HasPrefix(authHeader, "Bearer ") {
-				token := strings.TrimPrefix(authHeader, "Bearer ")
-				// Add token to context using both keys for compatibility
-				ctx = context.WithValue(ctx, "Authorization", token)
-				ctx = context.WithValue(ctx, "Authenticate", token)
-				// Create a new request with the updated context
-				r = r.WithContext(ctx)
-			}
+		// En websocket-anslutning tar över förbindelsen och har inget svar att logga
+		if websocket.IsWebSocketUpgrade(r) {
+			srv.ServeHTTP(w, r)
+			return
 		}
 
 		responseRecorder := &responseLogger{ResponseWriter: w}
@@ -180,6 +251,57 @@ func setupQueryEndpoint(srv *handler.Server) {
 	})
 }
 
+// setupOAIEndpoint konfigurerar /oai-endpointen där skördare hämtar publicerade filer med OAI-PMH.
+// Arkivets namn, namnrymd och kontaktadress anges i konfigurationen.
+func setupOAIEndpoint() {
+	config := graph.OAIConfig{
+		RepositoryName:       appConfig.OAI.RepositoryName,
+		RepositoryIdentifier: appConfig.OAI.RepositoryIdentifier,
+		AdminEmail:           appConfig.OAI.AdminEmail,
+	}
+	oaiHandler := graph.OAIHandler(db, config)
+
+	http.HandleFunc("/oai", func(w http.ResponseWriter, r *http.Request) {
+		logRequest(r)
+		oaiHandler.ServeHTTP(w, r)
+	})
+}
+
+// setupExportEndpoint konfigurerar /export/zip där inloggade användare hämtar nodträd som ZIP-arkiv
+func setupExportEndpoint() {
+	exportHandler := graph.ZipExportHandler(db)
+
+	http.HandleFunc(graph.ZipExportPath, func(w http.ResponseWriter, r *http.Request) {
+		logRequest(r)
+		exportHandler.ServeHTTP(w, r.WithContext(requestContext(r)))
+	})
+}
+
+// setupWebDAVEndpoint konfigurerar /dav/ där nodträdet kan monteras som en nätverksenhet i
+// filhanteraren. Inloggning sker med token eller med användarnamn och lösenord.
+func setupWebDAVEndpoint() {
+	davHandler := graph.WebDAVHandler(db)
+
+	handle := func(w http.ResponseWriter, r *http.Request) {
+		logRequest(r)
+		davHandler.ServeHTTP(w, r.WithContext(requestContext(r)))
+	}
+	http.HandleFunc(graph.WebDAVPath, handle)
+	// Utan avslutande snedstreck skulle ServeMux svara med en omdirigering som inte alla klienter följer
+	http.HandleFunc(strings.TrimSuffix(graph.WebDAVPath, "/"), handle)
+}
+
+// setupRestAPIEndpoint konfigurerar /api/v1/ med REST-API:t för noder, filer och användare.
+// OpenAPI-dokumentet finns på /api/v1/openapi.json.
+func setupRestAPIEndpoint() {
+	restHandler := graph.RestAPIHandler(db)
+
+	http.HandleFunc(graph.RestAPIPath, func(w http.ResponseWriter, r *http.Request) {
+		logRequest(r)
+		restHandler.ServeHTTP(w, r.WithContext(requestContext(r)))
+	})
+}
+
 // setupStaticEndpoints konfigurerar ändpunkter för statiska resurser (GraphiQL, sandbox etc.)
 func setupStaticEndpoints() {
 	http.HandleFunc("/graphiql", func(w http.ResponseWriter, r *http.Request) {
@@ -187,8 +309,9 @@ func setupStaticEndpoints() {
 		http.ServeFile(w, r, "graphiql.html")
 	})
 
+	page := sandboxHTML(appConfig.Server.SandboxEndpoint)
 	http.Handle("/sandbox", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Write(sandboxHTML)
+		w.Write(page)
 	}))
 }
 
@@ -198,46 +321,114 @@ func setupStaticEndpoints() {
 
 // main är huvudfunktionen som startar servern
 func main() {
+	// Läser konfigurationen från fil, miljövariabler och flaggor
+	config, args, err := loadConfig(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	appConfig = config
+	graph.SetJWTSecret(appConfig.Auth.JWTSecret)
+	graph.SetTokenLifetime(appConfig.Auth.TokenLifetime)
+	graph.SetExportDir(appConfig.Storage.ExportDir)
+	graph.SetImportDir(appConfig.Storage.ImportDir)
+	graph.SetWebhookRetryDelays(appConfig.Webhooks.RetryBaseDelay, appConfig.Webhooks.RetryMaxDelay)
+	graph.SetWebDAVLockTimeouts(appConfig.WebDAV.LockDefaultTimeout, appConfig.WebDAV.LockMaxTimeout)
+	graph.SetBackupOptions(graph.BackupOptions{Dir: appConfig.Backup.Dir, BlobDirs: appConfig.Backup.BlobDirs})
+
+	// Kör ett kommandoradskommando i stället för servern om ett sådant angetts
+	if len(args) > 0 {
+		os.Exit(runCommand(args))
+	}
+
+	if appConfig.Auth.JWTSecret == graph.DefaultJWTSecret {
+		log.Println("Warning: using the development JWT secret, set auth.jwt_secret or JWT_SECRET in production")
+	}
+
 	// Initierar databasen
 	initDB()
 
-	// Konfigurerar serverporten
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = defaultPort
+	// Startar arbetarna som kör bakgrundsjobb, inklusive jobb som avbröts vid förra stoppet
+	graph.StartJobWorkers(db, appConfig.Jobs.Workers)
+
+	// Startar arbetaren som skickar webhookleveranser, inklusive leveranser som väntar på omförsök
+	graph.StartWebhookWorker(db)
+
+	// Startar schemalagda säkerhetskopior om ett intervall angetts
+	if appConfig.Backup.Interval > 0 {
+		if dbDialect == graph.DialectSQLite {
+			graph.StartBackupScheduler(db, appConfig.Backup.Interval, appConfig.Backup.Keep)
+		} else {
+			log.Printf("Scheduled backups are only supported for SQLite, use pg_dump for PostgreSQL")
+		}
 	}
 
+	// Konfigurerar serverporten
+	port := strconv.Itoa(appConfig.Server.Port)
+
 	// Konfigurerar GraphQL-servern
 	srv := setupGraphQLHandler()
 
 	// Konfigurerar endpoints
 	setupQueryEndpoint(srv)
+	setupOAIEndpoint()
+	setupExportEndpoint()
+	setupWebDAVEndpoint()
+	setupRestAPIEndpoint()
 	setupStaticEndpoints()
 
 	localIP := getLocalIP()
 
-	// Konfigurerar CORS för att tillåta anrop från frontend
-	handlerWithCORS := cors.New(cors.Options{
-		AllowedOrigins: []string{"http://localhost:5173"},
-		AllowedMethods: []string{"GET", "POST", "OPTIONS"},
-		AllowedHeaders: []string{"Content-Type", "Authorization"},
-	}).Handler(http.DefaultServeMux)
+	handlerWithCORS := corsHandler(appConfig.Server.CORSOrigins, http.DefaultServeMux)
 
 	// Loggar serverinformation
 	log.Printf("Server is running at http://%s:%s/query", localIP, port)
+	log.Printf("Subscriptions are available at ws://%s:%s/query", localIP, port)
 	log.Printf("GraphiQL is available at http://%s:%s/graphiql", localIP, port)
 	log.Printf("Sandbox is available at http://%s:%s/sandbox", localIP, port)
+	log.Printf("OAI-PMH is available at http://%s:%s/oai", localIP, port)
+	log.Printf("ZIP export is available at http://%s:%s%s", localIP, port, graph.ZipExportPath)
+	log.Printf("WebDAV is available at http://%s:%s%s", localIP, port, graph.WebDAVPath)
+	log.Printf("REST API is available at http://%s:%s%s (OpenAPI: %sopenapi.json)", localIP, port, graph.RestAPIPath, graph.RestAPIPath)
 
 	log.Printf("Server is starting on port %s", port)
 	log.Fatal(http.ListenAndServe(":"+port, handlerWithCORS))
 }
 
+// corsHandler tillåter anrop från frontend till GraphQL, REST-API:t och WebDAV, med de metoder
+// och huvuden som de använder
+func corsHandler(origins []string, next http.Handler) http.Handler {
+	return cors.New(cors.Options{
+		AllowedOrigins: origins,
+		AllowedMethods: []string{
+			"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE", "OPTIONS",
+			"PROPFIND", "PROPPATCH", "MKCOL", "COPY", "MOVE", "LOCK", "UNLOCK",
+		},
+		AllowedHeaders: []string{
+			"Content-Type", "Authorization",
+			"Depth", "Destination", "Overwrite", "If", "Lock-Token", "Timeout",
+		},
+		// Filnamnet för nedladdade filer och ZIP-exporter, och WebDAV-svarens lås och versioner
+		ExposedHeaders: []string{"Content-Disposition", "DAV", "ETag", "Lock-Token"},
+	}).Handler(next)
+}
+
 // =============================================
 // ========== STATISKA RESURSER ==============
 // =============================================
 
-// sandboxHTML innehåller HTML för GraphQL Playground
-var sandboxHTML = []byte(`
+// sandboxHTML ger HTML för GraphQL Playground, som skickar frågor till endpoint
+func sandboxHTML(endpoint string) []byte {
+	// JSON-kodningen ger en JavaScript-sträng där även < och > är kodade
+	quoted, _ := json.Marshal(endpoint)
+	return []byte(strings.Replace(sandboxTemplate, "{{endpoint}}", string(quoted), 1))
+}
+
+// sandboxTemplate är sidan för GraphQL Playground, där {{endpoint}} ersätts med GraphQL-adressen
+const sandboxTemplate = `
 <!DOCTYPE html>
 <html lang="en">
 <body style="margin: 0; overflow-x: hidden; overflow-y: hidden">
@@ -248,9 +439,9 @@ var sandboxHTML = []byte(`
    target: "#sandbox",
    // Pass through your server href if you are embedding on an endpoint.
    // Otherwise, you can pass whatever endpoint you want Sandbox to start up with here.
-   initialEndpoint: "http://localhost:8080/query",
+   initialEndpoint: {{endpoint}},
  });
  // advanced options: https://www.apollographql.com/docs/studio/explorer/sandbox#embedding-sandbox
 </script>
 </body>
-</html>`)
+</html>`